@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// legacyAdminActor is the actor of requests authenticated with admin_token.
+const legacyAdminActor = "admin_token"
+
+// adminAuth holds the credentials and listener of the /admin/* route group.
+type adminAuth struct {
+	keys map[string]string // Bearer token by actor name
+
+	listen       string // Listener of the admin routes; "" serves them on the main listener
+	tlsCertFile  string
+	tlsKeyFile   string
+	clientCAFile string
+}
+
+// newAdminAuth returns the admin group of cfg, or nil when admin routes are disabled.
+func newAdminAuth(cfg *config.Config) *adminAuth {
+	if !cfg.AdminEnabled() {
+		return nil
+	}
+	a := cfg.Admin
+	if a == nil {
+		return &adminAuth{keys: map[string]string{legacyAdminActor: cfg.AdminToken}}
+	}
+	return &adminAuth{
+		keys:         a.APIKeys,
+		listen:       a.Listen,
+		tlsCertFile:  a.TLSCertFile,
+		tlsKeyFile:   a.TLSKeyFile,
+		clientCAFile: a.ClientCAFile,
+	}
+}
+
+// onMainListener reports whether the admin routes are served on the main listener.
+func (a *adminAuth) onMainListener() bool {
+	return a != nil && a.listen == ""
+}
+
+// onSeparateListener reports whether the admin routes have a listener of their own.
+func (a *adminAuth) onSeparateListener() bool {
+	return a != nil && a.listen != ""
+}
+
+// authenticate returns the actor a request authenticates as: the common name of a
+// verified client certificate, or the name of the API key in its bearer token.
+func (a *adminAuth) authenticate(r *http.Request) (string, bool) {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return "cn=" + r.TLS.VerifiedChains[0][0].Subject.CommonName, true
+	}
+	header := r.Header.Get("Authorization")
+	actor, ok := "", false
+	// Every key is compared so the time taken does not reveal which one matched
+	for name, key := range a.keys {
+		if validAdminAuth(header, key) {
+			actor, ok = name, true
+		}
+	}
+	return actor, ok
+}
+
+// auditAdminAction logs an admin request with its actor and outcome.
+func auditAdminAction(actor, client, method, uri string, status int) {
+	if actor == "" {
+		actor = "-"
+	}
+	log.Printf("Admin audit: actor=%s client=%s action=%q status=%d", actor, client, method+" "+uri, status)
+}
+
+// requireAdmin rejects requests that do not carry admin credentials, and writes every
+// admin request, allowed or not, to the audit log.
+func (h *HTTPProxy) requireAdmin(c *gin.Context) {
+	actor, ok := h.ps.admin.authenticate(c.Request)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing admin token"})
+		auditAdminAction("", c.ClientIP(), c.Request.Method, c.Request.URL.RequestURI(), http.StatusUnauthorized)
+		return
+	}
+	c.Next()
+	auditAdminAction(actor, c.ClientIP(), c.Request.Method, c.Request.URL.RequestURI(), c.Writer.Status())
+}
+
+// registerAdminRoutes mounts the /admin/* route group behind requireAdmin. It is only
+// called when the group is enabled; otherwise its paths are unknown.
+func (h *HTTPProxy) registerAdminRoutes(engine *gin.Engine) {
+	admin := engine.Group("/admin", h.requireAdmin)
+	admin.POST("/refresh", h.handleAdminRefresh)
+	admin.POST("/journal/replay", h.handleJournalReplay)
+	admin.POST("/upgrade", h.handleAdminUpgrade)
+	admin.GET("/analytics/resources", h.handleResourceAnalytics)
+}
+
+// newAdminHTTPServer builds the server of a separate admin listener, serving only the
+// admin route group and, when pprof is enabled, /debug/pprof/, over TLS when a
+// certificate is configured.
+func (h *HTTPProxy) newAdminHTTPServer() (*http.Server, error) {
+	a := h.ps.admin
+	engine := newGinEngine(h.ps.ecsLog)
+	engine.HandleMethodNotAllowed = true
+	if err := engine.SetTrustedProxies(h.ps.trustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted_proxies: %w", err)
+	}
+	h.registerAdminRoutes(engine)
+	if h.ps.pprof {
+		mux := http.NewServeMux()
+		registerPprof(mux, a)
+		engine.Any("/debug/pprof/*profile", gin.WrapH(mux))
+	}
+	engine.NoRoute(handleNoRoute)
+	engine.NoMethod(handleNoMethod)
+
+	srv := &http.Server{
+		Addr:         a.listen,
+		Handler:      engine,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+	if a.tlsCertFile == "" {
+		return srv, nil
+	}
+	cert, err := tls.LoadX509KeyPair(a.tlsCertFile, a.tlsKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load admin TLS certificate: %w", err)
+	}
+	srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if a.clientCAFile != "" {
+		pem, err := os.ReadFile(a.clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read admin client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in admin client CA file %s", a.clientCAFile)
+		}
+		srv.TLSConfig.ClientCAs = pool
+		srv.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return srv, nil
+}
+
+// serveAdmin binds the separate admin listener, if any, and serves it in the background.
+func (h *HTTPProxy) serveAdmin() error {
+	if h.adminSrv == nil {
+		return nil
+	}
+	listener, err := net.Listen("tcp", h.adminSrv.Addr)
+	if err != nil {
+		return fmt.Errorf("%w: failed to listen on admin address %s: %w", ErrListen, h.adminSrv.Addr, err)
+	}
+	h.adminListener = listener
+	log.Printf("Admin routes listening on %s", listener.Addr())
+	srv := h.adminSrv
+	go func() {
+		var err error
+		if srv.TLSConfig != nil {
+			err = srv.ServeTLS(listener, "", "")
+		} else {
+			err = srv.Serve(listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, net.ErrClosed) {
+			log.Printf("Admin server error: %v", err)
+		}
+	}()
+	return nil
+}
+
+// releaseAdminListener stops accepting admin connections, so that a new binary taking
+// over during an upgrade can bind the admin address. Requests in flight, such as the
+// upgrade itself, are still answered.
+func (h *HTTPProxy) releaseAdminListener() {
+	if h.adminListener != nil {
+		h.adminListener.Close()
+		h.adminReleased = true
+	}
+}
+
+// restoreAdminListener binds the admin listener again after a failed upgrade released it.
+func (h *HTTPProxy) restoreAdminListener() {
+	if !h.adminReleased {
+		return
+	}
+	h.adminReleased = false
+	if err := h.serveAdmin(); err != nil {
+		log.Printf("Failed to restore admin listener after upgrade failure: %v", err)
+	}
+}
+
+// shutdownAdmin gracefully stops the separate admin listener, if any.
+func (h *HTTPProxy) shutdownAdmin(ctx context.Context) {
+	if h.adminSrv == nil {
+		return
+	}
+	if err := h.adminSrv.Shutdown(ctx); err != nil {
+		log.Printf("Admin server forced to shutdown: %v", err)
+	}
+}
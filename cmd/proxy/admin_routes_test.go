@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureLog sends the standard logger to a buffer for the rest of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+// adminRequest sends POST path to handler with an optional bearer token.
+func adminRequest(handler http.Handler, path, token string) int {
+	req := httptest.NewRequest("POST", path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w.Code
+}
+
+// TestAdminRouteGroup tests that admin routes exist only when enabled, authenticate
+// each API key as its actor, and are audit logged.
+func TestAdminRouteGroup(t *testing.T) {
+	backend := proxytest.NewBackend([]proxytest.Tool{{Name: "tool1"}}, nil)
+	defer backend.Close()
+	servers := []config.MCPServerConfig{{Name: "server1", Address: backend.URL}}
+
+	ps, err := NewProxyServer(&config.Config{MCPServers: servers})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	disabled, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, adminRequest(disabled.engine, "/admin/refresh", "secret"))
+
+	ps, err = NewProxyServer(&config.Config{MCPServers: servers, Admin: &config.AdminConfig{
+		Enabled: true,
+		APIKeys: map[string]string{"alice": "alice-key", "deploy-bot": "bot-key"},
+	}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	logs := captureLog(t)
+	assert.Equal(t, http.StatusOK, adminRequest(httpProxy.engine, "/admin/refresh?server=server1", "bot-key"))
+	assert.Equal(t, http.StatusUnauthorized, adminRequest(httpProxy.engine, "/admin/refresh", "wrong"))
+	assert.Equal(t, []proxytest.AuditEntry{
+		{Actor: "deploy-bot", Client: "192.0.2.1", Action: "POST /admin/refresh?server=server1", Status: http.StatusOK},
+		{Actor: "-", Client: "192.0.2.1", Action: "POST /admin/refresh", Status: http.StatusUnauthorized},
+	}, proxytest.AuditEntries(logs.String()))
+}
+
+// writeTestCert creates a certificate signed by parent (self-signed when nil), writes it
+// and its key as PEM files in dir, and returns it for signing or use.
+func writeTestCert(t *testing.T, dir, name string, template *x509.Certificate, parent *tls.Certificate) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := template, interface{}(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+".pem"), certPEM, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+"-key.pem"), keyPEM, 0o600))
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	cert.Leaf, err = x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+// TestAdminListenerMTLS tests admin routes on a separate TLS listener that requires
+// client certificates: they are absent from the main listener, and a verified
+// certificate authenticates as its common name.
+func TestAdminListenerMTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := writeTestCert(t, dir, "ca", &x509.Certificate{
+		Subject: pkix.Name{CommonName: "test CA"}, IsCA: true, BasicConstraintsValid: true,
+		KeyUsage: x509.KeyUsageCertSign,
+	}, nil)
+	writeTestCert(t, dir, "server", &x509.Certificate{
+		Subject: pkix.Name{CommonName: "localhost"}, IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, &ca)
+	client := writeTestCert(t, dir, "client", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "alice-laptop"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, &ca)
+
+	backend := proxytest.NewBackend([]proxytest.Tool{{Name: "tool1"}}, nil)
+	defer backend.Close()
+	ps, err := NewProxyServer(&config.Config{
+		MCPServers: []config.MCPServerConfig{{Name: "server1", Address: backend.URL}},
+		Admin: &config.AdminConfig{
+			Enabled:      true,
+			Listen:       "127.0.0.1:0",
+			TLSCertFile:  filepath.Join(dir, "server.pem"),
+			TLSKeyFile:   filepath.Join(dir, "server-key.pem"),
+			ClientCAFile: filepath.Join(dir, "ca.pem"),
+		},
+	})
+	require.NoError(t, err)
+	httpProxy, err := NewHTTPProxy(ps, "127.0.0.1:0")
+	require.NoError(t, err)
+	require.NoError(t, httpProxy.serve())
+	defer httpProxy.Shutdown(t.Context())
+
+	assert.Equal(t, http.StatusNotFound, adminRequest(httpProxy.engine, "/admin/refresh", ""))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+	url := "https://" + httpProxy.adminListener.Addr().String() + "/admin/refresh"
+	withCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{client}}}}
+	withoutCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+
+	logs := captureLog(t)
+	resp, err := withCert.Post(url, "application/json", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	entries := proxytest.AuditEntries(logs.String())
+	require.Len(t, entries, 1)
+	assert.Equal(t, "cn=alice-laptop", entries[0].Actor)
+
+	_, err = withoutCert.Post(url, "application/json", nil)
+	assert.Error(t, err, "the TLS handshake should require a client certificate")
+}
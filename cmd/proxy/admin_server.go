@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing JSON response: %v", err)
+	}
+}
+
+// newAdminServer builds the minimal monitoring server used alongside command mode.
+// It serves only /metrics, /healthz, /readyz, /servers and /status, plus
+// /admin/analytics/resources when admin is configured and /debug/pprof/ when pprof is
+// enabled, both behind admin credentials.
+func newAdminServer(ps *ProxyServer) *http.Server {
+	registerMetrics()
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, healthzResponse(ps))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		status, body := readinessResponse(ps)
+		writeJSON(w, status, body)
+	})
+	mux.HandleFunc("/servers", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"servers": ps.ListServers()})
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ps.statusFor(r))
+	})
+	if ps.admin != nil {
+		mux.Handle("/admin/analytics/resources", requireAdminHandler(ps.admin, func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, ps.ResourceAnalytics())
+		}))
+	}
+	if ps.pprof {
+		registerPprof(mux, ps.admin)
+	}
+
+	return &http.Server{
+		Handler:      mux,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+}
+
+// requireAdminHandler serves h only to requests with admin credentials, audit logging
+// each one.
+func requireAdminHandler(admin *adminAuth, h http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor, ok := admin.authenticate(r)
+		if !ok {
+			writeJSON(w, http.StatusUnauthorized, map[string]interface{}{"error": "invalid or missing admin token"})
+			auditAdminAction("", r.RemoteAddr, r.Method, r.URL.RequestURI(), http.StatusUnauthorized)
+			return
+		}
+		auditAdminAction(actor, r.RemoteAddr, r.Method, r.URL.RequestURI(), http.StatusOK)
+		h(w, r)
+	})
+}
+
+// startAdminServer binds the admin listener and serves it in the background.
+// It returns the bound address, which differs from addr when a port of 0 is used.
+func startAdminServer(srv *http.Server, addr string) (string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	go func() {
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("Admin server error: %v", err)
+		}
+	}()
+	log.Printf("Admin server listening on %s", listener.Addr())
+	return listener.Addr().String(), nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrArgumentLimit is returned for tool calls whose arguments exceed
+// max_argument_bytes or max_argument_depth. They are not forwarded.
+var ErrArgumentLimit = errors.New("tool arguments exceed a configured limit")
+
+// checkArgumentLimits rejects arguments nested deeper than max_argument_depth or
+// larger than max_argument_bytes once encoded. A zero limit, from -1 in the
+// configuration, is not checked.
+func (ps *ProxyServer) checkArgumentLimits(arguments map[string]interface{}) error {
+	if ps.maxArgumentDepth > 0 {
+		if depth := argumentDepth(arguments); depth > ps.maxArgumentDepth {
+			return fmt.Errorf("%w: nesting depth %d exceeds max_argument_depth %d", ErrArgumentLimit, depth, ps.maxArgumentDepth)
+		}
+	}
+	if ps.maxArgumentBytes > 0 {
+		encoded, err := json.Marshal(arguments)
+		if err != nil {
+			return fmt.Errorf("%w: failed to marshal arguments: %v", ErrInternalProxy, err)
+		}
+		if size := int64(len(encoded)); size > ps.maxArgumentBytes {
+			return fmt.Errorf("%w: %d bytes exceed max_argument_bytes %d", ErrArgumentLimit, size, ps.maxArgumentBytes)
+		}
+	}
+	return nil
+}
+
+// argumentDepth returns the nesting depth of a decoded JSON value: 0 for a scalar, and
+// one more than its deepest element for an object or array.
+func argumentDepth(value interface{}) int {
+	deepest := 0
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, elem := range v {
+			deepest = max(deepest, argumentDepth(elem))
+		}
+	case []interface{}:
+		for _, elem := range v {
+			deepest = max(deepest, argumentDepth(elem))
+		}
+	default:
+		return 0
+	}
+	return deepest + 1
+}
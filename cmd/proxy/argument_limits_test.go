@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestArgumentLimits tests that tool calls with arguments over max_argument_bytes or
+// max_argument_depth are rejected before reaching the backend, over HTTP and in
+// command mode.
+func TestArgumentLimits(t *testing.T) {
+	backend := proxytest.NewBackend([]proxytest.Tool{{Name: "echo"}}, nil)
+	defer backend.Close()
+	ps, err := NewProxyServer(&config.Config{
+		MCPServers:       []config.MCPServerConfig{{Name: "server1", Address: backend.URL}},
+		MaxArgumentBytes: 64,
+		MaxArgumentDepth: 3,
+	})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+	cmdProxy, err := NewCommandProxy(ps)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		arguments string
+		rejected  string // Part of the error; "" when the call is forwarded
+	}{
+		{"within limits", `{"a":{"b":[1,2]}}`, ""},
+		{"over size", `{"text":"` + strings.Repeat("x", 64) + `"}`, "max_argument_bytes 64"},
+		{"over depth", `{"a":{"b":[{"c":1}]}}`, "nesting depth 4 exceeds max_argument_depth 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := len(backend.Calls())
+
+			req := httptest.NewRequest("POST", "/tool/echo", strings.NewReader(tt.arguments))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			httpProxy.engine.ServeHTTP(w, req)
+
+			respBytes, err := cmdProxy.handleCommandRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"echo","arguments":` + tt.arguments + `}}`))
+			require.NoError(t, err)
+			var resp jsonRPCResponse
+			require.NoError(t, json.Unmarshal(respBytes, &resp))
+
+			if tt.rejected == "" {
+				assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+				assert.Nil(t, resp.Error)
+				assert.Len(t, backend.Calls(), calls+2)
+				return
+			}
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tt.rejected)
+			require.NotNil(t, resp.Error)
+			assert.Equal(t, -32602, resp.Error.Code)
+			assert.Contains(t, resp.Error.Message, tt.rejected)
+			assert.Len(t, backend.Calls(), calls, "rejected arguments must not be forwarded")
+		})
+	}
+}
+
+// TestArgumentDepth tests the nesting depth of decoded JSON values.
+func TestArgumentDepth(t *testing.T) {
+	assert.Equal(t, 0, argumentDepth("scalar"))
+	assert.Equal(t, 1, argumentDepth(map[string]interface{}{}))
+	assert.Equal(t, 1, argumentDepth(map[string]interface{}{"a": 1, "b": "x"}))
+	assert.Equal(t, 3, argumentDepth(map[string]interface{}{"a": []interface{}{map[string]interface{}{"b": 1}}, "c": 2}))
+}
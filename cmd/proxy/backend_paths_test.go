@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPrefixedServer is a backend serving every route under /api/v1, with tool "echo"
+// and resource "docs". Resource responses echo the requested path.
+func testPrefixedServer() (*httptest.Server, config.MCPServerConfig) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/tools", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tools":[{"name":"echo","inputSchema":{"type":"object"}}]}`))
+	})
+	mux.HandleFunc("/api/v1/resources", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"resources":[{"name":"docs"}]}`))
+	})
+	mux.HandleFunc("POST /api/v1/tool/{name}", func(w http.ResponseWriter, r *http.Request) {
+		text := "called " + r.PathValue("name")
+		json.NewEncoder(w).Encode(config.CallToolResult{Content: []config.ContentBlock{{Type: "text", Text: &text}}})
+	})
+	mux.HandleFunc("/api/v1/resource/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"path":%q}`, r.URL.Path)
+	})
+	server := httptest.NewServer(mux)
+	return server, config.MCPServerConfig{
+		Name:                 "prefixed",
+		Address:              server.URL,
+		ToolPathTemplate:     "/api/v1/tool/{name}",
+		ResourcePathTemplate: "/api/v1/resource/{name}",
+		ToolsPath:            "/api/v1/tools",
+		ResourcesPath:        "/api/v1/resources",
+	}
+}
+
+// TestHTTPCustomBackendPaths tests discovery, tool calls and resource requests against
+// a backend whose routes are configured with non-default paths.
+func TestHTTPCustomBackendPaths(t *testing.T) {
+	backend, conf := testPrefixedServer()
+	defer backend.Close()
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{conf}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/tool/echo", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var result config.CallToolResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	require.Len(t, result.Content, 1)
+	require.NotNil(t, result.Content[0].Text)
+	assert.Equal(t, "called echo", *result.Content[0].Text)
+
+	req = httptest.NewRequest("GET", "/resource/prefixed/docs/guide", nil)
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"path":"/api/v1/resource/docs/guide"}`, w.Body.String())
+}
+
+// TestCommandCustomBackendPaths tests command-mode resource access against a backend
+// with a custom resource_path_template.
+func TestCommandCustomBackendPaths(t *testing.T) {
+	backend, conf := testPrefixedServer()
+	defer backend.Close()
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{conf}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	cmdProxy, err := NewCommandProxy(ps)
+	require.NoError(t, err)
+
+	req := `{"jsonrpc":"2.0","id":1,"method":"resources/access","params":{"serverName":"prefixed","resourceName":"docs","proxyPath":"guide","method":"GET"}}`
+	respBytes, err := cmdProxy.handleCommandRequest([]byte(req))
+	require.NoError(t, err)
+	assert.Contains(t, string(respBytes), `/api/v1/resource/docs/guide`)
+}
+
+// TestRewriteURLsCustomResourcePath tests that links under a custom resource path
+// template are rewritten to the proxy's resource route.
+func TestRewriteURLsCustomResourcePath(t *testing.T) {
+	server := &config.MCPServer{Config: config.MCPServerConfig{Name: "prefixed", Address: "http://backend:9000", ResourcePathTemplate: "/api/v1/resource/{name}"}}
+	out := &ProxyResponseOutput{
+		Headers: http.Header{"Content-Type": {"application/json"}},
+		Body:    []byte(`{"next":"http://backend:9000/api/v1/resource/docs/page2","home":"http://backend:9000/"}`),
+	}
+	rewriteBaseURLs(out, server, "https://mcp.example.com")
+	assert.JSONEq(t, `{"next":"https://mcp.example.com/resource/prefixed/docs/page2","home":"https://mcp.example.com/"}`, string(out.Body))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBufferLimitExceeded is returned when buffering a request body would push the
+// total number of in-flight buffered bytes over the configured ceiling.
+var ErrBufferLimitExceeded = errors.New("request body buffer limit exceeded")
+
+// bufferRetryAfter is the backoff hint sent with requests rejected by ErrBufferLimitExceeded.
+const bufferRetryAfter = time.Second
+
+// bufferLimitMessage is the error shown to clients rejected by ErrBufferLimitExceeded.
+const bufferLimitMessage = "proxy is buffering too much request data, retry later"
+
+// bodyBudget accounts for request bodies that are fully buffered in memory.
+// A zero limit disables the ceiling but still tracks usage for the gauge.
+type bodyBudget struct {
+	limit int64
+	inUse atomic.Int64
+}
+
+// newBodyBudget creates a bodyBudget with the given ceiling in bytes.
+func newBodyBudget(limit int64) *bodyBudget {
+	return &bodyBudget{limit: limit}
+}
+
+// reserve tries to account for n more buffered bytes, returning false if that would exceed the limit.
+func (b *bodyBudget) reserve(n int64) bool {
+	for {
+		current := b.inUse.Load()
+		next := current + n
+		if b.limit > 0 && next > b.limit {
+			return false
+		}
+		if b.inUse.CompareAndSwap(current, next) {
+			b.observe(next)
+			return true
+		}
+	}
+}
+
+// release gives back n previously reserved bytes.
+func (b *bodyBudget) release(n int64) {
+	if n == 0 {
+		return
+	}
+	b.observe(b.inUse.Add(-n))
+}
+
+// observe publishes the current buffered byte count to the Prometheus gauge, if registered.
+func (b *bodyBudget) observe(current int64) {
+	if bufferedBodyBytes != nil { // Check if initialized
+		bufferedBodyBytes.Set(float64(current))
+	}
+}
+
+// readAll buffers r fully while charging every chunk against the budget.
+// The returned release function must be called once the bytes are no longer needed.
+// If the budget is exhausted part way through, everything reserved so far is
+// released and ErrBufferLimitExceeded is returned, as a ThrottleError.
+func (b *bodyBudget) readAll(r io.Reader) ([]byte, func(), error) {
+	if r == nil {
+		return nil, func() {}, nil
+	}
+	cr := &budgetedReader{r: r, budget: b}
+	data, err := io.ReadAll(cr)
+	if err != nil {
+		b.release(cr.reserved)
+		return nil, func() {}, err
+	}
+	var once atomic.Bool
+	return data, func() {
+		if once.CompareAndSwap(false, true) {
+			b.release(cr.reserved)
+		}
+	}, nil
+}
+
+// budgetedReader reserves budget for every chunk read from the underlying reader.
+type budgetedReader struct {
+	r        io.Reader
+	budget   *bodyBudget
+	reserved int64
+}
+
+func (br *budgetedReader) Read(p []byte) (int, error) {
+	n, err := br.r.Read(p)
+	if n > 0 {
+		if !br.budget.reserve(int64(n)) {
+			return 0, throttled(throttleOverloaded, bufferRetryAfter, ErrBufferLimitExceeded)
+		}
+		br.reserved += int64(n)
+	}
+	return n, err
+}
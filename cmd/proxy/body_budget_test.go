@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBodyBudgetReadAll tests that buffered bytes are tracked and released.
+func TestBodyBudgetReadAll(t *testing.T) {
+	budget := newBodyBudget(10)
+
+	data, release, err := budget.readAll(strings.NewReader("12345"))
+	require.NoError(t, err)
+	assert.Equal(t, "12345", string(data))
+	assert.Equal(t, int64(5), budget.inUse.Load())
+
+	// A second body that would exceed the ceiling is rejected and leaves no residue
+	_, _, err = budget.readAll(strings.NewReader("1234567890"))
+	assert.ErrorIs(t, err, ErrBufferLimitExceeded)
+	assert.Equal(t, int64(5), budget.inUse.Load())
+
+	release()
+	release() // Releasing twice must not double-credit the budget
+	assert.Equal(t, int64(0), budget.inUse.Load())
+}
+
+// TestBodyBudgetUnlimited tests that a zero limit never rejects.
+func TestBodyBudgetUnlimited(t *testing.T) {
+	budget := newBodyBudget(0)
+	data, release, err := budget.readAll(strings.NewReader(strings.Repeat("x", 1<<16)))
+	require.NoError(t, err)
+	assert.Len(t, data, 1<<16)
+	release()
+	assert.Equal(t, int64(0), budget.inUse.Load())
+}
+
+// TestHTTPBufferLimitExceeded tests that requests over the ceiling get 503 with Retry-After.
+func TestHTTPBufferLimitExceeded(t *testing.T) {
+	httpProxy, ps, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+	ps.bodyBudget = newBodyBudget(4)
+
+	req := httptest.NewRequest("POST", "/tool/tool1", strings.NewReader(`{"arg1": "value1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"error":"`+bufferLimitMessage+`","reason":"overloaded","retryAfterMs":1000}`, w.Body.String())
+
+	req = httptest.NewRequest("POST", "/resource/server1/res1/action", strings.NewReader(`{"action":"start"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// Small bodies still fit
+	req = httptest.NewRequest("POST", "/tool/tool1", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(0), ps.bodyBudget.inUse.Load())
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+)
+
+// defaultCallTimeout bounds a one-shot tool call when -timeout is not given.
+const defaultCallTimeout = 60 * time.Second
+
+// ErrCallTimeout is returned when a one-shot tool call does not finish within -timeout.
+var ErrCallTimeout = errors.New("tool call timed out")
+
+// runCallCommand implements `smart-mcp-proxy call -tool name [-args json] [-raw]
+// [-timeout d] [-config path]`. It starts only the server owning the tool, with the
+// servers it depends on, calls it once and prints the result as JSON, or with -raw the
+// text of its text blocks. It returns 0 for a successful call, 1 when the call fails or the result is
+// an error, and 2 for invalid usage.
+func runCallCommand(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("call", flag.ContinueOnError)
+	configPathFlag := fs.String("config", "", "Path to MCP proxy config file")
+	toolFlag := fs.String("tool", "", "Name of the tool to call")
+	argsFlag := fs.String("args", "{}", "Tool arguments as a JSON object")
+	rawFlag := fs.Bool("raw", false, "Print the text of text content blocks instead of the result JSON")
+	timeoutFlag := fs.Duration("timeout", defaultCallTimeout, "Maximum time for starting the servers and calling the tool")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *toolFlag == "" || fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "usage: smart-mcp-proxy call -tool name [-args json] [-raw] [-timeout duration] [-config path]")
+		return 2
+	}
+	var arguments map[string]interface{}
+	if err := json.Unmarshal([]byte(*argsFlag), &arguments); err != nil || arguments == nil {
+		fmt.Fprintf(os.Stderr, "-args must be a JSON object: %s\n", *argsFlag)
+		return 2
+	}
+
+	configPath := *configPathFlag
+	if configPath == "" {
+		configPath = os.Getenv("MCP_PROXY_CONFIG")
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		log.Printf("failed to load config: %v", err)
+		return 1
+	}
+	candidates := callCandidates(cfg, *toolFlag)
+	if len(candidates) == 0 {
+		log.Printf("%v: %s", ErrToolNotFound, *toolFlag)
+		return 1
+	}
+
+	result, err := callOnce(cfg, candidates, *toolFlag, arguments, *timeoutFlag)
+	if err != nil {
+		log.Printf("tool call failed: %v", err)
+		return 1
+	}
+	if err := printCallResult(stdout, result, *rawFlag); err != nil {
+		log.Printf("failed to write result: %v", err)
+		return 1
+	}
+	if result.IsError {
+		return 1
+	}
+	return 0
+}
+
+// callOnce starts the first of candidates that discovers the tool, calls it and shuts
+// the servers down again, giving up once timeout has passed. A single candidate is
+// called without checking that it discovered the tool, as its allow-list names it or
+// no other server may serve it.
+func callOnce(cfg *config.Config, candidates []string, toolName string, arguments map[string]interface{}, timeout time.Duration) (*config.CallToolResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	type outcome struct {
+		result *config.CallToolResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	var mu sync.Mutex
+	var calling *ProxyServer // Shut down by the caller once the call ends or times out
+	go func() {
+		err := fmt.Errorf("%w: %s", ErrToolNotFound, toolName)
+		for _, name := range candidates {
+			if ctx.Err() != nil {
+				break
+			}
+			// Servers still starting are shut down once up; startup discovery is
+			// bounded by discovery_timeout_seconds
+			serverCfg := *cfg
+			serverCfg.MCPServers = withDependencies(cfg.MCPServers, name)
+			ps, startErr := NewProxyServer(&serverCfg)
+			if startErr != nil {
+				err = startErr
+				continue
+			}
+			if len(candidates) > 1 && !slices.Contains(ps.servers.toolServers(toolName), ps.findMCPServerByName(name)) {
+				log.Printf("MCP server %s does not provide tool %s", name, toolName)
+				ps.Shutdown()
+				continue
+			}
+			mu.Lock()
+			if ctx.Err() != nil {
+				mu.Unlock()
+				ps.Shutdown()
+				break
+			}
+			calling = ps
+			mu.Unlock()
+			result, err := ps.callToolFrom(ctx, "", toolName, arguments, nil)
+			done <- outcome{result, err}
+			return
+		}
+		done <- outcome{err: err}
+	}()
+	shutdown := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if calling != nil {
+			calling.Shutdown()
+		}
+	}
+
+	select {
+	case out := <-done:
+		shutdown()
+		return out.result, out.err
+	case <-ctx.Done():
+		shutdown() // Ends a call still waiting for its server
+		<-done
+		return nil, fmt.Errorf("%w after %s: %s", ErrCallTimeout, timeout, toolName)
+	}
+}
+
+// callCandidates returns the names of the servers that may own toolName, in the order
+// they are tried: those whose allowed_tools names it or, when none does, those without
+// allowed_tools. Lazy servers whose lazy_cache_dir file lists the tool come first, and
+// those whose file does not are left out.
+func callCandidates(cfg *config.Config, toolName string) []string {
+	var allowing, unrestricted []config.MCPServerConfig
+	for _, server := range cfg.MCPServers {
+		switch {
+		case !server.IsEnabled():
+		case len(server.AllowedTools) == 0:
+			unrestricted = append(unrestricted, server)
+		case server.AllowsTool(toolName):
+			allowing = append(allowing, server)
+		}
+	}
+	if len(allowing) == 0 {
+		allowing = unrestricted
+	}
+
+	var cached, undiscovered []string
+	for _, server := range allowing {
+		tools, ok := cfg.CachedTools(server)
+		if !ok {
+			undiscovered = append(undiscovered, server.Name)
+			continue
+		}
+		if slices.ContainsFunc(tools, func(tool config.ToolInfo) bool { return tool.Name == toolName }) {
+			cached = append(cached, server.Name)
+		}
+	}
+	return append(cached, undiscovered...)
+}
+
+// withDependencies returns the named server and the servers it depends on,
+// transitively, in configuration order.
+func withDependencies(servers []config.MCPServerConfig, name string) []config.MCPServerConfig {
+	byName := make(map[string]config.MCPServerConfig, len(servers))
+	for _, server := range servers {
+		byName[server.Name] = server
+	}
+	needed := map[string]bool{name: true}
+	var addDependencies func(name string)
+	addDependencies = func(name string) {
+		for _, dep := range byName[name].DependsOn {
+			if !needed[dep] {
+				needed[dep] = true
+				addDependencies(dep)
+			}
+		}
+	}
+	addDependencies(name)
+
+	var selected []config.MCPServerConfig
+	for _, server := range servers {
+		if needed[server.Name] {
+			selected = append(selected, server)
+		}
+	}
+	return selected
+}
+
+// printCallResult writes result as indented JSON or, when raw, the text of each text
+// content block on a line of its own.
+func printCallResult(w io.Writer, result *config.CallToolResult, raw bool) error {
+	if !raw {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+	for _, block := range result.Content {
+		if block.Type != "text" || block.Text == nil {
+			continue
+		}
+		if _, err := fmt.Fprintln(w, *block.Text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// callBackend is a stdio backend that records its pid in $PID_FILE. Its greet tool
+// succeeds, fail returns a tool error and slow never answers.
+var callBackend = proxytest.StdioBackend{
+	Tools: []proxytest.Tool{{Name: "greet"}, {Name: "fail", Result: &proxytest.Result{Text: "disk full", IsError: true}}, {Name: "slow"}},
+	Calls: map[string]string{
+		"greet": proxytest.Reply(`{"content":[{"type":"text","text":"hello"},{"type":"text","text":"world"}]}`),
+		"slow":  ":",
+	},
+	Setup: `echo $$ > "$PID_FILE"`,
+}
+
+// TestCallCommand tests that the call subcommand starts only the server owning the
+// tool, prints the result, exits with the tool's error status and stops the server.
+func TestCallCommand(t *testing.T) {
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "greeter.pid")
+	otherStarted := filepath.Join(dir, "other.started")
+	cfg := config.Config{MCPServers: []config.MCPServerConfig{
+		{Name: "other", Command: "sh", Args: []string{"-c", "touch " + otherStarted + "; cat"}, AllowedTools: []string{"other_tool"}},
+		{Name: "greeter", Command: callBackend.Command(), Args: callBackend.Args(), Env: map[string]interface{}{"PID_FILE": pidFile},
+			AllowedTools: []string{"greet", "fail", "slow"}},
+	}}
+	data, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	configPath := filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(configPath, data, 0o644))
+
+	call := func(args ...string) (int, string) {
+		var out bytes.Buffer
+		code := runCallCommand(append([]string{"-config", configPath}, args...), &out)
+		return code, out.String()
+	}
+	assertStopped := func() {
+		t.Helper()
+		pidText, err := os.ReadFile(pidFile)
+		require.NoError(t, err)
+		pid, err := strconv.Atoi(strings.TrimSpace(string(pidText)))
+		require.NoError(t, err)
+		assert.ErrorIs(t, syscall.Kill(pid, 0), syscall.ESRCH, "the backend is stopped on exit")
+		require.NoError(t, os.Remove(pidFile))
+	}
+
+	code, out := call("-tool", "greet", "-args", `{"q":"foo"}`)
+	assert.Equal(t, 0, code)
+	var result config.CallToolResult
+	require.NoError(t, json.Unmarshal([]byte(out), &result), out)
+	require.Len(t, result.Content, 2)
+	assert.Equal(t, "hello", *result.Content[0].Text)
+	assert.False(t, result.IsError)
+	assertStopped()
+	assert.NoFileExists(t, otherStarted, "only the server owning the tool is started")
+
+	code, out = call("-tool", "greet", "-raw")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "hello\nworld\n", out)
+	assertStopped()
+
+	code, out = call("-tool", "fail", "-raw")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "disk full\n", out)
+	assertStopped()
+
+	code, out = call("-tool", "slow", "-timeout", "300ms")
+	assert.Equal(t, 1, code)
+	assert.Empty(t, out)
+	assertStopped()
+
+	code, _ = call("-tool", "missing")
+	assert.Equal(t, 1, code, "no server allows the tool")
+	code, _ = call("-tool", "greet", "-args", `["not an object"]`)
+	assert.Equal(t, 2, code)
+	code, _ = call("-args", `{}`)
+	assert.Equal(t, 2, code, "-tool is required")
+}
+
+// TestCallCommand_Owner tests that among servers without allowed_tools, the call goes
+// to the one discovering the tool, and that lazy servers whose cached tools do not
+// include it are not started.
+func TestCallCommand_Owner(t *testing.T) {
+	dir := t.TempDir()
+	started := func(name string) string { return filepath.Join(dir, name+".started") }
+	backend := func(name string, tools ...proxytest.Tool) config.MCPServerConfig {
+		b := proxytest.StdioBackend{Tools: tools, Setup: "touch " + started(name)}
+		return config.MCPServerConfig{Name: name, Command: b.Command(), Args: b.Args(), Lazy: true}
+	}
+	cfg := config.Config{
+		MCPServers: []config.MCPServerConfig{
+			backend("cached", proxytest.Tool{Name: "other"}),
+			backend("searcher", proxytest.Tool{Name: "search"}),
+			backend("greeter", proxytest.Tool{Name: "greet", Result: &proxytest.Result{Text: "hello"}}),
+		},
+		LazyCacheDir: filepath.Join(dir, "cache"),
+	}
+	require.NoError(t, os.MkdirAll(cfg.LazyCacheDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.LazyCacheDir, "cached.json"), []byte(`{"tools":[{"name":"other"}]}`), 0o644))
+
+	result, err := callOnce(&cfg, callCandidates(&cfg, "greet"), "greet", nil, 10*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", *result.Content[0].Text)
+	assert.NoFileExists(t, started("cached"), "the cache shows the server does not provide the tool")
+	assert.FileExists(t, started("searcher"), "discovered, as it has no cache")
+
+	// Discovery cached the tools of the servers it started
+	require.NoError(t, os.Remove(started("searcher")))
+	_, err = callOnce(&cfg, callCandidates(&cfg, "greet"), "greet", nil, 10*time.Second)
+	require.NoError(t, err)
+	assert.NoFileExists(t, started("searcher"))
+
+	_, err = callOnce(&cfg, callCandidates(&cfg, "missing"), "missing", nil, 10*time.Second)
+	assert.ErrorIs(t, err, ErrToolNotFound)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// capabilitiesBackend configures a stdio backend advertising capabilities.
+func capabilitiesBackend(name, capabilities string) config.MCPServerConfig {
+	backend := proxytest.StdioBackend{Tools: []proxytest.Tool{{Name: name + "_tool"}}, Capabilities: capabilities}
+	return config.MCPServerConfig{Name: name, Command: backend.Command(), Args: backend.Args()}
+}
+
+// TestInitializeCapabilities tests the capabilities the proxy advertises in initialize
+// for combinations of backends, over /mcp, which relays list_changed notifications, and
+// in command mode, which does not.
+func TestInitializeCapabilities(t *testing.T) {
+	rest := proxytest.NewBackend([]proxytest.Tool{{Name: "rest_tool"}}, nil)
+	defer rest.Close()
+
+	tests := []struct {
+		name        string
+		servers     []config.MCPServerConfig
+		wantMCP     string
+		wantCommand string
+	}{
+		{
+			name:        "backends without initialize",
+			servers:     []config.MCPServerConfig{{Name: "rest", Address: rest.URL}},
+			wantMCP:     `{"tools":{"listChanged":true},"resources":{"listChanged":true}}`,
+			wantCommand: `{"tools":{},"resources":{}}`,
+		},
+		{
+			name:        "tools only",
+			servers:     []config.MCPServerConfig{capabilitiesBackend("plain", `{"tools":{}}`)},
+			wantMCP:     `{"tools":{"listChanged":true},"resources":{"listChanged":true}}`,
+			wantCommand: `{"tools":{},"resources":{}}`,
+		},
+		{
+			name: "one backend supporting subscriptions, which are not relayed",
+			servers: []config.MCPServerConfig{
+				capabilitiesBackend("plain", `{"tools":{},"resources":{}}`),
+				capabilitiesBackend("files", `{"tools":{},"resources":{"subscribe":true}}`),
+			},
+			wantMCP:     `{"tools":{"listChanged":true},"resources":{"listChanged":true}}`,
+			wantCommand: `{"tools":{},"resources":{}}`,
+		},
+		{
+			name: "one backend with prompts, which are not served",
+			servers: []config.MCPServerConfig{
+				{Name: "rest", Address: rest.URL},
+				capabilitiesBackend("prompter", `{"tools":{},"prompts":{"listChanged":true}}`),
+			},
+			wantMCP:     `{"tools":{"listChanged":true},"resources":{"listChanged":true}}`,
+			wantCommand: `{"tools":{},"resources":{}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ps, err := NewProxyServer(&config.Config{MCPServers: tt.servers})
+			require.NoError(t, err)
+			defer ps.Shutdown()
+			httpProxy, err := NewHTTPProxy(ps, ":0")
+			require.NoError(t, err)
+			cmdProxy, err := NewCommandProxy(ps)
+			require.NoError(t, err)
+
+			w := mcpRequest(t, httpProxy, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			var resp struct {
+				Result struct {
+					Capabilities json.RawMessage `json:"capabilities"`
+				} `json:"result"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.JSONEq(t, tt.wantMCP, string(resp.Result.Capabilities))
+			session, ok := ps.sessions.get(w.Header().Get(sessionIDHeader))
+			require.True(t, ok)
+			sessionCapabilities, err := json.Marshal(session.ServerCapabilities)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.wantMCP, string(sessionCapabilities), "the session keeps the advertised capabilities")
+
+			respBytes, err := cmdProxy.handleCommandRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`))
+			require.NoError(t, err)
+			require.NoError(t, json.Unmarshal(respBytes, &resp))
+			assert.JSONEq(t, tt.wantCommand, string(resp.Result.Capabilities))
+		})
+	}
+}
+
+// TestInitializeCapabilities_Stable tests that a command mode client is advertised the
+// same capabilities on every initialize, even after the backends changed.
+func TestInitializeCapabilities_Stable(t *testing.T) {
+	client := newCommandClient()
+	first := client.advertise(func() map[string]interface{} { return map[string]interface{}{"tools": map[string]interface{}{}} })
+	second := client.advertise(func() map[string]interface{} {
+		return map[string]interface{}{"tools": map[string]interface{}{}, "prompts": map[string]interface{}{}}
+	})
+	assert.Equal(t, first, second)
+}
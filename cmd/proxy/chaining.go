@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clientHeader carries the identity of the client a chained proxy forwards a request
+// for: its IP in HTTP mode or its label in command mode.
+const clientHeader = "X-MCP-Client"
+
+// requestClientKey is the context key holding the client a tool call is made for.
+type requestClientKey struct{}
+
+// withRequestClient returns a context carrying the client of a tool call.
+func withRequestClient(ctx context.Context, client string) context.Context {
+	if client == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestClientKey{}, client)
+}
+
+// requestClient returns the client stored by withRequestClient, or "".
+func requestClient(ctx context.Context) string {
+	client, _ := ctx.Value(requestClientKey{}).(string)
+	return client
+}
+
+// logClient returns the client of a tool call for log lines, "-" when it has none.
+func logClient(ctx context.Context) string {
+	if client := requestClient(ctx); client != "" {
+		return client
+	}
+	return "-"
+}
+
+// setClientIdentity forwards the identity of client to a chained smart-mcp-proxy: in
+// X-MCP-Client and, for an IP, X-Forwarded-For. Other backends do not receive them.
+func setClientIdentity(header http.Header, server *config.MCPServer, client string) {
+	if !server.Config.IsChainedProxy() || client == "" {
+		return
+	}
+	header.Set(clientHeader, client)
+	if _, err := netip.ParseAddr(client); err == nil {
+		header.Set("X-Forwarded-For", client)
+	}
+}
+
+// clientIdentity returns the client of an HTTP request: the X-MCP-Client sent by a
+// chaining proxy listed in trusted_proxies, otherwise the client IP.
+func (h *HTTPProxy) clientIdentity(c *gin.Context) string {
+	if client := c.GetHeader(clientHeader); client != "" && h.ps.trustsProxy(c.RemoteIP()) {
+		return client
+	}
+	return c.ClientIP()
+}
+
+// trustsProxy reports whether ip is in trusted_proxies.
+func (ps *ProxyServer) trustsProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, proxy := range ps.trustedProxies {
+		if trusted, err := netip.ParseAddr(proxy); err == nil {
+			if trusted.Unmap() == addr {
+				return true
+			}
+		} else if prefix, err := netip.ParsePrefix(proxy); err == nil && prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestChainedProxies tests discovery, tool calls, resource requests and status
+// through a top-level proxy chaining a team proxy, which serves a backend.
+func TestChainedProxies(t *testing.T) {
+	backend := proxytest.NewBackend(
+		[]proxytest.Tool{{Name: "search"}, {Name: "secret"}},
+		[]proxytest.Resource{{Name: "doc", URI: "file:///doc", Body: `{"doc":true}`}},
+	)
+	defer backend.Close()
+
+	team, err := NewProxyServer(&config.Config{
+		MCPServers:     []config.MCPServerConfig{{Name: "backend", Address: backend.URL, AllowedTools: []string{"search"}}},
+		TrustedProxies: []string{"127.0.0.1"},
+	})
+	require.NoError(t, err)
+	defer team.Shutdown()
+	teamHTTP, err := NewHTTPProxy(team, ":0")
+	require.NoError(t, err)
+	teamServer := httptest.NewServer(teamHTTP.engine)
+	defer teamServer.Close()
+
+	top, err := NewProxyServer(&config.Config{
+		MCPServers: []config.MCPServerConfig{{Name: "team", Address: teamServer.URL, Type: config.BackendSmartMCPProxy}},
+	})
+	require.NoError(t, err)
+	defer top.Shutdown()
+	topHTTP, err := NewHTTPProxy(top, ":0")
+	require.NoError(t, err)
+
+	// Discovery keeps the team proxy's restrictions and where each entry comes from
+	tools := top.ListToolsWithMetadata()
+	require.Len(t, tools, 1)
+	assert.Equal(t, "search", tools[0].Name)
+	assert.Equal(t, "team", tools[0].ServerName)
+	assert.Equal(t, []RestrictedToolInfo{{
+		ToolInfo:      config.ToolInfo{Name: "secret", InputSchema: map[string]interface{}{"type": "object"}, RestrictedBy: config.FilterChainedProxy, ChainedServer: "backend"},
+		ServerName:    "team",
+		Filter:        config.FilterChainedProxy,
+		RestrictedBy:  config.RestrictedByChainedProxy,
+		ChainedServer: "backend",
+	}}, top.ListRestrictedTools())
+
+	// Tool calls reach the backend, and the team proxy records the original client
+	req := httptest.NewRequest("POST", "/tool/search", strings.NewReader(`{"q":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	topHTTP.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Len(t, backend.Calls(), 1)
+	assert.Equal(t, map[string]interface{}{"q": "x"}, backend.Calls()[0].Arguments)
+	assert.Equal(t, "192.0.2.1", team.Status().RecentCalls[0].Client)
+
+	// Resources are requested from the team proxy's route for the backend
+	w = httptest.NewRecorder()
+	topHTTP.engine.ServeHTTP(w, httptest.NewRequest("GET", "/resource/team/doc", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"doc":true}`, w.Body.String())
+
+	// A request that already reached max_hops is not forwarded again
+	req = httptest.NewRequest("POST", "/tool/search", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(config.HopCountHeader, strconv.Itoa(config.DefaultMaxHops-1))
+	w = httptest.NewRecorder()
+	topHTTP.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusLoopDetected, w.Code, w.Body.String())
+	assert.Len(t, backend.Calls(), 1)
+
+	// The status of the team proxy's servers is aggregated
+	require.Len(t, top.Status().Servers, 1)
+	assert.Equal(t, []config.ChainedServerStatus{{Name: "backend", Health: "ok", Tools: 1, Resources: 1}}, top.Status().Servers[0].ChainedServers)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MCP clients that client configuration snippets can be generated for.
+const (
+	clientClaude = "claude" // Claude Desktop, claude_desktop_config.json
+	clientCursor = "cursor" // Cursor, .cursor/mcp.json
+	clientVSCode = "vscode" // VS Code, .vscode/mcp.json
+)
+
+// Proxy modes a client can connect to.
+const (
+	modeCommand = "command"
+	modeHTTP    = "http"
+)
+
+// clientServerName is the key the proxy is registered under in generated snippets.
+const clientServerName = "smart-mcp-proxy"
+
+// apiKeyPlaceholder stands in for the bearer token clients send in HTTP mode, e.g. to an
+// authenticating gateway in front of the proxy.
+const apiKeyPlaceholder = "YOUR_API_KEY"
+
+var errUnknownClient = errors.New("client must be 'claude', 'cursor' or 'vscode'")
+
+// clientConfigOptions describes how a client reaches the proxy.
+type clientConfigOptions struct {
+	Mode       string // modeCommand or modeHTTP
+	Command    string // Proxy binary, for command mode
+	ConfigPath string // Proxy config file passed as MCP_PROXY_CONFIG, for command mode; may be empty
+	URL        string // URL of the proxy's MCP endpoint, for HTTP mode
+}
+
+// generateClientConfig returns the JSON configuration block that registers the proxy
+// with the given client.
+func generateClientConfig(client string, opts clientConfigOptions) (map[string]interface{}, error) {
+	if opts.Mode != modeCommand && opts.Mode != modeHTTP {
+		return nil, fmt.Errorf("mode must be '%s' or '%s'", modeCommand, modeHTTP)
+	}
+
+	var entry map[string]interface{}
+	if opts.Mode == modeCommand {
+		entry = map[string]interface{}{
+			"command": opts.Command,
+			"args":    []string{"-mode", modeCommand},
+		}
+		if opts.ConfigPath != "" {
+			entry["env"] = map[string]string{"MCP_PROXY_CONFIG": opts.ConfigPath}
+		}
+	}
+
+	switch client {
+	case clientClaude:
+		if opts.Mode == modeHTTP {
+			// Claude Desktop only launches stdio servers; mcp-remote bridges to the URL.
+			entry = map[string]interface{}{
+				"command": "npx",
+				"args":    []string{"mcp-remote", opts.URL, "--header", "Authorization:${MCP_PROXY_AUTH}"},
+				"env":     map[string]string{"MCP_PROXY_AUTH": "Bearer " + apiKeyPlaceholder},
+			}
+		}
+		return map[string]interface{}{"mcpServers": map[string]interface{}{clientServerName: entry}}, nil
+	case clientCursor:
+		if opts.Mode == modeHTTP {
+			entry = map[string]interface{}{
+				"url":     opts.URL,
+				"headers": map[string]string{"Authorization": "Bearer " + apiKeyPlaceholder},
+			}
+		}
+		return map[string]interface{}{"mcpServers": map[string]interface{}{clientServerName: entry}}, nil
+	case clientVSCode:
+		snippet := map[string]interface{}{}
+		if opts.Mode == modeHTTP {
+			// VS Code prompts for the key once and stores it as a secret.
+			entry = map[string]interface{}{
+				"type":    "http",
+				"url":     opts.URL,
+				"headers": map[string]string{"Authorization": "Bearer ${input:smart-mcp-proxy-api-key}"},
+			}
+			snippet["inputs"] = []map[string]interface{}{{
+				"type":        "promptString",
+				"id":          "smart-mcp-proxy-api-key",
+				"description": "API key for smart-mcp-proxy",
+				"password":    true,
+			}}
+		} else {
+			entry["type"] = "stdio"
+		}
+		snippet["servers"] = map[string]interface{}{clientServerName: entry}
+		return snippet, nil
+	}
+	return nil, errUnknownClient
+}
+
+// listenURL returns the URL a local client uses to reach a listener bound to addr.
+// Wildcard or empty hosts are replaced with localhost.
+func listenURL(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://" + addr
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// handleClientConfig handles GET /clients/config?client=claude|cursor|vscode[&mode=http|command].
+// HTTP mode (the default) points the client at the proxy's /mcp endpoint, under
+// public_base_url when configured; command mode launches this binary with the proxy's
+// config file.
+func (h *HTTPProxy) handleClientConfig(c *gin.Context) {
+	opts := clientConfigOptions{Mode: c.DefaultQuery("mode", modeHTTP)}
+	if opts.Mode == modeHTTP {
+		opts.URL = h.publicBaseURL() + "/mcp"
+	} else {
+		opts.Command, _ = os.Executable()
+		opts.ConfigPath = h.ps.configPath
+	}
+	snippet, err := generateClientConfig(c.Query("client"), opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, snippet)
+}
+
+// runGenerateClientConfigCommand implements
+// `smart-mcp-proxy generate-client-config -client name [-mode command|http] [-config path] [-listen addr]`,
+// printing the client's configuration snippet.
+func runGenerateClientConfigCommand(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("generate-client-config", flag.ContinueOnError)
+	clientFlag := fs.String("client", "", "Client to configure: 'claude', 'cursor' or 'vscode'")
+	modeFlag := fs.String("mode", modeCommand, "Proxy mode the client connects to: 'command' or 'http'")
+	configPathFlag := fs.String("config", "", "Path to MCP proxy config file (command mode)")
+	listenFlag := fs.String("listen", defaultListenAddr, "Listen address of the proxy (http mode)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	opts := clientConfigOptions{Mode: *modeFlag, URL: listenURL(*listenFlag) + "/mcp"}
+	if opts.Mode == modeCommand {
+		command, err := os.Executable()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to locate proxy binary: %v\n", err)
+			return 1
+		}
+		opts.Command = command
+		configPath := *configPathFlag
+		if configPath == "" {
+			configPath = os.Getenv("MCP_PROXY_CONFIG")
+		}
+		if configPath != "" {
+			if opts.ConfigPath, err = filepath.Abs(configPath); err != nil {
+				fmt.Fprintf(os.Stderr, "invalid config path: %v\n", err)
+				return 1
+			}
+		}
+	}
+
+	snippet, err := generateClientConfig(*clientFlag, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\nusage: smart-mcp-proxy generate-client-config -client claude|cursor|vscode [-mode command|http] [-config path] [-listen addr]\n", err)
+		return 2
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(snippet); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write client config: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "Rewrite golden files in testdata")
+
+// assertGolden compares got with testdata/client_config/name, rewriting it under -update.
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", "client_config", name)
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, got, 0o644))
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+}
+
+// TestGenerateClientConfig tests the snippet for every client and mode against golden files.
+func TestGenerateClientConfig(t *testing.T) {
+	opts := map[string]clientConfigOptions{
+		modeCommand: {Mode: modeCommand, Command: "/usr/local/bin/smart-mcp-proxy", ConfigPath: "/etc/smart-mcp-proxy/config.json"},
+		modeHTTP:    {Mode: modeHTTP, URL: "http://proxy.internal:8080/mcp"},
+	}
+	for _, client := range []string{clientClaude, clientCursor, clientVSCode} {
+		for _, mode := range []string{modeCommand, modeHTTP} {
+			t.Run(client+"_"+mode, func(t *testing.T) {
+				snippet, err := generateClientConfig(client, opts[mode])
+				require.NoError(t, err)
+				got, err := json.MarshalIndent(snippet, "", "  ")
+				require.NoError(t, err)
+				assertGolden(t, client+"_"+mode+".json", append(got, '\n'))
+			})
+		}
+	}
+
+	_, err := generateClientConfig("emacs", opts[modeHTTP])
+	assert.ErrorIs(t, err, errUnknownClient)
+	_, err = generateClientConfig(clientClaude, clientConfigOptions{Mode: "sse"})
+	assert.Error(t, err)
+}
+
+// TestGenerateClientConfigCommand tests that the CLI subcommand uses the listen address.
+func TestGenerateClientConfigCommand(t *testing.T) {
+	var out bytes.Buffer
+	code := runGenerateClientConfigCommand([]string{"-client", "cursor", "-mode", "http", "-listen", "0.0.0.0:9090"}, &out)
+	require.Equal(t, 0, code)
+	assertGolden(t, "cursor_http_listen.json", out.Bytes())
+
+	assert.Equal(t, 2, runGenerateClientConfigCommand([]string{"-client", "emacs"}, &out))
+}
+
+// TestHTTPClientConfig tests that GET /clients/config points clients at the /mcp endpoint
+// of the listen address, or of public_base_url when configured.
+func TestHTTPClientConfig(t *testing.T) {
+	httpProxy, _, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+
+	req := httptest.NewRequest("GET", "/clients/config?client=vscode", nil)
+	req.Host = "proxy.example.com:8443"
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var snippet struct {
+		Servers map[string]struct {
+			Type string `json:"type"`
+			URL  string `json:"url"`
+		} `json:"servers"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &snippet))
+	assert.Equal(t, "http", snippet.Servers[clientServerName].Type)
+	assert.Equal(t, listenURL(httpProxy.srv.Addr)+"/mcp", snippet.Servers[clientServerName].URL)
+
+	httpProxy.ps.publicBaseURL = "https://mcp.example.com/proxy"
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &snippet))
+	assert.Equal(t, "https://mcp.example.com/proxy/mcp", snippet.Servers[clientServerName].URL)
+	httpProxy.ps.publicBaseURL = ""
+
+	req = httptest.NewRequest("GET", "/clients/config?client=claude&mode=command", nil)
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"-mode","command"`)
+
+	req = httptest.NewRequest("GET", "/clients/config?client=emacs", nil)
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+)
+
+// samplingMethod is the request a backend sends to have the client sample its LLM.
+const samplingMethod = "sampling/createMessage"
+
+// errClientGone is returned for relayed requests still waiting when stdin is closed.
+var errClientGone = errors.New("command mode client disconnected")
+
+// relayTimeout bounds the wait for the client's response to a relayed request. The
+// backend's stdio pipe stays locked meanwhile, so a client that never answers would
+// otherwise block every later call to that backend. Sampling may wait on a user's
+// approval, so it is generous. Tests lower it.
+var relayTimeout = 5 * time.Minute
+
+// clientRelay sends requests from backends to the command mode client and matches the
+// client's responses to them. Relayed requests get ids of their own ("proxy-<n>") so
+// they cannot collide with the ids the client uses for its requests.
+type clientRelay struct {
+	write func([]byte) error // Writes one line to the client
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[string]chan relayResponse // Keyed by relayed request id
+	expired map[string]bool               // Requests that timed out; late responses are dropped
+	closed  bool
+}
+
+// relayResponse is a client's response to a relayed request.
+type relayResponse struct {
+	ID     json.RawMessage      `json:"id"`
+	Method string               `json:"method"` // Set on requests, which are not responses
+	Result json.RawMessage      `json:"result"`
+	Error  *config.JSONRPCError `json:"error"`
+}
+
+func newClientRelay(write func([]byte) error) *clientRelay {
+	return &clientRelay{write: write, pending: make(map[string]chan relayResponse), expired: make(map[string]bool)}
+}
+
+// request sends method to the client and waits for its response, for up to
+// relayTimeout. On timeout the backend is answered with a JSON-RPC error.
+func (r *clientRelay) request(method string, params json.RawMessage) (json.RawMessage, error) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil, errClientGone
+	}
+	r.nextID++
+	id := fmt.Sprintf("proxy-%d", r.nextID)
+	ch := make(chan relayResponse, 1)
+	r.pending[id] = ch
+	r.mu.Unlock()
+
+	req, err := json.Marshal(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      string          `json:"id"`
+		Method  string          `json:"method"`
+		Params  json.RawMessage `json:"params,omitempty"`
+	}{"2.0", id, method, params})
+	if err == nil {
+		err = r.write(req)
+	}
+	if err != nil {
+		r.mu.Lock()
+		delete(r.pending, id)
+		r.mu.Unlock()
+		return nil, err
+	}
+
+	timer := time.NewTimer(relayTimeout)
+	defer timer.Stop()
+	var resp relayResponse
+	var ok bool
+	select {
+	case resp, ok = <-ch:
+	case <-timer.C:
+		r.mu.Lock()
+		_, waiting := r.pending[id]
+		delete(r.pending, id)
+		if waiting {
+			r.expired[id] = true
+		}
+		r.mu.Unlock()
+		if !waiting {
+			// Answered or closed while timing out
+			resp, ok = <-ch
+			break
+		}
+		log.Printf("Command mode client did not answer %s %s within %s", method, id, relayTimeout)
+		return nil, &config.JSONRPCError{Code: -32603, Message: fmt.Sprintf("client did not answer %s within %s", method, relayTimeout)}
+	}
+	if !ok {
+		return nil, errClientGone
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	return resp.Result, nil
+}
+
+// deliver hands line to the relayed request it answers and reports whether it did.
+// Lines that are not responses to a pending relayed request are left to the caller.
+func (r *clientRelay) deliver(line []byte) bool {
+	var resp relayResponse
+	if err := json.Unmarshal(line, &resp); err != nil || resp.Method != "" {
+		return false
+	}
+	var id string
+	if err := json.Unmarshal(resp.ID, &id); err != nil {
+		return false
+	}
+	r.mu.Lock()
+	ch, ok := r.pending[id]
+	delete(r.pending, id)
+	late := r.expired[id]
+	delete(r.expired, id)
+	r.mu.Unlock()
+	if ok {
+		ch <- resp
+	}
+	if late {
+		log.Printf("Dropping the command mode client's late response to %s", id)
+	}
+	return ok || late
+}
+
+// close fails the relayed requests still waiting and any sent later.
+func (r *clientRelay) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	for id, ch := range r.pending {
+		close(ch)
+		delete(r.pending, id)
+	}
+}
+
+// serverRequestHandler relays sampling/createMessage requests of the named backend to
+// the client. Other requests are answered with "Method not found".
+func (c *CommandProxy) serverRequestHandler(server string) config.ServerRequestHandler {
+	return func(method string, params json.RawMessage) (json.RawMessage, error) {
+		if method != samplingMethod {
+			return nil, &config.JSONRPCError{Code: -32601, Message: "Method not found"}
+		}
+		log.Printf("Relaying %s from MCP server %s to the client", method, server)
+		return c.relay.request(method, params)
+	}
+}
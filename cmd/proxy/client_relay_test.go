@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// samplingBackend is a stdio backend whose "ask" tool sends a sampling/createMessage
+// request and returns the answer it receives as the tool's structuredContent.
+var samplingBackend = proxytest.StdioBackend{
+	Tools: []proxytest.Tool{{Name: "ask"}},
+	Calls: map[string]string{"ask": proxytest.Echo(`{"jsonrpc":"2.0","id":"srv-1","method":"sampling/createMessage","params":{"messages":[{"role":"user","content":{"type":"text","text":"hi"}}],"maxTokens":10}}`) + `
+      read reply
+      echo "{\"content\":[{\"type\":\"text\",\"text\":\"done\"}],\"structuredContent\":$reply}"`},
+}
+
+// TestCommandSamplingRelay tests that a backend's sampling/createMessage request is relayed
+// to the command mode client and the client's response is returned to the backend.
+func TestCommandSamplingRelay(t *testing.T) {
+	ps, err := NewProxyServer(&config.Config{
+		MCPServers: []config.MCPServerConfig{{Name: "sampler", Command: samplingBackend.Command(), Args: samplingBackend.Args()}},
+	})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	cmdProxy, err := NewCommandProxy(ps)
+	require.NoError(t, err)
+
+	clientIn, proxyOut := io.Pipe()
+	proxyIn, clientOut := io.Pipe()
+	cmdProxy.in, cmdProxy.out = proxyIn, proxyOut
+	served := make(chan error, 1)
+	go func() { served <- cmdProxy.serveLines() }()
+	client := proxytest.NewConversation(clientOut, clientIn)
+
+	require.NoError(t, client.Send(`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"ask","arguments":{}}}`))
+
+	// The backend's request reaches the client under an id of the proxy's own
+	relayed, err := client.Read()
+	require.NoError(t, err)
+	assert.Equal(t, samplingMethod, relayed.Method)
+	assert.Contains(t, string(relayed.Params), `"maxTokens":10`)
+
+	require.NoError(t, client.Send(`{"jsonrpc":"2.0","id":`+string(relayed.ID)+`,"result":{"role":"assistant","model":"test-model","content":{"type":"text","text":"hello"}}}`))
+
+	// The backend received the client's result under its own request id
+	resp, err := client.Read()
+	require.NoError(t, err)
+	require.Nil(t, resp.Error)
+	assert.Equal(t, "7", string(resp.ID))
+	var result struct {
+		StructuredContent struct {
+			ID     string `json:"id"`
+			Result struct {
+				Model string `json:"model"`
+			} `json:"result"`
+		} `json:"structuredContent"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Result, &result), string(resp.Result))
+	assert.Equal(t, "srv-1", result.StructuredContent.ID)
+	assert.Equal(t, "test-model", result.StructuredContent.Result.Model)
+
+	require.NoError(t, client.Close())
+	require.NoError(t, <-served)
+}
+
+// TestClientRelayClose tests that relayed requests fail once the client is gone.
+func TestClientRelayClose(t *testing.T) {
+	relay := newClientRelay(func([]byte) error { return nil })
+	errs := make(chan error, 1)
+	go func() {
+		_, err := relay.request(samplingMethod, nil)
+		errs <- err
+	}()
+	assert.Eventually(t, func() bool {
+		relay.mu.Lock()
+		defer relay.mu.Unlock()
+		return len(relay.pending) == 1
+	}, time.Second, 10*time.Millisecond)
+	relay.close()
+	assert.ErrorIs(t, <-errs, errClientGone)
+	assert.False(t, relay.deliver([]byte(`{"jsonrpc":"2.0","id":"proxy-1","result":{}}`)))
+}
+
+// TestClientRelayTimeout tests that a relayed request the client never answers fails
+// with a JSON-RPC error after relayTimeout, and that a late response is dropped.
+func TestClientRelayTimeout(t *testing.T) {
+	origTimeout := relayTimeout
+	relayTimeout = 20 * time.Millisecond
+	defer func() { relayTimeout = origTimeout }()
+
+	relay := newClientRelay(func([]byte) error { return nil })
+	_, err := relay.request(samplingMethod, nil)
+	var rpcErr *config.JSONRPCError
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, -32603, rpcErr.Code)
+	assert.Contains(t, rpcErr.Message, samplingMethod)
+	assert.Empty(t, relay.pending)
+
+	assert.True(t, relay.deliver([]byte(`{"jsonrpc":"2.0","id":"proxy-1","result":{}}`)), "a late response is consumed")
+	assert.False(t, relay.deliver([]byte(`{"jsonrpc":"2.0","id":"proxy-1","result":{}}`)))
+}
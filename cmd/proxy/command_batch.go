@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// isBatch reports whether a JSON-RPC message is a batch: an array of requests.
+func isBatch(msg []byte) bool {
+	trimmed := bytes.TrimSpace(msg)
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// handleCommandBatch handles a JSON-RPC batch in order and returns the responses as an
+// array, without entries for notifications. A request reusing the non-null id of an
+// earlier request in the batch is ambiguous to the client, so it is answered with
+// -32600 instead of being handled; the rest of the batch still is.
+func (c *CommandProxy) handleCommandBatch(reqBytes []byte) ([]byte, error) {
+	var batch []json.RawMessage
+	if err := json.Unmarshal(reqBytes, &batch); err != nil {
+		return marshalRPCError(nil, -32700, "Parse error: invalid JSON", nil)
+	}
+	if len(batch) == 0 {
+		return marshalRPCError(nil, -32600, "Invalid Request: empty batch", nil)
+	}
+
+	seen := make(map[string]bool, len(batch))
+	responses := make([]json.RawMessage, 0, len(batch))
+	for _, msg := range batch {
+		var resp []byte
+		var err error
+		id, key := c.batchRequestID(msg)
+		switch {
+		case isBatch(msg):
+			resp, err = marshalRPCError(nil, -32600, "Invalid Request: nested batch", nil)
+		case key != "" && seen[key]:
+			resp, err = marshalRPCError(id, -32600, "Invalid Request: duplicate id in batch", nil)
+		default:
+			if key != "" {
+				seen[key] = true
+			}
+			resp, err = c.handleCommandRequest(msg)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if resp != nil {
+			responses = append(responses, resp)
+		}
+	}
+	if len(responses) == 0 {
+		return nil, nil // A batch of notifications gets no response
+	}
+	return json.Marshal(responses)
+}
+
+// batchRequestID returns the id of a request in a batch and the key duplicates are
+// detected by, its JSON encoding. The key is "" for a null or missing id and for a
+// request that cannot be parsed, which handleCommandRequest then reports.
+func (c *CommandProxy) batchRequestID(msg []byte) (interface{}, string) {
+	var req struct {
+		ID interface{} `json:"id"`
+	}
+	if err := c.ps.unmarshalJSON(msg, &req); err != nil || req.ID == nil {
+		return nil, ""
+	}
+	key, err := json.Marshal(req.ID)
+	if err != nil {
+		return req.ID, ""
+	}
+	return req.ID, string(key)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+
+	"smart-mcp-proxy/internal/config"
+)
+
+// unknownClient labels the command mode client before it sent initialize.
+const unknownClient = "unknown-client"
+
+// CommandClient identifies the client of a command mode session, as sent in the
+// clientInfo of its initialize request.
+type CommandClient struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+	Label   string `json:"label"` // "name/version", or unknownClient before initialize
+}
+
+// commandClient holds the identity of the command mode client once it initialized.
+type commandClient struct {
+	mu     sync.Mutex
+	client CommandClient
+
+	capabilities map[string]interface{} // Advertised in the first initialize result
+}
+
+func newCommandClient() *commandClient {
+	return &commandClient{client: CommandClient{Label: unknownClient}}
+}
+
+// set records the clientInfo of an initialize request. A client without a name keeps
+// the unknownClient label.
+func (cc *commandClient) set(name, version string) {
+	label := unknownClient
+	if name != "" {
+		label = name
+		if version != "" {
+			label += "/" + version
+		}
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.client = CommandClient{Name: name, Version: version, Label: label}
+}
+
+// get returns the client's identity.
+func (cc *commandClient) get() CommandClient {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.client
+}
+
+// advertise returns the capabilities advertised to the client: those computed for its
+// first initialize, so they stay fixed for the session.
+func (cc *commandClient) advertise(compute func() map[string]interface{}) map[string]interface{} {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.capabilities == nil {
+		cc.capabilities = compute()
+	}
+	return cc.capabilities
+}
+
+// label returns the client's label for logs, metrics and records of its calls.
+func (cc *commandClient) label() string {
+	return cc.get().Label
+}
+
+// handleInitialize records the client's clientInfo and returns the proxy's
+// capabilities.
+func (c *CommandProxy) handleInitialize(params json.RawMessage, result *interface{}) *rpcError {
+	var initParams initializeRequestParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &initParams); err != nil {
+			return &rpcError{Code: -32602, Message: "Invalid params for initialize", Data: err.Error()}
+		}
+	}
+	name, _ := initParams.ClientInfo["name"].(string)
+	version, _ := initParams.ClientInfo["version"].(string)
+	c.client.set(name, version)
+	// Command mode does not relay list_changed notifications
+	capabilities := c.client.advertise(func() map[string]interface{} { return c.ps.advertisedCapabilities(false) })
+	*result = initializeResult(negotiateProtocolVersion(initParams.ProtocolVersion), capabilities)
+	return nil
+}
+
+// recordCommandToolCall counts a command mode tool call by client, tool and outcome:
+// "ok", "tool_error" for an isError result, or "error".
+func recordCommandToolCall(client, toolName string, result *config.CallToolResult, err error) {
+	if commandToolCallsTotal == nil { // Check if initialized
+		return
+	}
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	} else if result != nil && result.IsError {
+		outcome = "tool_error"
+	}
+	commandToolCallsTotal.WithLabelValues(client, toolName, outcome).Inc()
+}
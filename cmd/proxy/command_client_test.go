@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCommandClientIdentity tests that the clientInfo of initialize labels the command
+// mode client in /status, in the records of its tool calls and resource accesses and in
+// the log lines of its tool calls, and that the client is unknown-client until it
+// initializes.
+func TestCommandClientIdentity(t *testing.T) {
+	cmdProxy, servers := setupTestCommandProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+	ps := cmdProxy.ps
+	callTool := func() {
+		_, err := cmdProxy.handleCommandRequest([]byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"tool1","arguments":{}}}`))
+		require.NoError(t, err)
+	}
+	lastCall := func() ToolCallRecord {
+		calls := ps.Status().RecentCalls
+		require.NotEmpty(t, calls)
+		return calls[0]
+	}
+
+	require.NotNil(t, ps.Status().Client)
+	assert.Equal(t, unknownClient, ps.Status().Client.Label)
+	callTool()
+	assert.Equal(t, unknownClient, lastCall().Client)
+
+	respBytes, err := cmdProxy.handleCommandRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":"claude-ai","version":"0.1.0"}}}`))
+	require.NoError(t, err)
+	var resp struct {
+		Result struct {
+			ProtocolVersion string                 `json:"protocolVersion"`
+			ServerInfo      map[string]interface{} `json:"serverInfo"`
+		} `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal(respBytes, &resp), string(respBytes))
+	assert.Equal(t, "2025-06-18", resp.Result.ProtocolVersion)
+	assert.Equal(t, "smart-mcp-proxy", resp.Result.ServerInfo["name"])
+
+	respBytes, err = cmdProxy.handleCommandRequest([]byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`))
+	require.NoError(t, err)
+	assert.Nil(t, respBytes, "notifications get no response")
+
+	assert.Equal(t, CommandClient{Name: "claude-ai", Version: "0.1.0", Label: "claude-ai/0.1.0"}, *ps.Status().Client)
+	logs := captureLog(t)
+	callTool()
+	assert.Equal(t, "claude-ai/0.1.0", lastCall().Client)
+	assert.Contains(t, logs.String(), "Calling tool 'tool1' on server 'server1'")
+	assert.Contains(t, logs.String(), "for client 'claude-ai/0.1.0'")
+
+	_, err = cmdProxy.handleCommandRequest([]byte(`{"jsonrpc":"2.0","id":3,"method":"resources/access","params":{"serverName":"server1","resourceName":"res1","method":"GET"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "claude-ai/0.1.0", ps.ResourceAnalytics().RecentAccesses[0].Client)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// errMessageTooLarge is returned by requestReader.next for a message over its size
+// limit. A line was skipped and reading may go on; in stream mode it ends the input.
+var errMessageTooLarge = errors.New("message exceeds max_message_bytes")
+
+// requestReader splits the command mode input into JSON-RPC messages. It starts with
+// newline-delimited JSON, one message per line, and switches to decoding a stream of
+// JSON values as soon as a line ends inside a value, as it does for a client that
+// pretty-prints its messages across lines. The stream decoder also accepts compact
+// messages, so the switch lasts until the input ends. Messages are read up to max
+// bytes.
+type requestReader struct {
+	r      *bufio.Reader
+	max    int64
+	dec    *json.Decoder // Set once the input is read as a stream of JSON values
+	stream *limitReader  // Input of dec
+}
+
+// newRequestReader returns a requestReader reading newline-delimited JSON from in,
+// with messages of at most max bytes.
+func newRequestReader(in io.Reader, max int64) *requestReader {
+	return &requestReader{r: bufio.NewReader(in), max: max}
+}
+
+// next returns the next message, or io.EOF at the end of the input. A line that is
+// not JSON is returned as it is, to be answered with a parse error. In stream mode
+// invalid JSON ends the input, as the decoder cannot find the next message after it.
+func (rr *requestReader) next() ([]byte, error) {
+	if rr.dec != nil {
+		rr.stream.limit = rr.dec.InputOffset() + rr.max
+		var msg json.RawMessage
+		if err := rr.dec.Decode(&msg); err != nil {
+			return nil, err
+		}
+		return msg, nil
+	}
+
+	line, err := rr.readLine()
+	if len(line) == 0 && err != nil {
+		return nil, err
+	}
+	if incompleteJSON(line) {
+		rr.stream = &limitReader{r: io.MultiReader(bytes.NewReader(line), rr.r)}
+		rr.dec = json.NewDecoder(rr.stream)
+		return rr.next()
+	}
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	return bytes.TrimSuffix(line, []byte("\r")), nil
+}
+
+// readLine reads up to and including the next newline. A line longer than max is
+// skipped to its end and errMessageTooLarge returned, so the next line can be read.
+func (rr *requestReader) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := rr.r.ReadSlice('\n')
+		if int64(len(line)+len(chunk)) > rr.max {
+			for errors.Is(err, bufio.ErrBufferFull) {
+				_, err = rr.r.ReadSlice('\n')
+			}
+			return nil, errMessageTooLarge
+		}
+		line = append(line, chunk...)
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return line, err
+		}
+	}
+}
+
+// limitReader reads from r up to limit bytes in total, then fails with
+// errMessageTooLarge.
+type limitReader struct {
+	r     io.Reader
+	n     int64 // Bytes read so far
+	limit int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n >= l.limit {
+		return 0, errMessageTooLarge
+	}
+	if int64(len(p)) > l.limit-l.n {
+		p = p[:l.limit-l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	return n, err
+}
+
+// incompleteJSON reports whether line holds the start of a JSON value that continues
+// past its end.
+func incompleteJSON(line []byte) bool {
+	var value json.RawMessage
+	err := json.NewDecoder(bytes.NewReader(line)).Decode(&value)
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
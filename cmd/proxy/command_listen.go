@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// unixListenPrefix marks a -command-listen address as a unix socket path.
+const unixListenPrefix = "unix:"
+
+// commandListener accepts -command-listen connections and runs an independent command
+// mode session on each.
+type commandListener struct {
+	ln   net.Listener
+	addr string // Bound address: host:port, or unix:path
+
+	mu       sync.Mutex
+	sessions map[net.Conn]*CommandProxy
+	closed   bool // Set by close; connections accepted after it are not served
+	wg       sync.WaitGroup
+}
+
+// SetCommandListen makes Run serve the command mode protocol on connections to addr
+// instead of stdin and stdout: host:port for TCP, or unix:path for a unix socket. The
+// protocol has no authentication, so a TCP host must be a loopback address unless
+// anyHost is set. It must be called before Run. With several clients there is no
+// single one to report in /status or to relay stdio server requests to, so neither is
+// done.
+func (c *CommandProxy) SetCommandListen(addr string, anyHost bool) {
+	c.commandListen = addr
+	c.commandListenAnyHost = anyHost
+	c.ps.commandClient = nil
+	for _, server := range c.ps.mcpServers {
+		server.ServerRequestHandler = nil
+	}
+}
+
+// isLoopbackHost reports whether host, from a host:port address, only accepts
+// connections from the local machine. An empty host binds every interface.
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// listenCommand binds a -command-listen address. TCP addresses on other than a loopback
+// host are refused unless anyHost is set, as anyone reaching them could call tools.
+func listenCommand(addr string, anyHost bool) (net.Listener, error) {
+	if strings.HasPrefix(addr, `\\.\pipe\`) {
+		return nil, errors.New("named pipes are not supported; use unix:path, which Windows 10 and later also support")
+	}
+	if path, ok := strings.CutPrefix(addr, unixListenPrefix); ok {
+		// A socket file left behind by an earlier run would make the bind fail, but one
+		// another process still accepts on must not be taken from it
+		if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+				conn.Close()
+				return nil, fmt.Errorf("%s is in use by another process", path)
+			}
+			os.Remove(path)
+		}
+		return net.Listen("unix", path)
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if !isLoopbackHost(host) {
+		if !anyHost {
+			return nil, fmt.Errorf("%s is not a loopback address and command mode sessions are not authenticated; use 127.0.0.1, a unix socket, or -command-listen-any-host", addr)
+		}
+		log.Printf("WARNING: serving unauthenticated command mode sessions on %s; anyone who can reach it can list and call every tool", addr)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// startCommandListener binds the -command-listen address, if one was set, and accepts
+// connections in the background.
+func (c *CommandProxy) startCommandListener() error {
+	if c.commandListen == "" {
+		return nil
+	}
+	ln, err := listenCommand(c.commandListen, c.commandListenAnyHost)
+	if err != nil {
+		return fmt.Errorf("%w: failed to start command listener on %s: %w", ErrListen, c.commandListen, err)
+	}
+	l := &commandListener{ln: ln, addr: ln.Addr().String(), sessions: make(map[net.Conn]*CommandProxy)}
+	if ln.Addr().Network() == "unix" {
+		l.addr = unixListenPrefix + l.addr
+	}
+	c.listener = l
+	log.Printf("Serving command mode sessions on %s", l.addr)
+	go l.accept(c.ps)
+	return nil
+}
+
+// stopCommandListener stops accepting connections, closes the open sessions and waits
+// for them to end.
+func (c *CommandProxy) stopCommandListener() {
+	if c.listener == nil {
+		return
+	}
+	c.listener.close()
+	c.listener = nil
+}
+
+// accept runs a new session on each accepted connection until the listener is closed.
+func (l *commandListener) accept(ps *ProxyServer) {
+	for {
+		conn, err := l.ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Command listener stopped accepting: %v", err)
+			}
+			return
+		}
+		session := &CommandProxy{ps: ps, in: conn, out: conn, client: newCommandClient()}
+		session.relay = newClientRelay(session.writeLine)
+		l.mu.Lock()
+		if l.closed {
+			l.mu.Unlock()
+			conn.Close()
+			return
+		}
+		l.sessions[conn] = session
+		l.wg.Add(1)
+		l.mu.Unlock()
+		go l.serve(session, conn)
+	}
+}
+
+// serve answers the requests of one connection with its session, which has its own
+// client identity and relay and shares only the ProxyServer.
+func (l *commandListener) serve(session *CommandProxy, conn net.Conn) {
+	defer l.wg.Done()
+	remote := conn.RemoteAddr().String()
+	log.Printf("Command session from %s started", remote)
+	if err := session.serveLines(); err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Printf("Command session from %s failed: %v", remote, err)
+	}
+	conn.Close()
+	l.mu.Lock()
+	delete(l.sessions, conn)
+	l.mu.Unlock()
+	log.Printf("Command session from %s (%s) ended", remote, session.client.label())
+}
+
+// clients returns the client labels of the open sessions.
+func (l *commandListener) clients() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	labels := make([]string, 0, len(l.sessions))
+	for _, session := range l.sessions {
+		labels = append(labels, session.client.label())
+	}
+	return labels
+}
+
+// close stops accepting, closes every session's connection and waits for the sessions
+// to end.
+func (l *commandListener) close() {
+	l.ln.Close()
+	l.mu.Lock()
+	l.closed = true
+	for conn := range l.sessions {
+		conn.Close()
+	}
+	l.mu.Unlock()
+	l.wg.Wait()
+}
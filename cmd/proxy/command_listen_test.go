@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// commandConn is a client connection to the command listener.
+type commandConn struct {
+	t       *testing.T
+	conn    net.Conn
+	scanner *bufio.Scanner
+}
+
+func dialCommand(t *testing.T, addr string) *commandConn {
+	network := "tcp"
+	if path, ok := strings.CutPrefix(addr, unixListenPrefix); ok {
+		network, addr = "unix", path
+	}
+	conn, err := net.Dial(network, addr)
+	require.NoError(t, err)
+	return &commandConn{t: t, conn: conn, scanner: bufio.NewScanner(conn)}
+}
+
+// call sends one request line and reads the response line.
+func (c *commandConn) call(request string) jsonRPCResponse {
+	_, err := fmt.Fprintln(c.conn, request)
+	require.NoError(c.t, err)
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	require.True(c.t, c.scanner.Scan(), "no response to %s: %v", request, c.scanner.Err())
+	var resp jsonRPCResponse
+	require.NoError(c.t, json.Unmarshal(c.scanner.Bytes(), &resp))
+	return resp
+}
+
+// TestCommandListen tests that two concurrent connections to the command listener
+// get independent sessions, with the same request ids and their own client identity,
+// and that sessions end when their connection closes.
+func TestCommandListen(t *testing.T) {
+	cmdProxy, servers := setupTestCommandProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+	cmdProxy.SetCommandListen("127.0.0.1:0", false)
+	require.NoError(t, cmdProxy.startCommandListener())
+	defer cmdProxy.stopCommandListener()
+	listener := cmdProxy.listener
+	assert.Nil(t, cmdProxy.ps.commandClient, "no single client to report in /status")
+
+	first := dialCommand(t, listener.addr)
+	second := dialCommand(t, listener.addr)
+	resp := first.call(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"clientInfo":{"name":"first","version":"1.0"}}}`)
+	require.Nil(t, resp.Error)
+	resp = second.call(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"clientInfo":{"name":"second","version":"2.0"}}}`)
+	require.Nil(t, resp.Error)
+	assert.ElementsMatch(t, []string{"first/1.0", "second/2.0"}, listener.clients())
+
+	done := make(chan jsonRPCResponse, 2)
+	go func() { done <- first.call(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`) }()
+	go func() {
+		done <- second.call(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"tool3","arguments":{}}}`)
+	}()
+	for i := 0; i < 2; i++ {
+		resp := <-done
+		assert.Nil(t, resp.Error)
+		assert.EqualValues(t, 2, resp.ID)
+	}
+
+	first.conn.Close()
+	assert.Eventually(t, func() bool { return len(listener.clients()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"second/2.0"}, listener.clients())
+	resp = second.call(`{"jsonrpc":"2.0","id":3,"method":"resources/list"}`)
+	assert.Nil(t, resp.Error, "other sessions keep working")
+
+	cmdProxy.stopCommandListener()
+	assert.Empty(t, listener.clients())
+	_, err := net.Dial("tcp", listener.addr)
+	assert.Error(t, err)
+}
+
+// TestCommandListenUnix tests a session over a unix socket.
+func TestCommandListenUnix(t *testing.T) {
+	cmdProxy, servers := setupTestCommandProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+	path := filepath.Join(t.TempDir(), "proxy.sock")
+	cmdProxy.SetCommandListen(unixListenPrefix+path, false)
+	require.NoError(t, cmdProxy.startCommandListener())
+
+	conn := dialCommand(t, cmdProxy.listener.addr)
+	defer conn.conn.Close()
+	resp := conn.call(`{"jsonrpc":"2.0","id":"a","method":"tools/list"}`)
+	assert.Nil(t, resp.Error)
+	assert.Equal(t, "a", resp.ID)
+
+	// A socket another process accepts on is not taken over
+	_, err := listenCommand(unixListenPrefix+path, false)
+	assert.ErrorContains(t, err, "in use")
+	cmdProxy.stopCommandListener()
+
+	// A socket file left behind by an earlier run is replaced
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	ln, err := listenCommand(unixListenPrefix+path, false)
+	require.NoError(t, err)
+	ln.Close()
+
+	cmdProxy.SetCommandListen(`\\.\pipe\smart-mcp-proxy`, false)
+	assert.ErrorIs(t, cmdProxy.startCommandListener(), ErrListen)
+}
+
+// TestCommandListenLoopbackOnly tests that TCP addresses reachable from other hosts are
+// refused unless explicitly allowed.
+func TestCommandListenLoopbackOnly(t *testing.T) {
+	cmdProxy, servers := setupTestCommandProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+	for _, addr := range []string{"0.0.0.0:0", ":0", "[::]:0", "example.com:0"} {
+		cmdProxy.SetCommandListen(addr, false)
+		assert.ErrorIs(t, cmdProxy.startCommandListener(), ErrListen, addr)
+	}
+	cmdProxy.SetCommandListen("localhost:0", false)
+	require.NoError(t, cmdProxy.startCommandListener())
+	cmdProxy.stopCommandListener()
+
+	cmdProxy.SetCommandListen("0.0.0.0:0", true)
+	require.NoError(t, cmdProxy.startCommandListener())
+	cmdProxy.stopCommandListener()
+}
+
+// blockingListener returns conn from Accept only once released, and only closes it
+// then, like an accept that returns just as the listener is closed.
+type blockingListener struct {
+	net.Listener
+	release chan struct{}
+	conn    net.Conn
+}
+
+func (l *blockingListener) Accept() (net.Conn, error) {
+	<-l.release
+	if l.conn == nil {
+		return nil, net.ErrClosed
+	}
+	conn := l.conn
+	l.conn = nil
+	return conn, nil
+}
+
+func (l *blockingListener) Close() error { return nil }
+
+// TestCommandListenerCloseRace tests that a connection accepted while the listener is
+// being closed is closed instead of starting a session close no longer waits for.
+func TestCommandListenerCloseRace(t *testing.T) {
+	cmdProxy, servers := setupTestCommandProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+	client, conn := net.Pipe()
+	defer client.Close()
+	ln := &blockingListener{release: make(chan struct{}), conn: conn}
+	l := &commandListener{ln: ln, sessions: make(map[net.Conn]*CommandProxy)}
+	accepted := make(chan struct{})
+	go func() {
+		l.accept(cmdProxy.ps)
+		close(accepted)
+	}()
+
+	l.close()
+	close(ln.release)
+	<-accepted
+	assert.Empty(t, l.clients())
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	_, err := client.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF, "the connection is closed")
+}
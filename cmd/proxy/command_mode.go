@@ -1,17 +1,20 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"context" // Keep for Shutdown signature
 	"encoding/json"
+	"errors"
 	"fmt"
-
-	// "io" // Not directly used, bytes.NewReader suffices
+	"io"
 	"log"
 	"net/http" // Keep for http status codes and header manipulation
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"smart-mcp-proxy/internal/config" // Needed for CallToolRequestParams and CallToolResult
 	// Gin is no longer needed here
@@ -52,11 +55,35 @@ type resourceAccessParams struct {
 	Body         json.RawMessage   `json:"body,omitempty"`
 }
 
+// toolsListParams are the optional params of tools/list.
+type toolsListParams struct {
+	ServerName string `json:"serverName,omitempty"` // Lists only this server's tools when set
+}
+
 // --- End Param Structs ---
 
 // CommandProxy implements the Proxy interface for STDIO transport
 type CommandProxy struct {
 	ps *ProxyServer // Reference to the core ProxyServer logic
+
+	// Optional admin listener serving /metrics, /healthz, /servers and /status
+	adminListen string
+	adminSrv    *http.Server
+	adminAddr   string // Bound address once the admin listener is started
+
+	// Optional listener serving a command mode session per connection instead of stdio
+	commandListen        string
+	commandListenAnyHost bool // Allow a non-loopback TCP host, which anyone could reach
+	listener             *commandListener
+
+	stopReason string // Why Run returned cleanly
+
+	in      io.Reader // Requests from the client, and its responses to relayed requests
+	out     io.Writer // Responses to the client, and requests relayed from backends
+	writeMu sync.Mutex
+	relay   *clientRelay
+
+	client *commandClient // Identity from the client's initialize request
 }
 
 // NewCommandProxy creates a new CommandProxy instance.
@@ -65,74 +92,275 @@ func NewCommandProxy(ps *ProxyServer) (*CommandProxy, error) {
 	if ps == nil {
 		return nil, fmt.Errorf("ProxyServer instance cannot be nil")
 	}
-	return &CommandProxy{
-		ps: ps,
-	}, nil
+	c := &CommandProxy{
+		ps:  ps,
+		in:  os.Stdin,
+		out: os.Stdout,
+
+		client: newCommandClient(),
+	}
+	ps.commandClient = c.client
+	c.relay = newClientRelay(c.writeLine)
+	for _, server := range ps.mcpServers {
+		if server.Config.Command != "" {
+			server.ServerRequestHandler = c.serverRequestHandler(server.Config.Name)
+		}
+	}
+	return c, nil
+}
+
+// SetAdminListen enables a minimal HTTP admin listener on addr (host:port) that runs
+// alongside the stdio loop. It must be called before Run.
+func (c *CommandProxy) SetAdminListen(addr string) {
+	c.adminListen = addr
+}
+
+// startAdmin starts the admin listener if one was configured.
+func (c *CommandProxy) startAdmin() error {
+	if c.adminListen == "" {
+		return nil
+	}
+	c.adminSrv = newAdminServer(c.ps)
+	addr, err := startAdminServer(c.adminSrv, c.adminListen)
+	if err != nil {
+		c.adminSrv = nil
+		return fmt.Errorf("%w: failed to start admin listener on %s: %w", ErrListen, c.adminListen, err)
+	}
+	c.adminAddr = addr
+	return nil
+}
+
+// stopAdmin shuts the admin listener down if it is running.
+func (c *CommandProxy) stopAdmin(ctx context.Context) error {
+	if c.adminSrv == nil {
+		return nil
+	}
+	err := c.adminSrv.Shutdown(ctx)
+	c.adminSrv = nil
+	return err
 }
 
-// Run starts the command mode loop, reading from stdin and writing to stdout.
+// Run starts the command mode loop, reading from stdin and writing to stdout, or
+// serving the connections of the command listener when one is set.
 func (c *CommandProxy) Run() error {
 	log.Println("Starting MCP Proxy in Command Mode")
-	scanner := bufio.NewScanner(os.Stdin)
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		// Use the handleCommandRequest method associated with the CommandProxy instance
-		respBytes, err := c.handleCommandRequest(line)
+	if err := c.startAdmin(); err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := c.stopAdmin(ctx); err != nil {
+			log.Printf("Admin server forced to shutdown: %v", err)
+		}
+	}()
+
+	// Serve stdin, or the command listener, in the background so a SIGTERM or
+	// interrupt also ends Run cleanly
+	done := make(chan error, 1)
+	if c.commandListen != "" {
+		if err := c.startCommandListener(); err != nil {
+			return err
+		}
+		defer c.stopCommandListener()
+	} else {
+		go func() { done <- c.serveLines() }()
+	}
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(quit)
+	select {
+	case sig := <-quit:
+		c.stopReason = "signal: " + sig.String()
+		log.Printf("Received %v, stopping MCP Proxy Command Mode", sig)
+		return nil
+	case err := <-done:
 		if err != nil {
-			// Log error to stderr, but try to send a JSON-RPC error response
-			fmt.Fprintf(os.Stderr, "Error processing command request: %v\n", err)
-			// Attempt to create a generic error response if possible
-			errorResp := jsonRPCResponse{
-				JSONRPC: "2.0",
-				ID:      nil, // ID might be unknown if parsing failed early
-				Error: &rpcError{
-					Code:    -32603, // Internal error
-					Message: fmt.Sprintf("Internal server error: %v", err),
-				},
+			return err
+		}
+		c.stopReason = "stdin closed"
+		log.Println("MCP Proxy Command Mode finished.")
+		return nil
+	}
+}
+
+// serveLines answers JSON-RPC requests read from the client (stdin, or a
+// -command-listen connection) until it is closed, one per line or pretty-printed
+// across lines (see requestReader). Requests are answered one at a time
+// in the order received, while the input keeps being read so that the client's
+// responses to relayed requests reach the waiting backend.
+func (c *CommandProxy) serveLines() error {
+	queue := newLineQueue()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			line, ok := queue.pop()
+			if !ok {
+				return
 			}
-			// Try to parse ID from the raw line if possible for better error reporting
-			var basicReq struct {
-				ID interface{} `json:"id"`
+			c.answer(line)
+		}
+	}()
+
+	requests := newRequestReader(c.in, c.ps.maxMessageBytes)
+	var err error
+	for {
+		var line []byte
+		line, err = requests.next()
+		if errors.Is(err, errMessageTooLarge) {
+			if resp, marshalErr := marshalRPCError(nil, -32600, fmt.Sprintf("Invalid Request: message larger than %d bytes", c.ps.maxMessageBytes), nil); marshalErr == nil {
+				c.writeLine(resp)
+			}
+			if requests.dec == nil {
+				continue // The line was skipped
 			}
-			_ = json.Unmarshal(line, &basicReq) // Ignore error, ID might still be nil
-			errorResp.ID = basicReq.ID
+		}
+		if err != nil {
+			break
+		}
+		if c.relay.deliver(line) {
+			continue
+		}
+		queue.push(line)
+	}
+	c.relay.close()
+	queue.close()
+	<-done
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		if resp, marshalErr := marshalRPCError(nil, -32700, "Parse error: invalid JSON", nil); marshalErr == nil {
+			c.writeLine(resp)
+		}
+	}
+	if err != io.EOF {
+		fmt.Fprintf(os.Stderr, "Error reading requests: %v\n", err)
+		return err
+	}
+	return nil
+}
 
-			respBytes, _ = json.Marshal(errorResp) // Marshal the error response
-			// Fallthrough to write the error response
+// answer handles one request line and writes the response to the client.
+func (c *CommandProxy) answer(line []byte) {
+	// Use the handleCommandRequest method associated with the CommandProxy instance
+	respBytes, err := c.handleCommandRequest(line)
+	if err != nil {
+		// Log error to stderr, but try to send a JSON-RPC error response
+		fmt.Fprintf(os.Stderr, "Error processing command request: %v\n", err)
+		// Attempt to create a generic error response if possible
+		errorResp := jsonRPCResponse{
+			JSONRPC: "2.0",
+			ID:      nil, // ID might be unknown if parsing failed early
+			Error: &rpcError{
+				Code:    -32603, // Internal error
+				Message: fmt.Sprintf("Internal server error: %v", err),
+			},
 		}
+		// Try to parse ID from the raw line if possible for better error reporting
+		var basicReq struct {
+			ID interface{} `json:"id"`
+		}
+		_ = c.ps.unmarshalJSON(line, &basicReq) // Ignore error, ID might still be nil
+		errorResp.ID = basicReq.ID
+
+		respBytes, _ = json.Marshal(errorResp) // Marshal the error response
+		// Fallthrough to write the error response
+	}
 
-		if respBytes != nil {
-			os.Stdout.Write(respBytes)
-			os.Stdout.Write([]byte("\n")) // Ensure newline separator
+	if respBytes != nil {
+		if err := c.writeLine(respBytes); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing response: %v\n", err)
 		}
 	}
-	if err := scanner.Err(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
-		return err // Return the error from the scanner
+}
+
+// writeLine writes one newline-terminated message to the client.
+func (c *CommandProxy) writeLine(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.out.Write(append(data, '\n'))
+	return err
+}
+
+// lineQueue is an unbounded FIFO of request lines, so reading stdin never waits for
+// the request being answered.
+type lineQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	lines  [][]byte
+	closed bool
+}
+
+func newLineQueue() *lineQueue {
+	q := &lineQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *lineQueue) push(line []byte) {
+	q.mu.Lock()
+	q.lines = append(q.lines, line)
+	q.mu.Unlock()
+	q.cond.Signal()
+}
+
+// pop waits for the next line. It reports false once the queue is closed and drained.
+func (q *lineQueue) pop() ([]byte, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.lines) == 0 && !q.closed {
+		q.cond.Wait()
 	}
-	log.Println("MCP Proxy Command Mode finished.")
-	return nil
+	if len(q.lines) == 0 {
+		return nil, false
+	}
+	line := q.lines[0]
+	q.lines = q.lines[1:]
+	return line, true
+}
+
+func (q *lineQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+// StopReason reports why Run returned cleanly: the signal received, or "stdin closed".
+func (c *CommandProxy) StopReason() string {
+	return c.stopReason
 }
 
 // Shutdown is a placeholder for command mode; typically no explicit shutdown needed.
 // The actual MCP server shutdown is handled by the ProxyServer instance.
 func (c *CommandProxy) Shutdown(ctx context.Context) error {
 	log.Println("CommandProxy Shutdown called (delegating to ProxyServer).")
-	// ProxyServer shutdown logic is called from main, no specific action here.
-	return nil
+	// ProxyServer shutdown logic is called from main; only the admin listener is ours to stop.
+	return c.stopAdmin(ctx)
+}
+
+// validJSONRPCVersion reports whether a client request's jsonrpc member is "2.0", or
+// missing (or empty) under lenient_jsonrpc.
+func (ps *ProxyServer) validJSONRPCVersion(version string) bool {
+	return version == "2.0" || version == "" && ps.lenientJSONRPC
 }
 
-// handleCommandRequest processes a single MCP request line (JSON-RPC).
+// handleCommandRequest processes a single MCP request line (JSON-RPC): a request or
+// a batch of them.
 // Now a method on CommandProxy to access c.ps.
 func (c *CommandProxy) handleCommandRequest(reqBytes []byte) ([]byte, error) {
+	if isBatch(reqBytes) {
+		return c.handleCommandBatch(reqBytes)
+	}
+
 	// 1. Parse JSON-RPC request
 	var rpcReq jsonRPCRequest
-	if err := json.Unmarshal(reqBytes, &rpcReq); err != nil {
+	if err := c.ps.unmarshalJSON(reqBytes, &rpcReq); err != nil {
 		return marshalRPCError(nil, -32700, "Parse error: invalid JSON", nil)
 	}
 
 	// 2. Validate JSON-RPC version
-	if rpcReq.JSONRPC != "2.0" {
+	if !c.ps.validJSONRPCVersion(rpcReq.JSONRPC) {
 		return marshalRPCError(rpcReq.ID, -32600, "Invalid Request: jsonrpc must be '2.0'", nil)
 	}
 
@@ -141,8 +369,12 @@ func (c *CommandProxy) handleCommandRequest(reqBytes []byte) ([]byte, error) {
 	var rpcErr *rpcError
 
 	switch rpcReq.Method {
+	case "initialize":
+		rpcErr = c.handleInitialize(rpcReq.Params, &result)
+	case "notifications/initialized":
+		return nil, nil // Notifications get no response
 	case "tools/list":
-		result = map[string]interface{}{"tools": c.ps.ListTools()}
+		rpcErr = c.handleToolsList(rpcReq.Params, &result)
 	case "restrictedTools/list":
 		result = map[string]interface{}{"tools": c.ps.ListRestrictedTools()}
 	case "resources/list":
@@ -153,6 +385,8 @@ func (c *CommandProxy) handleCommandRequest(reqBytes []byte) ([]byte, error) {
 		rpcErr = c.handleToolCall(rpcReq.ID, rpcReq.Params, &result)
 	case "resources/access":
 		rpcErr = c.handleResourceAccess(rpcReq.ID, rpcReq.Params, &result)
+	case "resources/read":
+		rpcErr = readResourceRPC(c.ps, c.client.label(), 0, rpcReq.Params, &result)
 	default:
 		rpcErr = &rpcError{Code: -32601, Message: "Method not found"}
 	}
@@ -169,10 +403,35 @@ func (c *CommandProxy) handleCommandRequest(reqBytes []byte) ([]byte, error) {
 	return json.Marshal(resp) // Let the caller handle potential marshal error
 }
 
+// handleToolsList handles the "tools/list" RPC method: the tools of every server, or
+// of the server named by params.serverName.
+func (c *CommandProxy) handleToolsList(params json.RawMessage, result *interface{}) *rpcError {
+	var listParams toolsListParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &listParams); err != nil {
+			return &rpcError{Code: -32602, Message: "Invalid params for tools/list", Data: err.Error()}
+		}
+	}
+	if listParams.ServerName == "" {
+		*result = map[string]interface{}{"tools": c.ps.ListTools()}
+		return nil
+	}
+	server := c.ps.findMCPServerByName(listParams.ServerName)
+	if server == nil {
+		return &rpcError{Code: -32001, Message: fmt.Sprintf("Server '%s' not found", listParams.ServerName)}
+	}
+	tools := []config.ToolInfo{}
+	for _, tool := range c.ps.listedTools(server) {
+		tools = append(tools, config.WithPlaceholderSchema(tool))
+	}
+	*result = map[string]interface{}{"tools": tools}
+	return nil
+}
+
 // handleToolCall handles the logic for the "tools/call" RPC method using the core ProxyServer.CallTool.
 func (c *CommandProxy) handleToolCall(reqID interface{}, params json.RawMessage, result *interface{}) *rpcError {
 	var toolParams config.CallToolRequestParams
-	if err := json.Unmarshal(params, &toolParams); err != nil {
+	if err := c.ps.unmarshalJSON(params, &toolParams); err != nil {
 		return &rpcError{Code: -32602, Message: "Invalid params for tools/call: failed to parse", Data: err.Error()}
 	}
 
@@ -183,12 +442,24 @@ func (c *CommandProxy) handleToolCall(reqID interface{}, params json.RawMessage,
 	}
 
 	// Call the centralized CallTool method
-	callResult, err := c.ps.CallTool(toolParams.Name, toolParams.Arguments)
+	client := c.client.label()
+	callResult, err := c.ps.CallToolFrom(client, 0, toolParams.Name, toolParams.Arguments, toolParams.Meta)
+	recordCommandToolCall(client, toolParams.Name, callResult, err)
 	if err != nil {
 		// Map the error from CallTool to a JSON-RPC error
 		// You might want more specific error codes based on the error type from CallTool
-		log.Printf("Error calling tool '%s' via ProxyServer: %v", toolParams.Name, err)
-		return &rpcError{Code: -32000, Message: fmt.Sprintf("Failed to execute tool '%s'", toolParams.Name), Data: err.Error()}
+		log.Printf("Error calling tool '%s' for client '%s' via ProxyServer: %v", toolParams.Name, client, err)
+		if errors.Is(err, ErrArgumentLimit) {
+			return &rpcError{Code: -32602, Message: "Invalid params for tools/call: " + err.Error()}
+		}
+		if errors.Is(err, ErrToolRestricted) {
+			return &rpcError{Code: -32002, Message: fmt.Sprintf("Tool '%s' not allowed by the proxy configuration", toolParams.Name)}
+		}
+		message := fmt.Sprintf("Failed to execute tool '%s'", toolParams.Name)
+		if t := asThrottle(err); t != nil {
+			return throttleRPCError(-32000, message, err, t)
+		}
+		return &rpcError{Code: -32000, Message: message, Data: err.Error()}
 	}
 
 	// Assign the successful CallToolResult directly to the JSON-RPC result field
@@ -215,11 +486,11 @@ func (c *CommandProxy) handleResourceAccess(reqID interface{}, params json.RawMe
 
 	// Check resource allowance *after* finding server but *before* preparing request
 	if !server.IsResourceAllowed(resourceParams.ResourceName) {
-		return &rpcError{Code: -32002, Message: fmt.Sprintf("Resource '%s' not allowed on server '%s'", resourceParams.ResourceName, resourceParams.ServerName)}
+		return c.ps.resourceRestrictedError(resourceParams.ServerName, resourceParams.ResourceName)
 	}
 
 	// Construct the target path, ensuring proxyPath starts correctly
-	targetPath := fmt.Sprintf("/resource/%s", resourceParams.ResourceName)
+	targetPath := server.ResourcePath(resourceParams.ResourceName)
 	if resourceParams.ProxyPath != "" {
 		// Ensure single slash between resource name and proxy path
 		if !strings.HasPrefix(resourceParams.ProxyPath, "/") {
@@ -236,6 +507,10 @@ func (c *CommandProxy) handleResourceAccess(reqID interface{}, params json.RawMe
 		Query:  "",                // Query params could be added if needed via params struct
 		Header: make(http.Header), // Initialize Header
 		Body:   bytes.NewReader(resourceParams.Body),
+
+		Resource: resourceParams.ResourceName,
+		Client:   c.client.label(),
+		BaseURL:  c.ps.publicBaseURL,
 	}
 
 	// Copy headers from params (map[string]string) to http.Header
@@ -251,9 +526,16 @@ func (c *CommandProxy) handleResourceAccess(reqID interface{}, params json.RawMe
 
 	// Call the core proxy logic
 	respOutput, err := c.ps.ProxyRequest(input)
+	if errors.Is(err, ErrInvalidHeaders) {
+		return &rpcError{Code: -32602, Message: "Invalid params for resources/access: headers", Data: err.Error()}
+	}
 	if err != nil {
 		// Provide more context in the error message
-		return &rpcError{Code: -32003, Message: fmt.Sprintf("Failed to proxy resource access to '%s'", resourceParams.ServerName), Data: err.Error()}
+		message := fmt.Sprintf("Failed to proxy resource access to '%s'", resourceParams.ServerName)
+		if t := asThrottle(err); t != nil {
+			return throttleRPCError(-32003, message, err, t)
+		}
+		return &rpcError{Code: -32003, Message: message, Data: err.Error()}
 	}
 
 	// Format the result for JSON-RPC
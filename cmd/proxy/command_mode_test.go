@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"io"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
-	"smart-mcp-proxy/internal/config" // Keep config import for setup
+	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest" // Keep config import for setup
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -90,6 +95,31 @@ func TestCommandHandleToolsList(t *testing.T) {
 	assert.True(t, foundTools["tool3"])
 }
 
+// TestCommandHandleToolsListByServer tests tools/list scoped to one server with
+// params.serverName, and the error for an unknown server.
+func TestCommandHandleToolsListByServer(t *testing.T) {
+	cmdProxy, servers := setupTestCommandProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+
+	respBytes, err := cmdProxy.handleCommandRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list","params":{"serverName":"server2"}}`))
+	require.NoError(t, err)
+	var rpcResp testToolsAndResourceResponse
+	require.NoError(t, json.Unmarshal(respBytes, &rpcResp))
+	assert.Nil(t, rpcResp.Error)
+	require.Len(t, rpcResp.Result.Tools, 1)
+	assert.Equal(t, "tool3", rpcResp.Result.Tools[0].Name)
+
+	respBytes, err = cmdProxy.handleCommandRequest([]byte(`{"jsonrpc":"2.0","id":2,"method":"tools/list","params":{"serverName":"serverX"}}`))
+	require.NoError(t, err)
+	var errResp jsonRPCResponse
+	require.NoError(t, json.Unmarshal(respBytes, &errResp))
+	require.NotNil(t, errResp.Error)
+	assert.Equal(t, -32001, errResp.Error.Code)
+	assert.Contains(t, errResp.Error.Message, "serverX")
+}
+
 // TestCommandHandleResourcesList tests the "resources/list" JSON-RPC method.
 func TestCommandHandleResourcesList(t *testing.T) {
 	cmdProxy, servers := setupTestCommandProxy(t)
@@ -526,3 +556,252 @@ func TestCommandHandleErrors(t *testing.T) {
 		})
 	}
 }
+
+// TestCommandAdminListen tests that the optional admin listener serves metrics, health and servers.
+func TestCommandAdminListen(t *testing.T) {
+	cmdProxy, servers := setupTestCommandProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+
+	cmdProxy.SetAdminListen("127.0.0.1:0")
+	require.NoError(t, cmdProxy.startAdmin())
+	require.NotEmpty(t, cmdProxy.adminAddr)
+	base := "http://" + cmdProxy.adminAddr
+
+	resp, err := http.Get(base + "/metrics")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_, ok := proxytest.MetricValue(string(body), "mcp_proxy_buffered_request_bytes", nil)
+	assert.True(t, ok)
+
+	resp, err = http.Get(base + "/healthz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Get(base + "/servers")
+	require.NoError(t, err)
+	var serversResp struct {
+		Servers []ServerDetail `json:"servers"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&serversResp))
+	resp.Body.Close()
+	assert.Len(t, serversResp.Servers, len(servers))
+
+	// Endpoints outside the admin surface are not served
+	resp, err = http.Get(base + "/tools")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	require.NoError(t, cmdProxy.Shutdown(context.Background()))
+	_, err = http.Get(base + "/healthz")
+	assert.Error(t, err)
+}
+
+// TestCommandBatchDuplicateIDs tests that a batch is answered in order, that a request
+// reusing an earlier id in the batch gets -32600 while the others are handled, and
+// that null ids and notifications are not treated as duplicates.
+func TestCommandBatchDuplicateIDs(t *testing.T) {
+	cmdProxy, servers := setupTestCommandProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+
+	respBytes, err := cmdProxy.handleCommandRequest([]byte(`[
+		{"jsonrpc":"2.0","id":7,"method":"tools/list"},
+		{"jsonrpc":"2.0","id":7,"method":"resources/list"},
+		{"jsonrpc":"2.0","id":"7","method":"resources/list"},
+		{"jsonrpc":"2.0","id":null,"method":"tools/list"},
+		{"jsonrpc":"2.0","id":null,"method":"tools/list"},
+		{"jsonrpc":"2.0","method":"notifications/initialized"}
+	]`))
+	require.NoError(t, err)
+	var responses []jsonRPCResponse
+	require.NoError(t, json.Unmarshal(respBytes, &responses))
+	require.Len(t, responses, 5)
+
+	assert.Nil(t, responses[0].Error)
+	assert.NotNil(t, responses[0].Result)
+	require.NotNil(t, responses[1].Error)
+	assert.Equal(t, -32600, responses[1].Error.Code)
+	assert.Equal(t, "Invalid Request: duplicate id in batch", responses[1].Error.Message)
+	assert.EqualValues(t, 7, responses[1].ID)
+	for _, resp := range responses[2:] {
+		assert.Nil(t, resp.Error, "a string id and null ids are not duplicates of 7")
+	}
+
+	respBytes, err = cmdProxy.handleCommandRequest([]byte(`[]`))
+	require.NoError(t, err)
+	var errResp jsonRPCResponse
+	require.NoError(t, json.Unmarshal(respBytes, &errResp))
+	require.NotNil(t, errResp.Error)
+	assert.Equal(t, -32600, errResp.Error.Code)
+}
+
+// TestCommandLenientJSONRPC tests that a missing jsonrpc member is rejected by default
+// and accepted under lenient_jsonrpc, while a wrong version is always rejected.
+func TestCommandLenientJSONRPC(t *testing.T) {
+	cmdProxy, servers := setupTestCommandProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+
+	tests := []struct {
+		name     string
+		request  string
+		lenient  bool
+		accepted bool
+	}{
+		{"correct version", `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`, false, true},
+		{"correct version, lenient", `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`, true, true},
+		{"missing version", `{"id":1,"method":"tools/list"}`, false, false},
+		{"missing version, lenient", `{"id":1,"method":"tools/list"}`, true, true},
+		{"wrong version", `{"jsonrpc":"1.0","id":1,"method":"tools/list"}`, false, false},
+		{"wrong version, lenient", `{"jsonrpc":"1.0","id":1,"method":"tools/list"}`, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmdProxy.ps.lenientJSONRPC = tt.lenient
+			respBytes, err := cmdProxy.handleCommandRequest([]byte(tt.request))
+			require.NoError(t, err)
+			var rpcResp jsonRPCResponse
+			require.NoError(t, json.Unmarshal(respBytes, &rpcResp))
+			assert.Equal(t, "2.0", rpcResp.JSONRPC)
+			assert.EqualValues(t, 1, rpcResp.ID)
+			if tt.accepted {
+				assert.Nil(t, rpcResp.Error)
+				assert.NotNil(t, rpcResp.Result)
+			} else {
+				require.NotNil(t, rpcResp.Error)
+				assert.Equal(t, -32600, rpcResp.Error.Code)
+			}
+		})
+	}
+}
+
+// TestCommandMultiLineRequests tests that requests pretty-printed across lines are read
+// as a stream of JSON values, while compact requests keep being read one per line.
+func TestCommandMultiLineRequests(t *testing.T) {
+	cmdProxy, servers := setupTestCommandProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		ids   []interface{} // Response ids in order, nil for a parse error
+	}{
+		{
+			name:  "newline-delimited",
+			input: "{\"jsonrpc\":\"2.0\",\"id\":1,\"method\":\"tools/list\"}\nnot json\r\n{\"jsonrpc\":\"2.0\",\"id\":2,\"method\":\"tools/list\"}",
+			ids:   []interface{}{float64(1), nil, float64(2)},
+		},
+		{
+			name: "pretty-printed",
+			input: `{"jsonrpc":"2.0","id":1,"method":"tools/list"}
+{
+  "jsonrpc": "2.0",
+  "id": 2,
+  "method": "tools/list"
+}
+{"jsonrpc":"2.0","id":3,"method":"tools/list"}   {
+	"jsonrpc": "2.0", "id": "four",
+	"method": "tools/list"}
+`,
+			ids: []interface{}{float64(1), float64(2), float64(3), "four"},
+		},
+		{
+			name:  "invalid JSON after a multi-line request",
+			input: "{\n\"jsonrpc\":\"2.0\",\"id\":1,\"method\":\"tools/list\"}\n{oops}\n{\"jsonrpc\":\"2.0\",\"id\":2,\"method\":\"tools/list\"}\n",
+			ids:   []interface{}{float64(1), nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			cmdProxy.in, cmdProxy.out = strings.NewReader(tt.input), &out
+			err := cmdProxy.serveLines()
+			if tt.ids[len(tt.ids)-1] == nil {
+				assert.Error(t, err, "the stream cannot be read past invalid JSON")
+			} else {
+				require.NoError(t, err)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			require.Len(t, lines, len(tt.ids), out.String())
+			for i, line := range lines {
+				var resp jsonRPCResponse
+				require.NoError(t, json.Unmarshal([]byte(line), &resp), line)
+				assert.Equal(t, tt.ids[i], resp.ID)
+				if tt.ids[i] == nil {
+					require.NotNil(t, resp.Error)
+					assert.Equal(t, -32700, resp.Error.Code)
+				} else {
+					assert.Nil(t, resp.Error, line)
+				}
+			}
+		})
+	}
+}
+
+// TestCommandMessageSizeLimit tests that a message over max_message_bytes is answered
+// with -32600: an oversized line is skipped, while a stream of JSON values ends.
+func TestCommandMessageSizeLimit(t *testing.T) {
+	cmdProxy, servers := setupTestCommandProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+	cmdProxy.ps.maxMessageBytes = 100
+	padding := strings.Repeat("x", 8192)
+
+	tests := []struct {
+		name    string
+		input   string
+		ids     []interface{} // Response ids, nil for the size error
+		wantErr bool
+	}{
+		{
+			name:  "newline-delimited",
+			input: "{\"jsonrpc\":\"2.0\",\"id\":1,\"method\":\"tools/list\"}\n{\"jsonrpc\":\"2.0\",\"id\":2,\"method\":\"tools/list\",\"params\":{\"pad\":\"" + padding + "\"}}\n{\"jsonrpc\":\"2.0\",\"id\":3,\"method\":\"tools/list\"}\n",
+			ids:   []interface{}{float64(1), nil, float64(3)},
+		},
+		{
+			name:    "pretty-printed",
+			input:   "{\n\"jsonrpc\":\"2.0\",\"id\":1,\"method\":\"tools/list\"}\n{\n\"jsonrpc\":\"2.0\",\"id\":2,\n\"params\":{\"pad\":\"" + padding + "\"}}\n{\"jsonrpc\":\"2.0\",\"id\":3,\"method\":\"tools/list\"}\n",
+			ids:     []interface{}{float64(1), nil},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			cmdProxy.in, cmdProxy.out = strings.NewReader(tt.input), &out
+			err := cmdProxy.serveLines()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errMessageTooLarge)
+			} else {
+				require.NoError(t, err)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			require.Len(t, lines, len(tt.ids), out.String())
+			got := make([]interface{}, 0, len(lines))
+			for _, line := range lines {
+				var resp jsonRPCResponse
+				require.NoError(t, json.Unmarshal([]byte(line), &resp), line)
+				if resp.ID == nil {
+					require.NotNil(t, resp.Error)
+					assert.Equal(t, -32600, resp.Error.Code)
+				}
+				got = append(got, resp.ID)
+			}
+			assert.ElementsMatch(t, tt.ids, got)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// deadLetterRecord is one line of the dead-letter file, describing a failed tool call.
+type deadLetterRecord struct {
+	Time         time.Time              `json:"time"`
+	ID           string                 `json:"id"`
+	Server       string                 `json:"server,omitempty"`
+	Tool         string                 `json:"tool"`
+	Client       string                 `json:"client,omitempty"`
+	Arguments    map[string]interface{} `json:"arguments,omitempty"`
+	Error        string                 `json:"error"`
+	UpstreamBody string                 `json:"upstreamBody,omitempty"`
+}
+
+// upstreamBodyError carries the raw response body of a failed backend call so it can be
+// written to the dead-letter file. Its message is that of the wrapped error.
+type upstreamBodyError struct {
+	err  error
+	body []byte
+}
+
+func (e *upstreamBodyError) Error() string { return e.err.Error() }
+func (e *upstreamBodyError) Unwrap() error { return e.err }
+
+// withUpstreamBody attaches body to err.
+func withUpstreamBody(err error, body []byte) error {
+	return &upstreamBodyError{err: err, body: body}
+}
+
+// upstreamBody returns the response body attached to err by withUpstreamBody, if any.
+func upstreamBody(err error) string {
+	var ube *upstreamBodyError
+	if errors.As(err, &ube) {
+		return string(ube.body)
+	}
+	return ""
+}
+
+// deadLetter appends failed tool calls to a file as JSON lines. When the file would grow
+// past maxBytes it is renamed to path.1, replacing the previous one, and a new file is started.
+type deadLetter struct {
+	path     string
+	maxBytes int64
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+
+	seq atomic.Uint64
+}
+
+// openDeadLetter opens (or creates) the dead-letter file for appending.
+func openDeadLetter(path string, maxBytes int64) (*deadLetter, error) {
+	d := &deadLetter{path: path, maxBytes: maxBytes}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create dead-letter directory: %w", err)
+	}
+	if err := d.openActive(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// openActive opens the active file. Records may hold sensitive arguments, so the file is
+// readable by its owner only.
+func (d *deadLetter) openActive() error {
+	f, err := os.OpenFile(d.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open dead-letter file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat dead-letter file: %w", err)
+	}
+	d.file = f
+	d.size = info.Size()
+	return nil
+}
+
+// append writes a record as a single line, rotating the file first if needed.
+func (d *deadLetter) append(rec deadLetterRecord) error {
+	rec.ID = fmt.Sprintf("%d-%d", rec.Time.UnixNano(), d.seq.Add(1))
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.size > 0 && d.size+int64(len(line)) > d.maxBytes {
+		if err := d.file.Close(); err != nil {
+			return err
+		}
+		if err := os.Rename(d.path, d.path+".1"); err != nil {
+			return fmt.Errorf("failed to rotate dead-letter file: %w", err)
+		}
+		if err := d.openActive(); err != nil {
+			return err
+		}
+	}
+	n, err := d.file.Write(line)
+	d.size += int64(n)
+	return err
+}
+
+// Close closes the dead-letter file.
+func (d *deadLetter) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.file.Close()
+}
+
+// recordDeadLetter writes a failed tool call to the dead-letter file when it is enabled.
+// A write failure is logged and otherwise ignored.
+func (ps *ProxyServer) recordDeadLetter(start time.Time, client, toolName string, arguments map[string]interface{}, callErr error) {
+	if ps.deadLetter == nil {
+		return
+	}
+	rec := deadLetterRecord{
+		Time:         start,
+		Tool:         toolName,
+		Client:       client,
+		Arguments:    arguments,
+		Error:        callErr.Error(),
+		UpstreamBody: upstreamBody(callErr),
+	}
+	if server := ps.findMCPServerByTool(toolName); server != nil {
+		rec.Server = server.Config.Name
+	}
+	if err := ps.deadLetter.append(rec); err != nil {
+		log.Printf("Failed to write dead-letter record for tool '%s': %v", toolName, err)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readDeadLetters decodes every record in the dead-letter file at path.
+func readDeadLetters(t *testing.T, path string) []deadLetterRecord {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var records []deadLetterRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec deadLetterRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &rec))
+		records = append(records, rec)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
+
+// TestDeadLetterFailedCall tests that a failed tool call is written to the dead-letter file
+// with its server, arguments, error and upstream body, and that successful calls are not.
+func TestDeadLetterFailedCall(t *testing.T) {
+	backend, _, _ := testFlakyServer(1)
+	defer backend.Close()
+
+	path := filepath.Join(t.TempDir(), "dead-letter.jsonl")
+	ps, err := NewProxyServer(&config.Config{
+		MCPServers:     []config.MCPServerConfig{{Name: "flaky-server", Address: backend.URL}},
+		DeadLetterFile: path,
+	})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+
+	_, err = ps.CallTool("flaky", map[string]interface{}{"q": "x"})
+	require.Error(t, err)
+	_, err = ps.CallTool("flaky", map[string]interface{}{"q": "y"})
+	require.NoError(t, err)
+
+	records := readDeadLetters(t, path)
+	require.Len(t, records, 1)
+	rec := records[0]
+	assert.NotEmpty(t, rec.ID)
+	assert.WithinDuration(t, time.Now(), rec.Time, time.Minute)
+	assert.Equal(t, "flaky-server", rec.Server)
+	assert.Equal(t, "flaky", rec.Tool)
+	assert.Equal(t, map[string]interface{}{"q": "x"}, rec.Arguments)
+	assert.Contains(t, rec.Error, "status 500")
+	assert.Equal(t, "flaky failure\n", rec.UpstreamBody)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+// TestDeadLetterRotation tests that the file is rotated to path.1 once it reaches its cap.
+func TestDeadLetterRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead-letter.jsonl")
+	d, err := openDeadLetter(path, 300)
+	require.NoError(t, err)
+	defer d.Close()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, d.append(deadLetterRecord{Time: time.Now(), Tool: "tool1", Error: "boom"}))
+	}
+	assert.FileExists(t, path+".1")
+	assert.NoFileExists(t, path+".2")
+	for _, p := range []string{path, path + ".1"} {
+		info, err := os.Stat(p)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, info.Size(), int64(300))
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Base fields of every ECS document.
+const (
+	ecsVersion     = "8.11.0"
+	ecsServiceName = "smart-mcp-proxy"
+)
+
+// logDateLayout is the prefix the standard logger puts before each line.
+const logDateLayout = "2006/01/02 15:04:05 "
+
+// ecsFields are the fields of one ECS document besides the base fields, keyed by their
+// dotted names.
+type ecsFields map[string]interface{}
+
+// ecsLogger writes Elastic Common Schema documents, one JSON object per line with
+// dotted field names as written by the ecs-logging libraries. As an io.Writer it turns
+// each line of the standard logger into a document.
+type ecsLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newECSLogger(w io.Writer) *ecsLogger {
+	return &ecsLogger{w: w}
+}
+
+// stderrECSLog writes the ECS documents of the process to stderr, shared by the
+// standard logger and the proxy so lines never interleave.
+var stderrECSLog = newECSLogger(os.Stderr)
+
+// setupLogSchema switches the standard logger and the stderr passthrough of stdio
+// servers to ECS documents on stderr under log_schema "ecs". /status keeps receiving
+// the text lines.
+func setupLogSchema(cfg *config.Config) {
+	if cfg.LogSchemaOrDefault() != config.LogSchemaECS {
+		return
+	}
+	log.SetOutput(io.MultiWriter(stderrECSLog, recentLogs))
+	config.SetStderrHandler(stderrECSLog.backendStderr)
+}
+
+// backendStderr logs a stderr line of a stdio server as a document, and as text for
+// /status.
+func (l *ecsLogger) backendStderr(server, line string) {
+	l.log("info", line, ecsFields{"event.kind": "event", "event.action": "backend_stderr", "labels.server": server})
+	fmt.Fprintf(recentLogs, "%sMCP server %s stderr: %s\n", time.Now().Format(logDateLayout), server, line)
+}
+
+// log writes one document.
+func (l *ecsLogger) log(level, message string, fields ecsFields) {
+	doc := make(map[string]interface{}, len(fields)+5)
+	for name, value := range fields {
+		doc[name] = value
+	}
+	doc["@timestamp"] = time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	doc["log.level"] = level
+	doc["message"] = message
+	doc["ecs.version"] = ecsVersion
+	doc["service.name"] = ecsServiceName
+	line, err := json.Marshal(doc)
+	if err != nil {
+		return // Fields only hold strings and numbers
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(append(line, '\n'))
+}
+
+// Write logs one call of the standard logger as a document, without its date prefix.
+// Lines starting with WARNING or ERROR get that level.
+func (l *ecsLogger) Write(p []byte) (int, error) {
+	message := strings.TrimSuffix(string(p), "\n")
+	if len(message) >= len(logDateLayout) {
+		if _, err := time.Parse(logDateLayout, message[:len(logDateLayout)]); err == nil {
+			message = message[len(logDateLayout):]
+		}
+	}
+	level := "info"
+	switch {
+	case strings.HasPrefix(message, "WARNING"):
+		level = "warn"
+	case strings.HasPrefix(message, "ERROR"):
+		level = "error"
+	}
+	l.log(level, message, nil)
+	return len(p), nil
+}
+
+// accessLog is the ECS counterpart of Gin's request logger.
+func (l *ecsLogger) accessLog(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+	status := c.Writer.Status()
+	fields := ecsFields{
+		"event.kind":                "event",
+		"event.action":              "http_request",
+		"event.duration":            time.Since(start).Nanoseconds(),
+		"event.outcome":             ecsOutcome(status < http.StatusBadRequest),
+		"http.request.method":       c.Request.Method,
+		"http.response.status_code": status,
+		"url.path":                  c.Request.URL.Path,
+		"client.ip":                 c.ClientIP(),
+	}
+	if err := c.Errors.Last(); err != nil {
+		fields["error.message"] = err.Error()
+	}
+	l.log("info", fmt.Sprintf("%s %s %d", c.Request.Method, c.Request.URL.Path, status), fields)
+}
+
+// ecsOutcome returns the event.outcome of an event that succeeded or failed.
+func ecsOutcome(success bool) string {
+	if success {
+		return "success"
+	}
+	return "failure"
+}
+
+// newGinEngine returns a Gin engine with panic recovery and a request log: Gin's text
+// log, or ECS documents when logs is set.
+func newGinEngine(logs *ecsLogger) *gin.Engine {
+	if logs == nil {
+		return gin.Default()
+	}
+	engine := gin.New()
+	engine.Use(logs.accessLog, gin.Recovery())
+	return engine
+}
+
+// logToolCall writes a tool call recorded for /status as a document.
+func (ps *ProxyServer) logToolCall(rec ToolCallRecord, duration time.Duration) {
+	if ps.ecsLog == nil {
+		return
+	}
+	fields := ecsFields{
+		"event.kind":     "event",
+		"event.action":   "tool_call",
+		"event.duration": duration.Nanoseconds(),
+		"event.outcome":  ecsOutcome(rec.Error == ""),
+		"labels.tool":    rec.Tool,
+	}
+	if rec.Client != "" {
+		fields["labels.client"] = rec.Client
+	}
+	level := "info"
+	if rec.Error != "" {
+		fields["error.message"] = rec.Error
+		level = "warn"
+	}
+	ps.ecsLog.log(level, "Tool call "+rec.Tool, fields)
+}
+
+// logServerEvent writes a server event as a document, with event.action set to its kind.
+func (ps *ProxyServer) logServerEvent(e config.ServerEvent) {
+	if ps.ecsLog == nil {
+		return
+	}
+	fields := ecsFields{"event.kind": "event", "event.action": string(e.Kind), "labels.server": e.Server}
+	level := "info"
+	if e.Err != nil {
+		fields["error.message"] = e.Err.Error()
+		level = "warn"
+	}
+	ps.ecsLog.log(level, fmt.Sprintf("MCP server %s: %s", e.Server, e.Kind), fields)
+}
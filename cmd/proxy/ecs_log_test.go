@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ecsAllowedFields are the ECS fields the proxy may write; labels.* are allowed too.
+var ecsAllowedFields = map[string]bool{
+	"@timestamp":                true,
+	"log.level":                 true,
+	"message":                   true,
+	"ecs.version":               true,
+	"service.name":              true,
+	"event.kind":                true,
+	"event.action":              true,
+	"event.duration":            true,
+	"event.outcome":             true,
+	"error.message":             true,
+	"http.request.method":       true,
+	"http.response.status_code": true,
+	"url.path":                  true,
+	"client.ip":                 true,
+}
+
+// TestECSLogs tests that access logs, tool calls, server events, standard logger lines
+// and backend stderr are written as ECS documents using only allowed fields.
+func TestECSLogs(t *testing.T) {
+	backend := proxytest.NewBackend([]proxytest.Tool{{Name: "search"}}, nil)
+	defer backend.Close()
+	ps, err := NewProxyServer(&config.Config{
+		MCPServers: []config.MCPServerConfig{{Name: "server1", Address: backend.URL}},
+		LogSchema:  config.LogSchemaECS,
+	})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	require.Same(t, stderrECSLog, ps.ecsLog)
+	var buf bytes.Buffer
+	logs := newECSLogger(&buf)
+	ps.ecsLog = logs
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	httpProxy.engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/tools", nil))
+	req := httptest.NewRequest("POST", "/tool/search", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	httpProxy.engine.ServeHTTP(httptest.NewRecorder(), req)
+	ps.logServerEvent(config.ServerEvent{Kind: config.EventBackendDown, Server: "server1", Err: errors.New("exit status 1")})
+	logs.Write([]byte("2026/10/16 12:00:00 WARNING: something happened\n"))
+	logs.backendStderr("server1", "listening on stdio")
+
+	logs.mu.Lock()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	logs.mu.Unlock()
+	byAction := make(map[string]map[string]interface{})
+	for _, line := range lines {
+		var doc map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &doc), line)
+		for name := range doc {
+			assert.True(t, ecsAllowedFields[name] || strings.HasPrefix(name, "labels."), "field %s is not in the ECS allowlist", name)
+		}
+		_, err := time.Parse(time.RFC3339Nano, doc["@timestamp"].(string))
+		assert.NoError(t, err)
+		assert.Equal(t, ecsVersion, doc["ecs.version"])
+		assert.Equal(t, "smart-mcp-proxy", doc["service.name"])
+		assert.Contains(t, []string{"info", "warn", "error"}, doc["log.level"])
+		action, _ := doc["event.action"].(string)
+		if byAction[action] == nil || action == "http_request" && doc["http.request.method"] == "POST" {
+			byAction[action] = doc
+		}
+	}
+
+	access := byAction["http_request"]
+	require.NotNil(t, access, "access log")
+	assert.Equal(t, "/tool/search", access["url.path"])
+	assert.Equal(t, float64(200), access["http.response.status_code"])
+	assert.IsType(t, float64(0), access["event.duration"])
+	assert.Equal(t, "success", access["event.outcome"])
+
+	call := byAction["tool_call"]
+	require.NotNil(t, call, "tool call")
+	assert.Equal(t, "search", call["labels.tool"])
+	assert.IsType(t, float64(0), call["event.duration"])
+
+	event := byAction[string(config.EventBackendDown)]
+	require.NotNil(t, event, "server event")
+	assert.Equal(t, "server1", event["labels.server"])
+	assert.Equal(t, "exit status 1", event["error.message"])
+	assert.Equal(t, "warn", event["log.level"])
+
+	line := byAction[""]
+	require.NotNil(t, line, "standard logger line")
+	assert.Equal(t, "WARNING: something happened", line["message"])
+	assert.Equal(t, "warn", line["log.level"])
+
+	stderr := byAction["backend_stderr"]
+	require.NotNil(t, stderr, "backend stderr")
+	assert.Equal(t, "listening on stdio", stderr["message"])
+	assert.Equal(t, "server1", stderr["labels.server"])
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEmptyListsSerializeAsArrays tests that listings of a proxy without any tool or
+// resource encode their lists as [] rather than null, in both modes.
+func TestEmptyListsSerializeAsArrays(t *testing.T) {
+	backend := proxytest.NewBackend(nil, nil)
+	defer backend.Close()
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{{Name: "empty", Address: backend.URL}}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+	for path, want := range map[string]string{
+		"/tools":                  `{"tools":[]}`,
+		"/resources":              `{"resources":[]}`,
+		"/restricted-tools":       `{"tools":[]}`,
+		"/restricted-resources":   `{"resources":[]}`,
+		"/export/openai-tools":    `{"tools":[],"warnings":[]}`,
+		"/export/anthropic-tools": `{"tools":[],"warnings":[]}`,
+	} {
+		w := httptest.NewRecorder()
+		httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		require.Equal(t, http.StatusOK, w.Code, path)
+		assert.Equal(t, want, w.Body.String(), path)
+	}
+
+	id := mcpInitialize(t, httpProxy)
+	w := mcpRequest(t, httpProxy, id, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	assert.Equal(t, `{"jsonrpc":"2.0","id":1,"result":{"tools":[]}}`, w.Body.String())
+	w = mcpRequest(t, httpProxy, id, `{"jsonrpc":"2.0","id":2,"method":"resources/list"}`)
+	assert.Equal(t, `{"jsonrpc":"2.0","id":2,"result":{"resources":[]}}`, w.Body.String())
+
+	cmdProxy, err := NewCommandProxy(ps)
+	require.NoError(t, err)
+	for method, want := range map[string]string{
+		"tools/list":               `{"jsonrpc":"2.0","id":1,"result":{"tools":[]}}`,
+		"resources/list":           `{"jsonrpc":"2.0","id":1,"result":{"resources":[]}}`,
+		"restrictedTools/list":     `{"jsonrpc":"2.0","id":1,"result":{"tools":[]}}`,
+		"restrictedResources/list": `{"jsonrpc":"2.0","id":1,"result":{"resources":[]}}`,
+	} {
+		respBytes, err := cmdProxy.handleCommandRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"` + method + `"}`))
+		require.NoError(t, err)
+		assert.Equal(t, want, string(respBytes), method)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+)
+
+// errorBudgetBuckets is the number of buckets the rolling window is divided into.
+const errorBudgetBuckets = 10
+
+// Error budget webhook event types.
+const (
+	eventServerDegraded  = "server_degraded"
+	eventServerRecovered = "server_recovered"
+)
+
+// ErrorBudgetEvent is posted to the error budget webhook when a server's degraded flag changes.
+type ErrorBudgetEvent struct {
+	Event     string    `json:"event"`
+	Server    string    `json:"server"`
+	ErrorRate float64   `json:"errorRate"`
+	Calls     int       `json:"calls"`
+	Time      time.Time `json:"time"`
+}
+
+// callBucket counts call outcomes within one slice of the rolling window.
+type callBucket struct {
+	start   time.Time
+	success int
+	errors  int
+}
+
+// serverBudget is the rolling window and degraded flag of one server.
+type serverBudget struct {
+	buckets  [errorBudgetBuckets]callBucket
+	degraded bool
+}
+
+// errorBudget tracks per-server error rates and flips a degraded flag with hysteresis:
+// a server degrades when its error rate reaches the threshold over at least minRequests
+// calls, and recovers once the rate falls to the recovery rate. Outcomes come from
+// recordServerCall, the same place the per-server call counter is incremented.
+type errorBudget struct {
+	window        time.Duration
+	bucketWidth   time.Duration
+	threshold     float64
+	recovery      float64
+	minRequests   int
+	failReadiness bool
+	webhookURL    string
+
+	mu      sync.Mutex
+	servers map[string]*serverBudget
+	now     func() time.Time
+	notify  func(ErrorBudgetEvent)
+}
+
+// newErrorBudget builds a tracker from cfg; cfg is validated at load time.
+func newErrorBudget(cfg config.ErrorBudgetConfig) (*errorBudget, error) {
+	window, err := cfg.WindowDuration()
+	if err != nil {
+		return nil, err
+	}
+	if window <= 0 {
+		return nil, errors.New("window must be positive")
+	}
+	eb := &errorBudget{
+		window:        window,
+		bucketWidth:   window / errorBudgetBuckets,
+		threshold:     cfg.ErrorRateThreshold,
+		recovery:      cfg.RecoveryRateOrDefault(),
+		minRequests:   cfg.MinRequestsOrDefault(),
+		failReadiness: cfg.FailReadiness,
+		webhookURL:    cfg.WebhookURL,
+		servers:       make(map[string]*serverBudget),
+		now:           time.Now,
+	}
+	eb.notify = eb.postWebhook
+	return eb, nil
+}
+
+// observe records one call outcome for server and re-evaluates its degraded flag.
+func (eb *errorBudget) observe(server string, success bool) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	sb := eb.serverLocked(server)
+	now := eb.now()
+	start := now.Truncate(eb.bucketWidth)
+	b := &sb.buckets[(start.UnixNano()/int64(eb.bucketWidth))%errorBudgetBuckets]
+	if !b.start.Equal(start) {
+		*b = callBucket{start: start}
+	}
+	if success {
+		b.success++
+	} else {
+		b.errors++
+	}
+	eb.evaluateLocked(server, sb, now)
+}
+
+// serverLocked returns the budget for server, creating it. Callers must hold eb.mu.
+func (eb *errorBudget) serverLocked(server string) *serverBudget {
+	sb, ok := eb.servers[server]
+	if !ok {
+		sb = &serverBudget{}
+		eb.servers[server] = sb
+	}
+	return sb
+}
+
+// rateLocked returns the error rate and call count within the window ending at now.
+func (eb *errorBudget) rateLocked(sb *serverBudget, now time.Time) (float64, int) {
+	var success, failed int
+	for _, b := range sb.buckets {
+		if now.Sub(b.start) < eb.window {
+			success += b.success
+			failed += b.errors
+		}
+	}
+	calls := success + failed
+	if calls == 0 {
+		return 0, 0
+	}
+	return float64(failed) / float64(calls), calls
+}
+
+// evaluateLocked flips the degraded flag when the rate crosses the thresholds,
+// updating the gauge and sending a webhook event on each change.
+func (eb *errorBudget) evaluateLocked(server string, sb *serverBudget, now time.Time) (float64, int) {
+	rate, calls := eb.rateLocked(sb, now)
+	event := ""
+	switch {
+	case !sb.degraded && calls >= eb.minRequests && rate >= eb.threshold:
+		sb.degraded = true
+		event = eventServerDegraded
+		log.Printf("WARNING: MCP server %s is degraded: error rate %.0f%% over %d calls in the last %s", server, rate*100, calls, eb.window)
+	case sb.degraded && rate <= eb.recovery:
+		sb.degraded = false
+		event = eventServerRecovered
+		log.Printf("MCP server %s recovered: error rate %.0f%% over %d calls", server, rate*100, calls)
+	}
+	if event != "" {
+		if serverDegraded != nil { // Check if initialized
+			value := 0.0
+			if sb.degraded {
+				value = 1
+			}
+			serverDegraded.WithLabelValues(server).Set(value)
+		}
+		eb.notify(ErrorBudgetEvent{Event: event, Server: server, ErrorRate: rate, Calls: calls, Time: now})
+	}
+	return rate, calls
+}
+
+// state re-evaluates server against the current window, so flags clear as errors age out,
+// and returns whether it is degraded along with its current error rate.
+func (eb *errorBudget) state(server string) (bool, float64) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	sb, ok := eb.servers[server]
+	if !ok {
+		return false, 0
+	}
+	rate, _ := eb.evaluateLocked(server, sb, eb.now())
+	return sb.degraded, rate
+}
+
+// postWebhook sends event to the configured webhook in the background.
+func (eb *errorBudget) postWebhook(event ErrorBudgetEvent) {
+	if eb.webhookURL == "" {
+		return
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode error budget event: %v", err)
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, eb.webhookURL, bytes.NewReader(body))
+		if err != nil {
+			log.Printf("Failed to create error budget webhook request: %v", err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			log.Printf("Failed to send error budget webhook for server %s: %v", event.Server, err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("Error budget webhook for server %s returned status %d", event.Server, resp.StatusCode)
+		}
+	}()
+}
+
+// recordServerCall counts one tool call attempt against server, feeding both the
+// per-server call metric and the error budget. Cancelled attempts and errors raised by
+// the proxy itself say nothing about backend health and are not counted.
+func (ps *ProxyServer) recordServerCall(ctx context.Context, server string, err error) {
+	var success bool
+	switch {
+	case err == nil:
+		success = true
+	case ctx.Err() != nil || !errors.Is(err, ErrBackendCommunication):
+		return
+	}
+
+	if serverCallsTotal != nil { // Check if initialized
+		outcome := "success"
+		if !success {
+			outcome = "error"
+		}
+		serverCallsTotal.WithLabelValues(server, outcome).Inc()
+	}
+	if ps.errorBudget != nil {
+		ps.errorBudget.observe(server, success)
+	}
+}
+
+// DegradedServers returns the names of servers currently over their error budget.
+func (ps *ProxyServer) DegradedServers() []string {
+	degraded := []string{}
+	if ps.errorBudget == nil {
+		return degraded
+	}
+	for _, server := range ps.mcpServers {
+		if ok, _ := ps.errorBudget.state(server.Config.Name); ok {
+			degraded = append(degraded, server.Config.Name)
+		}
+	}
+	return degraded
+}
+
+// Ready reports whether the proxy should receive traffic. It is only false when
+// error_budget.fail_readiness is set and at least one server is degraded.
+func (ps *ProxyServer) Ready() (bool, []string) {
+	degraded := ps.DegradedServers()
+	if ps.errorBudget == nil || !ps.errorBudget.failReadiness {
+		return true, degraded
+	}
+	return len(degraded) == 0, degraded
+}
+
+// readinessResponse builds the /readyz status code and body shared by HTTP mode and the admin listener.
+func readinessResponse(ps *ProxyServer) (int, map[string]interface{}) {
+	ready, degraded := ps.Ready()
+	if !ready {
+		return http.StatusServiceUnavailable, map[string]interface{}{"status": "degraded", "degradedServers": degraded}
+	}
+	return http.StatusOK, map[string]interface{}{"status": "ready", "degradedServers": degraded}
+}
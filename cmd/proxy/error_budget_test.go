@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestErrorBudgetHysteresis tests that a server degrades at the threshold, stays degraded
+// until the recovery rate, and recovers as errors age out of the window.
+func TestErrorBudgetHysteresis(t *testing.T) {
+	eb, err := newErrorBudget(config.ErrorBudgetConfig{Window: "1m", ErrorRateThreshold: 0.5, RecoveryRate: 0.2, MinRequests: 4})
+	require.NoError(t, err)
+	now := time.Now()
+	eb.now = func() time.Time { return now }
+	var events []ErrorBudgetEvent
+	eb.notify = func(e ErrorBudgetEvent) { events = append(events, e) }
+
+	// Below min_requests nothing changes, even at a 100% error rate
+	for i := 0; i < 3; i++ {
+		eb.observe("s", false)
+	}
+	degraded, _ := eb.state("s")
+	assert.False(t, degraded)
+
+	eb.observe("s", true)
+	degraded, rate := eb.state("s")
+	assert.True(t, degraded)
+	assert.InDelta(t, 0.75, rate, 0.001)
+	require.Len(t, events, 1)
+	assert.Equal(t, eventServerDegraded, events[0].Event)
+
+	// Dropping below the threshold but above the recovery rate keeps the flag
+	for i := 0; i < 4; i++ {
+		eb.observe("s", true)
+	}
+	degraded, _ = eb.state("s") // 3 errors out of 8
+	assert.True(t, degraded)
+
+	// Errors age out of the window
+	now = now.Add(2 * time.Minute)
+	degraded, rate = eb.state("s")
+	assert.False(t, degraded)
+	assert.Zero(t, rate)
+	require.Len(t, events, 2)
+	assert.Equal(t, eventServerRecovered, events[1].Event)
+}
+
+// TestErrorBudgetWebhookAndReadyz tests that backend errors degrade a server, post the
+// webhook event, fail /readyz and show up in /status.
+func TestErrorBudgetWebhookAndReadyz(t *testing.T) {
+	backend, _, _ := testFlakyServer(100)
+	defer backend.Close()
+
+	events := make(chan ErrorBudgetEvent, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event ErrorBudgetEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		events <- event
+	}))
+	defer webhook.Close()
+
+	ps, err := NewProxyServer(&config.Config{
+		MCPServers: []config.MCPServerConfig{{Name: "flaky-server", Address: backend.URL}},
+		ErrorBudget: &config.ErrorBudgetConfig{
+			ErrorRateThreshold: 0.5,
+			MinRequests:        2,
+			FailReadiness:      true,
+			WebhookURL:         webhook.URL,
+		},
+	})
+	require.NoError(t, err)
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	for i := 0; i < 2; i++ {
+		_, err := ps.CallTool("flaky", map[string]interface{}{})
+		require.Error(t, err)
+	}
+
+	select {
+	case event := <-events:
+		assert.Equal(t, eventServerDegraded, event.Event)
+		assert.Equal(t, "flaky-server", event.Server)
+		assert.Equal(t, 1.0, event.ErrorRate)
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "flaky-server")
+
+	status := ps.Status()
+	require.Len(t, status.Servers, 1)
+	assert.True(t, status.Servers[0].Degraded)
+	assert.Equal(t, "degraded", status.Servers[0].Health)
+}
+
+// TestRecordServerCallSkipsCancelled tests that cancelled attempts are not counted.
+func TestRecordServerCallSkipsCancelled(t *testing.T) {
+	eb, err := newErrorBudget(config.ErrorBudgetConfig{ErrorRateThreshold: 0.5, MinRequests: 1})
+	require.NoError(t, err)
+	eb.notify = func(ErrorBudgetEvent) {}
+	ps := &ProxyServer{errorBudget: eb}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ps.recordServerCall(ctx, "s", fmt.Errorf("%w: %v", ErrBackendCommunication, ctx.Err()))
+	ps.recordServerCall(context.Background(), "s", ErrToolNotFound)
+	degraded, _ := eb.state("s")
+	assert.False(t, degraded)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+)
+
+// defaultEventBuffer is the number of events buffered for a subscriber that did not ask
+// for a size.
+const defaultEventBuffer = 64
+
+// Event is a change in the proxy's state published on its event bus.
+type Event struct {
+	Type   config.EventKind `json:"type"`
+	Server string           `json:"server,omitempty"`
+	Error  string           `json:"error,omitempty"`
+	Time   time.Time        `json:"time"`
+}
+
+// eventSubscription is one subscriber's buffer of events.
+type eventSubscription struct {
+	name     string // Labels the subscriber's dropped events metric
+	ch       chan Event
+	overflow string // config.SSEOverflowDropOldest, config.SSEOverflowDisconnect, or "" to drop the new event
+}
+
+// eventBus delivers events to subscribers without ever blocking the publisher: when a
+// subscriber's buffer is full, the new event is dropped for it, or, depending on its
+// overflow policy, its oldest event or its whole subscription.
+type eventBus struct {
+	mu     sync.RWMutex
+	subs   map[*eventSubscription]struct{}
+	closed bool
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subs: make(map[*eventSubscription]struct{})}
+}
+
+// subscribe returns a channel receiving the events published from now on, buffering up
+// to buffer of them, and a function ending the subscription. The channel is closed when
+// the subscription ends or the bus is closed.
+func (b *eventBus) subscribe(name string, buffer int) (<-chan Event, func()) {
+	return b.subscribeWithOverflow(name, buffer, "")
+}
+
+// subscribeWithOverflow is subscribe with an overflow policy for a full buffer:
+// config.SSEOverflowDropOldest makes room by dropping the oldest buffered event, and
+// config.SSEOverflowDisconnect ends the subscription, closing the channel.
+func (b *eventBus) subscribeWithOverflow(name string, buffer int, overflow string) (<-chan Event, func()) {
+	if buffer <= 0 {
+		buffer = defaultEventBuffer
+	}
+	sub := &eventSubscription{name: name, ch: make(chan Event, buffer), overflow: overflow}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(sub.ch)
+		return sub.ch, func() {}
+	}
+	b.subs[sub] = struct{}{}
+	return sub.ch, func() { b.unsubscribe(sub) }
+}
+
+func (b *eventBus) unsubscribe(sub *eventSubscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// publish hands e to every subscriber with room for it.
+func (b *eventBus) publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if eventsPublishedTotal != nil {
+		eventsPublishedTotal.WithLabelValues(string(e.Type)).Inc()
+	}
+	var disconnect []*eventSubscription
+	b.mu.RLock()
+	for sub := range b.subs {
+		if b.deliver(sub, e) {
+			continue
+		}
+		if eventsDroppedTotal != nil {
+			eventsDroppedTotal.WithLabelValues(sub.name).Inc()
+		}
+		if sub.overflow == config.SSEOverflowDisconnect {
+			disconnect = append(disconnect, sub)
+		}
+	}
+	b.mu.RUnlock()
+	// Closing a channel needs the write lock
+	for _, sub := range disconnect {
+		b.unsubscribe(sub)
+	}
+}
+
+// deliver hands e to sub without blocking, dropping sub's oldest event first under
+// config.SSEOverflowDropOldest. It reports false when an event was dropped, which is e
+// itself unless the oldest event made room. Callers must hold b.mu for reading.
+func (b *eventBus) deliver(sub *eventSubscription, e Event) bool {
+	select {
+	case sub.ch <- e:
+		return true
+	default:
+	}
+	if sub.overflow != config.SSEOverflowDropOldest {
+		return false
+	}
+	select {
+	case <-sub.ch:
+	default:
+	}
+	select {
+	case sub.ch <- e:
+	default:
+	}
+	return false
+}
+
+// close ends all subscriptions.
+func (b *eventBus) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// SubscribeEvents subscribes name to the proxy's events; see eventBus.subscribe.
+func (ps *ProxyServer) SubscribeEvents(name string, buffer int) (<-chan Event, func()) {
+	return ps.events.subscribe(name, buffer)
+}
+
+// publishServerEvent publishes an event reported by a backend server.
+func (ps *ProxyServer) publishServerEvent(e config.ServerEvent) {
+	event := Event{Type: e.Kind, Server: e.Server}
+	if e.Err != nil {
+		event.Error = e.Err.Error()
+	}
+	ps.events.publish(event)
+}
+
+// handleServerEvent feeds health changes of a backend server to its circuit breaker,
+// so a failing health check counts as a failed call and a passing one closes the
+// breaker, re-indexes the servers when discovery changed their tools or resources,
+// then logs and publishes the event.
+func (ps *ProxyServer) handleServerEvent(e config.ServerEvent) {
+	if ps.servers != nil && (e.Kind == config.EventToolsetChanged || e.Kind == config.EventResourcesChanged) {
+		ps.servers.rebuild(ps.mcpServers)
+	}
+	if breaker, ok := ps.breakers[e.Server]; ok {
+		switch e.Kind {
+		case config.EventUnhealthy:
+			breaker.recordFailure()
+		case config.EventHealthy:
+			breaker.recordSuccess()
+		}
+	}
+	ps.logServerEvent(e)
+	ps.publishServerEvent(e)
+}
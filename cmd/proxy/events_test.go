@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEventBusSlowSubscriber tests that a subscriber that does not read loses events
+// beyond its buffer without blocking the publisher or other subscribers.
+func TestEventBusSlowSubscriber(t *testing.T) {
+	bus := newEventBus()
+	slow, _ := bus.subscribe("slow", 2)
+	fast, _ := bus.subscribe("fast", 10)
+
+	published := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			bus.publish(Event{Type: config.EventToolsetChanged, Server: "s"})
+		}
+		close(published)
+	}()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("publish blocked on a slow subscriber")
+	}
+
+	assert.Len(t, slow, 2)
+	assert.Len(t, fast, 5)
+	e := <-fast
+	assert.Equal(t, config.EventToolsetChanged, e.Type)
+	assert.False(t, e.Time.IsZero())
+}
+
+// TestEventBusUnsubscribe tests that ending a subscription, or closing the bus, closes
+// the subscriber's channel.
+func TestEventBusUnsubscribe(t *testing.T) {
+	bus := newEventBus()
+	events, cancel := bus.subscribe("test", 0)
+	cancel()
+	cancel() // A second call does nothing
+	_, ok := <-events
+	assert.False(t, ok)
+
+	events, _ = bus.subscribe("test", 0)
+	bus.close()
+	_, ok = <-events
+	assert.False(t, ok)
+	bus.publish(Event{Type: config.EventBackendUp}) // Publishing after close is harmless
+
+	events, _ = bus.subscribe("late", 0)
+	_, ok = <-events
+	assert.False(t, ok)
+}
+
+// TestProxyServerPublishesServerEvents tests that events of backend servers reach
+// subscribers, here a refresh failing once the backend is gone.
+func TestProxyServerPublishesServerEvents(t *testing.T) {
+	backend, conf := testHttpServer("server1", []string{"tool1"}, nil, nil, nil)
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{conf}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	events, cancel := ps.SubscribeEvents("test", 0)
+	defer cancel()
+
+	backend.Close()
+	require.Error(t, ps.mcpServers[0].Refresh())
+	select {
+	case e := <-events:
+		assert.Equal(t, config.EventRefreshFailed, e.Type)
+		assert.Equal(t, "server1", e.Server)
+		assert.NotEmpty(t, e.Error)
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+	}
+}
+
+// TestEventBusOverflowPolicies tests that a full buffer drops its oldest event or ends
+// the subscription, depending on the subscriber's overflow policy.
+func TestEventBusOverflowPolicies(t *testing.T) {
+	bus := newEventBus()
+	oldest, _ := bus.subscribeWithOverflow("oldest", 2, config.SSEOverflowDropOldest)
+	disconnected, _ := bus.subscribeWithOverflow("disconnect", 2, config.SSEOverflowDisconnect)
+	for _, server := range []string{"s1", "s2", "s3", "s4"} {
+		bus.publish(Event{Type: config.EventBackendUp, Server: server})
+	}
+
+	require.Len(t, oldest, 2)
+	assert.Equal(t, "s3", (<-oldest).Server)
+	assert.Equal(t, "s4", (<-oldest).Server)
+
+	var received []string
+	for e := range disconnected {
+		received = append(received, e.Server)
+	}
+	assert.Equal(t, []string{"s1", "s2"}, received)
+	bus.mu.RLock()
+	assert.Len(t, bus.subs, 1)
+	bus.mu.RUnlock()
+}
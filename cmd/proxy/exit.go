@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"log"
+)
+
+// Process exit codes of the proxy.
+const (
+	exitOK             = 0
+	exitError          = 1 // Any failure not listed below
+	exitConfigError    = 2 // The configuration could not be loaded or was rejected
+	exitListenError    = 3 // A listener could not be bound or failed while serving
+	exitBackendStartup = 4 // MCP servers failed to start, including strict_startup failures
+)
+
+var (
+	// ErrConfig marks errors caused by an invalid configuration or command line.
+	ErrConfig = errors.New("configuration error")
+	// ErrListen marks errors binding or serving the HTTP or admin listener.
+	ErrListen = errors.New("listener error")
+	// ErrBackendStartup marks errors starting the configured MCP servers.
+	ErrBackendStartup = errors.New("failed to initialize MCP servers")
+)
+
+// exitCodeFor maps the error that stopped the proxy to its exit code and shutdown reason.
+func exitCodeFor(err error) (int, string) {
+	switch {
+	case err == nil:
+		return exitOK, ""
+	case errors.Is(err, ErrConfig):
+		return exitConfigError, "config error"
+	case errors.Is(err, ErrListen):
+		return exitListenError, "listen error"
+	case errors.Is(err, ErrBackendStartup):
+		return exitBackendStartup, "backend startup failure"
+	}
+	return exitError, "error"
+}
+
+// logExit writes the final log line of the process and returns its exit code. reason
+// describes a clean stop (e.g. "signal: terminated") and is ignored when err is set.
+func logExit(reason string, err error) int {
+	code, errReason := exitCodeFor(err)
+	if err != nil {
+		log.Printf("Proxy exited: reason=%q code=%d error=%q", errReason, code, err.Error())
+		return code
+	}
+	log.Printf("Proxy exited: reason=%q code=%d", reason, code)
+	return code
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExitCodeFor tests the mapping of stop errors to exit codes.
+func TestExitCodeFor(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{nil, exitOK},
+		{fmt.Errorf("%w: bad ttl", ErrConfig), exitConfigError},
+		{fmt.Errorf("%w: address in use", ErrListen), exitListenError},
+		{fmt.Errorf("%w: %w", ErrBackendStartup, config.ErrNoUsableServers), exitBackendStartup},
+		{errors.New("stdin closed with an error"), exitError},
+	}
+	for _, tt := range tests {
+		code, _ := exitCodeFor(tt.err)
+		assert.Equal(t, tt.code, code, "error %v", tt.err)
+	}
+
+	assert.Equal(t, exitConfigError, runProxy(filepath.Join(t.TempDir(), "missing.json"), modeHTTP, false, "", "", false))
+}
+
+// TestNewProxyServerErrorKinds tests that startup and configuration errors are told apart.
+func TestNewProxyServerErrorKinds(t *testing.T) {
+	backend, _, _ := testFlakyServer(0)
+	defer backend.Close()
+
+	_, err := NewProxyServer(&config.Config{
+		MCPServers:    []config.MCPServerConfig{{Name: "empty", Address: backend.URL, AllowedTools: []string{"missing"}}},
+		StrictStartup: true,
+	})
+	assert.ErrorIs(t, err, ErrBackendStartup)
+	assert.ErrorIs(t, err, config.ErrNoUsableServers)
+
+	_, err = NewProxyServer(&config.Config{
+		MCPServers: []config.MCPServerConfig{{Name: "flaky-server", Address: backend.URL}},
+		ToolJobTTL: "soon",
+	})
+	assert.ErrorIs(t, err, ErrConfig)
+}
+
+// TestHTTPRunListenError tests that a listener that cannot be bound is returned as ErrListen.
+func TestHTTPRunListenError(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer taken.Close()
+
+	backend, _, _ := testFlakyServer(0)
+	defer backend.Close()
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{{Name: "flaky-server", Address: backend.URL}}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, taken.Addr().String())
+	require.NoError(t, err)
+
+	err = httpProxy.Run()
+	assert.ErrorIs(t, err, ErrListen)
+	code, _ := exitCodeFor(err)
+	assert.Equal(t, exitListenError, code)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// anthropicToolNamePattern is the set of names the Anthropic Messages API accepts for tools.
+var anthropicToolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,128}$`)
+
+// anthropicTool is a single entry of the Anthropic Messages API `tools` array.
+type anthropicTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+// anthropicToolUse is a `tool_use` content block produced by the model.
+type anthropicToolUse struct {
+	Type  string                 `json:"type"`
+	ID    string                 `json:"id"`
+	Name  string                 `json:"name"`
+	Input map[string]interface{} `json:"input"`
+}
+
+// anthropicImageSource is the source of an Anthropic image content block.
+type anthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+// anthropicContentBlock is a text or image block nested in a tool_result.
+type anthropicContentBlock struct {
+	Type   string                `json:"type"`
+	Text   string                `json:"text,omitempty"`
+	Source *anthropicImageSource `json:"source,omitempty"`
+}
+
+// anthropicToolResult is the `tool_result` content block sent back to the model.
+type anthropicToolResult struct {
+	Type      string                  `json:"type"`
+	ToolUseID string                  `json:"tool_use_id"`
+	Content   []anthropicContentBlock `json:"content"`
+	IsError   bool                    `json:"is_error,omitempty"`
+}
+
+// ExportAnthropicTools converts every advertised tool into the Anthropic tools format.
+// As with the OpenAI export, tools with names the API cannot represent are skipped.
+func (ps *ProxyServer) ExportAnthropicTools() ([]anthropicTool, []string) {
+	tools := []anthropicTool{}
+	warnings := []string{}
+	for _, tool := range ps.ListTools() {
+		if !anthropicToolNamePattern.MatchString(tool.Name) {
+			warnings = append(warnings, fmt.Sprintf("tool '%s' skipped: name is not a valid Anthropic tool name", tool.Name))
+			continue
+		}
+		schema, schemaWarnings := toFunctionParameters(tool)
+		warnings = append(warnings, schemaWarnings...)
+		tools = append(tools, anthropicTool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: schema,
+		})
+	}
+	for _, w := range warnings {
+		log.Printf("Anthropic export warning: %s", w)
+	}
+	return tools, warnings
+}
+
+// toAnthropicToolResult builds a tool_result block from a CallToolResult, keeping text
+// and image blocks and mapping the overall error flag and tool error message.
+func toAnthropicToolResult(toolUseID string, result *config.CallToolResult) anthropicToolResult {
+	blocks := []anthropicContentBlock{}
+	for _, block := range result.Content {
+		switch {
+		case block.Source != nil:
+			blocks = append(blocks, anthropicContentBlock{
+				Type: "image",
+				Source: &anthropicImageSource{
+					Type:      block.Source.Type,
+					MediaType: block.Source.MediaType,
+					Data:      block.Source.Data,
+				},
+			})
+		case block.Text != nil:
+			blocks = append(blocks, anthropicContentBlock{Type: "text", Text: *block.Text})
+		case block.Content != nil:
+			blocks = append(blocks, anthropicContentBlock{Type: "text", Text: *block.Content})
+		}
+	}
+	if result.ToolError != nil {
+		blocks = append(blocks, anthropicContentBlock{Type: "text", Text: result.ToolError.Message})
+	}
+	return anthropicToolResult{
+		Type:      "tool_result",
+		ToolUseID: toolUseID,
+		Content:   blocks,
+		IsError:   result.IsError || result.ToolError != nil,
+	}
+}
+
+// handleExportAnthropicTools handles GET /export/anthropic-tools
+func (h *HTTPProxy) handleExportAnthropicTools(c *gin.Context) {
+	tools, warnings := h.ps.ExportAnthropicTools()
+	respondListJSON(c, gin.H{"tools": tools, "warnings": warnings})
+}
+
+// handleAnthropicToolUse handles POST /bridge/anthropic/tool_use, executing a tool_use
+// block and returning the matching tool_result block.
+func (h *HTTPProxy) handleAnthropicToolUse(c *gin.Context) {
+	var toolUse anthropicToolUse
+	if err := h.ps.decodeJSON(c.Request.Body, &toolUse); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	if toolUse.Type != "" && toolUse.Type != "tool_use" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("expected a tool_use block, got '%s'", toolUse.Type)})
+		return
+	}
+	if toolUse.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tool name is required"})
+		return
+	}
+	if toolUse.Input == nil {
+		toolUse.Input = make(map[string]interface{})
+	}
+
+	callResult, err := h.ps.CallToolFrom(h.clientIdentity(c), requestHops(c), toolUse.Name, toolUse.Input, nil)
+	if err != nil {
+		respondToolCallError(c, toolUse.Name, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toAnthropicToolResult(toolUse.ID, callResult))
+}
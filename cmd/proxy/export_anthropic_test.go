@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readFixture loads a file from testdata.
+func readFixture(t *testing.T, name string) []byte {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	return data
+}
+
+// TestToAnthropicToolResult tests CallToolResult to tool_result conversion against fixtures.
+func TestToAnthropicToolResult(t *testing.T) {
+	var callResult config.CallToolResult
+	require.NoError(t, json.Unmarshal(readFixture(t, "anthropic_call_tool_result.json"), &callResult))
+
+	got, err := json.Marshal(toAnthropicToolResult("toolu_01A09q90qw90lq917835lq9", &callResult))
+	require.NoError(t, err)
+	assert.JSONEq(t, string(readFixture(t, "anthropic_tool_result.json")), string(got))
+
+	// Errors are flagged with is_error
+	message := "boom"
+	errResult := toAnthropicToolResult("id", &config.CallToolResult{IsError: true, Content: []config.ContentBlock{{Type: "text", Text: &message}}})
+	assert.True(t, errResult.IsError)
+	assert.Equal(t, "boom", errResult.Content[0].Text)
+}
+
+// TestHTTPExportAnthropicTools tests the GET /export/anthropic-tools endpoint.
+func TestHTTPExportAnthropicTools(t *testing.T) {
+	httpProxy, _, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+
+	req := httptest.NewRequest("GET", "/export/anthropic-tools", nil)
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Tools []anthropicTool `json:"tools"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Tools, 4)
+	for _, tool := range resp.Tools {
+		assert.NotEmpty(t, tool.Name)
+		assert.Equal(t, "object", tool.InputSchema["type"])
+	}
+}
+
+// TestHTTPAnthropicToolUse tests the POST /bridge/anthropic/tool_use endpoint with a fixture block.
+func TestHTTPAnthropicToolUse(t *testing.T) {
+	httpProxy, _, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+
+	req := httptest.NewRequest("POST", "/bridge/anthropic/tool_use", bytes.NewReader(readFixture(t, "anthropic_tool_use.json")))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var result anthropicToolResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, "tool_result", result.Type)
+	assert.Equal(t, "toolu_01A09q90qw90lq917835lq9", result.ToolUseID)
+	require.Len(t, result.Content, 1)
+	assert.JSONEq(t, `{"status":"tool /tool/tool1 called"}`, result.Content[0].Text)
+	assert.False(t, result.IsError)
+
+	// --- Wrong block type ---
+	req = httptest.NewRequest("POST", "/bridge/anthropic/tool_use", bytes.NewReader([]byte(`{"type":"text","name":"tool1"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// --- Unknown tool ---
+	req = httptest.NewRequest("POST", "/bridge/anthropic/tool_use", bytes.NewReader([]byte(`{"type":"tool_use","id":"x","name":"nonexistentTool","input":{}}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
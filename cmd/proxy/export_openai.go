@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAIFunctionNamePattern is the set of names OpenAI accepts for function tools.
+var openAIFunctionNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// unsupportedSchemaKeywords are top-level JSON Schema keywords function-calling APIs reject in tool schemas.
+var unsupportedSchemaKeywords = []string{"$schema", "$id"}
+
+// openAITool is a single entry of the OpenAI chat completions `tools` array.
+type openAITool struct {
+	Type     string             `json:"type"`
+	Function openAIFunctionSpec `json:"function"`
+}
+
+// openAIFunctionSpec describes a function the model may call.
+type openAIFunctionSpec struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// openAIFunctionCall is the name and JSON-encoded arguments chosen by the model.
+type openAIFunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// openAIToolCall accepts both the `tool_calls` entry form ({"id", "type", "function"})
+// and the legacy `function_call` form ({"name", "arguments"}).
+type openAIToolCall struct {
+	ID       string              `json:"id,omitempty"`
+	Type     string              `json:"type,omitempty"`
+	Function *openAIFunctionCall `json:"function,omitempty"`
+	openAIFunctionCall
+}
+
+// openAIToolMessage is the `tool` role message to append to the conversation.
+type openAIToolMessage struct {
+	Role       string `json:"role"`
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	Content    string `json:"content"`
+	IsError    bool   `json:"isError,omitempty"`
+}
+
+// toFunctionParameters converts a tool's input schema into an object schema accepted by
+// LLM function-calling APIs (OpenAI parameters, Anthropic input_schema).
+// Missing or non-object schemas degrade to an empty object schema, and unsupported
+// keywords are dropped. Every adjustment is reported as a warning.
+func toFunctionParameters(tool config.ToolInfo) (map[string]interface{}, []string) {
+	var warnings []string
+	if len(tool.InputSchema) == 0 {
+		warnings = append(warnings, fmt.Sprintf("tool '%s' has no input schema, using an empty object schema", tool.Name))
+		return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}, warnings
+	}
+
+	params := make(map[string]interface{}, len(tool.InputSchema))
+	for k, v := range tool.InputSchema {
+		params[k] = v
+	}
+	for _, keyword := range unsupportedSchemaKeywords {
+		if _, ok := params[keyword]; ok {
+			delete(params, keyword)
+			warnings = append(warnings, fmt.Sprintf("tool '%s': dropped unsupported schema keyword '%s'", tool.Name, keyword))
+		}
+	}
+	switch params["type"] {
+	case "object":
+	case nil:
+		params["type"] = "object"
+		warnings = append(warnings, fmt.Sprintf("tool '%s': schema has no type, assuming 'object'", tool.Name))
+	default:
+		warnings = append(warnings, fmt.Sprintf("tool '%s': schema type '%v' is not 'object', using an empty object schema", tool.Name, params["type"]))
+		return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}, warnings
+	}
+	if _, ok := params["properties"]; !ok {
+		params["properties"] = map[string]interface{}{}
+	}
+	return params, warnings
+}
+
+// ExportOpenAITools converts every advertised tool into the OpenAI function-calling format.
+// Tools whose names OpenAI cannot represent are skipped with a warning rather than renamed,
+// so that a returned function name always maps back to exactly one proxied tool.
+func (ps *ProxyServer) ExportOpenAITools() ([]openAITool, []string) {
+	tools := []openAITool{}
+	warnings := []string{}
+	for _, tool := range ps.ListTools() {
+		if !openAIFunctionNamePattern.MatchString(tool.Name) {
+			warnings = append(warnings, fmt.Sprintf("tool '%s' skipped: name is not a valid OpenAI function name", tool.Name))
+			continue
+		}
+		params, paramWarnings := toFunctionParameters(tool)
+		warnings = append(warnings, paramWarnings...)
+		tools = append(tools, openAITool{
+			Type: "function",
+			Function: openAIFunctionSpec{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  params,
+			},
+		})
+	}
+	for _, w := range warnings {
+		log.Printf("OpenAI export warning: %s", w)
+	}
+	return tools, warnings
+}
+
+// callResultText flattens a CallToolResult into plain text for clients that only accept strings.
+func callResultText(result *config.CallToolResult) string {
+	var parts []string
+	for _, block := range result.Content {
+		switch {
+		case block.Text != nil:
+			parts = append(parts, *block.Text)
+		case block.Content != nil:
+			parts = append(parts, *block.Content)
+		case block.Source != nil:
+			parts = append(parts, fmt.Sprintf("[%s image]", block.Source.MediaType))
+		}
+	}
+	if result.ToolError != nil {
+		parts = append(parts, result.ToolError.Message)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// handleExportOpenAITools handles GET /export/openai-tools
+func (h *HTTPProxy) handleExportOpenAITools(c *gin.Context) {
+	tools, warnings := h.ps.ExportOpenAITools()
+	respondListJSON(c, gin.H{"tools": tools, "warnings": warnings})
+}
+
+// handleExportOpenAICall handles POST /export/openai-call, translating an OpenAI
+// function call into a proxied tool call.
+func (h *HTTPProxy) handleExportOpenAICall(c *gin.Context) {
+	var call openAIToolCall
+	if err := c.ShouldBindJSON(&call); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	fn := call.openAIFunctionCall
+	if call.Function != nil {
+		fn = *call.Function
+	}
+	if fn.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "function name is required"})
+		return
+	}
+
+	arguments := make(map[string]interface{})
+	if strings.TrimSpace(fn.Arguments) != "" {
+		if err := h.ps.unmarshalJSON([]byte(fn.Arguments), &arguments); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "function arguments must be a JSON object: " + err.Error()})
+			return
+		}
+	}
+
+	callResult, err := h.ps.CallToolFrom(h.clientIdentity(c), requestHops(c), fn.Name, arguments, nil)
+	if err != nil {
+		respondToolCallError(c, fn.Name, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, openAIToolMessage{
+		Role:       "tool",
+		ToolCallID: call.ID,
+		Content:    callResultText(callResult),
+		IsError:    callResult.IsError,
+	})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestToFunctionParameters tests schema conversion edge cases.
+func TestToFunctionParameters(t *testing.T) {
+	params, warnings := toFunctionParameters(config.ToolInfo{Name: "t"})
+	assert.Equal(t, "object", params["type"])
+	assert.Len(t, warnings, 1)
+
+	params, warnings = toFunctionParameters(config.ToolInfo{Name: "t", InputSchema: map[string]interface{}{
+		"$schema":    "http://json-schema.org/draft-07/schema#",
+		"type":       "object",
+		"properties": map[string]interface{}{"q": map[string]interface{}{"type": "string"}},
+	}})
+	assert.NotContains(t, params, "$schema")
+	assert.Contains(t, params["properties"], "q")
+	assert.Len(t, warnings, 1)
+
+	params, warnings = toFunctionParameters(config.ToolInfo{Name: "t", InputSchema: map[string]interface{}{"type": "string"}})
+	assert.Equal(t, map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}, params)
+	assert.Len(t, warnings, 1)
+
+	_, warnings = toFunctionParameters(config.ToolInfo{Name: "t", InputSchema: map[string]interface{}{"type": "object"}})
+	assert.Empty(t, warnings)
+}
+
+// TestHTTPExportOpenAITools tests the GET /export/openai-tools endpoint.
+func TestHTTPExportOpenAITools(t *testing.T) {
+	httpProxy, _, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+
+	req := httptest.NewRequest("GET", "/export/openai-tools", nil)
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Tools    []openAITool `json:"tools"`
+		Warnings []string     `json:"warnings"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Tools, 4) // tool1, tool2, tool-error-500, tool3
+	for _, tool := range resp.Tools {
+		assert.Equal(t, "function", tool.Type)
+		assert.NotEmpty(t, tool.Function.Name)
+		assert.Equal(t, "object", tool.Function.Parameters["type"])
+	}
+}
+
+// TestHTTPExportOpenAICall tests the POST /export/openai-call endpoint.
+func TestHTTPExportOpenAICall(t *testing.T) {
+	httpProxy, _, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+
+	// --- tool_calls entry form ---
+	body := `{"id":"call_1","type":"function","function":{"name":"tool1","arguments":"{\"arg1\":\"value1\"}"}}`
+	req := httptest.NewRequest("POST", "/export/openai-call", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var msg openAIToolMessage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &msg))
+	assert.Equal(t, "tool", msg.Role)
+	assert.Equal(t, "call_1", msg.ToolCallID)
+	assert.JSONEq(t, `{"status":"tool /tool/tool1 called"}`, msg.Content)
+
+	// --- legacy function_call form with empty arguments ---
+	req = httptest.NewRequest("POST", "/export/openai-call", strings.NewReader(`{"name":"tool3","arguments":""}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// --- malformed arguments ---
+	req = httptest.NewRequest("POST", "/export/openai-call", strings.NewReader(`{"name":"tool1","arguments":"not json"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// --- unknown tool ---
+	req = httptest.NewRequest("POST", "/export/openai-call", strings.NewReader(`{"name":"nonexistentTool"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
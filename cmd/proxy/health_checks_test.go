@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHealthPathFlips tests that a server's health follows its health endpoint, and
+// that while it fails, calls go to another provider, its breaker opens and /healthz
+// names it.
+func TestHealthPathFlips(t *testing.T) {
+	replica, _ := testReplicaServer("first", "shared", 0)
+	defer replica.Close()
+	var failing atomic.Bool
+	first := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			if failing.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			return
+		}
+		replica.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer first.Close()
+	second, secondConf := testReplicaServer("second", "shared", 0)
+	defer second.Close()
+
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{
+		{
+			Name: "first", Address: first.URL, HealthPath: "/healthz", HealthIntervalSeconds: 1,
+			CircuitBreaker: &config.CircuitBreakerConfig{FailureThreshold: 1, ResetTimeout: "1m"},
+		},
+		secondConf,
+	}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	server := ps.findMCPServerByName("first")
+	assert.True(t, server.Healthy())
+	assert.Equal(t, "first", ps.findMCPServerByTool("shared").Config.Name)
+
+	failing.Store(true)
+	require.Eventually(t, func() bool { return !server.Healthy() }, 5*time.Second, 50*time.Millisecond)
+	assert.Equal(t, "second", ps.findMCPServerByTool("shared").Config.Name)
+	assert.True(t, ps.breakers["first"].isOpen())
+	assert.Equal(t, map[string]string{"first": "health check returned status 503"}, healthzResponse(ps)["unhealthyServers"])
+
+	failing.Store(false)
+	require.Eventually(t, server.Healthy, 5*time.Second, 50*time.Millisecond)
+	assert.Equal(t, "first", ps.findMCPServerByTool("shared").Config.Name)
+	assert.False(t, ps.breakers["first"].isOpen())
+	assert.NotContains(t, healthzResponse(ps), "unhealthyServers")
+	assert.Equal(t, "ok", ps.Status().Servers[0].Health)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+)
+
+// hedgeResult carries the outcome of one leg of a hedged tool call.
+type hedgeResult struct {
+	leg    string // "primary" or "hedge"
+	server *config.MCPServer
+	result *config.CallToolResult
+	err    error
+}
+
+// isToolIdempotent reports whether a tool is marked read-only or idempotent through
+// its MCP annotations, which makes it safe to send more than once.
+func isToolIdempotent(tool config.ToolInfo) bool {
+	for _, hint := range []string{"readOnlyHint", "idempotentHint"} {
+		if v, ok := tool.Annotations[hint].(bool); ok && v {
+			return true
+		}
+	}
+	return false
+}
+
+// findHedgeableReplicas returns every server that exposes the tool as read-only or
+// idempotent, in configuration order. These servers form the failover group for hedging.
+func (ps *ProxyServer) findHedgeableReplicas(toolName string) []*config.MCPServer {
+	var replicas []*config.MCPServer
+	for _, server := range ps.servers.toolServers(toolName) {
+		for _, tool := range server.GetTools() {
+			if tool.Name == toolName && isToolIdempotent(tool) {
+				replicas = append(replicas, server)
+				break
+			}
+		}
+	}
+	return replicas
+}
+
+// callToolHedged sends the call to the first replica and, if it has not answered
+// within delay, sends a second request to the next replica. The first successful
+// answer wins and the other request is cancelled; a losing leg that cannot be
+// cancelled, such as a stdio call, is drained in the background. It returns the server
+// that answered, or the primary if both fail, in which case the primary's error is
+// returned. Only calls whose hedge was sent are counted in
+// mcp_proxy_hedged_tool_calls_total.
+func (ps *ProxyServer) callToolHedged(ctx context.Context, toolName string, arguments map[string]interface{}, replicas []*config.MCPServer, delay time.Duration) (*config.CallToolResult, *config.MCPServer, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // Cancels the losing leg
+
+	results := make(chan hedgeResult, 2) // Buffered so the loser never blocks
+	launch := func(leg string, server *config.MCPServer) {
+		go func() {
+			res, err := ps.callToolOnServer(ctx, server, toolName, arguments)
+			results <- hedgeResult{leg: leg, server: server, result: res, err: err}
+		}()
+	}
+
+	launch("primary", replicas[0])
+	pending := 1
+	hedged := false
+	sendHedge := func(reason string) {
+		hedged = true
+		log.Printf("Hedging tool '%s' to server '%s' %s", toolName, replicas[1].Config.Name, reason)
+		launch("hedge", replicas[1])
+		pending++
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var primaryErr error
+	for pending > 0 || !hedged {
+		select {
+		case <-timer.C:
+			if !hedged {
+				sendHedge(fmt.Sprintf("after %v", delay))
+			}
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				if !hedged {
+					return r.result, r.server, nil // Answered before the hedge was due
+				}
+				if hedgedRequestsTotal != nil { // Check if initialized
+					hedgedRequestsTotal.WithLabelValues(toolName, r.leg).Inc()
+				}
+				log.Printf("Hedged tool '%s' answered by %s server '%s'", toolName, r.leg, r.server.Config.Name)
+				if pending > 0 {
+					cancel()
+					go drainHedge(toolName, results)
+				}
+				return r.result, r.server, nil
+			}
+			if r.leg == "primary" {
+				primaryErr = r.err
+			} else if primaryErr == nil {
+				primaryErr = r.err
+			}
+			// The primary failed before the hedge was sent: fire it immediately
+			if !hedged {
+				sendHedge("after the primary failed")
+			}
+		}
+	}
+	return nil, replicas[0], primaryErr
+}
+
+// drainHedge waits for the losing leg of a hedged call to return after its
+// cancellation and logs how it ended.
+func drainHedge(toolName string, results <-chan hedgeResult) {
+	r := <-results
+	if r.err != nil {
+		log.Printf("Losing %s leg of hedged tool '%s' on server '%s' ended: %v", r.leg, toolName, r.server.Config.Name, r.err)
+		return
+	}
+	log.Printf("Discarded the answer of the losing %s leg of hedged tool '%s' on server '%s'", r.leg, toolName, r.server.Config.Name)
+}
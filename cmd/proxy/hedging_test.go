@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testReplicaServer starts a backend exposing a single read-only tool that answers after latency.
+func testReplicaServer(name, toolName string, latency time.Duration) (*httptest.Server, config.MCPServerConfig) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tools", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"tools": []config.ToolInfo{{
+			Name:        toolName,
+			InputSchema: map[string]interface{}{"type": "object"},
+			Annotations: map[string]interface{}{"readOnlyHint": true},
+		}}})
+	})
+	mux.HandleFunc("/resources", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"resources":[]}`))
+	})
+	mux.HandleFunc("/tool/", func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body) // Drain the body so a client disconnect cancels r.Context()
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+		text := name
+		json.NewEncoder(w).Encode(config.CallToolResult{Content: []config.ContentBlock{{Type: "text", Text: &text}}})
+	})
+	server := httptest.NewServer(mux)
+	return server, config.MCPServerConfig{Name: name, Address: server.URL}
+}
+
+// TestCallToolHedged tests that a slow primary is hedged and the faster replica wins.
+func TestCallToolHedged(t *testing.T) {
+	slow, slowConf := testReplicaServer("slow", "lookup", 2*time.Second)
+	defer slow.Close()
+	fast, fastConf := testReplicaServer("fast", "lookup", 0)
+	defer fast.Close()
+
+	ps, err := NewProxyServer(&config.Config{
+		MCPServers:  []config.MCPServerConfig{slowConf, fastConf},
+		ToolHedging: map[string]config.HedgingConfig{"lookup": {Delay: "20ms"}},
+	})
+	require.NoError(t, err)
+	registerMetrics()
+	hedgeWins := testutil.ToFloat64(hedgedRequestsTotal.WithLabelValues("lookup", "hedge"))
+
+	start := time.Now()
+	result, err := ps.CallTool("lookup", map[string]interface{}{})
+	require.NoError(t, err)
+	require.Len(t, result.Content, 1)
+	assert.Equal(t, "fast", *result.Content[0].Text)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, hedgeWins+1, testutil.ToFloat64(hedgedRequestsTotal.WithLabelValues("lookup", "hedge")))
+}
+
+// TestCallToolHedgedValidatesWinner tests that with validate_results a hedged result is
+// checked against the outputSchema of the replica that answered, not the primary's.
+func TestCallToolHedgedValidatesWinner(t *testing.T) {
+	replica := func(field string, latency time.Duration) *httptest.Server {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/tools", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"tools":[{"name":"weather","inputSchema":{"type":"object"},
+				"annotations":{"readOnlyHint":true},
+				"outputSchema":{"type":"object","required":["` + field + `"]}}]}`))
+		})
+		mux.HandleFunc("/resources", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"resources":[]}`))
+		})
+		mux.HandleFunc("/tool/weather", func(w http.ResponseWriter, r *http.Request) {
+			io.ReadAll(r.Body)
+			select {
+			case <-time.After(latency):
+			case <-r.Context().Done():
+				return
+			}
+			w.Write([]byte(`{"content":[],"structuredContent":{"` + field + `":4}}`))
+		})
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+		return server
+	}
+	slow := replica("celsius", 2*time.Second)
+	fast := replica("fahrenheit", 0)
+
+	ps, err := NewProxyServer(&config.Config{
+		MCPServers:      []config.MCPServerConfig{{Name: "slow", Address: slow.URL}, {Name: "fast", Address: fast.URL}},
+		ToolHedging:     map[string]config.HedgingConfig{"weather": {Delay: "20ms"}},
+		ValidateResults: true,
+	})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+
+	result, err := ps.CallTool("weather", map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"fahrenheit": float64(4)}, result.StructuredContent)
+}
+
+// TestCallToolHedgeNotSent tests that a primary answering within the delay is neither
+// hedged nor counted as a hedged call.
+func TestCallToolHedgeNotSent(t *testing.T) {
+	primary, primaryConf := testReplicaServer("primary", "quick", 0)
+	defer primary.Close()
+	replica, replicaConf := testReplicaServer("replica", "quick", 0)
+	defer replica.Close()
+
+	ps, err := NewProxyServer(&config.Config{
+		MCPServers:  []config.MCPServerConfig{primaryConf, replicaConf},
+		ToolHedging: map[string]config.HedgingConfig{"quick": {Delay: "1s"}},
+	})
+	require.NoError(t, err)
+	registerMetrics()
+
+	result, err := ps.CallTool("quick", map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, "primary", *result.Content[0].Text)
+	assert.Zero(t, testutil.ToFloat64(hedgedRequestsTotal.WithLabelValues("quick", "primary")))
+}
+
+// TestCallToolNotHedgedWithoutConfig tests that tools without a hedging policy go to the primary only.
+func TestCallToolNotHedgedWithoutConfig(t *testing.T) {
+	primary, primaryConf := testReplicaServer("primary", "lookup", 50*time.Millisecond)
+	defer primary.Close()
+	other, otherConf := testReplicaServer("other", "lookup", 0)
+	defer other.Close()
+
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{primaryConf, otherConf}})
+	require.NoError(t, err)
+
+	result, err := ps.CallTool("lookup", map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, "primary", *result.Content[0].Text)
+}
+
+// TestIsToolHedgeable tests annotation-based detection of hedge-safe tools.
+func TestIsToolHedgeable(t *testing.T) {
+	assert.True(t, isToolIdempotent(config.ToolInfo{Annotations: map[string]interface{}{"readOnlyHint": true}}))
+	assert.True(t, isToolIdempotent(config.ToolInfo{Annotations: map[string]interface{}{"idempotentHint": true}}))
+	assert.False(t, isToolIdempotent(config.ToolInfo{Annotations: map[string]interface{}{"readOnlyHint": false}}))
+	assert.False(t, isToolIdempotent(config.ToolInfo{}))
+}
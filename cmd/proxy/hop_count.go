@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrLoopDetected is returned for requests whose hop count has reached max_hops.
+var ErrLoopDetected = errors.New("loop detected")
+
+// parseHopCount parses the value of the hop count header name; an empty value is zero hops.
+func parseHopCount(name, value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	hops, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || hops < 0 {
+		return 0, fmt.Errorf("invalid %s header '%s'", name, value)
+	}
+	return hops, nil
+}
+
+// requestHopCount returns the hop count of a client request, the higher of its
+// X-MCP-Hop-Count and X-MCP-Proxy-Hops headers.
+func requestHopCount(header http.Header) (int, error) {
+	hops := 0
+	for _, name := range []string{config.HopCountHeader, config.ProxyHopsHeader} {
+		n, err := parseHopCount(name, header.Get(name))
+		if err != nil {
+			return 0, err
+		}
+		hops = max(hops, n)
+	}
+	return hops, nil
+}
+
+// checkHops returns ErrLoopDetected when forwarding a request that has already passed
+// through hops proxies would exceed max_hops.
+func (ps *ProxyServer) checkHops(hops int) error {
+	if hops >= ps.maxHops {
+		return fmt.Errorf("%w: %s %d reached max_hops %d", ErrLoopDetected, config.HopCountHeader, hops, ps.maxHops)
+	}
+	return nil
+}
+
+// hopCountContextKey is the gin context key limitHops stores the request's hop count under.
+const hopCountContextKey = "hopCount"
+
+// limitHops rejects requests forwarded to backends whose hop count has reached
+// max_hops with 508 Loop Detected, and a malformed count with 400. Handlers read the
+// count with requestHops.
+func (h *HTTPProxy) limitHops(c *gin.Context) {
+	hops, err := requestHopCount(c.Request.Header)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.ps.checkHops(hops); err != nil {
+		c.AbortWithStatusJSON(http.StatusLoopDetected, gin.H{"error": err.Error()})
+		return
+	}
+	c.Set(hopCountContextKey, hops)
+	c.Next()
+}
+
+// requestHops returns the hop count of a request that passed limitHops.
+func requestHops(c *gin.Context) int {
+	return c.GetInt(hopCountContextKey)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testProxyChain starts length proxies with the given max_hops, each forwarding to the
+// next, in front of a backend with tool "tool1" and resource "res1". It returns the
+// first proxy; every proxy names its backend server "next".
+func testProxyChain(t *testing.T, length, maxHops int) *HTTPProxy {
+	backend, conf := testHttpServer("next", []string{"tool1"}, []string{"res1"}, nil, nil)
+	t.Cleanup(backend.Close)
+	var first *HTTPProxy
+	for range length {
+		ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{conf}, MaxHops: maxHops})
+		require.NoError(t, err)
+		t.Cleanup(ps.Shutdown)
+		first, err = NewHTTPProxy(ps, ":0")
+		require.NoError(t, err)
+
+		// The previous proxy's resource route takes the server name before the resource
+		server := httptest.NewServer(first.engine)
+		t.Cleanup(server.Close)
+		conf = config.MCPServerConfig{Name: "next", Address: server.URL, ResourcePathTemplate: "/resource/next/{name}"}
+	}
+	return first
+}
+
+// TestHopCountChain tests that a chain of proxies longer than max_hops is cut off with
+// 508, for tool calls and resource requests, while a shorter chain works.
+func TestHopCountChain(t *testing.T) {
+	call := func(h *HTTPProxy, method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.engine.ServeHTTP(w, req)
+		return w
+	}
+
+	// Three proxies forward with hop counts 1, 2 and 3
+	ok := testProxyChain(t, 3, 3)
+	w := call(ok, "POST", "/tool/tool1")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = call(ok, "GET", "/resource/next/res1/page")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// With max_hops 2, the third proxy receives hop count 2 and rejects the request
+	looping := testProxyChain(t, 3, 2)
+	w = call(looping, "POST", "/tool/tool1")
+	assert.Equal(t, http.StatusLoopDetected, w.Code, w.Body.String())
+	w = call(looping, "GET", "/resource/next/res1/page")
+	assert.Equal(t, http.StatusLoopDetected, w.Code, w.Body.String())
+}
+
+// TestHopCountHeader tests the header sent to backends and the handling of
+// client-supplied hop counts.
+func TestHopCountHeader(t *testing.T) {
+	var rec receivedRequest
+	backend, conf := testHeaderEchoServer(&rec)
+	defer backend.Close()
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{conf}, MaxHops: 5})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		hops       string
+		alias      string
+		wantStatus int
+		wantSent   string
+	}{
+		{"", "", http.StatusOK, "1"},
+		{"4", "", http.StatusOK, "5"},
+		{"", "3", http.StatusOK, "4"},
+		{"2", "3", http.StatusOK, "4"},
+		{"5", "", http.StatusLoopDetected, ""},
+		{"", "5", http.StatusLoopDetected, ""},
+		{"-1", "", http.StatusBadRequest, ""},
+		{"many", "", http.StatusBadRequest, ""},
+		{"1", "many", http.StatusBadRequest, ""},
+	} {
+		req := httptest.NewRequest("GET", "/resource/echo/res/page", nil)
+		if tt.hops != "" {
+			req.Header.Set(config.HopCountHeader, tt.hops)
+		}
+		if tt.alias != "" {
+			req.Header.Set(config.ProxyHopsHeader, tt.alias)
+		}
+		w := httptest.NewRecorder()
+		httpProxy.engine.ServeHTTP(w, req)
+		assert.Equal(t, tt.wantStatus, w.Code, "hop counts %q/%q: %s", tt.hops, tt.alias, w.Body.String())
+		if tt.wantSent != "" {
+			rec.mu.Lock()
+			assert.Equal(t, tt.wantSent, rec.header.Get(config.HopCountHeader), "hop counts %q/%q", tt.hops, tt.alias)
+			assert.Equal(t, tt.wantSent, rec.header.Get(config.ProxyHopsHeader), "hop counts %q/%q", tt.hops, tt.alias)
+			rec.mu.Unlock()
+		}
+	}
+}
+
+// TestHopCountJSONRPC tests that JSON-RPC backends receive the hop count headers, on
+// discovery and on tool calls.
+func TestHopCountJSONRPC(t *testing.T) {
+	var mu sync.Mutex
+	sent := map[string][]string{}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     int64  `json:"id"`
+			Method string `json:"method"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		mu.Lock()
+		sent[req.Method] = append(sent[req.Method], r.Header.Get(config.HopCountHeader)+"/"+r.Header.Get(config.ProxyHopsHeader))
+		mu.Unlock()
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "tools/list":
+			resp["result"] = map[string]interface{}{"tools": []interface{}{map[string]interface{}{"name": "rpc-tool", "inputSchema": map[string]interface{}{"type": "object"}}}}
+		case "tools/call":
+			text := "called"
+			resp["result"] = config.CallToolResult{Content: []config.ContentBlock{{Type: "text", Text: &text}}}
+		default:
+			resp["error"] = map[string]interface{}{"code": -32601, "message": "Method not found"}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer backend.Close()
+
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{
+		{Name: "rpc-server", Address: backend.URL, Discovery: config.DiscoveryJSONRPC},
+	}, MaxHops: 5})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/tool/rpc-tool", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(config.HopCountHeader, "2")
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"1/1"}, sent["initialize"])
+	assert.Equal(t, []string{"1/1"}, sent["tools/list"])
+	assert.Equal(t, []string{"3/3"}, sent["tools/call"])
+}
@@ -1,15 +1,21 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/subtle"
 	"errors" // Add errors package
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
-	// "strings" // Removed as it's no longer used
+	"strconv"
+	"strings"
 	"sync" // Import sync package
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -25,13 +31,51 @@ type HTTPProxy struct {
 	ps     *ProxyServer // Reference to the core ProxyServer logic
 	engine *gin.Engine
 	srv    *http.Server
+
+	listener  net.Listener  // Set by serve; handed over to the new binary on upgrade
+	served    chan struct{} // Closed when Serve returns
+	serveErr  error         // Error Serve returned other than http.ErrServerClosed; valid once served is closed
+	upgrading atomic.Bool
+	upgraded  chan struct{} // Closed once a new binary has taken over the listener
+
+	index *proxyIndex // Pre-rendered GET / response
+
+	adminSrv      *http.Server // Separate listener of the admin routes; nil when they are on the main one
+	adminListener net.Listener
+	adminReleased bool // The admin listener was closed for an upgrade
+
+	stopReason string // Why Run returned cleanly
 }
 
 // Package-level variables for Prometheus metrics to be initialized once.
 var (
-	httpMetricsOnce   sync.Once
-	httpRequestsTotal *prometheus.CounterVec
-	httpRequestDur    *prometheus.HistogramVec
+	httpMetricsOnce     sync.Once
+	httpRequestsTotal   *prometheus.CounterVec
+	httpRequestDur      *prometheus.HistogramVec
+	bufferedBodyBytes   prometheus.Gauge
+	hedgedRequestsTotal *prometheus.CounterVec
+	serverCallsTotal    *prometheus.CounterVec
+	serverDegraded      *prometheus.GaugeVec
+	sloViolationsTotal  *prometheus.CounterVec
+
+	mirrorCallsTotal      *prometheus.CounterVec
+	mirrorDurationSeconds *prometheus.HistogramVec
+
+	stdioQueueDepth   *prometheus.GaugeVec
+	stdioWaitDuration *prometheus.HistogramVec
+
+	resourceAccessesTotal   *prometheus.CounterVec
+	resourceAccessDurations *prometheus.HistogramVec
+
+	toolRateLimitedTotal   *prometheus.CounterVec
+	rateLimitStoreDuration *prometheus.HistogramVec
+
+	eventsPublishedTotal *prometheus.CounterVec
+	eventsDroppedTotal   *prometheus.CounterVec
+
+	commandToolCallsTotal *prometheus.CounterVec
+
+	resourceURIConflicts prometheus.Gauge
 )
 
 // NewHTTPProxy creates a new HTTPProxy instance.
@@ -41,35 +85,20 @@ func NewHTTPProxy(ps *ProxyServer, listenAddr string) (*HTTPProxy, error) {
 		return nil, fmt.Errorf("ProxyServer instance cannot be nil")
 	}
 
-	engine := gin.Default()
+	engine := newGinEngine(ps.ecsLog)
+	// Gin redirects near-miss paths by default; a client following a 301 for
+	// POST /tool/name/ would retry as a GET, so redirects are opt-in.
+	engine.RedirectTrailingSlash = ps.redirectTrailingSlash
+	engine.RedirectFixedPath = ps.redirectFixedPath
+	// Answer a wrong method with 405 and an Allow header instead of 404
+	engine.HandleMethodNotAllowed = true
+	// Gin trusts forwarding headers from any peer by default; only listed proxies may
+	// set the client IP, otherwise it is the remote address
+	if err := engine.SetTrustedProxies(ps.trustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted_proxies: %w", err)
+	}
 
-	// --- Prometheus Metrics Setup ---
-	// Use sync.Once to ensure metrics are registered only once globally.
-	httpMetricsOnce.Do(func() {
-		// Define temporary variables inside the closure first
-		reqCounter := prometheus.NewCounterVec(
-			prometheus.CounterOpts{
-				Name: "mcp_proxy_http_requests_total",
-				Help: "Total number of HTTP requests received by the proxy",
-			},
-			[]string{"method", "endpoint", "status"},
-		)
-		reqDuration := prometheus.NewHistogramVec(
-			prometheus.HistogramOpts{
-				Name:    "mcp_proxy_http_request_duration_seconds",
-				Help:    "Histogram of HTTP request durations",
-				Buckets: prometheus.DefBuckets,
-			},
-			[]string{"method", "endpoint"},
-		)
-		// Register metrics
-		prometheus.MustRegister(reqCounter, reqDuration)
-		// Assign to package-level variables AFTER registration
-		httpRequestsTotal = reqCounter
-		httpRequestDur = reqDuration
-		log.Println("Prometheus metrics registered for HTTP proxy.")
-	})
-	// --- End Prometheus Metrics Setup ---
+	registerMetrics()
 
 	// --- Middleware Setup ---
 	engine.Use(func(c *gin.Context) {
@@ -90,24 +119,64 @@ func NewHTTPProxy(ps *ProxyServer, listenAddr string) (*HTTPProxy, error) {
 			httpRequestDur.WithLabelValues(c.Request.Method, c.FullPath()).Observe(duration.Seconds())
 		}
 	})
+	if ps.maxHeaderCount > 0 {
+		engine.Use(limitHeaderCount(ps.maxHeaderCount))
+	}
 	// --- End Middleware Setup ---
 
 	// Create the HTTPProxy instance *before* setting up routes,
 	// so the handlers have access to the instance (h.ps).
 	h := &HTTPProxy{
-		ps:     ps,
-		engine: engine,
+		ps:       ps,
+		engine:   engine,
+		served:   make(chan struct{}),
+		upgraded: make(chan struct{}),
 	}
 
+	index, err := newProxyIndex(ps)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render index: %w", err)
+	}
+	h.index = index
+
 	// --- Route Setup ---
+	engine.GET("/", h.handleIndex)
 	engine.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	engine.GET("/healthz", h.handleHealthz)
+	engine.GET("/readyz", h.handleReadyz)
+	engine.GET("/servers", h.handleServers)
+	engine.GET("/status", h.handleStatus)
 	engine.GET("/tools", h.handleTools)
+	engine.GET("/tools/:toolName", h.handleToolDetail)
+	engine.GET("/tools/:toolName/schema", h.handleToolSchema)
 	engine.GET("/restricted-tools", h.handleRestrictedTools)
 	engine.GET("/resources", h.handleResources)
+	engine.GET("/resources/:resourceName", h.handleResourceDetail)
 	engine.GET("/restricted-resources", h.handleRestrictedResources)
+	engine.GET("/servers/:serverName", h.handleServerDetail)
 	// Change route for tool calls: POST /tool/:toolName
-	engine.POST("/tool/:toolName", h.handleToolCall)                                    // Renamed handler
-	engine.Any("/resource/:serverName/:resourceName/*proxyPath", h.handleResourceProxy) // Keep resource proxy as is for now
+	engine.POST("/tool/:toolName", h.limitHops, h.handleToolCall)
+	engine.Any("/resource/:serverName/:resourceName", h.limitHops, h.handleResourceProxy)
+	engine.Any("/resource/:serverName/:resourceName/*proxyPath", h.limitHops, h.handleResourceProxy) // Keep resource proxy as is for now
+	engine.GET("/tool-jobs/:id", h.handleToolJob)
+	engine.POST("/mcp", h.limitHops, h.handleMCP)
+	engine.GET("/mcp", h.handleMCPStream)
+	engine.DELETE("/mcp", h.handleMCPDelete)
+	if ps.admin.onMainListener() {
+		h.registerAdminRoutes(engine)
+	}
+	engine.GET("/clients/config", h.handleClientConfig)
+	engine.GET("/export/openai-tools", h.handleExportOpenAITools)
+	engine.POST("/export/openai-call", h.limitHops, h.handleExportOpenAICall)
+	engine.GET("/export/anthropic-tools", h.handleExportAnthropicTools)
+	engine.POST("/bridge/anthropic/tool_use", h.limitHops, h.handleAnthropicToolUse)
+	if ps.ui {
+		if err := h.registerUI(engine); err != nil {
+			return nil, fmt.Errorf("failed to set up web UI: %w", err)
+		}
+	}
+	engine.NoRoute(handleNoRoute)
+	engine.NoMethod(handleNoMethod)
 	// --- End Route Setup ---
 
 	// --- HTTP Server Setup ---
@@ -117,92 +186,524 @@ func NewHTTPProxy(ps *ProxyServer, listenAddr string) (*HTTPProxy, error) {
 		ReadTimeout:  15 * time.Second, // Increased slightly
 		WriteTimeout: 30 * time.Second, // Increased slightly
 		IdleTimeout:  60 * time.Second,
+
+		MaxHeaderBytes: ps.maxHeaderBytes, // Zero uses http.DefaultMaxHeaderBytes
 	}
 	h.srv = srv // Assign the configured server to the struct
+	if ps.admin != nil && !ps.admin.onMainListener() {
+		if h.adminSrv, err = h.newAdminHTTPServer(); err != nil {
+			return nil, err
+		}
+	}
 	// --- End HTTP Server Setup ---
 
 	return h, nil
 }
 
+// limitHeaderCount rejects requests carrying more than max header fields with 431.
+// Repeated fields count once per value.
+func limitHeaderCount(max int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		count := 0
+		for _, values := range c.Request.Header {
+			count += len(values)
+		}
+		if count > max {
+			c.AbortWithStatusJSON(http.StatusRequestHeaderFieldsTooLarge, gin.H{"error": fmt.Sprintf("too many request header fields: %d, limit %d", count, max)})
+			return
+		}
+		c.Next()
+	}
+}
+
+// handleNoRoute answers requests to unknown paths with a JSON 404 rather than Gin's
+// plain text one.
+func handleNoRoute(c *gin.Context) {
+	c.JSON(http.StatusNotFound, gin.H{"error": "not found", "path": c.Request.URL.Path})
+}
+
+// handleNoMethod answers requests with a method the path does not support with a JSON
+// 405. Gin has already set the Allow header.
+func handleNoMethod(c *gin.Context) {
+	c.JSON(http.StatusMethodNotAllowed, gin.H{"error": "method not allowed", "path": c.Request.URL.Path})
+}
+
+// registerMetrics registers the proxy's Prometheus metrics. It is shared by HTTP mode and
+// the command-mode admin listener.
+func registerMetrics() {
+	// Use sync.Once to ensure metrics are registered only once globally.
+	httpMetricsOnce.Do(func() {
+		// Define temporary variables inside the closure first
+		reqCounter := prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mcp_proxy_http_requests_total",
+				Help: "Total number of HTTP requests received by the proxy",
+			},
+			[]string{"method", "endpoint", "status"},
+		)
+		reqDuration := prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "mcp_proxy_http_request_duration_seconds",
+				Help:    "Histogram of HTTP request durations",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"method", "endpoint"},
+		)
+		bufferedBytes := prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "mcp_proxy_buffered_request_bytes",
+				Help: "Total size of request bodies currently buffered in memory",
+			},
+		)
+		hedgedCounter := prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mcp_proxy_hedged_tool_calls_total",
+				Help: "Total number of tool calls whose hedge was sent, by the leg that answered first",
+			},
+			[]string{"tool", "winner"},
+		)
+		serverCalls := prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mcp_proxy_server_tool_calls_total",
+				Help: "Total number of tool call attempts sent to each backend server by outcome",
+			},
+			[]string{"server", "outcome"},
+		)
+		degraded := prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "mcp_proxy_server_degraded",
+				Help: "1 when a backend server's error rate has exceeded its error budget",
+			},
+			[]string{"server"},
+		)
+		queuedRestarts := prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "mcp_proxy_queued_restarts",
+				Help: "Number of stdio server restarts waiting for a restart slot",
+			},
+			func() float64 { return float64(config.QueuedRestarts()) },
+		)
+		mirrorCalls := prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mcp_proxy_mirror_tool_calls_total",
+				Help: "Total number of tool calls mirrored to a shadow server by outcome (match, diff or dropped)",
+			},
+			[]string{"tool", "outcome"},
+		)
+		mirrorDuration := prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "mcp_proxy_mirror_tool_call_duration_seconds",
+				Help:    "Histogram of mirrored tool call durations on the primary and the shadow server",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"tool", "role"},
+		)
+		queueDepth := prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "mcp_proxy_stdio_queue_depth",
+				Help: "Number of requests waiting to be sent to a stdio server",
+			},
+			[]string{"server"},
+		)
+		queueWait := prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "mcp_proxy_stdio_wait_seconds",
+				Help:    "Histogram of the time stdio requests waited for the server's stdin/stdout before being sent",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"server"},
+		)
+		resourceAccesses := prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mcp_proxy_resource_accesses_total",
+				Help: "Total number of proxied resource accesses, by backend status",
+			},
+			[]string{"server", "resource", "status"},
+		)
+		resourceDuration := prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "mcp_proxy_resource_access_duration_seconds",
+				Help:    "Histogram of proxied resource access durations",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"server", "resource"},
+		)
+		rateLimited := prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mcp_proxy_tool_rate_limited_total",
+				Help: "Total number of tool calls rejected by tool_rate_limits",
+			},
+			[]string{"tool"},
+		)
+		rateLimitStore := prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "mcp_proxy_rate_limit_store_seconds",
+				Help:    "Histogram of the round trips to the shared rate limit store, by outcome (ok or error)",
+				Buckets: prometheus.ExponentialBuckets(0.0001, 2, 12),
+			},
+			[]string{"outcome"},
+		)
+		eventsPublished := prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mcp_proxy_events_published_total",
+				Help: "Total number of events published on the internal event bus, by type",
+			},
+			[]string{"type"},
+		)
+		eventsDropped := prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mcp_proxy_events_dropped_total",
+				Help: "Total number of events dropped for an internal subscriber whose buffer was full",
+			},
+			[]string{"subscriber"},
+		)
+		commandToolCalls := prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mcp_proxy_command_tool_calls_total",
+				Help: "Total number of tool calls in command mode by client (from initialize clientInfo), tool and outcome",
+			},
+			[]string{"client", "tool", "outcome"},
+		)
+		sloViolations := prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mcp_proxy_slo_violations_total",
+				Help: "Total number of tool call attempts and resource requests slower than the server's slo_ms",
+			},
+			[]string{"server"},
+		)
+		uriConflicts := prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "mcp_proxy_resource_uri_conflicts",
+				Help: "Number of resource URIs currently exposed by more than one server",
+			},
+		)
+		// Register metrics
+		prometheus.MustRegister(reqCounter, reqDuration, bufferedBytes, hedgedCounter, serverCalls, degraded, queuedRestarts, mirrorCalls, mirrorDuration, queueDepth, queueWait, resourceAccesses, resourceDuration, rateLimited, rateLimitStore, eventsPublished, eventsDropped, commandToolCalls, sloViolations, uriConflicts)
+		// Assign to package-level variables AFTER registration
+		httpRequestsTotal = reqCounter
+		httpRequestDur = reqDuration
+		bufferedBodyBytes = bufferedBytes
+		hedgedRequestsTotal = hedgedCounter
+		serverCallsTotal = serverCalls
+		serverDegraded = degraded
+		sloViolationsTotal = sloViolations
+		mirrorCallsTotal = mirrorCalls
+		mirrorDurationSeconds = mirrorDuration
+		stdioQueueDepth = queueDepth
+		stdioWaitDuration = queueWait
+		resourceAccessesTotal = resourceAccesses
+		resourceAccessDurations = resourceDuration
+		toolRateLimitedTotal = rateLimited
+		rateLimitStoreDuration = rateLimitStore
+		eventsPublishedTotal = eventsPublished
+		eventsDroppedTotal = eventsDropped
+		commandToolCallsTotal = commandToolCalls
+		resourceURIConflicts = uriConflicts
+		config.SetStdioQueueObserver(&config.StdioQueueObserver{
+			Depth: func(server string, depth int64) {
+				stdioQueueDepth.WithLabelValues(server).Set(float64(depth))
+			},
+			Wait: func(server string, wait time.Duration) {
+				stdioWaitDuration.WithLabelValues(server).Observe(wait.Seconds())
+			},
+		})
+		log.Println("Prometheus metrics registered for MCP proxy.")
+	})
+}
+
+// wantsPrettyJSON reports whether the client asked for indented JSON, either with
+// ?pretty=true or an Accept header carrying a pretty hint (e.g. "application/json; pretty").
+func wantsPrettyJSON(c *gin.Context) bool {
+	if pretty, err := strconv.ParseBool(c.Query("pretty")); err == nil && pretty {
+		return true
+	}
+	return strings.Contains(strings.ToLower(c.GetHeader("Accept")), "pretty")
+}
+
+// respondListJSON writes a listing response, indented when requested and compact otherwise.
+func respondListJSON(c *gin.Context, obj interface{}) {
+	if wantsPrettyJSON(c) {
+		c.IndentedJSON(http.StatusOK, obj)
+		return
+	}
+	c.JSON(http.StatusOK, obj)
+}
+
 // handleTools handles the /tools endpoint using the ProxyServer logic
 func (h *HTTPProxy) handleTools(c *gin.Context) {
-	allTools := h.ps.ListTools()
-	c.JSON(http.StatusOK, gin.H{"tools": allTools})
+	allTools := h.ps.ListToolsWithMetadata()
+	respondListJSON(c, gin.H{"tools": allTools})
+}
+
+// handleToolDetail handles the /tools/:toolName endpoint
+func (h *HTTPProxy) handleToolDetail(c *gin.Context) {
+	toolName := c.Param("toolName")
+	detail := h.ps.DescribeTool(toolName)
+	if detail == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("tool '%s' not found", toolName)})
+		return
+	}
+	c.JSON(http.StatusOK, detail)
 }
 
 // handleRestrictedTools handles the /restricted-tools endpoint
 func (h *HTTPProxy) handleRestrictedTools(c *gin.Context) {
 	allTools := h.ps.ListRestrictedTools()
-	c.JSON(http.StatusOK, gin.H{"tools": allTools})
+	respondListJSON(c, gin.H{"tools": allTools})
 }
 
 // handleResources handles the /resources endpoint
 func (h *HTTPProxy) handleResources(c *gin.Context) {
-	allResources := h.ps.ListResources()
-	c.JSON(http.StatusOK, gin.H{"resources": allResources})
+	allResources := h.ps.ListResourcesWithMetadata()
+	respondListJSON(c, gin.H{"resources": allResources})
+}
+
+// handleResourceDetail handles the /resources/:resourceName endpoint
+func (h *HTTPProxy) handleResourceDetail(c *gin.Context) {
+	resourceName := c.Param("resourceName")
+	detail := h.ps.DescribeResource(resourceName)
+	if detail == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("resource '%s' not found", resourceName)})
+		return
+	}
+	c.JSON(http.StatusOK, detail)
 }
 
 // handleRestrictedResources handles the /restricted-resources endpoint
 func (h *HTTPProxy) handleRestrictedResources(c *gin.Context) {
 	allResources := h.ps.ListRestrictedResources()
-	c.JSON(http.StatusOK, gin.H{"resources": allResources})
+	respondListJSON(c, gin.H{"resources": allResources})
+}
+
+// validAdminAuth reports whether an Authorization header carries the admin bearer token.
+func validAdminAuth(header, token string) bool {
+	return subtle.ConstantTimeCompare([]byte(header), []byte("Bearer "+token)) == 1
+}
+
+// handleAdminRefresh handles POST /admin/refresh, optionally scoped with ?server=name
+func (h *HTTPProxy) handleAdminRefresh(c *gin.Context) {
+	results, err := h.ps.RefreshServers(c.Query("server"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"servers": results})
+}
+
+// handleJournalReplay handles POST /admin/journal/replay, re-executing failed journal entries.
+// Non-idempotent tools are only replayed with ?force=true.
+func (h *HTTPProxy) handleJournalReplay(c *gin.Context) {
+	results, err := h.ps.ReplayJournal(c.Request.Context(), c.Query("force") == "true")
+	if err != nil {
+		if errors.Is(err, ErrJournalDisabled) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "results": results})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// handleHealthz handles the /healthz endpoint
+func (h *HTTPProxy) handleHealthz(c *gin.Context) {
+	c.JSON(http.StatusOK, healthzResponse(h.ps))
+}
+
+// healthzResponse is the /healthz body: the liveness status plus, when stdio servers
+// are configured, the number of requests queued for each one, the reason each
+// unhealthy server failed its health check, and the SLO compliance of servers with
+// slo_ms set.
+func healthzResponse(ps *ProxyServer) map[string]interface{} {
+	body := map[string]interface{}{"status": "ok"}
+	depths := make(map[string]int64)
+	unhealthy := make(map[string]string)
+	for _, server := range ps.mcpServers {
+		if server.Config.Command != "" {
+			depths[server.Config.Name] = server.StdioQueueDepth()
+		}
+		if err := server.HealthError(); err != nil {
+			unhealthy[server.Config.Name] = err.Error()
+		}
+	}
+	if len(depths) > 0 {
+		body["stdioQueueDepth"] = depths
+	}
+	if len(unhealthy) > 0 {
+		body["unhealthyServers"] = unhealthy
+	}
+	if compliance := ps.slo.compliance(); len(compliance) > 0 {
+		body["sloCompliance"] = compliance
+	}
+	return body
+}
+
+// handleReadyz handles the /readyz endpoint
+func (h *HTTPProxy) handleReadyz(c *gin.Context) {
+	status, body := readinessResponse(h.ps)
+	c.JSON(status, body)
+}
+
+// handleServers handles the /servers endpoint
+func (h *HTTPProxy) handleServers(c *gin.Context) {
+	respondListJSON(c, gin.H{"servers": h.ps.ListServers()})
+}
+
+// handleStatus handles the /status endpoint
+func (h *HTTPProxy) handleStatus(c *gin.Context) {
+	respondListJSON(c, h.ps.statusFor(c.Request))
+}
+
+// handleServerDetail handles the /servers/:serverName endpoint
+func (h *HTTPProxy) handleServerDetail(c *gin.Context) {
+	serverName := c.Param("serverName")
+	detail := h.ps.DescribeServer(serverName)
+	if detail == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("server '%s' not found", serverName)})
+		return
+	}
+	c.JSON(http.StatusOK, detail)
 }
 
 // handleToolCall handles POST requests to /tool/:toolName using the core ProxyServer.CallTool method.
 func (h *HTTPProxy) handleToolCall(c *gin.Context) {
 	toolName := c.Param("toolName")
 
-	// Bind JSON body to arguments map
-	var arguments map[string]interface{}
-	if err := c.ShouldBindJSON(&arguments); err != nil {
-		// Handle cases where body is empty or not valid JSON
-		// If the body is empty, arguments might be nil or an empty map, which could be valid.
-		// Let CallTool handle nil arguments if appropriate. If JSON is present but invalid:
-		if err.Error() == "EOF" { // Check for empty body explicitly
-			arguments = make(map[string]interface{}) // Treat empty body as empty args
-		} else {
-			log.Printf("Error binding JSON for tool '%s': %v", toolName, err)
+	// Buffer the body against the global budget before binding it
+	var bodySize int64
+	if c.Request.Body != nil {
+		bodyBytes, release, err := h.ps.bodyBudget.readAll(c.Request.Body)
+		if err != nil {
+			if t := asThrottle(err); t != nil {
+				respondThrottled(c, bufferLimitMessage, t)
+				return
+			}
+			log.Printf("Error reading body for tool '%s': %v", toolName, err)
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
 			return
 		}
+		defer release()
+		bodySize = int64(len(bodyBytes))
+		c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
 	}
 
-	// Call the centralized CallTool method
-	callResult, err := h.ps.CallTool(toolName, arguments)
+	// Bind the JSON, form or multipart body to the arguments map
+	arguments, err := h.bindToolArguments(c, bodySize)
 	if err != nil {
-		log.Printf("Error calling tool '%s' via ProxyServer: %v", toolName, err)
-
-		statusCode := http.StatusInternalServerError // Default to 500
-		errMsg := "An unexpected error occurred"     // Default generic message
-
-		// Use errors.Is for robust error checking
-		if errors.Is(err, ErrToolNotFound) {
-			statusCode = http.StatusNotFound
-			// Use the specific message from the wrapped error if desired, or a standard one
-			errMsg = fmt.Sprintf("Tool '%s' not found or not provided by any configured server", toolName)
-			// Alternatively, use err.Error() if the wrapped message is sufficient: errMsg = err.Error()
-		} else if errors.Is(err, ErrBackendCommunication) {
-			statusCode = http.StatusBadGateway
-			errMsg = fmt.Sprintf("Error communicating with backend server for tool '%s'", toolName)
-			// Log the underlying error for debugging, but don't expose details to the client
-			log.Printf("Backend communication error details for tool '%s': %v", toolName, err)
-		} else if errors.Is(err, ErrInternalProxy) {
-			statusCode = http.StatusInternalServerError
-			errMsg = fmt.Sprintf("Internal server error processing tool '%s'", toolName)
-			// Log the underlying error for debugging
-			log.Printf("Internal proxy error details for tool '%s': %v", toolName, err)
-		} else {
-			// For truly unexpected errors, log the full error but return the generic message
-			log.Printf("Unexpected error calling tool '%s': %v", toolName, err)
-		}
+		log.Printf("Error binding arguments for tool '%s': %v", toolName, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	// A _meta object in the body is request metadata, not a tool argument
+	arguments, meta := splitArgumentsMeta(arguments)
 
-		// Return consistent JSON error structure
-		c.JSON(statusCode, gin.H{"error": errMsg})
+	// Long-running tools, or clients sending "Prefer: respond-async", get a job to poll
+	if h.ps.isAsyncTool(toolName) || wantsAsync(c) {
+		h.respondToolJobAccepted(c, toolName, arguments, meta)
+		return
+	}
+
+	// Call the centralized CallTool method
+	callResult, err := h.ps.CallToolFrom(h.clientIdentity(c), requestHops(c), toolName, arguments, meta)
+	if err != nil {
+		respondToolCallError(c, toolName, err)
+		return
+	}
+	if _, pending := pendingMarkerOf(callResult); pending {
+		h.respondPendingToolJob(c, toolName, callResult)
 		return
 	}
 
 	// Success: Return the CallToolResult directly (it's already a struct)
-	c.JSON(http.StatusOK, callResult)
+	c.JSON(h.toolResultStatus(callResult), callResult)
+}
+
+// toolErrorStatus is the status of HTTP tool calls with an isError result when
+// map_tool_errors_to_status is enabled.
+const toolErrorStatus = http.StatusUnprocessableEntity
+
+// toolResultStatus returns the HTTP status of a tool call's result: 200, or
+// toolErrorStatus for a tool error when map_tool_errors_to_status is enabled.
+func (h *HTTPProxy) toolResultStatus(result *config.CallToolResult) int {
+	if h.ps.mapToolErrors && result != nil && result.IsError {
+		return toolErrorStatus
+	}
+	return http.StatusOK
+}
+
+// respondToolCallError maps an error from ProxyServer.CallTool to an HTTP status and
+// a JSON error body that does not leak backend details.
+func respondToolCallError(c *gin.Context, toolName string, err error) {
+	log.Printf("Error calling tool '%s' via ProxyServer: %v", toolName, err)
+	statusCode, errMsg := toolCallErrorStatus(toolName, err)
+	if t := asThrottle(err); t != nil {
+		respondThrottled(c, errMsg, t)
+		return
+	}
+	// Pass a redirect that was not followed on unchanged
+	if r := asRedirect(err); r != nil {
+		c.Header("Location", r.location)
+		statusCode = r.status
+	}
+	// Return consistent JSON error structure
+	c.JSON(statusCode, gin.H{"error": errMsg})
+}
+
+// toolCallErrorStatus maps a CallTool error to an HTTP status code and a client-safe message.
+func toolCallErrorStatus(toolName string, err error) (int, string) {
+	statusCode := http.StatusInternalServerError // Default to 500
+	errMsg := "An unexpected error occurred"     // Default generic message
+
+	// Use errors.Is for robust error checking
+	if errors.Is(err, ErrRateLimited) {
+		statusCode = http.StatusTooManyRequests
+		errMsg = fmt.Sprintf("Rate limit exceeded for tool '%s'", toolName)
+	} else if errors.Is(err, ErrRateLimitStore) {
+		statusCode = http.StatusServiceUnavailable
+		errMsg = fmt.Sprintf("Rate limit for tool '%s' cannot be checked", toolName)
+	} else if errors.Is(err, ErrArgumentLimit) {
+		statusCode = http.StatusBadRequest
+		errMsg = err.Error()
+	} else if errors.Is(err, ErrLoopDetected) {
+		statusCode = http.StatusLoopDetected
+		errMsg = fmt.Sprintf("Loop detected calling tool '%s': too many proxy hops", toolName)
+	} else if errors.Is(err, ErrCircuitOpen) {
+		statusCode = http.StatusServiceUnavailable
+		errMsg = fmt.Sprintf("Backend server for tool '%s' is temporarily unavailable", toolName)
+	} else if errors.Is(err, ErrBackendRestarting) {
+		statusCode = http.StatusServiceUnavailable
+		errMsg = fmt.Sprintf("Backend server for tool '%s' is restarting", toolName)
+	} else if errors.Is(err, ErrToolRestricted) {
+		statusCode = http.StatusForbidden
+		errMsg = fmt.Sprintf("Tool '%s' not allowed by the proxy configuration", toolName)
+	} else if errors.Is(err, ErrToolNotFound) {
+		statusCode = http.StatusNotFound
+		// Use the specific message from the wrapped error if desired, or a standard one
+		errMsg = fmt.Sprintf("Tool '%s' not found or not provided by any configured server", toolName)
+		// Alternatively, use err.Error() if the wrapped message is sufficient: errMsg = err.Error()
+	} else if errors.Is(err, ErrInvalidResult) {
+		statusCode = http.StatusBadGateway
+		errMsg = fmt.Sprintf("Backend server returned an invalid result for tool '%s'", toolName)
+	} else if errors.Is(err, ErrBackendRedirect) {
+		statusCode = http.StatusBadGateway
+		errMsg = fmt.Sprintf("Backend server for tool '%s' answered with a redirect", toolName)
+		log.Printf("Backend redirect for tool '%s': %v", toolName, err)
+	} else if errors.Is(err, ErrBackendCommunication) {
+		statusCode = http.StatusBadGateway
+		errMsg = fmt.Sprintf("Error communicating with backend server for tool '%s'", toolName)
+		// Log the underlying error for debugging, but don't expose details to the client
+		log.Printf("Backend communication error details for tool '%s': %v", toolName, err)
+	} else if errors.Is(err, ErrInternalProxy) {
+		statusCode = http.StatusInternalServerError
+		errMsg = fmt.Sprintf("Internal server error processing tool '%s'", toolName)
+		// Log the underlying error for debugging
+		log.Printf("Internal proxy error details for tool '%s': %v", toolName, err)
+	} else {
+		// For truly unexpected errors, log the full error but return the generic message
+		log.Printf("Unexpected error calling tool '%s': %v", toolName, err)
+	}
+	return statusCode, errMsg
 }
 
 // handleResourceProxy proxies requests to the specified resource on a specific server
@@ -219,13 +720,14 @@ func (h *HTTPProxy) handleResourceProxy(c *gin.Context) {
 
 	// Double-check if the server allows this resource
 	if !server.IsResourceAllowed(resourceName) {
-		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("resource '%s' not allowed on server '%s'", resourceName, serverName)})
+		h.respondResourceRestricted(c, serverName, resourceName)
 		return
 	}
 
-	// Construct the target path for the resource request
-	// Example: /resource/actual-resource-name/proxied/path
-	targetPath := fmt.Sprintf("/resource/%s%s", resourceName, proxyPath) // Ensure proxyPath starts with /
+	// Construct the target path for the resource request from the server's
+	// resource_path_template. Example: /resource/actual-resource-name/proxied/path
+	// proxyPath is empty on the route without a sub-path
+	targetPath := server.ResourcePath(resourceName) + proxyPath // proxyPath starts with /
 
 	h.proxyRequest(c, server, targetPath)
 }
@@ -239,9 +741,22 @@ func (h *HTTPProxy) proxyRequest(c *gin.Context, server *config.MCPServer, targe
 		Query:  c.Request.URL.RawQuery,
 		Header: c.Request.Header,
 		Body:   c.Request.Body, // Pass the original body reader
+
+		Resource: c.Param("resourceName"),
+		Client:   h.clientIdentity(c),
+		BaseURL:  h.publicBaseURL(),
+		Hops:     requestHops(c),
 	}
 
 	respOutput, err := h.ps.ProxyRequest(input)
+	if t := asThrottle(err); t != nil {
+		respondThrottled(c, bufferLimitMessage, t)
+		return
+	}
+	if errors.Is(err, ErrInvalidHeaders) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		// Log the detailed error from ProxyRequest
 		log.Printf("Error proxying request to server %s: %v", server.Config.Name, err)
@@ -250,6 +765,13 @@ func (h *HTTPProxy) proxyRequest(c *gin.Context, server *config.MCPServer, targe
 		return
 	}
 
+	// A proxy further down the chain hit max_hops; pass the loop on rather than
+	// reporting a backend failure
+	if respOutput.Status == http.StatusLoopDetected {
+		c.JSON(http.StatusLoopDetected, gin.H{"error": fmt.Sprintf("loop detected: backend server '%s' reached its hop limit", server.Config.Name)})
+		return
+	}
+
 	// Check if the backend itself returned an error status (5xx)
 	if respOutput.Status >= 500 {
 		log.Printf("Backend server %s returned error status %d for %s %s", server.Config.Name, respOutput.Status, input.Method, input.Path)
@@ -274,27 +796,63 @@ func (h *HTTPProxy) proxyRequest(c *gin.Context, server *config.MCPServer, targe
 	}
 }
 
-// Run starts the HTTP server and waits for a shutdown signal.
-func (h *HTTPProxy) Run() error {
-	log.Printf("Starting MCP Proxy HTTP Server on %s", h.srv.Addr)
-	done := make(chan struct{})
+// serve binds the listener, or takes over the one inherited from a previous process
+// during an upgrade, and serves requests in the background.
+func (h *HTTPProxy) serve() error {
+	listener, err := upgradeListener(h.srv.Addr)
+	if err != nil {
+		return fmt.Errorf("%w: failed to listen on %s: %w", ErrListen, h.srv.Addr, err)
+	}
+	h.listener = listener
+	if err := h.serveAdmin(); err != nil {
+		listener.Close()
+		return err
+	}
+	log.Printf("Starting MCP Proxy HTTP Server on %s", listener.Addr())
 	go func() {
-		defer close(done)
-		if err := h.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("HTTP server ListenAndServe error: %s\n", err)
+		defer close(h.served)
+		if err := h.srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			h.serveErr = fmt.Errorf("%w: HTTP server Serve error: %w", ErrListen, err)
 		}
 	}()
+	notifyUpgradeReady()
+	return nil
+}
+
+// Run starts the HTTP server and waits for a shutdown signal, a completed upgrade, or a
+// listener failure, which is returned wrapping ErrListen after the MCP servers are stopped.
+func (h *HTTPProxy) Run() error {
+	if err := h.serve(); err != nil {
+		return err
+	}
 
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
-	<-quit
-
-	log.Println("\nShutting down MCP Proxy HTTP Server...")
+	defer signal.Stop(quit)
+	select {
+	case sig := <-quit:
+		h.stopReason = "signal: " + sig.String()
+		log.Println("\nShutting down MCP Proxy HTTP Server...")
+	case <-h.upgraded:
+		h.stopReason = "upgraded"
+		log.Println("Listener handed over to the new binary, draining in-flight requests...")
+	case <-h.served:
+		// Shutdown was called directly, or the listener failed
+		h.shutdownAdmin(context.Background())
+		h.ps.Shutdown()
+		if h.serveErr != nil {
+			log.Printf("HTTP server stopped unexpectedly: %v", h.serveErr)
+			return h.serveErr
+		}
+		h.stopReason = "shutdown"
+		return nil
+	}
 
 	// Shutdown Gin server
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Increased timeout
 	defer cancel()
+	h.shutdownAdmin(ctx)
 	if err := h.srv.Shutdown(ctx); err != nil {
 		log.Printf("HTTP Server forced to shutdown: %v", err)
 		// Even if HTTP server shutdown fails, try to shutdown MCP servers
@@ -306,14 +864,20 @@ func (h *HTTPProxy) Run() error {
 	h.ps.Shutdown() // Call shutdown on the core ProxyServer
 
 	log.Println("MCP Proxy HTTP Server has been shut down gracefully")
-	<-done // Wait for ListenAndServe goroutine to finish
+	<-h.served // Wait for the Serve goroutine to finish
 	return nil
 }
 
+// StopReason reports why Run returned cleanly: the signal received, or "upgraded".
+func (h *HTTPProxy) StopReason() string {
+	return h.stopReason
+}
+
 // Shutdown gracefully shuts down the HTTP server.
 func (h *HTTPProxy) Shutdown(ctx context.Context) error {
 	log.Println("Initiating HTTPProxy Shutdown...")
-	// Shutdown the HTTP server first
+	// Shutdown the HTTP servers first
+	h.shutdownAdmin(ctx)
 	err := h.srv.Shutdown(ctx)
 	// Then shutdown the underlying ProxyServer (MCP connections)
 	h.ps.Shutdown() // Ensure MCP servers are also shut down
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// version is the proxy version reported by GET /. Release builds set it with
+// -ldflags "-X main.version=v1.2.3"; otherwise the module version from the build info
+// is used.
+var version string
+
+// docsURL is where GET / points people for documentation.
+const docsURL = "https://github.com/timthesinner/smart-mcp-proxy/tree/main/docs"
+
+// proxyVersion returns the version reported by GET /.
+func proxyVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// indexEndpoint describes one route in the GET / index.
+type indexEndpoint struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Description string `json:"description"`
+	Admin       bool   `json:"admin,omitempty"` // Requires the admin token; listed only when one is configured
+	UI          bool   `json:"-"`               // Listed only when the web UI is served
+}
+
+// indexEndpoints are the routes listed by GET /, in the order shown.
+var indexEndpoints = []indexEndpoint{
+	{Method: "GET", Path: "/tools", Description: "Tools exposed by all servers"},
+	{Method: "GET", Path: "/tools/:toolName", Description: "One tool and its owning server"},
+	{Method: "POST", Path: "/tool/:toolName", Description: "Call a tool with JSON arguments"},
+	{Method: "GET", Path: "/tool-jobs/:id", Description: "Status and result of a background tool call"},
+	{Method: "GET", Path: "/resources", Description: "Resources exposed by all servers"},
+	{Method: "GET", Path: "/resources/:resourceName", Description: "One resource and its owning server"},
+	{Method: "ANY", Path: "/resource/:serverName/:resourceName/*proxyPath", Description: "Proxy a request to a resource"},
+	{Method: "POST", Path: "/mcp", Description: "Streamable-HTTP MCP endpoint (JSON-RPC)"},
+	{Method: "GET", Path: "/servers", Description: "Configured servers and their state"},
+	{Method: "GET", Path: "/status", Description: "Proxy and per-server status"},
+	{Method: "GET", Path: "/healthz", Description: "Liveness check"},
+	{Method: "GET", Path: "/readyz", Description: "Readiness check"},
+	{Method: "GET", Path: "/metrics", Description: "Prometheus metrics"},
+	{Method: "GET", Path: "/clients/config", Description: "Configuration snippets for MCP clients"},
+	{Method: "GET", Path: "/export/openai-tools", Description: "Tools as OpenAI function definitions"},
+	{Method: "GET", Path: "/export/anthropic-tools", Description: "Tools as Anthropic tool definitions"},
+	{Method: "GET", Path: "/ui/", Description: "Web UI for browsing and calling tools", UI: true},
+	{Method: "POST", Path: "/admin/refresh", Description: "Rediscover tools and resources", Admin: true},
+	{Method: "POST", Path: "/admin/journal/replay", Description: "Replay failed journaled tool calls", Admin: true},
+	{Method: "POST", Path: "/admin/upgrade", Description: "Hand the listener to a new binary", Admin: true},
+	{Method: "GET", Path: "/admin/analytics/resources", Description: "Resource access counts and recent accesses", Admin: true},
+}
+
+// proxyIndex is the GET / response, rendered once since nothing in it changes while
+// the proxy runs.
+type proxyIndex struct {
+	json []byte
+	html []byte
+	etag string
+}
+
+// indexTemplate renders the index for browsers.
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Name}}</title></head>
+<body>
+<h1>{{.Name}} {{.Version}}</h1>
+<p>Running in {{.Mode}} mode. See the <a href="{{.Links.Docs}}">documentation</a>, <a href="{{.Links.Tools}}">tools</a> and <a href="{{.Links.Status}}">status</a>.</p>
+<table>
+<tr><th>Method</th><th>Path</th><th>Description</th></tr>
+{{range .Endpoints}}<tr><td>{{.Method}}</td><td><code>{{.Path}}</code></td><td>{{.Description}}{{if .Admin}} (admin token required){{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// basePath returns the path of public_base_url without a trailing slash, or "".
+func (ps *ProxyServer) basePath() string {
+	if u, err := url.Parse(ps.publicBaseURL); err == nil {
+		return strings.TrimSuffix(u.Path, "/")
+	}
+	return ""
+}
+
+// newProxyIndex renders the index for ps. Paths are prefixed with the path of
+// public_base_url, so links work behind a reverse proxy mounting the proxy under a
+// sub-path; admin endpoints are listed only when admin_token is set.
+func newProxyIndex(ps *ProxyServer) (*proxyIndex, error) {
+	basePath := ps.basePath()
+
+	type links struct {
+		Tools  string `json:"tools"`
+		Status string `json:"status"`
+		Docs   string `json:"docs"`
+	}
+	index := struct {
+		Name      string          `json:"name"`
+		Version   string          `json:"version"`
+		Mode      string          `json:"mode"`
+		Links     links           `json:"links"`
+		Endpoints []indexEndpoint `json:"endpoints"`
+	}{
+		Name:    "smart-mcp-proxy",
+		Version: proxyVersion(),
+		Mode:    "http",
+		Links:   links{Tools: basePath + "/tools", Status: basePath + "/status", Docs: docsURL},
+	}
+	for _, e := range indexEndpoints {
+		if e.Admin && !ps.admin.onMainListener() || e.UI && !(ps.ui && uiAvailable) {
+			continue
+		}
+		e.Path = basePath + e.Path
+		index.Endpoints = append(index.Endpoints, e)
+	}
+
+	jsonBody, err := json.Marshal(index)
+	if err != nil {
+		return nil, err
+	}
+	var htmlBody bytes.Buffer
+	if err := indexTemplate.Execute(&htmlBody, index); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(jsonBody)
+	return &proxyIndex{json: jsonBody, html: htmlBody.Bytes(), etag: `"` + hex.EncodeToString(sum[:8]) + `"`}, nil
+}
+
+// handleIndex handles GET /, answering browsers with HTML and other clients with JSON.
+// It serves the pre-rendered index with caching headers and no per-request work.
+func (h *HTTPProxy) handleIndex(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.Header("Vary", "Accept")
+	html := c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML
+	etag := h.index.etag
+	if html {
+		etag = strings.TrimSuffix(etag, `"`) + `-html"`
+	}
+	c.Header("ETag", etag)
+	if match := c.GetHeader("If-None-Match"); match != "" && strings.Contains(match, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	if html {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", h.index.html)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", h.index.json)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type indexResponse struct {
+	Name      string            `json:"name"`
+	Version   string            `json:"version"`
+	Mode      string            `json:"mode"`
+	Links     map[string]string `json:"links"`
+	Endpoints []indexEndpoint   `json:"endpoints"`
+}
+
+// TestHTTPIndex tests the JSON and HTML variants of GET / and its caching headers.
+func TestHTTPIndex(t *testing.T) {
+	httpProxy, _, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+	assert.Contains(t, w.Header().Get("Cache-Control"), "max-age=")
+	var index indexResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &index))
+	assert.Equal(t, "smart-mcp-proxy", index.Name)
+	assert.NotEmpty(t, index.Version)
+	assert.Equal(t, "http", index.Mode)
+	assert.Equal(t, "/tools", index.Links["tools"])
+	assert.Equal(t, "/status", index.Links["status"])
+	assert.Equal(t, docsURL, index.Links["docs"])
+	for _, e := range index.Endpoints {
+		assert.False(t, e.Admin, "admin endpoint %s listed without admin_token", e.Path)
+	}
+
+	// Every listed endpoint is a registered route; admin and UI routes only exist when enabled
+	routes := make(map[string]bool)
+	for _, r := range httpProxy.engine.Routes() {
+		routes[r.Path] = true
+	}
+	for _, e := range indexEndpoints {
+		assert.Equal(t, !e.Admin && !e.UI, routes[e.Path], "index lists unknown route %s", e.Path)
+	}
+
+	// A matching ETag gets a 304
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	// Browsers get HTML, with a different ETag
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), `<a href="/status">status</a>`)
+}
+
+// TestHTTPIndexBasePathAndAdmin tests that index paths carry the public_base_url path
+// and that admin endpoints are listed once an admin token is configured.
+func TestHTTPIndexBasePathAndAdmin(t *testing.T) {
+	backend, conf := testHttpServer("server1", []string{"tool1"}, nil, nil, nil)
+	defer backend.Close()
+	ps, err := NewProxyServer(&config.Config{
+		MCPServers:    []config.MCPServerConfig{conf},
+		PublicBaseURL: "https://example.com/mcp/",
+		AdminToken:    "secret",
+	})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var index indexResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &index))
+	assert.Equal(t, "/mcp/tools", index.Links["tools"])
+	assert.Contains(t, index.Endpoints, indexEndpoint{Method: "POST", Path: "/mcp/admin/refresh", Description: "Rediscover tools and resources", Admin: true})
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+)
+
+// ErrJournalDisabled is returned when a journal operation is requested but no journal is configured.
+var ErrJournalDisabled = errors.New("journal is not enabled")
+
+// ErrJournalInUse is returned when the journal's lock is held by another process: a
+// running proxy for `journal replay`, or `journal replay` for a proxy starting.
+var ErrJournalInUse = errors.New("journal is in use by another process")
+
+// Journal entry states. An entry starts pending and is marked completed or failed once
+// the backend call returns.
+const (
+	journalPending   = "pending"
+	journalCompleted = "completed"
+	journalFailed    = "failed"
+)
+
+// Replay outcomes that are not journal states.
+const journalSkipped = "skipped"
+
+// journalRecord is one line of the journal. The first record of an entry carries the tool
+// and arguments; later records for the same ID only update its state.
+type journalRecord struct {
+	ID        string                 `json:"id"`
+	Time      time.Time              `json:"time"`
+	State     string                 `json:"state"`
+	Tool      string                 `json:"tool,omitempty"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+// journalEntry is the folded state of all records sharing an ID.
+type journalEntry struct {
+	ID        string
+	Tool      string
+	Arguments map[string]interface{}
+	State     string
+	Error     string
+}
+
+// ReplayResult reports what happened to one failed journal entry during replay.
+type ReplayResult struct {
+	ID     string `json:"id"`
+	Tool   string `json:"tool"`
+	Status string `json:"status"` // completed, failed or skipped
+	Error  string `json:"error,omitempty"`
+}
+
+// journal is an append-only, size-rotated log of tool calls. Each record is written as a
+// single JSON line and synced before the call is dispatched, so a crash can at worst leave
+// a truncated final line, which is ignored on read.
+//
+// Processes writing the journal hold a shared lock on path.lock, so the old and new
+// binary of an upgrade can both have it open; `journal replay` takes it exclusively.
+type journal struct {
+	path     string
+	maxBytes int64
+	maxFiles int
+	lock     *os.File // path.lock, locked until Close
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+
+	replayMu sync.Mutex // Serializes replays so an entry is not re-executed twice
+	seq      atomic.Uint64
+}
+
+// openJournal opens (or creates) the active journal file described by cfg.
+func openJournal(cfg config.JournalConfig) (*journal, error) {
+	j := &journal{
+		path:     cfg.Path,
+		maxBytes: cfg.MaxBytesOrDefault(),
+		maxFiles: cfg.MaxFilesOrDefault(),
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	lock, err := os.OpenFile(j.path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal lock: %w", err)
+	}
+	if err := lockFile(lock, false); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	j.lock = lock
+	if err := j.openActive(); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+// lockExclusive makes the journal's lock exclusive, failing with ErrJournalInUse
+// while another process has the journal open.
+func (j *journal) lockExclusive() error {
+	return lockFile(j.lock, true)
+}
+
+// openActive opens the active file for appending. If the previous process died mid-write,
+// the file does not end in a newline; one is added so the next record starts on its own line.
+func (j *journal) openActive() error {
+	f, err := os.OpenFile(j.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat journal: %w", err)
+	}
+	size := info.Size()
+	if size > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, size-1); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to read journal: %w", err)
+		}
+		if last[0] != '\n' {
+			if _, err := f.Write([]byte("\n")); err != nil {
+				f.Close()
+				return fmt.Errorf("failed to repair journal: %w", err)
+			}
+			size++
+		}
+	}
+	j.file = f
+	j.size = size
+	return nil
+}
+
+// rotatedPath returns the name of the n-th rotated file; 0 is the active file.
+func (j *journal) rotatedPath(n int) string {
+	if n == 0 {
+		return j.path
+	}
+	return j.path + "." + strconv.Itoa(n)
+}
+
+// rotate shifts Path.n to Path.n+1, drops the oldest file and starts a new active file.
+// Callers must hold j.mu.
+func (j *journal) rotate() error {
+	if err := j.file.Close(); err != nil {
+		return err
+	}
+	oldest := j.maxFiles - 1
+	if err := os.Remove(j.rotatedPath(oldest)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for n := oldest - 1; n >= 0; n-- {
+		if err := os.Rename(j.rotatedPath(n), j.rotatedPath(n+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return j.openActive()
+}
+
+// append writes a record as a single line and syncs it to disk.
+func (j *journal) append(rec journalRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.size > 0 && j.size+int64(len(line)) > j.maxBytes {
+		if err := j.rotate(); err != nil {
+			return fmt.Errorf("failed to rotate journal: %w", err)
+		}
+	}
+	n, err := j.file.Write(line)
+	j.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+// begin records a tool call before dispatch and returns its entry ID.
+func (j *journal) begin(toolName string, arguments map[string]interface{}) (string, error) {
+	now := time.Now()
+	id := fmt.Sprintf("%d-%d", now.UnixNano(), j.seq.Add(1))
+	return id, j.append(journalRecord{ID: id, Time: now, State: journalPending, Tool: toolName, Arguments: arguments})
+}
+
+// finish marks an entry completed, or failed when err is non-nil.
+func (j *journal) finish(id string, err error) error {
+	rec := journalRecord{ID: id, Time: time.Now(), State: journalCompleted}
+	if err != nil {
+		rec.State = journalFailed
+		rec.Error = err.Error()
+	}
+	return j.append(rec)
+}
+
+// entries reads every journal file, oldest first, and folds records into entries in the
+// order they were first recorded. Unparseable lines (partial writes) and state updates
+// whose initial record was rotated away are skipped.
+func (j *journal) entries() ([]*journalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	byID := make(map[string]*journalEntry)
+	var ordered []*journalEntry
+	for n := j.maxFiles - 1; n >= 0; n-- {
+		f, err := os.Open(j.rotatedPath(n))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = readJournalRecords(f, func(rec journalRecord) {
+			entry, ok := byID[rec.ID]
+			if !ok {
+				if rec.Tool == "" {
+					return
+				}
+				entry = &journalEntry{ID: rec.ID, Tool: rec.Tool, Arguments: rec.Arguments}
+				byID[rec.ID] = entry
+				ordered = append(ordered, entry)
+			}
+			entry.State = rec.State
+			entry.Error = rec.Error
+		})
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// readJournalRecords decodes one record per line from r.
+func readJournalRecords(r io.Reader, fn func(journalRecord)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var rec journalRecord
+		if err := json.Unmarshal(line, &rec); err != nil || rec.ID == "" {
+			log.Printf("Skipping unreadable journal record: %q", line)
+			continue
+		}
+		fn(rec)
+	}
+	return scanner.Err()
+}
+
+// Close closes the active journal file and releases the lock.
+func (j *journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	defer j.lock.Close()
+	return j.file.Close()
+}
+
+// ReplayJournal re-executes failed journal entries against the current backends.
+// Tools not annotated as read-only or idempotent are skipped unless force is set.
+// Each replayed entry is marked completed or failed in the journal under its original ID.
+func (ps *ProxyServer) ReplayJournal(ctx context.Context, force bool) ([]ReplayResult, error) {
+	if ps.journal == nil {
+		return nil, ErrJournalDisabled
+	}
+	ps.journal.replayMu.Lock()
+	defer ps.journal.replayMu.Unlock()
+
+	entries, err := ps.journal.entries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	idempotent := make(map[string]bool)
+	for _, tool := range ps.ListTools() {
+		idempotent[tool.Name] = idempotent[tool.Name] || isToolIdempotent(tool)
+	}
+
+	results := []ReplayResult{}
+	for _, entry := range entries {
+		if entry.State != journalFailed {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		result := ReplayResult{ID: entry.ID, Tool: entry.Tool}
+		if !force && !idempotent[entry.Tool] {
+			result.Status = journalSkipped
+			result.Error = "tool is not marked read-only or idempotent"
+			results = append(results, result)
+			continue
+		}
+
+		arguments := entry.Arguments
+		if arguments == nil {
+			arguments = make(map[string]interface{})
+		}
+		_, callErr := ps.callTool(ctx, entry.Tool, arguments)
+		if err := ps.journal.finish(entry.ID, callErr); err != nil {
+			log.Printf("Failed to record replay of journal entry %s: %v", entry.ID, err)
+		}
+		result.Status = journalCompleted
+		if callErr != nil {
+			result.Status = journalFailed
+			result.Error = callErr.Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// lockFile does nothing where flock does not exist; the journal is not locked.
+func lockFile(f *os.File, exclusive bool) error {
+	return nil
+}
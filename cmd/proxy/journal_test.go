@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestJournalRotationAndPartialWrite tests size rotation and recovery from a truncated final line.
+func TestJournalRotationAndPartialWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal", "calls.jsonl")
+	j, err := openJournal(config.JournalConfig{Path: path, MaxBytes: 300, MaxFiles: 3})
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		id, err := j.begin("tool1", map[string]interface{}{"i": i})
+		require.NoError(t, err)
+		require.NoError(t, j.finish(id, nil))
+	}
+	assert.FileExists(t, path+".1")
+	assert.FileExists(t, path+".2")
+	assert.NoFileExists(t, path+".3")
+
+	// Simulate a crash in the middle of writing a record
+	require.NoError(t, j.Close())
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"id":"partial","state":"pend`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	j, err = openJournal(config.JournalConfig{Path: path, MaxBytes: 300, MaxFiles: 3})
+	require.NoError(t, err)
+	defer j.Close()
+	id, err := j.begin("tool2", nil)
+	require.NoError(t, err)
+	require.NoError(t, j.finish(id, assert.AnError))
+
+	entries, err := j.entries()
+	require.NoError(t, err)
+	require.NotEmpty(t, entries)
+	last := entries[len(entries)-1]
+	assert.Equal(t, "tool2", last.Tool)
+	assert.Equal(t, journalFailed, last.State)
+	assert.Equal(t, assert.AnError.Error(), last.Error)
+	for _, entry := range entries {
+		assert.NotEqual(t, "partial", entry.ID)
+	}
+}
+
+// TestReplayJournal tests that failed calls are journaled and replayed once the backend recovers.
+func TestReplayJournal(t *testing.T) {
+	backend, _, calls := testFlakyServer(1)
+	defer backend.Close()
+
+	ps, err := NewProxyServer(&config.Config{
+		MCPServers: []config.MCPServerConfig{{Name: "flaky-server", Address: backend.URL}},
+		Journal:    &config.JournalConfig{Path: filepath.Join(t.TempDir(), "journal.jsonl")},
+	})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+
+	_, err = ps.CallTool("flaky", map[string]interface{}{"q": "x"})
+	require.Error(t, err)
+
+	// "flaky" has no idempotency annotations, so it is skipped without force
+	results, err := ps.ReplayJournal(context.Background(), false)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, journalSkipped, results[0].Status)
+	assert.Equal(t, int32(1), calls.Load())
+
+	results, err = ps.ReplayJournal(context.Background(), true)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, journalCompleted, results[0].Status)
+	assert.Equal(t, "flaky", results[0].Tool)
+	assert.Equal(t, int32(2), calls.Load())
+
+	// The replayed entry is now completed and is not replayed again
+	results, err = ps.ReplayJournal(context.Background(), true)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	// Without a journal, replay reports that it is disabled
+	_, err = newResilientProxy(t, backend.URL, nil, nil).ReplayJournal(context.Background(), true)
+	assert.ErrorIs(t, err, ErrJournalDisabled)
+}
+
+// TestHTTPJournalReplay tests the POST /admin/journal/replay endpoint.
+func TestHTTPJournalReplay(t *testing.T) {
+	backend, _, _ := testFlakyServer(1)
+	defer backend.Close()
+
+	ps, err := NewProxyServer(&config.Config{
+		MCPServers: []config.MCPServerConfig{{Name: "flaky-server", Address: backend.URL}},
+		AdminToken: "secret",
+		Journal:    &config.JournalConfig{Path: filepath.Join(t.TempDir(), "journal.jsonl")},
+	})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/tool/flaky", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.NotEqual(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest("POST", "/admin/journal/replay?force=true", nil)
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req = httptest.NewRequest("POST", "/admin/journal/replay?force=true", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Results []ReplayResult `json:"results"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Results, 1)
+	assert.Equal(t, journalCompleted, resp.Results[0].Status)
+}
@@ -0,0 +1,34 @@
+//go:build unix
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestJournalLock tests that writers share the journal, as the old and new binary of an
+// upgrade do, while replay needs it alone and then keeps writers out.
+func TestJournalLock(t *testing.T) {
+	cfg := config.JournalConfig{Path: filepath.Join(t.TempDir(), "calls.jsonl")}
+	running, err := openJournal(cfg)
+	require.NoError(t, err)
+	upgraded, err := openJournal(cfg)
+	require.NoError(t, err)
+
+	replay, err := openJournal(cfg)
+	require.NoError(t, err)
+	defer replay.Close()
+	assert.ErrorIs(t, replay.lockExclusive(), ErrJournalInUse)
+
+	require.NoError(t, running.Close())
+	require.NoError(t, upgraded.Close())
+	require.NoError(t, replay.lockExclusive())
+	_, err = openJournal(cfg)
+	assert.ErrorIs(t, err, ErrJournalInUse)
+}
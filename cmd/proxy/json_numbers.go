@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// errTrailingJSON is returned for data after the top-level JSON value, as
+// json.Unmarshal rejects it.
+var errTrailingJSON = errors.New("invalid character after top-level value")
+
+// decodeJSON decodes a single JSON value from r into v. In the exact json_numbers mode,
+// numbers in untyped values (IDs, tool arguments) decode as json.Number, so they are
+// re-encoded exactly as the client wrote them: large integers keep every digit and are
+// never turned into floats or scientific notation.
+func (ps *ProxyServer) decodeJSON(r io.Reader, v interface{}) error {
+	dec := json.NewDecoder(r)
+	if ps.exactNumbers {
+		dec.UseNumber()
+	}
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errTrailingJSON
+	}
+	return nil
+}
+
+// unmarshalJSON is decodeJSON for a buffered value, in place of json.Unmarshal.
+func (ps *ProxyServer) unmarshalJSON(data []byte, v interface{}) error {
+	return ps.decodeJSON(bytes.NewReader(data), v)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rawBodyBackend serves a single tool "big" and records the raw body of each call.
+func rawBodyBackend(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tools":
+			w.Write([]byte(`{"tools":[{"name":"big","inputSchema":{"type":"object"}}]}`))
+		case "/resources":
+			w.Write([]byte(`{"resources":[]}`))
+		case "/tool/big":
+			body, _ := io.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+			w.Write([]byte(`{"content":[]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &bodies
+}
+
+// TestJSONNumbersForwardedUnchanged tests that integers too large for float64 reach the
+// backend with every digit, over HTTP and in command mode, and that the float mode
+// keeps decoding them as float64.
+func TestJSONNumbersForwardedUnchanged(t *testing.T) {
+	const arguments = `{"id":9007199254740993,"big":100000000000000000000000,"ratio":0.5}`
+
+	backend, bodies := rawBodyBackend(t)
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{{Name: "numbers", Address: backend.URL}}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/tool/big", strings.NewReader(arguments))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	cmdProxy, err := NewCommandProxy(ps)
+	require.NoError(t, err)
+	respBytes, err := cmdProxy.handleCommandRequest([]byte(`{"jsonrpc":"2.0","id":12345678901234567890,"method":"tools/call","params":{"name":"big","arguments":` + arguments + `}}`))
+	require.NoError(t, err)
+	assert.Contains(t, string(respBytes), `"id":12345678901234567890`)
+
+	require.Len(t, *bodies, 2)
+	for _, body := range *bodies {
+		assert.JSONEq(t, arguments, body)
+		assert.Contains(t, body, "9007199254740993")
+		assert.Contains(t, body, "100000000000000000000000")
+	}
+
+	floatBackend, floatBodies := rawBodyBackend(t)
+	floatPS, err := NewProxyServer(&config.Config{
+		MCPServers:  []config.MCPServerConfig{{Name: "numbers", Address: floatBackend.URL}},
+		JSONNumbers: config.JSONNumbersFloat,
+	})
+	require.NoError(t, err)
+	defer floatPS.Shutdown()
+	floatCmd, err := NewCommandProxy(floatPS)
+	require.NoError(t, err)
+	_, err = floatCmd.handleCommandRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"big","arguments":` + arguments + `}}`))
+	require.NoError(t, err)
+	require.Len(t, *floatBodies, 1)
+	assert.Contains(t, (*floatBodies)[0], "1e+23")
+}
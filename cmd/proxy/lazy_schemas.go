@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ToolSchema is the response of GET /tools/:toolName/schema.
+type ToolSchema struct {
+	Name         string                 `json:"name"`
+	ServerName   string                 `json:"serverName"`
+	InputSchema  map[string]interface{} `json:"inputSchema"`
+	OutputSchema map[string]interface{} `json:"outputSchema,omitempty"`
+}
+
+// ToolSchema returns the schemas of an allowed tool. For a server with lazy_schemas
+// they are fetched from the backend unless recently fetched already.
+func (ps *ProxyServer) ToolSchema(ctx context.Context, toolName string) (*ToolSchema, error) {
+	server := ps.findMCPServerByTool(toolName)
+	if server == nil {
+		return nil, fmt.Errorf("%w: %s", ErrToolNotFound, toolName)
+	}
+	tool, err := server.ToolSchema(ctx, toolName)
+	if errors.Is(err, config.ErrToolNotProvided) {
+		return nil, fmt.Errorf("%w: %v", ErrToolNotFound, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBackendCommunication, err)
+	}
+	return &ToolSchema{
+		Name:         tool.Name,
+		ServerName:   server.Config.Name,
+		InputSchema:  tool.InputSchema,
+		OutputSchema: tool.OutputSchema,
+	}, nil
+}
+
+// handleToolSchema handles the /tools/:toolName/schema endpoint
+func (h *HTTPProxy) handleToolSchema(c *gin.Context) {
+	toolName := c.Param("toolName")
+	schema, err := h.ps.ToolSchema(c.Request.Context(), toolName)
+	if errors.Is(err, ErrToolNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("tool '%s' not found", toolName)})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, schema)
+}
+
+// MarshalJSON leaves inputSchema out of the entry of a tool whose schemas lazy_schemas
+// dropped, rather than listing it as null, and sets lazySchema to tell clients to
+// fetch it from /tools/:toolName/schema.
+func (t ListedTool) MarshalJSON() ([]byte, error) {
+	type listedTool ListedTool
+	if !t.LazySchema {
+		return json.Marshal(listedTool(t))
+	}
+	return json.Marshal(struct {
+		listedTool
+		InputSchema *struct{} `json:"inputSchema,omitempty"`
+		LazySchema  bool      `json:"lazySchema"`
+	}{listedTool: listedTool(t), LazySchema: true})
+}
+
+// MarshalJSON leaves inputSchema out of a tool whose schemas lazy_schemas dropped,
+// like ListedTool.
+func (t ToolDetail) MarshalJSON() ([]byte, error) {
+	type toolDetail ToolDetail
+	if !t.LazySchema {
+		return json.Marshal(toolDetail(t))
+	}
+	return json.Marshal(struct {
+		toolDetail
+		InputSchema *struct{} `json:"inputSchema,omitempty"`
+		LazySchema  bool      `json:"lazySchema"`
+	}{toolDetail: toolDetail(t), LazySchema: true})
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syntheticToolsBackend serves count tools named tool0, tool1, ... each with an input
+// schema of a dozen properties, and counts the requests for its tool listing.
+func syntheticToolsBackend(tb testing.TB, count int) (*httptest.Server, *atomic.Int32) {
+	tb.Helper()
+	tools := make([]map[string]interface{}, count)
+	for i := range tools {
+		properties := make(map[string]interface{})
+		for p := 0; p < 12; p++ {
+			properties[fmt.Sprintf("param%d", p)] = map[string]interface{}{
+				"type":        "string",
+				"description": fmt.Sprintf("Parameter %d of tool %d, described at some length as real tools do", p, i),
+			}
+		}
+		tools[i] = map[string]interface{}{
+			"name":        fmt.Sprintf("tool%d", i),
+			"description": fmt.Sprintf("Synthetic tool %d", i),
+			"inputSchema": map[string]interface{}{"type": "object", "properties": properties, "required": []string{"param0"}},
+			"annotations": map[string]interface{}{"readOnlyHint": true},
+		}
+	}
+	listing, err := json.Marshal(map[string]interface{}{"tools": tools})
+	require.NoError(tb, err)
+
+	var listings atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tools":
+			listings.Add(1)
+			w.Write(listing)
+		case "/resources":
+			w.Write([]byte(`{"resources":[]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	tb.Cleanup(server.Close)
+	return server, &listings
+}
+
+// TestLazySchemas tests that lazy_schemas leaves input schemas out of the HTTP tool
+// listing, serves them from /tools/:toolName/schema with a single backend query per
+// tool, and advertises a placeholder schema to MCP clients.
+func TestLazySchemas(t *testing.T) {
+	backend, listings := syntheticToolsBackend(t, 3)
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{{Name: "big", Address: backend.URL, LazySchemas: true}}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+	require.Equal(t, int32(1), listings.Load())
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	w := get("/tools")
+	require.Equal(t, http.StatusOK, w.Code)
+	var listing struct {
+		Tools []map[string]interface{} `json:"tools"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listing))
+	require.Len(t, listing.Tools, 3)
+	for _, tool := range listing.Tools {
+		assert.NotContains(t, tool, "inputSchema")
+		assert.Equal(t, true, tool["lazySchema"])
+		assert.Equal(t, map[string]interface{}{"readOnlyHint": true}, tool["annotations"])
+	}
+
+	for i := 0; i < 2; i++ {
+		w = get("/tools/tool1/schema")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var schema ToolSchema
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &schema))
+		assert.Equal(t, "tool1", schema.Name)
+		assert.Equal(t, "big", schema.ServerName)
+		assert.Len(t, schema.InputSchema["properties"], 12)
+	}
+	assert.Equal(t, int32(2), listings.Load(), "the second schema request should be answered from the cache")
+	assert.Equal(t, http.StatusNotFound, get("/tools/missing/schema").Code)
+
+	for _, tool := range ps.ListTools() {
+		assert.Equal(t, map[string]interface{}{"type": "object"}, tool.InputSchema)
+	}
+}
+
+// TestLazySchemasConcurrentMisses tests that concurrent schema misses on a server with
+// lazy_schemas share a single backend query.
+func TestLazySchemasConcurrentMisses(t *testing.T) {
+	backend, listings := syntheticToolsBackend(t, 3)
+	var slow atomic.Bool
+	delayed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slow.Load() {
+			time.Sleep(200 * time.Millisecond)
+		}
+		backend.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer delayed.Close()
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{{Name: "big", Address: delayed.URL, LazySchemas: true}}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	require.Equal(t, int32(1), listings.Load())
+	slow.Store(true)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 9; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			schema, err := ps.ToolSchema(t.Context(), name)
+			if assert.NoError(t, err) {
+				assert.Equal(t, name, schema.Name)
+				assert.Len(t, schema.InputSchema["properties"], 12)
+			}
+		}(fmt.Sprintf("tool%d", i%3))
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), listings.Load(), "concurrent misses should share one backend query")
+}
+
+// TestToolSchemaEager tests that the schema endpoint answers from the tool cache of a
+// server without lazy_schemas.
+func TestToolSchemaEager(t *testing.T) {
+	backend, listings := syntheticToolsBackend(t, 1)
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{{Name: "small", Address: backend.URL}}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+
+	schema, err := ps.ToolSchema(t.Context(), "tool0")
+	require.NoError(t, err)
+	assert.Len(t, schema.InputSchema["properties"], 12)
+	assert.Equal(t, int32(1), listings.Load())
+}
+
+// BenchmarkToolCacheMemory reports the heap retained by the tool cache of a synthetic
+// 1000-tool backend, with and without lazy_schemas.
+func BenchmarkToolCacheMemory(b *testing.B) {
+	backend, _ := syntheticToolsBackend(b, 1000)
+	for _, lazy := range []bool{false, true} {
+		b.Run(fmt.Sprintf("lazy_schemas=%t", lazy), func(b *testing.B) {
+			var retained int64
+			for i := 0; i < b.N; i++ {
+				before := heapInUse()
+				ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{{Name: "big", Address: backend.URL, LazySchemas: lazy}}})
+				require.NoError(b, err)
+				retained += int64(heapInUse()) - int64(before)
+				runtime.KeepAlive(ps)
+				ps.Shutdown()
+			}
+			b.ReportMetric(float64(retained)/float64(b.N), "retained-B/op")
+		})
+	}
+}
+
+// heapInUse returns the live heap after a garbage collection.
+func heapInUse() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"sort"
+
+	"smart-mcp-proxy/internal/config"
+)
+
+// buildListLimits maps the name of every server with a list_limit to that limit.
+func buildListLimits(cfg *config.Config) map[string]int {
+	limits := make(map[string]int)
+	for _, server := range cfg.MCPServers {
+		if limit := cfg.ListLimitFor(server); limit > 0 {
+			limits[server.Name] = limit
+		}
+	}
+	return limits
+}
+
+// listedTools returns the tools of server to list: all of them, or up to its
+// list_limit, preferring prioritized tools and otherwise keeping the server's order.
+// Tools left out can still be called.
+func (ps *ProxyServer) listedTools(server *config.MCPServer) []config.ToolInfo {
+	tools := server.GetTools()
+	limit := ps.listLimits[server.Config.Name]
+	if limit == 0 || len(tools) <= limit {
+		return tools
+	}
+	tools = append([]config.ToolInfo(nil), tools...)
+	sort.SliceStable(tools, func(i, j int) bool {
+		ri, iPrioritized := ps.toolPriority[tools[i].Name]
+		rj, jPrioritized := ps.toolPriority[tools[j].Name]
+		if iPrioritized && jPrioritized {
+			return ri < rj
+		}
+		return iPrioritized && !jPrioritized
+	})
+	return tools[:limit]
+}
+
+// listedResources returns the first resources of server up to its list_limit, after
+// dropping the ones it does not own.
+func (ps *ProxyServer) listedResources(owners map[string]string, server *config.MCPServer) []config.ResourceInfo {
+	limit := ps.listLimits[server.Config.Name]
+	var resources []config.ResourceInfo
+	for _, resource := range server.GetResources() {
+		if limit > 0 && len(resources) == limit {
+			break
+		}
+		if ownsResource(owners, server, resource) {
+			resources = append(resources, resource)
+		}
+	}
+	return resources
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestListLimit tests that a server with more tools and resources than its list_limit
+// advertises a capped subset, prioritized tools first, while the rest stay callable.
+func TestListLimit(t *testing.T) {
+	large := proxytest.NewBackend(
+		[]proxytest.Tool{{Name: "a1"}, {Name: "a2"}, {Name: "a3"}, {Name: "a4"}, {Name: "a5"}},
+		[]proxytest.Resource{{Name: "r1", URI: "file:///r1"}, {Name: "r2", URI: "file:///r2"}, {Name: "r3", URI: "file:///r3"}},
+	)
+	defer large.Close()
+	small := proxytest.NewBackend([]proxytest.Tool{{Name: "b1"}, {Name: "b2"}}, []proxytest.Resource{{Name: "s1", URI: "file:///s1"}})
+	defer small.Close()
+
+	ps, err := NewProxyServer(&config.Config{
+		MCPServers: []config.MCPServerConfig{
+			{Name: "large", Address: large.URL, ToolPriority: []string{"a4"}},
+			{Name: "small", Address: small.URL, ListLimit: 5},
+		},
+		ListLimit: 2,
+	})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	get := func(path string, body interface{}) {
+		w := httptest.NewRecorder()
+		httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), body))
+	}
+	var tools struct {
+		Tools []ListedTool `json:"tools"`
+	}
+	get("/tools", &tools)
+	var toolNames []string
+	for _, tool := range tools.Tools {
+		toolNames = append(toolNames, tool.Name)
+	}
+	assert.Equal(t, []string{"a4", "a1", "b1", "b2"}, toolNames)
+
+	var resources struct {
+		Resources []ListedResource `json:"resources"`
+	}
+	get("/resources", &resources)
+	var resourceNames []string
+	for _, resource := range resources.Resources {
+		resourceNames = append(resourceNames, resource.Name)
+	}
+	assert.Equal(t, []string{"r1", "r2", "s1"}, resourceNames)
+
+	cmdProxy, err := NewCommandProxy(ps)
+	require.NoError(t, err)
+	respBytes, err := cmdProxy.handleCommandRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list","params":{"serverName":"large"}}`))
+	require.NoError(t, err)
+	var resp testToolsAndResourceResponse
+	require.NoError(t, json.Unmarshal(respBytes, &resp))
+	assert.Len(t, resp.Result.Tools, 2)
+
+	req := httptest.NewRequest("POST", "/tool/a5", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "unlisted tools can still be called: %s", w.Body.String())
+}
@@ -1,27 +1,55 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
+	"fmt"
+	"io"
 	"log"
 	"os"
 
 	"smart-mcp-proxy/internal/config"
 )
 
+// defaultListenAddr is the address the HTTP mode listener binds to.
+const defaultListenAddr = ":8080"
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "call":
+			os.Exit(runCallCommand(os.Args[2:], os.Stdout))
+		case "journal":
+			os.Exit(runJournalCommand(os.Args[2:]))
+		case "top":
+			os.Exit(runTopCommand(os.Args[2:], os.Stdout))
+		case "generate-client-config":
+			os.Exit(runGenerateClientConfigCommand(os.Args[2:], os.Stdout))
+		}
+	}
+
+	// Keep recent log lines for the /status endpoint
+	log.SetOutput(io.MultiWriter(os.Stderr, recentLogs))
+
+	// Dump goroutine stacks to stderr and a heap profile to a file on SIGUSR1
+	watchProfileSignal()
+
 	// Define command-line flags
 	configPathFlag := flag.String("config", "", "Path to MCP proxy config file")
 	modeFlag := flag.String("mode", "", "Run mode: 'http' or 'command' (default 'http')")
+	printConfigFlag := flag.Bool("print-config", false, "Print the loaded configuration, with server templates expanded, and exit")
+	adminListenFlag := flag.String("admin-listen", "", "Command mode only: host:port for an HTTP listener serving /metrics, /healthz, /servers and /status")
+	commandListenFlag := flag.String("command-listen", "", "Command mode only: host:port or unix:path to serve command mode sessions on instead of stdin and stdout")
+	commandListenAnyHostFlag := flag.Bool("command-listen-any-host", false, "Allow -command-listen on a non-loopback host; command mode sessions are not authenticated")
 	flag.Parse()
 
-	// Determine config path from flag or environment variable
+	// Determine config path from flag or environment variable.
+	// An empty path falls back to the MCP_SERVER_* environment variables.
 	configPath := *configPathFlag
 	if configPath == "" {
 		configPath = os.Getenv("MCP_PROXY_CONFIG")
 	}
-	if configPath == "" {
-		log.Fatal("MCP_PROXY_CONFIG environment variable or -config flag must be set")
-	}
 
 	// Determine mode: Environment variable takes precedence over flag
 	mode := os.Getenv("MCP_PROXY_MODE")
@@ -32,38 +60,124 @@ func main() {
 		mode = "command" // Default to command if both env var and flag are empty
 	}
 
-	// Load config
+	os.Exit(runProxy(configPath, mode, *printConfigFlag, *adminListenFlag, *commandListenFlag, *commandListenAnyHostFlag))
+}
+
+// runProxy loads the configuration, runs the proxy in the given mode until it stops and
+// returns the process exit code. Once the MCP servers have started they are always shut
+// down before returning, so stdio children are not orphaned.
+func runProxy(configPath, mode string, printConfig bool, adminListen, commandListen string, commandListenAnyHost bool) int {
 	cfg, err := config.LoadConfig(configPath)
 	if err != nil {
-		log.Fatalf("failed to load config: %v", err)
+		return logExit("", fmt.Errorf("%w: failed to load config: %v", ErrConfig, err))
+	}
+	if printConfig {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(cfg); err != nil {
+			return logExit("", fmt.Errorf("failed to print config: %w", err))
+		}
+		return exitOK
+	}
+	setupLogSchema(cfg)
+	if mode != modeHTTP && mode != modeCommand {
+		return logExit("", fmt.Errorf("%w: invalid mode: %s, must be 'http' or 'command'", ErrConfig, mode))
 	}
 
 	// Create the core ProxyServer instance first
 	ps, err := NewProxyServer(cfg)
 	if err != nil {
-		log.Fatalf("failed to create core proxy server: %v", err)
+		return logExit("", err)
 	}
 
 	var proxy Proxy
 	switch mode {
-	case "http":
-		// Define listen address (could be from config or flag later)
-		listenAddr := ":8080" // Default address
-		proxy, err = NewHTTPProxy(ps, listenAddr)
+	case modeHTTP:
+		proxy, err = NewHTTPProxy(ps, defaultListenAddr)
 		if err != nil {
-			log.Fatalf("failed to create HTTP proxy: %v", err)
+			ps.Shutdown()
+			return logExit("", fmt.Errorf("failed to create HTTP proxy: %w", err))
+		}
+		if adminListen != "" {
+			log.Println("-admin-listen is ignored in http mode; /metrics, /healthz, /servers and /status are served on the main listener")
+		}
+		if commandListen != "" {
+			log.Println("-command-listen is ignored in http mode")
 		}
-	case "command":
-		// Pass the ProxyServer instance to NewCommandProxy
-		proxy, err = NewCommandProxy(ps) // Assuming NewCommandProxy will take *ProxyServer
+		if cfg.Pprof && !ps.admin.onSeparateListener() {
+			log.Println("pprof is not served: it is only served on a separate admin listener, set with admin.listen, never on the main HTTP listener")
+		}
+	case modeCommand:
+		cmdProxy, err := NewCommandProxy(ps)
 		if err != nil {
-			log.Fatalf("failed to create command proxy: %v", err)
+			ps.Shutdown()
+			return logExit("", fmt.Errorf("failed to create command proxy: %w", err))
+		}
+		cmdProxy.SetAdminListen(adminListen)
+		if commandListen != "" {
+			cmdProxy.SetCommandListen(commandListen, commandListenAnyHost)
 		}
-	default:
-		log.Fatalf("invalid mode: %s, must be 'http' or 'command'", mode)
+		proxy = cmdProxy
 	}
 
-	if err := proxy.Run(); err != nil {
-		log.Fatalf("proxy run error: %v", err)
+	err = proxy.Run()
+	ps.Shutdown()
+	return logExit(proxy.StopReason(), err)
+}
+
+// runJournalCommand implements `smart-mcp-proxy journal replay [-config path] [-force]`.
+// It prints the replay results as JSON and returns a non-zero exit code if any entry failed.
+func runJournalCommand(args []string) int {
+	if len(args) == 0 || args[0] != "replay" {
+		fmt.Fprintln(os.Stderr, "usage: smart-mcp-proxy journal replay [-config path] [-force]")
+		return 2
+	}
+	fs := flag.NewFlagSet("journal replay", flag.ExitOnError)
+	configPathFlag := fs.String("config", "", "Path to MCP proxy config file")
+	forceFlag := fs.Bool("force", false, "Also replay tools that are not marked read-only or idempotent")
+	fs.Parse(args[1:])
+
+	configPath := *configPathFlag
+	if configPath == "" {
+		configPath = os.Getenv("MCP_PROXY_CONFIG")
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		log.Printf("failed to load config: %v", err)
+		return 1
+	}
+	if cfg.Journal == nil {
+		log.Printf("%v: set journal.path in the config", ErrJournalDisabled)
+		return 1
+	}
+
+	ps, err := NewProxyServer(cfg)
+	if err != nil {
+		log.Printf("failed to create core proxy server: %v", err)
+		return 1
+	}
+	defer ps.Shutdown()
+	// Replaying next to a running proxy would execute entries it may be replaying too
+	if err := ps.journal.lockExclusive(); err != nil {
+		log.Printf("journal replay refused: %v; use POST /admin/journal/replay of the running proxy", err)
+		return 1
+	}
+
+	results, err := ps.ReplayJournal(context.Background(), *forceFlag)
+	if err != nil {
+		log.Printf("journal replay failed: %v", err)
+		return 1
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(map[string]interface{}{"results": results}); err != nil {
+		log.Printf("failed to write results: %v", err)
+		return 1
+	}
+	for _, result := range results {
+		if result.Status == journalFailed {
+			return 1
+		}
 	}
+	return 0
 }
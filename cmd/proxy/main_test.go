@@ -4,120 +4,68 @@ import (
 	"bytes" // Keep bytes
 	"encoding/json"
 	"fmt"
-	"io"  // Add io
 	"log" // Add log
 	"net/http"
 	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"slices"
 	"strings" // Add strings
+	"sync"
 	"testing"
 
 	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest"
 
 	// Gin is needed for HTTPProxy tests
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require" // Add require
 )
 
-// testHttpServer updated to return CallToolResult for tool calls
+// testHttpServer starts a fake backend serving the allowed and restricted tools and
+// resources. Tool calls return CallToolResult text naming the tool, except
+// "tool-error-500", which fails with a 500. Resource requests echo their path, and
+// paths containing "error-404" or "error-500" fail with that status.
 func testHttpServer(serverName string, allowedTools []string, allowedResources []string, restrictedTools []string, restrictedResources []string) (*httptest.Server, config.MCPServerConfig) {
-	mux := http.NewServeMux()
-
-	// Simulate /tools endpoint on backend
-	mux.HandleFunc("/tools", func(w http.ResponseWriter, req *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Header().Set("Content-Type", "application/json")
-		var tools []config.ToolInfo
-		for _, tool := range allowedTools {
-			// Add basic schema for testing
-			tools = append(tools, config.ToolInfo{Name: tool, InputSchema: map[string]interface{}{"type": "object"}})
-		}
-		for _, tool := range restrictedTools {
-			// Add basic schema for testing
-			tools = append(tools, config.ToolInfo{Name: tool, InputSchema: map[string]interface{}{"type": "object"}})
-		}
-		bytes, _ := json.Marshal(map[string]interface{}{"tools": tools})
-		w.Write(bytes)
-	})
-
-	// Simulate /resources endpoint on backend
-	mux.HandleFunc("/resources", func(w http.ResponseWriter, req *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Header().Set("Content-Type", "application/json")
-		var resources []config.ResourceInfo
-		for _, r := range allowedResources {
-			resources = append(resources, config.ResourceInfo{Name: r})
-		}
-		for _, r := range restrictedResources {
-			resources = append(resources, config.ResourceInfo{Name: r})
-		}
-		bytes, _ := json.Marshal(map[string]interface{}{"resources": resources})
-		w.Write(bytes)
-	})
-
-	// Simulate a generic tool endpoint on backend (POST /tool/:toolName)
-	mux.HandleFunc("/tool/", func(w http.ResponseWriter, r *http.Request) {
-		toolName := strings.TrimPrefix(r.URL.Path, "/tool/")
-
-		// --- Error Simulation ---
-		if toolName == "tool-error-500" {
-			log.Printf("Mock Server: Simulating 500 error for tool '%s'", toolName)
-			http.Error(w, "Internal Server Error Simulation", http.StatusInternalServerError)
-			return
-		}
-		// --- End Error Simulation ---
-
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-			return
+	var tools []proxytest.Tool
+	for _, name := range append(slices.Clone(allowedTools), restrictedTools...) {
+		tool := proxytest.Tool{Name: name, Result: &proxytest.Result{Text: fmt.Sprintf(`{"status": "tool /tool/%s called"}`, name)}}
+		if name == "tool-error-500" {
+			tool.Status = http.StatusInternalServerError
 		}
+		tools = append(tools, tool)
+	}
 
-		// Basic echo response for tool calls, returning a CallToolResult structure
-		bodyBytes, _ := io.ReadAll(r.Body) // Read arguments from body if needed for response
-		log.Printf("Mock Server: Received call to tool '%s' with body: %s", toolName, string(bodyBytes))
-
-		w.WriteHeader(http.StatusOK)
-		w.Header().Set("Content-Type", "application/json")
-		// Return a valid CallToolResult matching the expected structure
-		responseText := fmt.Sprintf(`{"status": "tool /tool/%s called"}`, toolName) // This is the inner JSON string
-		result := config.CallToolResult{
-			Content: []config.ContentBlock{
-				{Type: "text", Text: &responseText}, // Wrap the JSON string in the ContentBlock
-			},
-		}
-		json.NewEncoder(w).Encode(result) // Encode the CallToolResult struct
-	})
-
-	// Simulate a generic resource endpoint on backend
-	mux.HandleFunc("/resource/", func(w http.ResponseWriter, r *http.Request) {
-		// --- Error Simulation ---
-		if strings.Contains(r.URL.Path, "error-404") {
-			log.Printf("Mock Server: Simulating 404 error for resource path '%s'", r.URL.Path)
-			http.Error(w, "Resource Not Found Simulation", http.StatusNotFound)
-			return
-		}
-		if strings.Contains(r.URL.Path, "error-500") {
-			log.Printf("Mock Server: Simulating 500 error for resource path '%s'", r.URL.Path)
-			http.Error(w, "Internal Server Error Simulation", http.StatusInternalServerError)
-			return
-		}
-		// --- End Error Simulation ---
-
-		// Basic echo response for resource access
-		w.WriteHeader(http.StatusOK)
-		w.Header().Set("Content-Type", "application/json")
-		// Revert: Do not include method in response to keep command_mode_test passing
-		fmt.Fprintf(w, `{"status": "resource %s accessed"}`, r.URL.Path)
-	})
+	var resources []proxytest.Resource
+	for _, name := range append(slices.Clone(allowedResources), restrictedResources...) {
+		resources = append(resources, proxytest.Resource{Name: name, Handler: func(w http.ResponseWriter, r *http.Request) {
+			// --- Error Simulation ---
+			if strings.Contains(r.URL.Path, "error-404") {
+				log.Printf("Mock Server: Simulating 404 error for resource path '%s'", r.URL.Path)
+				http.Error(w, "Resource Not Found Simulation", http.StatusNotFound)
+				return
+			}
+			if strings.Contains(r.URL.Path, "error-500") {
+				log.Printf("Mock Server: Simulating 500 error for resource path '%s'", r.URL.Path)
+				http.Error(w, "Internal Server Error Simulation", http.StatusInternalServerError)
+				return
+			}
+			// --- End Error Simulation ---
+
+			// Do not include method in response to keep command_mode_test passing
+			fmt.Fprintf(w, `{"status": "resource %s accessed"}`, r.URL.Path)
+		}})
+	}
 
-	server := httptest.NewServer(mux)
+	backend := proxytest.NewBackend(tools, resources)
 	conf := config.MCPServerConfig{
 		Name:             serverName,
-		Address:          server.URL,
+		Address:          backend.URL,
 		AllowedTools:     allowedTools,
 		AllowedResources: allowedResources,
 	}
 
-	return server, conf
+	return backend.Server, conf
 }
 
 // setupTestHTTPProxy sets up ProxyServer and HTTPProxy for testing.
@@ -216,6 +164,41 @@ func TestHTTPHandleTools(t *testing.T) {
 	assert.True(t, foundTools["tool3"])
 }
 
+// TestListToolsPriority tests that tools keep server order, then each server's order,
+// unless prioritized tools lead the listing in configured order or sort_tools sorts
+// the rest by name.
+func TestListToolsPriority(t *testing.T) {
+	server1, server1Conf := testHttpServer("server1", []string{"tool4", "tool1", "tool2"}, []string{"res1"}, nil, nil)
+	defer server1.Close()
+	server2, server2Conf := testHttpServer("server2", []string{"tool3"}, []string{"res2"}, nil, nil)
+	defer server2.Close()
+	prioritized := server2Conf
+	prioritized.ToolPriority = []string{"tool2", "tool3"}
+
+	for _, tt := range []struct {
+		name string
+		cfg  *config.Config
+		want []string
+	}{
+		{"default", &config.Config{MCPServers: []config.MCPServerConfig{server1Conf, server2Conf}}, []string{"tool4", "tool1", "tool2", "tool3"}},
+		{"sort_tools", &config.Config{MCPServers: []config.MCPServerConfig{server1Conf, server2Conf}, SortTools: true}, []string{"tool1", "tool2", "tool3", "tool4"}},
+		// Global priority first, then per-server priority, then the rest
+		{"tool_priority", &config.Config{MCPServers: []config.MCPServerConfig{server1Conf, prioritized}, ToolPriority: []string{"tool3"}}, []string{"tool3", "tool2", "tool4", "tool1"}},
+		{"tool_priority and sort_tools", &config.Config{MCPServers: []config.MCPServerConfig{server1Conf, prioritized}, ToolPriority: []string{"tool3"}, SortTools: true}, []string{"tool3", "tool2", "tool1", "tool4"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ps, err := NewProxyServer(tt.cfg)
+			require.NoError(t, err)
+
+			var names []string
+			for _, tool := range ps.ListTools() {
+				names = append(names, tool.Name)
+			}
+			assert.Equal(t, tt.want, names)
+		})
+	}
+}
+
 // TestHTTPHandleResources tests the /resources endpoint via the HTTPProxy.
 func TestHTTPHandleResources(t *testing.T) {
 	httpProxy, _, servers := setupTestHTTPProxy(t)
@@ -275,6 +258,7 @@ func TestHTTPHandleRestrictedTools(t *testing.T) {
 		assert.NotEmpty(t, tool.Name)
 		assert.NotEmpty(t, tool.ServerName)
 		assert.NotNil(t, tool.InputSchema)
+		assert.Equal(t, config.RestrictedByServerAllowlist, tool.RestrictedBy)
 		foundTools[tool.Name] = tool.ServerName
 	}
 	assert.Equal(t, "server1", foundTools["r-tool1"])
@@ -308,6 +292,8 @@ func TestHTTPHandleRestrictedResources(t *testing.T) {
 	for _, res := range resp.Resources {
 		assert.NotEmpty(t, res.Name)
 		assert.NotEmpty(t, res.ServerName)
+		assert.Equal(t, config.FilterAllowedResources, res.Filter)
+		assert.Equal(t, config.RestrictedByServerAllowlist, res.RestrictedBy)
 		foundResources[res.Name] = res.ServerName
 	}
 	assert.Equal(t, "server1", foundResources["r-res1"])
@@ -421,12 +407,9 @@ func TestHTTPHandleToolCall(t *testing.T) {
 	w = httptest.NewRecorder()
 	httpProxy.engine.ServeHTTP(w, req)
 
-	// Gin's default behavior for unhandled methods on a matched route prefix is 404
-	// If we wanted 405, the handler itself would need more specific method checks.
-	// For now, asserting 404 is consistent with Gin's behavior.
-	assert.Equal(t, http.StatusNotFound, w.Code)
-	// Optionally check the body for Gin's standard 404 page or JSON error
-	// assert.Contains(t, w.Body.String(), "404 page not found")
+	// Methods a route does not handle get 405 with the allowed methods
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "POST", w.Header().Get("Allow"))
 }
 
 // TestHTTPHandleResourceProxy tests the resource proxy endpoint via the HTTPProxy.
@@ -498,3 +481,512 @@ func TestHTTPHandleResourceProxy(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "backend server 'server2' returned an error", errResp["error"])
 }
+
+// TestHTTPAdminRefresh tests that POST /admin/refresh picks up a backend's changed tool list.
+func TestHTTPAdminRefresh(t *testing.T) {
+	var mu sync.Mutex
+	toolNames := []string{"tool1"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tools", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var tools []config.ToolInfo
+		for _, name := range toolNames {
+			tools = append(tools, config.ToolInfo{Name: name, InputSchema: map[string]interface{}{"type": "object"}})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"tools": tools})
+	})
+	mux.HandleFunc("/resources", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"resources":[]}`))
+	})
+	backend := httptest.NewServer(mux)
+	defer backend.Close()
+
+	ps, err := NewProxyServer(&config.Config{
+		MCPServers: []config.MCPServerConfig{{Name: "server1", Address: backend.URL}},
+		AdminToken: "secret",
+	})
+	require.NoError(t, err)
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	// Deploy a new backend version with an extra tool
+	mu.Lock()
+	toolNames = []string{"tool1", "tool2"}
+	mu.Unlock()
+
+	// --- Missing token is rejected ---
+	req := httptest.NewRequest("POST", "/admin/refresh", nil)
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// --- Unknown server ---
+	req = httptest.NewRequest("POST", "/admin/refresh?server=serverX", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// --- Refresh the server ---
+	req = httptest.NewRequest("POST", "/admin/refresh?server=server1", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var refreshResp struct {
+		Servers []RefreshResult `json:"servers"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &refreshResp))
+	require.Len(t, refreshResp.Servers, 1)
+	assert.Equal(t, "server1", refreshResp.Servers[0].ServerName)
+	assert.Equal(t, 2, refreshResp.Servers[0].Tools)
+	assert.Empty(t, refreshResp.Servers[0].Error)
+
+	// --- /tools reflects the refreshed list ---
+	req = httptest.NewRequest("GET", "/tools", nil)
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	var toolsResp struct {
+		Tools []config.ToolInfo `json:"tools"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &toolsResp))
+	assert.Len(t, toolsResp.Tools, 2)
+}
+
+// TestHTTPPrettyJSON tests that listing endpoints emit indented JSON only when requested.
+func TestHTTPPrettyJSON(t *testing.T) {
+	httpProxy, _, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+
+	// --- Compact by default ---
+	req := httptest.NewRequest("GET", "/tools", nil)
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "\n    ")
+
+	// --- Indented with ?pretty=true ---
+	req = httptest.NewRequest("GET", "/tools?pretty=true", nil)
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "\n    ")
+
+	// --- Indented with an Accept pretty hint ---
+	req = httptest.NewRequest("GET", "/resources", nil)
+	req.Header.Set("Accept", "application/json; pretty")
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "\n    ")
+
+	// Indented output is still valid JSON
+	var resp map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+}
+
+// TestHTTPHandleServerDetail tests the GET /servers/:serverName endpoint.
+func TestHTTPHandleServerDetail(t *testing.T) {
+	httpProxy, _, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+
+	req := httptest.NewRequest("GET", "/servers/server1", nil)
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var detail ServerDetail
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+	assert.Equal(t, "server1", detail.Name)
+	assert.Equal(t, servers[0].URL, detail.Address)
+	assert.Equal(t, 3, detail.Tools)
+	assert.Equal(t, 1, detail.Resources)
+	assert.Equal(t, config.MatchExact, detail.MatchMode)
+
+	req = httptest.NewRequest("GET", "/servers/serverX", nil)
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// TestHTTPHandleToolAndResourceDetail tests GET /tools/:toolName and /resources/:resourceName
+// for allowed, unknown and restricted entries.
+func TestHTTPHandleToolAndResourceDetail(t *testing.T) {
+	httpProxy, _, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+
+	req := httptest.NewRequest("GET", "/tools/tool3", nil)
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var tool ToolDetail
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tool))
+	assert.Equal(t, "tool3", tool.Name)
+	assert.Equal(t, "server2", tool.ServerName)
+	assert.Equal(t, "object", tool.InputSchema["type"])
+
+	req = httptest.NewRequest("GET", "/resources/res1", nil)
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resource ResourceDetail
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resource))
+	assert.Equal(t, "res1", resource.Name)
+	assert.Equal(t, "server1", resource.ServerName)
+
+	// Unknown and restricted (provided by the backend but not allowed) entries are not found
+	for _, path := range []string{"/tools/nonexistentTool", "/tools/r-tool1", "/resources/nonexistentRes", "/resources/r-res1"} {
+		req = httptest.NewRequest("GET", path, nil)
+		w = httptest.NewRecorder()
+		httpProxy.engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+	}
+}
+
+// TestPerServerHTTPProxy tests that a server with http_proxy is reached through the egress
+// proxy for discovery and tool calls, while a server without it is reached directly.
+func TestPerServerHTTPProxy(t *testing.T) {
+	for _, env := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy"} {
+		t.Setenv(env, "")
+	}
+
+	proxiedBackend, _ := testHttpServer("proxied", []string{"proxied-tool"}, nil, nil, nil)
+	defer proxiedBackend.Close()
+	directBackend, directConf := testHttpServer("direct", []string{"direct-tool"}, nil, nil, nil)
+	defer directBackend.Close()
+
+	backendURL, err := url.Parse(proxiedBackend.URL)
+	require.NoError(t, err)
+	var mu sync.Mutex
+	var proxiedHosts []string
+	egress := httptest.NewServer(&httputil.ReverseProxy{Director: func(r *http.Request) {
+		mu.Lock()
+		proxiedHosts = append(proxiedHosts, r.Host)
+		mu.Unlock()
+		r.URL.Scheme = backendURL.Scheme
+		r.URL.Host = backendURL.Host
+	}})
+	defer egress.Close()
+
+	// The proxied server's host does not resolve; it is only reachable through the egress proxy.
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{
+		{Name: "proxied", Address: "http://mcp-backend.invalid", HTTPProxy: egress.URL, AllowedTools: []string{"proxied-tool"}},
+		directConf,
+	}})
+	require.NoError(t, err)
+
+	result, err := ps.CallTool("proxied-tool", map[string]interface{}{})
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	_, err = ps.CallTool("direct-tool", map[string]interface{}{})
+	require.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Contains(t, proxiedHosts, "mcp-backend.invalid")
+	for _, host := range proxiedHosts {
+		assert.Equal(t, "mcp-backend.invalid", host, "direct server requests must not go through the proxy")
+	}
+}
+
+// TestHTTPHeaderLimits tests that requests over the header count or size limit are
+// rejected with 431.
+func TestHTTPHeaderLimits(t *testing.T) {
+	_, ps, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+	ps.maxHeaderCount = 5
+	ps.maxHeaderBytes = 4096
+	httpProxy, err := NewHTTPProxy(ps, "127.0.0.1:0")
+	require.NoError(t, err)
+	assert.Equal(t, 4096, httpProxy.srv.MaxHeaderBytes)
+
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	req.Header.Set("X-One", "1")
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest("GET", "/healthz", nil)
+	for i := 0; i < 6; i++ {
+		req.Header.Add("X-Repeated", fmt.Sprint(i))
+	}
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestHeaderFieldsTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), "too many request header fields")
+
+	// The size limit is enforced by the server before routing
+	require.NoError(t, httpProxy.serve())
+	defer httpProxy.srv.Close()
+	req, err = http.NewRequest("GET", "http://"+httpProxy.listener.Addr().String()+"/healthz", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Large", strings.Repeat("a", 8192))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusRequestHeaderFieldsTooLarge, resp.StatusCode)
+}
+
+// TestHTTPTrustedProxies tests that X-Forwarded-For sets the client IP only for requests
+// from a proxy listed in trusted_proxies.
+func TestHTTPTrustedProxies(t *testing.T) {
+	backend, conf := testHttpServer("server1", nil, []string{"res1"}, nil, nil)
+	defer backend.Close()
+
+	clientIP := func(trusted []string, remoteAddr string) string {
+		ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{conf}, TrustedProxies: trusted})
+		require.NoError(t, err)
+		defer ps.Shutdown()
+		httpProxy, err := NewHTTPProxy(ps, ":0")
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/resource/server1/res1/page", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		w := httptest.NewRecorder()
+		httpProxy.engine.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		accesses := ps.ResourceAnalytics().RecentAccesses
+		require.Len(t, accesses, 1)
+		return accesses[0].Client
+	}
+
+	// No trusted proxies: the header is ignored
+	assert.Equal(t, "10.0.0.5", clientIP(nil, "10.0.0.5:4321"))
+	// Request from a trusted proxy: the forwarded client
+	assert.Equal(t, "203.0.113.7", clientIP([]string{"10.0.0.0/8"}, "10.0.0.5:4321"))
+	// Request from elsewhere: the remote address, even with proxies configured
+	assert.Equal(t, "192.0.2.9", clientIP([]string{"10.0.0.0/8"}, "192.0.2.9:4321"))
+}
+
+// TestJSONRPCToolCall tests that servers with discovery "jsonrpc" receive tool calls as
+// JSON-RPC tools/call requests, with _meta in params.
+func TestJSONRPCToolCall(t *testing.T) {
+	var gotParams map[string]interface{}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     int64                  `json:"id"`
+			Method string                 `json:"method"`
+			Params map[string]interface{} `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "tools/list":
+			resp["result"] = map[string]interface{}{"tools": []interface{}{map[string]interface{}{"name": "rpc-tool", "inputSchema": map[string]interface{}{"type": "object"}}}}
+		case "resources/list":
+			resp["result"] = map[string]interface{}{"resources": []interface{}{}}
+		case "tools/call":
+			if req.Params["name"] == "failing-tool" {
+				resp["error"] = map[string]interface{}{"code": -32000, "message": "tool crashed"}
+				break
+			}
+			gotParams = req.Params
+			text := "called"
+			resp["result"] = config.CallToolResult{Content: []config.ContentBlock{{Type: "text", Text: &text}}}
+		default:
+			resp["error"] = map[string]interface{}{"code": -32601, "message": "Method not found"}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer backend.Close()
+
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{
+		{Name: "rpc-server", Address: backend.URL, Discovery: config.DiscoveryJSONRPC},
+	}})
+	require.NoError(t, err)
+	require.Len(t, ps.ListTools(), 1)
+
+	result, err := ps.CallToolWithMeta("rpc-tool", map[string]interface{}{"q": "x"}, map[string]interface{}{"progressToken": "p1"})
+	require.NoError(t, err)
+	assert.Equal(t, "called", *result.Content[0].Text)
+	assert.Equal(t, "rpc-tool", gotParams["name"])
+	assert.Equal(t, map[string]interface{}{"q": "x"}, gotParams["arguments"])
+	assert.Equal(t, map[string]interface{}{"progressToken": "p1"}, gotParams["_meta"])
+
+	_, err = ps.CallTool("failing-tool", nil)
+	assert.ErrorIs(t, err, ErrBackendCommunication)
+	assert.Contains(t, err.Error(), "tool crashed")
+}
+
+// TestHTTPTrailingSlash tests that paths with a trailing slash are not redirected by
+// default, so a POST is never turned into a GET, and that redirects can be enabled.
+func TestHTTPTrailingSlash(t *testing.T) {
+	httpProxy, ps, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+
+	req := httptest.NewRequest("POST", "/tool/tool1/", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", "/tools/", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	ps.redirectTrailingSlash = true
+	redirecting, err := NewHTTPProxy(ps, "127.0.0.1:0")
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	redirecting.engine.ServeHTTP(w, httptest.NewRequest("GET", "/tools/", nil))
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "/tools", w.Header().Get("Location"))
+}
+
+// TestHTTPNotFoundJSON tests that unknown paths and wrong methods get JSON error bodies,
+// with an Allow header for the latter.
+func TestHTTPNotFoundJSON(t *testing.T) {
+	httpProxy, _, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", "/no/such/path", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+	assert.JSONEq(t, `{"error":"not found","path":"/no/such/path"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", "/tool/tool1", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "POST", w.Header().Get("Allow"))
+	assert.JSONEq(t, `{"error":"method not allowed","path":"/tool/tool1"}`, w.Body.String())
+}
+
+// TestHealthzStdioQueueDepth tests that /healthz reports the request queue of each
+// stdio server.
+func TestHealthzStdioQueueDepth(t *testing.T) {
+	server := &config.MCPServer{
+		Config:                 config.MCPServerConfig{Name: "stdio-server", Command: "unused"},
+		HandleStdioRequestFunc: func(reqBytes []byte) ([]byte, error) { return []byte(`{}`), nil },
+	}
+	ps := &ProxyServer{mcpServers: []*config.MCPServer{server}, recentCalls: newCallRing(recentCallsSize)}
+	httpProxy, err := NewHTTPProxy(ps, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ok","stdioQueueDepth":{"stdio-server":0}}`, w.Body.String())
+}
+
+// TestHTTPListingServerMetadata tests that listings carry the display metadata of each
+// tool's server, and omit it for servers without any.
+func TestHTTPListingServerMetadata(t *testing.T) {
+	server1, server1Conf := testHttpServer("server1", []string{"tool1"}, []string{"res1"}, nil, nil)
+	defer server1.Close()
+	server2, server2Conf := testHttpServer("server2", []string{"tool3"}, nil, nil, nil)
+	defer server2.Close()
+	server1Conf.Display = &config.DisplayConfig{Title: "Server One", IconURL: "https://example.com/one.png", Color: "#ff8800"}
+
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{server1Conf, server2Conf}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	get := func(path string, v interface{}) {
+		w := httptest.NewRecorder()
+		httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), v))
+	}
+	wantMeta := map[string]interface{}{"name": "server1", "title": "Server One", "iconUrl": "https://example.com/one.png", "color": "#ff8800"}
+
+	var tools struct {
+		Tools []map[string]interface{} `json:"tools"`
+	}
+	get("/tools", &tools)
+	require.Len(t, tools.Tools, 2)
+	assert.Equal(t, wantMeta, tools.Tools[0]["server"])
+	assert.NotContains(t, tools.Tools[1], "server")
+
+	var resources struct {
+		Resources []map[string]interface{} `json:"resources"`
+	}
+	get("/resources", &resources)
+	require.Len(t, resources.Resources, 1)
+	assert.Equal(t, wantMeta, resources.Resources[0]["server"])
+
+	var detail map[string]interface{}
+	get("/tools/tool1", &detail)
+	assert.Equal(t, wantMeta, detail["server"])
+
+	var servers struct {
+		Servers []map[string]interface{} `json:"servers"`
+	}
+	get("/servers", &servers)
+	require.Len(t, servers.Servers, 2)
+	assert.Equal(t, wantMeta, servers.Servers[0]["server"])
+	assert.NotContains(t, servers.Servers[1], "server")
+}
+
+// TestHTTPResourceProxyForwardHeaders tests that only allowlisted client headers reach
+// the backend when forward_headers is set, and all of them otherwise.
+func TestHTTPResourceProxyForwardHeaders(t *testing.T) {
+	var received http.Header
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tools", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tools":[]}`))
+	})
+	mux.HandleFunc("/resources", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"resources":[{"name":"res1"}]}`))
+	})
+	mux.HandleFunc("/resource/res1/data", func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.Write([]byte(`ok`))
+	})
+	backend := httptest.NewServer(mux)
+	defer backend.Close()
+
+	for _, tt := range []struct {
+		name        string
+		allow       []string
+		wantForward []string
+		wantDropped []string
+	}{
+		{"allowlist", []string{"x-request-id", "Authorization"}, []string{"X-Request-Id", "Authorization", "Content-Type"}, []string{"X-Internal-Token"}},
+		{"no allowlist", nil, []string{"X-Request-Id", "Authorization", "Content-Type", "X-Internal-Token"}, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{
+				{Name: "server1", Address: backend.URL, ForwardHeaders: tt.allow},
+			}})
+			require.NoError(t, err)
+			defer ps.Shutdown()
+			httpProxy, err := NewHTTPProxy(ps, ":0")
+			require.NoError(t, err)
+
+			req := httptest.NewRequest("POST", "/resource/server1/res1/data", strings.NewReader(`{}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Request-Id", "abc")
+			req.Header.Set("Authorization", "Bearer client")
+			req.Header.Set("X-Internal-Token", "secret")
+			w := httptest.NewRecorder()
+			httpProxy.engine.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			for _, name := range tt.wantForward {
+				assert.NotEmpty(t, received.Get(name), "header %s should be forwarded", name)
+			}
+			for _, name := range tt.wantDropped {
+				assert.Empty(t, received.Get(name), "header %s should be dropped", name)
+			}
+		})
+	}
+}
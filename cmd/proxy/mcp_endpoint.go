@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sessionIDHeader carries the session of a streamable-HTTP MCP request.
+const sessionIDHeader = "Mcp-Session-Id"
+
+// mcpProtocolVersions are the MCP protocol versions the /mcp endpoint negotiates,
+// latest first.
+var mcpProtocolVersions = []string{"2025-06-18", "2025-03-26"}
+
+// initializeRequestParams are the params of an initialize request.
+type initializeRequestParams struct {
+	ProtocolVersion string                 `json:"protocolVersion"`
+	Capabilities    map[string]interface{} `json:"capabilities"`
+	ClientInfo      map[string]interface{} `json:"clientInfo"`
+}
+
+// resourceURIParams are the params of resources/read, resources/subscribe and
+// resources/unsubscribe.
+type resourceURIParams struct {
+	URI string `json:"uri"`
+}
+
+// negotiateProtocolVersion returns the client's protocol version when the endpoint
+// supports it, and the latest supported version otherwise.
+func negotiateProtocolVersion(requested string) string {
+	if slices.Contains(mcpProtocolVersions, requested) {
+		return requested
+	}
+	return mcpProtocolVersions[0]
+}
+
+// handleMCP serves a streamable-HTTP MCP JSON-RPC request with a JSON response.
+// initialize starts a session whose ID is returned in Mcp-Session-Id; every other
+// request must carry it, and gets 404 once the session has expired so the client
+// initializes again. Notifications are answered with 202.
+func (h *HTTPProxy) handleMCP(c *gin.Context) {
+	body, release, err := h.ps.bodyBudget.readAll(c.Request.Body)
+	if err != nil {
+		if t := asThrottle(err); t != nil {
+			respondThrottled(c, bufferLimitMessage, t)
+			return
+		}
+		c.JSON(http.StatusBadRequest, jsonRPCResponse{JSONRPC: "2.0", Error: &rpcError{Code: -32700, Message: "Parse error: " + err.Error()}})
+		return
+	}
+	defer release()
+
+	var req jsonRPCRequest
+	if err := h.ps.unmarshalJSON(body, &req); err != nil {
+		c.JSON(http.StatusBadRequest, jsonRPCResponse{JSONRPC: "2.0", Error: &rpcError{Code: -32700, Message: "Parse error: invalid JSON"}})
+		return
+	}
+	if !h.ps.validJSONRPCVersion(req.JSONRPC) {
+		c.JSON(http.StatusBadRequest, jsonRPCResponse{JSONRPC: "2.0", ID: req.ID, Error: &rpcError{Code: -32600, Message: "Invalid Request: jsonrpc must be '2.0'"}})
+		return
+	}
+
+	if req.Method == "initialize" {
+		h.handleMCPInitialize(c, req)
+		return
+	}
+
+	sessionID := c.GetHeader(sessionIDHeader)
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, jsonRPCResponse{JSONRPC: "2.0", ID: req.ID, Error: &rpcError{Code: -32600, Message: "Bad Request: missing " + sessionIDHeader + " header"}})
+		return
+	}
+	session, ok := h.ps.sessions.get(sessionID)
+	if !ok {
+		c.JSON(http.StatusNotFound, jsonRPCResponse{JSONRPC: "2.0", ID: req.ID, Error: &rpcError{Code: -32001, Message: "Session not found"}})
+		return
+	}
+
+	var result interface{}
+	var rpcErr *rpcError
+	switch req.Method {
+	case "notifications/initialized":
+		h.ps.sessions.update(sessionID, func(s *clientSession) { s.Initialized = true })
+	case "ping":
+		result = map[string]interface{}{}
+	case "tools/list":
+		result = map[string]interface{}{"tools": h.ps.ListTools()}
+	case "resources/list":
+		result = map[string]interface{}{"resources": h.ps.ListResources()}
+	case "resources/read":
+		rpcErr = readResourceRPC(h.ps, h.clientIdentity(c), requestHops(c), req.Params, &result)
+	case "resources/subscribe", "resources/unsubscribe":
+		rpcErr = h.handleMCPSubscription(sessionID, req, &result)
+	case "tools/call":
+		rpcErr = h.handleMCPToolCall(c, session, req.Params, &result)
+	default:
+		rpcErr = &rpcError{Code: -32601, Message: "Method not found"}
+	}
+
+	if req.ID == nil {
+		// Notifications get no response body
+		c.Status(http.StatusAccepted)
+		return
+	}
+	c.JSON(http.StatusOK, jsonRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rpcErr})
+}
+
+// handleMCPInitialize starts a session and returns the proxy's capabilities.
+func (h *HTTPProxy) handleMCPInitialize(c *gin.Context, req jsonRPCRequest) {
+	var params initializeRequestParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			c.JSON(http.StatusOK, jsonRPCResponse{JSONRPC: "2.0", ID: req.ID, Error: &rpcError{Code: -32602, Message: "Invalid params for initialize", Data: err.Error()}})
+			return
+		}
+	}
+	version := negotiateProtocolVersion(params.ProtocolVersion)
+	// The GET /mcp stream relays list_changed notifications
+	capabilities := h.ps.advertisedCapabilities(true)
+	session, err := h.ps.sessions.create(version, params.Capabilities, params.ClientInfo, capabilities)
+	if t := asThrottle(err); t != nil {
+		log.Printf("Refused MCP session: %v", err)
+		t.setRetryAfter(c)
+		c.JSON(t.status(), jsonRPCResponse{JSONRPC: "2.0", ID: req.ID, Error: throttleRPCError(-32000, "Too many sessions", err, t)})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to start MCP session: %v", err)
+		c.JSON(http.StatusOK, jsonRPCResponse{JSONRPC: "2.0", ID: req.ID, Error: &rpcError{Code: -32603, Message: "Failed to start session"}})
+		return
+	}
+	c.Header(sessionIDHeader, session.ID)
+	c.JSON(http.StatusOK, jsonRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: initializeResult(version, capabilities)})
+}
+
+// initializeResult is the proxy's initialize result for a negotiated protocol version.
+func initializeResult(version string, capabilities map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"protocolVersion": version,
+		"capabilities":    capabilities,
+		"serverInfo":      map[string]interface{}{"name": "smart-mcp-proxy", "version": proxyVersion()},
+	}
+}
+
+// advertisedCapabilities returns the capabilities of the proxy's initialize result.
+// tools and resources are always advertised, with listChanged only when relay is set,
+// i.e. the client receives list_changed notifications. prompts is never advertised,
+// even when a backend has them, as the proxy does not serve prompts/list, and neither
+// is resources.subscribe: subscriptions are recorded in the session but neither
+// forwarded to backends nor answered with resource updates.
+func (ps *ProxyServer) advertisedCapabilities(relay bool) map[string]interface{} {
+	tools := map[string]interface{}{}
+	resources := map[string]interface{}{}
+	if relay {
+		tools["listChanged"] = true
+		resources["listChanged"] = true
+	}
+	return map[string]interface{}{"tools": tools, "resources": resources}
+}
+
+// handleMCPSubscription records a resources/subscribe or resources/unsubscribe in the
+// session.
+func (h *HTTPProxy) handleMCPSubscription(sessionID string, req jsonRPCRequest, result *interface{}) *rpcError {
+	var params resourceURIParams
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.URI == "" {
+		return &rpcError{Code: -32602, Message: "Invalid params for " + req.Method + ": 'uri' is required"}
+	}
+	h.ps.sessions.update(sessionID, func(s *clientSession) {
+		if req.Method == "resources/subscribe" {
+			s.subscribe(params.URI)
+		} else {
+			s.unsubscribe(params.URI)
+		}
+	})
+	*result = map[string]interface{}{}
+	return nil
+}
+
+// handleMCPToolCall calls a tool, preferring the server that served the tool earlier in
+// the session, and pins the session to the server that served it.
+func (h *HTTPProxy) handleMCPToolCall(c *gin.Context, session clientSession, params json.RawMessage, result *interface{}) *rpcError {
+	var toolParams config.CallToolRequestParams
+	if err := h.ps.unmarshalJSON(params, &toolParams); err != nil {
+		return &rpcError{Code: -32602, Message: "Invalid params for tools/call: failed to parse", Data: err.Error()}
+	}
+	if toolParams.Name == "" {
+		return &rpcError{Code: -32602, Message: "Invalid params for tools/call: 'name' is required"}
+	}
+
+	affinity := &serverAffinity{preferred: session.Affinity[toolParams.Name]}
+	ctx := withServerAffinity(config.WithHopCount(context.Background(), requestHops(c)), affinity)
+	callResult, err := h.ps.callToolFrom(ctx, h.clientIdentity(c), toolParams.Name, toolParams.Arguments, toolParams.Meta)
+	if affinity.served != "" && affinity.served != affinity.preferred {
+		h.ps.sessions.update(session.ID, func(s *clientSession) { s.pin(toolParams.Name, affinity.served) })
+	}
+	if err != nil {
+		log.Printf("Error calling tool '%s' via ProxyServer: %v", toolParams.Name, err)
+		_, message := toolCallErrorStatus(toolParams.Name, err)
+		if errors.Is(err, ErrArgumentLimit) {
+			return &rpcError{Code: -32602, Message: "Invalid params for tools/call: " + message}
+		}
+		if t := asThrottle(err); t != nil {
+			return throttleRPCError(-32000, message, err, t)
+		}
+		return &rpcError{Code: -32000, Message: message}
+	}
+	*result = callResult
+	return nil
+}
+
+// handleMCPDelete ends the session named by Mcp-Session-Id.
+func (h *HTTPProxy) handleMCPDelete(c *gin.Context) {
+	if !h.ps.sessions.delete(c.GetHeader(sessionIDHeader)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mcpStreamSubscriber labels the event subscriptions of /mcp SSE streams in the
+// dropped events metric.
+const mcpStreamSubscriber = "mcp_stream"
+
+// eventNotification converts a proxy event to the MCP notification sent on SSE streams:
+// notifications/tools/list_changed or notifications/resources/list_changed when a
+// server's tools or resources changed, and a notifications/message log entry otherwise.
+func eventNotification(e Event) jsonRPCNotification {
+	switch e.Type {
+	case config.EventToolsetChanged:
+		return jsonRPCNotification{JSONRPC: "2.0", Method: "notifications/tools/list_changed"}
+	case config.EventResourcesChanged:
+		return jsonRPCNotification{JSONRPC: "2.0", Method: "notifications/resources/list_changed"}
+	}
+	level := "warning"
+	if e.Type == config.EventBackendUp {
+		level = "info"
+	}
+	return jsonRPCNotification{JSONRPC: "2.0", Method: "notifications/message", Params: map[string]interface{}{
+		"level":  level,
+		"logger": "smart-mcp-proxy",
+		"data":   e,
+	}}
+}
+
+// jsonRPCNotification is a JSON-RPC request without an ID.
+type jsonRPCNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// handleMCPStream serves GET /mcp: a Server-Sent Events stream of notifications for the
+// session named by Mcp-Session-Id. Events wait in a queue of sse.queue_size, which a
+// client reading too slowly overflows by the sse.overflow policy. A heartbeat comment
+// is written every sse.heartbeat_interval, and a write that does not complete within
+// the interval closes the stream, so a dead connection releases its queue.
+func (h *HTTPProxy) handleMCPStream(c *gin.Context) {
+	sessionID := c.GetHeader(sessionIDHeader)
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing " + sessionIDHeader + " header"})
+		return
+	}
+	if _, ok := h.ps.sessions.get(sessionID); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	events, cancel := h.ps.events.subscribeWithOverflow(mcpStreamSubscriber, h.ps.sseQueueSize, h.ps.sseOverflow)
+	defer cancel()
+
+	rc := http.NewResponseController(c.Writer)
+	write := func(data string) error {
+		// Unsupported by test recorders; real connections always support deadlines
+		rc.SetWriteDeadline(time.Now().Add(h.ps.sseHeartbeat))
+		if _, err := io.WriteString(c.Writer, data); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+	if err := write(": connected\n\n"); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.ps.sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				// Disconnected for overflowing its queue, or the proxy is shutting down
+				log.Printf("Closing SSE stream of session %s: event queue closed", sessionID)
+				return
+			}
+			data, _ := json.Marshal(eventNotification(e))
+			err = write(fmt.Sprintf("event: message\ndata: %s\n\n", data))
+		case <-heartbeat.C:
+			err = write(": ping\n\n")
+		}
+		if err != nil {
+			log.Printf("Closing SSE stream of session %s: %v", sessionID, err)
+			return
+		}
+		// An open stream keeps its session alive; a deleted session ends it
+		if _, ok := h.ps.sessions.get(sessionID); !ok {
+			return
+		}
+	}
+}
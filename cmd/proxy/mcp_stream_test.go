@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openMCPStream starts a session on a proxy served over TCP and opens its SSE stream.
+func openMCPStream(t *testing.T, sse *config.SSEConfig) (*ProxyServer, *http.Response) {
+	backend, conf := testHttpServer("server1", []string{"tool1"}, nil, nil, nil)
+	t.Cleanup(backend.Close)
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{conf}, SSE: sse})
+	require.NoError(t, err)
+	t.Cleanup(ps.Shutdown)
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+	server := httptest.NewServer(httpProxy.engine)
+	t.Cleanup(server.Close)
+
+	id := mcpInitialize(t, httpProxy)
+	req, err := http.NewRequest("GET", server.URL+"/mcp", nil)
+	require.NoError(t, err)
+	req.Header.Set(sessionIDHeader, id)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	return ps, resp
+}
+
+// streamSubscriptions returns the queue length of every /mcp stream subscription.
+func streamSubscriptions(ps *ProxyServer) []int {
+	ps.events.mu.RLock()
+	defer ps.events.mu.RUnlock()
+	var queued []int
+	for sub := range ps.events.subs {
+		if sub.name == mcpStreamSubscriber {
+			queued = append(queued, len(sub.ch))
+		}
+	}
+	return queued
+}
+
+// TestMCPStreamNotificationsAndHeartbeat tests that events reach the stream as MCP
+// notifications and that heartbeats are sent.
+func TestMCPStreamNotificationsAndHeartbeat(t *testing.T) {
+	ps, resp := openMCPStream(t, &config.SSEConfig{HeartbeatInterval: "50ms"})
+	reader := bufio.NewReader(resp.Body)
+	readLine := func() string {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		return strings.TrimSpace(line)
+	}
+	require.Equal(t, ": connected", readLine())
+
+	ps.events.publish(Event{Type: config.EventToolsetChanged, Server: "server1"})
+	pinged := false
+	line := readLine()
+	for ; line != "event: message"; line = readLine() {
+		pinged = pinged || line == ": ping"
+	}
+	assert.Equal(t, `data: {"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`, readLine())
+
+	for !pinged {
+		pinged = readLine() == ": ping"
+	}
+}
+
+// TestMCPStreamStalledReader tests that a client that stops reading cannot make the
+// proxy queue more than sse.queue_size events, and that with the disconnect policy its
+// stream is closed and its queue released.
+func TestMCPStreamStalledReader(t *testing.T) {
+	for _, overflow := range []string{config.SSEOverflowDropOldest, config.SSEOverflowDisconnect} {
+		t.Run(overflow, func(t *testing.T) {
+			ps, _ := openMCPStream(t, &config.SSEConfig{QueueSize: 4, Overflow: overflow, HeartbeatInterval: "100ms"})
+			require.Eventually(t, func() bool { return len(streamSubscriptions(ps)) == 1 }, time.Second, 10*time.Millisecond)
+
+			// Large events fill the connection's buffers, after which the handler blocks
+			// writing and events pile up in its queue. The blocked write misses its
+			// deadline, or the overflow disconnects the stream; either way the
+			// subscription goes away.
+			big := strings.Repeat("x", 64<<10)
+			deadline := time.Now().Add(10 * time.Second)
+			for {
+				ps.events.publish(Event{Type: config.EventRefreshFailed, Server: "server1", Error: big})
+				queues := streamSubscriptions(ps)
+				if len(queues) == 0 {
+					break
+				}
+				require.LessOrEqual(t, queues[0], 4)
+				require.True(t, time.Now().Before(deadline), "stream of a stalled reader was not closed")
+				time.Sleep(time.Millisecond)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+)
+
+// metaKey is the reserved MCP key carrying request and result metadata.
+const metaKey = "_meta"
+
+// Keys the proxy adds to result _meta when result_meta is enabled.
+const (
+	metaServerKey     = "smartproxy/server"
+	metaDurationMsKey = "smartproxy/duration_ms"
+)
+
+// requestMetaKey is the context key holding the _meta of the client's request.
+type requestMetaKey struct{}
+
+// withRequestMeta returns a context carrying the request's _meta.
+func withRequestMeta(ctx context.Context, meta map[string]interface{}) context.Context {
+	if len(meta) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, requestMetaKey{}, meta)
+}
+
+// requestMeta returns the _meta stored by withRequestMeta, or nil.
+func requestMeta(ctx context.Context) map[string]interface{} {
+	meta, _ := ctx.Value(requestMetaKey{}).(map[string]interface{})
+	return meta
+}
+
+// argumentsWithMeta returns the backend parameters for a call: the arguments plus the
+// request's _meta, placed under the reserved _meta key as MCP does for params. The
+// caller's map is not modified.
+func argumentsWithMeta(arguments, meta map[string]interface{}) map[string]interface{} {
+	if len(meta) == 0 {
+		return arguments
+	}
+	params := make(map[string]interface{}, len(arguments)+1)
+	for k, v := range arguments {
+		params[k] = v
+	}
+	params[metaKey] = meta
+	return params
+}
+
+// splitArgumentsMeta separates a _meta object sent alongside HTTP tool arguments.
+// A _meta value that is not an object is left in the arguments.
+func splitArgumentsMeta(arguments map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	meta, ok := arguments[metaKey].(map[string]interface{})
+	if !ok {
+		return arguments, nil
+	}
+	rest := make(map[string]interface{}, len(arguments)-1)
+	for k, v := range arguments {
+		if k != metaKey {
+			rest[k] = v
+		}
+	}
+	return rest, meta
+}
+
+// annotateResultMeta adds the proxy's own keys to the result's _meta when result_meta
+// is enabled. Keys set by the backend are kept.
+func (ps *ProxyServer) annotateResultMeta(result *config.CallToolResult, serverName string, duration time.Duration) {
+	if !ps.resultMeta || result == nil {
+		return
+	}
+	if result.Meta == nil {
+		result.Meta = make(map[string]interface{}, 2)
+	}
+	result.Meta[metaServerKey] = serverName
+	result.Meta[metaDurationMsKey] = float64(duration.Microseconds()) / 1000
+}
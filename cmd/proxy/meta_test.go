@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// backendMeta is the nested _meta returned by testMetaServer.
+var backendMeta = map[string]interface{}{
+	"backend/trace": map[string]interface{}{"spans": []interface{}{"a", "b"}, "sampled": true},
+}
+
+// testMetaServer starts a backend whose "echo" tool returns the body it received as text
+// and a nested _meta in the result.
+func testMetaServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tools", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tools":[{"name":"echo","inputSchema":{"type":"object"}}]}`))
+	})
+	mux.HandleFunc("/resources", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"resources":[]}`))
+	})
+	mux.HandleFunc("/tool/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		text := string(body)
+		json.NewEncoder(w).Encode(config.CallToolResult{
+			Content: []config.ContentBlock{{Type: "text", Text: &text}},
+			Meta:    backendMeta,
+		})
+	})
+	return httptest.NewServer(mux)
+}
+
+// requestMetaJSON is a nested _meta sent by clients.
+const requestMetaJSON = `{"progressToken":"tok-1","client/context":{"session":{"id":42,"tags":["x","y"]}}}`
+
+// TestHTTPToolCallMetaRoundTrip tests that request _meta reaches the backend, backend
+// _meta is returned, and the proxy adds its own keys when result_meta is enabled.
+func TestHTTPToolCallMetaRoundTrip(t *testing.T) {
+	backend := testMetaServer()
+	defer backend.Close()
+	ps, err := NewProxyServer(&config.Config{
+		MCPServers: []config.MCPServerConfig{{Name: "meta-server", Address: backend.URL}},
+		ResultMeta: true,
+	})
+	require.NoError(t, err)
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/tool/echo", strings.NewReader(`{"n":1,"_meta":`+requestMetaJSON+`}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var result config.CallToolResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+
+	var sent map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(*result.Content[0].Text), &sent))
+	var wantMeta map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(requestMetaJSON), &wantMeta))
+	assert.Equal(t, 1.0, sent["n"])
+	assert.Equal(t, wantMeta, sent["_meta"])
+
+	assert.Equal(t, backendMeta["backend/trace"], result.Meta["backend/trace"])
+	assert.Equal(t, "meta-server", result.Meta[metaServerKey])
+	assert.Contains(t, result.Meta, metaDurationMsKey)
+}
+
+// TestCommandToolCallMetaStdio tests _meta passthrough over JSON-RPC to a stdio backend,
+// without proxy keys when result_meta is disabled.
+func TestCommandToolCallMetaStdio(t *testing.T) {
+	var backendReq map[string]interface{}
+	server := &config.MCPServer{
+		Config: config.MCPServerConfig{Name: "stdio-meta", Command: "unused", AllowedTools: []string{"echo"}},
+		HandleStdioRequestFunc: func(reqBytes []byte) ([]byte, error) {
+			json.Unmarshal(reqBytes, &backendReq)
+			return json.Marshal(config.CallToolResult{Content: []config.ContentBlock{}, Meta: backendMeta})
+		},
+	}
+	ps := &ProxyServer{mcpServers: []*config.MCPServer{server}, recentCalls: newCallRing(recentCallsSize)}
+	cmdProxy, err := NewCommandProxy(ps)
+	require.NoError(t, err)
+
+	reqBytes := []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"echo","arguments":{"n":1},"_meta":` + requestMetaJSON + `}}`)
+	respBytes, err := cmdProxy.handleCommandRequest(reqBytes)
+	require.NoError(t, err)
+
+	var resp struct {
+		Result config.CallToolResult `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal(respBytes, &resp))
+	assert.Equal(t, backendMeta["backend/trace"], resp.Result.Meta["backend/trace"])
+	assert.NotContains(t, resp.Result.Meta, metaServerKey)
+
+	var wantMeta map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(requestMetaJSON), &wantMeta))
+	params, ok := backendReq["params"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"n": 1.0}, params["arguments"])
+	assert.Equal(t, wantMeta, params["_meta"])
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+)
+
+// maxMirrorsInFlight caps concurrent mirrored calls; further calls are not mirrored
+// until a slot frees up, so a slow shadow server cannot pile up goroutines.
+const maxMirrorsInFlight = 16
+
+// maxMirrorDiffs caps the differences reported for one mirrored call.
+const maxMirrorDiffs = 10
+
+// mirrorDrainTimeout bounds the wait for mirrored calls in flight at shutdown. It is
+// replaced in tests.
+var mirrorDrainTimeout = 10 * time.Second
+
+// mirrorSample returns a number in [0, 100) compared against the sampling percentage.
+// It is replaced in tests.
+var mirrorSample = func() float64 { return rand.Float64() * 100 }
+
+// mirror copies a primary server's tool calls to a shadow server.
+type mirror struct {
+	target  *config.MCPServer
+	tools   map[string]bool // Nil mirrors every tool
+	percent float64
+	timeout time.Duration
+}
+
+// covers reports whether calls to toolName are mirrored.
+func (m *mirror) covers(toolName string) bool {
+	return m.tools == nil || m.tools[toolName]
+}
+
+// setupMirrors takes the shadow servers out of the routed servers and builds the
+// mirror of every server with mirror_to, keyed by primary server name.
+func (ps *ProxyServer) setupMirrors(cfg *config.Config) error {
+	shadows := cfg.ShadowServers()
+	if len(shadows) == 0 {
+		return nil
+	}
+	targets := make(map[string]*config.MCPServer)
+	routed := ps.mcpServers[:0:0]
+	for _, server := range ps.mcpServers {
+		if shadows[server.Config.Name] {
+			targets[server.Config.Name] = server
+			ps.shadowServers = append(ps.shadowServers, server)
+		} else {
+			routed = append(routed, server)
+		}
+	}
+	ps.mcpServers = routed
+
+	ps.mirrors = make(map[string]*mirror)
+	ps.mirrorSlots = make(chan struct{}, maxMirrorsInFlight)
+	for _, server := range routed {
+		mc := server.Config.MirrorTo
+		if mc == nil {
+			continue
+		}
+		target, ok := targets[mc.Server]
+		if !ok {
+			log.Printf("Mirror target '%s' of server '%s' is not running; calls are not mirrored", mc.Server, server.Config.Name)
+			continue
+		}
+		timeout, err := mc.TimeoutDuration()
+		if err != nil {
+			return fmt.Errorf("invalid mirror_to.timeout for server '%s': %w", server.Config.Name, err)
+		}
+		m := &mirror{target: target, percent: mc.Percent(), timeout: timeout}
+		if len(mc.Tools) > 0 {
+			m.tools = make(map[string]bool, len(mc.Tools))
+			for _, tool := range mc.Tools {
+				m.tools[tool] = true
+			}
+		}
+		ps.mirrors[server.Config.Name] = m
+		log.Printf("Mirroring %.0f%% of tool calls from server '%s' to '%s'", m.percent, server.Config.Name, target.Config.Name)
+	}
+	return nil
+}
+
+// mirrorToolCall sends a copy of a finished tool call to the shadow server of the tool's
+// primary server, if any, and compares the results in the background. The copy is not
+// journaled, retried or counted toward breakers, error budgets or recent calls.
+func (ps *ProxyServer) mirrorToolCall(toolName string, arguments map[string]interface{}, primaryResult *config.CallToolResult, primaryErr error, primaryDuration time.Duration) {
+	if len(ps.mirrors) == 0 {
+		return
+	}
+	primary := ps.findMCPServerByTool(toolName)
+	if primary == nil {
+		return
+	}
+	m, ok := ps.mirrors[primary.Config.Name]
+	if !ok || !m.covers(toolName) || mirrorSample() >= m.percent {
+		return
+	}
+	select {
+	case ps.mirrorSlots <- struct{}{}:
+	default:
+		log.Printf("Mirror event: tool '%s' not mirrored to '%s', %d mirrored calls already in flight", toolName, m.target.Config.Name, maxMirrorsInFlight)
+		recordMirrorOutcome(toolName, "dropped")
+		return
+	}
+
+	ps.mirrorWG.Add(1)
+	go func() {
+		defer ps.mirrorWG.Done()
+		defer func() { <-ps.mirrorSlots }()
+		ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+		defer cancel()
+		start := time.Now()
+		result, err := ps.dispatchToolCall(ctx, m.target, toolName, arguments)
+		mirrorDuration := time.Since(start)
+
+		diffs := diffToolResults(primaryResult, primaryErr, result, err)
+		outcome := "match"
+		summary := "results match"
+		if len(diffs) > 0 {
+			outcome = "diff"
+			summary = fmt.Sprintf("%d difference(s): %s", len(diffs), strings.Join(diffs, "; "))
+		}
+		log.Printf("Mirror event: tool '%s' primary '%s' %.1fms, mirror '%s' %.1fms: %s", toolName, primary.Config.Name,
+			float64(primaryDuration.Microseconds())/1000, m.target.Config.Name, float64(mirrorDuration.Microseconds())/1000, summary)
+		recordMirrorOutcome(toolName, outcome)
+		if mirrorDurationSeconds != nil { // Check if initialized
+			mirrorDurationSeconds.WithLabelValues(toolName, "primary").Observe(primaryDuration.Seconds())
+			mirrorDurationSeconds.WithLabelValues(toolName, "mirror").Observe(mirrorDuration.Seconds())
+		}
+	}()
+}
+
+// waitMirrors waits for the mirrored calls in flight to finish, for at most timeout. It
+// reports false when some did not finish in time.
+func (ps *ProxyServer) waitMirrors(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		ps.mirrorWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// recordMirrorOutcome counts a mirrored call by outcome: match, diff or dropped.
+func recordMirrorOutcome(toolName, outcome string) {
+	if mirrorCallsTotal != nil { // Check if initialized
+		mirrorCallsTotal.WithLabelValues(toolName, outcome).Inc()
+	}
+}
+
+// diffToolResults summarizes how a mirrored call differs from the primary one. Failed
+// calls are only compared by whether they failed; _meta is ignored.
+func diffToolResults(primary *config.CallToolResult, primaryErr error, shadow *config.CallToolResult, shadowErr error) []string {
+	switch {
+	case primaryErr != nil && shadowErr != nil:
+		return nil
+	case primaryErr != nil:
+		return []string{fmt.Sprintf("primary failed (%v), mirror succeeded", primaryErr)}
+	case shadowErr != nil:
+		return []string{fmt.Sprintf("mirror failed: %v", shadowErr)}
+	}
+	var diffs []string
+	diffJSON("", resultTree(primary), resultTree(shadow), &diffs)
+	if len(diffs) > maxMirrorDiffs {
+		diffs = append(diffs[:maxMirrorDiffs], "...")
+	}
+	return diffs
+}
+
+// resultTree converts a result to generic JSON values for comparison, without _meta.
+func resultTree(result *config.CallToolResult) interface{} {
+	if result == nil {
+		return nil
+	}
+	stripped := *result
+	stripped.Meta = nil
+	data, err := json.Marshal(stripped)
+	if err != nil {
+		return nil
+	}
+	var tree interface{}
+	json.Unmarshal(data, &tree)
+	return tree
+}
+
+// diffJSON appends the paths at which a and b differ, descending into objects and arrays.
+func diffJSON(path string, a, b interface{}, diffs *[]string) {
+	if len(*diffs) > maxMirrorDiffs {
+		return
+	}
+	at := func(suffix string) string {
+		if path == "" {
+			return strings.TrimPrefix(suffix, ".")
+		}
+		return path + suffix
+	}
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			*diffs = append(*diffs, fmt.Sprintf("%s: %s vs %s", at(""), jsonKind(a), jsonKind(b)))
+			return
+		}
+		keys := make([]string, 0, len(av)+len(bv))
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, ok := av[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			aChild, inA := av[k]
+			bChild, inB := bv[k]
+			switch {
+			case !inB:
+				*diffs = append(*diffs, at("."+k)+": only in primary")
+			case !inA:
+				*diffs = append(*diffs, at("."+k)+": only in mirror")
+			default:
+				diffJSON(at("."+k), aChild, bChild, diffs)
+			}
+		}
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			*diffs = append(*diffs, fmt.Sprintf("%s: %s vs %s", at(""), jsonKind(a), jsonKind(b)))
+			return
+		}
+		if len(av) != len(bv) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: %d vs %d items", at(""), len(av), len(bv)))
+		}
+		for i := 0; i < len(av) && i < len(bv); i++ {
+			diffJSON(at(fmt.Sprintf("[%d]", i)), av[i], bv[i], diffs)
+		}
+	default:
+		if jsonKind(a) != jsonKind(b) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: %s vs %s", at(""), jsonKind(a), jsonKind(b)))
+		} else if !reflect.DeepEqual(a, b) {
+			*diffs = append(*diffs, at("")+": value differs")
+		}
+	}
+}
+
+// jsonKind names the JSON type of a decoded value.
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
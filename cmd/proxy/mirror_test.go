@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testMirrorBackend starts a backend serving the "lookup" tool, answering with text
+// after delay.
+func testMirrorBackend(text string, delay time.Duration) *proxytest.Backend {
+	return proxytest.NewBackend([]proxytest.Tool{{Name: "lookup", Result: &proxytest.Result{Text: text}, Delay: delay}}, nil)
+}
+
+// TestMirrorToolCall tests that calls are copied to the shadow server without affecting
+// the client response, and that the shadow server is not routed to.
+func TestMirrorToolCall(t *testing.T) {
+	primary := testMirrorBackend("old", 0)
+	defer primary.Close()
+	shadow := testMirrorBackend("new", 200*time.Millisecond)
+	defer shadow.Close()
+
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{
+		{Name: "primary", Address: primary.URL, MirrorTo: &config.MirrorConfig{Server: "shadow", SamplePercent: 50}},
+		{Name: "shadow", Address: shadow.URL},
+	}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	require.Len(t, ps.mcpServers, 1)
+	assert.Equal(t, "primary", ps.mcpServers[0].Config.Name)
+	assert.Len(t, ps.ListTools(), 1)
+
+	origSample := mirrorSample
+	defer func() { mirrorSample = origSample }()
+
+	// Sampled in: the client gets the primary result without waiting for the shadow
+	mirrorSample = func() float64 { return 10 }
+	start := time.Now()
+	result, err := ps.CallTool("lookup", map[string]interface{}{"id": 1})
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 200*time.Millisecond)
+	assert.Equal(t, "old", *result.Content[0].Text)
+	ps.mirrorWG.Wait()
+	assert.Len(t, shadow.Calls(), 1)
+
+	// Sampled out
+	mirrorSample = func() float64 { return 60 }
+	_, err = ps.CallTool("lookup", map[string]interface{}{"id": 2})
+	require.NoError(t, err)
+	ps.mirrorWG.Wait()
+	assert.Len(t, primary.Calls(), 2)
+	assert.Len(t, shadow.Calls(), 1)
+
+	// Mirrored calls are not recorded as client calls
+	assert.Len(t, ps.recentCalls.snapshot(), 2)
+}
+
+// TestDiffToolResults tests the structural comparison of primary and mirrored results.
+func TestDiffToolResults(t *testing.T) {
+	text := func(s string) config.ContentBlock { return config.ContentBlock{Type: "text", Text: &s} }
+	a := &config.CallToolResult{Content: []config.ContentBlock{text("x")}, Meta: map[string]interface{}{"k": 1}}
+
+	assert.Empty(t, diffToolResults(a, nil, &config.CallToolResult{Content: []config.ContentBlock{text("x")}}, nil))
+	assert.Equal(t, []string{"content[0].text: value differs"},
+		diffToolResults(a, nil, &config.CallToolResult{Content: []config.ContentBlock{text("y")}}, nil))
+	assert.Equal(t, []string{"content: 1 vs 2 items"},
+		diffToolResults(a, nil, &config.CallToolResult{Content: []config.ContentBlock{text("x"), text("z")}}, nil))
+	assert.Equal(t, []string{"isError: value differs", "toolError: only in mirror"},
+		diffToolResults(a, nil, &config.CallToolResult{Content: []config.ContentBlock{text("x")}, IsError: true, ToolError: &config.ToolError{}}, nil))
+
+	assert.Empty(t, diffToolResults(nil, errors.New("down"), nil, errors.New("down")))
+	assert.Equal(t, []string{"mirror failed: down"}, diffToolResults(a, nil, nil, errors.New("down")))
+}
+
+// TestMirrorShutdown tests that shutdown waits for mirrored calls in flight, up to
+// mirrorDrainTimeout.
+func TestMirrorShutdown(t *testing.T) {
+	primary := testMirrorBackend("old", 0)
+	defer primary.Close()
+	shadow := testMirrorBackend("new", 300*time.Millisecond)
+	defer shadow.Close()
+
+	origSample, origTimeout := mirrorSample, mirrorDrainTimeout
+	defer func() { mirrorSample, mirrorDrainTimeout = origSample, origTimeout }()
+	mirrorSample = func() float64 { return 0 }
+
+	for _, tt := range []struct {
+		name    string
+		timeout time.Duration
+		waits   bool
+	}{
+		{"waits", 5 * time.Second, true},
+		{"bounded", 50 * time.Millisecond, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mirrorDrainTimeout = tt.timeout
+			ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{
+				{Name: "primary", Address: primary.URL, MirrorTo: &config.MirrorConfig{Server: "shadow", SamplePercent: 100}},
+				{Name: "shadow", Address: shadow.URL},
+			}})
+			require.NoError(t, err)
+			_, err = ps.CallTool("lookup", nil)
+			require.NoError(t, err)
+
+			start := time.Now()
+			ps.Shutdown()
+			if tt.waits {
+				assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond, "shutdown waits for the mirrored call")
+			} else {
+				assert.Less(t, time.Since(start), 250*time.Millisecond, "shutdown does not wait past the timeout")
+			}
+			ps.mirrorWG.Wait()
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"time"
+)
+
+// registerPprof mounts the net/http/pprof handlers under /debug/pprof/ on an admin mux.
+// Every handler requires the admin credentials, and requests are audit logged.
+func registerPprof(mux *http.ServeMux, admin *adminAuth) {
+	guard := func(h http.HandlerFunc) http.Handler { return requireAdminHandler(admin, h) }
+	// Index also serves the named profiles (goroutine, heap, allocs, block, mutex, ...)
+	mux.Handle("/debug/pprof/", guard(pprof.Index))
+	mux.Handle("/debug/pprof/cmdline", guard(pprof.Cmdline))
+	mux.Handle("/debug/pprof/profile", guard(pprof.Profile))
+	mux.Handle("/debug/pprof/symbol", guard(pprof.Symbol))
+	mux.Handle("/debug/pprof/trace", guard(pprof.Trace))
+}
+
+// dumpGoroutines writes the stacks of all goroutines to w, in the same format the
+// runtime prints on SIGQUIT.
+func dumpGoroutines(w io.Writer) error {
+	fmt.Fprintf(w, "=== goroutine dump at %s ===\n", time.Now().Format(time.RFC3339))
+	return runtimepprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+// dumpHeap writes a heap profile, after a garbage collection so it is up to date, to a
+// new file in dir and returns its path. The profile is binary, for `go tool pprof`, so
+// it is not written to stderr.
+func dumpHeap(dir string) (string, error) {
+	path := filepath.Join(dir, fmt.Sprintf("smart-mcp-proxy-heap-%d-%s.pprof", os.Getpid(), time.Now().Format("20060102T150405.000")))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	runtime.GC()
+	if err := runtimepprof.WriteHeapProfile(f); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// watchProfileSignal dumps all goroutine stacks to stderr, and a heap profile to a file
+// in the temporary directory, each time a profile signal (SIGUSR1) is received. Unlike
+// SIGQUIT, the proxy keeps running.
+func watchProfileSignal() {
+	if len(profileDumpSignals) == 0 {
+		return
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, profileDumpSignals...)
+	go func() {
+		for range sig {
+			if err := dumpGoroutines(os.Stderr); err != nil {
+				log.Printf("Failed to dump goroutines: %v", err)
+			}
+			if path, err := dumpHeap(os.TempDir()); err != nil {
+				log.Printf("Failed to dump heap profile: %v", err)
+			} else {
+				log.Printf("Wrote heap profile to %s", path)
+			}
+		}
+	}()
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// profileDumpSignals is empty where SIGUSR1 does not exist.
+var profileDumpSignals []os.Signal
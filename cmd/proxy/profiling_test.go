@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAdminPprof tests that the admin listener serves the pprof index behind the admin
+// token only when pprof is enabled.
+func TestAdminPprof(t *testing.T) {
+	get := func(srv *http.Server, path, token string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	admin := newAdminAuth(&config.Config{AdminToken: "secret"})
+	enabled := newAdminServer(&ProxyServer{admin: admin, pprof: true})
+	assert.Equal(t, http.StatusOK, get(enabled, "/debug/pprof/", "secret"))
+	assert.Equal(t, http.StatusOK, get(enabled, "/debug/pprof/goroutine?debug=1", "secret"))
+	assert.Equal(t, http.StatusUnauthorized, get(enabled, "/debug/pprof/", ""))
+	assert.Equal(t, http.StatusUnauthorized, get(enabled, "/debug/pprof/heap", "wrong"))
+
+	disabled := newAdminServer(&ProxyServer{admin: admin})
+	assert.Equal(t, http.StatusNotFound, get(disabled, "/debug/pprof/", "secret"))
+
+	// Never on the main proxy listener
+	_, ps, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+	ps.admin, ps.pprof = admin, true
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+	req := httptest.NewRequest("GET", "/debug/pprof/", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Served on http mode's separate admin listener
+	separate := *admin
+	separate.listen = "127.0.0.1:0"
+	ps.admin = &separate
+	httpProxy, err = NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+	require.NotNil(t, httpProxy.adminSrv)
+	assert.Equal(t, http.StatusOK, get(httpProxy.adminSrv, "/debug/pprof/", "secret"))
+	assert.Equal(t, http.StatusOK, get(httpProxy.adminSrv, "/debug/pprof/goroutine?debug=1", "secret"))
+	assert.Equal(t, http.StatusUnauthorized, get(httpProxy.adminSrv, "/debug/pprof/", ""))
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// TestDumpGoroutines tests the goroutine dump written on the profile signal.
+func TestDumpGoroutines(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, dumpGoroutines(&buf))
+	assert.Contains(t, buf.String(), "goroutine dump at")
+	assert.Contains(t, buf.String(), "TestDumpGoroutines")
+}
+
+// TestAdminPprofDefaultProfile tests that CPU profiles and traces of the admin listener's
+// 30 second write timeout or longer, including the default profile, are not refused
+// with 400; net/http/pprof extends the write deadline by the requested duration.
+func TestAdminPprofDefaultProfile(t *testing.T) {
+	admin := newAdminAuth(&config.Config{AdminToken: "secret"})
+	ts := httptest.NewUnstartedServer(nil)
+	ts.Config = newAdminServer(&ProxyServer{admin: admin, pprof: true})
+	ts.Start()
+	defer ts.Close()
+
+	for _, path := range []string{"/debug/pprof/profile", "/debug/pprof/profile?seconds=45", "/debug/pprof/trace?seconds=31"} {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		req, err := http.NewRequestWithContext(ctx, "GET", ts.URL+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			t.Errorf("%s answered %d instead of profiling", path, resp.StatusCode)
+		} else {
+			assert.ErrorIs(t, err, context.DeadlineExceeded, path)
+		}
+		cancel()
+	}
+}
+
+// TestDumpHeap tests the heap profile written on the profile signal.
+func TestDumpHeap(t *testing.T) {
+	path, err := dumpHeap(t.TempDir())
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.NotZero(t, info.Size())
+}
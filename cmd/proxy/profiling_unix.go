@@ -0,0 +1,11 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// profileDumpSignals trigger a goroutine dump to stderr and a heap profile dump.
+var profileDumpSignals = []os.Signal{syscall.SIGUSR1}
@@ -11,7 +11,10 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"smart-mcp-proxy/internal/config"
@@ -21,11 +24,86 @@ import (
 type Proxy interface {
 	Run() error
 	Shutdown(ctx context.Context) error
+	// StopReason describes why Run returned without an error, e.g. "signal: terminated".
+	StopReason() string
 }
 
 // ProxyServer holds the MCP server backends and common logic
 type ProxyServer struct {
 	mcpServers []*config.MCPServer
+	servers    *serverIndex // Lookup of mcpServers by name, tool and resource; nil scans them
+	bodyBudget *bodyBudget  // Caps memory used by buffered request bodies
+	configPath string       // Absolute path of the loaded config file; empty for env-based configs
+
+	publicBaseURL string // public_base_url for rewrite_urls; empty derives it from HTTP requests
+
+	maxHeaderBytes int // http.Server.MaxHeaderBytes in HTTP mode; zero uses the default
+	maxHeaderCount int // Header fields allowed per HTTP request; zero means no limit
+
+	trustedProxies []string // Proxies whose forwarding headers give the client IP in HTTP mode
+	maxHops        int      // Highest X-MCP-Hop-Count of a request the proxy still forwards
+
+	maxArgumentBytes int64 // Largest encoded size of tool call arguments
+	maxArgumentDepth int   // Deepest nesting of tool call arguments
+	maxMessageBytes  int64 // Largest JSON-RPC message read in command mode
+
+	restrictedNotFound bool // Answer for restricted tools and resources as for missing ones
+
+	redirectTrailingSlash bool // Redirect /tools/ to /tools in HTTP mode
+	redirectFixedPath     bool // Redirect cleaned, case-insensitive path matches in HTTP mode
+
+	toolHedging    map[string]time.Duration    // Hedge delay per tool name
+	toolRateLimits map[string]*toolRateLimiter // Call rate limits per tool name
+	admin          *adminAuth                  // Credentials of the /admin/* routes; nil disables them
+	pprof          bool                        // Serve /debug/pprof/ on the admin listener
+	ui             bool                        // Serve the web UI at /ui/ in HTTP mode
+
+	rateLimitStore      rateLimitStore // Buckets shared with other replicas; nil keeps them in toolRateLimits
+	rateLimitKeyPrefix  string
+	rateLimitFailClosed bool // Reject rate-limited calls while rateLimitStore is unreachable
+
+	toolPriority map[string]int // Listing rank of prioritized tools; lower sorts first
+	sortTools    bool           // List the tools without a rank by name
+	listLimits   map[string]int // list_limit of each server that has one
+
+	journal    *journal           // Write-ahead journal of tool calls; nil when disabled
+	resources  *resourceAnalytics // Resource access counts and recent accesses
+	deadLetter *deadLetter        // Log of failed tool calls; nil when disabled
+
+	recentCalls *callRing // Latest tool calls reported by /status
+
+	asyncTools map[string]bool // Tools always run as background jobs over HTTP
+	toolJobs   *toolJobStore   // Background tool calls polled via /tool-jobs/:id
+
+	sessions *sessionStore // Sessions of the streamable-HTTP MCP endpoint
+
+	commandClient *commandClient // Command mode client reported by /status; nil in HTTP mode
+
+	sseQueueSize int           // Events queued per /mcp SSE stream
+	sseOverflow  string        // What a full SSE queue does: drop its oldest event or disconnect
+	sseHeartbeat time.Duration // Interval of SSE heartbeats, and the time a write to a stream may take
+
+	errorBudget *errorBudget // Per-server error rate tracking; nil when disabled
+	slo         *sloTracker  // Rolling compliance with each server's slo_ms
+
+	resultMeta      bool // Add smartproxy/* keys to tool result _meta
+	mapToolErrors   bool // Answer HTTP tool calls with isError results with toolErrorStatus
+	validateResults bool // Check structuredContent against the tool's outputSchema
+	exactNumbers    bool // Decode request numbers as json.Number rather than float64
+	lenientJSONRPC  bool // Accept client requests without a jsonrpc member
+
+	shadowServers []*config.MCPServer // mirror_to targets; not used for routing
+	mirrors       map[string]*mirror  // Shadow server per primary server name
+	mirrorSlots   chan struct{}       // Caps mirrored calls in flight
+	mirrorWG      sync.WaitGroup      // Mirrored calls in flight
+
+	breakers map[string]*circuitBreaker // Circuit breakers keyed by server name
+
+	events *eventBus // Backend and discovery events for internal subscribers
+
+	ecsLog *ecsLogger // Access log, tool calls and server events under log_schema "ecs"; nil logs text
+
+	shutdownOnce sync.Once
 }
 
 // Define sentinel errors for tool call failures
@@ -33,46 +111,312 @@ var (
 	ErrToolNotFound         = errors.New("tool not found or not provided by any configured server")
 	ErrBackendCommunication = errors.New("error communicating with or parsing response from backend server")
 	ErrInternalProxy        = errors.New("internal server error processing tool call")
+	ErrInvalidResult        = errors.New("backend server returned a result that does not match the tool's outputSchema")
 )
 
 // RestrictedToolInfo adds ServerName to ToolInfo
 type RestrictedToolInfo struct {
 	config.ToolInfo
+	ServerName   string `json:"serverName"`
+	Filter       string `json:"filter,omitempty"`       // e.g. "allowed_tools" or "strict_schemas"
+	RestrictedBy string `json:"restrictedBy,omitempty"` // e.g. config.RestrictedByServerAllowlist
+
+	ChainedServer string `json:"chainedServer,omitempty"` // Server providing it on a chained proxy
+}
+
+// RefreshResult reports the outcome of refreshing a single MCP server's tools and resources.
+type RefreshResult struct {
 	ServerName string `json:"serverName"`
+	Tools      int    `json:"tools"`
+	Resources  int    `json:"resources"`
+	Error      string `json:"error,omitempty"`
 }
 
-// RestrictedResourceInfo adds ServerName to ResourceInfo
+// ServerDetail describes a configured MCP server. Env is deliberately omitted as it may hold secrets.
+type ServerDetail struct {
+	Name             string   `json:"name"`
+	Address          string   `json:"address,omitempty"`
+	Command          string   `json:"command,omitempty"`
+	ResolvedCommand  string   `json:"resolvedCommand,omitempty"`
+	Args             []string `json:"args,omitempty"`
+	WorkingDir       string   `json:"workingDir,omitempty"`
+	AllowedTools     []string `json:"allowedTools,omitempty"`
+	AllowedToolsFrom string   `json:"allowedToolsFrom,omitempty"`
+	AllowedResources []string `json:"allowedResources,omitempty"`
+	MatchMode        string   `json:"matchMode"` // How the allow-lists match names
+	Tools            int      `json:"tools"`
+	Resources        int      `json:"resources"`
+
+	Server *config.ServerMetadata `json:"server,omitempty"` // Display metadata, when known
+}
+
+// ToolDetail is a single allowed tool and the server that provides it.
+type ToolDetail struct {
+	config.ToolInfo
+	ServerName string                 `json:"serverName"`
+	Server     *config.ServerMetadata `json:"server,omitempty"`
+}
+
+// ResourceDetail is a single allowed resource and the server that provides it.
+type ResourceDetail struct {
+	config.ResourceInfo
+	ServerName string                 `json:"serverName"`
+	Server     *config.ServerMetadata `json:"server,omitempty"`
+}
+
+// ListedTool is a tool in the HTTP tool listing with its server's name and display
+// metadata.
+type ListedTool struct {
+	config.ToolInfo
+	ServerName string                 `json:"serverName"`
+	Server     *config.ServerMetadata `json:"server,omitempty"`
+}
+
+// ListedResource is a resource in the HTTP resource listing with its server's name and
+// display metadata.
+type ListedResource struct {
+	config.ResourceInfo
+	ServerName string                 `json:"serverName"`
+	Server     *config.ServerMetadata `json:"server,omitempty"`
+}
+
+// RestrictedResourceInfo adds ServerName and the filter that hid it to ResourceInfo
 type RestrictedResourceInfo struct {
 	config.ResourceInfo
-	ServerName string `json:"serverName"`
+	ServerName   string `json:"serverName"`
+	Filter       string `json:"filter,omitempty"`       // e.g. "allowed_resources" or "denied_mime_types"
+	RestrictedBy string `json:"restrictedBy,omitempty"` // e.g. config.RestrictedByServerDenylist
+
+	ChainedServer string `json:"chainedServer,omitempty"` // Server providing it on a chained proxy
 }
 
-// NewProxyServer creates a new ProxyServer instance with initialized MCP servers
-func NewProxyServer(cfg *config.Config) (*ProxyServer, error) {
+// NewProxyServer creates a new ProxyServer instance with initialized MCP servers.
+// Errors starting the servers wrap ErrBackendStartup; any other error comes from the
+// configuration, wraps ErrConfig, and stops the servers that were already started.
+func NewProxyServer(cfg *config.Config) (_ *ProxyServer, err error) {
 	servers, err := config.NewMCPServers(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize MCP servers: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrBackendStartup, err)
 	}
 
 	ps := &ProxyServer{
-		mcpServers: servers,
+		mcpServers:     servers,
+		bodyBudget:     newBodyBudget(cfg.MaxBufferedBytes),
+		configPath:     cfg.Path,
+		publicBaseURL:  strings.TrimSuffix(cfg.PublicBaseURL, "/"),
+		toolHedging:    make(map[string]time.Duration),
+		toolRateLimits: make(map[string]*toolRateLimiter),
+		admin:          newAdminAuth(cfg),
+		pprof:          cfg.Pprof,
+		ui:             cfg.UI != nil && cfg.UI.Enabled,
+		breakers:       make(map[string]*circuitBreaker),
+		events:         newEventBus(),
+
+		maxHeaderBytes: cfg.MaxHeaderBytes,
+		maxHeaderCount: cfg.MaxHeaderCount,
+		trustedProxies: cfg.TrustedProxies,
+		maxHops:        cfg.MaxHopsOrDefault(),
+
+		maxArgumentBytes: cfg.MaxArgumentBytesOrDefault(),
+		maxArgumentDepth: cfg.MaxArgumentDepthOrDefault(),
+		maxMessageBytes:  cfg.MaxMessageBytesOrDefault(),
+
+		restrictedNotFound: cfg.RestrictedStatusOrDefault() == config.RestrictedStatusNotFound,
+
+		redirectTrailingSlash: cfg.RedirectTrailingSlash,
+		redirectFixedPath:     cfg.RedirectFixedPath,
+
+		toolPriority: buildToolPriority(cfg),
+		sortTools:    cfg.SortTools,
+		listLimits:   buildListLimits(cfg),
+		recentCalls:  newCallRing(recentCallsSize),
+		resources:    newResourceAnalytics(recentResourceAccessesSize),
+		slo:          newSLOTracker(),
+		resultMeta:   cfg.ResultMeta,
+
+		validateResults: cfg.ValidateResults,
+		mapToolErrors:   cfg.MapToolErrorsToStatus,
+		exactNumbers:    cfg.JSONNumbersOrDefault() == config.JSONNumbersExact,
+		lenientJSONRPC:  cfg.LenientJSONRPC,
+	}
+	defer func() {
+		if err != nil {
+			ps.Shutdown()
+			err = fmt.Errorf("%w: %w", ErrConfig, err)
+		}
+	}()
+	for _, server := range servers {
+		if server.Config.CircuitBreaker == nil {
+			continue
+		}
+		breaker, err := newCircuitBreaker(*server.Config.CircuitBreaker)
+		if err != nil {
+			return nil, fmt.Errorf("invalid circuit breaker for server '%s': %w", server.Config.Name, err)
+		}
+		ps.breakers[server.Config.Name] = breaker
+	}
+	for tool, hedge := range cfg.ToolHedging {
+		delay, err := hedge.DelayDuration()
+		if err != nil {
+			return nil, fmt.Errorf("invalid hedging delay for tool '%s': %w", tool, err)
+		}
+		ps.toolHedging[tool] = delay
+	}
+	for tool, limit := range cfg.ToolRateLimits {
+		ps.toolRateLimits[tool] = newToolRateLimiter(limit)
+	}
+	if ps.rateLimitStore, err = newRateLimitStore(cfg.RateLimitStore); err != nil {
+		return nil, err
+	}
+	if store := cfg.RateLimitStore; store != nil {
+		ps.rateLimitKeyPrefix = store.KeyPrefixOrDefault()
+		ps.rateLimitFailClosed = store.OnFailureOrDefault() == config.RateLimitStoreFailClosed
+	}
+	jobTTL, err := cfg.ToolJobTTLDuration()
+	if err != nil {
+		return nil, fmt.Errorf("invalid tool_job_ttl: %w", err)
+	}
+	ps.toolJobs = newToolJobStore(jobTTL)
+	go ps.toolJobs.runSweeps(min(jobTTL, time.Minute))
+	if ps.sessions, err = sessionsFromConfig(cfg); err != nil {
+		return nil, err
+	}
+	go ps.sessions.runSweeps(sessionSweepInterval)
+	sse := config.SSEConfig{}
+	if cfg.SSE != nil {
+		sse = *cfg.SSE
+	}
+	ps.sseQueueSize, ps.sseOverflow = sse.QueueSizeOrDefault(), sse.OverflowOrDefault()
+	if ps.sseHeartbeat, err = sse.HeartbeatIntervalDuration(); err != nil {
+		return nil, fmt.Errorf("invalid sse.heartbeat_interval: %w", err)
+	}
+	ps.asyncTools = make(map[string]bool, len(cfg.AsyncTools))
+	for _, tool := range cfg.AsyncTools {
+		ps.asyncTools[tool] = true
+	}
+	if cfg.Journal != nil {
+		j, err := openJournal(*cfg.Journal)
+		if err != nil {
+			return nil, err
+		}
+		ps.journal = j
+	}
+	if cfg.DeadLetterFile != "" {
+		d, err := openDeadLetter(cfg.DeadLetterFile, cfg.DeadLetterMaxBytesOrDefault())
+		if err != nil {
+			return nil, err
+		}
+		ps.deadLetter = d
+	}
+	if cfg.ErrorBudget != nil {
+		eb, err := newErrorBudget(*cfg.ErrorBudget)
+		if err != nil {
+			return nil, fmt.Errorf("invalid error_budget: %w", err)
+		}
+		ps.errorBudget = eb
+	}
+	if err := ps.setupMirrors(cfg); err != nil {
+		return nil, err
+	}
+	ps.servers = newServerIndex(ps.mcpServers, newResourceResolver(cfg))
+	if cfg.LogSchemaOrDefault() == config.LogSchemaECS {
+		ps.ecsLog = stderrECSLog
+	}
+	for _, server := range append(append([]*config.MCPServer{}, ps.mcpServers...), ps.shadowServers...) {
+		server.SetEventHandler(ps.handleServerEvent)
+		server.RequestHeaders = func(ctx context.Context, header http.Header) {
+			setClientIdentity(header, server, requestClient(ctx))
+		}
 	}
 	return ps, nil
 }
 
-// Shutdown gracefully shuts down all MCP servers.
+// buildToolPriority ranks the tools named in the global tool_priority list, followed by
+// those in each server's list in config order. A tool keeps its first (highest) rank.
+func buildToolPriority(cfg *config.Config) map[string]int {
+	ranks := make(map[string]int)
+	add := func(names []string) {
+		for _, name := range names {
+			if _, ok := ranks[name]; !ok {
+				ranks[name] = len(ranks)
+			}
+		}
+	}
+	add(cfg.ToolPriority)
+	for _, server := range cfg.MCPServers {
+		add(server.ToolPriority)
+	}
+	return ranks
+}
+
+// Shutdown gracefully shuts down all MCP servers. Calls after the first do nothing.
 func (ps *ProxyServer) Shutdown() {
+	ps.shutdownOnce.Do(ps.shutdown)
+}
+
+func (ps *ProxyServer) shutdown() {
 	log.Println("Shutting down proxy server...")
-	for _, server := range ps.mcpServers {
+	// Mirrored calls in flight finish first, so their shadow and primary servers are up
+	if !ps.waitMirrors(mirrorDrainTimeout) {
+		log.Printf("Mirrored calls still in flight after %s; shutting down anyway", mirrorDrainTimeout)
+	}
+	// Shadow servers only receive mirrored calls and go first
+	servers := append(append([]*config.MCPServer{}, ps.shadowServers...), ps.shutdownOrder()...)
+	for _, server := range servers {
 		if err := server.Shutdown(); err != nil {
 			log.Printf("Error shutting down MCP server %s: %v", server.Config.Name, err)
 		}
 	}
+	ps.events.close()
+	if ps.toolJobs != nil {
+		ps.toolJobs.close()
+	}
+	if ps.sessions != nil {
+		ps.sessions.close()
+	}
+	if ps.rateLimitStore != nil {
+		ps.rateLimitStore.close()
+	}
+	if ps.journal != nil {
+		if err := ps.journal.Close(); err != nil {
+			log.Printf("Error closing journal: %v", err)
+		}
+	}
+	if ps.deadLetter != nil {
+		if err := ps.deadLetter.Close(); err != nil {
+			log.Printf("Error closing dead-letter file: %v", err)
+		}
+	}
 	log.Println("Proxy server shutdown complete.")
 }
 
+// shutdownOrder returns the servers in reverse dependency order, so that a server is
+// shut down before the servers it depends on.
+func (ps *ProxyServer) shutdownOrder() []*config.MCPServer {
+	configs := make([]config.MCPServerConfig, len(ps.mcpServers))
+	for i, server := range ps.mcpServers {
+		configs[i] = server.Config
+	}
+	names, err := config.DependencyOrder(configs)
+	if err != nil {
+		// Validation rejects cycles; fall back to reverse configuration order.
+		names = names[:0]
+		for _, sc := range configs {
+			names = append(names, sc.Name)
+		}
+	}
+	ordered := make([]*config.MCPServer, 0, len(names))
+	for i := len(names) - 1; i >= 0; i-- {
+		ordered = append(ordered, ps.findMCPServerByName(names[i]))
+	}
+	return ordered
+}
+
 // findMCPServerByName finds an MCP server by its name.
 func (ps *ProxyServer) findMCPServerByName(name string) *config.MCPServer {
+	if ps.servers != nil {
+		return ps.servers.server(name)
+	}
 	for _, server := range ps.mcpServers {
 		if server.Config.Name == name {
 			return server
@@ -81,102 +425,406 @@ func (ps *ProxyServer) findMCPServerByName(name string) *config.MCPServer {
 	return nil
 }
 
-// findMCPServerByTool finds the MCP server that allows the given tool
+// findMCPServerByTool finds the MCP server that allows the given tool, preferring a
+// healthy one. An unhealthy server is only returned when no healthy server provides it.
+// Servers that discovered the tool are found in the index; a name no server discovered,
+// e.g. one matched under match_mode, is checked against every allow-list.
 func (ps *ProxyServer) findMCPServerByTool(toolName string) *config.MCPServer {
-	for _, server := range ps.mcpServers {
-		if server.IsToolAllowed(toolName) {
-			return server
-		}
+	if servers := ps.servers.toolServers(toolName); len(servers) > 0 {
+		return ps.pickServer(servers, nil)
 	}
-	return nil
+	return ps.pickServer(ps.mcpServers, func(server *config.MCPServer) bool { return server.IsToolAllowed(toolName) })
 }
 
-// findMCPServerByResource finds the MCP server that allows the given resource
+// findMCPServerByResource finds the MCP server that allows the given resource, preferring
+// a healthy one like findMCPServerByTool. A discovered resource is only found on the
+// servers owning its URI under resource_conflicts.
 func (ps *ProxyServer) findMCPServerByResource(resourceName string) *config.MCPServer {
-	for _, server := range ps.mcpServers {
-		if server.IsResourceAllowed(resourceName) {
+	if servers, ok := ps.servers.resourceServers(resourceName); ok {
+		return ps.pickServer(servers, nil)
+	}
+	return ps.pickServer(ps.mcpServers, func(server *config.MCPServer) bool { return server.IsResourceAllowed(resourceName) })
+}
+
+// pickServer returns the first healthy server in servers that provides, falling back to
+// the first one that provides when none of them is healthy. A nil provides accepts
+// every server.
+func (ps *ProxyServer) pickServer(servers []*config.MCPServer, provides func(*config.MCPServer) bool) *config.MCPServer {
+	var fallback *config.MCPServer
+	for _, server := range servers {
+		if provides != nil && !provides(server) {
+			continue
+		}
+		if ps.serverHealthy(server) {
 			return server
 		}
+		if fallback == nil {
+			fallback = server
+		}
 	}
-	return nil
+	return fallback
 }
 
-// ListTools collects ToolInfo from all MCP servers.
+// serverHealthy reports whether a server should receive traffic: it is not restarting,
+// passes its health checks, its circuit breaker is not rejecting calls and it is within
+// its error budget.
+func (ps *ProxyServer) serverHealthy(server *config.MCPServer) bool {
+	if server.IsRestarting() || !server.Healthy() {
+		return false
+	}
+	if breaker, ok := ps.breakers[server.Config.Name]; ok && breaker.rejecting() {
+		return false
+	}
+	if ps.errorBudget != nil {
+		if degraded, _ := ps.errorBudget.state(server.Config.Name); degraded {
+			return false
+		}
+	}
+	return true
+}
+
+// RefreshServers synchronously refreshes the tools and resources of every MCP server,
+// or only the named one when serverName is non-empty.
+func (ps *ProxyServer) RefreshServers(serverName string) ([]RefreshResult, error) {
+	servers := ps.mcpServers
+	if serverName != "" {
+		server := ps.findMCPServerByName(serverName)
+		if server == nil {
+			return nil, fmt.Errorf("server '%s' not found", serverName)
+		}
+		servers = []*config.MCPServer{server}
+	}
+
+	results := make([]RefreshResult, 0, len(servers))
+	for _, server := range servers {
+		result := RefreshResult{ServerName: server.Config.Name}
+		if err := server.Refresh(); err != nil {
+			log.Printf("Error refreshing tools/resources for MCP server %s: %v", server.Config.Name, err)
+			result.Error = err.Error()
+		}
+		result.Tools = len(server.GetTools())
+		result.Resources = len(server.GetResources())
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// DescribeServer returns details for the named MCP server, or nil if it does not exist.
+func (ps *ProxyServer) DescribeServer(name string) *ServerDetail {
+	server := ps.findMCPServerByName(name)
+	if server == nil {
+		return nil
+	}
+	return &ServerDetail{
+		Name:             server.Config.Name,
+		Address:          server.Config.Address,
+		Command:          server.Config.Command,
+		ResolvedCommand:  server.Config.ResolvedCommand,
+		Args:             server.Config.Args,
+		WorkingDir:       server.Config.WorkingDirPath(),
+		AllowedTools:     server.AllowedTools(),
+		AllowedToolsFrom: server.Config.AllowedToolsFrom,
+		AllowedResources: server.Config.AllowedResources,
+		MatchMode:        server.Config.MatchModeOrDefault(),
+		Tools:            len(server.GetTools()),
+		Resources:        len(server.GetResources()),
+		Server:           server.Metadata(),
+	}
+}
+
+// ListServers returns details for every configured MCP server in configuration order.
+func (ps *ProxyServer) ListServers() []ServerDetail {
+	servers := make([]ServerDetail, 0, len(ps.mcpServers))
+	for _, server := range ps.mcpServers {
+		servers = append(servers, *ps.DescribeServer(server.Config.Name))
+	}
+	return servers
+}
+
+// ListTools collects ToolInfo from all MCP servers. Tools of lazy_schemas servers carry
+// a placeholder input schema, since MCP clients require one.
 func (ps *ProxyServer) ListTools() []config.ToolInfo {
-	allTools := []config.ToolInfo{}
+	listed := ps.ListToolsWithMetadata()
+	allTools := make([]config.ToolInfo, len(listed))
+	for i, tool := range listed {
+		allTools[i] = config.WithPlaceholderSchema(tool.ToolInfo)
+	}
+	return allTools
+}
+
+// ListToolsWithMetadata collects the tools of all MCP servers, up to each server's
+// list_limit, with each server's display metadata, in listing order.
+func (ps *ProxyServer) ListToolsWithMetadata() []ListedTool {
+	allTools := []ListedTool{}
 	for _, server := range ps.mcpServers {
-		tools := server.GetTools()
-		allTools = append(allTools, tools...)
+		meta := server.Metadata()
+		for _, tool := range ps.listedTools(server) {
+			allTools = append(allTools, ListedTool{ToolInfo: tool, ServerName: server.Config.Name, Server: meta})
+		}
+	}
+	if len(ps.toolPriority) == 0 && !ps.sortTools {
+		return allTools
 	}
+	// Prioritized tools lead in their configured order. The rest keep server order
+	// unless sort_tools lists them by name; the sort is stable so replicas of a tool
+	// keep server config order.
+	sort.SliceStable(allTools, func(i, j int) bool {
+		ri, iPrioritized := ps.toolPriority[allTools[i].Name]
+		rj, jPrioritized := ps.toolPriority[allTools[j].Name]
+		switch {
+		case iPrioritized && jPrioritized:
+			return ri < rj
+		case iPrioritized != jPrioritized:
+			return iPrioritized
+		default:
+			return ps.sortTools && allTools[i].Name < allTools[j].Name
+		}
+	})
 	return allTools
 }
 
+// DescribeTool returns the named tool from the server that serves it, or nil if no
+// server allows and provides it.
+func (ps *ProxyServer) DescribeTool(toolName string) *ToolDetail {
+	server := ps.findMCPServerByTool(toolName)
+	if server == nil {
+		return nil
+	}
+	for _, tool := range server.GetTools() {
+		if tool.Name == toolName {
+			return &ToolDetail{ToolInfo: tool, ServerName: server.Config.Name, Server: server.Metadata()}
+		}
+	}
+	return nil
+}
+
 // ListRestrictedTools collects RestrictedToolInfo from all MCP servers.
 func (ps *ProxyServer) ListRestrictedTools() []RestrictedToolInfo {
 	allTools := []RestrictedToolInfo{}
 	for _, server := range ps.mcpServers {
 		tools := server.GetRestrictedTools()
 		for _, tool := range tools {
-			allTools = append(allTools, RestrictedToolInfo{ToolInfo: tool, ServerName: server.Config.Name})
+			allTools = append(allTools, RestrictedToolInfo{
+				ToolInfo:     tool,
+				ServerName:   server.Config.Name,
+				Filter:       tool.RestrictedBy,
+				RestrictedBy: config.RestrictionReason(tool.RestrictedBy),
+
+				ChainedServer: tool.ChainedServer,
+			})
 		}
 	}
 	return allTools
 }
 
-// ListResources collects ResourceInfo from all MCP servers.
+// ListResources collects ResourceInfo from all MCP servers, up to each server's
+// list_limit.
 func (ps *ProxyServer) ListResources() []config.ResourceInfo {
+	owners, _ := ps.resourceOwners()
 	allResources := []config.ResourceInfo{}
 	for _, server := range ps.mcpServers {
-		resources := server.GetResources()
-		allResources = append(allResources, resources...)
+		allResources = append(allResources, ps.listedResources(owners, server)...)
+	}
+	return allResources
+}
+
+// ListResourcesWithMetadata collects the resources of all MCP servers with each
+// server's display metadata.
+func (ps *ProxyServer) ListResourcesWithMetadata() []ListedResource {
+	owners, _ := ps.resourceOwners()
+	allResources := []ListedResource{}
+	for _, server := range ps.mcpServers {
+		meta := server.Metadata()
+		for _, resource := range ps.listedResources(owners, server) {
+			allResources = append(allResources, ListedResource{ResourceInfo: resource, ServerName: server.Config.Name, Server: meta})
+		}
 	}
 	return allResources
 }
 
+// DescribeResource returns the named resource from the server that serves it, or nil
+// if no server allows and provides it.
+func (ps *ProxyServer) DescribeResource(resourceName string) *ResourceDetail {
+	server := ps.findMCPServerByResource(resourceName)
+	if server == nil {
+		return nil
+	}
+	for _, resource := range server.GetResources() {
+		if resource.Name == resourceName {
+			return &ResourceDetail{ResourceInfo: resource, ServerName: server.Config.Name, Server: server.Metadata()}
+		}
+	}
+	return nil
+}
+
 // ListRestrictedResources collects RestrictedResourceInfo from all MCP servers.
 func (ps *ProxyServer) ListRestrictedResources() []RestrictedResourceInfo {
 	allResources := []RestrictedResourceInfo{}
 	for _, server := range ps.mcpServers {
 		resources := server.GetRestrictedResources()
 		for _, resource := range resources {
-			allResources = append(allResources, RestrictedResourceInfo{ResourceInfo: resource, ServerName: server.Config.Name})
+			allResources = append(allResources, RestrictedResourceInfo{
+				ResourceInfo: resource,
+				ServerName:   server.Config.Name,
+				Filter:       resource.RestrictedBy,
+				RestrictedBy: config.RestrictionReason(resource.RestrictedBy),
+
+				ChainedServer: resource.ChainedServer,
+			})
 		}
 	}
 	return allResources
 }
 
 // CallTool handles the logic for executing a tool call on the appropriate backend MCP server.
+// When the journal is enabled, the call is recorded before dispatch and marked completed or
+// failed afterwards. A journal write failure is logged but does not block the call.
+// Failed calls are also appended to the dead-letter file when one is configured.
 func (ps *ProxyServer) CallTool(toolName string, arguments map[string]interface{}) (*config.CallToolResult, error) {
-	server := ps.findMCPServerByTool(toolName)
+	return ps.CallToolWithMeta(toolName, arguments, nil)
+}
+
+// CallToolWithMeta is CallTool with the request's _meta, which is forwarded to the backend.
+func (ps *ProxyServer) CallToolWithMeta(toolName string, arguments map[string]interface{}, meta map[string]interface{}) (*config.CallToolResult, error) {
+	return ps.CallToolFrom("", 0, toolName, arguments, meta)
+}
+
+// CallToolFrom is CallToolWithMeta for a call made by client (the client IP in HTTP
+// mode), which per-client tool_rate_limits are keyed by. hops is the request's
+// X-MCP-Hop-Count, which HTTP backends receive incremented.
+func (ps *ProxyServer) CallToolFrom(client string, hops int, toolName string, arguments, meta map[string]interface{}) (*config.CallToolResult, error) {
+	return ps.callToolFrom(config.WithHopCount(context.Background(), hops), client, toolName, arguments, meta)
+}
+
+// callToolFrom is CallToolFrom with a context carrying the hop count and, for calls in
+// a session, its server affinity.
+func (ps *ProxyServer) callToolFrom(ctx context.Context, client string, toolName string, arguments, meta map[string]interface{}) (*config.CallToolResult, error) {
+	if err := ps.checkArgumentLimits(arguments); err != nil {
+		return nil, err
+	}
+	if err := ps.checkToolRateLimit(toolName, client); err != nil {
+		return nil, err
+	}
+	return ps.callToolRecorded(ctx, client, toolName, arguments, meta)
+}
+
+// callToolRecorded calls a tool and records the call in /status, the dead-letter log
+// and the mirror.
+func (ps *ProxyServer) callToolRecorded(ctx context.Context, client string, toolName string, arguments, meta map[string]interface{}) (*config.CallToolResult, error) {
+	start := time.Now()
+	ctx = withRequestClient(withRequestMeta(ctx, meta), client)
+	result, err := ps.callToolJournaled(ctx, toolName, arguments)
+	duration := time.Since(start)
+	rec := ToolCallRecord{Time: start, Tool: toolName, Client: client, DurationMs: float64(duration.Microseconds()) / 1000}
+	if err != nil {
+		rec.Error = err.Error()
+		ps.recordDeadLetter(start, client, toolName, arguments, err)
+	}
+	ps.recentCalls.record(rec)
+	ps.logToolCall(rec, duration)
+	ps.mirrorToolCall(toolName, arguments, result, err, duration)
+	return result, err
+}
+
+// callToolJournaled wraps callTool with the write-ahead journal when it is enabled.
+func (ps *ProxyServer) callToolJournaled(ctx context.Context, toolName string, arguments map[string]interface{}) (*config.CallToolResult, error) {
+	if ps.journal == nil || ps.findMCPServerByTool(toolName) == nil {
+		return ps.callTool(ctx, toolName, arguments)
+	}
+
+	id, err := ps.journal.begin(toolName, arguments)
+	if err != nil {
+		log.Printf("Failed to journal call to tool '%s': %v", toolName, err)
+		return ps.callTool(ctx, toolName, arguments)
+	}
+	result, callErr := ps.callTool(ctx, toolName, arguments)
+	if err := ps.journal.finish(id, callErr); err != nil {
+		log.Printf("Failed to journal result of tool '%s': %v", toolName, err)
+	}
+	return result, callErr
+}
+
+// callTool dispatches a tool call without journaling it.
+func (ps *ProxyServer) callTool(ctx context.Context, toolName string, arguments map[string]interface{}) (*config.CallToolResult, error) {
+	server := ps.findToolServer(ctx, toolName)
 	if server == nil {
-		// Return the specific sentinel error
-		return nil, fmt.Errorf("%w: %s", ErrToolNotFound, toolName)
+		return nil, ps.toolNotServed(toolName)
 	}
 
-	log.Printf("Calling tool '%s' on server '%s' (%s)", toolName, server.Config.Name, server.Config.Address)
+	// Hedge read-only tools that are served by more than one server
+	if delay, ok := ps.toolHedging[toolName]; ok {
+		if replicas := ps.findHedgeableReplicas(toolName); len(replicas) > 1 {
+			// The result is checked against the schema of the replica that answered
+			result, answered, err := ps.callToolHedged(ctx, toolName, arguments, replicas, delay)
+			return ps.checkToolResult(ctx, answered, toolName, result, err)
+		}
+	}
 
+	result, err := ps.callToolOnServer(ctx, server, toolName, arguments)
+	return ps.checkToolResult(ctx, server, toolName, result, err)
+}
+
+// checkToolResult validates a successful result's structuredContent against the tool's
+// outputSchema when validate_results is enabled. Tool errors (isError) are not checked.
+func (ps *ProxyServer) checkToolResult(ctx context.Context, server *config.MCPServer, toolName string, result *config.CallToolResult, err error) (*config.CallToolResult, error) {
+	if !ps.validateResults || err != nil || result == nil || result.IsError {
+		return result, err
+	}
+	schema := server.OutputSchema(toolName)
+	if schema == nil {
+		return result, nil
+	}
+	if result.StructuredContent == nil {
+		log.Printf("Tool '%s' on server '%s' returned no structuredContent despite declaring an outputSchema, for client '%s'", toolName, server.Config.Name, logClient(ctx))
+		return nil, fmt.Errorf("%w: tool '%s' returned no structuredContent", ErrInvalidResult, toolName)
+	}
+	if verr := schema.Validate(result.StructuredContent); verr != nil {
+		log.Printf("Tool '%s' on server '%s' returned a result that does not match its outputSchema, for client '%s': %v", toolName, server.Config.Name, logClient(ctx), verr)
+		return nil, fmt.Errorf("%w: tool '%s': %v", ErrInvalidResult, toolName, verr)
+	}
+	return result, nil
+}
+
+// dispatchToolCall sends a single tool call attempt to a specific server based on its transport.
+func (ps *ProxyServer) dispatchToolCall(ctx context.Context, server *config.MCPServer, toolName string, arguments map[string]interface{}) (*config.CallToolResult, error) {
+	client := logClient(ctx)
+	log.Printf("Calling tool '%s' on server '%s' (%s) for client '%s'", toolName, server.Config.Name, server.Config.Address, client)
+	meta := requestMeta(ctx)
+
+	if server.Config.StaticTool(toolName) != nil {
+		// Served by the proxy itself, by running the tool's command
+		result, err := server.CallStaticTool(ctx, toolName, arguments)
+		if err != nil {
+			log.Printf("Error executing static tool '%s' on server '%s' for client '%s': %v", toolName, server.Config.Name, client, err)
+			return nil, fmt.Errorf("%w: failed to execute static tool '%s': %w", ErrBackendCommunication, toolName, err)
+		}
+		return result, nil
+	}
 	if server.Config.Command != "" {
+		if server.IsRestarting() {
+			return nil, throttled(throttleRestarting, restartRetryAfter, fmt.Errorf("%w: %s", ErrBackendRestarting, server.Config.Name))
+		}
 		// Handle stdio-based tool call
-		return ps.callStdioTool(server, toolName, arguments)
+		return ps.callStdioTool(server, client, toolName, arguments, meta)
+	}
+	if server.UsesJSONRPC() {
+		// Handle JSON-RPC-over-HTTP tool call
+		return ps.callJSONRPCTool(ctx, server, toolName, arguments, meta)
 	}
 	// Handle HTTP-based tool call
-	return ps.callHttpTool(server, toolName, arguments)
-
+	return ps.callHttpTool(ctx, server, toolName, argumentsWithMeta(arguments, meta))
 }
 
-// callStdioTool executes a tool call on a stdio-based MCP server.
-func (ps *ProxyServer) callStdioTool(server *config.MCPServer, toolName string, arguments map[string]interface{}) (*config.CallToolResult, error) {
-	// Construct the request payload expected by the stdio server for a tool call.
-	// This might vary based on the server's implementation, but a common pattern
-	// is a JSON object with method and params.
-	// Assuming a generic JSON-RPC like structure for the backend call:
+// callStdioTool executes a tool call on a stdio-based MCP server for client, as logged.
+func (ps *ProxyServer) callStdioTool(server *config.MCPServer, client, toolName string, arguments, meta map[string]interface{}) (*config.CallToolResult, error) {
+	// Send an MCP tools/call request; the method can be changed with stdio_tool_method
+	// for backends that use another name, but the params keep the MCP shape
+	id := server.NextRPCID()
 	backendRequest := map[string]interface{}{
-		// Adjust "method" if the backend expects something different (e.g., just the tool name)
-		"method": toolName, // Or perhaps a specific method like "call_tool"
-		"params": arguments,
-		// Add other necessary fields like jsonrpc version or id if required by the backend
-		// "jsonrpc": "2.0",
-		// "id": some_unique_id, // Generating a unique ID might be needed
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  server.Config.StdioToolMethodOrDefault(),
+		"params":  config.CallToolRequestParams{Name: toolName, Arguments: arguments, Meta: meta},
 	}
 
 	reqBytes, err := json.Marshal(backendRequest)
@@ -189,33 +837,26 @@ func (ps *ProxyServer) callStdioTool(server *config.MCPServer, toolName string,
 	// Use the existing HandleStdioRequest logic
 	respBytes, err := server.HandleStdioRequest(reqBytes)
 	if err != nil {
-		log.Printf("Error executing stdio tool call '%s' on server '%s': %v", toolName, server.Config.Name, err)
+		log.Printf("Error executing stdio tool call '%s' (id %d) on server '%s' for client '%s': %v", toolName, id, server.Config.Name, client, err)
 		// Wrap the original error with ErrBackendCommunication
 		return nil, fmt.Errorf("%w: failed to execute stdio tool '%s': %v", ErrBackendCommunication, toolName, err)
 	}
 
-	// Parse the response from the stdio server.
-	// Assume the response body directly contains the CallToolResult structure or can be unmarshalled into it.
-	var toolResult config.CallToolResult
-	if err := json.Unmarshal(respBytes, &toolResult); err != nil {
-		// Log the raw response for debugging if unmarshalling fails
-		log.Printf("Error unmarshalling stdio tool call response for '%s' from server '%s'. Raw response: %s. Error: %v", toolName, server.Config.Name, string(respBytes), err)
-		// Attempt to parse as a generic error structure if possible
-		var genericError map[string]interface{}
-		if json.Unmarshal(respBytes, &genericError) == nil {
-			// Wrap the generic error info with ErrBackendCommunication
-			return nil, fmt.Errorf("%w: stdio tool '%s' execution failed: %v", ErrBackendCommunication, toolName, genericError)
-		}
-		// If it's not even JSON, wrap the unmarshal error with ErrBackendCommunication
-		return nil, fmt.Errorf("%w: failed to parse response from stdio tool '%s': %v", ErrBackendCommunication, toolName, err)
+	// Parse the response from the stdio server: a CallToolResult, bare or as a JSON-RPC result
+	toolResult, err := parseStdioToolResult(toolName, respBytes)
+	if err != nil {
+		// Log the raw response for debugging
+		log.Printf("Error parsing stdio tool call response for '%s' (id %d) from server '%s' for client '%s'. Raw response: %s. Error: %v", toolName, id, server.Config.Name, client, string(respBytes), err)
+		return nil, err
 	}
 
-	log.Printf("Successfully called stdio tool '%s' on server '%s'", toolName, server.Config.Name)
-	return &toolResult, nil
+	log.Printf("Successfully called stdio tool '%s' on server '%s' for client '%s'", toolName, server.Config.Name, client)
+	return toolResult, nil
 }
 
 // callHttpTool executes a tool call on an HTTP-based MCP server.
-func (ps *ProxyServer) callHttpTool(server *config.MCPServer, toolName string, arguments map[string]interface{}) (*config.CallToolResult, error) {
+func (ps *ProxyServer) callHttpTool(ctx context.Context, server *config.MCPServer, toolName string, arguments map[string]interface{}) (*config.CallToolResult, error) {
+	client := logClient(ctx)
 	targetURL, err := url.Parse(server.Config.Address)
 	if err != nil {
 		log.Printf("Invalid MCP server address '%s' for tool '%s': %v", server.Config.Address, toolName, err)
@@ -223,8 +864,8 @@ func (ps *ProxyServer) callHttpTool(server *config.MCPServer, toolName string, a
 		return nil, fmt.Errorf("%w: invalid MCP server address '%s': %v", ErrInternalProxy, server.Config.Address, err)
 	}
 
-	// Construct the target path. Assuming POST /tool/{toolName}
-	targetURL.Path = singleJoiningSlash(targetURL.Path, fmt.Sprintf("/tool/%s", toolName))
+	// Construct the target path from the server's tool_path_template, /tool/{name} by default
+	targetURL.Path = singleJoiningSlash(targetURL.Path, server.Config.ToolPath(toolName))
 
 	// Marshal arguments into JSON body
 	bodyBytes, err := json.Marshal(arguments)
@@ -243,17 +884,26 @@ func (ps *ProxyServer) callHttpTool(server *config.MCPServer, toolName string, a
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json") // Expect JSON response
+	config.SetHopCount(req.Header, config.HopCount(ctx))
+	setClientIdentity(req.Header, server, requestClient(ctx))
+	server.Config.SignRequest(req.Header, bodyBytes, time.Now())
 
 	// Set a timeout context (TODO: Make timeout configurable)
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 	req = req.WithContext(ctx)
 
 	// Perform the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := server.Client().Do(req)
+	if redirectErr := backendRedirect(server, toolName, resp, err); redirectErr != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		log.Printf("HTTP tool '%s' on server '%s' not called for client '%s': %v", toolName, server.Config.Name, client, redirectErr)
+		return nil, redirectErr
+	}
 	if err != nil {
-		log.Printf("Failed to reach MCP server '%s' for tool '%s': %v", server.Config.Name, toolName, err)
+		log.Printf("Failed to reach MCP server '%s' for tool '%s' for client '%s': %v", server.Config.Name, toolName, client, err)
 		// Wrap with ErrBackendCommunication
 		return nil, fmt.Errorf("%w: failed to reach MCP server '%s' for tool '%s': %v", ErrBackendCommunication, server.Config.Name, toolName, err)
 	}
@@ -262,32 +912,61 @@ func (ps *ProxyServer) callHttpTool(server *config.MCPServer, toolName string, a
 	// Read response body
 	respBodyBytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Error reading response body from server '%s' for tool '%s': %v", server.Config.Name, toolName, err)
+		log.Printf("Error reading response body from server '%s' for tool '%s' for client '%s': %v", server.Config.Name, toolName, client, err)
 		// Wrap with ErrBackendCommunication
 		return nil, fmt.Errorf("%w: failed to read response body from server '%s' for tool '%s': %v", ErrBackendCommunication, server.Config.Name, toolName, err)
 	}
 
 	// Check for non-2xx status codes
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		log.Printf("HTTP tool call '%s' failed on server '%s' with status %d. Body: %s", toolName, server.Config.Name, resp.StatusCode, string(respBodyBytes))
+		// A tool that ran and failed is a result for the client, whatever the status
+		if toolResult := toolErrorResult(respBodyBytes); toolResult != nil {
+			log.Printf("HTTP tool '%s' on server '%s' returned a tool error with status %d for client '%s'", toolName, server.Config.Name, resp.StatusCode, client)
+			return toolResult, nil
+		}
+		log.Printf("HTTP tool call '%s' failed on server '%s' with status %d for client '%s'. Body: %s", toolName, server.Config.Name, resp.StatusCode, client, string(respBodyBytes))
+		// A proxy further down the chain hit max_hops; report the loop rather than a
+		// backend failure, so it is not retried and every proxy answers 508
+		if resp.StatusCode == http.StatusLoopDetected {
+			return nil, fmt.Errorf("%w: HTTP tool '%s' on server '%s'", ErrLoopDetected, toolName, server.Config.Name)
+		}
 		// Try to parse error details from body if possible
 		var errorDetail map[string]interface{}
 		// Wrap with ErrBackendCommunication, including status and details if available
 		if json.Unmarshal(respBodyBytes, &errorDetail) == nil {
-			return nil, fmt.Errorf("%w: HTTP tool '%s' failed with status %d: %v", ErrBackendCommunication, toolName, resp.StatusCode, errorDetail)
+			return nil, withUpstreamBody(fmt.Errorf("%w: HTTP tool '%s' failed with status %d: %v", ErrBackendCommunication, toolName, resp.StatusCode, errorDetail), respBodyBytes)
 		}
-		return nil, fmt.Errorf("%w: HTTP tool '%s' failed with status %d", ErrBackendCommunication, toolName, resp.StatusCode)
+		return nil, withUpstreamBody(fmt.Errorf("%w: HTTP tool '%s' failed with status %d", ErrBackendCommunication, toolName, resp.StatusCode), respBodyBytes)
 	}
 
 	// Parse the response body into CallToolResult
 	var toolResult config.CallToolResult
 	if err := json.Unmarshal(respBodyBytes, &toolResult); err != nil {
-		log.Printf("Error unmarshalling HTTP tool call response for '%s' from server '%s'. Raw response: %s. Error: %v", toolName, server.Config.Name, string(respBodyBytes), err)
+		log.Printf("Error unmarshalling HTTP tool call response for '%s' from server '%s' for client '%s'. Raw response: %s. Error: %v", toolName, server.Config.Name, client, string(respBodyBytes), err)
 		// Wrap with ErrBackendCommunication
-		return nil, fmt.Errorf("%w: failed to parse response from HTTP tool '%s': %v", ErrBackendCommunication, toolName, err)
+		return nil, withUpstreamBody(fmt.Errorf("%w: failed to parse response from HTTP tool '%s': %v", ErrBackendCommunication, toolName, err), respBodyBytes)
+	}
+
+	log.Printf("Successfully called HTTP tool '%s' on server '%s' for client '%s'", toolName, server.Config.Name, client)
+	return &toolResult, nil
+}
+
+// callJSONRPCTool executes a tool call as a JSON-RPC tools/call request on an HTTP
+// server using discovery "jsonrpc" (or "auto" resolved to it).
+func (ps *ProxyServer) callJSONRPCTool(ctx context.Context, server *config.MCPServer, toolName string, arguments, meta map[string]interface{}) (*config.CallToolResult, error) {
+	params := config.CallToolRequestParams{Name: toolName, Arguments: arguments, Meta: meta}
+
+	// Set a timeout context, as for REST tool calls
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var toolResult config.CallToolResult
+	if err := server.CallJSONRPC(ctx, "tools/call", params, &toolResult); err != nil {
+		log.Printf("JSON-RPC tool call '%s' failed on server '%s' for client '%s': %v", toolName, server.Config.Name, logClient(ctx), err)
+		return nil, fmt.Errorf("%w: JSON-RPC tool '%s' failed on server '%s': %v", ErrBackendCommunication, toolName, server.Config.Name, err)
 	}
 
-	log.Printf("Successfully called HTTP tool '%s' on server '%s'", toolName, server.Config.Name)
+	log.Printf("Successfully called JSON-RPC tool '%s' on server '%s' for client '%s'", toolName, server.Config.Name, logClient(ctx))
 	return &toolResult, nil
 }
 
@@ -299,6 +978,18 @@ type ProxyRequestInput struct {
 	Query  string
 	Header http.Header
 	Body   io.Reader
+
+	// Resource and Client identify resource accesses for access analytics; Resource
+	// is empty for other requests.
+	Resource string
+	Client   string
+
+	// BaseURL is the proxy's public base URL that rewrite_urls replaces the server's
+	// address with; empty leaves response bodies untouched.
+	BaseURL string
+
+	// Hops is the client request's X-MCP-Hop-Count; HTTP backends receive it incremented.
+	Hops int
 }
 
 // ProxyResponseOutput holds the response data from the proxied server.
@@ -317,12 +1008,33 @@ func (ps *ProxyServer) ProxyRequest(input ProxyRequestInput) (*ProxyResponseOutp
 	}
 
 	log.Printf("Proxying request: %s %s%s to server %s (%s)", input.Method, input.Path, input.Query, server.Config.Name, server.Config.Address)
+	header, err := sanitizeResourceHeaders(input.Header, ps.maxResourceHeaderBytes())
+	if err != nil {
+		log.Printf("Rejecting request to server %s: %v", server.Config.Name, err)
+		return nil, err
+	}
+	input.Header = forwardedHeaders(header, server.Config.ForwardHeaders)
 
-	if server.Config.Command != "" {
+	start := time.Now()
+	out := ps.staticResponse(input)
+	if out != nil {
+		// Served by the proxy itself
+	} else if server.Config.Command != "" {
 		// Correctly call the refactored stdio proxy method
-		return ps.proxyStdioRequestInternal(input)
+		out, err = ps.proxyStdioRequestInternal(input)
+	} else {
+		out, err = ps.proxyHttpRequest(input)
+	}
+	if !errors.Is(err, ErrBufferLimitExceeded) {
+		ps.observeSLO(server, time.Since(start))
+		if input.Resource != "" {
+			ps.recordResourceAccess(input, start, out, err)
+		}
+	}
+	if err == nil && server.Config.RewriteURLs && input.BaseURL != "" {
+		rewriteBaseURLs(out, server, input.BaseURL)
 	}
-	return ps.proxyHttpRequest(input)
+	return out, err
 }
 
 // proxyHttpRequest forwards the request to an HTTP-based MCP server.
@@ -338,12 +1050,13 @@ func (ps *ProxyServer) proxyHttpRequest(input ProxyRequestInput) (*ProxyResponse
 	targetURL.Path = singleJoiningSlash(targetURL.Path, input.Path)
 	targetURL.RawQuery = input.Query
 
-	// Read body for the new request
-	bodyBytes, err := ioutil.ReadAll(input.Body)
+	// Read body for the new request, accounting for it against the buffer budget
+	bodyBytes, release, err := ps.bodyBudget.readAll(input.Body)
 	if err != nil {
 		log.Printf("Failed to read request body for proxying: %v", err)
 		return nil, fmt.Errorf("failed to read request body: %w", err)
 	}
+	defer release()
 
 	req, err := http.NewRequest(input.Method, targetURL.String(), bytes.NewReader(bodyBytes))
 	if err != nil {
@@ -353,6 +1066,9 @@ func (ps *ProxyServer) proxyHttpRequest(input ProxyRequestInput) (*ProxyResponse
 
 	// Copy headers
 	copyHeaders(input.Header, req.Header)
+	config.SetHopCount(req.Header, input.Hops)
+	setClientIdentity(req.Header, server, input.Client)
+	server.Config.SignRequest(req.Header, bodyBytes, time.Now())
 
 	// Set a timeout context (TODO: Make timeout configurable)
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -360,8 +1076,7 @@ func (ps *ProxyServer) proxyHttpRequest(input ProxyRequestInput) (*ProxyResponse
 	req = req.WithContext(ctx)
 
 	// Perform the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := server.Client().Do(req)
 	if err != nil {
 		log.Printf("Failed to reach MCP server '%s': %v", server.Config.Name, err)
 		return nil, fmt.Errorf("failed to reach MCP server: %w", err)
@@ -389,12 +1104,13 @@ func (ps *ProxyServer) proxyHttpRequest(input ProxyRequestInput) (*ProxyResponse
 func (ps *ProxyServer) proxyStdioRequestInternal(input ProxyRequestInput) (*ProxyResponseOutput, error) {
 	server := input.Server
 
-	// Read the full request body
-	bodyBytes, err := io.ReadAll(input.Body)
+	// Read the full request body, accounting for it against the buffer budget
+	bodyBytes, release, err := ps.bodyBudget.readAll(input.Body)
 	if err != nil {
 		log.Printf("Failed to read request body for stdio proxying: %v", err)
 		return nil, fmt.Errorf("failed to read request body: %w", err)
 	}
+	defer release()
 
 	// Build MCP protocol request object
 	mcpRequest := map[string]interface{}{
@@ -452,14 +1168,30 @@ func (ps *ProxyServer) proxyStdioRequestInternal(input ProxyRequestInput) (*Prox
 func copyHeaders(src http.Header, dst http.Header) {
 	for k, vv := range src {
 		// Filter out hop-by-hop headers (like Connection, Proxy-Authenticate, etc.)
-		// This is a basic filter, a more robust solution might be needed.
-		if k == "Connection" || k == "Proxy-Connection" || k == "Keep-Alive" || k == "Proxy-Authenticate" || k == "Proxy-Authorization" || k == "Te" || k == "Trailers" || k == "Transfer-Encoding" || k == "Upgrade" {
+		if slices.Contains(hopByHopHeaders, k) {
 			continue
 		}
 		dst[k] = append([]string(nil), vv...) // Create a copy of the slice
 	}
 }
 
+// forwardedHeaders returns the client headers to forward under a forward_headers
+// allowlist. Content-Type describes the forwarded body and is always kept. An empty
+// allowlist keeps every header; copyHeaders still drops hop-by-hop ones.
+func forwardedHeaders(src http.Header, allow []string) http.Header {
+	if len(allow) == 0 {
+		return src
+	}
+	dst := make(http.Header)
+	for _, name := range append([]string{"Content-Type"}, allow...) {
+		key := http.CanonicalHeaderKey(name)
+		if values, ok := src[key]; ok {
+			dst[key] = values
+		}
+	}
+	return dst
+}
+
 // singleJoiningSlash joins two URL paths with a single slash
 func singleJoiningSlash(a, b string) string {
 	aSlash := strings.HasSuffix(a, "/")
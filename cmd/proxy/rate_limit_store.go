@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+)
+
+// ErrRateLimitStore is returned for calls to rate-limited tools while the shared rate
+// limit store cannot be reached and rate_limit_store.on_failure is "closed".
+var ErrRateLimitStore = errors.New("rate limit store unavailable")
+
+// rateLimitStoreRetry is how long an unreachable store is skipped before it is tried
+// again, and the backoff hint of calls rejected meanwhile.
+const rateLimitStoreRetry = time.Second
+
+// maxIdleRedisConns is the number of connections kept open for later round trips.
+const maxIdleRedisConns = 16
+
+// rateLimitStore holds token buckets shared by the replicas of the proxy.
+type rateLimitStore interface {
+	// take takes a token from the bucket named key, refilled at rate tokens per second
+	// up to burst. When none is left it returns false and how long until one is.
+	take(key string, rate, burst float64) (bool, time.Duration, error)
+	close() error
+}
+
+// tokenBucketScript is the token bucket of toolRateLimiter.take run atomically in
+// Redis, on the Redis clock so replicas agree on refills. It returns whether a token
+// was taken and, if not, the milliseconds until one is. Idle buckets expire once full.
+const tokenBucketScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(bucket[1]) or burst
+local last = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - last) * rate)
+local taken, wait = 0, 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  taken = 1
+else
+  wait = math.ceil((1 - tokens) / rate * 1000)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'last', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {taken, wait}
+`
+
+// tokenBucketSHA is the SHA1 digest EVALSHA runs tokenBucketScript by.
+var tokenBucketSHA = func() string {
+	sum := sha1.Sum([]byte(tokenBucketScript))
+	return hex.EncodeToString(sum[:])
+}()
+
+// newRateLimitStore returns the store configured by rate_limit_store, or nil when the
+// buckets stay in memory.
+func newRateLimitStore(cfg *config.RateLimitStoreConfig) (rateLimitStore, error) {
+	if cfg == nil || cfg.TypeOrDefault() != config.RateLimitStoreRedis {
+		return nil, nil
+	}
+	timeout, err := cfg.TimeoutDuration()
+	if err != nil {
+		return nil, fmt.Errorf("invalid rate_limit_store.timeout: %w", err)
+	}
+	s := &redisRateLimitStore{cfg: *cfg, timeout: timeout, conns: make(chan struct{}, cfg.MaxConnectionsOrDefault())}
+	if cfg.TLS {
+		host, _, err := net.SplitHostPort(cfg.Address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate_limit_store.address '%s': %w", cfg.Address, err)
+		}
+		s.tlsConfig = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	}
+	return s, nil
+}
+
+// redisRateLimitStore keeps token buckets in Redis. Each take is one EVALSHA round
+// trip on a pooled connection; a new connection authenticates and selects its
+// database in one pipelined round trip before that. At most max_connections round
+// trips, and so dialed connections, are in flight at once.
+type redisRateLimitStore struct {
+	cfg       config.RateLimitStoreConfig
+	timeout   time.Duration
+	tlsConfig *tls.Config   // nil without TLS
+	conns     chan struct{} // Semaphore of the connections in use
+
+	mu        sync.Mutex
+	idle      []*redisConn
+	closed    bool
+	downUntil time.Time // Set after a failure; takes fail fast until then
+}
+
+// errRateLimitStoreDown is returned without a round trip while the store is skipped
+// after a failure.
+var errRateLimitStoreDown = errors.New("store skipped after a recent failure")
+
+// errRateLimitStoreBusy is returned when every connection stayed in use for the
+// store's timeout. The store is not skipped for it.
+var errRateLimitStoreBusy = errors.New("all store connections busy")
+
+func (s *redisRateLimitStore) take(key string, rate, burst float64) (bool, time.Duration, error) {
+	s.mu.Lock()
+	down := time.Now().Before(s.downUntil)
+	s.mu.Unlock()
+	if down {
+		return false, 0, errRateLimitStoreDown
+	}
+
+	start := time.Now()
+	reply, err := s.eval(key, rate, burst)
+	if errors.Is(err, errRateLimitStoreBusy) {
+		return false, 0, err
+	}
+	if rateLimitStoreDuration != nil {
+		outcome := "ok"
+		if err != nil {
+			outcome = "error"
+		}
+		rateLimitStoreDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		if s.downUntil.IsZero() {
+			log.Printf("Rate limit store at %s is unreachable, using on_failure '%s': %v", s.cfg.Address, s.cfg.OnFailureOrDefault(), err)
+		}
+		s.downUntil = time.Now().Add(rateLimitStoreRetry)
+		return false, 0, err
+	}
+	if !s.downUntil.IsZero() {
+		log.Printf("Rate limit store at %s is reachable again", s.cfg.Address)
+		s.downUntil = time.Time{}
+	}
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 2 {
+		return false, 0, fmt.Errorf("unexpected token bucket reply %v", reply)
+	}
+	taken, _ := values[0].(int64)
+	waitMs, _ := values[1].(int64)
+	return taken == 1, time.Duration(waitMs) * time.Millisecond, nil
+}
+
+// eval runs tokenBucketScript for key by its digest, loading it with EVAL when Redis
+// does not have it yet. It waits up to the timeout for a connection to be free.
+func (s *redisRateLimitStore) eval(key string, rate, burst float64) (interface{}, error) {
+	select {
+	case s.conns <- struct{}{}:
+		defer func() { <-s.conns }()
+	case <-time.After(s.timeout):
+		return nil, errRateLimitStoreBusy
+	}
+	conn, err := s.get()
+	if err != nil {
+		return nil, err
+	}
+	rateArg := strconv.FormatFloat(rate, 'g', -1, 64)
+	burstArg := strconv.FormatFloat(burst, 'g', -1, 64)
+	reply, err := conn.do(s.timeout, "EVALSHA", tokenBucketSHA, "1", key, rateArg, burstArg)
+	var replyErr redisError
+	if errors.As(err, &replyErr) && strings.HasPrefix(string(replyErr), "NOSCRIPT") {
+		reply, err = conn.do(s.timeout, "EVAL", tokenBucketScript, "1", key, rateArg, burstArg)
+	}
+	if err != nil && !errors.As(err, &replyErr) {
+		conn.Close() // The connection may hold a partial reply
+		return nil, err
+	}
+	s.put(conn)
+	return reply, err
+}
+
+// get returns an idle connection, or dials a new one.
+func (s *redisRateLimitStore) get() (*redisConn, error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, net.ErrClosed
+	}
+	if n := len(s.idle); n > 0 {
+		conn := s.idle[n-1]
+		s.idle = s.idle[:n-1]
+		s.mu.Unlock()
+		return conn, nil
+	}
+	s.mu.Unlock()
+	return s.dial()
+}
+
+// put returns conn to the idle connections, or closes it when there are enough.
+func (s *redisRateLimitStore) put(conn *redisConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || len(s.idle) >= min(maxIdleRedisConns, cap(s.conns)) {
+		conn.Close()
+		return
+	}
+	s.idle = append(s.idle, conn)
+}
+
+// dial opens a connection, sending AUTH and SELECT in one pipelined round trip.
+func (s *redisRateLimitStore) dial() (*redisConn, error) {
+	dialer := &net.Dialer{Timeout: s.timeout}
+	var nc net.Conn
+	var err error
+	if s.tlsConfig != nil {
+		nc, err = tls.DialWithDialer(dialer, "tcp", s.cfg.Address, s.tlsConfig)
+	} else {
+		nc, err = dialer.Dial("tcp", s.cfg.Address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+
+	var setup [][]string
+	if s.cfg.Password != "" {
+		if s.cfg.Username != "" {
+			setup = append(setup, []string{"AUTH", s.cfg.Username, s.cfg.Password})
+		} else {
+			setup = append(setup, []string{"AUTH", s.cfg.Password})
+		}
+	}
+	if s.cfg.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(s.cfg.DB)})
+	}
+	if len(setup) > 0 {
+		if err := conn.pipeline(s.timeout, setup...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to set up connection: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func (s *redisRateLimitStore) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for _, conn := range s.idle {
+		conn.Close()
+	}
+	s.idle = nil
+	return nil
+}
+
+// rateLimitKey is the store key of the bucket of toolName for client, "" when the
+// limit is shared by all clients.
+func (ps *ProxyServer) rateLimitKey(toolName, client string) string {
+	return ps.rateLimitKeyPrefix + "rate:" + url.QueryEscape(toolName) + ":" + client
+}
+
+// redisError is an error reply from Redis. The connection stays usable.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// redisConn is a connection speaking RESP, the Redis protocol.
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// do sends one command and returns its reply.
+func (c *redisConn) do(timeout time.Duration, args ...string) (interface{}, error) {
+	if err := c.Conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	if err := c.write(args); err != nil {
+		return nil, err
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+// pipeline sends the commands at once, then reads their replies, returning the first
+// error among them.
+func (c *redisConn) pipeline(timeout time.Duration, cmds ...[]string) error {
+	if err := c.Conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	for _, args := range cmds {
+		if err := c.write(args); err != nil {
+			return err
+		}
+	}
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	var first error
+	for range cmds {
+		if _, err := c.read(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// write buffers a command as an array of bulk strings.
+func (c *redisConn) write(args []string) error {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.w, "$%d\r\n", len(arg))
+		c.w.WriteString(arg)
+		if _, err := c.w.WriteString("\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read reads one reply: a string, int64, nil, []interface{} or a redisError.
+func (c *redisConn) read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("invalid redis reply %q", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, redisError(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		values := make([]interface{}, n)
+		var first error
+		for i := range values {
+			// Read every element so the connection stays in sync
+			if values[i], err = c.read(); err != nil && first == nil {
+				first = err
+			}
+		}
+		return values, first
+	}
+	return nil, fmt.Errorf("invalid redis reply %q", line)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis is a Redis server answering the commands of redisRateLimitStore, running
+// the token bucket script natively.
+type fakeRedis struct {
+	listener net.Listener
+	password string
+
+	mu       sync.Mutex
+	commands []string // Command names in the order received
+	loaded   bool     // EVAL has loaded the script
+	buckets  map[string]*tokenBucket
+}
+
+func newFakeRedis(tb testing.TB, password string) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(tb, err)
+	f := &fakeRedis{listener: listener, password: password, buckets: make(map[string]*tokenBucket)}
+	tb.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			nc, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(nc)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) serve(nc net.Conn) {
+	defer nc.Close()
+	conn := &redisConn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	authed := f.password == ""
+	for {
+		req, err := conn.read()
+		if err != nil {
+			return
+		}
+		args := req.([]interface{})
+		name := args[0].(string)
+		f.mu.Lock()
+		f.commands = append(f.commands, name)
+		switch {
+		case name == "AUTH":
+			authed = args[len(args)-1] == f.password
+			if authed {
+				conn.w.WriteString("+OK\r\n")
+			} else {
+				conn.w.WriteString("-WRONGPASS invalid password\r\n")
+			}
+		case !authed:
+			conn.w.WriteString("-NOAUTH Authentication required.\r\n")
+		case name == "SELECT":
+			conn.w.WriteString("+OK\r\n")
+		case name == "EVALSHA" && !f.loaded:
+			conn.w.WriteString("-NOSCRIPT No matching script.\r\n")
+		case name == "EVAL" || name == "EVALSHA":
+			f.loaded = true
+			var rate, burst float64
+			fmt.Sscan(args[4].(string), &rate)
+			fmt.Sscan(args[5].(string), &burst)
+			taken, wait := f.take(args[3].(string), rate, burst)
+			fmt.Fprintf(conn.w, "*2\r\n:%d\r\n:%d\r\n", taken, wait.Milliseconds())
+		default:
+			conn.w.WriteString("-ERR unknown command\r\n")
+		}
+		f.mu.Unlock()
+		conn.w.Flush()
+	}
+}
+
+// take is the token bucket of tokenBucketScript; f.mu is held.
+func (f *fakeRedis) take(key string, rate, burst float64) (int, time.Duration) {
+	l := &toolRateLimiter{rate: rate, burst: burst, buckets: f.buckets, now: time.Now}
+	if ok, wait := l.take(key); !ok {
+		return 0, wait
+	}
+	return 1, 0
+}
+
+func (f *fakeRedis) received() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.commands...)
+}
+
+// newReplica returns an HTTP proxy whose tool1 is limited to a burst of 2 with buckets
+// in store.
+func newReplica(t *testing.T, store *config.RateLimitStoreConfig) *HTTPProxy {
+	backend, conf := testHttpServer("server1", []string{"tool1"}, nil, nil, nil)
+	t.Cleanup(backend.Close)
+	ps, err := NewProxyServer(&config.Config{
+		MCPServers:     []config.MCPServerConfig{conf},
+		ToolRateLimits: map[string]config.ToolRateLimitConfig{"tool1": {RPS: 0.5, Burst: 2}},
+		RateLimitStore: store,
+	})
+	require.NoError(t, err)
+	t.Cleanup(ps.Shutdown)
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+	return httpProxy
+}
+
+// TestRedisRateLimitStore tests that replicas sharing a Redis store share the limit, and
+// that each call is a single round trip once a connection is set up.
+func TestRedisRateLimitStore(t *testing.T) {
+	redis := newFakeRedis(t, "secret")
+	store := &config.RateLimitStoreConfig{Type: config.RateLimitStoreRedis, Address: redis.listener.Addr().String(), Password: "secret", DB: 2}
+	replica1, replica2 := newReplica(t, store), newReplica(t, store)
+
+	assert.Equal(t, http.StatusOK, callToolFrom(replica1, "tool1", "192.0.2.1:1234").Code)
+	assert.Equal(t, http.StatusOK, callToolFrom(replica2, "tool1", "192.0.2.1:1234").Code)
+	w := callToolFrom(replica1, "tool1", "192.0.2.1:1234")
+	require.Equal(t, http.StatusTooManyRequests, w.Code, w.Body.String())
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+
+	// Each replica authenticated and selected the database on one connection; the
+	// script was loaded once, and the calls after that were one EVALSHA each
+	assert.Equal(t, []string{"AUTH", "SELECT", "EVALSHA", "EVAL", "AUTH", "SELECT", "EVALSHA", "EVALSHA"}, redis.received())
+}
+
+// TestRateLimitStoreUnreachable tests the on_failure policies while the store cannot
+// be reached.
+func TestRateLimitStoreUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	t.Run("open", func(t *testing.T) {
+		replica := newReplica(t, &config.RateLimitStoreConfig{Type: config.RateLimitStoreRedis, Address: address})
+		for i := 0; i < 2; i++ {
+			w := callToolFrom(replica, "tool1", "192.0.2.1:1234")
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		}
+		assert.Equal(t, http.StatusTooManyRequests, callToolFrom(replica, "tool1", "192.0.2.1:1234").Code, "limited in memory")
+	})
+	t.Run("closed", func(t *testing.T) {
+		replica := newReplica(t, &config.RateLimitStoreConfig{Type: config.RateLimitStoreRedis, Address: address, OnFailure: config.RateLimitStoreFailClosed})
+		w := callToolFrom(replica, "tool1", "192.0.2.1:1234")
+		require.Equal(t, http.StatusServiceUnavailable, w.Code, w.Body.String())
+		var info ThrottleInfo
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+		assert.Equal(t, throttleOverloaded, info.Reason)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	})
+}
+
+// TestRedisRateLimitStoreMaxConnections tests that a take waits at most the timeout for
+// one of max_connections to be free, without sending a command or skipping the store.
+func TestRedisRateLimitStoreMaxConnections(t *testing.T) {
+	redis := newFakeRedis(t, "")
+	store, err := newRateLimitStore(&config.RateLimitStoreConfig{Type: config.RateLimitStoreRedis, Address: redis.listener.Addr().String(), MaxConnections: 1, Timeout: "20ms"})
+	require.NoError(t, err)
+	defer store.close()
+	conns := store.(*redisRateLimitStore).conns
+
+	conns <- struct{}{} // The only connection is in use
+	_, _, err = store.take("busy", 1, 1)
+	assert.ErrorIs(t, err, errRateLimitStoreBusy)
+	assert.Empty(t, redis.received())
+
+	<-conns
+	taken, _, err := store.take("busy", 1, 1)
+	require.NoError(t, err)
+	assert.True(t, taken)
+}
+
+// TestTokenBucketScript runs tokenBucketScript on the Redis server at
+// $MCP_PROXY_TEST_REDIS_ADDR, e.g. "localhost:6379", and is skipped without one.
+func TestTokenBucketScript(t *testing.T) {
+	address := os.Getenv("MCP_PROXY_TEST_REDIS_ADDR")
+	if address == "" {
+		t.Skip("MCP_PROXY_TEST_REDIS_ADDR is not set")
+	}
+	store, err := newRateLimitStore(&config.RateLimitStoreConfig{Type: config.RateLimitStoreRedis, Address: address, Timeout: "1s"})
+	require.NoError(t, err)
+	defer store.close()
+	key := fmt.Sprintf("smart-mcp-proxy-test:%d", time.Now().UnixNano())
+
+	// A burst of 2 refilled every 2 seconds
+	for i := 0; i < 2; i++ {
+		taken, _, err := store.take(key, 0.5, 2)
+		require.NoError(t, err)
+		assert.True(t, taken, "take %d within the burst", i+1)
+	}
+	taken, wait, err := store.take(key, 0.5, 2)
+	require.NoError(t, err)
+	assert.False(t, taken)
+	assert.InDelta(t, 2*time.Second, wait, float64(100*time.Millisecond))
+
+	// A fast bucket refills between takes
+	fast := key + ":fast"
+	taken, _, err = store.take(fast, 20, 1)
+	require.NoError(t, err)
+	require.True(t, taken)
+	taken, wait, err = store.take(fast, 20, 1)
+	require.NoError(t, err)
+	require.False(t, taken)
+	assert.LessOrEqual(t, wait, 50*time.Millisecond)
+	time.Sleep(wait + 10*time.Millisecond)
+	taken, _, err = store.take(fast, 20, 1)
+	require.NoError(t, err)
+	assert.True(t, taken, "refilled after the wait")
+}
+
+// BenchmarkRedisRateLimitStore measures the latency a call to a rate-limited tool gets
+// from the store: one round trip to a local Redis.
+func BenchmarkRedisRateLimitStore(b *testing.B) {
+	redis := newFakeRedis(b, "")
+	store, err := newRateLimitStore(&config.RateLimitStoreConfig{Type: config.RateLimitStoreRedis, Address: redis.listener.Addr().String()})
+	require.NoError(b, err)
+	defer store.close()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := store.take("bench", 1e9, 1e9); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"smart-mcp-proxy/internal/config"
+)
+
+// ErrBackendRedirect is returned for tool calls the backend answered with a redirect
+// that was not followed: with follow_redirects false, to another host, or beyond
+// max_redirects. Such calls are not retried.
+var ErrBackendRedirect = errors.New("backend server answered with a redirect")
+
+// redirectError is a redirect response to a tool call under follow_redirects false.
+// HTTP mode answers the call with its status and Location unchanged.
+type redirectError struct {
+	status   int
+	location string
+	err      error
+}
+
+func (e *redirectError) Error() string { return e.err.Error() }
+func (e *redirectError) Unwrap() error { return e.err }
+
+// asRedirect returns the redirect response carried by err, if any.
+func asRedirect(err error) *redirectError {
+	var r *redirectError
+	if errors.As(err, &r) {
+		return r
+	}
+	return nil
+}
+
+// backendRedirect returns the error of a tool call whose request failed by the
+// server's redirect policy, or whose response is a redirect; nil otherwise.
+func backendRedirect(server *config.MCPServer, toolName string, resp *http.Response, err error) error {
+	if err != nil {
+		if errors.Is(err, config.ErrRedirectNotFollowed) {
+			return fmt.Errorf("%w: tool '%s' on server '%s': %v", ErrBackendRedirect, toolName, server.Config.Name, err)
+		}
+		return nil
+	}
+	location := resp.Header.Get("Location")
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 || location == "" {
+		return nil
+	}
+	return &redirectError{
+		status:   resp.StatusCode,
+		location: location,
+		err:      fmt.Errorf("%w: tool '%s' on server '%s' redirected with status %d to %s", ErrBackendRedirect, toolName, server.Config.Name, resp.StatusCode, location),
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBackendRedirects tests follow_redirects, max_redirects and
+// allow_cross_host_redirects against a backend redirecting tool calls and resources.
+func TestBackendRedirects(t *testing.T) {
+	portal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"content":[{"type":"text","text":"login page"}]}`))
+	}))
+	defer portal.Close()
+	var loops atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tools":
+			w.Write([]byte(`{"tools":[{"name":"moved"},{"name":"expired"},{"name":"loop"}]}`))
+		case "/resources":
+			w.Write([]byte(`{"resources":[{"name":"doc","uri":"file:///doc"}]}`))
+		case "/tool/moved":
+			http.Redirect(w, r, "/tool/final", http.StatusTemporaryRedirect)
+		case "/tool/final":
+			w.Write([]byte(`{"content":[{"type":"text","text":"done"}]}`))
+		case "/tool/expired":
+			http.Redirect(w, r, portal.URL+"/login", http.StatusFound)
+		case "/tool/loop":
+			loops.Add(1)
+			http.Redirect(w, r, "/tool/loop", http.StatusTemporaryRedirect)
+		case "/resource/doc":
+			http.Redirect(w, r, portal.URL+"/login", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer backend.Close()
+
+	newProxy := func(t *testing.T, server config.MCPServerConfig) *HTTPProxy {
+		server.Name = "server1"
+		server.Address = backend.URL
+		ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{server}})
+		require.NoError(t, err)
+		t.Cleanup(ps.Shutdown)
+		httpProxy, err := NewHTTPProxy(ps, ":0")
+		require.NoError(t, err)
+		return httpProxy
+	}
+	call := func(httpProxy *HTTPProxy, tool string) *httptest.ResponseRecorder {
+		return callToolFrom(httpProxy, tool, "192.0.2.1:1234")
+	}
+
+	t.Run("follow", func(t *testing.T) {
+		httpProxy := newProxy(t, config.MCPServerConfig{MaxRedirects: 3})
+		w := call(httpProxy, "moved")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "done")
+
+		w = call(httpProxy, "expired")
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+		assert.Contains(t, w.Body.String(), "redirect")
+		assert.NotContains(t, w.Body.String(), "login page")
+
+		loops.Store(0)
+		w = call(httpProxy, "loop")
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+		assert.Equal(t, int32(4), loops.Load(), "the request and 3 redirects, without retries")
+	})
+
+	t.Run("cross host allowed", func(t *testing.T) {
+		httpProxy := newProxy(t, config.MCPServerConfig{AllowCrossHostRedirects: true})
+		w := call(httpProxy, "expired")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "login page")
+	})
+
+	t.Run("not followed", func(t *testing.T) {
+		follow := false
+		httpProxy := newProxy(t, config.MCPServerConfig{FollowRedirects: &follow})
+		w := call(httpProxy, "moved")
+		assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+		assert.Equal(t, "/tool/final", w.Header().Get("Location"))
+
+		w = call(httpProxy, "expired")
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, portal.URL+"/login", w.Header().Get("Location"))
+
+		w = httptest.NewRecorder()
+		httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", "/resource/server1/doc", nil))
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, portal.URL+"/login", w.Header().Get("Location"))
+	})
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+)
+
+// ErrCircuitOpen is returned when a server's circuit breaker is open and the call is short-circuited.
+var ErrCircuitOpen = errors.New("circuit breaker open for backend server")
+
+// breakerState is the state of a circuitBreaker.
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker tracks consecutive failures for a server and stops sending it
+// traffic for a cool-down period once the threshold is reached.
+type circuitBreaker struct {
+	mu                  sync.Mutex
+	state               breakerState
+	consecutiveFailures int
+	openedAt            time.Time
+	trialInFlight       bool
+
+	threshold    int
+	resetTimeout time.Duration
+	countEach    bool
+	now          func() time.Time // Overridable for tests
+}
+
+// newCircuitBreaker creates a breaker from server configuration.
+func newCircuitBreaker(cfg config.CircuitBreakerConfig) (*circuitBreaker, error) {
+	resetTimeout, err := cfg.ResetTimeoutDuration()
+	if err != nil {
+		return nil, err
+	}
+	return &circuitBreaker{
+		threshold:    cfg.FailureThreshold,
+		resetTimeout: resetTimeout,
+		countEach:    cfg.CountsEachRetry(),
+		now:          time.Now,
+	}, nil
+}
+
+// allow reports whether a call may proceed. After the reset timeout an open breaker
+// lets a single trial call through (half-open).
+func (cb *circuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case breakerOpen:
+		if cb.now().Sub(cb.openedAt) < cb.resetTimeout {
+			return false
+		}
+		cb.state = breakerHalfOpen
+		cb.trialInFlight = true
+		return true
+	case breakerHalfOpen:
+		if cb.trialInFlight {
+			return false
+		}
+		cb.trialInFlight = true
+		return true
+	default:
+		return true
+	}
+}
+
+// recordSuccess closes the breaker and resets the consecutive-failure counter.
+func (cb *circuitBreaker) recordSuccess() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.state = breakerClosed
+	cb.consecutiveFailures = 0
+	cb.trialInFlight = false
+}
+
+// recordFailure counts a failure, opening the breaker when the threshold is reached
+// or when a half-open trial fails.
+func (cb *circuitBreaker) recordFailure() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.consecutiveFailures++
+	cb.trialInFlight = false
+	if cb.state == breakerHalfOpen || cb.consecutiveFailures >= cb.threshold {
+		cb.state = breakerOpen
+		cb.openedAt = cb.now()
+	}
+}
+
+// releaseTrial frees a half-open trial slot without recording an outcome, used when a
+// call fails for reasons unrelated to the backend's health.
+func (cb *circuitBreaker) releaseTrial() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.trialInFlight = false
+}
+
+// isOpen reports whether the breaker is currently rejecting calls.
+func (cb *circuitBreaker) isOpen() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state == breakerOpen
+}
+
+// rejecting reports whether allow would currently turn a call away: the breaker is open
+// within its reset timeout, or half-open with its trial call in flight.
+func (cb *circuitBreaker) rejecting() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case breakerOpen:
+		return cb.now().Sub(cb.openedAt) < cb.resetTimeout
+	case breakerHalfOpen:
+		return cb.trialInFlight
+	default:
+		return false
+	}
+}
+
+// retryAfter estimates how long until a call may be let through again: the rest of the
+// reset timeout while open, zero otherwise.
+func (cb *circuitBreaker) retryAfter() time.Duration {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state != breakerOpen {
+		return 0
+	}
+	return max(0, cb.resetTimeout-cb.now().Sub(cb.openedAt))
+}
+
+// isRetryable reports whether a failed tool call may be attempted again.
+// Only backend communication failures are retried; routing and proxy errors are not.
+func isRetryable(err error) bool {
+	return errors.Is(err, ErrBackendCommunication) && !errors.Is(err, ErrCircuitOpen)
+}
+
+// callToolOnServer calls a tool on a specific server, applying the server's retry
+// policy and circuit breaker.
+//
+// Interaction between the two:
+//   - When the breaker is open the call is short-circuited before any attempt.
+//   - With retry_accounting "once" (the default) all attempts of one call count as a
+//     single logical attempt: the breaker records one failure if every attempt fails,
+//     and a success (including a successful retry) resets the consecutive-failure counter.
+//   - With retry_accounting "each" every failed attempt is recorded, and retries stop as
+//     soon as the breaker opens.
+func (ps *ProxyServer) callToolOnServer(ctx context.Context, server *config.MCPServer, toolName string, arguments map[string]interface{}) (*config.CallToolResult, error) {
+	start := time.Now()
+	breaker := ps.breakers[server.Config.Name]
+	if breaker != nil && !breaker.allow() {
+		log.Printf("Circuit breaker open for server '%s', short-circuiting tool '%s' for client '%s'", server.Config.Name, toolName, logClient(ctx))
+		return nil, throttled(throttleOverloaded, breaker.retryAfter(), fmt.Errorf("%w: %s", ErrCircuitOpen, server.Config.Name))
+	}
+
+	attempts := 1
+	var backoff time.Duration
+	if server.Config.Retry != nil {
+		attempts = server.Config.Retry.MaxAttempts
+		backoff, _ = server.Config.Retry.BackoffDuration() // Validated at load time
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			if breaker != nil && breaker.countEach && breaker.isOpen() {
+				log.Printf("Circuit breaker opened for server '%s' during retries of tool '%s'", server.Config.Name, toolName)
+				break
+			}
+			log.Printf("Retrying tool '%s' on server '%s' for client '%s' (attempt %d/%d)", toolName, server.Config.Name, logClient(ctx), attempt, attempts)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				if breaker != nil {
+					breaker.releaseTrial()
+				}
+				return nil, fmt.Errorf("%w: %v", ErrBackendCommunication, ctx.Err())
+			}
+		}
+
+		attemptStart := time.Now()
+		result, err := ps.dispatchToolCall(ctx, server, toolName, arguments)
+		ps.recordServerCall(ctx, server.Config.Name, err)
+		if ctx.Err() == nil { // A cancelled attempt's duration is not the backend's
+			ps.observeSLO(server, time.Since(attemptStart))
+		}
+		if err == nil {
+			if breaker != nil {
+				breaker.recordSuccess()
+			}
+			ps.annotateResultMeta(result, server.Config.Name, time.Since(start))
+			return result, nil
+		}
+		lastErr = err
+		// A cancelled caller (e.g. the losing leg of a hedged call) says nothing about backend health
+		if !isRetryable(err) || ctx.Err() != nil {
+			if breaker != nil {
+				breaker.releaseTrial()
+			}
+			return nil, err
+		}
+		if breaker != nil && breaker.countEach {
+			breaker.recordFailure()
+		}
+	}
+
+	if breaker != nil && !breaker.countEach {
+		breaker.recordFailure()
+	}
+	return nil, lastErr
+}
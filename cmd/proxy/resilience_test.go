@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testFlakyServer starts a backend whose tool fails with 500 for the first failures calls.
+// It returns the server, the remaining failure budget, and the number of tool calls received.
+func testFlakyServer(failures int32) (*httptest.Server, *atomic.Int32, *atomic.Int32) {
+	remaining := &atomic.Int32{}
+	remaining.Store(failures)
+	calls := &atomic.Int32{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tools", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tools":[{"name":"flaky","inputSchema":{"type":"object"}}]}`))
+	})
+	mux.HandleFunc("/resources", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"resources":[]}`))
+	})
+	mux.HandleFunc("/tool/", func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		calls.Add(1)
+		if remaining.Add(-1) >= 0 {
+			http.Error(w, "flaky failure", http.StatusInternalServerError)
+			return
+		}
+		text := "ok"
+		json.NewEncoder(w).Encode(config.CallToolResult{Content: []config.ContentBlock{{Type: "text", Text: &text}}})
+	})
+	return httptest.NewServer(mux), remaining, calls
+}
+
+// newResilientProxy creates a ProxyServer for a single backend with the given retry and breaker settings.
+func newResilientProxy(t *testing.T, address string, retry *config.RetryConfig, breaker *config.CircuitBreakerConfig) *ProxyServer {
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{{
+		Name:           "flaky-server",
+		Address:        address,
+		Retry:          retry,
+		CircuitBreaker: breaker,
+	}}})
+	require.NoError(t, err)
+	return ps
+}
+
+// TestRetrySucceedsAndResetsBreaker tests that a successful retry resets consecutive failures.
+func TestRetrySucceedsAndResetsBreaker(t *testing.T) {
+	backend, _, calls := testFlakyServer(1)
+	defer backend.Close()
+
+	ps := newResilientProxy(t, backend.URL,
+		&config.RetryConfig{MaxAttempts: 2},
+		&config.CircuitBreakerConfig{FailureThreshold: 2, RetryAccounting: config.RetryAccountingEach})
+
+	result, err := ps.CallTool("flaky", map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", *result.Content[0].Text)
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Equal(t, 0, ps.breakers["flaky-server"].consecutiveFailures)
+}
+
+// TestBreakerCountsRetriesOnce tests that with "once" accounting a fully failed call counts as one failure.
+func TestBreakerCountsRetriesOnce(t *testing.T) {
+	backend, _, calls := testFlakyServer(100)
+	defer backend.Close()
+
+	ps := newResilientProxy(t, backend.URL,
+		&config.RetryConfig{MaxAttempts: 3},
+		&config.CircuitBreakerConfig{FailureThreshold: 2, ResetTimeout: "1m"})
+	breaker := ps.breakers["flaky-server"]
+
+	_, err := ps.CallTool("flaky", map[string]interface{}{})
+	assert.ErrorIs(t, err, ErrBackendCommunication)
+	assert.Equal(t, int32(3), calls.Load(), "all retries should be attempted")
+	assert.Equal(t, 1, breaker.consecutiveFailures)
+	assert.False(t, breaker.isOpen())
+
+	_, err = ps.CallTool("flaky", map[string]interface{}{})
+	assert.ErrorIs(t, err, ErrBackendCommunication)
+	assert.Equal(t, int32(6), calls.Load())
+	assert.True(t, breaker.isOpen())
+
+	// Open breaker short-circuits before any attempt
+	_, err = ps.CallTool("flaky", map[string]interface{}{})
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(6), calls.Load())
+}
+
+// TestBreakerCountsEachRetry tests that with "each" accounting retries stop once the breaker opens.
+func TestBreakerCountsEachRetry(t *testing.T) {
+	backend, _, calls := testFlakyServer(100)
+	defer backend.Close()
+
+	ps := newResilientProxy(t, backend.URL,
+		&config.RetryConfig{MaxAttempts: 5},
+		&config.CircuitBreakerConfig{FailureThreshold: 2, ResetTimeout: "1m", RetryAccounting: config.RetryAccountingEach})
+	breaker := ps.breakers["flaky-server"]
+
+	_, err := ps.CallTool("flaky", map[string]interface{}{})
+	assert.ErrorIs(t, err, ErrBackendCommunication)
+	assert.Equal(t, int32(2), calls.Load(), "retries should stop when the breaker opens")
+	assert.True(t, breaker.isOpen())
+
+	_, err = ps.CallTool("flaky", map[string]interface{}{})
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+// TestBreakerHalfOpen tests that an open breaker admits a trial call after the reset timeout.
+func TestBreakerHalfOpen(t *testing.T) {
+	backend, remaining, _ := testFlakyServer(100)
+	defer backend.Close()
+
+	ps := newResilientProxy(t, backend.URL, nil, &config.CircuitBreakerConfig{FailureThreshold: 1, ResetTimeout: "1m"})
+	breaker := ps.breakers["flaky-server"]
+	now := time.Now()
+	breaker.now = func() time.Time { return now }
+
+	_, err := ps.CallTool("flaky", map[string]interface{}{})
+	assert.ErrorIs(t, err, ErrBackendCommunication)
+	assert.True(t, breaker.isOpen())
+
+	// Backend recovers and the reset timeout elapses
+	remaining.Store(0)
+	now = now.Add(2 * time.Minute)
+
+	_, err = ps.CallTool("flaky", map[string]interface{}{})
+	require.NoError(t, err)
+	assert.False(t, breaker.isOpen())
+}
+
+// TestRoutingPrefersHealthyProvider tests that a tool served by two servers is routed to
+// the second while the first one's breaker is open, and back once both are unhealthy.
+func TestRoutingPrefersHealthyProvider(t *testing.T) {
+	first, firstConf := testReplicaServer("first", "shared", 0)
+	defer first.Close()
+	second, secondConf := testReplicaServer("second", "shared", 0)
+	defer second.Close()
+	breaker := &config.CircuitBreakerConfig{FailureThreshold: 1, ResetTimeout: "1m"}
+	firstConf.CircuitBreaker = breaker
+	secondConf.CircuitBreaker = breaker
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{firstConf, secondConf}})
+	require.NoError(t, err)
+
+	assert.Equal(t, "first", ps.findMCPServerByTool("shared").Config.Name)
+
+	ps.breakers["first"].recordFailure()
+	assert.Equal(t, "second", ps.findMCPServerByTool("shared").Config.Name)
+	result, err := ps.CallTool("shared", map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, "second", *result.Content[0].Text)
+
+	// The sole remaining choice is an unhealthy provider: keep the first in config order
+	ps.breakers["second"].recordFailure()
+	assert.Equal(t, "first", ps.findMCPServerByTool("shared").Config.Name)
+
+	// Once the reset timeout has passed the first server gets its trial call again
+	ps.breakers["second"].recordSuccess()
+	ps.breakers["first"].now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	assert.Equal(t, "first", ps.findMCPServerByTool("shared").Config.Name)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recentResourceAccessesSize is the number of resource accesses kept for /analytics/resources.
+const recentResourceAccessesSize = 100
+
+// ResourceAccessRecord describes one proxied resource access.
+type ResourceAccessRecord struct {
+	Time       time.Time `json:"time"`
+	Server     string    `json:"server"`
+	Resource   string    `json:"resource"`
+	Client     string    `json:"client"`     // Client IP in HTTP mode, the client's label in command mode
+	Status     int       `json:"status"`     // Backend status; 502 when the backend could not be reached
+	DurationMs float64   `json:"durationMs"` // Time spent proxying the request
+}
+
+// ResourceAccessStats counts the accesses to one resource of one server.
+type ResourceAccessStats struct {
+	Server       string    `json:"server"`
+	Resource     string    `json:"resource"`
+	Accesses     int64     `json:"accesses"`
+	Errors       int64     `json:"errors"` // Accesses answered with a 5xx status or not answered
+	LastAccessed time.Time `json:"lastAccessed"`
+}
+
+// ResourceAnalytics is the body of the /analytics/resources endpoint.
+type ResourceAnalytics struct {
+	Resources      []ResourceAccessStats  `json:"resources"`      // Most accessed first
+	RecentAccesses []ResourceAccessRecord `json:"recentAccesses"` // Newest first
+}
+
+// resourceKey identifies a resource of a server.
+type resourceKey struct {
+	server   string
+	resource string
+}
+
+// resourceAnalytics counts resource accesses and keeps the most recent ones.
+type resourceAnalytics struct {
+	mu     sync.Mutex
+	stats  map[resourceKey]*ResourceAccessStats
+	recent []ResourceAccessRecord // Oldest first, at most size entries
+	size   int
+}
+
+func newResourceAnalytics(size int) *resourceAnalytics {
+	return &resourceAnalytics{stats: make(map[resourceKey]*ResourceAccessStats), size: size}
+}
+
+// record adds an access to the counts and the recent list.
+func (a *resourceAnalytics) record(rec ResourceAccessRecord) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := resourceKey{rec.Server, rec.Resource}
+	stats, ok := a.stats[key]
+	if !ok {
+		stats = &ResourceAccessStats{Server: rec.Server, Resource: rec.Resource}
+		a.stats[key] = stats
+	}
+	stats.Accesses++
+	if rec.Status >= 500 {
+		stats.Errors++
+	}
+	stats.LastAccessed = rec.Time
+
+	a.recent = append(a.recent, rec)
+	if len(a.recent) > a.size {
+		a.recent = append([]ResourceAccessRecord(nil), a.recent[len(a.recent)-a.size:]...)
+	}
+}
+
+// snapshot returns the counts, most accessed first, and the recent accesses, newest first.
+func (a *resourceAnalytics) snapshot() ResourceAnalytics {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := ResourceAnalytics{
+		Resources:      make([]ResourceAccessStats, 0, len(a.stats)),
+		RecentAccesses: make([]ResourceAccessRecord, 0, len(a.recent)),
+	}
+	for _, stats := range a.stats {
+		out.Resources = append(out.Resources, *stats)
+	}
+	sort.Slice(out.Resources, func(i, j int) bool {
+		ri, rj := out.Resources[i], out.Resources[j]
+		if ri.Accesses != rj.Accesses {
+			return ri.Accesses > rj.Accesses
+		}
+		if ri.Server != rj.Server {
+			return ri.Server < rj.Server
+		}
+		return ri.Resource < rj.Resource
+	})
+	for i := len(a.recent) - 1; i >= 0; i-- {
+		out.RecentAccesses = append(out.RecentAccesses, a.recent[i])
+	}
+	return out
+}
+
+// recordResourceAccess records a proxied resource access in the analytics and metrics,
+// unless the server is marked sensitive.
+func (ps *ProxyServer) recordResourceAccess(input ProxyRequestInput, start time.Time, out *ProxyResponseOutput, err error) {
+	server := input.Server
+	if server.Config.Sensitive {
+		return
+	}
+	duration := time.Since(start)
+	status := http.StatusBadGateway
+	if err == nil && out != nil {
+		status = out.Status
+	}
+	ps.resources.record(ResourceAccessRecord{
+		Time:       start,
+		Server:     server.Config.Name,
+		Resource:   input.Resource,
+		Client:     input.Client,
+		Status:     status,
+		DurationMs: float64(duration.Microseconds()) / 1000,
+	})
+	if resourceAccessesTotal != nil {
+		resourceAccessesTotal.WithLabelValues(server.Config.Name, input.Resource, strconv.Itoa(status)).Inc()
+	}
+	if resourceAccessDurations != nil {
+		resourceAccessDurations.WithLabelValues(server.Config.Name, input.Resource).Observe(duration.Seconds())
+	}
+}
+
+// ResourceAnalytics reports how often each resource was accessed and the latest accesses.
+func (ps *ProxyServer) ResourceAnalytics() ResourceAnalytics {
+	return ps.resources.snapshot()
+}
+
+// handleResourceAnalytics handles GET /analytics/resources.
+func (h *HTTPProxy) handleResourceAnalytics(c *gin.Context) {
+	respondListJSON(c, h.ps.ResourceAnalytics())
+}
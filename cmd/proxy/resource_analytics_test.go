@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestResourceAnalytics tests that resource proxy accesses are counted and listed,
+// except for servers marked sensitive, to admins only.
+func TestResourceAnalytics(t *testing.T) {
+	_, ps, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+	ps.findMCPServerByName("server2").Config.Sensitive = true
+	ps.admin = &adminAuth{keys: map[string]string{"ops": "ops-key"}}
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	for _, path := range []string{"/resource/server1/res1/a", "/resource/server1/res1/b", "/resource/server2/res2/c"} {
+		w := httptest.NewRecorder()
+		httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		require.Equal(t, http.StatusOK, w.Code, path)
+	}
+
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", "/analytics/resources", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code, "only served in the admin group")
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", "/admin/analytics/resources", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = httptest.NewRecorder()
+	adminServer := newAdminServer(ps)
+	adminServer.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/admin/analytics/resources", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "command mode admin listener")
+
+	req := httptest.NewRequest("GET", "/admin/analytics/resources", nil)
+	req.Header.Set("Authorization", "Bearer ops-key")
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var analytics ResourceAnalytics
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &analytics))
+
+	require.Len(t, analytics.Resources, 1)
+	assert.Equal(t, "server1", analytics.Resources[0].Server)
+	assert.Equal(t, "res1", analytics.Resources[0].Resource)
+	assert.Equal(t, int64(2), analytics.Resources[0].Accesses)
+	assert.Zero(t, analytics.Resources[0].Errors)
+
+	require.Len(t, analytics.RecentAccesses, 2)
+	assert.Equal(t, http.StatusOK, analytics.RecentAccesses[0].Status)
+	assert.NotEmpty(t, analytics.RecentAccesses[0].Client)
+}
+
+// TestCommandResourceAnalytics tests that resources/access requests are recorded with the command mode client's label.
+func TestCommandResourceAnalytics(t *testing.T) {
+	cmdProxy, servers := setupTestCommandProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+
+	_, err := cmdProxy.handleCommandRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"resources/access","params":{"serverName":"server1","resourceName":"res1","method":"GET"}}`))
+	require.NoError(t, err)
+
+	analytics := cmdProxy.ps.ResourceAnalytics()
+	require.Len(t, analytics.RecentAccesses, 1)
+	assert.Equal(t, "res1", analytics.RecentAccesses[0].Resource)
+	assert.Equal(t, unknownClient, analytics.RecentAccesses[0].Client)
+}
+
+// TestResourceAnalyticsRecentLimit tests that only the most recent accesses are kept, newest first.
+func TestResourceAnalyticsRecentLimit(t *testing.T) {
+	a := newResourceAnalytics(2)
+	for _, name := range []string{"a", "b", "c"} {
+		a.record(ResourceAccessRecord{Server: "s", Resource: name, Status: http.StatusOK})
+	}
+	a.record(ResourceAccessRecord{Server: "s", Resource: "c", Status: http.StatusBadGateway})
+
+	snapshot := a.snapshot()
+	require.Len(t, snapshot.RecentAccesses, 2)
+	assert.Equal(t, http.StatusBadGateway, snapshot.RecentAccesses[0].Status)
+	assert.Equal(t, "c", snapshot.RecentAccesses[1].Resource)
+	require.Len(t, snapshot.Resources, 3)
+	assert.Equal(t, "c", snapshot.Resources[0].Resource)
+	assert.Equal(t, int64(1), snapshot.Resources[0].Errors)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"smart-mcp-proxy/internal/config"
+)
+
+// Errors returned by ReadResource.
+var (
+	ErrResourceNotFound = errors.New("resource not found or not provided by any configured server")
+	ErrResourceConflict = errors.New("resource URI is exposed by more than one server")
+)
+
+// ResourceConflict is a resource URI exposed by more than one server.
+type ResourceConflict struct {
+	URI     string   `json:"uri"`
+	Servers []string `json:"servers"`         // Servers exposing the URI, in configuration order
+	Owner   string   `json:"owner,omitempty"` // Server the URI resolves to; empty under the error policy
+}
+
+// resourceResolver decides which server owns a resource URI exposed by more than one
+// server, by the resource_conflicts policy.
+type resourceResolver struct {
+	policy string
+	prefer []string // Server names for config.ResourceConflictPreferServers, most preferred first
+
+	mu       sync.Mutex
+	reported map[string]bool // Conflicting URIs already logged
+}
+
+func newResourceResolver(cfg *config.Config) *resourceResolver {
+	rc := config.ResourceConflictsConfig{}
+	if cfg.ResourceConflicts != nil {
+		rc = *cfg.ResourceConflicts
+	}
+	return &resourceResolver{policy: rc.PolicyOrDefault(), prefer: rc.PreferServers, reported: make(map[string]bool)}
+}
+
+// owner picks the owner of a URI exposed by servers, in configuration order.
+func (r *resourceResolver) owner(servers []string) string {
+	switch r.policy {
+	case config.ResourceConflictError:
+		return ""
+	case config.ResourceConflictPreferServers:
+		for _, name := range r.prefer {
+			if slices.Contains(servers, name) {
+				return name
+			}
+		}
+	}
+	return servers[0]
+}
+
+// report logs conflicts not seen before and updates the conflicts gauge.
+func (r *resourceResolver) report(conflicts []ResourceConflict) {
+	if resourceURIConflicts != nil { // Check if initialized
+		resourceURIConflicts.Set(float64(len(conflicts)))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range conflicts {
+		if r.reported[c.URI] {
+			continue
+		}
+		r.reported[c.URI] = true
+		if c.Owner == "" {
+			log.Printf("WARNING: resource URI '%s' is exposed by servers %s; hiding it (resource_conflicts policy '%s')", c.URI, strings.Join(c.Servers, ", "), r.policy)
+		} else {
+			log.Printf("WARNING: resource URI '%s' is exposed by servers %s; using server '%s' (resource_conflicts policy '%s')", c.URI, strings.Join(c.Servers, ", "), c.Owner, r.policy)
+		}
+	}
+}
+
+// resolveResourceOwners resolves the owner of every resource URI exposed by servers,
+// from their currently discovered resources. A URI exposed by one server is owned by
+// it; a conflicting URI is owned as decided by resolver, or by no server (""). Resources
+// without a URI are not included.
+func resolveResourceOwners(servers []*config.MCPServer, resolver *resourceResolver) (map[string]string, []ResourceConflict) {
+	exposedBy := make(map[string][]string)
+	var uris []string
+	for _, server := range servers {
+		for _, resource := range server.GetResources() {
+			if resource.URI == "" || slices.Contains(exposedBy[resource.URI], server.Config.Name) {
+				continue
+			}
+			if len(exposedBy[resource.URI]) == 0 {
+				uris = append(uris, resource.URI)
+			}
+			exposedBy[resource.URI] = append(exposedBy[resource.URI], server.Config.Name)
+		}
+	}
+
+	owners := make(map[string]string, len(exposedBy))
+	var conflicts []ResourceConflict
+	for _, uri := range uris {
+		servers := exposedBy[uri]
+		if len(servers) == 1 {
+			owners[uri] = servers[0]
+			continue
+		}
+		owner := resolver.owner(servers)
+		owners[uri] = owner
+		conflicts = append(conflicts, ResourceConflict{URI: uri, Servers: servers, Owner: owner})
+	}
+	resolver.report(conflicts)
+	return owners, conflicts
+}
+
+// resourceOwners returns the owner of every resource URI, as resolved by the server
+// index when discovery last changed, and the conflicting URIs.
+func (ps *ProxyServer) resourceOwners() (map[string]string, []ResourceConflict) {
+	return ps.servers.resourceOwners()
+}
+
+// ownsResource reports whether a server's resource is listed: it has no URI, or the
+// server owns its URI.
+func ownsResource(owners map[string]string, server *config.MCPServer, resource config.ResourceInfo) bool {
+	return resource.URI == "" || owners[resource.URI] == server.Config.Name
+}
+
+// ResourceConflicts returns the resource URIs currently exposed by more than one server.
+func (ps *ProxyServer) ResourceConflicts() []ResourceConflict {
+	_, conflicts := ps.resourceOwners()
+	return conflicts
+}
+
+// ReadResource reads the resource with the given URI from the server that owns it,
+// the same server listings show it for, with a GET of the resource's path. The result
+// is an MCP ReadResourceResult.
+func (ps *ProxyServer) ReadResource(client string, hops int, uri string) (map[string]interface{}, error) {
+	owners, _ := ps.resourceOwners()
+	owner, ok := owners[uri]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrResourceNotFound, uri)
+	}
+	if owner == "" {
+		return nil, fmt.Errorf("%w: %s", ErrResourceConflict, uri)
+	}
+	server := ps.findMCPServerByName(owner)
+	var resource config.ResourceInfo
+	for _, r := range server.GetResources() {
+		if r.URI == uri {
+			resource = r
+			break
+		}
+	}
+
+	out, err := ps.ProxyRequest(ProxyRequestInput{
+		Server:   server,
+		Method:   http.MethodGet,
+		Path:     server.ResourcePath(resource.Name),
+		Header:   make(http.Header),
+		Resource: resource.Name,
+		Client:   client,
+		BaseURL:  ps.publicBaseURL,
+		Hops:     hops,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBackendCommunication, err)
+	}
+	if out.Status < 200 || out.Status >= 300 {
+		return nil, fmt.Errorf("%w: server '%s' answered %d for resource '%s'", ErrBackendCommunication, owner, out.Status, uri)
+	}
+
+	mimeType := resource.MimeType
+	if mimeType == "" {
+		mimeType = out.Headers.Get("Content-Type")
+	}
+	contents := map[string]interface{}{"uri": uri}
+	if mimeType != "" {
+		contents["mimeType"] = mimeType
+	}
+	if isTextContent(mimeType, out.Body) {
+		contents["text"] = string(out.Body)
+	} else {
+		contents["blob"] = base64.StdEncoding.EncodeToString(out.Body)
+	}
+	return map[string]interface{}{"contents": []interface{}{contents}}, nil
+}
+
+// readResourceRPC serves a resources/read JSON-RPC request for a client.
+func readResourceRPC(ps *ProxyServer, client string, hops int, params json.RawMessage, result *interface{}) *rpcError {
+	var readParams resourceURIParams
+	if err := json.Unmarshal(params, &readParams); err != nil || readParams.URI == "" {
+		return &rpcError{Code: -32602, Message: "Invalid params for resources/read: 'uri' is required"}
+	}
+	contents, err := ps.ReadResource(client, hops, readParams.URI)
+	if errors.Is(err, ErrResourceNotFound) || errors.Is(err, ErrResourceConflict) {
+		return &rpcError{Code: -32002, Message: "Resource not found", Data: err.Error()}
+	}
+	if err != nil {
+		message := fmt.Sprintf("Failed to read resource '%s'", readParams.URI)
+		if t := asThrottle(err); t != nil {
+			return throttleRPCError(-32003, message, err, t)
+		}
+		return &rpcError{Code: -32003, Message: message, Data: err.Error()}
+	}
+	*result = contents
+	return nil
+}
+
+// isTextContent reports whether a resource body is returned as text rather than a
+// base64 blob: text/*, JSON and XML types, or, without a type, valid UTF-8.
+func isTextContent(mimeType string, body []byte) bool {
+	if mimeType == "" {
+		return utf8.Valid(body)
+	}
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return utf8.Valid(body)
+	}
+	return strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "json") || strings.HasSuffix(mediaType, "xml")
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupConflictingResources starts two backends exposing file:///shared with different
+// bodies, and a proxy over them with the given conflict settings.
+func setupConflictingResources(t *testing.T, conflicts *config.ResourceConflictsConfig) *ProxyServer {
+	t.Helper()
+	alpha := proxytest.NewBackend(nil, []proxytest.Resource{
+		{Name: "shared", URI: "file:///shared", MimeType: "text/plain", Body: "from alpha"},
+		{Name: "own", URI: "file:///alpha", Body: `{"own":true}`},
+	})
+	t.Cleanup(alpha.Close)
+	beta := proxytest.NewBackend(nil, []proxytest.Resource{
+		{Name: "shared", URI: "file:///shared", MimeType: "text/plain", Body: "from beta"},
+	})
+	t.Cleanup(beta.Close)
+
+	ps, err := NewProxyServer(&config.Config{
+		MCPServers: []config.MCPServerConfig{
+			{Name: "alpha", Address: alpha.URL},
+			{Name: "beta", Address: beta.URL},
+		},
+		ResourceConflicts: conflicts,
+	})
+	require.NoError(t, err)
+	t.Cleanup(ps.Shutdown)
+	return ps
+}
+
+// listedResourceURIs counts how often each resource URI is listed.
+func listedResourceURIs(ps *ProxyServer) map[string]int {
+	listed := make(map[string]int)
+	for _, r := range ps.ListResourcesWithMetadata() {
+		listed[r.URI]++
+	}
+	return listed
+}
+
+// readText reads a resource and returns its text contents.
+func readText(t *testing.T, ps *ProxyServer, uri string) string {
+	t.Helper()
+	result, err := ps.ReadResource("test", 0, uri)
+	require.NoError(t, err)
+	contents := result["contents"].([]interface{})
+	require.Len(t, contents, 1)
+	return contents[0].(map[string]interface{})["text"].(string)
+}
+
+// TestResourceConflictPolicies tests that a URI exposed by two servers is listed once,
+// for the server reads of it and requests for its name go to.
+func TestResourceConflictPolicies(t *testing.T) {
+	tests := []struct {
+		name      string
+		conflicts *config.ResourceConflictsConfig
+		owner     string
+		body      string
+	}{
+		{"default first wins", nil, "alpha", "from alpha"},
+		{"prefer servers", &config.ResourceConflictsConfig{Policy: config.ResourceConflictPreferServers, PreferServers: []string{"beta"}}, "beta", "from beta"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ps := setupConflictingResources(t, tt.conflicts)
+
+			assert.Equal(t, map[string]int{"file:///shared": 1, "file:///alpha": 1}, listedResourceURIs(ps))
+			assert.Len(t, ps.ListResources(), 2)
+
+			assert.Equal(t, tt.body, readText(t, ps, "file:///shared"))
+			assert.Equal(t, tt.owner, ps.findMCPServerByResource("shared").Config.Name)
+			assert.Equal(t, []ResourceConflict{{URI: "file:///shared", Servers: []string{"alpha", "beta"}, Owner: tt.owner}}, ps.Status().ResourceConflicts)
+		})
+	}
+}
+
+// TestResourceConflictErrorPolicy tests that the error policy hides a conflicting URI
+// from listings and fails reads of it and requests for its name, while other resources
+// stay readable.
+func TestResourceConflictErrorPolicy(t *testing.T) {
+	ps := setupConflictingResources(t, &config.ResourceConflictsConfig{Policy: config.ResourceConflictError})
+
+	assert.Equal(t, map[string]int{"file:///alpha": 1}, listedResourceURIs(ps))
+
+	_, err := ps.ReadResource("test", 0, "file:///shared")
+	assert.ErrorIs(t, err, ErrResourceConflict)
+	_, err = ps.ReadResource("test", 0, "file:///missing")
+	assert.ErrorIs(t, err, ErrResourceNotFound)
+	assert.Equal(t, `{"own":true}`, readText(t, ps, "file:///alpha"))
+	assert.Nil(t, ps.findMCPServerByResource("shared"))
+	assert.Nil(t, ps.DescribeResource("shared"))
+
+	assert.Equal(t, []ResourceConflict{{URI: "file:///shared", Servers: []string{"alpha", "beta"}}}, ps.Status().ResourceConflicts)
+}
+
+// TestResourcesReadRPC tests resources/read in command mode and on /mcp.
+func TestResourcesReadRPC(t *testing.T) {
+	ps := setupConflictingResources(t, &config.ResourceConflictsConfig{Policy: config.ResourceConflictPreferServers, PreferServers: []string{"beta"}})
+
+	cmdProxy, err := NewCommandProxy(ps)
+	require.NoError(t, err)
+	respBytes, err := cmdProxy.handleCommandRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"resources/read","params":{"uri":"file:///shared"}}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":{"contents":[{"uri":"file:///shared","mimeType":"text/plain","text":"from beta"}]}}`, string(respBytes))
+
+	respBytes, err = cmdProxy.handleCommandRequest([]byte(`{"jsonrpc":"2.0","id":2,"method":"resources/read","params":{"uri":"file:///missing"}}`))
+	require.NoError(t, err)
+	var resp jsonRPCResponse
+	require.NoError(t, json.Unmarshal(respBytes, &resp))
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, -32002, resp.Error.Code)
+
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+	id := mcpInitialize(t, httpProxy)
+	w := mcpRequest(t, httpProxy, id, `{"jsonrpc":"2.0","id":3,"method":"resources/read","params":{"uri":"file:///alpha"}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":3,"result":{"contents":[{"uri":"file:///alpha","mimeType":"application/json","text":"{\"own\":true}"}]}}`, w.Body.String())
+}
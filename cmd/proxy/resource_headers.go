@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// ErrInvalidHeaders is returned for resource requests whose client headers cannot be
+// forwarded: invalid names or values, or too many bytes in total.
+var ErrInvalidHeaders = errors.New("invalid request headers")
+
+// hopByHopHeaders describe a single connection and are never forwarded, in either direction.
+var hopByHopHeaders = []string{"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization", "Te", "Trailers", "Transfer-Encoding", "Upgrade"}
+
+// framingHeaders are derived by the proxy from the backend request itself; client
+// values would contradict them.
+var framingHeaders = []string{"Host", "Content-Length", "Trailer"}
+
+// sanitizeResourceHeaders returns the client headers to forward with a resource request:
+// hop-by-hop and framing headers, and headers named in Connection, are dropped. Names
+// and values must be valid per RFC 9110 and the forwarded headers may take at most
+// maxBytes, counted as "Name: value\r\n" lines.
+func sanitizeResourceHeaders(src http.Header, maxBytes int) (http.Header, error) {
+	dropped := make(map[string]bool)
+	for _, name := range append(slices.Clone(hopByHopHeaders), framingHeaders...) {
+		dropped[name] = true
+	}
+	for _, value := range src.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				dropped[textproto.CanonicalMIMEHeaderKey(token)] = true
+			}
+		}
+	}
+
+	dst := make(http.Header, len(src))
+	size := 0
+	for name, values := range src {
+		if !httpguts.ValidHeaderFieldName(name) {
+			return nil, fmt.Errorf("%w: invalid header name %q", ErrInvalidHeaders, name)
+		}
+		key := http.CanonicalHeaderKey(name)
+		for _, value := range values {
+			if !httpguts.ValidHeaderFieldValue(value) {
+				return nil, fmt.Errorf("%w: invalid value for header %s", ErrInvalidHeaders, key)
+			}
+		}
+		if dropped[key] {
+			continue
+		}
+		for _, value := range values {
+			size += len(key) + len(value) + len(": \r\n")
+		}
+		dst[key] = append(dst[key], values...)
+	}
+	if size > maxBytes {
+		return nil, fmt.Errorf("%w: %d header bytes, limit %d", ErrInvalidHeaders, size, maxBytes)
+	}
+	return dst, nil
+}
+
+// maxResourceHeaderBytes is the cap on forwarded resource request headers: the HTTP
+// listener's max_header_bytes, which command mode shares.
+func (ps *ProxyServer) maxResourceHeaderBytes() int {
+	if ps.maxHeaderBytes > 0 {
+		return ps.maxHeaderBytes
+	}
+	return http.DefaultMaxHeaderBytes
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSanitizeResourceHeaders tests that each protected header, and headers named in
+// Connection, are dropped while other headers are kept.
+func TestSanitizeResourceHeaders(t *testing.T) {
+	protected := append(append([]string{}, hopByHopHeaders...), framingHeaders...)
+	for _, name := range protected {
+		t.Run(name, func(t *testing.T) {
+			src := http.Header{name: {"x"}, "X-Keep": {"1"}}
+			dst, err := sanitizeResourceHeaders(src, http.DefaultMaxHeaderBytes)
+			require.NoError(t, err)
+			assert.Empty(t, dst.Values(name))
+			assert.Equal(t, "1", dst.Get("X-Keep"))
+		})
+	}
+
+	src := http.Header{"Connection": {"close, x-private"}, "X-Private": {"secret"}, "X-Keep": {"1"}}
+	dst, err := sanitizeResourceHeaders(src, http.DefaultMaxHeaderBytes)
+	require.NoError(t, err)
+	assert.Equal(t, http.Header{"X-Keep": {"1"}}, dst)
+}
+
+// TestSanitizeResourceHeadersInvalid tests that invalid names and values and oversized
+// headers are rejected.
+func TestSanitizeResourceHeadersInvalid(t *testing.T) {
+	for name, src := range map[string]http.Header{
+		"name with space": {"Bad Name": {"x"}},
+		"empty name":      {"": {"x"}},
+		"newline value":   {"X-Test": {"a\r\nContent-Length: 0"}},
+		"control value":   {"X-Test": {"a\x00b"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := sanitizeResourceHeaders(src, http.DefaultMaxHeaderBytes)
+			assert.ErrorIs(t, err, ErrInvalidHeaders)
+		})
+	}
+
+	// "X-Test: 0123456789\r\n" takes 20 bytes
+	src := http.Header{"X-Test": {"0123456789"}}
+	_, err := sanitizeResourceHeaders(src, 20)
+	assert.NoError(t, err)
+	_, err = sanitizeResourceHeaders(src, 19)
+	assert.ErrorIs(t, err, ErrInvalidHeaders)
+}
+
+// receivedRequest is what testHeaderEchoServer saw of the last request.
+type receivedRequest struct {
+	mu            sync.Mutex
+	host          string
+	contentLength int64
+	header        http.Header
+	body          string
+}
+
+// testHeaderEchoServer is a backend with resource "res" that records the requests it gets.
+func testHeaderEchoServer(rec *receivedRequest) (*httptest.Server, config.MCPServerConfig) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tools", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`{"tools":[]}`)) })
+	mux.HandleFunc("/resources", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"resources":[{"name":"res"}]}`))
+	})
+	mux.HandleFunc("/resource/", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rec.mu.Lock()
+		rec.host, rec.contentLength, rec.header, rec.body = r.Host, r.ContentLength, r.Header.Clone(), string(body)
+		rec.mu.Unlock()
+		w.Write([]byte(`{"ok":true}`))
+	})
+	server := httptest.NewServer(mux)
+	return server, config.MCPServerConfig{Name: "echo", Address: server.URL}
+}
+
+// TestCommandResourceAccessProtectedHeaders tests that client headers cannot override the
+// backend request's framing in command mode, and that invalid headers are rejected.
+func TestCommandResourceAccessProtectedHeaders(t *testing.T) {
+	var rec receivedRequest
+	backend, conf := testHeaderEchoServer(&rec)
+	defer backend.Close()
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{conf}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	cmdProxy, err := NewCommandProxy(ps)
+	require.NoError(t, err)
+
+	req := `{"jsonrpc":"2.0","id":1,"method":"resources/access","params":{"serverName":"echo","resourceName":"res","method":"POST",
+		"headers":{"Host":"evil.example","Content-Length":"999","Transfer-Encoding":"chunked","Connection":"x-private","X-Private":"secret","X-Keep":"1"},
+		"body":{"a":1}}}`
+	respBytes, err := cmdProxy.handleCommandRequest([]byte(strings.ReplaceAll(req, "\n", "")))
+	require.NoError(t, err)
+	var resp jsonRPCResponse
+	require.NoError(t, json.Unmarshal(respBytes, &resp))
+	require.Nil(t, resp.Error, string(respBytes))
+
+	rec.mu.Lock()
+	assert.Equal(t, strings.TrimPrefix(backend.URL, "http://"), rec.host)
+	assert.Equal(t, int64(len(`{"a":1}`)), rec.contentLength)
+	assert.Equal(t, `{"a":1}`, rec.body)
+	assert.Empty(t, rec.header.Get("X-Private"))
+	assert.Empty(t, rec.header.Get("Transfer-Encoding"))
+	assert.Equal(t, "1", rec.header.Get("X-Keep"))
+	rec.mu.Unlock()
+
+	req = `{"jsonrpc":"2.0","id":2,"method":"resources/access","params":{"serverName":"echo","resourceName":"res","method":"GET","headers":{"Bad Name":"x"}}}`
+	respBytes, err = cmdProxy.handleCommandRequest([]byte(req))
+	require.NoError(t, err)
+	resp = jsonRPCResponse{}
+	require.NoError(t, json.Unmarshal(respBytes, &resp))
+	require.NotNil(t, resp.Error, string(respBytes))
+	assert.Equal(t, -32602, resp.Error.Code)
+}
+
+// TestHTTPResourceProxyProtectedHeaders tests that the HTTP resource proxy drops headers
+// named in Connection and rejects headers over max_header_bytes.
+func TestHTTPResourceProxyProtectedHeaders(t *testing.T) {
+	var rec receivedRequest
+	backend, conf := testHeaderEchoServer(&rec)
+	defer backend.Close()
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{conf}, MaxHeaderBytes: 4096})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/resource/echo/res/page", nil)
+	req.Header.Set("Connection", "x-private")
+	req.Header.Set("X-Private", "secret")
+	req.Header.Set("Proxy-Authorization", "Basic c2VjcmV0")
+	req.Header.Set("X-Keep", "1")
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	rec.mu.Lock()
+	assert.Empty(t, rec.header.Get("X-Private"))
+	assert.Empty(t, rec.header.Get("Proxy-Authorization"))
+	assert.Equal(t, "1", rec.header.Get("X-Keep"))
+	rec.mu.Unlock()
+
+	req = httptest.NewRequest("GET", "/resource/echo/res/page", nil)
+	req.Header.Set("X-Large", strings.Repeat("a", 5000))
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
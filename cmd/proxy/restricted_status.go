@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrToolRestricted is returned for calls to a tool that a server restricts and no
+// server provides, when restricted_status is "forbidden".
+var ErrToolRestricted = errors.New("tool is restricted by the proxy configuration")
+
+// toolNotServed returns the error for a call to a tool no server provides: under
+// restricted_status "forbidden", ErrToolRestricted when a server restricts the tool,
+// otherwise ErrToolNotFound.
+func (ps *ProxyServer) toolNotServed(toolName string) error {
+	if !ps.restrictedNotFound && ps.toolRestricted(toolName) {
+		return fmt.Errorf("%w: %s", ErrToolRestricted, toolName)
+	}
+	return fmt.Errorf("%w: %s", ErrToolNotFound, toolName)
+}
+
+// toolRestricted reports whether a server discovered the tool and restricts it.
+func (ps *ProxyServer) toolRestricted(toolName string) bool {
+	for _, server := range ps.mcpServers {
+		if slices.ContainsFunc(server.GetRestrictedTools(), func(tool config.ToolInfo) bool { return tool.Name == toolName }) {
+			return true
+		}
+	}
+	return false
+}
+
+// respondResourceRestricted answers an HTTP request for a resource the server does not
+// allow: 403, or 404 as for a missing resource under restricted_status "not_found".
+func (h *HTTPProxy) respondResourceRestricted(c *gin.Context, serverName, resourceName string) {
+	if h.ps.restrictedNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("resource '%s' not found on server '%s'", resourceName, serverName)})
+		return
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("resource '%s' not allowed on server '%s'", resourceName, serverName)})
+}
+
+// resourceRestrictedError is the command mode counterpart of respondResourceRestricted.
+func (ps *ProxyServer) resourceRestrictedError(serverName, resourceName string) *rpcError {
+	if ps.restrictedNotFound {
+		return &rpcError{Code: -32002, Message: fmt.Sprintf("Resource '%s' not found on server '%s'", resourceName, serverName)}
+	}
+	return &rpcError{Code: -32002, Message: fmt.Sprintf("Resource '%s' not allowed on server '%s'", resourceName, serverName)}
+}
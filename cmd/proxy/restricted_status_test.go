@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRestrictedStatus tests that restricted tools and resources are answered alike
+// under each restricted_status, in HTTP and command mode, while unknown tools stay 404.
+func TestRestrictedStatus(t *testing.T) {
+	backend := proxytest.NewBackend(
+		[]proxytest.Tool{{Name: "search"}, {Name: "secret"}},
+		[]proxytest.Resource{{Name: "doc", URI: "file:///doc"}, {Name: "private", URI: "file:///private"}},
+	)
+	defer backend.Close()
+
+	tests := []struct {
+		restrictedStatus string
+		httpStatus       int
+		toolCode         int // Command mode error code of a call to the restricted tool
+		message          string
+	}{
+		{"", http.StatusForbidden, -32002, "not allowed"},
+		{config.RestrictedStatusForbidden, http.StatusForbidden, -32002, "not allowed"},
+		{config.RestrictedStatusNotFound, http.StatusNotFound, -32000, "not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.restrictedStatus, func(t *testing.T) {
+			ps, err := NewProxyServer(&config.Config{
+				MCPServers: []config.MCPServerConfig{{
+					Name:             "server1",
+					Address:          backend.URL,
+					AllowedTools:     []string{"search"},
+					AllowedResources: []string{"doc"},
+				}},
+				RestrictedStatus: tt.restrictedStatus,
+			})
+			require.NoError(t, err)
+			defer ps.Shutdown()
+			httpProxy, err := NewHTTPProxy(ps, ":0")
+			require.NoError(t, err)
+			cmdProxy, err := NewCommandProxy(ps)
+			require.NoError(t, err)
+
+			callTool := func(name string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest("POST", "/tool/"+name, strings.NewReader(`{}`))
+				req.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+				httpProxy.engine.ServeHTTP(w, req)
+				return w
+			}
+			w := callTool("secret")
+			assert.Equal(t, tt.httpStatus, w.Code, w.Body.String())
+			assert.Contains(t, w.Body.String(), tt.message)
+			assert.Equal(t, http.StatusNotFound, callTool("unknown").Code)
+
+			w = httptest.NewRecorder()
+			httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", "/resource/server1/private", nil))
+			assert.Equal(t, tt.httpStatus, w.Code, w.Body.String())
+			assert.Contains(t, w.Body.String(), tt.message)
+
+			command := func(request string) *rpcError {
+				respBytes, err := cmdProxy.handleCommandRequest([]byte(request))
+				require.NoError(t, err)
+				var resp jsonRPCResponse
+				require.NoError(t, json.Unmarshal(respBytes, &resp))
+				require.NotNil(t, resp.Error)
+				return resp.Error
+			}
+			rpcErr := command(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"secret","arguments":{}}}`)
+			assert.Equal(t, tt.toolCode, rpcErr.Code)
+			rpcErr = command(`{"jsonrpc":"2.0","id":2,"method":"resources/access","params":{"serverName":"server1","resourceName":"private","method":"GET"}}`)
+			assert.Equal(t, -32002, rpcErr.Code)
+			assert.Contains(t, rpcErr.Message, tt.message)
+			assert.Empty(t, backend.Calls(), "restricted tools are never called")
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRestrictedByReasons tests that restricted listings give the reason for each
+// restriction cause, over HTTP and in command mode.
+func TestRestrictedByReasons(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tools":
+			w.Write([]byte(`{"tools":[
+				{"name":"allowed","inputSchema":{"type":"object"}},
+				{"name":"unlisted","inputSchema":{"type":"object"}},
+				{"name":"bad-schema","inputSchema":null}
+			]}`))
+		case "/resources":
+			w.Write([]byte(`{"resources":[
+				{"name":"doc","uri":"file:///doc","mimeType":"text/plain"},
+				{"name":"image","uri":"file:///image","mimeType":"image/png"},
+				{"name":"secret","uri":"file:///secret","mimeType":"text/plain"}
+			]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer backend.Close()
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{{
+		Name:             "server1",
+		Address:          backend.URL,
+		AllowedTools:     []string{"allowed", "bad-schema"},
+		AllowedResources: []string{"doc", "image"},
+		DeniedMimeTypes:  []string{"image/*"},
+		StrictSchemas:    true,
+	}}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+
+	wantTools := map[string]string{
+		"unlisted":   config.RestrictedByServerAllowlist,
+		"bad-schema": config.RestrictedBySchemaRule,
+	}
+	wantResources := map[string]string{
+		"secret": config.RestrictedByServerAllowlist,
+		"image":  config.RestrictedByServerDenylist,
+	}
+
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+	var httpResp struct {
+		Tools     []RestrictedToolInfo     `json:"tools"`
+		Resources []RestrictedResourceInfo `json:"resources"`
+	}
+	for _, path := range []string{"/restricted-tools", "/restricted-resources"} {
+		w := httptest.NewRecorder()
+		httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &httpResp))
+	}
+	assert.Equal(t, wantTools, restrictedToolReasons(httpResp.Tools))
+	assert.Equal(t, wantResources, restrictedResourceReasons(httpResp.Resources))
+
+	cmdProxy, err := NewCommandProxy(ps)
+	require.NoError(t, err)
+	var cmdResp testRestrictedToolsAndResourceResponse
+	for _, method := range []string{"restrictedTools/list", "restrictedResources/list"} {
+		respBytes, err := cmdProxy.handleCommandRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"` + method + `"}`))
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(respBytes, &cmdResp))
+	}
+	assert.Equal(t, wantTools, restrictedToolReasons(cmdResp.Result.Tools))
+	assert.Equal(t, wantResources, restrictedResourceReasons(cmdResp.Result.Resources))
+}
+
+// restrictedToolReasons maps each restricted tool to its restrictedBy reason.
+func restrictedToolReasons(tools []RestrictedToolInfo) map[string]string {
+	reasons := make(map[string]string)
+	for _, tool := range tools {
+		reasons[tool.Name] = tool.RestrictedBy
+	}
+	return reasons
+}
+
+// restrictedResourceReasons maps each restricted resource to its restrictedBy reason.
+func restrictedResourceReasons(resources []RestrictedResourceInfo) map[string]string {
+	reasons := make(map[string]string)
+	for _, resource := range resources {
+		reasons[resource.Name] = resource.RestrictedBy
+	}
+	return reasons
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testWeatherServer starts a backend whose "weather" tool declares an outputSchema and
+// answers with the given structuredContent.
+func testWeatherServer(structured string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tools", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tools":[{"name":"weather","inputSchema":{"type":"object"},"outputSchema":{
+			"type":"object",
+			"properties":{"city":{"type":"string"},"celsius":{"type":"number","minimum":-90}},
+			"required":["city","celsius"]}}]}`))
+	})
+	mux.HandleFunc("/resources", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"resources":[]}`))
+	})
+	mux.HandleFunc("/tool/weather", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"content":[{"type":"text","text":"ok"}],"isError":false,"structuredContent":` + structured + `}`))
+	})
+	return httptest.NewServer(mux)
+}
+
+// TestValidateToolResults tests that structured results are checked against the
+// tool's outputSchema when validate_results is enabled.
+func TestValidateToolResults(t *testing.T) {
+	newProxy := func(t *testing.T, structured string, validate bool) *ProxyServer {
+		backend := testWeatherServer(structured)
+		t.Cleanup(backend.Close)
+		ps, err := NewProxyServer(&config.Config{
+			ValidateResults: validate,
+			MCPServers:      []config.MCPServerConfig{{Name: "weather", Address: backend.URL}},
+		})
+		require.NoError(t, err)
+		t.Cleanup(ps.Shutdown)
+		return ps
+	}
+
+	t.Run("conforming result", func(t *testing.T) {
+		ps := newProxy(t, `{"city":"Oslo","celsius":4.5}`, true)
+		result, err := ps.CallTool("weather", map[string]interface{}{})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"city": "Oslo", "celsius": 4.5}, result.StructuredContent)
+	})
+
+	t.Run("non-conforming result", func(t *testing.T) {
+		ps := newProxy(t, `{"city":"Oslo","celsius":"warm"}`, true)
+		_, err := ps.CallTool("weather", map[string]interface{}{})
+		require.ErrorIs(t, err, ErrInvalidResult)
+		assert.Contains(t, err.Error(), "celsius: expected number, got string")
+
+		status, _ := toolCallErrorStatus("weather", err)
+		assert.Equal(t, http.StatusBadGateway, status)
+	})
+
+	t.Run("missing structuredContent", func(t *testing.T) {
+		ps := newProxy(t, `null`, true)
+		_, err := ps.CallTool("weather", map[string]interface{}{})
+		require.ErrorIs(t, err, ErrInvalidResult)
+	})
+
+	t.Run("validation disabled", func(t *testing.T) {
+		ps := newProxy(t, `{"city":"Oslo","celsius":"warm"}`, false)
+		_, err := ps.CallTool("weather", map[string]interface{}{})
+		require.NoError(t, err)
+	})
+}
+
+// TestStructuredResultRoundTrip tests that outputSchema is listed and structuredContent
+// is returned untouched over HTTP and command mode, from HTTP and stdio backends.
+func TestStructuredResultRoundTrip(t *testing.T) {
+	backend := testWeatherServer(`{"city":"Oslo","celsius":4.5,"extra":{"nested":[1,2]}}`)
+	defer backend.Close()
+	forecaster := proxytest.StdioBackend{
+		Tools: []proxytest.Tool{{
+			Name:         "forecast",
+			OutputSchema: map[string]interface{}{"type": "object", "properties": map[string]interface{}{"days": map[string]interface{}{"type": "array"}}},
+		}},
+		Calls: map[string]string{"forecast": proxytest.Echo(`{"content":[{"type":"text","text":"ok"}],"structuredContent":{"days":["sun","rain"]}}`)},
+	}
+	ps, err := NewProxyServer(&config.Config{
+		ValidateResults: true,
+		MCPServers: []config.MCPServerConfig{
+			{Name: "weather", Address: backend.URL, AllowedTools: []string{"weather"}},
+			{Name: "forecaster", Command: forecaster.Command(), Args: forecaster.Args(), AllowedTools: []string{"forecast"}},
+		},
+	})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+	cmdProxy, err := NewCommandProxy(ps)
+	require.NoError(t, err)
+
+	serve := func(method, path string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		httpProxy.engine.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body
+	}
+
+	// Listings and the describe endpoint carry outputSchema
+	outputSchemas := map[string]interface{}{}
+	for _, tool := range serve("GET", "/tools")["tools"].([]interface{}) {
+		tool := tool.(map[string]interface{})
+		outputSchemas[tool["name"].(string)] = tool["outputSchema"]
+	}
+	assert.Contains(t, outputSchemas["weather"], "required")
+	assert.NotNil(t, outputSchemas["forecast"])
+	assert.Contains(t, serve("GET", "/tools/weather"), "outputSchema")
+
+	// HTTP mode returns structuredContent as the backend sent it
+	assert.Equal(t, map[string]interface{}{"city": "Oslo", "celsius": 4.5, "extra": map[string]interface{}{"nested": []interface{}{1.0, 2.0}}},
+		serve("POST", "/tool/weather")["structuredContent"])
+	assert.Equal(t, map[string]interface{}{"days": []interface{}{"sun", "rain"}}, serve("POST", "/tool/forecast")["structuredContent"])
+
+	// So does command mode
+	respBytes, err := cmdProxy.handleCommandRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"forecast","arguments":{}}}`))
+	require.NoError(t, err)
+	assert.Contains(t, string(respBytes), `"structuredContent":{"days":["sun","rain"]}`)
+	respBytes, err = cmdProxy.handleCommandRequest([]byte(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`))
+	require.NoError(t, err)
+	assert.Contains(t, string(respBytes), `"outputSchema":{"properties":{"days":{"type":"array"}},"type":"object"}`)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"sync"
+
+	"smart-mcp-proxy/internal/config"
+)
+
+// serverIndex maps names to the servers that routing picks from, so a lookup does not
+// scan every server's allow-list. The tool and resource maps hold the discovered names
+// each server allows, and the owners the servers resource URIs resolve to; they are
+// rebuilt whenever discovery changes them.
+type serverIndex struct {
+	mu        sync.RWMutex
+	byName    map[string]*config.MCPServer
+	tools     map[string][]*config.MCPServer // Servers allowing a discovered tool, in configuration order
+	resources map[string][]*config.MCPServer // Servers owning a discovered resource, in configuration order; empty when all are hidden by resource_conflicts
+	owners    map[string]string              // Server owning each resource URI; "" for a URI hidden by resource_conflicts
+	conflicts []ResourceConflict             // Resource URIs exposed by more than one server
+
+	resolver *resourceResolver
+}
+
+// newServerIndex indexes servers by name and by their discovered tools and resources,
+// with resource URIs exposed by more than one server resolved by resolver.
+func newServerIndex(servers []*config.MCPServer, resolver *resourceResolver) *serverIndex {
+	idx := &serverIndex{byName: make(map[string]*config.MCPServer, len(servers)), resolver: resolver}
+	for _, server := range servers {
+		idx.byName[server.Config.Name] = server
+	}
+	idx.rebuild(servers)
+	return idx
+}
+
+// rebuild re-indexes the discovered tools and resources of servers. It runs under the
+// write lock so concurrent rebuilds cannot store an older view over a newer one.
+func (idx *serverIndex) rebuild(servers []*config.MCPServer) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.tools = make(map[string][]*config.MCPServer)
+	idx.resources = make(map[string][]*config.MCPServer)
+	idx.owners, idx.conflicts = resolveResourceOwners(servers, idx.resolver)
+	for _, server := range servers {
+		for _, tool := range server.GetTools() {
+			idx.tools[tool.Name] = appendServer(idx.tools[tool.Name], server)
+		}
+		for _, resource := range server.GetResources() {
+			if ownsResource(idx.owners, server, resource) {
+				idx.resources[resource.Name] = appendServer(idx.resources[resource.Name], server)
+			} else if _, ok := idx.resources[resource.Name]; !ok {
+				idx.resources[resource.Name] = nil
+			}
+		}
+	}
+}
+
+// appendServer appends server unless it is already last, as a server listing a name
+// twice is indexed once.
+func appendServer(servers []*config.MCPServer, server *config.MCPServer) []*config.MCPServer {
+	if n := len(servers); n > 0 && servers[n-1] == server {
+		return servers
+	}
+	return append(servers, server)
+}
+
+// server returns the server with the given name, or nil.
+func (idx *serverIndex) server(name string) *config.MCPServer {
+	return idx.byName[name] // Servers are fixed once the proxy is built
+}
+
+// toolServers returns the servers whose discovered tools include toolName; none for a
+// nil index.
+func (idx *serverIndex) toolServers(toolName string) []*config.MCPServer {
+	if idx == nil {
+		return nil
+	}
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.tools[toolName]
+}
+
+// resourceServers returns the servers owning a discovered resource named
+// resourceName, and whether any server discovered it; none for a nil index.
+func (idx *serverIndex) resourceServers(resourceName string) ([]*config.MCPServer, bool) {
+	if idx == nil {
+		return nil, false
+	}
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	servers, ok := idx.resources[resourceName]
+	return servers, ok
+}
+
+// resourceOwners returns the server owning each discovered resource URI and the URIs
+// exposed by more than one server; none for a nil index.
+func (idx *serverIndex) resourceOwners() (map[string]string, []ResourceConflict) {
+	if idx == nil {
+		return nil, nil
+	}
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.owners, idx.conflicts
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestServerIndex tests that tool lookups go to the servers that discovered the tool,
+// in configuration order, that names no server discovered fall back to the allow-lists,
+// and that a refresh re-indexes the changed server.
+func TestServerIndex(t *testing.T) {
+	first := proxytest.NewBackend([]proxytest.Tool{{Name: "shared"}}, nil)
+	defer first.Close()
+
+	var mu sync.Mutex
+	tools := []string{"shared", "search"}
+	second := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/tools":
+			listed := make([]map[string]string, len(tools))
+			for i, name := range tools {
+				listed[i] = map[string]string{"name": name}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"tools": listed})
+		case "/resources":
+			w.Write([]byte(`{"resources":[]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer second.Close()
+
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{
+		{Name: "first", Address: first.URL}, // No allow-list: allows every name
+		{Name: "second", Address: second.URL},
+	}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+
+	assert.Equal(t, "second", ps.findMCPServerByName("second").Config.Name)
+	assert.Nil(t, ps.findMCPServerByName("third"))
+	assert.Equal(t, "first", ps.findMCPServerByTool("shared").Config.Name, "configuration order decides between servers")
+	assert.Equal(t, "second", ps.findMCPServerByTool("search").Config.Name, "only the second server discovered it")
+	assert.Equal(t, "first", ps.findMCPServerByTool("undiscovered").Config.Name, "allowed by the first server's empty allow-list")
+
+	mu.Lock()
+	tools = []string{"fetch"}
+	mu.Unlock()
+	_, err = ps.RefreshServers("second")
+	require.NoError(t, err)
+	assert.Equal(t, "second", ps.findMCPServerByTool("fetch").Config.Name)
+	assert.Equal(t, []*config.MCPServer{ps.findMCPServerByName("first")}, ps.servers.toolServers("shared"))
+}
+
+// BenchmarkFindMCPServerByTool compares the index with scanning the allow-lists of 50
+// servers exposing 20 tools each, looking up a tool of the last server.
+func BenchmarkFindMCPServerByTool(b *testing.B) {
+	var servers []config.MCPServerConfig
+	for s := 0; s < 50; s++ {
+		tools := make([]proxytest.Tool, 20)
+		allowed := make([]string, 20)
+		for i := range tools {
+			allowed[i] = fmt.Sprintf("server%d_tool%d", s, i)
+			tools[i] = proxytest.Tool{Name: allowed[i]}
+		}
+		backend := proxytest.NewBackend(tools, nil)
+		b.Cleanup(backend.Close)
+		servers = append(servers, config.MCPServerConfig{Name: fmt.Sprintf("server%d", s), Address: backend.URL, AllowedTools: allowed})
+	}
+	ps, err := NewProxyServer(&config.Config{MCPServers: servers})
+	require.NoError(b, err)
+	b.Cleanup(ps.Shutdown)
+	const toolName = "server49_tool19"
+
+	b.Run("index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if ps.findMCPServerByTool(toolName) == nil {
+				b.Fatal("tool not found")
+			}
+		}
+	})
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if ps.pickServer(ps.mcpServers, func(server *config.MCPServer) bool { return server.IsToolAllowed(toolName) }) == nil {
+				b.Fatal("tool not found")
+			}
+		}
+	})
+}
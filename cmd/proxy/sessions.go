@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+)
+
+// clientSession is the state of a client of the streamable-HTTP MCP endpoint, keyed by
+// its Mcp-Session-Id.
+type clientSession struct {
+	ID              string                 `json:"id"`
+	ProtocolVersion string                 `json:"protocolVersion"`
+	Capabilities    map[string]interface{} `json:"capabilities,omitempty"` // Client capabilities sent in initialize
+	ClientInfo      map[string]interface{} `json:"clientInfo,omitempty"`
+	// ServerCapabilities are the capabilities the proxy advertised in its initialize
+	// result. They stay fixed for the session; later backend changes are announced with
+	// list_changed notifications.
+	ServerCapabilities map[string]interface{} `json:"serverCapabilities,omitempty"`
+	Initialized        bool                   `json:"initialized"`             // notifications/initialized was received
+	Subscriptions      []string               `json:"subscriptions,omitempty"` // Resource URIs from resources/subscribe
+	Affinity           map[string]string      `json:"affinity,omitempty"`      // Server that last served each tool
+	CreatedAt          time.Time              `json:"createdAt"`
+	LastSeen           time.Time              `json:"lastSeen"`
+}
+
+// ErrTooManySessions is returned for an initialize request while sessions.max_sessions
+// sessions are open.
+var ErrTooManySessions = errors.New("too many open sessions")
+
+// sessionSweepInterval is how often expired sessions are removed in the background, on
+// top of the sweeps of each request.
+const sessionSweepInterval = time.Minute
+
+// sessionFlushInterval is how often a persisted store writes sessions whose only change
+// is LastSeen. Other changes are written at once.
+const sessionFlushInterval = 30 * time.Second
+
+// sessionStore holds the sessions of the streamable-HTTP MCP endpoint. A session expires
+// ttl after its last request; at most maxSessions are open, unless it is 0. When path is set, sessions are written to it when they
+// are created, deleted, expire or change state, LastSeen at most every
+// sessionFlushInterval, and loaded from it on startup, so they survive restarts.
+type sessionStore struct {
+	mu          sync.Mutex
+	sessions    map[string]*clientSession
+	ttl         time.Duration
+	maxSessions int
+	path        string
+	now         func() time.Time
+
+	stop     chan struct{} // Closed by close to end runSweeps
+	stopOnce sync.Once
+
+	dirty   bool      // LastSeen changed since the last snapshot
+	savedAt time.Time // Time of the last snapshot
+	version uint64    // Of the last snapshot
+
+	// Snapshots are taken under mu but written under writeMu, so requests do not wait
+	// on the disk; written keeps an older snapshot from replacing a newer one.
+	writeMu sync.Mutex
+	written uint64
+}
+
+// sessionSnapshot is the encoded sessions file, numbered in the order taken.
+type sessionSnapshot struct {
+	data    []byte
+	version uint64
+}
+
+// newSessionStore creates a session store, loading the sessions persisted to path that
+// have not expired.
+func newSessionStore(ttl time.Duration, path string) (*sessionStore, error) {
+	s := &sessionStore{sessions: make(map[string]*clientSession), ttl: ttl, path: path, now: time.Now, stop: make(chan struct{})}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sessions file: %w", err)
+	}
+	var sessions []*clientSession
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, fmt.Errorf("invalid sessions file %s: %w", path, err)
+	}
+	for _, session := range sessions {
+		s.sessions[session.ID] = session
+	}
+	s.sweep()
+	log.Printf("Loaded %d sessions from %s", len(s.sessions), path)
+	return s, nil
+}
+
+// sessionsFromConfig creates the session store configured by cfg.Sessions.
+func sessionsFromConfig(cfg *config.Config) (*sessionStore, error) {
+	sessions := config.SessionsConfig{}
+	if cfg.Sessions != nil {
+		sessions = *cfg.Sessions
+	}
+	ttl, err := sessions.TTLDuration()
+	if err != nil {
+		return nil, fmt.Errorf("invalid sessions.ttl: %w", err)
+	}
+	s, err := newSessionStore(ttl, sessions.Path)
+	if err != nil {
+		return nil, err
+	}
+	s.maxSessions = sessions.MaxSessionsOrDefault()
+	return s, nil
+}
+
+// runSweeps removes expired sessions every interval until close is called, so sessions
+// of clients that went away are released while no requests arrive.
+func (s *sessionStore) runSweeps(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			var snap *sessionSnapshot
+			if s.sweep() {
+				snap = s.snapshot()
+			}
+			s.mu.Unlock()
+			s.write(snap)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// close stops the periodic sweeps and writes sessions not written yet.
+func (s *sessionStore) close() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	s.flush()
+}
+
+// sweep removes expired sessions and reports whether any was removed. Callers must
+// hold s.mu.
+func (s *sessionStore) sweep() bool {
+	now := s.now()
+	swept := false
+	for id, session := range s.sessions {
+		if now.Sub(session.LastSeen) > s.ttl {
+			delete(s.sessions, id)
+			swept = true
+		}
+	}
+	return swept
+}
+
+// snapshot encodes the sessions when a path is configured, or returns nil. Callers
+// must hold s.mu and pass the result to write once they have released it.
+func (s *sessionStore) snapshot() *sessionSnapshot {
+	if s.path == "" {
+		return nil
+	}
+	sessions := make([]*clientSession, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	data, err := json.Marshal(sessions)
+	if err != nil {
+		log.Printf("Warning: failed to encode sessions: %v", err)
+		return nil
+	}
+	s.dirty = false
+	s.savedAt = s.now()
+	s.version++
+	return &sessionSnapshot{data: data, version: s.version}
+}
+
+// write persists a snapshot unless a newer one was written already. Errors are
+// logged; sessions keep working in memory.
+func (s *sessionStore) write(snap *sessionSnapshot) {
+	if snap == nil {
+		return
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if snap.version <= s.written {
+		return
+	}
+	err := os.MkdirAll(filepath.Dir(s.path), 0o755)
+	if err == nil {
+		// Write then rename so a crash never leaves a truncated file behind
+		tmp := s.path + ".tmp"
+		if err = os.WriteFile(tmp, snap.data, 0o600); err == nil {
+			err = os.Rename(tmp, s.path)
+		}
+	}
+	if err != nil {
+		log.Printf("Warning: failed to persist sessions to %s: %v", s.path, err)
+		return
+	}
+	s.written = snap.version
+}
+
+// flush writes sessions whose LastSeen changed since they were last written. It is
+// called on shutdown.
+func (s *sessionStore) flush() {
+	s.mu.Lock()
+	var snap *sessionSnapshot
+	if s.dirty {
+		snap = s.snapshot()
+	}
+	s.mu.Unlock()
+	s.write(snap)
+}
+
+// create starts a session for an initialize request. While maxSessions sessions are open
+// it fails with ErrTooManySessions, throttled until the first of them expires.
+func (s *sessionStore) create(protocolVersion string, capabilities, clientInfo, serverCapabilities map[string]interface{}) (clientSession, error) {
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return clientSession{}, fmt.Errorf("failed to generate session ID: %w", err)
+	}
+
+	s.mu.Lock()
+	s.sweep()
+	now := s.now()
+	if s.maxSessions > 0 && len(s.sessions) >= s.maxSessions {
+		retryAfter := s.ttl
+		for _, session := range s.sessions {
+			retryAfter = min(retryAfter, session.LastSeen.Add(s.ttl).Sub(now))
+		}
+		s.mu.Unlock()
+		return clientSession{}, throttled(throttleOverloaded, retryAfter, ErrTooManySessions)
+	}
+	session := &clientSession{
+		ID:                 hex.EncodeToString(idBytes),
+		ProtocolVersion:    protocolVersion,
+		Capabilities:       capabilities,
+		ClientInfo:         clientInfo,
+		CreatedAt:          now,
+		ServerCapabilities: serverCapabilities,
+		LastSeen:           now,
+	}
+	s.sessions[session.ID] = session
+	snap := s.snapshot()
+	created := *session
+	s.mu.Unlock()
+	s.write(snap)
+	return created, nil
+}
+
+// update marks a session as seen, applies change to it when not nil, and returns a
+// copy. It returns false when the session does not exist or has expired. Only a change
+// that alters the session is written at once.
+func (s *sessionStore) update(id string, change func(*clientSession)) (clientSession, bool) {
+	s.mu.Lock()
+	snap, session, ok := s.updateLocked(id, change)
+	s.mu.Unlock()
+	s.write(snap)
+	return session, ok
+}
+
+// updateLocked is update under s.mu, returning the snapshot to write.
+func (s *sessionStore) updateLocked(id string, change func(*clientSession)) (*sessionSnapshot, clientSession, bool) {
+	swept := s.sweep()
+	session, ok := s.sessions[id]
+	if !ok {
+		if swept {
+			return s.snapshot(), clientSession{}, false
+		}
+		return nil, clientSession{}, false
+	}
+	session.LastSeen = s.now()
+	changed := swept
+	if change != nil {
+		before := session.state()
+		change(session)
+		changed = changed || !bytes.Equal(before, session.state())
+	}
+	var snap *sessionSnapshot
+	switch {
+	case changed:
+		snap = s.snapshot()
+	case s.path != "":
+		s.dirty = true
+		if s.now().Sub(s.savedAt) >= sessionFlushInterval {
+			snap = s.snapshot()
+		}
+	}
+	copied := *session
+	copied.Subscriptions = slices.Clone(session.Subscriptions)
+	copied.Affinity = make(map[string]string, len(session.Affinity))
+	for tool, server := range session.Affinity {
+		copied.Affinity[tool] = server
+	}
+	return snap, copied, true
+}
+
+// state encodes the session without LastSeen, to tell a change of state from a request
+// that only marks it as seen.
+func (session *clientSession) state() []byte {
+	copied := *session
+	copied.LastSeen = time.Time{}
+	data, _ := json.Marshal(copied)
+	return data
+}
+
+// get is update without a change.
+func (s *sessionStore) get(id string) (clientSession, bool) {
+	return s.update(id, nil)
+}
+
+// delete ends a session, reporting whether it existed.
+func (s *sessionStore) delete(id string) bool {
+	s.mu.Lock()
+	s.sweep()
+	if _, ok := s.sessions[id]; !ok {
+		s.mu.Unlock()
+		return false
+	}
+	delete(s.sessions, id)
+	snap := s.snapshot()
+	s.mu.Unlock()
+	s.write(snap)
+	return true
+}
+
+// subscribe adds a resource URI to the session's subscriptions.
+func (session *clientSession) subscribe(uri string) {
+	if !slices.Contains(session.Subscriptions, uri) {
+		session.Subscriptions = append(session.Subscriptions, uri)
+	}
+}
+
+// unsubscribe removes a resource URI from the session's subscriptions.
+func (session *clientSession) unsubscribe(uri string) {
+	session.Subscriptions = slices.DeleteFunc(session.Subscriptions, func(s string) bool { return s == uri })
+}
+
+// pin records the server that served a tool, which the session's later calls of the
+// tool prefer.
+func (session *clientSession) pin(toolName, serverName string) {
+	if session.Affinity == nil {
+		session.Affinity = make(map[string]string)
+	}
+	session.Affinity[toolName] = serverName
+}
+
+// serverAffinity carries a session's preferred server for a tool call through the call's
+// context, and receives the server the call was sent to.
+type serverAffinity struct {
+	preferred string
+	served    string
+}
+
+// affinityKey is the context key holding a *serverAffinity.
+type affinityKey struct{}
+
+// withServerAffinity returns a context whose tool call prefers, and reports, a server.
+func withServerAffinity(ctx context.Context, affinity *serverAffinity) context.Context {
+	return context.WithValue(ctx, affinityKey{}, affinity)
+}
+
+// findToolServer finds the server for a tool call like findMCPServerByTool, preferring
+// the server a session is pinned to while it provides the tool and is healthy.
+func (ps *ProxyServer) findToolServer(ctx context.Context, toolName string) *config.MCPServer {
+	affinity, _ := ctx.Value(affinityKey{}).(*serverAffinity)
+	if affinity == nil {
+		return ps.findMCPServerByTool(toolName)
+	}
+	server := ps.findMCPServerByName(affinity.preferred)
+	if server == nil || !server.IsToolAllowed(toolName) || !ps.serverHealthy(server) {
+		server = ps.findMCPServerByTool(toolName)
+	}
+	if server != nil {
+		affinity.served = server.Config.Name
+	}
+	return server
+}
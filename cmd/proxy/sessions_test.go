@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mcpRequest posts a JSON-RPC request to /mcp in the given session ("" for none).
+func mcpRequest(t *testing.T, h *HTTPProxy, sessionID, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/mcp", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if sessionID != "" {
+		req.Header.Set(sessionIDHeader, sessionID)
+	}
+	w := httptest.NewRecorder()
+	h.engine.ServeHTTP(w, req)
+	return w
+}
+
+// mcpInitialize starts a session and returns its ID.
+func mcpInitialize(t *testing.T, h *HTTPProxy) string {
+	t.Helper()
+	w := mcpRequest(t, h, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{"roots":{}},"clientInfo":{"name":"test"}}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Result struct {
+			ProtocolVersion string                 `json:"protocolVersion"`
+			Capabilities    map[string]interface{} `json:"capabilities"`
+		} `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "2025-03-26", resp.Result.ProtocolVersion)
+	assert.Contains(t, resp.Result.Capabilities, "tools")
+	id := w.Header().Get(sessionIDHeader)
+	require.NotEmpty(t, id)
+	return id
+}
+
+// TestMCPSessionLifecycle tests session creation, its use across requests and its end.
+func TestMCPSessionLifecycle(t *testing.T) {
+	httpProxy, _, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+
+	// Requests other than initialize need a known session
+	w := mcpRequest(t, httpProxy, "", `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = mcpRequest(t, httpProxy, "unknown", `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	id := mcpInitialize(t, httpProxy)
+	session, ok := httpProxy.ps.sessions.get(id)
+	require.True(t, ok)
+	assert.False(t, session.Initialized)
+	assert.Equal(t, map[string]interface{}{"roots": map[string]interface{}{}}, session.Capabilities)
+
+	w = mcpRequest(t, httpProxy, id, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	w = mcpRequest(t, httpProxy, id, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"tool1"`)
+	w = mcpRequest(t, httpProxy, id, `{"jsonrpc":"2.0","id":3,"method":"resources/subscribe","params":{"uri":"file:///a"}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":3,"result":{}}`, w.Body.String())
+	w = mcpRequest(t, httpProxy, id, `{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"tool1","arguments":{}}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "tool /tool/tool1 called")
+
+	session, ok = httpProxy.ps.sessions.get(id)
+	require.True(t, ok)
+	assert.True(t, session.Initialized)
+	assert.Equal(t, []string{"file:///a"}, session.Subscriptions)
+	assert.Equal(t, "server1", session.Affinity["tool1"])
+
+	req := httptest.NewRequest("DELETE", "/mcp", nil)
+	req.Header.Set(sessionIDHeader, id)
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = mcpRequest(t, httpProxy, id, `{"jsonrpc":"2.0","id":5,"method":"ping"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// TestMCPSessionAffinity tests that a session's tool calls stay on the server it is
+// pinned to while other sessions are routed as usual.
+func TestMCPSessionAffinity(t *testing.T) {
+	first := proxytest.NewBackend([]proxytest.Tool{{Name: "shared"}}, nil)
+	defer first.Close()
+	second := proxytest.NewBackend([]proxytest.Tool{{Name: "shared"}}, nil)
+	defer second.Close()
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{
+		{Name: "first", Address: first.URL},
+		{Name: "second", Address: second.URL},
+	}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	pinned := mcpInitialize(t, httpProxy)
+	ps.sessions.update(pinned, func(s *clientSession) { s.pin("shared", "second") })
+	other := mcpInitialize(t, httpProxy)
+
+	call := `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"shared"}}`
+	for range 2 {
+		assert.Equal(t, http.StatusOK, mcpRequest(t, httpProxy, pinned, call).Code)
+	}
+	assert.Equal(t, http.StatusOK, mcpRequest(t, httpProxy, other, call).Code)
+	assert.Len(t, second.Calls(), 2)
+	assert.Len(t, first.Calls(), 1)
+
+	// A pinned server that no longer provides the tool is replaced
+	ps.sessions.update(pinned, func(s *clientSession) { s.pin("shared", "gone") })
+	assert.Equal(t, http.StatusOK, mcpRequest(t, httpProxy, pinned, call).Code)
+	session, _ := ps.sessions.get(pinned)
+	assert.Equal(t, "first", session.Affinity["shared"])
+}
+
+// TestSessionStoreExpiryAndPersistence tests that idle sessions expire and that
+// sessions persisted to a file are loaded again.
+func TestSessionStoreExpiryAndPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions", "sessions.json")
+	now := time.Now()
+	store, err := newSessionStore(time.Minute, path)
+	require.NoError(t, err)
+	store.now = func() time.Time { return now }
+
+	idle, err := store.create("2025-06-18", nil, nil, nil)
+	require.NoError(t, err)
+	active, err := store.create("2025-06-18", nil, nil, nil)
+	require.NoError(t, err)
+	store.update(active.ID, func(s *clientSession) { s.subscribe("file:///a") })
+
+	// Requests keep a session alive past the TTL from its creation
+	now = now.Add(45 * time.Second)
+	_, ok := store.get(active.ID)
+	require.True(t, ok)
+	now = now.Add(30 * time.Second)
+	_, ok = store.get(idle.ID)
+	assert.False(t, ok, "idle session should have expired")
+
+	reloaded, err := newSessionStore(time.Hour, path)
+	require.NoError(t, err)
+	session, ok := reloaded.get(active.ID)
+	require.True(t, ok)
+	assert.Equal(t, []string{"file:///a"}, session.Subscriptions)
+	_, ok = reloaded.get(idle.ID)
+	assert.False(t, ok)
+}
+
+// TestSessionStoreWrites tests that a persisted store writes state changes at once but
+// LastSeen only every sessionFlushInterval and on flush.
+func TestSessionStoreWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	now := time.Now()
+	store, err := newSessionStore(time.Hour, path)
+	require.NoError(t, err)
+	store.now = func() time.Time { return now }
+	session, err := store.create("2025-06-18", nil, nil, nil)
+	require.NoError(t, err)
+
+	lastSeen := func() time.Time {
+		reloaded, err := newSessionStore(time.Hour, path)
+		require.NoError(t, err)
+		return reloaded.sessions[session.ID].LastSeen
+	}
+	written := lastSeen()
+
+	now = now.Add(time.Second)
+	store.get(session.ID)
+	store.update(session.ID, func(s *clientSession) { s.Initialized = false })
+	assert.True(t, lastSeen().Equal(written), "requests that change nothing are not written")
+
+	store.update(session.ID, func(s *clientSession) { s.pin("tool", "server") })
+	assert.True(t, lastSeen().Equal(now), "a change of state is written at once")
+	written = now
+
+	now = now.Add(time.Second)
+	store.get(session.ID)
+	store.flush()
+	assert.True(t, lastSeen().Equal(now), "flush writes the pending LastSeen")
+
+	now = now.Add(sessionFlushInterval)
+	store.get(session.ID)
+	assert.True(t, lastSeen().Equal(now), "LastSeen is written every sessionFlushInterval")
+}
+
+// TestMCPSessionLimit tests that initialize is refused while sessions.max_sessions are
+// open, until one ends.
+func TestMCPSessionLimit(t *testing.T) {
+	httpProxy, ps, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+	ps.sessions.maxSessions = 2
+
+	first := mcpInitialize(t, httpProxy)
+	mcpInitialize(t, httpProxy)
+	w := mcpRequest(t, httpProxy, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code, w.Body.String())
+	assert.Equal(t, "1800", w.Header().Get("Retry-After"), "until the oldest session expires")
+	assert.Empty(t, w.Header().Get(sessionIDHeader))
+	var resp jsonRPCResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, -32000, resp.Error.Code)
+
+	req := httptest.NewRequest("DELETE", "/mcp", nil)
+	req.Header.Set(sessionIDHeader, first)
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	mcpInitialize(t, httpProxy)
+}
+
+// TestSessionStoreSweeps tests that expired sessions are removed, and the removal
+// persisted, without a request arriving.
+func TestSessionStoreSweeps(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	var mu sync.Mutex
+	now := time.Now()
+	store, err := newSessionStore(time.Minute, path)
+	require.NoError(t, err)
+	store.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	_, err = store.create("2025-06-18", nil, nil, nil)
+	require.NoError(t, err)
+
+	mu.Lock()
+	now = now.Add(2 * time.Minute)
+	mu.Unlock()
+	go store.runSweeps(time.Millisecond)
+	defer store.close()
+	assert.Eventually(t, func() bool {
+		data, err := os.ReadFile(path)
+		return err == nil && string(data) == "[]"
+	}, time.Second, time.Millisecond)
+}
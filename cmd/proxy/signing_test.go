@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRequestSigning tests that tool calls and resource requests reach the backend
+// with a signature that verifies with the shared secret.
+func TestRequestSigning(t *testing.T) {
+	const secret = "shared-secret"
+	var mu sync.Mutex
+	verified := make(map[string]bool)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tools":
+			w.Write([]byte(`{"tools":[{"name":"echo","inputSchema":{"type":"object"}}]}`))
+			return
+		case "/resources":
+			w.Write([]byte(`{"resources":[{"name":"doc","uri":"file:///doc"}]}`))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		timestamp := r.Header.Get("X-Request-Time")
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(timestamp + "." + string(body)))
+		want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		mu.Lock()
+		verified[r.URL.Path] = timestamp != "" && hmac.Equal([]byte(want), []byte(r.Header.Get("X-Hub-Signature")))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"content":[]}`))
+	}))
+	defer backend.Close()
+
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{{
+		Name:            "signed",
+		Address:         backend.URL,
+		SigningSecret:   secret,
+		SignatureHeader: "X-Hub-Signature",
+		TimestampHeader: "X-Request-Time",
+	}}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	_, err = ps.CallTool("echo", map[string]interface{}{"text": "hello"})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/resource/signed/doc/search", strings.NewReader(`{"q":1}`))
+	req.Header.Set("X-Hub-Signature", "sha256=forged")
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string]bool{"/tool/echo": true, "/resource/doc/search": true}, verified)
+}
+
+// TestRequestSigningJSONRPC tests that the JSON-RPC requests of discovery and tool
+// calls are signed over their body.
+func TestRequestSigningJSONRPC(t *testing.T) {
+	const secret = "shared-secret"
+	var mu sync.Mutex
+	verified := make(map[string]bool)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp := r.Header.Get(config.DefaultTimestampHeader)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(timestamp + "." + string(body)))
+		want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		var req struct {
+			ID     int64  `json:"id"`
+			Method string `json:"method"`
+		}
+		require.NoError(t, json.Unmarshal(body, &req))
+		mu.Lock()
+		verified[req.Method] = timestamp != "" && hmac.Equal([]byte(want), []byte(r.Header.Get(config.DefaultSignatureHeader)))
+		mu.Unlock()
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "tools/list":
+			resp["result"] = map[string]interface{}{"tools": []interface{}{map[string]interface{}{"name": "echo", "inputSchema": map[string]interface{}{"type": "object"}}}}
+		case "tools/call":
+			resp["result"] = map[string]interface{}{"content": []interface{}{}}
+		default:
+			resp["error"] = map[string]interface{}{"code": -32601, "message": "Method not found"}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer backend.Close()
+
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{{
+		Name:          "signed",
+		Address:       backend.URL,
+		Discovery:     config.DiscoveryJSONRPC,
+		SigningSecret: secret,
+	}}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+
+	_, err = ps.CallTool("echo", map[string]interface{}{"text": "hello"})
+	require.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string]bool{"initialize": true, "tools/list": true, "resources/list": true, "tools/call": true}, verified)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+)
+
+// SLO compliance is reported over a rolling window split into sloBuckets buckets.
+const (
+	sloWindow  = 5 * time.Minute
+	sloBuckets = 10
+)
+
+// sloBucket counts responses within and over the SLO in one slice of the window.
+type sloBucket struct {
+	start      time.Time
+	met        int
+	violations int
+}
+
+// sloTracker keeps the rolling SLO compliance of servers with slo_ms set.
+type sloTracker struct {
+	mu      sync.Mutex
+	servers map[string]*[sloBuckets]sloBucket
+	now     func() time.Time
+}
+
+func newSLOTracker() *sloTracker {
+	return &sloTracker{servers: make(map[string]*[sloBuckets]sloBucket), now: time.Now}
+}
+
+// observe records whether one response of server met its SLO.
+func (t *sloTracker) observe(server string, met bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	buckets, ok := t.servers[server]
+	if !ok {
+		buckets = &[sloBuckets]sloBucket{}
+		t.servers[server] = buckets
+	}
+	width := sloWindow / sloBuckets
+	start := t.now().Truncate(width)
+	b := &buckets[(start.UnixNano()/int64(width))%sloBuckets]
+	if !b.start.Equal(start) {
+		*b = sloBucket{start: start}
+	}
+	if met {
+		b.met++
+	} else {
+		b.violations++
+	}
+}
+
+// compliance returns the share of each server's responses within the window that met
+// its SLO. Servers without responses in the window are left out; a nil tracker has
+// none.
+func (t *sloTracker) compliance() map[string]float64 {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	ratios := make(map[string]float64)
+	for server, buckets := range t.servers {
+		var met, violations int
+		for _, b := range buckets {
+			if now.Sub(b.start) < sloWindow {
+				met += b.met
+				violations += b.violations
+			}
+		}
+		if met+violations > 0 {
+			ratios[server] = float64(met) / float64(met+violations)
+		}
+	}
+	return ratios
+}
+
+// observeSLO checks the duration of one tool call attempt or resource request against
+// the server's slo_ms, counting a violation when it took longer.
+func (ps *ProxyServer) observeSLO(server *config.MCPServer, duration time.Duration) {
+	slo := server.Config.SLO()
+	if slo <= 0 {
+		return
+	}
+	met := duration <= slo
+	if !met && sloViolationsTotal != nil { // Check if initialized
+		sloViolationsTotal.WithLabelValues(server.Config.Name).Inc()
+	}
+	ps.slo.observe(server.Config.Name, met)
+}
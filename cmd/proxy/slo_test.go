@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSLOViolations tests that calls slower than a server's slo_ms increment
+// mcp_proxy_slo_violations_total and lower its compliance in /healthz, while servers
+// without slo_ms are not tracked.
+func TestSLOViolations(t *testing.T) {
+	backend := proxytest.NewBackend([]proxytest.Tool{
+		{Name: "slow", Delay: 50 * time.Millisecond},
+		{Name: "fast"},
+	}, nil)
+	defer backend.Close()
+	untracked := proxytest.NewBackend([]proxytest.Tool{{Name: "other"}}, nil)
+	defer untracked.Close()
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{
+		{Name: "slo-server", Address: backend.URL, SLOMs: 20},
+		{Name: "slo-untracked", Address: untracked.URL},
+	}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+	violations := sloViolationsTotal.WithLabelValues("slo-server")
+	before := counterValue(t, violations)
+
+	for _, tool := range []string{"slow", "fast", "fast", "fast", "other"} {
+		require.Equal(t, 200, callToolFrom(httpProxy, tool, "192.0.2.1:1234").Code)
+	}
+
+	assert.Equal(t, float64(1), counterValue(t, violations)-before)
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	exported, ok := proxytest.MetricValue(w.Body.String(), "mcp_proxy_slo_violations_total", map[string]string{"server": "slo-server"})
+	assert.True(t, ok)
+	assert.Equal(t, counterValue(t, violations), exported)
+	_, ok = proxytest.MetricValue(w.Body.String(), "mcp_proxy_slo_violations_total", map[string]string{"server": "slo-untracked"})
+	assert.False(t, ok, "servers without slo_ms are not tracked")
+
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	var health struct {
+		SLOCompliance map[string]float64 `json:"sloCompliance"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &health))
+	assert.Equal(t, map[string]float64{"slo-server": 0.75}, health.SLOCompliance)
+}
+
+// TestSLOTrackerWindow tests that responses older than the window no longer count.
+func TestSLOTrackerWindow(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newSLOTracker()
+	tracker.now = func() time.Time { return now }
+	tracker.observe("server", false)
+	now = now.Add(sloWindow / 2)
+	tracker.observe("server", true)
+	assert.Equal(t, map[string]float64{"server": 0.5}, tracker.compliance())
+	now = now.Add(sloWindow / 2)
+	assert.Equal(t, map[string]float64{"server": 1}, tracker.compliance())
+	now = now.Add(sloWindow)
+	assert.Empty(t, tracker.compliance())
+}
+
+// counterValue returns the current value of a counter.
+func counterValue(t *testing.T, counter prometheus.Counter) float64 {
+	var m dto.Metric
+	require.NoError(t, counter.Write(&m))
+	return m.GetCounter().GetValue()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+
+	"smart-mcp-proxy/internal/config"
+)
+
+// staticResponse answers a request for a static resource of the server, or for any
+// resource of a server with only static resources, without a backend. It returns nil
+// for requests the backend serves.
+func (ps *ProxyServer) staticResponse(input ProxyRequestInput) *ProxyResponseOutput {
+	server := input.Server
+	static := server.Config.StaticResource(input.Resource)
+	if static == nil {
+		if server.Config.IsStatic() {
+			return errorResponse(http.StatusNotFound, fmt.Sprintf("resource '%s' not found on server '%s'", input.Resource, server.Config.Name))
+		}
+		return nil
+	}
+	// Static resources have no sub-paths, and are hidden like discovered ones when the
+	// server's filters restrict them
+	listed := slices.ContainsFunc(server.GetResources(), func(r config.ResourceInfo) bool { return r.Name == static.Name })
+	if !listed || input.Path != server.ResourcePath(static.Name) {
+		return errorResponse(http.StatusNotFound, fmt.Sprintf("resource '%s' not found on server '%s'", input.Path, server.Config.Name))
+	}
+	if input.Method != http.MethodGet && input.Method != http.MethodHead {
+		out := errorResponse(http.StatusMethodNotAllowed, fmt.Sprintf("static resource '%s' is read-only", static.Name))
+		out.Headers.Set("Allow", "GET, HEAD")
+		return out
+	}
+	body, err := static.Read()
+	if err != nil {
+		log.Printf("Failed to read static resource '%s' of server '%s': %v", static.Name, server.Config.Name, err)
+		return errorResponse(http.StatusInternalServerError, fmt.Sprintf("failed to read static resource '%s'", static.Name))
+	}
+	header := make(http.Header)
+	if static.MimeType != "" {
+		header.Set("Content-Type", static.MimeType)
+	}
+	if input.Method == http.MethodHead {
+		body = nil
+	}
+	return &ProxyResponseOutput{Status: http.StatusOK, Headers: header, Body: body}
+}
+
+// errorResponse returns a response with a JSON {"error": message} body.
+func errorResponse(status int, message string) *ProxyResponseOutput {
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	body, _ := json.Marshal(map[string]string{"error": message})
+	return &ProxyResponseOutput{Status: status, Headers: header, Body: body}
+}
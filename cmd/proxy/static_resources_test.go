@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStaticResources tests that static_resources are listed and served by the proxy,
+// for a server with only static resources and next to a backend's resources.
+func TestStaticResources(t *testing.T) {
+	backend := proxytest.NewBackend(nil, []proxytest.Resource{{Name: "remote", URI: "file:///remote"}})
+	defer backend.Close()
+	guide := filepath.Join(t.TempDir(), "guide.txt")
+	require.NoError(t, os.WriteFile(guide, []byte("first edition"), 0o644))
+
+	ps, err := NewProxyServer(&config.Config{
+		MCPServers: []config.MCPServerConfig{
+			{
+				Name: "docs",
+				StaticResources: []config.StaticResourceConfig{
+					{Name: "readme", URI: "docs://readme", MimeType: "text/markdown", Content: "# Hello"},
+					{Name: "guide", URI: "docs://guide", MimeType: "text/plain", File: guide},
+					{Name: "secret", URI: "docs://secret", Content: "hidden"},
+				},
+				AllowedResources: []string{"readme", "guide"},
+			},
+			{
+				Name:            "api",
+				Address:         backend.URL,
+				StaticResources: []config.StaticResourceConfig{{Name: "notes", URI: "api://notes", Content: "static notes"}},
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		httpProxy.engine.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	w := serve("GET", "/resources")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resources struct {
+		Resources []ListedResource `json:"resources"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resources))
+	var names []string
+	for _, resource := range resources.Resources {
+		names = append(names, resource.Name)
+	}
+	assert.ElementsMatch(t, []string{"readme", "guide", "remote", "notes"}, names)
+
+	w = serve("GET", "/resource/docs/readme")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "# Hello", w.Body.String())
+	assert.Equal(t, "text/markdown", w.Header().Get("Content-Type"))
+
+	w = serve("GET", "/resource/docs/guide")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "first edition", w.Body.String())
+	require.NoError(t, os.WriteFile(guide, []byte("second edition"), 0o644))
+	w = serve("GET", "/resource/docs/guide")
+	assert.Equal(t, "second edition", w.Body.String(), "files are read on every request")
+
+	w = serve("POST", "/resource/docs/readme")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, HEAD", w.Header().Get("Allow"))
+	assert.Equal(t, http.StatusForbidden, serve("GET", "/resource/docs/secret").Code, "filtered static resources are not served")
+	assert.Equal(t, http.StatusNotFound, serve("GET", "/resource/docs/readme/part").Code)
+
+	w = serve("GET", "/resource/api/notes")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "static notes", w.Body.String())
+	w = serve("GET", "/resource/api/remote")
+	assert.Equal(t, http.StatusOK, w.Code, "other resources are still served by the backend: %s", w.Body.String())
+
+	cmdProxy, err := NewCommandProxy(ps)
+	require.NoError(t, err)
+	respBytes, err := cmdProxy.handleCommandRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"resources/read","params":{"uri":"docs://readme"}}`))
+	require.NoError(t, err)
+	assert.Contains(t, string(respBytes), `"text":"# Hello"`)
+
+	var status StatusSnapshot
+	require.NoError(t, json.Unmarshal(serve("GET", "/status").Body.Bytes(), &status))
+	require.Len(t, status.Servers, 2)
+	assert.Equal(t, "static", status.Servers[0].Transport)
+	assert.Equal(t, "ok", status.Servers[0].Health)
+}
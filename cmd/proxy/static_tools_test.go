@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStaticTools tests that static_tools are listed, filtered by allowed_tools and
+// called by running their command with the arguments on stdin.
+func TestStaticTools(t *testing.T) {
+	ps, err := NewProxyServer(&config.Config{
+		MCPServers: []config.MCPServerConfig{{
+			Name: "utils",
+			StaticTools: []config.StaticToolConfig{
+				{Name: "echo", Description: "Returns its arguments", Command: "cat"},
+				{Name: "fail", Command: "sh", Args: []string{"-c", "echo boom >&2; exit 3"}},
+				{Name: "slow", Command: "sleep", Args: []string{"10"}, TimeoutSeconds: 1},
+				{Name: "hidden", Command: "cat"},
+			},
+			AllowedTools: []string{"echo", "fail", "slow"},
+		}},
+	})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", "/tools", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var tools struct {
+		Tools []ListedTool `json:"tools"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tools))
+	var names []string
+	for _, tool := range tools.Tools {
+		names = append(names, tool.Name)
+	}
+	assert.Equal(t, []string{"echo", "fail", "slow"}, names)
+
+	arguments := `{"message":"hello","count":2,"nested":{"ok":true}}`
+	req := httptest.NewRequest("POST", "/tool/echo", strings.NewReader(arguments))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var result config.CallToolResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	require.Len(t, result.Content, 1)
+	require.NotNil(t, result.Content[0].Text)
+	assert.JSONEq(t, arguments, *result.Content[0].Text, "arguments round-trip through stdin to stdout")
+	assert.False(t, result.IsError)
+
+	cmdProxy, err := NewCommandProxy(ps)
+	require.NoError(t, err)
+	respBytes, err := cmdProxy.handleCommandRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"echo","arguments":{"via":"stdio"}}}`))
+	require.NoError(t, err)
+	assert.Contains(t, string(respBytes), `"text":"{\"via\":\"stdio\"}"`)
+
+	failed, err := ps.CallTool("fail", nil)
+	require.NoError(t, err)
+	assert.True(t, failed.IsError)
+	require.NotNil(t, failed.Content[0].Text)
+	assert.Equal(t, "boom", *failed.Content[0].Text)
+
+	_, err = ps.CallTool("slow", nil)
+	assert.True(t, errors.Is(err, config.ErrStaticToolTimeout), "expected a timeout, got %v", err)
+
+	_, err = ps.CallTool("hidden", nil)
+	assert.Error(t, err, "tools outside allowed_tools are not served")
+}
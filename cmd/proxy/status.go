@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+)
+
+// Sizes of the in-memory status buffers.
+const (
+	recentCallsSize = 50
+	recentLogsSize  = 200
+)
+
+// recentLogs keeps the latest log lines for the /status endpoint. main tees the
+// standard logger into it.
+var recentLogs = newLogRing(recentLogsSize)
+
+// ToolCallRecord describes one completed tool call.
+type ToolCallRecord struct {
+	Time       time.Time `json:"time"`
+	Tool       string    `json:"tool"`
+	Client     string    `json:"client,omitempty"` // Client IP in HTTP mode, the client's label in command mode
+	DurationMs float64   `json:"durationMs"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// ServerStatus reports the health of one configured MCP server.
+type ServerStatus struct {
+	Name      string `json:"name"`
+	Transport string `json:"transport"` // "stdio", "http" or "static"
+	Health    string `json:"health"`    // "ok", "restarting", "unhealthy", "degraded" or "circuit-open"
+	Tools     int    `json:"tools"`
+	Resources int    `json:"resources"`
+	Restarts  int    `json:"restarts"`
+
+	// Stopped is set for a stdio server whose process is not running under
+	// max_stdio_processes or lazy; it starts on the next request.
+	Stopped bool `json:"stopped,omitempty"`
+	// ProcessState is where a stdio server's process is in its lifecycle: "stopped",
+	// "starting", "ready", "restarting" or "stopping".
+	ProcessState config.ProcessState `json:"processState,omitempty"`
+
+	DependsOn []string `json:"dependsOn,omitempty"` // Edges of the startup dependency graph
+
+	// PolicyStale is set when the last load of allowed_tools_from failed, so the tools
+	// are filtered by the previously loaded list; PolicyError says why.
+	PolicyStale bool   `json:"policyStale,omitempty"`
+	PolicyError string `json:"policyError,omitempty"`
+
+	Degraded  bool    `json:"degraded"`            // Over the error budget
+	ErrorRate float64 `json:"errorRate,omitempty"` // Error rate within the error budget window
+
+	// SchemaIssues lists tools whose inputSchema was missing, null or not an object,
+	// mapped to the problem found. Their schema is served as {"type":"object"}.
+	SchemaIssues map[string]string `json:"schemaIssues,omitempty"`
+
+	// MissingCapabilities lists the discovery calls ("tools", "resources") the server
+	// does not implement; they are skipped on refresh.
+	MissingCapabilities []string `json:"missingCapabilities,omitempty"`
+
+	// ChainedServers reports the servers behind a chained smart-mcp-proxy, as of its
+	// last discovery.
+	ChainedServers []config.ChainedServerStatus `json:"chainedServers,omitempty"`
+}
+
+// StatusSnapshot is the body of the /status endpoint.
+type StatusSnapshot struct {
+	Time              time.Time          `json:"time"`
+	Servers           []ServerStatus     `json:"servers"`
+	Client            *CommandClient     `json:"client,omitempty"` // The command mode client; omitted in HTTP mode
+	ResourceConflicts []ResourceConflict `json:"resourceConflicts,omitempty"`
+	RecentCalls       []ToolCallRecord   `json:"recentCalls"`
+	RecentLogs        []string           `json:"recentLogs,omitempty"` // Only for admin requests; see statusFor
+}
+
+// callRing is a fixed-size buffer of the most recent tool calls.
+type callRing struct {
+	mu    sync.Mutex
+	calls []ToolCallRecord
+	next  int
+	full  bool
+}
+
+func newCallRing(size int) *callRing {
+	return &callRing{calls: make([]ToolCallRecord, size)}
+}
+
+// record stores a call, overwriting the oldest once the buffer is full.
+func (r *callRing) record(rec ToolCallRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls[r.next] = rec
+	r.next = (r.next + 1) % len(r.calls)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// snapshot returns the recorded calls, newest first.
+func (r *callRing) snapshot() []ToolCallRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := r.next
+	if r.full {
+		n = len(r.calls)
+	}
+	out := make([]ToolCallRecord, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, r.calls[(r.next-i+len(r.calls))%len(r.calls)])
+	}
+	return out
+}
+
+// logRing is an io.Writer that keeps the last lines written to it.
+type logRing struct {
+	mu      sync.Mutex
+	lines   []string
+	size    int
+	partial []byte
+}
+
+func newLogRing(size int) *logRing {
+	return &logRing{size: size}
+}
+
+// Write splits p into lines, buffering an unterminated tail until the next write.
+func (l *logRing) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	data := append(l.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		l.lines = append(l.lines, strings.TrimRight(string(data[:i]), "\r"))
+		data = data[i+1:]
+	}
+	l.partial = append([]byte(nil), data...)
+	if len(l.lines) > l.size {
+		l.lines = append([]string(nil), l.lines[len(l.lines)-l.size:]...)
+	}
+	return len(p), nil
+}
+
+// snapshot returns the buffered lines, oldest first.
+func (l *logRing) snapshot() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string{}, l.lines...)
+}
+
+// Status reports backend health, recent tool calls and recent log lines.
+func (ps *ProxyServer) Status() StatusSnapshot {
+	servers := make([]ServerStatus, 0, len(ps.mcpServers))
+	for _, server := range ps.mcpServers {
+		status := ServerStatus{
+			Name:      server.Config.Name,
+			Transport: "http",
+			Health:    "ok",
+			Tools:     len(server.GetTools()),
+			Resources: len(server.GetResources()),
+			Restarts:  server.Restarts(),
+			DependsOn: server.Config.DependsOn,
+
+			SchemaIssues: server.SchemaIssues(),
+
+			MissingCapabilities: server.MissingCapabilities(),
+			ChainedServers:      server.ChainedServers(),
+		}
+		if server.Config.Command != "" {
+			status.Transport = "stdio"
+			status.Stopped = !server.ProcessRunning()
+			status.ProcessState = server.ProcessState()
+		} else if server.Config.IsStatic() {
+			status.Transport = "static"
+		}
+		if err := server.ToolPolicyError(); err != nil {
+			status.PolicyStale = true
+			status.PolicyError = err.Error()
+		}
+		if ps.errorBudget != nil {
+			status.Degraded, status.ErrorRate = ps.errorBudget.state(server.Config.Name)
+		}
+		if breaker, ok := ps.breakers[server.Config.Name]; ok && breaker.isOpen() {
+			status.Health = "circuit-open"
+		} else if status.Degraded {
+			status.Health = "degraded"
+		} else if server.IsRestarting() {
+			status.Health = "restarting"
+		} else if !server.Healthy() {
+			status.Health = "unhealthy"
+		}
+		servers = append(servers, status)
+	}
+	snapshot := StatusSnapshot{
+		Time:              time.Now(),
+		Servers:           servers,
+		ResourceConflicts: ps.ResourceConflicts(),
+		RecentCalls:       ps.recentCalls.snapshot(),
+		RecentLogs:        recentLogs.snapshot(),
+	}
+	if ps.commandClient != nil {
+		client := ps.commandClient.get()
+		snapshot.Client = &client
+	}
+	return snapshot
+}
+
+// statusFor returns the /status body for r. recentLogs can quote backend stderr, admin
+// audit lines and raw backend responses, so it is left out unless r carries admin
+// credentials.
+func (ps *ProxyServer) statusFor(r *http.Request) StatusSnapshot {
+	status := ps.Status()
+	if ps.admin == nil {
+		status.RecentLogs = nil
+	} else if _, ok := ps.admin.authenticate(r); !ok {
+		status.RecentLogs = nil
+	}
+	return status
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCallRing tests that the call buffer keeps the newest calls, newest first.
+func TestCallRing(t *testing.T) {
+	ring := newCallRing(3)
+	assert.Empty(t, ring.snapshot())
+	for i := 0; i < 5; i++ {
+		ring.record(ToolCallRecord{Tool: fmt.Sprintf("tool%d", i)})
+	}
+	calls := ring.snapshot()
+	require.Len(t, calls, 3)
+	assert.Equal(t, []string{"tool4", "tool3", "tool2"}, []string{calls[0].Tool, calls[1].Tool, calls[2].Tool})
+}
+
+// TestLogRing tests line splitting across writes and the line cap.
+func TestLogRing(t *testing.T) {
+	ring := newLogRing(2)
+	ring.Write([]byte("one\ntw"))
+	ring.Write([]byte("o\nthree\n"))
+	assert.Equal(t, []string{"two", "three"}, ring.snapshot())
+}
+
+// TestHTTPStatus tests the GET /status endpoint.
+func TestHTTPStatus(t *testing.T) {
+	httpProxy, _, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+
+	req := httptest.NewRequest("POST", "/tool/tool1", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest("GET", "/status", nil)
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var status StatusSnapshot
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	require.Len(t, status.Servers, 2)
+	assert.Equal(t, "server1", status.Servers[0].Name)
+	assert.Equal(t, "http", status.Servers[0].Transport)
+	assert.Equal(t, "ok", status.Servers[0].Health)
+	require.NotEmpty(t, status.RecentCalls)
+	assert.Equal(t, "tool1", status.RecentCalls[0].Tool)
+	assert.Empty(t, status.RecentCalls[0].Error)
+	assert.WithinDuration(t, time.Now(), status.RecentCalls[0].Time, time.Minute)
+}
+
+// TestStatusSchemaIssues tests that /status flags tools served without a usable input schema.
+func TestStatusSchemaIssues(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tools":
+			w.Write([]byte(`{"tools":[{"name":"ok","inputSchema":{"type":"object"}},{"name":"broken","inputSchema":null}]}`))
+		default:
+			w.Write([]byte(`{"resources":[]}`))
+		}
+	}))
+	defer backend.Close()
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{{Name: "schemas", Address: backend.URL}}})
+	require.NoError(t, err)
+
+	status := ps.Status()
+	require.Len(t, status.Servers, 1)
+	assert.Equal(t, map[string]string{"broken": config.SchemaNull}, status.Servers[0].SchemaIssues)
+	assert.Equal(t, 2, status.Servers[0].Tools)
+
+	tools := ps.ListTools()
+	require.Len(t, tools, 2)
+	for _, tool := range tools {
+		assert.Equal(t, "object", tool.InputSchema["type"])
+	}
+}
+
+// TestDependencyGraphAndShutdownOrder tests that /status reports depends_on and that
+// dependents are shut down before their dependencies.
+func TestDependencyGraphAndShutdownOrder(t *testing.T) {
+	base, baseConf := testHttpServer("base", []string{"tool1"}, nil, nil, nil)
+	defer base.Close()
+	dependent, dependentConf := testHttpServer("dependent", []string{"tool2"}, nil, nil, nil)
+	defer dependent.Close()
+	dependentConf.DependsOn = []string{"base"}
+
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{baseConf, dependentConf}})
+	require.NoError(t, err)
+
+	status := ps.Status()
+	require.Len(t, status.Servers, 2)
+	assert.Empty(t, status.Servers[0].DependsOn)
+	assert.Equal(t, []string{"base"}, status.Servers[1].DependsOn)
+
+	order := ps.shutdownOrder()
+	require.Len(t, order, 2)
+	assert.Equal(t, "dependent", order[0].Config.Name)
+	assert.Equal(t, "base", order[1].Config.Name)
+}
+
+// TestStatusRecentLogsRequireAdmin tests that /status only includes recentLogs for
+// requests with admin credentials.
+func TestStatusRecentLogsRequireAdmin(t *testing.T) {
+	backend := proxytest.NewBackend([]proxytest.Tool{{Name: "tool1"}}, nil)
+	defer backend.Close()
+	ps, err := NewProxyServer(&config.Config{
+		AdminToken: "secret",
+		MCPServers: []config.MCPServerConfig{{Name: "server1", Address: backend.URL}},
+	})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+	recentLogs.Write([]byte("backend stderr: token=hunter2\n"))
+
+	status := func(token string) map[string]interface{} {
+		req := httptest.NewRequest("GET", "/status", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		httpProxy.engine.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body
+	}
+	assert.NotContains(t, status(""), "recentLogs")
+	assert.NotContains(t, status("wrong"), "recentLogs")
+	assert.Contains(t, status("secret")["recentLogs"], "backend stderr: token=hunter2")
+
+	w := httptest.NewRecorder()
+	newAdminServer(ps).Handler.ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+	assert.NotContains(t, w.Body.String(), "recentLogs", "the command mode admin listener applies the same rule")
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Throttle reasons reported to clients in the reason field.
+const (
+	throttleRateLimit  = "rate_limit" // The client sent too many requests
+	throttleRestarting = "restarting" // The backend process is being restarted
+	throttleOverloaded = "overloaded" // The proxy or backend cannot take more work right now
+)
+
+// restartRetryAfter is the backoff hint for calls to a stdio server that is restarting.
+const restartRetryAfter = 2 * time.Second
+
+// minRetryAfter is the smallest backoff hint sent, as Retry-After counts whole seconds.
+const minRetryAfter = time.Second
+
+// ErrBackendRestarting is returned for tool calls to a stdio server that is restarting.
+var ErrBackendRestarting = errors.New("backend server is restarting")
+
+// ThrottleError marks a request that was turned away for now rather than failed; the
+// client may retry after RetryAfter. Err is the underlying error, e.g. ErrCircuitOpen.
+type ThrottleError struct {
+	Reason     string
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *ThrottleError) Error() string { return e.Err.Error() }
+
+func (e *ThrottleError) Unwrap() error { return e.Err }
+
+// throttled wraps err as a throttle error with the given reason and backoff hint.
+func throttled(reason string, retryAfter time.Duration, err error) error {
+	return &ThrottleError{Reason: reason, RetryAfter: retryAfter, Err: err}
+}
+
+// asThrottle returns the ThrottleError in err's chain, or nil.
+func asThrottle(err error) *ThrottleError {
+	var t *ThrottleError
+	if errors.As(err, &t) {
+		return t
+	}
+	return nil
+}
+
+// status is the HTTP status for the throttle: 429 when the client should slow down,
+// 503 when the proxy or backend is unavailable.
+func (e *ThrottleError) status() int {
+	if e.Reason == throttleRateLimit {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusServiceUnavailable
+}
+
+// retryAfter is the backoff hint sent to the client, at least minRetryAfter.
+func (e *ThrottleError) retryAfter() time.Duration {
+	return max(e.RetryAfter, minRetryAfter)
+}
+
+// ThrottleInfo is the body of throttled HTTP responses and the error.data of throttled
+// JSON-RPC errors.
+type ThrottleInfo struct {
+	Error        string `json:"error"`
+	Reason       string `json:"reason"`
+	RetryAfterMs int64  `json:"retryAfterMs"`
+}
+
+// info describes the throttle to the client with message as its error.
+func (e *ThrottleError) info(message string) ThrottleInfo {
+	return ThrottleInfo{Error: message, Reason: e.Reason, RetryAfterMs: e.retryAfter().Milliseconds()}
+}
+
+// respondThrottled writes a throttled HTTP response: Retry-After in whole seconds,
+// rounded up, and ThrottleInfo with message as its error.
+func respondThrottled(c *gin.Context, message string, t *ThrottleError) {
+	t.setRetryAfter(c)
+	c.JSON(t.status(), t.info(message))
+}
+
+// setRetryAfter sets the Retry-After header of the response, in whole seconds rounded up.
+func (e *ThrottleError) setRetryAfter(c *gin.Context) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(e.retryAfter().Seconds()))))
+}
+
+// throttleRPCError returns the JSON-RPC error for a request throttled by t, with
+// ThrottleInfo carrying err as its data.
+func throttleRPCError(code int, message string, err error, t *ThrottleError) *rpcError {
+	return &rpcError{Code: code, Message: message, Data: t.info(err.Error())}
+}
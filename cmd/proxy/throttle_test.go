@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeThrottleInfo decodes the ThrottleInfo carried by a JSON-RPC error's data.
+func decodeThrottleInfo(t *testing.T, respBytes []byte) ThrottleInfo {
+	t.Helper()
+	var resp struct {
+		Error struct {
+			Data ThrottleInfo `json:"data"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(respBytes, &resp), string(respBytes))
+	return resp.Error.Data
+}
+
+// TestThrottleCircuitOpen tests that calls short-circuited by an open breaker carry the
+// time left until the breaker lets a call through.
+func TestThrottleCircuitOpen(t *testing.T) {
+	backend, _, _ := testFlakyServer(100)
+	defer backend.Close()
+	ps := newResilientProxy(t, backend.URL, nil, &config.CircuitBreakerConfig{FailureThreshold: 1, ResetTimeout: "1m"})
+	defer ps.Shutdown()
+	_, err := ps.CallTool("flaky", map[string]interface{}{})
+	require.ErrorIs(t, err, ErrBackendCommunication)
+
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, httptest.NewRequest("POST", "/tool/flaky", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	var info ThrottleInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+	assert.Equal(t, throttleOverloaded, info.Reason)
+	assert.InDelta(t, 60000, info.RetryAfterMs, 1000)
+	assert.Contains(t, info.Error, "temporarily unavailable")
+
+	cmdProxy, err := NewCommandProxy(ps)
+	require.NoError(t, err)
+	respBytes, err := cmdProxy.handleCommandRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"flaky"}}`))
+	require.NoError(t, err)
+	info = decodeThrottleInfo(t, respBytes)
+	assert.Equal(t, throttleOverloaded, info.Reason)
+	assert.InDelta(t, 60000, info.RetryAfterMs, 1000)
+}
+
+// TestThrottleBufferLimit tests that resources/access rejected by the buffer ceiling
+// reports the overload in error.data.
+func TestThrottleBufferLimit(t *testing.T) {
+	cmdProxy, servers := setupTestCommandProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+	cmdProxy.ps.bodyBudget = newBodyBudget(4)
+
+	respBytes, err := cmdProxy.handleCommandRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"resources/access","params":{"serverName":"server1","resourceName":"res1","method":"POST","body":{"action":"start"}}}`))
+	require.NoError(t, err)
+	info := decodeThrottleInfo(t, respBytes)
+	assert.Equal(t, throttleOverloaded, info.Reason)
+	assert.Equal(t, bufferRetryAfter.Milliseconds(), info.RetryAfterMs)
+}
+
+// TestThrottleRestarting tests that calls to a stdio server waiting to be restarted are
+// turned away with the restarting reason instead of failing on the dead pipe.
+func TestThrottleRestarting(t *testing.T) {
+	crasher := proxytest.StdioBackend{Tools: []proxytest.Tool{{Name: "crash"}}, Calls: map[string]string{"crash": "exit 1"}}
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{{Name: "crasher", Command: crasher.Command(), Args: crasher.Args()}}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, httptest.NewRequest("POST", "/tool/crash", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	server := ps.findMCPServerByName("crasher")
+	require.Eventually(t, server.IsRestarting, 2*time.Second, 10*time.Millisecond)
+
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, httptest.NewRequest("POST", "/tool/crash", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"error":"Backend server for tool 'crash' is restarting","reason":"restarting","retryAfterMs":2000}`, w.Body.String())
+}
+
+// TestThrottleStatus tests the HTTP status chosen for each throttle reason.
+func TestThrottleStatus(t *testing.T) {
+	for reason, status := range map[string]int{
+		throttleRateLimit:  http.StatusTooManyRequests,
+		throttleRestarting: http.StatusServiceUnavailable,
+		throttleOverloaded: http.StatusServiceUnavailable,
+	} {
+		assert.Equal(t, status, (&ThrottleError{Reason: reason}).status(), reason)
+	}
+	assert.Equal(t, minRetryAfter, (&ThrottleError{RetryAfter: time.Millisecond}).retryAfter())
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindToolArguments builds the arguments map for a tool call from the request body.
+// JSON is the primary format. Form-encoded and multipart bodies are converted field by
+// field: a field sent once becomes a string and a repeated field a list of strings.
+// Uploaded files become content blocks of type "file" carrying the base64-encoded data.
+// bodySize is the size of the buffered body, used to keep multipart parsing in memory.
+func (h *HTTPProxy) bindToolArguments(c *gin.Context, bodySize int64) (map[string]interface{}, error) {
+	switch c.ContentType() {
+	case gin.MIMEPOSTForm:
+		if err := c.Request.ParseForm(); err != nil {
+			return nil, err
+		}
+		return formArguments(c.Request.PostForm), nil
+	case gin.MIMEMultipartPOSTForm:
+		// The body is already buffered, so parsing never needs to spill files to disk
+		if err := c.Request.ParseMultipartForm(bodySize + 1); err != nil {
+			return nil, err
+		}
+		defer c.Request.MultipartForm.RemoveAll()
+		arguments := formArguments(c.Request.MultipartForm.Value)
+		for field, headers := range c.Request.MultipartForm.File {
+			files := make([]interface{}, 0, len(headers))
+			for _, header := range headers {
+				block, err := fileContentBlock(header)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read file field '%s': %w", field, err)
+				}
+				files = append(files, block)
+			}
+			if len(files) == 1 {
+				arguments[field] = files[0]
+			} else {
+				arguments[field] = files
+			}
+		}
+		return arguments, nil
+	}
+
+	var arguments map[string]interface{}
+	if err := h.ps.decodeJSON(c.Request.Body, &arguments); err != nil {
+		// Treat an empty body as empty arguments
+		if errors.Is(err, io.EOF) {
+			return make(map[string]interface{}), nil
+		}
+		return nil, err
+	}
+	return arguments, nil
+}
+
+// formArguments converts form values into tool arguments.
+func formArguments(values url.Values) map[string]interface{} {
+	arguments := make(map[string]interface{}, len(values))
+	for field, vals := range values {
+		if len(vals) == 1 {
+			arguments[field] = vals[0]
+			continue
+		}
+		list := make([]interface{}, len(vals))
+		for i, v := range vals {
+			list[i] = v
+		}
+		arguments[field] = list
+	}
+	return arguments
+}
+
+// fileContentBlock reads an uploaded file into a base64 "file" content block.
+func fileContentBlock(header *multipart.FileHeader) (map[string]interface{}, error) {
+	f, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	mimeType := header.Header.Get("Content-Type")
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return map[string]interface{}{
+		"type":     "file",
+		"filename": header.Filename,
+		"mimeType": mimeType,
+		"data":     base64.StdEncoding.EncodeToString(data),
+	}, nil
+}
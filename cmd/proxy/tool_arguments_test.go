@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testEchoServer starts a backend whose "echo" tool returns the arguments it received as text.
+func testEchoServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tools", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tools":[{"name":"echo","inputSchema":{"type":"object"}}]}`))
+	})
+	mux.HandleFunc("/resources", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"resources":[]}`))
+	})
+	mux.HandleFunc("/tool/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		text := string(body)
+		json.NewEncoder(w).Encode(config.CallToolResult{Content: []config.ContentBlock{{Type: "text", Text: &text}}})
+	})
+	return httptest.NewServer(mux)
+}
+
+// callEcho sends body to the echo tool through the HTTP proxy and returns the arguments the backend saw.
+func callEcho(t *testing.T, contentType string, body io.Reader) map[string]interface{} {
+	backend := testEchoServer()
+	t.Cleanup(backend.Close)
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{{Name: "echo-server", Address: backend.URL}}})
+	require.NoError(t, err)
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/tool/echo", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var result config.CallToolResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	var arguments map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(*result.Content[0].Text), &arguments))
+	return arguments
+}
+
+// TestHTTPToolCallFormEncoded tests that form fields become string arguments, and repeated fields lists.
+func TestHTTPToolCallFormEncoded(t *testing.T) {
+	arguments := callEcho(t, "application/x-www-form-urlencoded", strings.NewReader("query=hello+world&tag=a&tag=b"))
+	assert.Equal(t, "hello world", arguments["query"])
+	assert.Equal(t, []interface{}{"a", "b"}, arguments["tag"])
+}
+
+// TestHTTPToolCallMultipart tests that multipart fields and files are converted, files as base64 blocks.
+func TestHTTPToolCallMultipart(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	require.NoError(t, mw.WriteField("title", "report"))
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="upload"; filename="report.csv"`)
+	header.Set("Content-Type", "text/csv")
+	part, err := mw.CreatePart(header)
+	require.NoError(t, err)
+	part.Write([]byte("a,b\n1,2\n"))
+	require.NoError(t, mw.Close())
+
+	arguments := callEcho(t, mw.FormDataContentType(), &body)
+	assert.Equal(t, "report", arguments["title"])
+	upload, ok := arguments["upload"].(map[string]interface{})
+	require.True(t, ok, "upload should be a file content block, got %v", arguments["upload"])
+	assert.Equal(t, "file", upload["type"])
+	assert.Equal(t, "report.csv", upload["filename"])
+	assert.Equal(t, "text/csv", upload["mimeType"])
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("a,b\n1,2\n")), upload["data"])
+}
+
+// TestHTTPToolCallJSONStillDefault tests that JSON remains the default when no form type is sent.
+func TestHTTPToolCallJSONStillDefault(t *testing.T) {
+	arguments := callEcho(t, "application/json", strings.NewReader(`{"n":1}`))
+	assert.Equal(t, 1.0, arguments["n"])
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tool job states.
+const (
+	toolJobRunning   = "running"
+	toolJobCompleted = "completed"
+	toolJobFailed    = "failed"
+)
+
+// toolJobPollSeconds is the Retry-After hint sent while a job is running.
+const toolJobPollSeconds = "1"
+
+// pendingMetaKey is the _meta key of a tool result marking the call as started but not
+// finished. Its value is a pendingMarker.
+const pendingMetaKey = "smart-mcp-proxy/pending"
+
+// pendingMarker tells the proxy how to learn the outcome of a pending tool call: by
+// calling PollTool with Arguments until it returns a result that is not pending itself.
+type pendingMarker struct {
+	PollTool  string                 `json:"pollTool"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+}
+
+// pendingPollInterval is the wait between calls of a pending result's poll tool.
+var pendingPollInterval = time.Second
+
+// ToolJob is an asynchronous tool call tracked by the proxy.
+type ToolJob struct {
+	ID          string                 `json:"id"`
+	Tool        string                 `json:"tool"`
+	Status      string                 `json:"status"`
+	Result      *config.CallToolResult `json:"result,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	ErrorStatus int                    `json:"errorStatus,omitempty"` // HTTP status the synchronous call would have returned
+	CreatedAt   time.Time              `json:"createdAt"`
+	CompletedAt *time.Time             `json:"completedAt,omitempty"`
+
+	polledAt time.Time          // Creation or last poll, while running
+	cancel   context.CancelFunc // Ends the backend call of a running job
+}
+
+// toolJobStore holds asynchronous tool calls. Finished jobs are dropped ttl after they
+// complete, and running jobs ttl after they were last polled, which cancels their
+// backend call. Expired jobs are swept on access and periodically.
+type toolJobStore struct {
+	mu   sync.Mutex
+	jobs map[string]*ToolJob
+	ttl  time.Duration
+	now  func() time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newToolJobStore(ttl time.Duration) *toolJobStore {
+	return &toolJobStore{jobs: make(map[string]*ToolJob), ttl: ttl, now: time.Now, stop: make(chan struct{})}
+}
+
+// runSweeps sweeps expired jobs every interval until close is called.
+func (s *toolJobStore) runSweeps(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			s.sweep()
+			s.mu.Unlock()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// close stops the periodic sweeps and cancels the backend calls of running jobs.
+func (s *toolJobStore) close() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		if job.cancel != nil {
+			job.cancel()
+		}
+	}
+}
+
+// sweep removes expired jobs. Callers must hold s.mu.
+func (s *toolJobStore) sweep() {
+	now := s.now()
+	for id, job := range s.jobs {
+		switch {
+		case job.CompletedAt != nil:
+			if now.Sub(*job.CompletedAt) > s.ttl {
+				delete(s.jobs, id)
+			}
+		case now.Sub(job.polledAt) > s.ttl:
+			log.Printf("Tool job %s of tool '%s' was not polled for %s; cancelling it", id, job.Tool, s.ttl)
+			if job.cancel != nil {
+				job.cancel()
+			}
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// create registers a running job for toolName whose backend call ends with cancel.
+func (s *toolJobStore) create(toolName string, cancel context.CancelFunc) ToolJob {
+	idBytes := make([]byte, 16)
+	rand.Read(idBytes)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep()
+	now := s.now()
+	job := &ToolJob{ID: hex.EncodeToString(idBytes), Tool: toolName, Status: toolJobRunning, CreatedAt: now, polledAt: now, cancel: cancel}
+	s.jobs[job.ID] = job
+	return *job
+}
+
+// complete records the outcome of a job.
+func (s *toolJobStore) complete(id string, result *config.CallToolResult, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return
+	}
+	now := s.now()
+	job.CompletedAt = &now
+	job.cancel = nil
+	if err != nil {
+		job.Status = toolJobFailed
+		job.ErrorStatus, job.Error = toolCallErrorStatus(job.Tool, err)
+		return
+	}
+	job.Status = toolJobCompleted
+	job.Result = result
+}
+
+// get returns a copy of the job, or false if it does not exist or has expired. Getting
+// a running job counts as polling it.
+func (s *toolJobStore) get(id string) (ToolJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep()
+	job, ok := s.jobs[id]
+	if !ok {
+		return ToolJob{}, false
+	}
+	job.polledAt = s.now()
+	return *job, true
+}
+
+// pendingMarkerOf returns the pending marker of a tool result, or false for a result
+// that is final.
+func pendingMarkerOf(result *config.CallToolResult) (pendingMarker, bool) {
+	var marker pendingMarker
+	if result == nil || result.Meta[pendingMetaKey] == nil {
+		return marker, false
+	}
+	data, err := json.Marshal(result.Meta[pendingMetaKey])
+	if err != nil || json.Unmarshal(data, &marker) != nil || marker.PollTool == "" {
+		log.Printf("Ignoring invalid %s marker in tool result: %v", pendingMetaKey, result.Meta[pendingMetaKey])
+		return marker, false
+	}
+	return marker, true
+}
+
+// awaitPending polls the poll tool of a pending result until it returns a final result.
+func (ps *ProxyServer) awaitPending(ctx context.Context, client string, result *config.CallToolResult) (*config.CallToolResult, error) {
+	for {
+		marker, ok := pendingMarkerOf(result)
+		if !ok {
+			return result, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pendingPollInterval):
+		}
+		var err error
+		result, err = ps.callToolRecorded(ctx, client, marker.PollTool, marker.Arguments, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// startToolJob registers a job for toolName and runs it in the background until run
+// returns or the job expires.
+func (ps *ProxyServer) startToolJob(hops int, toolName string, run func(ctx context.Context) (*config.CallToolResult, error)) ToolJob {
+	ctx, cancel := context.WithCancel(config.WithHopCount(context.Background(), hops))
+	job := ps.toolJobs.create(toolName, cancel)
+	go func() {
+		defer cancel()
+		result, err := run(ctx)
+		ps.toolJobs.complete(job.ID, result, err)
+	}()
+	return job
+}
+
+// isAsyncTool reports whether calls to toolName are always run as jobs.
+func (ps *ProxyServer) isAsyncTool(toolName string) bool {
+	return ps.asyncTools[toolName]
+}
+
+// StartToolJob runs a tool call from client in the background and returns the job
+// tracking it. The tool must exist and be within its rate limit; otherwise
+// ErrToolNotFound, ErrToolRestricted or ErrRateLimited is returned synchronously.
+func (ps *ProxyServer) StartToolJob(client string, hops int, toolName string, arguments, meta map[string]interface{}) (ToolJob, error) {
+	if ps.findMCPServerByTool(toolName) == nil {
+		return ToolJob{}, ps.toolNotServed(toolName)
+	}
+	if err := ps.checkArgumentLimits(arguments); err != nil {
+		return ToolJob{}, err
+	}
+	if err := ps.checkToolRateLimit(toolName, client); err != nil {
+		return ToolJob{}, err
+	}
+	job := ps.startToolJob(hops, toolName, func(ctx context.Context) (*config.CallToolResult, error) {
+		result, err := ps.callToolRecorded(ctx, client, toolName, arguments, meta)
+		if err != nil {
+			return nil, err
+		}
+		return ps.awaitPending(ctx, client, result)
+	})
+	return job, nil
+}
+
+// TrackPendingToolJob returns a job completing with the final result of a tool call
+// from client whose result was pending, found by polling its poll tool.
+func (ps *ProxyServer) TrackPendingToolJob(client string, hops int, toolName string, result *config.CallToolResult) ToolJob {
+	return ps.startToolJob(hops, toolName, func(ctx context.Context) (*config.CallToolResult, error) {
+		return ps.awaitPending(ctx, client, result)
+	})
+}
+
+// wantsAsync reports whether the client asked for an asynchronous response (RFC 7240).
+func wantsAsync(c *gin.Context) bool {
+	for _, pref := range strings.Split(c.GetHeader("Prefer"), ",") {
+		if strings.EqualFold(strings.TrimSpace(pref), "respond-async") {
+			return true
+		}
+	}
+	return false
+}
+
+// respondToolJobAccepted starts a job and returns 202 with its Location.
+func (h *HTTPProxy) respondToolJobAccepted(c *gin.Context, toolName string, arguments, meta map[string]interface{}) {
+	job, err := h.ps.StartToolJob(h.clientIdentity(c), requestHops(c), toolName, arguments, meta)
+	if err != nil {
+		respondToolCallError(c, toolName, err)
+		return
+	}
+	respondToolJob(c, job)
+}
+
+// respondToolJob answers 202 with the Location of a running job.
+func respondToolJob(c *gin.Context, job ToolJob) {
+	c.Header("Location", "/tool-jobs/"+job.ID)
+	c.Header("Retry-After", toolJobPollSeconds)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// respondPendingToolJob answers a tool call whose result is pending, per its
+// pendingMetaKey marker, with 202 and a job tracking its final result.
+func (h *HTTPProxy) respondPendingToolJob(c *gin.Context, toolName string, result *config.CallToolResult) {
+	respondToolJob(c, h.ps.TrackPendingToolJob(h.clientIdentity(c), requestHops(c), toolName, result))
+}
+
+// handleToolJob handles GET /tool-jobs/:id. Running jobs return 202 with Retry-After,
+// finished jobs return 200 with the result or error, and unknown or expired jobs 404.
+func (h *HTTPProxy) handleToolJob(c *gin.Context) {
+	job, ok := h.ps.toolJobs.get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tool job not found or expired"})
+		return
+	}
+	if job.Status == toolJobRunning {
+		c.Header("Retry-After", toolJobPollSeconds)
+		c.JSON(http.StatusAccepted, job)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testBlockingServer starts a backend whose "slow" tool answers only once release is closed.
+func testBlockingServer(release <-chan struct{}) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tools", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tools":[{"name":"slow","inputSchema":{"type":"object"}}]}`))
+	})
+	mux.HandleFunc("/resources", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"resources":[]}`))
+	})
+	mux.HandleFunc("/tool/slow", func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		<-release
+		text := "done"
+		json.NewEncoder(w).Encode(config.CallToolResult{Content: []config.ContentBlock{{Type: "text", Text: &text}}})
+	})
+	return httptest.NewServer(mux)
+}
+
+// TestHTTPAsyncToolJob tests the 202 path for an async tool and polling until it completes.
+func TestHTTPAsyncToolJob(t *testing.T) {
+	release := make(chan struct{})
+	backend := testBlockingServer(release)
+	defer backend.Close()
+
+	ps, err := NewProxyServer(&config.Config{
+		MCPServers: []config.MCPServerConfig{{Name: "slow-server", Address: backend.URL}},
+		AsyncTools: []string{"slow"},
+	})
+	require.NoError(t, err)
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/tool/slow", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	var job ToolJob
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, toolJobRunning, job.Status)
+	location := w.Header().Get("Location")
+	assert.Equal(t, "/tool-jobs/"+job.ID, location)
+
+	// Still running while the backend blocks
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", location, nil))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, toolJobPollSeconds, w.Header().Get("Retry-After"))
+
+	close(release)
+	require.Eventually(t, func() bool {
+		w = httptest.NewRecorder()
+		httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", location, nil))
+		return w.Code == http.StatusOK
+	}, 2*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, toolJobCompleted, job.Status)
+	require.NotNil(t, job.Result)
+	assert.Equal(t, "done", *job.Result.Content[0].Text)
+	assert.NotNil(t, job.CompletedAt)
+}
+
+// TestHTTPPreferRespondAsync tests that clients can request a job for any tool, and that
+// unknown tools are still rejected synchronously.
+func TestHTTPPreferRespondAsync(t *testing.T) {
+	httpProxy, _, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+
+	req := httptest.NewRequest("POST", "/tool/tool1", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Prefer", "respond-async, wait=0")
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	location := w.Header().Get("Location")
+
+	require.Eventually(t, func() bool {
+		w = httptest.NewRecorder()
+		httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", location, nil))
+		return w.Code == http.StatusOK
+	}, 2*time.Second, 10*time.Millisecond)
+
+	req = httptest.NewRequest("POST", "/tool/nonexistentTool", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Prefer", "respond-async")
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// TestToolJobExpiry tests that finished jobs are dropped after the TTL, and running jobs
+// once they were not polled for the TTL, which cancels their backend call.
+func TestToolJobExpiry(t *testing.T) {
+	now := time.Now()
+	store := newToolJobStore(time.Minute)
+	store.now = func() time.Time { return now }
+
+	finished := store.create("tool1", func() {})
+	polled := store.create("tool2", func() {})
+	abandonedCtx, cancel := context.WithCancel(context.Background())
+	abandoned := store.create("tool3", cancel)
+	store.complete(finished.ID, &config.CallToolResult{}, nil)
+
+	now = now.Add(30 * time.Second)
+	_, ok := store.get(finished.ID)
+	assert.True(t, ok, "job should be kept within the TTL")
+	_, ok = store.get(polled.ID)
+	assert.True(t, ok)
+
+	now = now.Add(45 * time.Second)
+	_, ok = store.get(finished.ID)
+	assert.False(t, ok, "job should expire after the TTL")
+	job, ok := store.get(polled.ID)
+	assert.True(t, ok, "running jobs are kept while polled")
+	assert.Equal(t, toolJobRunning, job.Status)
+	_, ok = store.get(abandoned.ID)
+	assert.False(t, ok, "running jobs expire once not polled for the TTL")
+	assert.Error(t, abandonedCtx.Err(), "the backend call of an expired job is cancelled")
+}
+
+// TestToolJobSweep tests that expired jobs are swept in the background.
+func TestToolJobSweep(t *testing.T) {
+	store := newToolJobStore(10 * time.Millisecond)
+	defer store.close()
+	ctx, cancel := context.WithCancel(context.Background())
+	store.create("tool1", cancel)
+	go store.runSweeps(5 * time.Millisecond)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("the abandoned job was not swept")
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	assert.Empty(t, store.jobs)
+}
+
+// TestHTTPPendingToolResult tests that a tool result carrying the pending marker is
+// answered with 202 and a job that polls the backend's poll tool until it finishes.
+func TestHTTPPendingToolResult(t *testing.T) {
+	defer func(interval time.Duration) { pendingPollInterval = interval }(pendingPollInterval)
+	pendingPollInterval = 10 * time.Millisecond
+
+	var mu sync.Mutex
+	var polls []map[string]interface{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tools", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tools":[{"name":"export","inputSchema":{"type":"object"}},{"name":"export_status","inputSchema":{"type":"object"}}]}`))
+	})
+	mux.HandleFunc("/resources", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"resources":[]}`))
+	})
+	mux.HandleFunc("/tool/export", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"content":[],"_meta":{"smart-mcp-proxy/pending":{"pollTool":"export_status","arguments":{"job":"42"}}}}`))
+	})
+	mux.HandleFunc("/tool/export_status", func(w http.ResponseWriter, r *http.Request) {
+		var arguments map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&arguments)
+		mu.Lock()
+		polls = append(polls, arguments)
+		n := len(polls)
+		mu.Unlock()
+		if n < 3 {
+			w.Write([]byte(`{"content":[],"_meta":{"smart-mcp-proxy/pending":{"pollTool":"export_status","arguments":{"job":"42"}}}}`))
+			return
+		}
+		w.Write([]byte(`{"content":[{"type":"text","text":"exported"}]}`))
+	})
+	backend := httptest.NewServer(mux)
+	defer backend.Close()
+
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{{Name: "exporter", Address: backend.URL}}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/tool/export", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	location := w.Header().Get("Location")
+	require.NotEmpty(t, location)
+
+	require.Eventually(t, func() bool {
+		w = httptest.NewRecorder()
+		httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", location, nil))
+		return w.Code == http.StatusOK
+	}, 2*time.Second, 10*time.Millisecond)
+	var job ToolJob
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, toolJobCompleted, job.Status)
+	assert.Equal(t, "exported", *job.Result.Content[0].Text)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, polls, 3)
+	assert.Equal(t, map[string]interface{}{"job": "42"}, polls[0])
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+)
+
+// ErrRateLimited is returned for tool calls over the tool's tool_rate_limits entry.
+var ErrRateLimited = errors.New("tool rate limit exceeded")
+
+// maxIdleRateBuckets is the number of per-client buckets kept before full ones, which
+// behave like new buckets, are dropped.
+const maxIdleRateBuckets = 1024
+
+// tokenBucket holds the tokens left at last; one is refilled every 1/rate seconds.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// toolRateLimiter enforces the tool_rate_limits entry of one tool.
+type toolRateLimiter struct {
+	rate      float64 // Tokens refilled per second
+	burst     float64 // Bucket size
+	perClient bool
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket // Keyed by client, or "" for all clients
+	now     func() time.Time
+}
+
+func newToolRateLimiter(cfg config.ToolRateLimitConfig) *toolRateLimiter {
+	return &toolRateLimiter{
+		rate:      cfg.RPS,
+		burst:     float64(cfg.BurstOrDefault()),
+		perClient: cfg.PerClient,
+		buckets:   make(map[string]*tokenBucket),
+		now:       time.Now,
+	}
+}
+
+// take takes a token for a call from client. When none is left it returns false and
+// how long until one is.
+func (l *toolRateLimiter) take(client string) (bool, time.Duration) {
+	client = l.bucket(client)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	b, ok := l.buckets[client]
+	if !ok {
+		if len(l.buckets) >= maxIdleRateBuckets {
+			l.dropFull(now)
+		}
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// bucket returns the bucket of client's calls: "" unless the limit is per client.
+func (l *toolRateLimiter) bucket(client string) string {
+	if !l.perClient {
+		return ""
+	}
+	return client
+}
+
+// dropFull removes the buckets that have refilled completely.
+func (l *toolRateLimiter) dropFull(now time.Time) {
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// checkToolRateLimit takes a token for a call to toolName from client and returns a
+// rate_limit throttle error when the tool's limit is exceeded.
+func (ps *ProxyServer) checkToolRateLimit(toolName, client string) error {
+	limiter := ps.toolRateLimits[toolName]
+	if limiter == nil {
+		return nil
+	}
+	ok, wait, err := ps.takeToolToken(limiter, toolName, client)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return nil
+	}
+	log.Printf("Rate limit exceeded for tool '%s' (client %q)", toolName, client)
+	if toolRateLimitedTotal != nil {
+		toolRateLimitedTotal.WithLabelValues(toolName).Inc()
+	}
+	return throttled(throttleRateLimit, wait, fmt.Errorf("%w: %s", ErrRateLimited, toolName))
+}
+
+// takeToolToken takes a token for a call to toolName from client from the shared
+// rate_limit_store, or from the limiter's in-memory buckets when there is none. While
+// the store cannot be reached, on_failure "open" falls back to the in-memory buckets
+// and "closed" rejects the call as overloaded.
+func (ps *ProxyServer) takeToolToken(limiter *toolRateLimiter, toolName, client string) (bool, time.Duration, error) {
+	if ps.rateLimitStore == nil {
+		ok, wait := limiter.take(client)
+		return ok, wait, nil
+	}
+	ok, wait, err := ps.rateLimitStore.take(ps.rateLimitKey(toolName, limiter.bucket(client)), limiter.rate, limiter.burst)
+	if err == nil {
+		return ok, wait, nil
+	}
+	if ps.rateLimitFailClosed {
+		return false, 0, throttled(throttleOverloaded, rateLimitStoreRetry, fmt.Errorf("%w: %v", ErrRateLimitStore, err))
+	}
+	ok, wait = limiter.take(client)
+	return ok, wait, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRateLimitedHTTPProxy returns an HTTP proxy for a backend with tool1 and tool2,
+// where tool1 is limited by limit.
+func newRateLimitedHTTPProxy(t *testing.T, limit config.ToolRateLimitConfig) *HTTPProxy {
+	backend, conf := testHttpServer("server1", []string{"tool1", "tool2"}, nil, nil, nil)
+	t.Cleanup(backend.Close)
+	ps, err := NewProxyServer(&config.Config{
+		MCPServers:     []config.MCPServerConfig{conf},
+		ToolRateLimits: map[string]config.ToolRateLimitConfig{"tool1": limit},
+	})
+	require.NoError(t, err)
+	t.Cleanup(ps.Shutdown)
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+	return httpProxy
+}
+
+// callToolFrom posts an empty tool call from the client at remoteAddr.
+func callToolFrom(httpProxy *HTTPProxy, tool, remoteAddr string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/tool/"+tool, strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = remoteAddr
+	httpProxy.engine.ServeHTTP(w, req)
+	return w
+}
+
+// TestToolRateLimit tests that calls over a tool's rate limit get 429 with Retry-After
+// while other tools are unaffected.
+func TestToolRateLimit(t *testing.T) {
+	httpProxy := newRateLimitedHTTPProxy(t, config.ToolRateLimitConfig{RPS: 0.5, Burst: 2})
+
+	for i := 0; i < 2; i++ {
+		w := callToolFrom(httpProxy, "tool1", "192.0.2.1:1234")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+	w := callToolFrom(httpProxy, "tool1", "192.0.2.1:1234")
+	require.Equal(t, http.StatusTooManyRequests, w.Code, w.Body.String())
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	var info ThrottleInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+	assert.Equal(t, throttleRateLimit, info.Reason)
+	assert.Equal(t, "Rate limit exceeded for tool 'tool1'", info.Error)
+
+	// The limit is shared by all clients and leaves other tools alone
+	w = callToolFrom(httpProxy, "tool1", "192.0.2.2:1234")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	for i := 0; i < 5; i++ {
+		w := callToolFrom(httpProxy, "tool2", "192.0.2.1:1234")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+}
+
+// TestToolRateLimitPerClient tests that per_client limits give each client its own bucket.
+func TestToolRateLimitPerClient(t *testing.T) {
+	httpProxy := newRateLimitedHTTPProxy(t, config.ToolRateLimitConfig{RPS: 0.5, Burst: 1, PerClient: true})
+
+	assert.Equal(t, http.StatusOK, callToolFrom(httpProxy, "tool1", "192.0.2.1:1234").Code)
+	assert.Equal(t, http.StatusTooManyRequests, callToolFrom(httpProxy, "tool1", "192.0.2.1:1234").Code)
+	assert.Equal(t, http.StatusOK, callToolFrom(httpProxy, "tool1", "192.0.2.2:1234").Code)
+}
+
+// TestToolRateLimiterRefill tests that tokens are refilled at the configured rate up to
+// the burst size, which defaults to the rate.
+func TestToolRateLimiterRefill(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newToolRateLimiter(config.ToolRateLimitConfig{RPS: 2})
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		ok, _ := l.take("a")
+		assert.True(t, ok)
+	}
+	ok, wait := l.take("a")
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	now = now.Add(250 * time.Millisecond)
+	ok, wait = l.take("a")
+	assert.False(t, ok)
+	assert.Equal(t, 250*time.Millisecond, wait)
+
+	// A long pause refills no more than the burst of 2
+	now = now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		ok, _ = l.take("a")
+		assert.True(t, ok)
+	}
+	ok, _ = l.take("a")
+	assert.False(t, ok)
+}
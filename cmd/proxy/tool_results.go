@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"smart-mcp-proxy/internal/config"
+)
+
+// A tool that runs and fails answers with a result with isError set, which clients get
+// as a successful response so the LLM can read the failure. Only failures to reach the
+// backend or to understand its answer are errors (HTTP 5xx, JSON-RPC error).
+
+// stdioToolResponse is a stdio tool call response sent as a JSON-RPC response rather
+// than a bare CallToolResult.
+type stdioToolResponse struct {
+	Result json.RawMessage      `json:"result"`
+	Error  *config.JSONRPCError `json:"error"`
+}
+
+// parseStdioToolResult parses a stdio server's response to a tool call, either a bare
+// CallToolResult or a JSON-RPC response carrying one. A JSON-RPC error is a protocol
+// failure, not a tool error.
+func parseStdioToolResult(toolName string, resp []byte) (*config.CallToolResult, error) {
+	var envelope stdioToolResponse
+	if err := json.Unmarshal(resp, &envelope); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse response from stdio tool '%s': %v", ErrBackendCommunication, toolName, err)
+	}
+	if envelope.Error != nil {
+		return nil, fmt.Errorf("%w: stdio tool '%s' execution failed: %v", ErrBackendCommunication, toolName, envelope.Error)
+	}
+	if envelope.Result != nil {
+		resp = envelope.Result
+	}
+
+	var toolResult config.CallToolResult
+	if err := json.Unmarshal(resp, &toolResult); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse response from stdio tool '%s': %v", ErrBackendCommunication, toolName, err)
+	}
+	return &toolResult, nil
+}
+
+// toolErrorResult returns the tool error result in the body of a non-2xx response to a
+// REST tool call, or nil when the body is not one. Some backends send failed tool runs
+// with an error status; they are still tool errors.
+func toolErrorResult(body []byte) *config.CallToolResult {
+	var toolResult config.CallToolResult
+	if err := json.Unmarshal(body, &toolResult); err != nil {
+		return nil
+	}
+	if !toolResult.IsError && toolResult.ToolError == nil {
+		return nil
+	}
+	toolResult.IsError = true
+	return &toolResult
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// toolFailureBackend is a stdio backend whose tools fail in different ways: stdio_fail
+// and stdio_wrapped_fail are tool errors, bare and as a JSON-RPC result; stdio_rpc_error
+// and stdio_garbage are protocol failures.
+var toolFailureBackend = proxytest.StdioBackend{
+	Tools: []proxytest.Tool{
+		{Name: "stdio_fail"},
+		{Name: "stdio_wrapped_fail", Result: &proxytest.Result{Text: "disk full", IsError: true}},
+		{Name: "stdio_rpc_error"},
+		{Name: "stdio_garbage"},
+	},
+	Calls: map[string]string{
+		"stdio_fail":      proxytest.Echo(`{"content":[{"type":"text","text":"disk full"}],"isError":true}`),
+		"stdio_rpc_error": proxytest.ReplyError(-32603, "backend crashed"),
+		"stdio_garbage":   proxytest.Echo("not json"),
+	},
+}
+
+// testToolFailureServer is a REST backend whose tools fail in different ways: rest_fail
+// and rest_fail_500 are tool errors, with status 200 and 500; rest_down is a plain 500.
+func testToolFailureServer() *proxytest.Backend {
+	diskFull := &proxytest.Result{Text: "disk full", IsError: true}
+	return proxytest.NewBackend([]proxytest.Tool{
+		{Name: "rest_fail", Result: diskFull},
+		{Name: "rest_fail_500", Result: diskFull, Status: http.StatusInternalServerError},
+		{Name: "rest_down", Status: http.StatusInternalServerError},
+	}, nil)
+}
+
+// newToolFailureProxy returns a proxy for testToolFailureServer and toolFailureBackend.
+func newToolFailureProxy(t *testing.T) *ProxyServer {
+	backend := testToolFailureServer()
+	t.Cleanup(backend.Close)
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{
+		{Name: "rest", Address: backend.URL, AllowedTools: []string{"rest_fail", "rest_fail_500", "rest_down"}},
+		{Name: "stdio", Command: toolFailureBackend.Command(), Args: toolFailureBackend.Args(),
+			AllowedTools: []string{"stdio_fail", "stdio_wrapped_fail", "stdio_rpc_error", "stdio_garbage"}},
+	}})
+	require.NoError(t, err)
+	t.Cleanup(ps.Shutdown)
+	return ps
+}
+
+// toolFailureCases lists each tool of newToolFailureProxy and whether it is a tool error,
+// which clients get as a result, rather than a failure to call the tool.
+var toolFailureCases = []struct {
+	tool      string
+	toolError bool
+}{
+	{"rest_fail", true},
+	{"rest_fail_500", true},
+	{"rest_down", false},
+	{"stdio_fail", true},
+	{"stdio_wrapped_fail", true},
+	{"stdio_rpc_error", false},
+	{"stdio_garbage", false},
+}
+
+// TestHTTPToolErrorResults tests that tool errors are answered with 200 and the isError
+// result, and failures to call the tool with 502.
+func TestHTTPToolErrorResults(t *testing.T) {
+	ps := newToolFailureProxy(t)
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	for _, tc := range toolFailureCases {
+		t.Run(tc.tool, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/tool/"+tc.tool, strings.NewReader(`{}`))
+			req.Header.Set("Content-Type", "application/json")
+			httpProxy.engine.ServeHTTP(w, req)
+
+			if !tc.toolError {
+				assert.Equal(t, http.StatusBadGateway, w.Code, w.Body.String())
+				return
+			}
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			var result config.CallToolResult
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+			assert.True(t, result.IsError)
+			require.Len(t, result.Content, 1)
+			assert.Equal(t, "disk full", *result.Content[0].Text)
+		})
+	}
+}
+
+// TestHTTPMapToolErrorsToStatus tests that map_tool_errors_to_status answers tool errors
+// with 422 and the isError result, leaving successful results at 200.
+func TestHTTPMapToolErrorsToStatus(t *testing.T) {
+	backend := proxytest.NewBackend([]proxytest.Tool{
+		{Name: "fail", Result: &proxytest.Result{Text: "disk full", IsError: true}},
+		{Name: "ok", Result: &proxytest.Result{Text: "done"}},
+	}, nil)
+	defer backend.Close()
+
+	for _, mapped := range []bool{false, true} {
+		ps, err := NewProxyServer(&config.Config{
+			MCPServers:            []config.MCPServerConfig{{Name: "rest", Address: backend.URL}},
+			MapToolErrorsToStatus: mapped,
+		})
+		require.NoError(t, err)
+		defer ps.Shutdown()
+		httpProxy, err := NewHTTPProxy(ps, ":0")
+		require.NoError(t, err)
+
+		call := func(tool string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/tool/"+tool, strings.NewReader(`{}`))
+			req.Header.Set("Content-Type", "application/json")
+			httpProxy.engine.ServeHTTP(w, req)
+			return w
+		}
+
+		wantStatus := http.StatusOK
+		if mapped {
+			wantStatus = http.StatusUnprocessableEntity
+		}
+		w := call("fail")
+		assert.Equal(t, wantStatus, w.Code, "map_tool_errors_to_status=%v", mapped)
+		var result config.CallToolResult
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		assert.True(t, result.IsError)
+		require.Len(t, result.Content, 1)
+		assert.Equal(t, "disk full", *result.Content[0].Text)
+
+		assert.Equal(t, http.StatusOK, call("ok").Code, "map_tool_errors_to_status=%v", mapped)
+	}
+}
+
+// TestCommandToolErrorResults tests that tool errors are answered with the isError result
+// and failures to call the tool with a JSON-RPC error.
+func TestCommandToolErrorResults(t *testing.T) {
+	ps := newToolFailureProxy(t)
+	cmdProxy, err := NewCommandProxy(ps)
+	require.NoError(t, err)
+
+	for _, tc := range toolFailureCases {
+		t.Run(tc.tool, func(t *testing.T) {
+			req := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"` + tc.tool + `","arguments":{}}}`
+			respBytes, err := cmdProxy.handleCommandRequest([]byte(req))
+			require.NoError(t, err)
+			var resp struct {
+				Result *config.CallToolResult `json:"result"`
+				Error  *rpcError              `json:"error"`
+			}
+			require.NoError(t, json.Unmarshal(respBytes, &resp), string(respBytes))
+
+			if !tc.toolError {
+				require.NotNil(t, resp.Error, string(respBytes))
+				assert.Equal(t, -32000, resp.Error.Code)
+				assert.Nil(t, resp.Result)
+				return
+			}
+			require.Nil(t, resp.Error, string(respBytes))
+			require.NotNil(t, resp.Result)
+			assert.True(t, resp.Result.IsError)
+			require.Len(t, resp.Result.Content, 1)
+			assert.Equal(t, "disk full", *resp.Result.Content[0].Text)
+		})
+	}
+}
+
+// TestStdioToolCallEnvelope tests that stdio tool calls are sent as MCP tools/call
+// JSON-RPC requests, with the method taken from stdio_tool_method when set.
+func TestStdioToolCallEnvelope(t *testing.T) {
+	for _, method := range []string{"", "call_tool"} {
+		var backendReq []byte
+		server := &config.MCPServer{
+			Config: config.MCPServerConfig{Name: "stdio", Command: "unused", AllowedTools: []string{"echo"}, StdioToolMethod: method},
+			HandleStdioRequestFunc: func(reqBytes []byte) ([]byte, error) {
+				backendReq = reqBytes
+				return []byte(`{"jsonrpc":"2.0","id":1,"result":{"content":[]}}`), nil
+			},
+		}
+		ps := &ProxyServer{mcpServers: []*config.MCPServer{server}, recentCalls: newCallRing(recentCallsSize)}
+
+		_, err := ps.CallToolWithMeta("echo", map[string]interface{}{"n": 1}, map[string]interface{}{"progressToken": "p1"})
+		require.NoError(t, err)
+		wantMethod := method
+		if wantMethod == "" {
+			wantMethod = "tools/call"
+		}
+		assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"method":"`+wantMethod+`","params":{"name":"echo","arguments":{"n":1},"_meta":{"progressToken":"p1"}}}`, string(backendReq))
+	}
+}
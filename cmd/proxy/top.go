@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Number of recent tool calls shown by top.
+const topRecentCalls = 10
+
+// metricsSummary holds the proxy-wide totals shown by top.
+type metricsSummary struct {
+	HTTPRequests  float64
+	HedgedCalls   float64
+	BufferedBytes float64
+}
+
+// topSnapshot is everything top renders in one refresh.
+type topSnapshot struct {
+	Status  StatusSnapshot
+	Metrics metricsSummary
+}
+
+// statusSource supplies snapshots to top, either from a remote proxy or an embedded one.
+type statusSource interface {
+	Name() string
+	Snapshot(ctx context.Context) (topSnapshot, error)
+}
+
+// remoteSource reads /status and /metrics from a running proxy's HTTP or admin listener.
+type remoteSource struct {
+	baseURL string
+	token   string // Admin API key, needed for the log pane
+	client  *http.Client
+}
+
+func newRemoteSource(addr, token string) *remoteSource {
+	if !strings.HasPrefix(addr, "http://") && !strings.HasPrefix(addr, "https://") {
+		addr = "http://" + addr
+	}
+	return &remoteSource{baseURL: strings.TrimRight(addr, "/"), token: token, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (r *remoteSource) Name() string { return r.baseURL }
+
+func (r *remoteSource) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s returned status %d", path, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+func (r *remoteSource) Snapshot(ctx context.Context) (topSnapshot, error) {
+	var snap topSnapshot
+	resp, err := r.get(ctx, "/status")
+	if err != nil {
+		return snap, err
+	}
+	err = json.NewDecoder(resp.Body).Decode(&snap.Status)
+	resp.Body.Close()
+	if err != nil {
+		return snap, fmt.Errorf("failed to decode /status: %w", err)
+	}
+
+	resp, err = r.get(ctx, "/metrics")
+	if err != nil {
+		return snap, err
+	}
+	defer resp.Body.Close()
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return snap, fmt.Errorf("failed to parse /metrics: %w", err)
+	}
+	snap.Metrics = summarizeMetrics(families)
+	return snap, nil
+}
+
+// embeddedSource reads status from a ProxyServer running in the top process.
+type embeddedSource struct {
+	ps *ProxyServer
+}
+
+func (e *embeddedSource) Name() string { return "embedded" }
+
+func (e *embeddedSource) Snapshot(ctx context.Context) (topSnapshot, error) {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return topSnapshot{}, err
+	}
+	byName := make(map[string]*dto.MetricFamily, len(families))
+	for _, family := range families {
+		byName[family.GetName()] = family
+	}
+	return topSnapshot{Status: e.ps.Status(), Metrics: summarizeMetrics(byName)}, nil
+}
+
+// summarizeMetrics totals the proxy metrics top displays.
+func summarizeMetrics(families map[string]*dto.MetricFamily) metricsSummary {
+	sum := func(name string) float64 {
+		var total float64
+		family, ok := families[name]
+		if !ok {
+			return 0
+		}
+		for _, m := range family.GetMetric() {
+			switch {
+			case m.Counter != nil:
+				total += m.Counter.GetValue()
+			case m.Gauge != nil:
+				total += m.Gauge.GetValue()
+			}
+		}
+		return total
+	}
+	return metricsSummary{
+		HTTPRequests:  sum("mcp_proxy_http_requests_total"),
+		HedgedCalls:   sum("mcp_proxy_hedged_tool_calls_total"),
+		BufferedBytes: sum("mcp_proxy_buffered_request_bytes"),
+	}
+}
+
+// renderTop writes a plain-text view of snap. logLines limits the log pane to the latest lines.
+func renderTop(w io.Writer, source string, snap topSnapshot, logLines int) {
+	fmt.Fprintf(w, "smart-mcp-proxy top - %s - %s\n\n", source, snap.Status.Time.Format("15:04:05"))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BACKEND\tTRANSPORT\tHEALTH\tTOOLS\tRESOURCES\tRESTARTS\tDEPENDS ON")
+	for _, s := range snap.Status.Servers {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%s\n", s.Name, s.Transport, s.Health, s.Tools, s.Resources, s.Restarts, strings.Join(s.DependsOn, ", "))
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\nhttp requests: %.0f   hedged tool calls: %.0f   buffered bytes: %.0f\n\n",
+		snap.Metrics.HTTPRequests, snap.Metrics.HedgedCalls, snap.Metrics.BufferedBytes)
+
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tTOOL\tLATENCY\tERROR")
+	for i, call := range snap.Status.RecentCalls {
+		if i == topRecentCalls {
+			break
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.1fms\t%s\n", call.Time.Format("15:04:05"), call.Tool, call.DurationMs, call.Error)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w, "\nLOGS")
+	logs := snap.Status.RecentLogs
+	if len(logs) > logLines {
+		logs = logs[len(logs)-logLines:]
+	}
+	for _, line := range logs {
+		fmt.Fprintln(w, line)
+	}
+}
+
+// runTopCommand implements `smart-mcp-proxy top`, a live status view of a proxy.
+// It refreshes every interval until interrupted, or prints a single snapshot with --once.
+func runTopCommand(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("top", flag.ExitOnError)
+	addrFlag := fs.String("addr", "", "Address of a running proxy's HTTP or admin listener (e.g. localhost:8080)")
+	tokenFlag := fs.String("token", "", "Admin API key for -addr; without it the proxy leaves out recent log lines")
+	configPathFlag := fs.String("config", "", "Run the proxy embedded from this config file instead of connecting to -addr")
+	onceFlag := fs.Bool("once", false, "Print a single plain-text snapshot and exit")
+	intervalFlag := fs.Duration("interval", time.Second, "Refresh interval")
+	logLinesFlag := fs.Int("log-lines", 10, "Number of log lines shown")
+	fs.Parse(args)
+
+	var source statusSource
+	switch {
+	case *addrFlag != "":
+		source = newRemoteSource(*addrFlag, *tokenFlag)
+	case *configPathFlag != "":
+		// Keep backend logs out of the terminal; they are shown in the log pane instead.
+		log.SetOutput(recentLogs)
+		cfg, err := config.LoadConfig(*configPathFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
+			return 1
+		}
+		ps, err := NewProxyServer(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create core proxy server: %v\n", err)
+			return 1
+		}
+		defer ps.Shutdown()
+		registerMetrics()
+		source = &embeddedSource{ps: ps}
+	default:
+		fmt.Fprintln(os.Stderr, "usage: smart-mcp-proxy top (-addr host:port | -config path) [--once] [-interval 1s]")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *onceFlag {
+		snap, err := source.Snapshot(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read status: %v\n", err)
+			return 1
+		}
+		renderTop(out, source.Name(), snap, *logLinesFlag)
+		return 0
+	}
+	return runTopLoop(ctx, out, source, *intervalFlag, *logLinesFlag)
+}
+
+// runTopLoop redraws the screen every interval until ctx is cancelled.
+func runTopLoop(ctx context.Context, out io.Writer, source statusSource, interval time.Duration, logLines int) int {
+	fmt.Fprint(out, "\x1b[?25l") // Hide the cursor while redrawing
+	defer fmt.Fprint(out, "\x1b[?25h\n")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		snap, err := source.Snapshot(ctx)
+		fmt.Fprint(out, "\x1b[H\x1b[2J") // Move home and clear the screen
+		if err != nil && !errors.Is(err, context.Canceled) {
+			fmt.Fprintf(out, "smart-mcp-proxy top - %s\n\nfailed to read status: %v\n", source.Name(), err)
+		} else if err == nil {
+			renderTop(out, source.Name(), snap, logLines)
+		}
+		select {
+		case <-ctx.Done():
+			return 0
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTopOnce tests that `top --once` renders a snapshot of a remote proxy.
+func TestTopOnce(t *testing.T) {
+	httpProxy, ps, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+	_, err := ps.CallTool("tool3", map[string]interface{}{})
+	require.NoError(t, err)
+
+	proxyServer := httptest.NewServer(httpProxy.engine)
+	defer proxyServer.Close()
+
+	var out bytes.Buffer
+	code := runTopCommand([]string{"-addr", proxyServer.URL, "--once"}, &out)
+	require.Equal(t, 0, code)
+
+	text := out.String()
+	assert.Contains(t, text, "BACKEND")
+	assert.Contains(t, text, "server1")
+	assert.Contains(t, text, "server2")
+	assert.Contains(t, text, "tool3")
+	assert.NotContains(t, text, "\x1b[", "--once output must be plain text")
+}
+
+// TestTopLoopStopsOnCancel tests that the refresh loop exits cleanly when interrupted.
+func TestTopLoopStopsOnCancel(t *testing.T) {
+	httpProxy, _, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+	proxyServer := httptest.NewServer(httpProxy.engine)
+	defer proxyServer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	var out bytes.Buffer
+	code := runTopLoop(ctx, &out, newRemoteSource(proxyServer.URL, ""), 50*time.Millisecond, 5)
+	assert.Equal(t, 0, code)
+	assert.GreaterOrEqual(t, strings.Count(out.String(), "BACKEND"), 2, "expected several refreshes")
+	assert.True(t, strings.HasSuffix(out.String(), "\x1b[?25h\n"), "cursor must be restored on exit")
+}
@@ -0,0 +1,60 @@
+//go:build !noui
+
+package main
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uiFiles is the web UI: index.html, rendered with the base path, and static assets.
+//
+//go:embed ui
+var uiFiles embed.FS
+
+// uiAvailable reports whether this binary includes the web UI; builds with the noui
+// tag leave it out.
+const uiAvailable = true
+
+// uiContentSecurityPolicy keeps the UI from loading or contacting anything but the
+// proxy itself.
+const uiContentSecurityPolicy = "default-src 'none'; script-src 'self'; style-src 'self'; connect-src 'self'; img-src 'self' data:; form-action 'self'; frame-ancestors 'none'; base-uri 'none'"
+
+// registerUI serves the web UI at /ui/, with /ui redirecting there. Links in the page
+// are prefixed with the path of public_base_url like those of GET /.
+func (h *HTTPProxy) registerUI(engine *gin.Engine) error {
+	basePath := h.ps.basePath()
+	page, err := template.ParseFS(uiFiles, "ui/index.html")
+	if err != nil {
+		return err
+	}
+	var index bytes.Buffer
+	if err := page.Execute(&index, struct{ BasePath string }{basePath}); err != nil {
+		return err
+	}
+	assets, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		return err
+	}
+	fileServer := http.StripPrefix("/ui/", http.FileServer(http.FS(assets)))
+
+	engine.GET("/ui", func(c *gin.Context) {
+		c.Redirect(http.StatusMovedPermanently, basePath+"/ui/")
+	})
+	engine.GET("/ui/*filepath", func(c *gin.Context) {
+		c.Header("Content-Security-Policy", uiContentSecurityPolicy)
+		c.Header("X-Content-Type-Options", "nosniff")
+		if file := c.Param("filepath"); file == "/" || file == "/index.html" {
+			c.Header("Cache-Control", "no-cache")
+			c.Data(http.StatusOK, "text/html; charset=utf-8", index.Bytes())
+			return
+		}
+		fileServer.ServeHTTP(c.Writer, c.Request)
+	})
+	return nil
+}
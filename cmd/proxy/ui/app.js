@@ -0,0 +1,254 @@
+// Web UI of smart-mcp-proxy. It only talks to the proxy's own HTTP API, under the
+// base path the page was rendered with, sending the saved API key as a Bearer token.
+(function () {
+  "use strict";
+
+  const basePath = document.body.dataset.basePath || "";
+  const keyStorage = "smart-mcp-proxy-api-key";
+  let tools = [];
+
+  function el(tag, attrs, ...children) {
+    const node = document.createElement(tag);
+    for (const [name, value] of Object.entries(attrs || {})) {
+      if (name === "text") {
+        node.textContent = value;
+      } else {
+        node.setAttribute(name, value);
+      }
+    }
+    for (const child of children) {
+      node.append(child);
+    }
+    return node;
+  }
+
+  function showError(message) {
+    const error = document.getElementById("error");
+    error.textContent = message;
+    error.hidden = !message;
+  }
+
+  async function api(path, options) {
+    const headers = Object.assign({ Accept: "application/json" }, (options && options.headers) || {});
+    const key = sessionStorage.getItem(keyStorage);
+    if (key) {
+      headers.Authorization = "Bearer " + key;
+    }
+    const response = await fetch(basePath + path, Object.assign({}, options, { headers }));
+    const text = await response.text();
+    let body = text;
+    try {
+      body = JSON.parse(text);
+    } catch (e) {
+      // Not JSON; keep the text
+    }
+    return { ok: response.ok, status: response.status, body };
+  }
+
+  async function load(path) {
+    const response = await api(path);
+    if (!response.ok) {
+      throw new Error(path + ": " + response.status + " " + JSON.stringify(response.body));
+    }
+    return response.body;
+  }
+
+  // --- Tools ---
+
+  function renderToolList() {
+    const filter = document.getElementById("tool-filter").value.toLowerCase();
+    const list = document.getElementById("tool-list");
+    list.replaceChildren();
+    for (const tool of tools) {
+      if (filter && !tool.name.toLowerCase().includes(filter) && !(tool.description || "").toLowerCase().includes(filter)) {
+        continue;
+      }
+      const item = el("li", {}, el("strong", { text: tool.name }), el("br"), el("small", { text: tool.serverName }));
+      item.addEventListener("click", () => {
+        for (const selected of list.querySelectorAll(".selected")) {
+          selected.classList.remove("selected");
+        }
+        item.classList.add("selected");
+        showTool(tool);
+      });
+      list.append(item);
+    }
+  }
+
+  async function showTool(tool) {
+    const detail = document.getElementById("tool-detail");
+    let schema = tool.inputSchema;
+    if (tool.lazySchema) {
+      try {
+        schema = (await load("/tools/" + encodeURIComponent(tool.name) + "/schema")).inputSchema;
+      } catch (e) {
+        showError(e.message);
+        return;
+      }
+    }
+    schema = schema || { type: "object" };
+
+    const form = el("form");
+    const fields = [];
+    const properties = schema.properties || {};
+    const required = schema.required || [];
+    for (const [name, property] of Object.entries(properties)) {
+      const field = inputFor(name, property || {}, required.includes(name));
+      fields.push(field);
+      form.append(field.node);
+    }
+    const raw = el("textarea", { rows: "6" });
+    raw.value = "{}";
+    const rawField = el("div", { class: "field" }, el("label", { text: "Arguments (JSON)" }), raw);
+    rawField.hidden = fields.length > 0;
+    form.append(rawField);
+    const result = el("pre", { hidden: "" });
+    form.append(el("button", { type: "submit", text: "Call " + tool.name }));
+    form.addEventListener("submit", async (event) => {
+      event.preventDefault();
+      let args;
+      try {
+        args = fields.length > 0 ? collect(fields) : JSON.parse(raw.value || "{}");
+      } catch (e) {
+        showError("Invalid arguments: " + e.message);
+        return;
+      }
+      showError("");
+      const response = await api("/tool/" + encodeURIComponent(tool.name), {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify(args),
+      });
+      result.hidden = false;
+      result.textContent = response.status + "\n" + (typeof response.body === "string" ? response.body : JSON.stringify(response.body, null, 2));
+    });
+
+    detail.replaceChildren(
+      el("h2", { text: tool.name }),
+      el("p", { class: "description", text: tool.description || "" }),
+      el("p", { class: "hint", text: "Server: " + tool.serverName }),
+      form,
+      result,
+      el("details", {}, el("summary", { text: "Input schema" }), el("pre", { text: JSON.stringify(schema, null, 2) })),
+    );
+  }
+
+  // inputFor returns the form field of one schema property and a function reading its
+  // value, or undefined when left empty.
+  function inputFor(name, property, isRequired) {
+    const label = el("label", { text: name });
+    if (isRequired) {
+      label.classList.add("required");
+    }
+    const node = el("div", { class: "field" }, label);
+    if (property.description) {
+      node.append(el("small", { class: "description", text: property.description }));
+    }
+    let input;
+    let read;
+    const type = Array.isArray(property.type) ? property.type.find((t) => t !== "null") : property.type;
+    if (Array.isArray(property.enum)) {
+      input = el("select", {}, el("option", { value: "", text: "" }));
+      property.enum.forEach((value, i) => input.append(el("option", { value: String(i), text: JSON.stringify(value) })));
+      read = () => (input.value === "" ? undefined : property.enum[Number(input.value)]);
+    } else if (type === "boolean") {
+      input = el("input", { type: "checkbox" });
+      read = () => input.checked;
+    } else if (type === "integer" || type === "number") {
+      input = el("input", { type: "number", step: type === "integer" ? "1" : "any" });
+      read = () => (input.value === "" ? undefined : Number(input.value));
+    } else if (type === "object" || type === "array" || type === undefined) {
+      input = el("textarea", { placeholder: type === "array" ? "[]" : "JSON value" });
+      read = () => (input.value.trim() === "" ? undefined : JSON.parse(input.value));
+    } else {
+      input = el("input", { type: "text" });
+      read = () => (input.value === "" ? undefined : input.value);
+    }
+    if (property.default !== undefined) {
+      if (input.type === "checkbox") {
+        input.checked = Boolean(property.default);
+      } else if (input.tagName !== "SELECT") {
+        input.value = typeof property.default === "string" ? property.default : JSON.stringify(property.default);
+      }
+    }
+    node.append(input);
+    return { name, node, read };
+  }
+
+  function collect(fields) {
+    const args = {};
+    for (const field of fields) {
+      let value;
+      try {
+        value = field.read();
+      } catch (e) {
+        throw new Error(field.name + ": " + e.message);
+      }
+      if (value !== undefined) {
+        args[field.name] = value;
+      }
+    }
+    return args;
+  }
+
+  // --- Other views ---
+
+  function rows(id, items, cells) {
+    const body = document.getElementById(id);
+    body.replaceChildren(...items.map((item) => el("tr", {}, ...cells(item).map((cell) => el("td", { text: cell === undefined ? "" : String(cell) })))));
+  }
+
+  const views = {
+    async tools() {
+      tools = (await load("/tools")).tools || [];
+      renderToolList();
+    },
+    async resources() {
+      const resources = (await load("/resources")).resources || [];
+      rows("resource-rows", resources, (r) => [r.name, r.serverName, r.uri || r.uriTemplate, r.mimeType, r.description]);
+    },
+    async servers() {
+      const status = await load("/status");
+      rows("server-rows", status.servers || [], (s) => [s.name, s.transport, s.health, s.tools, s.resources, s.restarts]);
+      for (const cell of document.querySelectorAll("#server-rows td:nth-child(3)")) {
+        cell.classList.toggle("health-ok", cell.textContent === "ok");
+      }
+    },
+    async status() {
+      const status = await load("/status");
+      rows("call-rows", (status.recentCalls || []).slice().reverse(), (c) => [c.time, c.tool, c.client, c.durationMs.toFixed(1) + " ms", c.error]);
+      document.getElementById("logs").textContent = (status.recentLogs || []).join("\n");
+    },
+  };
+
+  async function show() {
+    const name = location.hash.slice(1) in views ? location.hash.slice(1) : "tools";
+    for (const section of document.querySelectorAll(".view")) {
+      section.hidden = section.id !== name;
+    }
+    for (const link of document.querySelectorAll("nav a")) {
+      link.classList.toggle("active", link.dataset.view === name);
+    }
+    showError("");
+    try {
+      await views[name]();
+    } catch (e) {
+      showError(e.message);
+    }
+  }
+
+  document.getElementById("api-key").value = sessionStorage.getItem(keyStorage) || "";
+  document.getElementById("api-key-form").addEventListener("submit", (event) => {
+    event.preventDefault();
+    const key = document.getElementById("api-key").value;
+    if (key) {
+      sessionStorage.setItem(keyStorage, key);
+    } else {
+      sessionStorage.removeItem(keyStorage);
+    }
+    show();
+  });
+  document.getElementById("tool-filter").addEventListener("input", renderToolList);
+  window.addEventListener("hashchange", show);
+  show();
+})();
@@ -0,0 +1,19 @@
+//go:build noui
+
+package main
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uiAvailable reports whether this binary includes the web UI; builds with the noui
+// tag leave it out.
+const uiAvailable = false
+
+// registerUI logs that ui.enabled has no effect in a binary built without the UI.
+func (h *HTTPProxy) registerUI(engine *gin.Engine) error {
+	log.Println("WARNING: ui.enabled is set but this binary was built with the noui tag; the web UI is not served")
+	return nil
+}
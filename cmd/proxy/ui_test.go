@@ -0,0 +1,86 @@
+//go:build !noui
+
+package main
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+	"smart-mcp-proxy/proxytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUI tests that ui.enabled serves the web UI under the public_base_url path, lists
+// it in GET /, and that the UI is not served by default.
+func TestUI(t *testing.T) {
+	backend := proxytest.NewBackend([]proxytest.Tool{{Name: "search"}}, nil)
+	defer backend.Close()
+	newProxy := func(ui *config.UIConfig) *HTTPProxy {
+		ps, err := NewProxyServer(&config.Config{
+			MCPServers:    []config.MCPServerConfig{{Name: "server1", Address: backend.URL}},
+			PublicBaseURL: "https://proxy.example/mcp/",
+			UI:            ui,
+		})
+		require.NoError(t, err)
+		t.Cleanup(ps.Shutdown)
+		httpProxy, err := NewHTTPProxy(ps, ":0")
+		require.NoError(t, err)
+		return httpProxy
+	}
+	get := func(httpProxy *HTTPProxy, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	httpProxy := newProxy(&config.UIConfig{Enabled: true})
+	w := get(httpProxy, "/ui")
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "/mcp/ui/", w.Header().Get("Location"))
+
+	w = get(httpProxy, "/ui/")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Header().Get("Content-Security-Policy"), "connect-src 'self'")
+	assert.Contains(t, w.Body.String(), `data-base-path="/mcp"`)
+	assert.Contains(t, w.Body.String(), `src="/mcp/ui/app.js"`)
+
+	w = get(httpProxy, "/ui/app.js")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "javascript")
+	assert.Equal(t, http.StatusNotFound, get(httpProxy, "/ui/missing.js").Code)
+
+	var index indexResponse
+	require.NoError(t, json.Unmarshal(get(httpProxy, "/").Body.Bytes(), &index))
+	assert.Contains(t, index.Endpoints, indexEndpoint{Method: "GET", Path: "/mcp/ui/", Description: "Web UI for browsing and calling tools"})
+
+	disabled := newProxy(nil)
+	assert.Equal(t, http.StatusNotFound, get(disabled, "/ui/").Code)
+	require.NoError(t, json.Unmarshal(get(disabled, "/").Body.Bytes(), &index))
+	for _, e := range index.Endpoints {
+		assert.NotContains(t, e.Path, "/ui/")
+	}
+}
+
+// TestUIFilesStayLocal tests that the UI assets reference no other host.
+func TestUIFilesStayLocal(t *testing.T) {
+	err := fs.WalkDir(uiFiles, "ui", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := fs.ReadFile(uiFiles, path)
+		require.NoError(t, err)
+		for _, scheme := range []string{"http://", "https://", "//cdn"} {
+			assert.False(t, strings.Contains(string(content), scheme), "%s references %s", path, scheme)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
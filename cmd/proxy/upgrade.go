@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Environment variables passed to the new binary during an upgrade. They name the
+// inherited file descriptors of the listening socket and of the readiness pipe.
+const (
+	upgradeListenerFDEnv = "MCP_PROXY_LISTENER_FD"
+	upgradeReadyFDEnv    = "MCP_PROXY_UPGRADE_READY_FD"
+)
+
+// upgradeReadyTimeout bounds how long the old process waits for the new one to start
+// serving before giving up and keeping the listener.
+var upgradeReadyTimeout = 60 * time.Second
+
+// ErrUpgradeInProgress is returned when an upgrade is requested while another is running.
+var ErrUpgradeInProgress = errors.New("upgrade already in progress")
+
+// upgradeCommand builds the command for the new binary. It re-runs the current
+// executable with the same arguments and is replaced in tests.
+var upgradeCommand = func() (*exec.Cmd, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd, nil
+}
+
+// upgradeListener returns the listening socket handed over by a previous process, or
+// listens on addr when the proxy was started normally.
+func upgradeListener(addr string) (net.Listener, error) {
+	fdStr := os.Getenv(upgradeListenerFDEnv)
+	if fdStr == "" {
+		return net.Listen("tcp", addr)
+	}
+	os.Unsetenv(upgradeListenerFDEnv)
+	fd, err := strconv.Atoi(fdStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s '%s': %w", upgradeListenerFDEnv, fdStr, err)
+	}
+	f := os.NewFile(uintptr(fd), "inherited-listener")
+	defer f.Close() // FileListener duplicates the descriptor
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use inherited listener: %w", err)
+	}
+	log.Printf("Took over listening socket %s from the previous process", ln.Addr())
+	return ln, nil
+}
+
+// notifyUpgradeReady tells the previous process that this one is serving, so it can
+// stop accepting and drain. It does nothing when the proxy was started normally.
+func notifyUpgradeReady() {
+	fdStr := os.Getenv(upgradeReadyFDEnv)
+	if fdStr == "" {
+		return
+	}
+	os.Unsetenv(upgradeReadyFDEnv)
+	fd, err := strconv.Atoi(fdStr)
+	if err != nil {
+		log.Printf("Invalid %s '%s': %v", upgradeReadyFDEnv, fdStr, err)
+		return
+	}
+	f := os.NewFile(uintptr(fd), "upgrade-ready")
+	defer f.Close()
+	if _, err := f.Write([]byte("ready\n")); err != nil {
+		log.Printf("Failed to notify the previous process: %v", err)
+	}
+}
+
+// Upgrade starts a new copy of the binary that inherits the listening socket. Once the
+// new process reports it is serving, the old one stops accepting connections and drains
+// in-flight requests (Run returns). It returns the new process ID.
+//
+// Stdio servers are not handed over: the new process starts its own, and the old ones
+// are stopped after the drain. If the new process fails to start, the old one keeps serving.
+func (h *HTTPProxy) Upgrade(ctx context.Context) (int, error) {
+	if !h.upgrading.CompareAndSwap(false, true) {
+		return 0, ErrUpgradeInProgress
+	}
+	pid, err := h.startUpgrade(ctx)
+	if err != nil {
+		h.upgrading.Store(false)
+		h.restoreAdminListener()
+		return 0, err
+	}
+	close(h.upgraded)
+	return pid, nil
+}
+
+// startUpgrade execs the new binary and waits until it reports ready.
+func (h *HTTPProxy) startUpgrade(ctx context.Context) (int, error) {
+	tcpListener, ok := h.listener.(*net.TCPListener)
+	if !ok {
+		return 0, errors.New("listener cannot be handed over")
+	}
+	listenerFile, err := tcpListener.File()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get listener file: %w", err)
+	}
+	defer listenerFile.Close()
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	defer readyR.Close()
+
+	cmd, err := upgradeCommand()
+	if err != nil {
+		readyW.Close()
+		return 0, err
+	}
+	// ExtraFiles[i] becomes file descriptor 3+i in the child
+	cmd.ExtraFiles = []*os.File{listenerFile, readyW}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, upgradeListenerFDEnv+"=3", upgradeReadyFDEnv+"=4")
+	// The admin listener is not handed over; the new binary binds the address again
+	h.releaseAdminListener()
+	err = cmd.Start()
+	readyW.Close()
+	if restoreErr := restoreNonblocking(tcpListener); restoreErr != nil {
+		log.Printf("Failed to restore non-blocking listener: %v", restoreErr)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to start new binary: %w", err)
+	}
+	pid := cmd.Process.Pid
+	log.Printf("Started new proxy process %d, waiting for it to take over", pid)
+
+	ready := make(chan error, 1)
+	go func() {
+		// EOF before a line means the child exited or closed the pipe without serving
+		_, err := bufio.NewReader(readyR).ReadString('\n')
+		ready <- err
+	}()
+	timer := time.NewTimer(upgradeReadyTimeout)
+	defer timer.Stop()
+	select {
+	case err = <-ready:
+	case <-timer.C:
+		err = fmt.Errorf("timed out after %s", upgradeReadyTimeout)
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return 0, fmt.Errorf("new proxy process %d did not become ready: %w", pid, err)
+	}
+	// The new process outlives this one
+	go cmd.Wait()
+	log.Printf("New proxy process %d is serving; draining this process", pid)
+	return pid, nil
+}
+
+// handleAdminUpgrade handles POST /admin/upgrade, handing the listener over to a new
+// copy of the binary. It answers once the new process is serving.
+func (h *HTTPProxy) handleAdminUpgrade(c *gin.Context) {
+	pid, err := h.Upgrade(c.Request.Context())
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrUpgradeInProgress) {
+			status = http.StatusConflict
+		}
+		log.Printf("Upgrade failed: %v", err)
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "upgraded", "pid": pid})
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// upgradeHelperEnv marks the test binary re-executed as the "new" proxy process.
+const upgradeHelperEnv = "MCP_PROXY_TEST_UPGRADE_HELPER"
+
+// TestUpgradeHelperProcess is not a real test. It is run by TestUpgradeHandover as the
+// new binary: it takes over the inherited listener, answers "new" and reports ready.
+func TestUpgradeHelperProcess(t *testing.T) {
+	if os.Getenv(upgradeHelperEnv) != "1" {
+		t.Skip("helper process for TestUpgradeHandover")
+	}
+	listener, err := upgradeListener("")
+	if err != nil {
+		os.Exit(1)
+	}
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "new")
+	}))
+	notifyUpgradeReady()
+	time.Sleep(30 * time.Second) // Killed by the parent test
+	os.Exit(0)
+}
+
+// get fetches url and returns the body, or "" on error.
+func get(url string) string {
+	client := &http.Client{Timeout: time.Second, Transport: &http.Transport{DisableKeepAlives: true}}
+	resp, err := client.Get(url)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+// TestUpgradeHandover tests that a new process takes over the listening socket and
+// keeps serving on the same address after the old one drains.
+func TestUpgradeHandover(t *testing.T) {
+	origCommand := upgradeCommand
+	upgradeCommand = func() (*exec.Cmd, error) {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestUpgradeHelperProcess$")
+		cmd.Env = append(os.Environ(), upgradeHelperEnv+"=1")
+		return cmd, nil
+	}
+	defer func() { upgradeCommand = origCommand }()
+
+	_, ps, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+	httpProxy, err := NewHTTPProxy(ps, "127.0.0.1:0")
+	require.NoError(t, err)
+	require.NoError(t, httpProxy.serve())
+	url := "http://" + httpProxy.listener.Addr().String()
+	assert.Contains(t, get(url+"/healthz"), `"ok"`)
+
+	pid, err := httpProxy.Upgrade(context.Background())
+	require.NoError(t, err)
+	require.Positive(t, pid)
+	defer func() {
+		if p, err := os.FindProcess(pid); err == nil {
+			p.Kill()
+		}
+	}()
+	select {
+	case <-httpProxy.upgraded:
+	default:
+		t.Fatal("upgraded channel should be closed after a successful upgrade")
+	}
+
+	_, err = httpProxy.Upgrade(context.Background())
+	assert.ErrorIs(t, err, ErrUpgradeInProgress)
+
+	// Drain the old server the way Run does; the address keeps answering from the new process
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, httpProxy.srv.Shutdown(ctx))
+	assert.Equal(t, "new", get(url+"/healthz"))
+}
+
+// TestUpgradeFailureKeepsServing tests that the old process keeps its listener when the
+// new binary exits without becoming ready.
+func TestUpgradeFailureKeepsServing(t *testing.T) {
+	origCommand := upgradeCommand
+	upgradeCommand = func() (*exec.Cmd, error) { return exec.Command("false"), nil }
+	defer func() { upgradeCommand = origCommand }()
+
+	_, ps, servers := setupTestHTTPProxy(t)
+	for _, server := range servers {
+		defer server.Close()
+	}
+	httpProxy, err := NewHTTPProxy(ps, "127.0.0.1:0")
+	require.NoError(t, err)
+	require.NoError(t, httpProxy.serve())
+	defer httpProxy.srv.Close()
+
+	_, err = httpProxy.Upgrade(context.Background())
+	require.Error(t, err)
+	assert.False(t, httpProxy.upgrading.Load())
+
+	_, isTCP := httpProxy.listener.(*net.TCPListener)
+	assert.True(t, isTCP)
+	assert.True(t, strings.Contains(get("http://"+httpProxy.listener.Addr().String()+"/healthz"), `"ok"`))
+}
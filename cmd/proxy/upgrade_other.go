@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "net"
+
+// restoreNonblocking is a no-op where descriptors cannot be handed to a child process.
+func restoreNonblocking(l *net.TCPListener) error {
+	return nil
+}
@@ -0,0 +1,25 @@
+//go:build unix
+
+package main
+
+import (
+	"net"
+	"syscall"
+)
+
+// restoreNonblocking puts the listener back into non-blocking mode. Passing its
+// descriptor to a child process switches the shared socket to blocking mode, which
+// would leave Accept stuck in a system call that Close cannot interrupt.
+func restoreNonblocking(l *net.TCPListener) error {
+	rc, err := l.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var setErr error
+	if err := rc.Control(func(fd uintptr) {
+		setErr = syscall.SetNonblock(int(fd), true)
+	}); err != nil {
+		return err
+	}
+	return setErr
+}
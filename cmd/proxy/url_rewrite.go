@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"mime"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"smart-mcp-proxy/internal/config"
+)
+
+// publicBaseURL returns the base URL clients reach the proxy at: public_base_url when
+// configured, otherwise the listen address, with a wildcard host shown as localhost.
+// The request's Host header is client-controlled and never used.
+func (h *HTTPProxy) publicBaseURL() string {
+	if h.ps.publicBaseURL != "" {
+		return h.ps.publicBaseURL
+	}
+	return listenURL(h.srv.Addr)
+}
+
+// isTextualContentType reports whether a response of this Content-Type may be rewritten
+// as text: text/*, JSON, XML and JavaScript.
+func isTextualContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == "application/json",
+		mediaType == "application/xml",
+		mediaType == "application/javascript",
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	return false
+}
+
+// rewriteBaseURLs replaces the server's address in a textual, unencoded response body
+// with base. Links to the server's resources, under its resource_path_template, become
+// links to the proxy's resource route for the server (/resource/<server>/...); any other
+// link keeps its path. Only whole hosts are replaced: http://backend:9000 is left alone in
+// http://backend:90001 or http://backend:9000.evil.example.
+func rewriteBaseURLs(out *ProxyResponseOutput, server *config.MCPServer, base string) {
+	if len(out.Body) == 0 || !isTextualContentType(out.Headers.Get("Content-Type")) {
+		return
+	}
+	if enc := out.Headers.Get("Content-Encoding"); enc != "" && enc != "identity" {
+		return
+	}
+	address := strings.TrimSuffix(server.Config.Address, "/")
+	base = strings.TrimSuffix(base, "/")
+	body := out.Body
+	if prefix, ok := server.Config.ResourcePathPrefix(); ok {
+		body = replaceURLPrefix(body, address+prefix, base+"/resource/"+url.PathEscape(server.Config.Name)+"/")
+	}
+	body = replaceURLPrefix(body, address, base)
+	if bytes.Equal(body, out.Body) {
+		return
+	}
+	out.Body = body
+	if out.Headers.Get("Content-Length") != "" {
+		out.Headers = out.Headers.Clone()
+		out.Headers.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+}
+
+// replaceURLPrefix replaces the occurrences of prefix in body that end on a URL boundary:
+// followed by "/", a double quote or the end of body, or ending in "/" themselves.
+func replaceURLPrefix(body []byte, prefix, replacement string) []byte {
+	if prefix == "" {
+		return body
+	}
+	var out []byte
+	rest := body
+	for {
+		i := bytes.Index(rest, []byte(prefix))
+		if i < 0 {
+			break
+		}
+		end := i + len(prefix)
+		if strings.HasSuffix(prefix, "/") || end == len(rest) || rest[end] == '/' || rest[end] == '"' {
+			out = append(out, rest[:i]...)
+			out = append(out, replacement...)
+		} else {
+			out = append(out, rest[:end]...)
+		}
+		rest = rest[end:]
+	}
+	if out == nil {
+		return body
+	}
+	return append(out, rest...)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"smart-mcp-proxy/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLinkingServer returns a backend whose "docs" resource answers with absolute links
+// to itself, as JSON or, under /resource/docs/raw, as binary data.
+func testLinkingServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tools", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"tools":[]}`)
+	})
+	mux.HandleFunc("/resources", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"resources":[{"name":"docs","uri":"docs"}]}`)
+	})
+	mux.HandleFunc("/resource/docs/", func(w http.ResponseWriter, r *http.Request) {
+		self := "http://" + r.Host
+		if r.URL.Path == "/resource/docs/raw" {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			fmt.Fprint(w, self+"/resource/docs/next")
+			return
+		}
+		body := fmt.Sprintf(`{"next":"%s/resource/docs/page2","home":"%s/index.html"}`, self, self)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+		fmt.Fprint(w, body)
+	})
+	return httptest.NewServer(mux)
+}
+
+// TestRewriteURLs tests that backend-absolute URLs in textual resource bodies are
+// rewritten to the proxy's base URL.
+func TestRewriteURLs(t *testing.T) {
+	backend := testLinkingServer()
+	defer backend.Close()
+	cfg := &config.Config{MCPServers: []config.MCPServerConfig{{Name: "docs-server", Address: backend.URL, RewriteURLs: true}}}
+	ps, err := NewProxyServer(cfg)
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, "proxy.local:8080")
+	require.NoError(t, err)
+
+	// Without public_base_url the listen address is used, never the request's Host
+	req := httptest.NewRequest("GET", "/resource/docs-server/docs/page1", nil)
+	req.Host = "attacker.example"
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"next":"http://proxy.local:8080/resource/docs-server/docs/page2","home":"http://proxy.local:8080/index.html"}`, w.Body.String())
+	assert.Equal(t, fmt.Sprint(w.Body.Len()), w.Header().Get("Content-Length"))
+
+	// Binary content is passed through untouched
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", "/resource/docs-server/docs/raw", nil))
+	assert.Equal(t, backend.URL+"/resource/docs/next", w.Body.String())
+
+	// public_base_url takes precedence, and applies in command mode
+	ps.publicBaseURL = "https://mcp.example.com"
+	w = httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", "/resource/docs-server/docs/page1", nil))
+	assert.Contains(t, w.Body.String(), `"next":"https://mcp.example.com/resource/docs-server/docs/page2"`)
+
+	cmdProxy, err := NewCommandProxy(ps)
+	require.NoError(t, err)
+	respBytes, err := cmdProxy.handleCommandRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"resources/access","params":{"serverName":"docs-server","resourceName":"docs","proxyPath":"/page1","method":"GET"}}`))
+	require.NoError(t, err)
+	assert.Contains(t, string(respBytes), `"home":"https://mcp.example.com/index.html"`)
+}
+
+// TestRewriteURLsDisabled tests that bodies are left alone unless rewrite_urls is set.
+func TestRewriteURLsDisabled(t *testing.T) {
+	backend := testLinkingServer()
+	defer backend.Close()
+	ps, err := NewProxyServer(&config.Config{MCPServers: []config.MCPServerConfig{{Name: "docs-server", Address: backend.URL}}})
+	require.NoError(t, err)
+	defer ps.Shutdown()
+	httpProxy, err := NewHTTPProxy(ps, ":0")
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	httpProxy.engine.ServeHTTP(w, httptest.NewRequest("GET", "/resource/docs-server/docs/page1", nil))
+	assert.Contains(t, w.Body.String(), backend.URL+"/resource/docs/page2")
+}
+
+// TestRewriteURLsBoundary tests that the backend address is only replaced where it ends
+// on a URL boundary, not inside a longer host or port.
+func TestRewriteURLsBoundary(t *testing.T) {
+	server := &config.MCPServer{Config: config.MCPServerConfig{Name: "docs", Address: "http://backend:9000"}}
+	out := &ProxyResponseOutput{
+		Headers: http.Header{"Content-Type": {"text/plain"}},
+		Body:    []byte(`"http://backend:9000" http://backend:9000/a http://backend:90001/b http://backend:9000.evil.example/c http://backend:9000`),
+	}
+	rewriteBaseURLs(out, server, "https://mcp.example.com")
+	assert.Equal(t, `"https://mcp.example.com" https://mcp.example.com/a http://backend:90001/b http://backend:9000.evil.example/c https://mcp.example.com`, string(out.Body))
+}
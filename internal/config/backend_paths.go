@@ -0,0 +1,111 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Default paths of an HTTP server's REST routes. {name} stands for the tool or
+// resource name.
+const (
+	DefaultToolPathTemplate     = "/tool/{name}"
+	DefaultResourcePathTemplate = "/resource/{name}"
+	DefaultToolsPath            = "/tools"
+	DefaultResourcesPath        = "/resources"
+)
+
+// pathNamePlaceholder is replaced with the tool or resource name in path templates.
+const pathNamePlaceholder = "{name}"
+
+// ToolPath returns the backend path for calling the named tool.
+func (sc MCPServerConfig) ToolPath(name string) string {
+	return expandPathTemplate(pathOrDefault(sc.ToolPathTemplate, DefaultToolPathTemplate), name)
+}
+
+// ResourcePath returns the backend path of the named resource. Proxied sub-paths are
+// appended to it.
+func (sc MCPServerConfig) ResourcePath(name string) string {
+	return expandPathTemplate(pathOrDefault(sc.ResourcePathTemplate, DefaultResourcePathTemplate), name)
+}
+
+// ResourcePathPrefix returns the part of the resource path template before {name}.
+// ok is false when text follows {name}, since a resource link then cannot be split
+// into the resource name and a sub-path.
+func (sc MCPServerConfig) ResourcePathPrefix() (prefix string, ok bool) {
+	prefix, suffix, _ := strings.Cut(pathOrDefault(sc.ResourcePathTemplate, DefaultResourcePathTemplate), pathNamePlaceholder)
+	return prefix, suffix == ""
+}
+
+// ToolsPathOrDefault returns the path of the tool listing used by REST discovery.
+func (sc MCPServerConfig) ToolsPathOrDefault() string {
+	return pathOrDefault(sc.ToolsPath, DefaultToolsPath)
+}
+
+// ResourcesPathOrDefault returns the path of the resource listing used by REST discovery.
+func (sc MCPServerConfig) ResourcesPathOrDefault() string {
+	return pathOrDefault(sc.ResourcesPath, DefaultResourcesPath)
+}
+
+func pathOrDefault(path, def string) string {
+	if path == "" {
+		return def
+	}
+	return path
+}
+
+func expandPathTemplate(template, name string) string {
+	return strings.Replace(template, pathNamePlaceholder, name, 1)
+}
+
+// validatePathTemplate checks a tool or resource path template: an absolute path
+// containing {name} exactly once and no other braces, query or fragment.
+func validatePathTemplate(template string) error {
+	if strings.Count(template, pathNamePlaceholder) != 1 {
+		return fmt.Errorf("must contain %s exactly once", pathNamePlaceholder)
+	}
+	return validateBackendPath(strings.Replace(template, pathNamePlaceholder, "name", 1))
+}
+
+// validateBackendPath checks a fixed backend path such as tools_path.
+func validateBackendPath(path string) error {
+	if !strings.HasPrefix(path, "/") {
+		return errors.New("must start with '/'")
+	}
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		return fmt.Errorf("must not contain '%c'", path[i])
+	}
+	if strings.ContainsAny(path, "{}") {
+		return fmt.Errorf("unknown placeholder; only %s is supported in path templates", pathNamePlaceholder)
+	}
+	return nil
+}
+
+// validateBackendPaths checks the server's path settings, which only apply to HTTP servers.
+func (sc MCPServerConfig) validateBackendPaths() error {
+	settings := []struct {
+		key, value string
+		template   bool
+	}{
+		{"tool_path_template", sc.ToolPathTemplate, true},
+		{"resource_path_template", sc.ResourcePathTemplate, true},
+		{"tools_path", sc.ToolsPath, false},
+		{"resources_path", sc.ResourcesPath, false},
+	}
+	for _, s := range settings {
+		if s.value == "" {
+			continue
+		}
+		if strings.TrimSpace(sc.Address) == "" {
+			return fmt.Errorf("%s requires address", s.key)
+		}
+		validate := validateBackendPath
+		if s.template {
+			validate = validatePathTemplate
+		}
+		if err := validate(s.value); err != nil {
+			return fmt.Errorf("invalid %s '%s': %w", s.key, s.value, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestMCPServerConfig_BackendPaths tests path template expansion and the defaults.
+func TestMCPServerConfig_BackendPaths(t *testing.T) {
+	def := MCPServerConfig{}
+	if got := def.ToolPath("echo"); got != "/tool/echo" {
+		t.Errorf("default ToolPath = %q", got)
+	}
+	if got := def.ResourcePath("docs"); got != "/resource/docs" {
+		t.Errorf("default ResourcePath = %q", got)
+	}
+	if def.ToolsPathOrDefault() != "/tools" || def.ResourcesPathOrDefault() != "/resources" {
+		t.Errorf("default listing paths = %q, %q", def.ToolsPathOrDefault(), def.ResourcesPathOrDefault())
+	}
+
+	sc := MCPServerConfig{ToolPathTemplate: "/api/v1/tools/{name}/call", ResourcePathTemplate: "/api/v1/resource/{name}"}
+	if got := sc.ToolPath("echo"); got != "/api/v1/tools/echo/call" {
+		t.Errorf("ToolPath = %q", got)
+	}
+	if got := sc.ResourcePath("docs"); got != "/api/v1/resource/docs" {
+		t.Errorf("ResourcePath = %q", got)
+	}
+	if prefix, ok := sc.ResourcePathPrefix(); !ok || prefix != "/api/v1/resource/" {
+		t.Errorf("ResourcePathPrefix = %q, %v", prefix, ok)
+	}
+	if _, ok := (MCPServerConfig{ResourcePathTemplate: "/r/{name}/content"}).ResourcePathPrefix(); ok {
+		t.Error("expected no prefix for a template with text after {name}")
+	}
+}
+
+// TestValidate_BackendPaths tests validation of the path settings.
+func TestValidate_BackendPaths(t *testing.T) {
+	invalid := []MCPServerConfig{
+		{Name: "s", Address: "http://backend.example", ToolPathTemplate: "/tool"},
+		{Name: "s", Address: "http://backend.example", ToolPathTemplate: "tool/{name}"},
+		{Name: "s", Address: "http://backend.example", ToolPathTemplate: "/{name}/{name}"},
+		{Name: "s", Address: "http://backend.example", ResourcePathTemplate: "/resource/{id}/{name}"},
+		{Name: "s", Address: "http://backend.example", ResourcePathTemplate: "/resource/{name}?x=1"},
+		{Name: "s", Address: "http://backend.example", ToolsPath: "tools"},
+		{Name: "s", Address: "http://backend.example", ResourcesPath: "/resources/{name}"},
+		{Name: "s", Command: "cat", ToolPathTemplate: "/api/tool/{name}"},
+	}
+	for _, sc := range invalid {
+		if err := (&Config{MCPServers: []MCPServerConfig{sc}}).Validate(); err == nil {
+			t.Errorf("expected validation error for %+v", sc)
+		}
+	}
+
+	valid := &Config{MCPServers: []MCPServerConfig{{
+		Name: "s", Address: "http://backend.example",
+		ToolPathTemplate: "/api/v1/tool/{name}", ResourcePathTemplate: "/api/v1/resource/{name}",
+		ToolsPath: "/api/v1/tools", ResourcesPath: "/api/v1/resources",
+	}}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+}
+
+// TestDiscovery_CustomPaths tests that REST discovery lists tools and resources at
+// tools_path and resources_path.
+func TestDiscovery_CustomPaths(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/tools", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tools":[{"name":"echo","inputSchema":{"type":"object"}}]}`))
+	})
+	mux.HandleFunc("/api/v1/resources", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"resources":[{"name":"docs"}]}`))
+	})
+	backend := httptest.NewServer(mux)
+	defer backend.Close()
+
+	server := &MCPServer{
+		Config:     MCPServerConfig{Name: "s", Address: backend.URL, ToolsPath: "/api/v1/tools", ResourcesPath: "/api/v1/resources"},
+		httpClient: backend.Client(),
+	}
+	tools, resources, err := server.fetchToolsAndResourcesHTTP(t.Context())
+	if err != nil {
+		t.Fatalf("discovery failed: %v", err)
+	}
+	if len(tools) != 1 || tools[0].Name != "echo" || len(resources) != 1 || resources[0].Name != "docs" {
+		t.Errorf("discovered tools %+v and resources %+v", tools, resources)
+	}
+}
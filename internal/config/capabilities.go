@@ -0,0 +1,92 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+)
+
+// Discovery capabilities a server may leave unimplemented.
+const (
+	CapabilityTools     = "tools"
+	CapabilityResources = "resources"
+)
+
+// errCapabilityAbsent marks a discovery call the server does not implement.
+var errCapabilityAbsent = errors.New("not implemented by server")
+
+// isCapabilityAbsent reports whether a discovery error means the server does not
+// implement the call: errCapabilityAbsent, or a JSON-RPC error with code -32601
+// whatever its message.
+func isCapabilityAbsent(err error) bool {
+	var rpcErr *JSONRPCError
+	return errors.Is(err, errCapabilityAbsent) || (errors.As(err, &rpcErr) && rpcErr.Code == jsonRPCMethodNotFound)
+}
+
+// absentStatus reports whether a discovery endpoint's HTTP status means the
+// endpoint does not exist.
+func absentStatus(code int) bool {
+	return code == http.StatusNotFound || code == http.StatusMethodNotAllowed
+}
+
+// stdioResponseError converts the error member of a stdio JSON-RPC response.
+func stdioResponseError(rpcErr interface{}, respBytes []byte) error {
+	if errMap, ok := rpcErr.(map[string]interface{}); ok {
+		if code, ok := errMap["code"].(float64); ok && int(code) == jsonRPCMethodNotFound {
+			return fmt.Errorf("%w: %v", errCapabilityAbsent, rpcErr)
+		}
+	}
+	return fmt.Errorf("error response: %v %s", rpcErr, string(respBytes))
+}
+
+// capabilityAbsent reports whether an earlier discovery found the capability missing.
+// Absent capabilities are not requested again.
+func (s *MCPServer) capabilityAbsent(capability string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.absentCapabilities[capability]
+}
+
+// recordAbsentCapabilities stores the capabilities a successful discovery found
+// missing. A server implementing neither is reported as an error.
+func (s *MCPServer) recordAbsentCapabilities(toolsAbsent, resourcesAbsent bool) error {
+	if toolsAbsent && resourcesAbsent {
+		return errors.New("server implements neither tools nor resources discovery")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for capability, absent := range map[string]bool{CapabilityTools: toolsAbsent, CapabilityResources: resourcesAbsent} {
+		if !absent || s.absentCapabilities[capability] {
+			continue
+		}
+		if s.absentCapabilities == nil {
+			s.absentCapabilities = make(map[string]bool)
+		}
+		s.absentCapabilities[capability] = true
+		log.Printf("MCP server %s does not implement %s discovery; skipping it in future refreshes", s.Config.Name, capability)
+	}
+	return nil
+}
+
+// MissingCapabilities returns the discovery capabilities (CapabilityTools,
+// CapabilityResources) the server was found not to implement, sorted.
+func (s *MCPServer) MissingCapabilities() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var missing []string
+	for capability := range s.absentCapabilities {
+		missing = append(missing, capability)
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// BackendCapabilities returns the capabilities of the server's last successful
+// initialize result, or nil when it never answered initialize, e.g. a REST server.
+func (s *MCPServer) BackendCapabilities() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.capabilities
+}
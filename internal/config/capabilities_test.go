@@ -0,0 +1,141 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+// TestDiscovery_MissingCapability_HTTP tests that a 404 or 405 from one discovery
+// endpoint keeps the other endpoint's results and is not requested again.
+func TestDiscovery_MissingCapability_HTTP(t *testing.T) {
+	tests := []struct {
+		name          string
+		missing       string
+		status        int
+		wantTools     int
+		wantResources int
+	}{
+		{"no resources", CapabilityResources, http.StatusNotFound, 1, 0},
+		{"no tools", CapabilityTools, http.StatusMethodNotAllowed, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var missingRequests atomic.Int32
+			mux := http.NewServeMux()
+			mux.HandleFunc("/tools", func(w http.ResponseWriter, r *http.Request) {
+				if tt.missing == CapabilityTools {
+					missingRequests.Add(1)
+					w.WriteHeader(tt.status)
+					return
+				}
+				w.Write([]byte(`{"tools":[{"name":"tool1","inputSchema":{"type":"object"}}]}`))
+			})
+			mux.HandleFunc("/resources", func(w http.ResponseWriter, r *http.Request) {
+				if tt.missing == CapabilityResources {
+					missingRequests.Add(1)
+					w.WriteHeader(tt.status)
+					return
+				}
+				w.Write([]byte(`{"resources":[{"name":"res1"}]}`))
+			})
+			backend := httptest.NewServer(mux)
+			defer backend.Close()
+
+			server := &MCPServer{Config: MCPServerConfig{Name: "http-server", Address: backend.URL}, httpClient: &http.Client{}}
+			for i := 0; i < 2; i++ {
+				if err := server.refreshToolsAndResources(); err != nil {
+					t.Fatalf("refreshToolsAndResources failed: %v", err)
+				}
+			}
+			if len(server.GetTools()) != tt.wantTools || len(server.GetResources()) != tt.wantResources {
+				t.Errorf("got %d tools and %d resources, want %d and %d", len(server.GetTools()), len(server.GetResources()), tt.wantTools, tt.wantResources)
+			}
+			if got := server.MissingCapabilities(); !reflect.DeepEqual(got, []string{tt.missing}) {
+				t.Errorf("MissingCapabilities() = %v, want [%s]", got, tt.missing)
+			}
+			if got := missingRequests.Load(); got != 1 {
+				t.Errorf("expected the missing endpoint to be requested once, got %d", got)
+			}
+		})
+	}
+}
+
+// TestDiscovery_MissingCapability_Stdio tests that method-not-found is recognized by
+// its code whatever the message, in either direction.
+func TestDiscovery_MissingCapability_Stdio(t *testing.T) {
+	notFound := `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"Méthode introuvable"}}`
+	tests := []struct {
+		name          string
+		responses     map[string][]string
+		missing       string
+		wantTools     int
+		wantResources int
+	}{
+		{
+			name: "no resources",
+			responses: map[string][]string{
+				"tools/list":     {`{"result":{"tools":[{"name":"tool1"}]}}`},
+				"resources/list": {notFound},
+			},
+			missing:   CapabilityResources,
+			wantTools: 1,
+		},
+		{
+			name: "no tools",
+			responses: map[string][]string{
+				"tools/list":     {notFound},
+				"resources/list": {`{"result":{"resources":[{"name":"res1"}]}}`, `{"result":{"resources":[{"name":"res1"}]}}`},
+			},
+			missing:       CapabilityTools,
+			wantResources: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &mockMCPServer{
+				MCPServer: MCPServer{Config: MCPServerConfig{Name: "stdio-server", Command: "mockcmd"}},
+				responses: tt.responses,
+				callCount: make(map[string]int),
+			}
+			server.HandleStdioRequestFunc = server.HandleStdioRequest
+
+			// The second refresh has no mock response left for the missing method, so
+			// it fails unless the method is skipped.
+			if tt.missing == CapabilityResources {
+				server.responses["tools/list"] = append(server.responses["tools/list"], server.responses["tools/list"][0])
+			}
+			for i := 0; i < 2; i++ {
+				if err := server.refreshToolsAndResources(); err != nil {
+					t.Fatalf("refreshToolsAndResources failed: %v", err)
+				}
+			}
+			if len(server.tools) != tt.wantTools || len(server.resources) != tt.wantResources {
+				t.Errorf("got %d tools and %d resources, want %d and %d", len(server.tools), len(server.resources), tt.wantTools, tt.wantResources)
+			}
+			if got := server.MissingCapabilities(); !reflect.DeepEqual(got, []string{tt.missing}) {
+				t.Errorf("MissingCapabilities() = %v, want [%s]", got, tt.missing)
+			}
+			if got := server.callCount[tt.missing+"/list"]; got != 1 {
+				t.Errorf("expected %s/list to be requested once, got %d", tt.missing, got)
+			}
+		})
+	}
+}
+
+// TestDiscovery_NoCapabilities tests that a server implementing neither discovery
+// call fails discovery.
+func TestDiscovery_NoCapabilities(t *testing.T) {
+	backend := httptest.NewServer(http.NotFoundHandler())
+	defer backend.Close()
+
+	server := &MCPServer{Config: MCPServerConfig{Name: "http-server", Address: backend.URL}, httpClient: &http.Client{}}
+	if err := server.refreshToolsAndResources(); err == nil {
+		t.Error("expected an error for a server without tools or resources")
+	}
+	if got := server.MissingCapabilities(); len(got) != 0 {
+		t.Errorf("expected no capabilities recorded, got %v", got)
+	}
+}
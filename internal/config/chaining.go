@@ -0,0 +1,187 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// BackendSmartMCPProxy is the type of a server that is another instance of this proxy.
+const BackendSmartMCPProxy = "smart-mcp-proxy"
+
+// Routes of a chained proxy used by discovery.
+const (
+	chainedToolsPath               = "/tools"
+	chainedResourcesPath           = "/resources"
+	chainedRestrictedToolsPath     = "/restricted-tools"
+	chainedRestrictedResourcesPath = "/restricted-resources"
+	chainedStatusPath              = "/status"
+)
+
+// ChainedServerStatus is the health of a server behind a chained proxy, as reported by
+// its /status endpoint.
+type ChainedServerStatus struct {
+	Name      string `json:"name"`
+	Health    string `json:"health"`
+	Tools     int    `json:"tools"`
+	Resources int    `json:"resources"`
+
+	// ChainedServers are the servers behind it when it is a chained proxy itself.
+	ChainedServers []ChainedServerStatus `json:"chainedServers,omitempty"`
+}
+
+// IsChainedProxy reports whether the server is another smart-mcp-proxy.
+func (sc MCPServerConfig) IsChainedProxy() bool {
+	return sc.Type == BackendSmartMCPProxy
+}
+
+// validateType checks the server's type. A chained proxy is reached over its HTTP
+// routes, so it needs an address and fixes the REST discovery paths.
+func (sc MCPServerConfig) validateType() error {
+	switch sc.Type {
+	case "":
+		return nil
+	case BackendSmartMCPProxy:
+	default:
+		return fmt.Errorf("invalid type '%s': must be '%s' or unset", sc.Type, BackendSmartMCPProxy)
+	}
+	if strings.TrimSpace(sc.Address) == "" || strings.TrimSpace(sc.Command) != "" {
+		return fmt.Errorf("type '%s' requires address without command", BackendSmartMCPProxy)
+	}
+	if sc.Discovery != "" && sc.Discovery != DiscoveryREST {
+		return fmt.Errorf("type '%s' requires discovery '%s'", BackendSmartMCPProxy, DiscoveryREST)
+	}
+	if sc.ToolPathTemplate != "" || sc.ResourcePathTemplate != "" || sc.ToolsPath != "" || sc.ResourcesPath != "" {
+		return fmt.Errorf("type '%s' does not support custom backend paths", BackendSmartMCPProxy)
+	}
+	return nil
+}
+
+// chainedTool is a tool in the listings of a chained proxy, annotated with the
+// name of the server providing it there.
+type chainedTool struct {
+	tool       discoveredTool
+	serverName string
+}
+
+func (t *chainedTool) UnmarshalJSON(data []byte) error {
+	var origin struct {
+		ServerName string `json:"serverName"`
+	}
+	if err := json.Unmarshal(data, &origin); err != nil {
+		return err
+	}
+	t.serverName = origin.ServerName
+	return json.Unmarshal(data, &t.tool)
+}
+
+// chainedResource is a resource in the listings of a chained proxy.
+type chainedResource struct {
+	ResourceInfo
+	ServerName string `json:"serverName"`
+}
+
+// fetchToolsAndResourcesChained discovers a chained proxy: its allowed and restricted
+// tools and resources, each with the server providing it there, and the status of
+// its servers. Restricted entries are kept restricted here with FilterChainedProxy.
+// A failed /status request is only logged.
+func (s *MCPServer) fetchToolsAndResourcesChained(ctx context.Context) ([]ToolInfo, []ResourceInfo, error) {
+	client := *s.httpClient
+	client.Timeout = 0
+	get := func(path string, into interface{}) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.Config.Address+path, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to get %s: %w", path, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s endpoint returned status %d", path, resp.StatusCode)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(into); err != nil {
+			return fmt.Errorf("failed to decode %s response: %w", path, err)
+		}
+		return nil
+	}
+
+	var tools []ToolInfo
+	for _, listing := range []struct {
+		path       string
+		restricted bool
+	}{{chainedToolsPath, false}, {chainedRestrictedToolsPath, true}} {
+		var data struct {
+			Tools []chainedTool `json:"tools"`
+		}
+		if err := get(listing.path, &data); err != nil {
+			return nil, nil, err
+		}
+		for _, t := range data.Tools {
+			tool := ToolInfo(t.tool)
+			tool.ChainedServer = t.serverName
+			if listing.restricted {
+				tool.RestrictedBy = FilterChainedProxy
+			}
+			tools = append(tools, tool)
+		}
+	}
+
+	var resources []ResourceInfo
+	for _, listing := range []struct {
+		path       string
+		restricted bool
+	}{{chainedResourcesPath, false}, {chainedRestrictedResourcesPath, true}} {
+		var data struct {
+			Resources []chainedResource `json:"resources"`
+		}
+		if err := get(listing.path, &data); err != nil {
+			return nil, nil, err
+		}
+		for _, r := range data.Resources {
+			resource := r.ResourceInfo
+			resource.ChainedServer = r.ServerName
+			if listing.restricted {
+				resource.RestrictedBy = FilterChainedProxy
+			}
+			resources = append(resources, resource)
+		}
+	}
+
+	var status struct {
+		Servers []ChainedServerStatus `json:"servers"`
+	}
+	if err := get(chainedStatusPath, &status); err != nil {
+		log.Printf("MCP server %s: failed to get the status of the chained proxy: %v", s.Config.Name, err)
+	}
+	s.mu.Lock()
+	s.chainedServers = status.Servers
+	s.mu.Unlock()
+	return tools, resources, nil
+}
+
+// ChainedServers returns the status of the servers behind a chained proxy as of the
+// last discovery, or nil for other servers.
+func (s *MCPServer) ChainedServers() []ChainedServerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ChainedServerStatus(nil), s.chainedServers...)
+}
+
+// ResourcePath returns the backend path of the named resource: on a chained proxy,
+// its resource route for the server providing the resource there, otherwise
+// MCPServerConfig.ResourcePath.
+func (s *MCPServer) ResourcePath(name string) string {
+	if s.Config.IsChainedProxy() {
+		for _, resource := range s.GetResources() {
+			if resource.Name == name && resource.ChainedServer != "" {
+				return "/resource/" + resource.ChainedServer + "/" + name
+			}
+		}
+	}
+	return s.Config.ResourcePath(name)
+}
@@ -9,13 +9,24 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"math"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"smart-mcp-proxy/internal/jsonschema"
+
+	"golang.org/x/net/http/httpguts"
+	"golang.org/x/net/http/httpproxy"
 )
 
 // MCPServerConfig represents the configuration for a single MCP server.
@@ -27,11 +38,887 @@ type MCPServerConfig struct {
 	Env              map[string]interface{} `json:"env,omitempty"`
 	AllowedTools     []string               `json:"allowed_tools,omitempty"`
 	AllowedResources []string               `json:"allowed_resources,omitempty"`
+	WorkingDir       string                 `json:"working_dir,omitempty"`
+
+	// AllowedToolsFrom loads allowed_tools from a "file://" or "https://" URL instead,
+	// one name or glob per line, and reloads it every AllowedToolsRefreshSeconds.
+	AllowedToolsFrom           string `json:"allowed_tools_from,omitempty"`
+	AllowedToolsRefreshSeconds int    `json:"allowed_tools_refresh_seconds,omitempty"`
+
+	// MatchMode sets how allowed_tools and allowed_resources entries match names:
+	// MatchExact (default), MatchCaseInsensitive or MatchNormalized.
+	MatchMode string `json:"match_mode,omitempty"`
+
+	// AllowedMimeTypes and DeniedMimeTypes filter advertised resources by MIME type.
+	// Entries match exactly or by wildcard ("text/*"), ignoring case and parameters.
+	AllowedMimeTypes []string `json:"allowed_mime_types,omitempty"`
+	DeniedMimeTypes  []string `json:"denied_mime_types,omitempty"`
+	// MimeTypeFallback decides resources with an empty MIME type or one matched by
+	// neither list: "allow" or "restrict". Defaults to "restrict" when
+	// allowed_mime_types is set and "allow" otherwise.
+	MimeTypeFallback string `json:"mime_type_fallback,omitempty"`
+
+	// Enabled set to false keeps the server in the config without starting it.
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// ToolPriority lists tool names to place first in tool listings, in the given order.
+	ToolPriority []string `json:"tool_priority,omitempty"`
+	// ListLimit caps how many of the server's tools, and of its resources, are listed.
+	// Unlisted ones can still be called. Zero uses the global list_limit.
+	ListLimit int `json:"list_limit,omitempty"`
+
+	// Display sets how UIs present the server next to its tools and resources.
+	Display *DisplayConfig `json:"display,omitempty"`
+
+	// DiscoveryTimeoutSeconds bounds each attempt to list tools and resources, independent
+	// of the tool call timeout. Zero uses DefaultDiscoveryTimeout.
+	DiscoveryTimeoutSeconds int `json:"discovery_timeout_seconds,omitempty"`
+	// DiscoveryRetries is how many times a failed discovery attempt is retried.
+	DiscoveryRetries int `json:"discovery_retries,omitempty"`
+	// DiscoveryMaxPages caps the nextCursor pages followed per list call. Zero uses
+	// DefaultDiscoveryMaxPages.
+	DiscoveryMaxPages int `json:"discovery_max_pages,omitempty"`
+	// StdioTimeoutSeconds bounds each write of a request to a stdio server's stdin and
+	// each read of its stdout. Zero uses DefaultStdioTimeout.
+	StdioTimeoutSeconds int `json:"stdio_timeout_seconds,omitempty"`
+	// DiscoveryMaxItems caps the tools, and separately the resources, kept from one
+	// discovery. Zero uses DefaultDiscoveryMaxItems.
+	DiscoveryMaxItems int `json:"discovery_max_items,omitempty"`
+
+	// HealthPath is a path on an HTTP server's address (e.g. "/healthz") that is
+	// polled to decide whether the server is healthy. When empty, the outcome of
+	// discovery is the health signal.
+	HealthPath string `json:"health_path,omitempty"`
+	// HealthIntervalSeconds is how often HealthPath is polled. Zero uses
+	// DefaultHealthInterval.
+	HealthIntervalSeconds int `json:"health_interval_seconds,omitempty"`
+
+	// SLOMs is the response time objective of the server's tool calls and resource
+	// requests in milliseconds. Slower responses count as SLO violations. Zero disables
+	// SLO tracking.
+	SLOMs int `json:"slo_ms,omitempty"`
+
+	// StrictSchemas restricts tools whose inputSchema is missing, null or not an object
+	// instead of advertising them with an empty object schema.
+	StrictSchemas bool `json:"strict_schemas,omitempty"`
+	// LazySchemas keeps only the name, description and annotations of discovered tools,
+	// dropping their schemas. GET /tools/:toolName/schema fetches a schema on demand.
+	LazySchemas bool `json:"lazy_schemas,omitempty"`
+
+	// DependsOn names servers that must be ready before this one is started.
+	DependsOn []string `json:"depends_on,omitempty"`
+	// DependsOnTimeout is how long to wait for dependencies before starting anyway
+	// (e.g. "30s"). Defaults to DefaultDependsOnTimeout.
+	DependsOnTimeout string `json:"depends_on_timeout,omitempty"`
+
+	Retry          *RetryConfig          `json:"retry,omitempty"`
+	CircuitBreaker *CircuitBreakerConfig `json:"circuit_breaker,omitempty"`
+
+	// MirrorTo copies this server's tool calls to a shadow server for comparison.
+	MirrorTo *MirrorConfig `json:"mirror_to,omitempty"`
+
+	// Discovery selects how an HTTP server is listed and called: "rest" (default),
+	// "jsonrpc" or "auto".
+	Discovery string `json:"discovery,omitempty"`
+	// Type "smart-mcp-proxy" makes the server at Address another instance of this
+	// proxy, whose servers and restricted listings are chained into this one.
+	Type string `json:"type,omitempty"`
+
+	// HTTPProxy routes this server's HTTP requests through an egress proxy
+	// (e.g. "http://proxy:3128"), overriding HTTP_PROXY and HTTPS_PROXY.
+	HTTPProxy string `json:"http_proxy,omitempty"`
+	// NoProxy lists hosts reached directly, in NO_PROXY syntax (e.g. "internal.example,10.0.0.0/8").
+	// It overrides NO_PROXY for this server.
+	NoProxy string `json:"no_proxy,omitempty"`
+
+	// FollowRedirects set to false returns an HTTP server's redirect responses to the
+	// caller instead of following them. MaxRedirects caps the redirects followed; zero
+	// uses DefaultMaxRedirects. Redirects to another host fail unless
+	// AllowCrossHostRedirects is set.
+	FollowRedirects         *bool `json:"follow_redirects,omitempty"`
+	MaxRedirects            int   `json:"max_redirects,omitempty"`
+	AllowCrossHostRedirects bool  `json:"allow_cross_host_redirects,omitempty"`
+
+	// ForwardHeaders lists the client request headers copied to this server when
+	// proxying resource requests. When empty, all headers except hop-by-hop ones are.
+	ForwardHeaders []string `json:"forward_headers,omitempty"`
+
+	// SigningSecret, when set, signs every tool call and resource request to this HTTP
+	// server with an HMAC of a timestamp and the body (see SignRequest).
+	SigningSecret string `json:"signing_secret,omitempty"`
+	// SigningAlgorithm is the HMAC hash, SigningSHA256 (default) or SigningSHA512.
+	SigningAlgorithm string `json:"signing_algorithm,omitempty"`
+	// SignatureHeader and TimestampHeader name the headers carrying the signature and
+	// its timestamp, "X-Signature" and "X-Timestamp" by default.
+	SignatureHeader string `json:"signature_header,omitempty"`
+	TimestampHeader string `json:"timestamp_header,omitempty"`
+
+	// Sensitive excludes the server's resource accesses from access analytics and
+	// the per-resource metrics.
+	Sensitive bool `json:"sensitive,omitempty"`
+
+	// Lazy keeps a stdio server's process stopped until a request needs it, and stops
+	// it again after stdio_idle_timeout. Its tools come from the lazy_cache_dir file
+	// when present, otherwise from a one-time discovery at startup.
+	Lazy bool `json:"lazy,omitempty"`
+
+	// ToolPathTemplate and ResourcePathTemplate are the backend paths for tool calls and
+	// resource requests on an HTTP server, with {name} replaced by the tool or resource
+	// name. They default to "/tool/{name}" and "/resource/{name}".
+	ToolPathTemplate     string `json:"tool_path_template,omitempty"`
+	ResourcePathTemplate string `json:"resource_path_template,omitempty"`
+	// ToolsPath and ResourcesPath are the listing endpoints used by REST discovery,
+	// "/tools" and "/resources" by default.
+	ToolsPath     string `json:"tools_path,omitempty"`
+	ResourcesPath string `json:"resources_path,omitempty"`
+
+	// StaticResources are resources the proxy serves itself, listed with the server's
+	// discovered resources and filtered like them. A server with only static resources
+	// needs neither an address nor a command.
+	StaticResources []StaticResourceConfig `json:"static_resources,omitempty"`
+	// StaticTools are tools the proxy serves itself by running a local command, listed
+	// and filtered like the discovered tools.
+	StaticTools []StaticToolConfig `json:"static_tools,omitempty"`
+
+	// StdioToolMethod is the JSON-RPC method of tool calls sent to a stdio server, for
+	// backends that do not implement the MCP "tools/call". Params keep the MCP shape.
+	StdioToolMethod string `json:"stdio_tool_method,omitempty"`
+
+	// RewriteURLs replaces the server's address in textual proxied response bodies with
+	// the proxy's public base URL, so absolute links point at the proxy.
+	RewriteURLs bool `json:"rewrite_urls,omitempty"`
+
+	// ResolvedCommand and ResolvedWorkingDir hold Command and WorkingDir after relative
+	// paths were resolved against the config file's directory. They are empty when no
+	// resolution was needed.
+	ResolvedCommand    string `json:"-"`
+	ResolvedWorkingDir string `json:"-"`
+}
+
+// IsEnabled reports whether the server should be started. Servers are enabled unless
+// enabled is explicitly false.
+func (sc MCPServerConfig) IsEnabled() bool {
+	return sc.Enabled == nil || *sc.Enabled
+}
+
+// ProxyFunc returns the proxy selection function for this server's HTTP transport.
+// Settings left empty fall back to the environment. It returns nil when neither
+// http_proxy nor no_proxy is set, leaving the environment proxy in place.
+func (sc MCPServerConfig) ProxyFunc() func(*http.Request) (*url.URL, error) {
+	if sc.HTTPProxy == "" && sc.NoProxy == "" {
+		return nil
+	}
+	proxyConfig := httpproxy.FromEnvironment()
+	if sc.HTTPProxy != "" {
+		proxyConfig.HTTPProxy = sc.HTTPProxy
+		proxyConfig.HTTPSProxy = sc.HTTPProxy
+	}
+	if sc.NoProxy != "" {
+		proxyConfig.NoProxy = sc.NoProxy
+	}
+	proxyFunc := proxyConfig.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+}
+
+// DefaultStdioToolMethod is the method of stdio tool calls when stdio_tool_method is unset.
+const DefaultStdioToolMethod = "tools/call"
+
+// StdioToolMethodOrDefault returns the JSON-RPC method used for tool calls on a stdio server.
+func (sc MCPServerConfig) StdioToolMethodOrDefault() string {
+	if sc.StdioToolMethod == "" {
+		return DefaultStdioToolMethod
+	}
+	return sc.StdioToolMethod
+}
+
+// validateProxyURL checks that raw is an absolute http, https or socks5 proxy URL.
+func validateProxyURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("unsupported scheme '%s'", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("missing host")
+	}
+	return nil
+}
+
+// validIPOrCIDR reports whether s is an IP address or a CIDR range, as accepted by
+// trusted_proxies.
+func validIPOrCIDR(s string) bool {
+	if _, err := netip.ParseAddr(s); err == nil {
+		return true
+	}
+	_, err := netip.ParsePrefix(s)
+	return err == nil
+}
+
+// DefaultDiscoveryTimeout is used when discovery_timeout_seconds is not set.
+const DefaultDiscoveryTimeout = 30 * time.Second
+
+// DiscoveryTimeout returns the per-attempt discovery timeout.
+func (sc MCPServerConfig) DiscoveryTimeout() time.Duration {
+	if sc.DiscoveryTimeoutSeconds <= 0 {
+		return DefaultDiscoveryTimeout
+	}
+	return time.Duration(sc.DiscoveryTimeoutSeconds) * time.Second
+}
+
+// ListLimitFor returns the list_limit of server: its own, or else the global one. Zero
+// means no limit.
+func (c *Config) ListLimitFor(server MCPServerConfig) int {
+	if server.ListLimit > 0 {
+		return server.ListLimit
+	}
+	return c.ListLimit
+}
+
+// SLO returns the response time objective, or zero when slo_ms is not set.
+func (sc MCPServerConfig) SLO() time.Duration {
+	return time.Duration(sc.SLOMs) * time.Millisecond
+}
+
+// DefaultDependsOnTimeout is used when depends_on_timeout is not set.
+const DefaultDependsOnTimeout = 30 * time.Second
+
+// DependsOnTimeoutDuration parses DependsOnTimeout, defaulting to DefaultDependsOnTimeout.
+func (sc MCPServerConfig) DependsOnTimeoutDuration() (time.Duration, error) {
+	if sc.DependsOnTimeout == "" {
+		return DefaultDependsOnTimeout, nil
+	}
+	return time.ParseDuration(sc.DependsOnTimeout)
+}
+
+// RetryAccounting values control how retried tool calls count toward a circuit breaker.
+const (
+	// RetryAccountingOnce counts all attempts of one call as a single logical failure.
+	RetryAccountingOnce = "once"
+	// RetryAccountingEach counts every failed attempt, including retries, as a failure.
+	RetryAccountingEach = "each"
+)
+
+// RetryConfig controls retries of failed tool calls against a server.
+type RetryConfig struct {
+	MaxAttempts int    `json:"max_attempts"`      // Total attempts including the first; 1 disables retries
+	Backoff     string `json:"backoff,omitempty"` // Delay between attempts (e.g. "100ms"), defaults to no delay
+}
+
+// BackoffDuration parses Backoff, returning zero when unset.
+func (r RetryConfig) BackoffDuration() (time.Duration, error) {
+	if r.Backoff == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(r.Backoff)
+}
+
+// CircuitBreakerConfig controls the per-server circuit breaker for tool calls.
+type CircuitBreakerConfig struct {
+	FailureThreshold int    `json:"failure_threshold"`          // Consecutive failures that open the breaker
+	ResetTimeout     string `json:"reset_timeout,omitempty"`    // How long the breaker stays open, defaults to 30s
+	RetryAccounting  string `json:"retry_accounting,omitempty"` // "once" (default) or "each"
+}
+
+// ResetTimeoutDuration parses ResetTimeout, defaulting to 30 seconds.
+func (cb CircuitBreakerConfig) ResetTimeoutDuration() (time.Duration, error) {
+	if cb.ResetTimeout == "" {
+		return 30 * time.Second, nil
+	}
+	return time.ParseDuration(cb.ResetTimeout)
+}
+
+// CountsEachRetry reports whether every failed attempt counts toward the breaker.
+func (cb CircuitBreakerConfig) CountsEachRetry() bool {
+	return cb.RetryAccounting == RetryAccountingEach
+}
+
+// DefaultMirrorTimeout bounds a mirrored tool call when mirror_to.timeout is not set.
+const DefaultMirrorTimeout = 30 * time.Second
+
+// MirrorConfig copies tool calls to a shadow server. Mirrored calls never affect the
+// client response; their results are only compared with the primary's.
+type MirrorConfig struct {
+	Server        string   `json:"server"`                   // Name of the shadow server in mcp_servers
+	Tools         []string `json:"tools,omitempty"`          // Tools to mirror; empty mirrors every tool
+	SamplePercent float64  `json:"sample_percent,omitempty"` // Share of calls mirrored, 0 < x <= 100; defaults to 100
+	Timeout       string   `json:"timeout,omitempty"`        // Bound on each mirrored call, defaults to 30s
+}
+
+// TimeoutDuration parses Timeout, defaulting to DefaultMirrorTimeout.
+func (m MirrorConfig) TimeoutDuration() (time.Duration, error) {
+	if m.Timeout == "" {
+		return DefaultMirrorTimeout, nil
+	}
+	return time.ParseDuration(m.Timeout)
+}
+
+// Percent returns SamplePercent, defaulting to 100.
+func (m MirrorConfig) Percent() float64 {
+	if m.SamplePercent == 0 {
+		return 100
+	}
+	return m.SamplePercent
+}
+
+// ShadowServers returns the names of servers used as a mirror_to target. They only
+// receive mirrored calls and are not used for routing.
+func (c *Config) ShadowServers() map[string]bool {
+	shadows := make(map[string]bool)
+	for _, server := range c.MCPServers {
+		if server.MirrorTo != nil {
+			shadows[server.MirrorTo.Server] = true
+		}
+	}
+	return shadows
+}
+
+// CommandPath returns the command to execute, preferring the resolved path.
+func (sc MCPServerConfig) CommandPath() string {
+	if sc.ResolvedCommand != "" {
+		return sc.ResolvedCommand
+	}
+	return sc.Command
+}
+
+// WorkingDirPath returns the working directory for the command, preferring the resolved path.
+func (sc MCPServerConfig) WorkingDirPath() string {
+	if sc.ResolvedWorkingDir != "" {
+		return sc.ResolvedWorkingDir
+	}
+	return sc.WorkingDir
 }
 
 // Config represents the overall configuration for the MCP Proxy Server.
 type Config struct {
 	MCPServers []MCPServerConfig `json:"mcp_servers"`
+
+	// Path is the absolute path of the file the config was loaded from; empty when it
+	// was built from environment variables.
+	Path string `json:"-"`
+
+	// ServerTemplates are server definitions with `{{ .var }}` placeholders, keyed by
+	// template name. Each entry in Instances is expanded into MCPServers on load.
+	ServerTemplates map[string]MCPServerConfig `json:"server_templates,omitempty"`
+	Instances       []ServerInstance           `json:"instances,omitempty"`
+
+	// MaxBufferedBytes caps the total size of request bodies held in memory across
+	// all in-flight requests. Zero means no limit.
+	MaxBufferedBytes int64 `json:"max_buffered_bytes,omitempty"`
+
+	// MaxHeaderBytes caps the size of request headers in HTTP mode. Zero uses Go's
+	// default of 1 MB.
+	MaxHeaderBytes int `json:"max_header_bytes,omitempty"`
+	// MaxHeaderCount caps the number of request header fields in HTTP mode; requests
+	// with more are rejected with 431. Zero means no limit.
+	MaxHeaderCount int `json:"max_header_count,omitempty"`
+
+	// TrustedProxies lists the IPs and CIDR ranges of proxies whose X-Forwarded-For and
+	// X-Real-IP headers give the client IP in HTTP mode. Empty trusts none, so the
+	// client IP is the connection's remote address.
+	TrustedProxies []string `json:"trusted_proxies,omitempty"`
+
+	// MaxHops caps the X-MCP-Hop-Count of requests the proxy forwards, so a chain of
+	// proxies that routes back to itself fails with 508 instead of looping. Zero uses
+	// DefaultMaxHops.
+	MaxHops int `json:"max_hops,omitempty"`
+
+	// RedirectTrailingSlash redirects HTTP requests for a path with an extra or missing
+	// trailing slash (e.g. /tools/) to the matching route. Off by default, so such
+	// requests get a 404 instead of a redirect that clients may follow with a GET.
+	RedirectTrailingSlash bool `json:"redirect_trailing_slash,omitempty"`
+	// RedirectFixedPath redirects HTTP requests whose cleaned, case-insensitive path
+	// matches a route (e.g. /TOOLS or //tools). Off by default.
+	RedirectFixedPath bool `json:"redirect_fixed_path,omitempty"`
+
+	// ToolHedging enables hedged requests for read-only tools served by more than one
+	// server, keyed by tool name.
+	ToolHedging map[string]HedgingConfig `json:"tool_hedging,omitempty"`
+
+	// ToolRateLimits caps how often expensive tools are called, keyed by tool name.
+	// Calls over the limit are rejected whatever the overall traffic.
+	ToolRateLimits map[string]ToolRateLimitConfig `json:"tool_rate_limits,omitempty"`
+	// RateLimitStore selects where the token buckets of ToolRateLimits are kept, so
+	// replicas can share them. Nil keeps them in memory.
+	RateLimitStore *RateLimitStoreConfig `json:"rate_limit_store,omitempty"`
+
+	// AdminToken is the bearer token required by the HTTP admin endpoints.
+	// When empty, admin endpoints are disabled. It is shorthand for an Admin group
+	// with this single API key, and cannot be combined with Admin.
+	AdminToken string `json:"admin_token,omitempty"`
+
+	// Admin configures the /admin/* route group: its credentials, and optionally a
+	// listener of its own. The group is disabled unless enabled.
+	Admin *AdminConfig `json:"admin,omitempty"`
+
+	// Pprof serves net/http/pprof under /debug/pprof/ on the admin listener, guarded by
+	// the admin credentials. It is never served on the main proxy listener.
+	Pprof bool `json:"pprof,omitempty"`
+
+	// UI serves a web UI for browsing servers, tools and resources and calling tools
+	// at /ui/ in HTTP mode. Nil disables it.
+	UI *UIConfig `json:"ui,omitempty"`
+
+	// LogSchema sets the format of the proxy's logs: LogSchemaText (the default) or
+	// LogSchemaECS, one Elastic Common Schema JSON document per line.
+	LogSchema string `json:"log_schema,omitempty"`
+
+	// ToolPriority lists tool names to place first in tool listings, in the given order.
+	// It takes precedence over the per-server tool_priority lists.
+	ToolPriority []string `json:"tool_priority,omitempty"`
+	// SortTools lists the tools not named in a tool_priority list by name. Otherwise
+	// they keep server order, then the order each server lists them in.
+	SortTools bool `json:"sort_tools,omitempty"`
+	// ListLimit is the list_limit of servers that do not set one. Zero lists every
+	// tool and resource.
+	ListLimit int `json:"list_limit,omitempty"`
+
+	// ResourceConflicts decides which server owns a resource URI that more than one
+	// server exposes. Nil uses ResourceConflictFirstWins.
+	ResourceConflicts *ResourceConflictsConfig `json:"resource_conflicts,omitempty"`
+
+	// Journal enables the write-ahead journal of tool calls. Nil disables journaling.
+	Journal *JournalConfig `json:"journal,omitempty"`
+
+	// DeadLetterFile appends a JSON record of every failed tool call, including its
+	// arguments and the upstream response body, to this file. Empty disables it.
+	DeadLetterFile string `json:"dead_letter_file,omitempty"`
+	// DeadLetterMaxBytes is the size at which the dead-letter file is rotated to
+	// DeadLetterFile.1, replacing the previous one. Zero uses DefaultDeadLetterMaxBytes.
+	DeadLetterMaxBytes int64 `json:"dead_letter_max_bytes,omitempty"`
+
+	// PublicBaseURL is the URL clients reach the proxy at (e.g. "https://mcp.example.com"),
+	// used by rewrite_urls. When empty, HTTP mode uses the scheme and host of each request.
+	PublicBaseURL string `json:"public_base_url,omitempty"`
+
+	// AsyncTools lists tools whose HTTP calls always return 202 with a job to poll.
+	AsyncTools []string `json:"async_tools,omitempty"`
+	// ErrorBudget marks servers as degraded when their error rate is abnormal.
+	// Nil disables tracking.
+	ErrorBudget *ErrorBudgetConfig `json:"error_budget,omitempty"`
+
+	// StrictStartup fails startup when no server provides any tool or resource,
+	// instead of only logging a warning.
+	StrictStartup bool `json:"strict_startup,omitempty"`
+
+	// ToolJobTTL is how long a finished tool job is kept for polling (e.g. "10m").
+	// Defaults to DefaultToolJobTTL.
+	ToolJobTTL string `json:"tool_job_ttl,omitempty"`
+
+	// ResultMeta adds the proxy's own smartproxy/* keys to the _meta of tool results.
+	ResultMeta bool `json:"result_meta,omitempty"`
+
+	// MapToolErrorsToStatus answers HTTP tool calls whose result has isError set with
+	// 422 instead of 200, still sending the result. MCP reports tool errors in-band, so
+	// this is off by default.
+	MapToolErrorsToStatus bool `json:"map_tool_errors_to_status,omitempty"`
+
+	// JSONNumbers sets how numbers in client requests and tool arguments are decoded:
+	// JSONNumbersExact (the default) or JSONNumbersFloat.
+	JSONNumbers string `json:"json_numbers,omitempty"`
+
+	// LenientJSONRPC accepts client requests without a jsonrpc member as JSON-RPC 2.0.
+	// Any version other than "2.0" is still rejected.
+	LenientJSONRPC bool `json:"lenient_jsonrpc,omitempty"`
+
+	// MaxArgumentBytes and MaxArgumentDepth cap the encoded size and nesting depth of
+	// tool call arguments. Zero uses DefaultMaxArgumentBytes and DefaultMaxArgumentDepth;
+	// -1 disables the limit.
+	MaxArgumentBytes int64 `json:"max_argument_bytes,omitempty"`
+	MaxArgumentDepth int   `json:"max_argument_depth,omitempty"`
+
+	// MaxMessageBytes caps the size of a JSON-RPC message read in command mode. Zero
+	// uses DefaultMaxMessageBytes.
+	MaxMessageBytes int64 `json:"max_message_bytes,omitempty"`
+
+	// RestrictedStatus answers calls to restricted tools and requests for restricted
+	// resources alike: RestrictedStatusForbidden (the default) or
+	// RestrictedStatusNotFound, which hides that they exist.
+	RestrictedStatus string `json:"restricted_status,omitempty"`
+
+	// ValidateResults checks the structuredContent of tool results against the tool's
+	// outputSchema and fails calls whose result does not conform.
+	ValidateResults bool `json:"validate_results,omitempty"`
+
+	// MaxConcurrentRestarts caps how many crashed stdio servers are restarted at once.
+	// Defaults to DefaultMaxConcurrentRestarts.
+	MaxConcurrentRestarts int `json:"max_concurrent_restarts,omitempty"`
+
+	// MaxStdioProcesses caps how many stdio server processes run at once. Processes are
+	// started on demand and the least recently used idle one is stopped to make room.
+	// Zero means no limit.
+	MaxStdioProcesses int `json:"max_stdio_processes,omitempty"`
+	// StdioIdleTimeout stops a stdio process that has not served a request for this long
+	// (e.g. "5m") when MaxStdioProcesses is set or the server is lazy. Defaults to
+	// DefaultStdioIdleTimeout.
+	StdioIdleTimeout string `json:"stdio_idle_timeout,omitempty"`
+	// LazyCacheDir holds the last discovered tools and resources of lazy servers, one
+	// file per server, so they are not started to be discovered on every startup.
+	LazyCacheDir string `json:"lazy_cache_dir,omitempty"`
+
+	// Sessions configures the sessions of the streamable-HTTP MCP endpoint (/mcp).
+	// Nil keeps sessions in memory for DefaultSessionTTL.
+	Sessions *SessionsConfig `json:"sessions,omitempty"`
+	// SSE configures the Server-Sent Events streams of the /mcp endpoint. Nil uses the
+	// defaults.
+	SSE *SSEConfig `json:"sse,omitempty"`
+}
+
+// DefaultSessionTTL is how long an idle /mcp session is kept when sessions.ttl is unset.
+const DefaultSessionTTL = 30 * time.Minute
+
+// DefaultMaxSessions is the number of open /mcp sessions allowed when
+// sessions.max_sessions is unset.
+const DefaultMaxSessions = 10000
+
+// UIConfig configures the built-in web UI. Binaries built with the noui tag do not
+// include it.
+type UIConfig struct {
+	Enabled bool `json:"enabled"`
+}
+
+// SessionsConfig configures the sessions of the streamable-HTTP MCP endpoint.
+type SessionsConfig struct {
+	TTL  string `json:"ttl,omitempty"`  // Idle time after which a session expires, e.g. "30m"
+	Path string `json:"path,omitempty"` // File sessions are persisted to across restarts; empty keeps them in memory
+
+	MaxSessions int `json:"max_sessions,omitempty"` // Open sessions at most; 0 uses DefaultMaxSessions
+}
+
+// MaxSessionsOrDefault returns MaxSessions, or DefaultMaxSessions when unset.
+func (s SessionsConfig) MaxSessionsOrDefault() int {
+	if s.MaxSessions == 0 {
+		return DefaultMaxSessions
+	}
+	return s.MaxSessions
+}
+
+// TTLDuration parses TTL, defaulting to DefaultSessionTTL.
+func (s SessionsConfig) TTLDuration() (time.Duration, error) {
+	if s.TTL == "" {
+		return DefaultSessionTTL, nil
+	}
+	return time.ParseDuration(s.TTL)
+}
+
+// Error budget defaults applied when the corresponding field is zero.
+const (
+	DefaultErrorBudgetWindow      = 5 * time.Minute
+	DefaultErrorBudgetMinRequests = 10
+)
+
+// ErrorBudgetConfig configures per-server error rate tracking over a rolling window.
+// A server becomes degraded when its error rate reaches ErrorRateThreshold and recovers
+// once the rate drops to RecoveryRate or below.
+type ErrorBudgetConfig struct {
+	Window             string  `json:"window,omitempty"`        // Rolling window, e.g. "5m"
+	ErrorRateThreshold float64 `json:"error_rate_threshold"`    // Fraction of failed calls, 0 < x <= 1
+	RecoveryRate       float64 `json:"recovery_rate,omitempty"` // Defaults to half the threshold
+	MinRequests        int     `json:"min_requests,omitempty"`  // Calls required in the window before degrading
+	FailReadiness      bool    `json:"fail_readiness,omitempty"`
+	WebhookURL         string  `json:"webhook_url,omitempty"`
+}
+
+// WindowDuration parses Window, defaulting to DefaultErrorBudgetWindow.
+func (e ErrorBudgetConfig) WindowDuration() (time.Duration, error) {
+	if e.Window == "" {
+		return DefaultErrorBudgetWindow, nil
+	}
+	return time.ParseDuration(e.Window)
+}
+
+// RecoveryRateOrDefault returns RecoveryRate, or half the threshold when unset.
+func (e ErrorBudgetConfig) RecoveryRateOrDefault() float64 {
+	if e.RecoveryRate == 0 {
+		return e.ErrorRateThreshold / 2
+	}
+	return e.RecoveryRate
+}
+
+// MinRequestsOrDefault returns MinRequests, or DefaultErrorBudgetMinRequests when unset.
+func (e ErrorBudgetConfig) MinRequestsOrDefault() int {
+	if e.MinRequests == 0 {
+		return DefaultErrorBudgetMinRequests
+	}
+	return e.MinRequests
+}
+
+// SSE stream defaults applied when the corresponding field is zero.
+const (
+	DefaultSSEQueueSize         = 64
+	DefaultSSEHeartbeatInterval = 15 * time.Second
+)
+
+// Overflow policies for an SSE stream whose queue is full.
+const (
+	SSEOverflowDropOldest = "drop_oldest" // Drop the oldest queued event to make room
+	SSEOverflowDisconnect = "disconnect"  // End the stream; the client reconnects
+)
+
+// SSEConfig bounds the events queued for each Server-Sent Events stream, so a slow
+// client cannot make the proxy buffer without limit.
+type SSEConfig struct {
+	QueueSize         int    `json:"queue_size,omitempty"`         // Events queued per stream
+	Overflow          string `json:"overflow,omitempty"`           // SSEOverflowDropOldest (default) or SSEOverflowDisconnect
+	HeartbeatInterval string `json:"heartbeat_interval,omitempty"` // e.g. "15s"; a stream whose heartbeat cannot be written within it is closed
+}
+
+// QueueSizeOrDefault returns QueueSize, or DefaultSSEQueueSize when unset.
+func (s SSEConfig) QueueSizeOrDefault() int {
+	if s.QueueSize == 0 {
+		return DefaultSSEQueueSize
+	}
+	return s.QueueSize
+}
+
+// OverflowOrDefault returns Overflow, or SSEOverflowDropOldest when unset.
+func (s SSEConfig) OverflowOrDefault() string {
+	if s.Overflow == "" {
+		return SSEOverflowDropOldest
+	}
+	return s.Overflow
+}
+
+// HeartbeatIntervalDuration parses HeartbeatInterval, defaulting to
+// DefaultSSEHeartbeatInterval.
+func (s SSEConfig) HeartbeatIntervalDuration() (time.Duration, error) {
+	if s.HeartbeatInterval == "" {
+		return DefaultSSEHeartbeatInterval, nil
+	}
+	return time.ParseDuration(s.HeartbeatInterval)
+}
+
+// Policies for resource URIs exposed by more than one server.
+const (
+	ResourceConflictFirstWins     = "first_wins"     // The first server in config order owns the URI
+	ResourceConflictPreferServers = "prefer_servers" // The first server in PreferServers owns it, else the first in config order
+	ResourceConflictError         = "error"          // No server owns it; the resource is hidden and cannot be read
+)
+
+// ResourceConflictsConfig configures how resource URI collisions between servers are
+// resolved. Listing and reading resources by URI use the same resolution.
+type ResourceConflictsConfig struct {
+	Policy        string   `json:"policy,omitempty"`         // Defaults to ResourceConflictFirstWins
+	PreferServers []string `json:"prefer_servers,omitempty"` // Server names, most preferred first
+}
+
+// PolicyOrDefault returns Policy, or ResourceConflictFirstWins when unset.
+func (r ResourceConflictsConfig) PolicyOrDefault() string {
+	if r.Policy == "" {
+		return ResourceConflictFirstWins
+	}
+	return r.Policy
+}
+
+// DefaultToolJobTTL is used when tool_job_ttl is not set.
+const DefaultToolJobTTL = 10 * time.Minute
+
+// ToolJobTTLDuration parses ToolJobTTL, defaulting to DefaultToolJobTTL.
+func (c *Config) ToolJobTTLDuration() (time.Duration, error) {
+	if c.ToolJobTTL == "" {
+		return DefaultToolJobTTL, nil
+	}
+	return time.ParseDuration(c.ToolJobTTL)
+}
+
+// Journal defaults applied when the corresponding field is zero.
+const (
+	DefaultJournalMaxBytes = 10 << 20
+	DefaultJournalMaxFiles = 5
+)
+
+// DefaultDeadLetterMaxBytes is the dead-letter file size used when dead_letter_max_bytes is unset.
+const DefaultDeadLetterMaxBytes = 10 << 20
+
+// DeadLetterMaxBytesOrDefault returns DeadLetterMaxBytes, or DefaultDeadLetterMaxBytes when unset.
+func (c *Config) DeadLetterMaxBytesOrDefault() int64 {
+	if c.DeadLetterMaxBytes == 0 {
+		return DefaultDeadLetterMaxBytes
+	}
+	return c.DeadLetterMaxBytes
+}
+
+// JSON number modes for json_numbers.
+const (
+	JSONNumbersExact = "exact" // Keep numbers as written, so integers are forwarded unchanged
+	JSONNumbersFloat = "float" // Decode numbers as float64, re-encoding them from it
+)
+
+// JSONNumbersOrDefault returns JSONNumbers, or JSONNumbersExact when unset.
+func (c *Config) JSONNumbersOrDefault() string {
+	if c.JSONNumbers == "" {
+		return JSONNumbersExact
+	}
+	return c.JSONNumbers
+}
+
+// Tool argument limits used when max_argument_bytes and max_argument_depth are unset.
+const (
+	DefaultMaxArgumentBytes = 4 << 20
+	DefaultMaxArgumentDepth = 64
+)
+
+// MaxArgumentBytesOrDefault returns MaxArgumentBytes, DefaultMaxArgumentBytes when
+// unset, or 0 when disabled with -1.
+func (c *Config) MaxArgumentBytesOrDefault() int64 {
+	switch {
+	case c.MaxArgumentBytes == 0:
+		return DefaultMaxArgumentBytes
+	case c.MaxArgumentBytes < 0:
+		return 0
+	}
+	return c.MaxArgumentBytes
+}
+
+// MaxArgumentDepthOrDefault returns MaxArgumentDepth, DefaultMaxArgumentDepth when
+// unset, or 0 when disabled with -1.
+func (c *Config) MaxArgumentDepthOrDefault() int {
+	switch {
+	case c.MaxArgumentDepth == 0:
+		return DefaultMaxArgumentDepth
+	case c.MaxArgumentDepth < 0:
+		return 0
+	}
+	return c.MaxArgumentDepth
+}
+
+// DefaultMaxMessageBytes is the command mode message size limit used when
+// max_message_bytes is unset.
+const DefaultMaxMessageBytes = 16 << 20
+
+// MaxMessageBytesOrDefault returns MaxMessageBytes, or DefaultMaxMessageBytes when unset.
+func (c *Config) MaxMessageBytesOrDefault() int64 {
+	if c.MaxMessageBytes == 0 {
+		return DefaultMaxMessageBytes
+	}
+	return c.MaxMessageBytes
+}
+
+// DefaultMaxHops is the hop limit used when max_hops is unset.
+const DefaultMaxHops = 10
+
+// MaxHopsOrDefault returns MaxHops, or DefaultMaxHops when unset.
+func (c *Config) MaxHopsOrDefault() int {
+	if c.MaxHops == 0 {
+		return DefaultMaxHops
+	}
+	return c.MaxHops
+}
+
+// AdminConfig configures the /admin/* route group.
+type AdminConfig struct {
+	// Enabled serves the admin routes. They are disabled otherwise.
+	Enabled bool `json:"enabled"`
+	// APIKeys maps actor names, recorded in the audit log, to the bearer tokens they
+	// authenticate with.
+	APIKeys map[string]string `json:"api_keys,omitempty"`
+	// Listen serves the admin routes on a listener of their own (host:port) instead
+	// of the main one.
+	Listen string `json:"listen,omitempty"`
+	// TLSCertFile and TLSKeyFile serve Listen over TLS.
+	TLSCertFile string `json:"tls_cert_file,omitempty"`
+	TLSKeyFile  string `json:"tls_key_file,omitempty"`
+	// ClientCAFile requires clients of Listen to present a certificate signed by one of
+	// these CAs. A verified certificate authenticates as its common name, without an
+	// API key.
+	ClientCAFile string `json:"client_ca_file,omitempty"`
+}
+
+// validate checks the admin group's credentials and listener.
+func (a *AdminConfig) validate() error {
+	if !a.Enabled {
+		return nil
+	}
+	if len(a.APIKeys) == 0 && a.ClientCAFile == "" {
+		return errors.New("admin requires api_keys or client_ca_file")
+	}
+	for actor, key := range a.APIKeys {
+		if actor == "" || key == "" {
+			return fmt.Errorf("invalid admin.api_keys entry '%s': actor and key must not be empty", actor)
+		}
+	}
+	if (a.TLSCertFile == "") != (a.TLSKeyFile == "") {
+		return errors.New("admin.tls_cert_file and admin.tls_key_file must be set together")
+	}
+	if a.TLSCertFile != "" && a.Listen == "" {
+		return errors.New("admin.tls_cert_file requires admin.listen")
+	}
+	if a.ClientCAFile != "" && a.TLSCertFile == "" {
+		return errors.New("admin.client_ca_file requires admin.tls_cert_file and admin.tls_key_file")
+	}
+	return nil
+}
+
+// AdminEnabled reports whether the admin routes are served, by Admin or AdminToken.
+func (c *Config) AdminEnabled() bool {
+	if c.Admin != nil {
+		return c.Admin.Enabled
+	}
+	return c.AdminToken != ""
+}
+
+// JournalConfig configures the write-ahead journal of tool calls.
+type JournalConfig struct {
+	// Path is the active journal file. Rotated files are named Path.1, Path.2, ...
+	Path string `json:"path"`
+	// MaxBytes is the size at which the active file is rotated.
+	MaxBytes int64 `json:"max_bytes,omitempty"`
+	// MaxFiles is the number of journal files kept, including the active one.
+	MaxFiles int `json:"max_files,omitempty"`
+}
+
+// MaxBytesOrDefault returns MaxBytes, or DefaultJournalMaxBytes when unset.
+func (j JournalConfig) MaxBytesOrDefault() int64 {
+	if j.MaxBytes == 0 {
+		return DefaultJournalMaxBytes
+	}
+	return j.MaxBytes
+}
+
+// MaxFilesOrDefault returns MaxFiles, or DefaultJournalMaxFiles when unset.
+func (j JournalConfig) MaxFilesOrDefault() int {
+	if j.MaxFiles == 0 {
+		return DefaultJournalMaxFiles
+	}
+	return j.MaxFiles
+}
+
+// HedgingConfig describes how a hedged second request is sent for a tool call.
+type HedgingConfig struct {
+	// Delay is how long to wait for the primary server before sending the hedge (e.g. "200ms").
+	Delay string `json:"delay"`
+}
+
+// DelayDuration parses Delay into a time.Duration.
+func (h HedgingConfig) DelayDuration() (time.Duration, error) {
+	d, err := time.ParseDuration(h.Delay)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, errors.New("delay must be positive")
+	}
+	return d, nil
+}
+
+// ToolRateLimitConfig is the token bucket limiting calls to one tool.
+type ToolRateLimitConfig struct {
+	RPS       float64 `json:"rps"`                  // Sustained calls per second
+	Burst     int     `json:"burst,omitempty"`      // Calls allowed at once; defaults to RPS rounded up
+	PerClient bool    `json:"per_client,omitempty"` // One bucket per client instead of one for all
+}
+
+// BurstOrDefault returns Burst, or RPS rounded up (at least 1) when unset.
+func (r ToolRateLimitConfig) BurstOrDefault() int {
+	if r.Burst == 0 {
+		return max(1, int(math.Ceil(r.RPS)))
+	}
+	return r.Burst
 }
 
 // Validate validates the Config struct.
@@ -40,6 +927,155 @@ func (c *Config) Validate() error {
 		return errors.New("no MCP servers defined in configuration")
 	}
 
+	if c.MaxBufferedBytes < 0 {
+		return errors.New("max_buffered_bytes must not be negative")
+	}
+	if c.MaxHeaderBytes < 0 {
+		return errors.New("max_header_bytes must not be negative")
+	}
+	if c.MaxHeaderCount < 0 {
+		return errors.New("max_header_count must not be negative")
+	}
+	if c.MaxHops < 0 {
+		return errors.New("max_hops must not be negative")
+	}
+	if c.ListLimit < 0 {
+		return errors.New("list_limit must not be negative")
+	}
+	if c.MaxArgumentBytes < -1 {
+		return errors.New("max_argument_bytes must be -1 or more")
+	}
+	if c.MaxArgumentDepth < -1 {
+		return errors.New("max_argument_depth must be -1 or more")
+	}
+	if c.MaxMessageBytes < 0 {
+		return errors.New("max_message_bytes must not be negative")
+	}
+	for _, proxy := range c.TrustedProxies {
+		if !validIPOrCIDR(proxy) {
+			return fmt.Errorf("trusted_proxies: invalid IP address or CIDR range '%s'", proxy)
+		}
+	}
+
+	for tool, hedge := range c.ToolHedging {
+		if _, err := hedge.DelayDuration(); err != nil {
+			return fmt.Errorf("tool_hedging[%s]: invalid delay '%s': %w", tool, hedge.Delay, err)
+		}
+	}
+
+	for tool, limit := range c.ToolRateLimits {
+		if limit.RPS <= 0 {
+			return fmt.Errorf("tool_rate_limits[%s]: rps must be positive", tool)
+		}
+		if limit.Burst < 0 {
+			return fmt.Errorf("tool_rate_limits[%s]: burst must not be negative", tool)
+		}
+	}
+	if store := c.RateLimitStore; store != nil {
+		if err := store.validate(); err != nil {
+			return fmt.Errorf("rate_limit_store: %w", err)
+		}
+	}
+
+	if d, err := c.ToolJobTTLDuration(); err != nil || d <= 0 {
+		return fmt.Errorf("invalid tool_job_ttl '%s'", c.ToolJobTTL)
+	}
+	if c.MaxConcurrentRestarts < 0 {
+		return errors.New("max_concurrent_restarts must not be negative")
+	}
+	if c.MaxStdioProcesses < 0 {
+		return errors.New("max_stdio_processes must not be negative")
+	}
+	if d, err := c.StdioIdleTimeoutDuration(); err != nil || d < 0 {
+		return fmt.Errorf("invalid stdio_idle_timeout '%s'", c.StdioIdleTimeout)
+	}
+	if c.Admin != nil {
+		if c.AdminToken != "" {
+			return errors.New("admin_token cannot be combined with admin; add it to admin.api_keys")
+		}
+		if err := c.Admin.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Pprof && !c.AdminEnabled() {
+		return errors.New("pprof requires admin_token or an enabled admin group")
+	}
+
+	if eb := c.ErrorBudget; eb != nil {
+		if eb.ErrorRateThreshold <= 0 || eb.ErrorRateThreshold > 1 {
+			return errors.New("error_budget.error_rate_threshold must be greater than 0 and at most 1")
+		}
+		if eb.RecoveryRate < 0 || eb.RecoveryRate >= eb.ErrorRateThreshold {
+			return errors.New("error_budget.recovery_rate must be at least 0 and below error_rate_threshold")
+		}
+		if eb.MinRequests < 0 {
+			return errors.New("error_budget.min_requests must not be negative")
+		}
+		if d, err := eb.WindowDuration(); err != nil || d <= 0 {
+			return fmt.Errorf("invalid error_budget.window '%s'", eb.Window)
+		}
+	}
+
+	if j := c.Journal; j != nil {
+		if strings.TrimSpace(j.Path) == "" {
+			return errors.New("journal.path is required when journal is set")
+		}
+		if j.MaxBytes < 0 {
+			return errors.New("journal.max_bytes must not be negative")
+		}
+		if j.MaxFiles < 0 {
+			return errors.New("journal.max_files must not be negative")
+		}
+	}
+	if s := c.Sessions; s != nil {
+		if d, err := s.TTLDuration(); err != nil || d <= 0 {
+			return fmt.Errorf("invalid sessions.ttl '%s'", s.TTL)
+		}
+		if s.MaxSessions < 0 {
+			return errors.New("sessions.max_sessions must not be negative")
+		}
+	}
+	if s := c.SSE; s != nil {
+		if s.QueueSize < 0 {
+			return errors.New("sse.queue_size must not be negative")
+		}
+		if o := s.OverflowOrDefault(); o != SSEOverflowDropOldest && o != SSEOverflowDisconnect {
+			return fmt.Errorf("invalid sse.overflow '%s': must be '%s' or '%s'", s.Overflow, SSEOverflowDropOldest, SSEOverflowDisconnect)
+		}
+		if d, err := s.HeartbeatIntervalDuration(); err != nil || d <= 0 {
+			return fmt.Errorf("invalid sse.heartbeat_interval '%s'", s.HeartbeatInterval)
+		}
+	}
+	if r := c.RestrictedStatusOrDefault(); r != RestrictedStatusForbidden && r != RestrictedStatusNotFound {
+		return fmt.Errorf("invalid restricted_status '%s': must be '%s' or '%s'", c.RestrictedStatus, RestrictedStatusForbidden, RestrictedStatusNotFound)
+	}
+	if l := c.LogSchemaOrDefault(); l != LogSchemaText && l != LogSchemaECS {
+		return fmt.Errorf("invalid log_schema '%s': must be '%s' or '%s'", c.LogSchema, LogSchemaText, LogSchemaECS)
+	}
+	if n := c.JSONNumbersOrDefault(); n != JSONNumbersExact && n != JSONNumbersFloat {
+		return fmt.Errorf("invalid json_numbers '%s': must be '%s' or '%s'", c.JSONNumbers, JSONNumbersExact, JSONNumbersFloat)
+	}
+	if c.DeadLetterMaxBytes < 0 {
+		return errors.New("dead_letter_max_bytes must not be negative")
+	}
+	if c.PublicBaseURL != "" {
+		if u, err := url.Parse(c.PublicBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid public_base_url '%s': must be an absolute http or https URL", c.PublicBaseURL)
+		}
+	}
+
+	if rc := c.ResourceConflicts; rc != nil {
+		switch rc.PolicyOrDefault() {
+		case ResourceConflictFirstWins, ResourceConflictError:
+		case ResourceConflictPreferServers:
+			if len(rc.PreferServers) == 0 {
+				return errors.New("resource_conflicts.prefer_servers is required with policy 'prefer_servers'")
+			}
+		default:
+			return fmt.Errorf("invalid resource_conflicts.policy '%s': must be '%s', '%s' or '%s'", rc.Policy, ResourceConflictFirstWins, ResourceConflictPreferServers, ResourceConflictError)
+		}
+	}
+
 	names := make(map[string]struct{})
 	for i, server := range c.MCPServers {
 		if strings.TrimSpace(server.Name) == "" {
@@ -50,22 +1086,266 @@ func (c *Config) Validate() error {
 		}
 		names[server.Name] = struct{}{}
 
-		if strings.TrimSpace(server.Address) == "" && strings.TrimSpace(server.Command) == "" {
+		if strings.TrimSpace(server.Address) == "" && strings.TrimSpace(server.Command) == "" && len(server.StaticResources) == 0 && len(server.StaticTools) == 0 {
 			return fmt.Errorf("mcp_servers[%d]: either address or command is required", i)
 		}
+		if err := server.validateStaticResources(); err != nil {
+			return fmt.Errorf("mcp_servers[%d]: %w", i, err)
+		}
+		if err := server.validateStaticTools(); err != nil {
+			return fmt.Errorf("mcp_servers[%d]: %w", i, err)
+		}
+
+		if server.ResolvedCommand != "" {
+			if _, err := os.Stat(server.ResolvedCommand); err != nil {
+				return fmt.Errorf("mcp_servers[%d]: command '%s' resolved to '%s' does not exist", i, server.Command, server.ResolvedCommand)
+			}
+		}
+		if dir := server.WorkingDirPath(); dir != "" {
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				return fmt.Errorf("mcp_servers[%d]: working_dir '%s' is not a directory", i, dir)
+			}
+		}
+
+		if server.RewriteURLs && strings.TrimSpace(server.Address) == "" {
+			return fmt.Errorf("mcp_servers[%d]: rewrite_urls requires address", i)
+		}
+
+		if server.StdioToolMethod != "" && (strings.TrimSpace(server.Command) == "" || strings.TrimSpace(server.Address) != "") {
+			return fmt.Errorf("mcp_servers[%d]: stdio_tool_method requires a stdio server (command without address)", i)
+		}
+
+		if server.Lazy && strings.TrimSpace(server.Address) != "" {
+			return fmt.Errorf("mcp_servers[%d]: lazy requires a stdio server (command without address)", i)
+		}
+
+		if server.HTTPProxy != "" || server.NoProxy != "" {
+			if strings.TrimSpace(server.Address) == "" {
+				return fmt.Errorf("mcp_servers[%d]: http_proxy and no_proxy require address", i)
+			}
+			if server.HTTPProxy != "" {
+				if err := validateProxyURL(server.HTTPProxy); err != nil {
+					return fmt.Errorf("mcp_servers[%d]: invalid http_proxy '%s': %v", i, server.HTTPProxy, err)
+				}
+			}
+		}
+
+		if server.MaxRedirects < 0 {
+			return fmt.Errorf("mcp_servers[%d]: max_redirects must not be negative", i)
+		}
+
+		if err := server.validateBackendPaths(); err != nil {
+			return fmt.Errorf("mcp_servers[%d]: %w", i, err)
+		}
+		if err := server.validateMatchMode(); err != nil {
+			return fmt.Errorf("mcp_servers[%d]: %w", i, err)
+		}
+		if err := server.validateToolPolicy(); err != nil {
+			return fmt.Errorf("mcp_servers[%d]: %w", i, err)
+		}
+		if err := server.validateSigning(); err != nil {
+			return fmt.Errorf("mcp_servers[%d]: %w", i, err)
+		}
+		if err := server.validateType(); err != nil {
+			return fmt.Errorf("mcp_servers[%d]: %w", i, err)
+		}
+
+		for _, name := range server.ForwardHeaders {
+			if !httpguts.ValidHeaderFieldName(name) {
+				return fmt.Errorf("mcp_servers[%d]: forward_headers: invalid header name '%s'", i, name)
+			}
+		}
+		if server.Display != nil {
+			if err := server.Display.validate(); err != nil {
+				return fmt.Errorf("mcp_servers[%d]: %w", i, err)
+			}
+		}
+		if err := validateMimeTypePatterns(server.AllowedMimeTypes); err != nil {
+			return fmt.Errorf("mcp_servers[%d]: allowed_mime_types: %w", i, err)
+		}
+		if err := validateMimeTypePatterns(server.DeniedMimeTypes); err != nil {
+			return fmt.Errorf("mcp_servers[%d]: denied_mime_types: %w", i, err)
+		}
+		if f := server.MimeTypeFallback; f != "" && f != MimeTypeFallbackAllow && f != MimeTypeFallbackRestrict {
+			return fmt.Errorf("mcp_servers[%d]: mime_type_fallback must be '%s' or '%s'", i, MimeTypeFallbackAllow, MimeTypeFallbackRestrict)
+		}
+
+		switch server.Discovery {
+		case "", DiscoveryREST, DiscoveryJSONRPC, DiscoveryAuto:
+		default:
+			return fmt.Errorf("mcp_servers[%d]: discovery must be '%s', '%s' or '%s'", i, DiscoveryREST, DiscoveryJSONRPC, DiscoveryAuto)
+		}
+		if server.Discovery != "" && server.Address == "" {
+			return fmt.Errorf("mcp_servers[%d]: discovery requires an address", i)
+		}
+
+		if server.DiscoveryTimeoutSeconds < 0 {
+			return fmt.Errorf("mcp_servers[%d]: discovery_timeout_seconds must not be negative", i)
+		}
+		if server.DiscoveryRetries < 0 {
+			return fmt.Errorf("mcp_servers[%d]: discovery_retries must not be negative", i)
+		}
+		if server.DiscoveryMaxPages < 0 {
+			return fmt.Errorf("mcp_servers[%d]: discovery_max_pages must not be negative", i)
+		}
+		if server.DiscoveryMaxItems < 0 {
+			return fmt.Errorf("mcp_servers[%d]: discovery_max_items must not be negative", i)
+		}
+		if server.HealthPath != "" && server.Address == "" {
+			return fmt.Errorf("mcp_servers[%d]: health_path requires an address", i)
+		}
+		if server.HealthPath != "" && !strings.HasPrefix(server.HealthPath, "/") {
+			return fmt.Errorf("mcp_servers[%d]: invalid health_path '%s': must start with '/'", i, server.HealthPath)
+		}
+		if server.StdioTimeoutSeconds < 0 {
+			return fmt.Errorf("mcp_servers[%d]: stdio_timeout_seconds must not be negative", i)
+		}
+		if server.HealthIntervalSeconds < 0 {
+			return fmt.Errorf("mcp_servers[%d]: health_interval_seconds must not be negative", i)
+		}
+		if server.SLOMs < 0 {
+			return fmt.Errorf("mcp_servers[%d]: slo_ms must not be negative", i)
+		}
+		if server.ListLimit < 0 {
+			return fmt.Errorf("mcp_servers[%d]: list_limit must not be negative", i)
+		}
+
+		if server.Retry != nil {
+			if server.Retry.MaxAttempts < 1 {
+				return fmt.Errorf("mcp_servers[%d]: retry.max_attempts must be at least 1", i)
+			}
+			if d, err := server.Retry.BackoffDuration(); err != nil || d < 0 {
+				return fmt.Errorf("mcp_servers[%d]: invalid retry.backoff '%s'", i, server.Retry.Backoff)
+			}
+		}
+		if cb := server.CircuitBreaker; cb != nil {
+			if cb.FailureThreshold < 1 {
+				return fmt.Errorf("mcp_servers[%d]: circuit_breaker.failure_threshold must be at least 1", i)
+			}
+			if d, err := cb.ResetTimeoutDuration(); err != nil || d <= 0 {
+				return fmt.Errorf("mcp_servers[%d]: invalid circuit_breaker.reset_timeout '%s'", i, cb.ResetTimeout)
+			}
+			if cb.RetryAccounting != "" && cb.RetryAccounting != RetryAccountingOnce && cb.RetryAccounting != RetryAccountingEach {
+				return fmt.Errorf("mcp_servers[%d]: circuit_breaker.retry_accounting must be '%s' or '%s'", i, RetryAccountingOnce, RetryAccountingEach)
+			}
+		}
+
+		for key, value := range server.Env {
+			if _, err := formatEnvValue(value); err != nil {
+				return fmt.Errorf("mcp_servers[%d]: env '%s': %w", i, key, err)
+			}
+		}
+
+		if d, err := server.DependsOnTimeoutDuration(); err != nil || d <= 0 {
+			return fmt.Errorf("mcp_servers[%d]: invalid depends_on_timeout '%s'", i, server.DependsOnTimeout)
+		}
 
 		// AllowedTools and AllowedResources can be empty or nil, meaning no restrictions.
 	}
 
+	shadows := c.ShadowServers()
+	for i, server := range c.MCPServers {
+		for _, dep := range server.DependsOn {
+			if _, ok := names[dep]; !ok {
+				return fmt.Errorf("mcp_servers[%d]: depends_on references unknown server '%s'", i, dep)
+			}
+		}
+		if m := server.MirrorTo; m != nil {
+			if _, ok := names[m.Server]; !ok {
+				return fmt.Errorf("mcp_servers[%d]: mirror_to.server references unknown server '%s'", i, m.Server)
+			}
+			if m.Server == server.Name {
+				return fmt.Errorf("mcp_servers[%d]: mirror_to.server cannot be the server itself", i)
+			}
+			if shadows[server.Name] {
+				return fmt.Errorf("mcp_servers[%d]: a mirror_to target cannot mirror to another server", i)
+			}
+			if m.SamplePercent < 0 || m.SamplePercent > 100 {
+				return fmt.Errorf("mcp_servers[%d]: mirror_to.sample_percent must be between 0 and 100", i)
+			}
+			if d, err := m.TimeoutDuration(); err != nil || d <= 0 {
+				return fmt.Errorf("mcp_servers[%d]: invalid mirror_to.timeout '%s'", i, m.Timeout)
+			}
+		}
+	}
+	if rc := c.ResourceConflicts; rc != nil {
+		for _, name := range rc.PreferServers {
+			if _, ok := names[name]; !ok {
+				return fmt.Errorf("resource_conflicts.prefer_servers references unknown server '%s'", name)
+			}
+		}
+	}
+	if _, err := DependencyOrder(c.MCPServers); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// DependencyOrder returns server names ordered so that every server comes after the
+// servers it depends on. Independent servers keep their configuration order.
+// It returns an error describing the cycle if depends_on is circular.
+func DependencyOrder(servers []MCPServerConfig) ([]string, error) {
+	deps := make(map[string][]string, len(servers))
+	for _, server := range servers {
+		deps[server.Name] = server.DependsOn
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(servers))
+	order := make([]string, 0, len(servers))
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			start := slices.Index(path, name)
+			cycle := append(append([]string{}, path[start:]...), name)
+			return fmt.Errorf("depends_on cycle: %s", strings.Join(cycle, " -> "))
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range deps[name] {
+			if _, ok := deps[dep]; !ok {
+				continue
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		order = append(order, name)
+		return nil
+	}
+	for _, server := range servers {
+		if err := visit(server.Name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
 // MCPServer represents a running MCP server instance.
 type MCPServer struct {
 	Config MCPServerConfig
 
 	// For HTTP/SSE MCP servers
-	httpClient *http.Client
+	httpClient    *http.Client
+	discoveryMode string       // DiscoveryREST or DiscoveryJSONRPC once known; guarded by mu
+	rpcID         atomic.Int64 // Last JSON-RPC request ID
+
+	absentCapabilities map[string]bool // Discovery calls the server does not implement; guarded by mu
+
+	serverInfo     *ServerInfo            // From the last successful initialize; guarded by mu
+	capabilities   map[string]interface{} // From the last successful initialize; nil before one; guarded by mu
+	initializeSent bool                   // initialize was sent to the current process or HTTP server; guarded by mu
 
 	// For stdio-based MCP servers
 	cmd    *exec.Cmd
@@ -73,15 +1353,48 @@ type MCPServer struct {
 	stdout io.ReadCloser
 	stderr io.ReadCloser
 
+	stdioQueue atomic.Int64 // Requests waiting for the stdin/stdout pipe
+
 	// Optional override for HandleStdioRequest for testing/mocking
 	HandleStdioRequestFunc func(reqBytes []byte) ([]byte, error)
 
+	// Answers requests the stdio server sends while a request to it is in flight, such
+	// as sampling/createMessage. When nil they are answered with "Method not found".
+	ServerRequestHandler ServerRequestHandler
+
+	// Sets further headers of the JSON-RPC requests sent to an HTTP server, such as the
+	// identity of the client carried by ctx. Discovery requests get a background context.
+	RequestHeaders func(ctx context.Context, header http.Header)
+
 	// Process supervision
-	mu         sync.Mutex
-	restarting bool
-	ctx        context.Context
-	cancel     context.CancelFunc
-	wg         sync.WaitGroup
+	mu           sync.Mutex
+	restarts     int                // Number of successful process restarts
+	unhealthy    error              // Cause of the last failed health check or discovery; nil while healthy
+	eventHandler ServerEventHandler // Receives backend and discovery events; guarded by mu
+	ctx          context.Context    // Guarded by both mu and stateMu
+	cancel       context.CancelFunc // Guarded by both mu and stateMu
+	wg           sync.WaitGroup
+
+	// Lifecycle of the stdio process. stateMu is separate from mu so that a stop does not
+	// wait for an in-flight request, which holds mu.
+	stateMu sync.Mutex
+	state   ProcessState // "" is ProcessStopped; guarded by stateMu
+
+	restartLimiter *restartLimiter // Shared cap on concurrent restarts; nil means unlimited
+
+	// Shared cap on running stdio processes, or a pool of its own for a lazy server;
+	// nil means the process runs from startup to shutdown. The fields below are
+	// guarded by pool.mu.
+	pool         *stdioPool
+	pooled       bool        // Holds a slot: the process is running or starting
+	poolStarting bool        // The process is being started by acquire
+	poolStopping bool        // The process is being stopped after eviction
+	poolClosed   bool        // Shut down; no longer started on demand
+	inUse        int         // Requests holding the process
+	lastUsed     time.Time   // When the process was last acquired or released
+	idleTimer    *time.Timer // Stops the process once idle for the pool's idle timeout
+
+	toolCachePath string // File persisting the discovered tools of a lazy server; "" when not cached
 
 	// Cached list of tools and resources exposed by the MCP server
 	tools     []ToolInfo
@@ -90,6 +1403,15 @@ type MCPServer struct {
 	// Cached list of tools and resources restricted by the MCP server
 	restrictedTools     []ToolInfo
 	restrictedResources []ResourceInfo
+
+	schemaIssues  map[string]string             // Tools whose input schema was replaced, by name
+	outputSchemas map[string]*jsonschema.Schema // Compiled outputSchema of each tool, by name
+	lazySchemas   schemaLRU                     // Schemas fetched on demand with lazy_schemas
+
+	chainedServers []ChainedServerStatus // Servers of a chained smart-mcp-proxy at the last discovery
+
+	policy          *toolPolicy // Loaded from allowed_tools_from; nil without it
+	discoveredTools []ToolInfo  // Tools of the last discovery, re-partitioned when policy changes; guarded by mu
 }
 
 // ResourceInfo represents detailed information about a resource exposed by the MCP server.
@@ -99,20 +1421,43 @@ type ResourceInfo struct {
 	Name        string `json:"name"`
 	Description string `json:"description,omitempty"`
 	MimeType    string `json:"mimeType,omitempty"`
+
+	// RestrictedBy names the filter that hid a restricted resource, e.g.
+	// FilterAllowedResources or FilterDeniedMimeTypes.
+	RestrictedBy string `json:"-"`
+
+	// ChainedServer is the server providing the resource on a chained smart-mcp-proxy.
+	ChainedServer string `json:"-"`
 }
 
 // ToolInfo represents detailed information about a tool exposed by the MCP server.
 type ToolInfo struct {
-	Name        string                 `json:"name"`
-	Description string                 `json:"description,omitempty"`
-	InputSchema map[string]interface{} `json:"inputSchema"`
-	Annotations map[string]interface{} `json:"annotations,omitempty"`
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description,omitempty"`
+	InputSchema  map[string]interface{} `json:"inputSchema"`
+	OutputSchema map[string]interface{} `json:"outputSchema,omitempty"`
+	Annotations  map[string]interface{} `json:"annotations,omitempty"`
+
+	// SchemaIssue is set when the backend's inputSchema was missing, null or not an
+	// object (see SchemaMissing, SchemaNull and SchemaNotObject).
+	SchemaIssue string `json:"-"`
+
+	// RestrictedBy names the filter that hid a restricted tool, e.g.
+	// FilterAllowedTools or FilterStrictSchemas.
+	RestrictedBy string `json:"-"`
+
+	// LazySchema is set when lazy_schemas dropped the tool's input and output schemas.
+	LazySchema bool `json:"-"`
+
+	// ChainedServer is the server providing the tool on a chained smart-mcp-proxy.
+	ChainedServer string `json:"-"`
 }
 
 // CallToolRequestParams represents the parameters for a 'tools/call' JSON-RPC request.
 type CallToolRequestParams struct {
 	Name      string                 `json:"name"`
 	Arguments map[string]interface{} `json:"arguments"`
+	Meta      map[string]interface{} `json:"_meta,omitempty"` // Request metadata, e.g. progressToken
 }
 
 // ToolError represents an error returned by a tool execution.
@@ -157,6 +1502,19 @@ type CallToolResult struct {
 	Content   []ContentBlock `json:"content"`
 	IsError   bool           `json:"isError"`             // Overall error status for the tool call itself
 	ToolError *ToolError     `json:"toolError,omitempty"` // Error details if the call itself failed (distinct from tool_result block errors)
+
+	StructuredContent interface{} `json:"structuredContent,omitempty"` // Result conforming to the tool's outputSchema
+
+	Meta map[string]interface{} `json:"_meta,omitempty"` // Result metadata returned by the backend
+}
+
+// Transport returns the HTTP transport for requests to this server, honoring its
+// http_proxy and no_proxy settings.
+func (s *MCPServer) Transport() http.RoundTripper {
+	if s.httpClient != nil && s.httpClient.Transport != nil {
+		return s.httpClient.Transport
+	}
+	return http.DefaultTransport
 }
 
 // GetTools returns a copy of the current list of tools exposed by the MCP server.
@@ -195,27 +1553,122 @@ func (s *MCPServer) GetRestrictedResources() []ResourceInfo {
 	return resourcesCopy
 }
 
-// LoadConfig loads the configuration from a JSON file.
-// The path to the config file can be provided via the configPath argument.
-// If configPath is empty, it will look for the environment variable MCP_PROXY_CONFIG.
-func LoadConfig(configPath string) (*Config, error) {
-	if configPath == "" {
-		configPath = os.Getenv("MCP_PROXY_CONFIG")
-		if configPath == "" {
-			return nil, errors.New("configuration path not provided and MCP_PROXY_CONFIG environment variable is not set")
-		}
+// LoadConfig loads the configuration from a JSON file.
+// The path to the config file can be provided via the configPath argument.
+// If configPath is empty, it will look for the environment variable MCP_PROXY_CONFIG.
+// If neither is set, a single-server configuration is synthesized from the
+// MCP_SERVER_* environment variables (see LoadConfigFromEnv).
+func LoadConfig(configPath string) (*Config, error) {
+	if configPath == "" {
+		configPath = os.Getenv("MCP_PROXY_CONFIG")
+		if configPath == "" {
+			return LoadConfigFromEnv()
+		}
+	}
+
+	data, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", configPath, err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config JSON: %w", err)
+	}
+
+	if err := cfg.ExpandTemplates(); err != nil {
+		return nil, fmt.Errorf("failed to expand server templates: %w", err)
+	}
+
+	cfg.ResolvePaths(filepath.Dir(configPath))
+	if abs, err := filepath.Abs(configPath); err == nil {
+		cfg.Path = abs
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+// ResolvePaths resolves relative command and working directory paths against baseDir,
+// normally the directory containing the config file. Absolute paths and bare command
+// names (looked up on PATH, such as "npx") are left untouched.
+func (c *Config) ResolvePaths(baseDir string) {
+	for i := range c.MCPServers {
+		server := &c.MCPServers[i]
+		if isRelativePath(server.Command) {
+			server.ResolvedCommand = filepath.Join(baseDir, server.Command)
+		}
+		if server.WorkingDir != "" && !filepath.IsAbs(server.WorkingDir) {
+			server.ResolvedWorkingDir = filepath.Join(baseDir, server.WorkingDir)
+		}
+		server.resolveStaticFiles(baseDir)
+	}
+}
+
+// isRelativePath reports whether a command is a relative path rather than a bare name.
+func isRelativePath(command string) bool {
+	return command != "" && !filepath.IsAbs(command) && strings.ContainsRune(filepath.ToSlash(command), '/')
+}
+
+// formatEnvValue converts a JSON env value to its string form. Strings, numbers and
+// booleans are accepted; numbers are formatted without scientific notation so that
+// large integers such as 3000000000 are passed through verbatim.
+func formatEnvValue(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case string:
+		return val, nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(val), 'f', -1, 32), nil
+	case json.Number:
+		return val.String(), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", val), nil
+	case nil:
+		return "", errors.New("value must not be null")
+	default:
+		return "", fmt.Errorf("value must be a string, number, or boolean, got %T", v)
+	}
+}
+
+// LoadConfigFromEnv synthesizes a single-server configuration from environment variables:
+//
+//	MCP_SERVER_NAME               server name (defaults to "default")
+//	MCP_SERVER_ADDRESS            address of an HTTP MCP server
+//	MCP_SERVER_COMMAND            command for a stdio MCP server
+//	MCP_SERVER_ARGS               space-separated command arguments
+//	MCP_SERVER_ALLOWED_TOOLS      comma-separated tool allow-list
+//	MCP_SERVER_ALLOWED_RESOURCES  comma-separated resource allow-list
+//
+// One of MCP_SERVER_ADDRESS or MCP_SERVER_COMMAND must be set.
+func LoadConfigFromEnv() (*Config, error) {
+	address := strings.TrimSpace(os.Getenv("MCP_SERVER_ADDRESS"))
+	command := strings.TrimSpace(os.Getenv("MCP_SERVER_COMMAND"))
+	if address == "" && command == "" {
+		return nil, errors.New("configuration path not provided, MCP_PROXY_CONFIG environment variable is not set, and neither MCP_SERVER_ADDRESS nor MCP_SERVER_COMMAND is set")
 	}
 
-	data, err := ioutil.ReadFile(configPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file %s: %w", configPath, err)
+	name := strings.TrimSpace(os.Getenv("MCP_SERVER_NAME"))
+	if name == "" {
+		name = "default"
 	}
 
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config JSON: %w", err)
+	server := MCPServerConfig{
+		Name:             name,
+		Address:          address,
+		Command:          command,
+		Args:             strings.Fields(os.Getenv("MCP_SERVER_ARGS")),
+		AllowedTools:     splitEnvList(os.Getenv("MCP_SERVER_ALLOWED_TOOLS")),
+		AllowedResources: splitEnvList(os.Getenv("MCP_SERVER_ALLOWED_RESOURCES")),
 	}
 
+	cfg := Config{MCPServers: []MCPServerConfig{server}}
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("configuration validation failed: %w", err)
 	}
@@ -223,134 +1676,440 @@ func LoadConfig(configPath string) (*Config, error) {
 	return &cfg, nil
 }
 
+// splitEnvList splits a comma-separated environment value, dropping empty entries.
+func splitEnvList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // NewMCPServers creates MCPServer instances from config.
+// Servers are started in parallel; a server with depends_on waits until its dependencies
+// are ready (started and discovered) or its depends_on_timeout elapses. The returned slice
+// keeps configuration order.
 func NewMCPServers(cfg *Config) ([]*MCPServer, error) {
 	servers := make([]*MCPServer, 0, len(cfg.MCPServers))
+	startups := make(map[string]*serverStartup, len(cfg.MCPServers))
+	limiter := newRestartLimiter(cfg.MaxConcurrentRestarts)
+	idleTimeout, err := cfg.StdioIdleTimeoutDuration()
+	if err != nil {
+		return nil, fmt.Errorf("invalid stdio_idle_timeout: %w", err)
+	}
+	pool := newStdioPool(cfg.MaxStdioProcesses, idleTimeout)
 	for _, sc := range cfg.MCPServers {
-		server := &MCPServer{
-			Config: sc,
+		if !sc.IsEnabled() {
+			log.Printf("MCP server %s is disabled, skipping", sc.Name)
+			continue
 		}
-
-		if sc.Address != "" {
-			// Initialize HTTP client for HTTP/SSE MCP server
-			server.httpClient = &http.Client{
-				Timeout: 30 * time.Second,
-			}
-			// Fetch initial tools and resources for HTTP/SSE server
-			if err := server.refreshToolsAndResources(); err != nil {
-				fmt.Printf("failed to fetch tools/resources for server %s: %v\n", sc.Name, err)
-			}
-			// Start periodic refresh
-			//go server.startPeriodicRefresh()
-		} else if sc.Command != "" {
-			// Initialize stdio-based MCP server
-			if err := server.startStdioProcess(); err != nil {
-				return nil, err
+		server := &MCPServer{Config: sc, restartLimiter: limiter}
+		if sc.Command != "" {
+			server.pool = pool
+		}
+		if sc.Lazy {
+			if server.pool == nil {
+				server.pool = newStdioPool(1, idleTimeout)
 			}
-			// Fetch initial tools and resources for stdio server
-			if err := server.refreshToolsAndResources(); err != nil {
-				fmt.Printf("failed to fetch tools/resources for server %s: %v", sc.Name, err)
+			if cfg.LazyCacheDir != "" {
+				server.toolCachePath = toolCachePath(cfg.LazyCacheDir, sc.Name)
 			}
-			// Start periodic refresh
-			//go server.startPeriodicRefresh()
-		} else {
-			return nil, errors.New("mcp server config must have either address or command")
 		}
-
 		servers = append(servers, server)
+		startups[sc.Name] = &serverStartup{done: make(chan struct{})}
+	}
+
+	errs := make([]error, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			startup := startups[server.Config.Name]
+			defer close(startup.done)
+			server.waitForDependencies(startups)
+			startup.ready, errs[i] = server.start()
+		}()
+	}
+	wg.Wait()
+
+	for _, startErr := range errs {
+		if startErr != nil {
+			err = startErr
+			break
+		}
+	}
+	if err == nil {
+		err = checkUsableServers(servers, len(cfg.MCPServers), cfg.StrictStartup)
+	}
+	if err != nil {
+		// Stop the servers that did start so their processes are not orphaned
+		for _, server := range servers {
+			if shutdownErr := server.Shutdown(); shutdownErr != nil {
+				log.Printf("Error shutting down MCP server %s: %v", server.Config.Name, shutdownErr)
+			}
+		}
+		return nil, err
+	}
+	if pool != nil {
+		pool.stopBeyondLimit(servers)
 	}
 	return servers, nil
 }
 
-// startStdioProcess launches the stdio-based MCP server process and sets up pipes and supervision.
-func (s *MCPServer) startStdioProcess() error {
-	s.mu.Lock()
+// ErrNoUsableServers is returned under strict_startup when no server provides any tool or resource.
+var ErrNoUsableServers = errors.New("no enabled MCP server provides any tool or resource")
+
+// checkUsableServers warns, or fails when strict is set, if the started servers expose
+// nothing, e.g. because every server is disabled or every tool is filtered out.
+func checkUsableServers(servers []*MCPServer, configured int, strict bool) error {
+	for _, server := range servers {
+		if len(server.GetTools()) > 0 || len(server.GetResources()) > 0 {
+			return nil
+		}
+	}
+	if strict {
+		return fmt.Errorf("%w (%d of %d configured servers enabled)", ErrNoUsableServers, len(servers), configured)
+	}
+	log.Printf("WARNING: %v (%d of %d configured servers enabled); the proxy will serve empty tool and resource listings", ErrNoUsableServers, len(servers), configured)
+	return nil
+}
 
-	if s.restarting {
+// serverStartup tracks one server's progress through NewMCPServers.
+type serverStartup struct {
+	done  chan struct{} // Closed once start returns
+	ready bool          // Valid after done is closed
+}
+
+// waitForDependencies blocks until every depends_on server has finished starting, or
+// depends_on_timeout elapses. Dependencies that fail or time out are logged and skipped.
+func (s *MCPServer) waitForDependencies(startups map[string]*serverStartup) {
+	if len(s.Config.DependsOn) == 0 {
+		return
+	}
+	timeout, err := s.Config.DependsOnTimeoutDuration()
+	if err != nil {
+		timeout = DefaultDependsOnTimeout
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for _, dep := range s.Config.DependsOn {
+		startup, ok := startups[dep]
+		if !ok {
+			continue
+		}
+		select {
+		case <-startup.done:
+			if !startup.ready {
+				log.Printf("Warning: dependency %s of MCP server %s did not become ready, starting anyway", dep, s.Config.Name)
+			}
+		case <-deadline.C:
+			log.Printf("Warning: timed out after %s waiting for dependencies of MCP server %s, starting anyway", timeout, s.Config.Name)
+			return
+		}
+	}
+}
+
+// start launches the server (the process for stdio servers) and runs initial discovery.
+// It reports whether the server is ready, i.e. discovery succeeded.
+func (s *MCPServer) start() (bool, error) {
+	sc := s.Config
+	s.startToolPolicy()
+	if sc.Address != "" {
+		// HTTP servers have no process; their context only ends in-flight discovery at shutdown
+		s.mu.Lock()
+		s.stateMu.Lock()
+		s.ctx, s.cancel = context.WithCancel(context.Background())
+		s.stateMu.Unlock()
 		s.mu.Unlock()
+		// Initialize HTTP client for HTTP/SSE MCP server
+		s.httpClient = &http.Client{
+			Timeout:       30 * time.Second,
+			CheckRedirect: sc.CheckRedirect,
+		}
+		if proxy := sc.ProxyFunc(); proxy != nil {
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.Proxy = proxy
+			s.httpClient.Transport = transport
+		}
+		if sc.HealthPath != "" {
+			s.wg.Add(1)
+			go s.runHealthChecks(s.ctx)
+		}
+		// Fetch initial tools and resources for HTTP/SSE server
+		if err := s.refreshToolsAndResources(); err != nil {
+			log.Printf("failed to fetch tools/resources for server %s: %v", sc.Name, err)
+			return false, nil
+		}
+		// Start periodic refresh
+		//go server.startPeriodicRefresh()
+	} else if sc.Command != "" && s.pool != nil {
+		if sc.Lazy {
+			if s.loadToolCache() {
+				return true, nil
+			}
+			// Discover once, then stop the process until a request needs it
+			defer s.pool.stopUnused(s, "until first use (lazy)")
+		}
+		// The pool starts the process, which is held until discovery completes
+		if err := s.pool.acquire(s); err != nil {
+			return false, err
+		}
+		defer s.pool.release(s)
+		if err := s.refreshToolsAndResources(); err != nil {
+			log.Printf("failed to fetch tools/resources for server %s: %v", sc.Name, err)
+			return false, nil
+		}
+	} else if sc.Command != "" {
+		// Initialize stdio-based MCP server
+		if err := s.startStdioProcess(); err != nil {
+			return false, err
+		}
+		// Fetch initial tools and resources for stdio server
+		if err := s.refreshToolsAndResources(); err != nil {
+			log.Printf("failed to fetch tools/resources for server %s: %v", sc.Name, err)
+			return false, nil
+		}
+		// Start periodic refresh
+		//go server.startPeriodicRefresh()
+	} else if sc.IsStatic() {
+		// Nothing to start; discovery lists the static tools and resources
+		if err := s.refreshToolsAndResources(); err != nil {
+			return false, err
+		}
+	} else {
+		return false, errors.New("mcp server config must have either address or command")
+	}
+	return true, nil
+}
+
+// startStdioProcess launches the stdio-based MCP server process and sets up pipes and
+// supervision. A start requested while the process is starting, running or being
+// restarted is coalesced into that one; one requested while it is stopping fails.
+func (s *MCPServer) startStdioProcess() error {
+	s.stateMu.Lock()
+	switch s.processStateLocked() {
+	case ProcessStarting, ProcessReady, ProcessRestarting:
+		s.stateMu.Unlock()
 		return nil
+	case ProcessStopping:
+		s.stateMu.Unlock()
+		return fmt.Errorf("%w: %s", errProcessStopping, s.Config.Name)
 	}
+	s.state = ProcessStarting
+	s.stateMu.Unlock()
+	return s.launch(ProcessStarting)
+}
 
+// launch launches the process from state from (ProcessStarting or ProcessRestarting),
+// which becomes ProcessReady once it runs, or ProcessStopped if it fails to start. It
+// fails without launching when the server was stopped meanwhile.
+func (s *MCPServer) launch(from ProcessState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stateMu.Lock()
+	if s.state != from {
+		s.stateMu.Unlock()
+		return fmt.Errorf("%w: %s", errProcessStopping, s.Config.Name)
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	s.ctx = ctx
 	s.cancel = cancel
+	// Counted while the state is checked, so a stop waiting for the process also waits
+	// for this one
+	s.wg.Add(1)
+	s.stateMu.Unlock()
+
+	fail := func(err error) error {
+		cancel()
+		s.transition(from, ProcessStopped)
+		s.wg.Done()
+		return err
+	}
 
-	cmd := exec.CommandContext(ctx, s.Config.Command, s.Config.Args...)
+	cmd := exec.CommandContext(ctx, s.Config.CommandPath(), s.Config.Args...)
+	cmd.Dir = s.Config.WorkingDirPath()
 	envVars := make([]string, 0, len(s.Config.Env))
 	for k, v := range s.Config.Env {
-		envVars = append(envVars, fmt.Sprintf("%s=%v", k, v))
+		value, err := formatEnvValue(v)
+		if err != nil {
+			return fail(fmt.Errorf("invalid env value for '%s': %w", k, err))
+		}
+		envVars = append(envVars, k+"="+value)
 	}
 	cmd.Env = append(os.Environ(), append(cmd.Env, envVars...)...)
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
-		s.mu.Unlock()
-		return err
+		return fail(err)
 	}
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		s.mu.Unlock()
-		return err
+		return fail(err)
 	}
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		s.mu.Unlock()
-		return err
+		return fail(err)
 	}
 
 	s.cmd = cmd
 	s.stdin = stdin
 	s.stdout = stdout
 	s.stderr = stderr
-
-	s.mu.Unlock()
+	s.initializeSent = false
 
 	if err := cmd.Start(); err != nil {
-		return err
+		return fail(err)
 	}
-
-	s.wg.Add(1)
-	go s.monitorProcess()
+	s.transition(from, ProcessReady)
+	go s.monitorProcess(cmd, stderr)
 
 	return nil
 }
 
-// refreshToolsAndResources fetches the list of tools and resources from the MCP server.
-func (s *MCPServer) refreshToolsAndResources() error {
-	var toolInfos []ToolInfo
-	var resourceInfos []ResourceInfo
+// discoveryRetryBackoff is the pause between discovery attempts.
+var discoveryRetryBackoff = 500 * time.Millisecond
+
+// discoverToolsAndResources fetches tools and resources, retrying failed attempts up to
+// discovery_retries times. Each attempt is bounded by the discovery timeout, and all of
+// them end as soon as the server is shut down.
+func (s *MCPServer) discoverToolsAndResources() ([]ToolInfo, []ResourceInfo, error) {
+	ctx := s.discoveryContext()
+	timeout := s.Config.DiscoveryTimeout()
 	var err error
+	for attempt := 0; attempt <= s.Config.DiscoveryRetries; attempt++ {
+		if attempt > 0 {
+			log.Printf("Retrying discovery for MCP server %s (attempt %d/%d) after error: %v", s.Config.Name, attempt+1, s.Config.DiscoveryRetries+1, err)
+			select {
+			case <-ctx.Done():
+				return nil, nil, fmt.Errorf("discovery for server %s cancelled: %w", s.Config.Name, ctx.Err())
+			case <-time.After(discoveryRetryBackoff):
+			}
+		}
+		var toolInfos []ToolInfo
+		var resourceInfos []ResourceInfo
+		toolInfos, resourceInfos, err = s.discoverOnce(ctx, timeout)
+		if err == nil {
+			return toolInfos, resourceInfos, nil
+		}
+		if ctx.Err() != nil {
+			return nil, nil, err
+		}
+	}
+	return nil, nil, err
+}
+
+// discoveryContext returns the context discovery runs under: the server's context,
+// cancelled by Shutdown, or a background context before the server was started.
+func (s *MCPServer) discoveryContext() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
 
+// discoverOnce runs a single discovery attempt bounded by timeout and ctx.
+func (s *MCPServer) discoverOnce(ctx context.Context, timeout time.Duration) ([]ToolInfo, []ResourceInfo, error) {
 	if s.Config.Command != "" {
-		// stdio-based MCP server: send request to get tools and resources
-		toolInfos, resourceInfos, err = s.fetchToolsAndResourcesStdio()
-		if err != nil {
-			return err
+		// stdio-based MCP server: the pipe cannot be interrupted, so an attempt that
+		// exceeds the timeout is abandoned and its result discarded.
+		type fetchResult struct {
+			tools     []ToolInfo
+			resources []ResourceInfo
+			err       error
+		}
+		done := make(chan fetchResult, 1)
+		go func() {
+			tools, resources, err := s.fetchToolsAndResourcesStdio(ctx)
+			done <- fetchResult{tools, resources, err}
+		}()
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case res := <-done:
+			return res.tools, res.resources, res.err
+		case <-timer.C:
+			return nil, nil, fmt.Errorf("discovery for server %s timed out after %s", s.Config.Name, timeout)
+		case <-ctx.Done():
+			return nil, nil, fmt.Errorf("discovery for server %s cancelled: %w", s.Config.Name, ctx.Err())
 		}
 	} else if s.Config.Address != "" {
 		// HTTP/SSE MCP server: send HTTP requests to get tools and resources
-		toolInfos, resourceInfos, err = s.fetchToolsAndResourcesHTTP()
-	} else {
-		return errors.New("mcp server config must have either address or command")
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return s.fetchToolsAndResourcesHTTPMode(ctx)
+	} else if s.Config.IsStatic() {
+		return nil, nil, nil
 	}
+	return nil, nil, errors.New("mcp server config must have either address or command")
+}
 
+// refreshToolsAndResources fetches the list of tools and resources from the MCP server.
+func (s *MCPServer) refreshToolsAndResources() error {
+	toolInfos, resourceInfos, err := s.discoverToolsAndResources()
+	if err != nil {
+		s.emit(EventRefreshFailed, err)
+	}
+	if s.Config.HealthPath == "" {
+		// Without a health_path, discovery is the health signal
+		s.setHealth(err)
+	}
 	if err != nil {
 		return err
 	}
+	toolInfos, resourceInfos = s.capDiscovered(toolInfos, resourceInfos)
+	if s.toolCachePath != "" {
+		s.saveToolCache(toolInfos, resourceInfos)
+	}
+	s.applyDiscovered(toolInfos, resourceInfos)
+	return nil
+}
 
-	var allowedTools []ToolInfo
-	var restrictedTools []ToolInfo
+// applyDiscovered filters discovered tools and resources through the server's allow-lists
+// and schema checks and stores the result.
+func (s *MCPServer) applyDiscovered(toolInfos []ToolInfo, resourceInfos []ResourceInfo) {
+	var discoveredTools []ToolInfo
+	var schemaIssues map[string]string
+	toolInfos = append(slices.Clip(toolInfos), s.Config.staticToolInfos()...)
+	var outputSchemas map[string]*jsonschema.Schema
+	if !s.Config.LazySchemas {
+		outputSchemas = s.compileOutputSchemas(toolInfos)
+	}
 	for _, tool := range toolInfos {
-		if len(s.Config.AllowedTools) == 0 || slices.Contains(s.Config.AllowedTools, tool.Name) {
-			allowedTools = append(allowedTools, tool)
-		} else {
-			restrictedTools = append(restrictedTools, tool)
+		normalizeToolSchema(&tool)
+		if tool.SchemaIssue != "" {
+			if schemaIssues == nil {
+				schemaIssues = make(map[string]string)
+			}
+			schemaIssues[tool.Name] = tool.SchemaIssue
+			s.logSchemaIssue(tool)
 		}
+		if s.Config.LazySchemas {
+			dropSchemas(&tool)
+		}
+		if tool.RestrictedBy != FilterChainedProxy && tool.SchemaIssue != "" && s.Config.StrictSchemas {
+			tool.RestrictedBy = FilterStrictSchemas
+		}
+		discoveredTools = append(discoveredTools, tool)
 	}
+	allowedTools, restrictedTools := s.partitionTools(discoveredTools)
 
 	var allowedResources []ResourceInfo
 	var restrictedResources []ResourceInfo
+	resourceInfos = append(slices.Clip(resourceInfos), s.Config.staticResourceInfos()...)
 	for _, resource := range resourceInfos {
-		if len(s.Config.AllowedResources) == 0 || slices.Contains(s.Config.AllowedResources, resource.Name) {
+		if resource.RestrictedBy == FilterChainedProxy {
+			// Restricted by the chained proxy already
+		} else if !s.Config.allowListed(s.Config.AllowedResources, resource.Name) {
+			resource.RestrictedBy = FilterAllowedResources
+		} else {
+			resource.RestrictedBy = s.Config.restrictedByMimeType(resource.MimeType)
+		}
+		if resource.RestrictedBy == "" {
 			allowedResources = append(allowedResources, resource)
 		} else {
 			restrictedResources = append(restrictedResources, resource)
@@ -358,11 +2117,47 @@ func (s *MCPServer) refreshToolsAndResources() error {
 	}
 
 	// Assign allowed ToolInfo and ResourceInfo slices to MCPServer fields
+	s.mu.Lock()
+	changed := toolsetChanged(s.tools, allowedTools)
+	changedResources := resourcesChanged(s.resources, allowedResources)
 	s.tools = allowedTools
 	s.restrictedTools = restrictedTools
 	s.resources = allowedResources
 	s.restrictedResources = restrictedResources
-	return nil
+	s.schemaIssues = schemaIssues
+	s.outputSchemas = outputSchemas
+	if s.policy != nil {
+		s.discoveredTools = discoveredTools
+	}
+	s.mu.Unlock()
+	s.lazySchemas.clear()
+	if changed {
+		s.emit(EventToolsetChanged, nil)
+	}
+	if changedResources {
+		s.emit(EventResourcesChanged, nil)
+	}
+}
+
+// partitionTools splits discovered tools into those allowed by allowed_tools, or the
+// list from allowed_tools_from, and those restricted by it or already restricted.
+func (s *MCPServer) partitionTools(tools []ToolInfo) (allowed, restricted []ToolInfo) {
+	for _, tool := range tools {
+		if tool.RestrictedBy == FilterChainedProxy || tool.RestrictedBy == FilterStrictSchemas {
+			restricted = append(restricted, tool)
+		} else if s.IsToolAllowed(tool.Name) {
+			allowed = append(allowed, tool)
+		} else {
+			tool.RestrictedBy = FilterAllowedTools
+			restricted = append(restricted, tool)
+		}
+	}
+	return allowed, restricted
+}
+
+// Refresh re-fetches the tools and resources exposed by the MCP server and updates the cache.
+func (s *MCPServer) Refresh() error {
+	return s.refreshToolsAndResources()
 }
 
 // startPeriodicRefresh starts a goroutine that refreshes tools and resources every 15 minutes.
@@ -416,74 +2211,157 @@ func (s *MCPServer) startPeriodicRefresh() {
 // This function supports backward compatibility with legacy responses where tools and resources
 // are arrays of strings. In such cases, a warning is logged and the strings are converted to
 // ToolInfo and ResourceInfo with only the Name field populated.
-func (s *MCPServer) fetchToolsAndResourcesHTTP() ([]ToolInfo, []ResourceInfo, error) {
-	toolsURL := fmt.Sprintf("%s/tools", s.Config.Address)
-	resourcesURL := fmt.Sprintf("%s/resources", s.Config.Address)
-
-	toolsResp, err := s.httpClient.Get(toolsURL)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get tools: %w", err)
+//
+// The requests are bounded by ctx rather than the client's tool call timeout.
+func (s *MCPServer) fetchToolsAndResourcesHTTP(ctx context.Context) ([]ToolInfo, []ResourceInfo, error) {
+	toolsURL := s.Config.Address + s.Config.ToolsPathOrDefault()
+	resourcesURL := s.Config.Address + s.Config.ResourcesPathOrDefault()
+
+	client := *s.httpClient
+	client.Timeout = 0
+	get := func(url string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		return client.Do(req)
 	}
-	defer toolsResp.Body.Close()
 
-	if toolsResp.StatusCode != http.StatusOK {
-		return nil, nil, fmt.Errorf("tools endpoint returned status %d", toolsResp.StatusCode)
+	// list GETs one discovery endpoint and decodes it into into. A 404 or 405 means
+	// the capability is absent.
+	list := func(url, capability string, into interface{}) (absent bool, err error) {
+		if s.capabilityAbsent(capability) {
+			return true, nil
+		}
+		resp, err := get(url)
+		if err != nil {
+			return false, fmt.Errorf("failed to get %s: %w", capability, err)
+		}
+		defer resp.Body.Close()
+
+		if absentStatus(resp.StatusCode) {
+			return true, nil
+		}
+		if resp.StatusCode != http.StatusOK {
+			return false, fmt.Errorf("%s endpoint returned status %d", capability, resp.StatusCode)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(into); err != nil {
+			return false, fmt.Errorf("failed to decode %s response: %w", capability, err)
+		}
+		return false, nil
 	}
 
-	// Decode full ToolInfo array response
-	var toolsDataFull struct {
-		Tools []ToolInfo `json:"tools"`
+	// Decode the tools array, of ToolInfo objects or legacy names
+	var toolsData struct {
+		Tools json.RawMessage `json:"tools"`
 	}
-	err = json.NewDecoder(toolsResp.Body).Decode(&toolsDataFull)
+	toolsAbsent, err := list(toolsURL, CapabilityTools, &toolsData)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to decode tools response: %w", err)
+		return nil, nil, err
 	}
-
-	resourcesResp, err := s.httpClient.Get(resourcesURL)
+	tools, err := decodeListOrNames(s, CapabilityTools, toolsData.Tools, func(name string) discoveredTool {
+		return discoveredTool{Name: name}
+	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get resources: %w", err)
+		return nil, nil, err
 	}
-	defer resourcesResp.Body.Close()
 
-	if resourcesResp.StatusCode != http.StatusOK {
-		return nil, nil, fmt.Errorf("resources endpoint returned status %d", resourcesResp.StatusCode)
+	// Decode the resources array, of ResourceInfo objects or legacy names
+	var resourcesData struct {
+		Resources json.RawMessage `json:"resources"`
 	}
-
-	// Decode full ResourceInfo array response
-	var resourcesDataFull struct {
-		Resources []ResourceInfo `json:"resources"`
+	resourcesAbsent, err := list(resourcesURL, CapabilityResources, &resourcesData)
+	if err != nil {
+		return nil, nil, err
 	}
-	err = json.NewDecoder(resourcesResp.Body).Decode(&resourcesDataFull)
+	resources, err := decodeListOrNames(s, CapabilityResources, resourcesData.Resources, func(name string) ResourceInfo {
+		return ResourceInfo{Name: name}
+	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to decode resources response: %w", err)
+		return nil, nil, err
 	}
 
-	return toolsDataFull.Tools, resourcesDataFull.Resources, nil
+	if err := s.recordAbsentCapabilities(toolsAbsent, resourcesAbsent); err != nil {
+		return nil, nil, err
+	}
+	return toToolInfos(tools), resources, nil
+}
+
+// decodeListOrNames decodes a discovered list of objects or, failing that, a legacy
+// array of names, each converted with fromName. Legacy lists are logged as a warning.
+func decodeListOrNames[T any](s *MCPServer, capability string, raw json.RawMessage, fromName func(string) T) ([]T, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var items []T
+	err := json.Unmarshal(raw, &items)
+	if err == nil {
+		return items, nil
+	}
+	var names []string
+	if json.Unmarshal(raw, &names) != nil {
+		return nil, fmt.Errorf("failed to decode %s response: %w", capability, err)
+	}
+	log.Printf("Warning: MCP server %s listed %s as an array of names (legacy format); converting them with only a name", s.Config.Name, capability)
+	items = make([]T, len(names))
+	for i, name := range names {
+		items[i] = fromName(name)
+	}
+	return items, nil
 }
 
 type stdioToolsAndResourceInfo struct {
 	Result struct {
-		Tools      []ToolInfo     `json:"tools,omitempty"`
-		Resources  []ResourceInfo `json:"resources,omitempty"`
-		NextCursor string         `json:"nextCursor,omitempty"`
+		Tools      []discoveredTool `json:"tools,omitempty"`
+		Resources  []ResourceInfo   `json:"resources,omitempty"`
+		NextCursor string           `json:"nextCursor,omitempty"`
 	} `json:"result"`
 	Error interface{} `json:"error"`
+
+	// Some non-strict servers return the list fields at the top level without the
+	// `result` envelope. They are only used when the envelope is empty.
+	Tools      []discoveredTool `json:"tools,omitempty"`
+	Resources  []ResourceInfo   `json:"resources,omitempty"`
+	NextCursor string           `json:"nextCursor,omitempty"`
+}
+
+// applyUnwrappedFallback copies top-level tools, resources, and nextCursor into Result when
+// the `result` envelope is empty, logging a compatibility warning for the server.
+func (r *stdioToolsAndResourceInfo) applyUnwrappedFallback(serverName, method string) {
+	if len(r.Result.Tools) > 0 || len(r.Result.Resources) > 0 {
+		return
+	}
+	if len(r.Tools) == 0 && len(r.Resources) == 0 {
+		return
+	}
+	log.Printf("Warning: MCP server %s returned %s without a 'result' wrapper; using top-level fields for compatibility", serverName, method)
+	r.Result.Tools = r.Tools
+	r.Result.Resources = r.Resources
+	if r.Result.NextCursor == "" {
+		r.Result.NextCursor = r.NextCursor
+	}
 }
 
-// fetchToolsAndResourcesStdio fetches tools and resources from stdio MCP server.
-func (s *MCPServer) fetchToolsAndResourcesStdio() ([]ToolInfo, []ResourceInfo, error) {
+// fetchToolsAndResourcesStdio fetches tools and resources from stdio MCP server. A
+// request on the pipe cannot be interrupted, so ctx is checked before each one.
+func (s *MCPServer) fetchToolsAndResourcesStdio(ctx context.Context) ([]ToolInfo, []ResourceInfo, error) {
+	// The handshake comes first; list requests before it are invalid under MCP
+	s.initializeStdio(ctx)
+
 	// Define a helper function to send a request and parse response
 	sendRequest := func(method string) ([]stdioToolsAndResourceInfo, error) {
 		var allItems []stdioToolsAndResourceInfo
+		pages := s.newPageGuard(method)
 		cursor := ""
 		for {
 			params := map[string]interface{}{}
 			if cursor != "" {
 				params["cursor"] = cursor
 			}
+			id := s.NextRPCID()
 			req := map[string]interface{}{
 				"jsonrpc": "2.0",
-				"id":      1,
+				"id":      id,
 				"method":  method,
 				"params":  params,
 			}
@@ -492,31 +2370,29 @@ func (s *MCPServer) fetchToolsAndResourcesStdio() ([]ToolInfo, []ResourceInfo, e
 				return allItems, err
 			}
 
+			if err := ctx.Err(); err != nil {
+				return allItems, err
+			}
 			respBytes, err := s.HandleStdioRequest(reqBytes)
 			if err != nil {
-				log.Printf("Failed to handle MCP server request: %s", string(respBytes))
+				log.Printf("Failed to handle MCP server %s request %s (id %d): %v", s.Config.Name, method, id, err)
 				return allItems, err
 			}
 
 			var resp stdioToolsAndResourceInfo
 			if err := json.Unmarshal(respBytes, &resp); err != nil {
-				log.Printf("Failed to unmarshal MCP server response: %s", string(respBytes))
+				log.Printf("Failed to unmarshal MCP server %s response to %s (id %d): %s", s.Config.Name, method, id, string(respBytes))
 				return allItems, err
 			}
 
 			if resp.Error != nil {
-				// If error message is "Method not found", do not return an error
-				if errMap, ok := resp.Error.(map[string]interface{}); ok {
-					if msg, ok := errMap["message"].(string); ok && msg == "Method not found" {
-						return allItems, nil
-					}
-				}
-
-				return allItems, fmt.Errorf("error response: %v %s", resp.Error, string(respBytes))
+				// Method not found (-32601) is reported as errCapabilityAbsent
+				return allItems, stdioResponseError(resp.Error, respBytes)
 			}
+			resp.applyUnwrappedFallback(s.Config.Name, method)
 
 			allItems = append(allItems, resp)
-			if resp.Result.NextCursor == "" {
+			if !pages.follow(resp.Result.NextCursor) {
 				break
 			}
 			cursor = resp.Result.NextCursor
@@ -525,93 +2401,152 @@ func (s *MCPServer) fetchToolsAndResourcesStdio() ([]ToolInfo, []ResourceInfo, e
 	}
 
 	var tools []ToolInfo
-	toolResp, toolErr := sendRequest("tools/list")
-	if toolErr != nil {
-		fmt.Printf("failed to fetch tools: %v", toolErr)
-	} else {
+	toolsAbsent := s.capabilityAbsent(CapabilityTools)
+	if !toolsAbsent {
+		toolResp, err := sendRequest("tools/list")
+		switch {
+		case isCapabilityAbsent(err):
+			toolsAbsent = true
+		case err != nil:
+			return nil, nil, fmt.Errorf("failed to fetch tools for server %s: %w", s.Config.Name, err)
+		}
 		for _, tr := range toolResp {
-			tools = append(tools, tr.Result.Tools...)
+			tools = append(tools, toToolInfos(tr.Result.Tools)...)
 		}
 	}
 
 	var resources []ResourceInfo
-	resourceResp, resourceErr := sendRequest("resources/list")
-	if resourceErr != nil {
-		fmt.Printf("failed to fetch resources: %v", resourceErr)
-	} else {
+	resourcesAbsent := s.capabilityAbsent(CapabilityResources)
+	if !resourcesAbsent {
+		resourceResp, err := sendRequest("resources/list")
+		switch {
+		case isCapabilityAbsent(err):
+			resourcesAbsent = true
+		case err != nil:
+			return nil, nil, fmt.Errorf("failed to fetch resources for server %s: %w", s.Config.Name, err)
+		}
 		for _, rr := range resourceResp {
 			resources = append(resources, rr.Result.Resources...)
 		}
 	}
 
-	var err error
-	if toolErr != nil {
-		err = fmt.Errorf("failed to fetch tools for server %s: %w", s.Config.Name, toolErr)
-	} else if resourceErr != nil {
-		err = fmt.Errorf("failed to fetch resources for server %s: %w", s.Config.Name, toolErr)
+	if err := s.recordAbsentCapabilities(toolsAbsent, resourcesAbsent); err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch tools and resources for server %s: %w", s.Config.Name, err)
 	}
-
-	return tools, resources, err
+	return tools, resources, nil
 }
 
 // monitorProcess monitors the stdio MCP server process and restarts it if it exits unexpectedly.
-func (s *MCPServer) monitorProcess() {
+func (s *MCPServer) monitorProcess(cmd *exec.Cmd, stderr io.Reader) {
 	defer s.wg.Done()
 
-	stderrScanner := bufio.NewScanner(s.stderr)
+	stderrScanner := bufio.NewScanner(stderr)
 	go func() {
 		for stderrScanner.Scan() {
-			log.Printf("MCP server %s stderr: %s", s.Config.Name, stderrScanner.Text())
+			s.logStderr(stderrScanner.Text())
 		}
 	}()
 
-	err := s.cmd.Wait()
+	err := cmd.Wait()
 	if err != nil {
 		log.Printf("MCP server %s exited with error: %v", s.Config.Name, err)
 	} else {
 		log.Printf("MCP server %s exited", s.Config.Name)
 	}
 
-	s.mu.Lock()
-
-	if s.restarting {
-		s.mu.Unlock()
+	// Only a running process that was not stopped on purpose is restarted
+	s.stateMu.Lock()
+	ctx := s.ctx
+	if s.state != ProcessReady || ctx.Err() != nil {
+		s.stateMu.Unlock()
 		return
 	}
-
-	// Check if context is done (shutdown)
+	s.state = ProcessRestarting
+	s.stateMu.Unlock()
+	s.emit(EventBackendDown, err)
+
+	// Jittered backoff before restart to avoid rapid restart loops and spread out
+	// servers that crashed together
+	backoff := jitteredRestartBackoff()
+	log.Printf("Waiting %v before restarting MCP server %s", backoff.Round(time.Millisecond), s.Config.Name)
 	select {
-	case <-s.ctx.Done():
-		// Context canceled, do not restart
-		s.mu.Unlock()
+	case <-ctx.Done():
+		// Shut down while waiting, do not restart
 		return
-	default:
+	case <-time.After(backoff):
+	}
+
+	// Wait for a restart slot, holding it until the new process answers discovery
+	if s.restartLimiter != nil {
+		if err := s.restartLimiter.acquire(ctx); err != nil {
+			// Shut down while queued, do not restart
+			return
+		}
+		defer s.restartLimiter.release()
 	}
 
-	s.restarting = true
+	// Restart the process, unless it was stopped meanwhile
+	if err := s.launch(ProcessRestarting); errors.Is(err, errProcessStopping) {
+		return
+	} else if err != nil {
+		log.Printf("Failed to restart MCP server %s: %v", s.Config.Name, err)
+		s.emit(EventBackendDown, err)
+		return
+	}
+	s.mu.Lock()
+	s.restarts++
 	s.mu.Unlock()
+	s.emit(EventBackendUp, nil)
 
-	defer func() {
-		s.mu.Lock()
-		s.restarting = false
-		s.mu.Unlock()
-	}()
+	if err := s.refreshToolsAndResources(); err != nil {
+		log.Printf("Failed to refresh tools/resources for restarted MCP server %s: %v", s.Config.Name, err)
+	}
+}
 
-	// Backoff delay before restart to avoid rapid restart loops
-	backoff := 3 * time.Second
-	log.Printf("Waiting %v before restarting MCP server %s", backoff, s.Config.Name)
-	time.Sleep(backoff)
+// Restarts returns how many times the stdio process has been restarted after exiting.
+func (s *MCPServer) Restarts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restarts
+}
 
-	// Restart the process
-	if err := s.startStdioProcess(); err != nil {
-		log.Printf("Failed to restart MCP server %s: %v", s.Config.Name, err)
-	}
+// IsRestarting reports whether the stdio process is currently waiting to be restarted.
+func (s *MCPServer) IsRestarting() bool {
+	return s.ProcessState() == ProcessRestarting
 }
 
 // Shutdown gracefully shuts down the MCP server process.
 func (s *MCPServer) Shutdown() error {
-	if s.cancel != nil {
-		s.cancel()
+	s.stopToolPolicy()
+	if s.pool != nil {
+		s.pool.close(s)
+	}
+	s.stopProcess()
+	return nil
+}
+
+// stopProcess stops the stdio process, if any, without restarting it. A stop requested
+// while another is in progress waits for that one.
+func (s *MCPServer) stopProcess() {
+	// Not mu, which a request to the process holds until it answers
+	s.stateMu.Lock()
+	stdio := s.Config.Command != ""
+	if stdio {
+		switch s.processStateLocked() {
+		case ProcessStopped:
+			s.stateMu.Unlock()
+			return
+		case ProcessStopping:
+			s.stateMu.Unlock()
+			s.wg.Wait()
+			return
+		}
+		s.state = ProcessStopping
+	}
+	cancel := s.cancel
+	s.stateMu.Unlock()
+	if cancel != nil {
+		cancel()
 	}
 
 	// Give process some time to exit gracefully
@@ -646,58 +2581,104 @@ func (s *MCPServer) Shutdown() error {
 		s.stderr.Close()
 	}
 	s.mu.Unlock()
-
-	return nil
+	if stdio {
+		s.transition(ProcessStopping, ProcessStopped)
+	}
 }
 
-// IsToolAllowed checks if a tool is allowed for this MCP server.
+// IsToolAllowed checks if a tool is allowed for this MCP server, under its match_mode.
 func (s *MCPServer) IsToolAllowed(toolName string) bool {
-	if len(s.Config.AllowedTools) == 0 {
-		return true
-	}
-	for _, t := range s.Config.AllowedTools {
-		if t == toolName {
-			return true
-		}
+	if s.policy != nil {
+		return s.policy.allows(s.Config, toolName)
 	}
-	return false
+	return s.Config.AllowsTool(toolName)
 }
 
-// IsResourceAllowed checks if a resource is allowed for this MCP server.
+// IsResourceAllowed checks if a resource is allowed for this MCP server, under its match_mode.
 func (s *MCPServer) IsResourceAllowed(resourceName string) bool {
-	if len(s.Config.AllowedResources) == 0 {
-		return true
+	return s.Config.allowListed(s.Config.AllowedResources, resourceName)
+}
+
+// NotifyStdio writes a serialized notification to the stdio MCP server. Notifications
+// are not answered, so nothing is read back.
+func (s *MCPServer) NotifyStdio(reqBytes []byte) error {
+	if s.HandleStdioRequestFunc != nil {
+		_, err := s.HandleStdioRequestFunc(reqBytes)
+		return err
 	}
-	for _, r := range s.Config.AllowedResources {
-		if r == resourceName {
-			return true
+	if s.pool != nil {
+		if err := s.pool.acquire(s); err != nil {
+			return err
 		}
+		defer s.pool.release(s)
+	}
+
+	s.lockStdio()
+	defer s.mu.Unlock()
+	if s.stdin == nil {
+		return fmt.Errorf("MCP server %s process is not running", s.Config.Name)
 	}
-	return false
+	return s.pipeOp("notification write", func() error {
+		_, err := s.stdin.Write(append(reqBytes, '\n'))
+		return err
+	})
 }
 
 // HandleStdioRequest sends the serialized request to the stdio MCP server and reads the response.
+// When the request has an id, lines until the response carrying that id are skipped.
 func (s *MCPServer) HandleStdioRequest(reqBytes []byte) ([]byte, error) {
 	if s.HandleStdioRequestFunc != nil {
 		return s.HandleStdioRequestFunc(reqBytes)
 	}
+	if s.pool != nil {
+		if err := s.pool.acquire(s); err != nil {
+			return nil, err
+		}
+		defer s.pool.release(s)
+	}
 
-	s.mu.Lock()
+	s.lockStdio()
 	defer s.mu.Unlock()
+	if s.stdin == nil || s.stdout == nil {
+		// Never started; the pipe operations below run on goroutines where a nil pipe
+		// would crash the proxy
+		return nil, fmt.Errorf("MCP server %s process is not running", s.Config.Name)
+	}
+
+	id := stdioRequestID(reqBytes)
+	write, read := "request write", "response read"
+	if id != "" {
+		write, read = "write of request id "+id, "read of the response to request id "+id
+	}
 
 	// Write request followed by newline
-	_, err := s.stdin.Write(append(reqBytes, '\n'))
+	err := s.pipeOp(write, func() error {
+		_, err := s.stdin.Write(append(reqBytes, '\n'))
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	reader := bufio.NewReader(s.stdout)
 
-	// Read response line
-	respBytes, err := reader.ReadBytes('\n')
-	if err != nil {
-		return nil, err
+	// Read response line, answering requests the server makes in the meantime
+	for {
+		var respBytes []byte
+		err := s.pipeOp(read, func() error {
+			var err error
+			respBytes, err = reader.ReadBytes('\n')
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		answered, err := s.answerServerRequest(respBytes)
+		if err != nil {
+			return nil, err
+		}
+		if !answered && (id == "" || s.answers(respBytes, id)) {
+			return respBytes, nil
+		}
 	}
-
-	return respBytes, nil
 }
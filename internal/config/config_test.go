@@ -1,12 +1,20 @@
 package config
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -47,6 +55,49 @@ func TestLoadConfig_Valid(t *testing.T) {
 	}
 }
 
+// TestLoadConfig_RelativeCommand tests that relative command paths resolve against the config file directory.
+func TestLoadConfig_RelativeCommand(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "servers"), 0o755); err != nil {
+		t.Fatalf("failed to create servers dir: %v", err)
+	}
+	script := filepath.Join(dir, "servers", "run.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ncat\n"), 0o755); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	configPath := filepath.Join(dir, "config.json")
+	content := `{"mcp_servers":[
+		{"name":"rel","command":"./servers/run.sh","working_dir":"servers"},
+		{"name":"bare","command":"cat"}
+	]}`
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if got := cfg.MCPServers[0].CommandPath(); got != script {
+		t.Errorf("expected command resolved to %s, got %s", script, got)
+	}
+	if got := cfg.MCPServers[0].WorkingDirPath(); got != filepath.Join(dir, "servers") {
+		t.Errorf("expected working dir resolved to %s, got %s", filepath.Join(dir, "servers"), got)
+	}
+	if got := cfg.MCPServers[1].CommandPath(); got != "cat" {
+		t.Errorf("expected bare command to stay 'cat', got %s", got)
+	}
+
+	// A relative command that does not exist after resolution fails validation
+	content = `{"mcp_servers":[{"name":"rel","command":"./servers/missing.sh"}]}`
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if _, err := LoadConfig(configPath); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("expected missing command error, got %v", err)
+	}
+}
+
 // TestLoadConfig_InvalidPath tests loading a config from a nonexistent path.
 func TestLoadConfig_InvalidPath(t *testing.T) {
 	_, err := LoadConfig("nonexistent.json")
@@ -55,49 +106,316 @@ func TestLoadConfig_InvalidPath(t *testing.T) {
 	}
 }
 
-// TestValidate tests the Validate method of Config.
+// TestLoadConfig_FromEnv tests synthesizing a single-server config from environment variables.
+func TestLoadConfig_FromEnv(t *testing.T) {
+	t.Setenv("MCP_PROXY_CONFIG", "")
+	t.Setenv("MCP_SERVER_NAME", "env-server")
+	t.Setenv("MCP_SERVER_ADDRESS", "http://localhost:9000")
+	t.Setenv("MCP_SERVER_ALLOWED_TOOLS", "tool1, tool2,")
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig from env failed: %v", err)
+	}
+	if len(cfg.MCPServers) != 1 {
+		t.Fatalf("expected 1 MCP server, got %d", len(cfg.MCPServers))
+	}
+	server := cfg.MCPServers[0]
+	if server.Name != "env-server" || server.Address != "http://localhost:9000" {
+		t.Errorf("unexpected server config: %+v", server)
+	}
+	if len(server.AllowedTools) != 2 || server.AllowedTools[0] != "tool1" || server.AllowedTools[1] != "tool2" {
+		t.Errorf("unexpected allowed tools: %v", server.AllowedTools)
+	}
+}
+
+// TestLoadConfig_NoFileOrEnv tests that LoadConfig fails when neither a file nor server env vars are set.
+func TestLoadConfig_NoFileOrEnv(t *testing.T) {
+	t.Setenv("MCP_PROXY_CONFIG", "")
+	t.Setenv("MCP_SERVER_ADDRESS", "")
+	t.Setenv("MCP_SERVER_COMMAND", "")
+
+	if _, err := LoadConfig(""); err == nil {
+		t.Error("expected error when neither config file nor env vars are set, got nil")
+	}
+}
+
+// TestValidate tests that Validate accepts valid configurations and rejects invalid ones.
 func TestValidate(t *testing.T) {
-	cfg := &Config{
-		MCPServers: []MCPServerConfig{
+	servers := []MCPServerConfig{{Name: "s", Address: "http://backend.example"}}
+	one := func(sc MCPServerConfig) *Config { return &Config{MCPServers: []MCPServerConfig{sc}} }
+
+	tests := []struct {
+		name        string
+		cfg         *Config
+		wantErr     bool
+		errContains string // Part of the expected error message, when set
+	}{
+		{name: "valid", cfg: &Config{MCPServers: []MCPServerConfig{
 			{Name: "server1", Address: "http://localhost:9000"},
 			{Name: "server2", Address: "http://localhost:9001"},
-		},
+		}}},
+		{name: "empty mcp_servers", cfg: &Config{}, wantErr: true},
+		{name: "duplicate server names", cfg: &Config{MCPServers: []MCPServerConfig{
+			{Name: "server1", Address: "http://localhost:9000"},
+			{Name: "server1", Address: "http://localhost:9001"},
+		}}, wantErr: true},
+		{name: "empty server name", cfg: one(MCPServerConfig{Name: "", Address: "http://localhost:9000"}), wantErr: true},
+		{name: "empty server address and command", cfg: one(MCPServerConfig{Name: "server1"}), wantErr: true},
+		{name: "negative max_buffered_bytes", cfg: &Config{MCPServers: servers, MaxBufferedBytes: -1}, wantErr: true},
+		{name: "journal without path", cfg: &Config{MCPServers: servers, Journal: &JournalConfig{MaxBytes: 1024}}, wantErr: true},
+		{name: "error_budget recovery_rate above threshold", cfg: &Config{MCPServers: servers, ErrorBudget: &ErrorBudgetConfig{ErrorRateThreshold: 0.2, RecoveryRate: 0.3}}, wantErr: true},
+		{name: "pprof without admin_token", cfg: &Config{MCPServers: servers, Pprof: true}, wantErr: true},
+		{name: "mirror_to unknown server", cfg: one(MCPServerConfig{Name: "server1", Address: "http://localhost:9000", MirrorTo: &MirrorConfig{Server: "missing"}}), wantErr: true},
+		{name: "mirror_to sample_percent above 100", cfg: &Config{MCPServers: []MCPServerConfig{
+			{Name: "server1", Address: "http://localhost:9000", MirrorTo: &MirrorConfig{Server: "server2", SamplePercent: 150}},
+			{Name: "server2", Address: "http://localhost:9001"},
+		}}, wantErr: true},
+
+		// env
+		{name: "scalar env values", cfg: one(MCPServerConfig{Name: "s", Command: "cat", Env: map[string]interface{}{"A": "x", "B": float64(3000000000), "C": true}})},
+		{name: "nested env value", cfg: one(MCPServerConfig{Name: "s", Command: "cat", Env: map[string]interface{}{"A": "x", "D": map[string]interface{}{"nested": true}}}), wantErr: true, errContains: "env 'D'"},
+
+		// depends_on
+		{name: "depends_on", cfg: &Config{MCPServers: []MCPServerConfig{
+			{Name: "a", Address: "http://localhost:9000", DependsOn: []string{"b"}},
+			{Name: "b", Address: "http://localhost:9001"},
+		}}},
+		{name: "depends_on unknown server", cfg: &Config{MCPServers: []MCPServerConfig{
+			{Name: "a", Address: "http://localhost:9000", DependsOn: []string{"b"}},
+			{Name: "b", Address: "http://localhost:9001", DependsOn: []string{"missing"}},
+		}}, wantErr: true, errContains: "unknown server 'missing'"},
+		{name: "depends_on cycle", cfg: &Config{MCPServers: []MCPServerConfig{
+			{Name: "a", Address: "http://localhost:9000", DependsOn: []string{"b"}},
+			{Name: "b", Address: "http://localhost:9001", DependsOn: []string{"a"}},
+		}}, wantErr: true, errContains: "depends_on cycle: a -> b -> a"},
+
+		// stdio_tool_method
+		{name: "stdio_tool_method on a stdio server", cfg: one(MCPServerConfig{Name: "s", Command: "cat", StdioToolMethod: "call_tool"})},
+		{name: "stdio_tool_method on an HTTP server", cfg: one(MCPServerConfig{Name: "s", Address: "http://backend.example", StdioToolMethod: "call_tool"}), wantErr: true},
+
+		// sessions
+		{name: "sessions", cfg: &Config{MCPServers: servers, Sessions: &SessionsConfig{TTL: "1h", Path: "sessions.json"}}},
+		{name: "sessions.ttl not a duration", cfg: &Config{MCPServers: servers, Sessions: &SessionsConfig{TTL: "soon"}}, wantErr: true},
+		{name: "sessions.ttl zero", cfg: &Config{MCPServers: servers, Sessions: &SessionsConfig{TTL: "0s"}}, wantErr: true},
+		{name: "sessions.ttl negative", cfg: &Config{MCPServers: servers, Sessions: &SessionsConfig{TTL: "-1m"}}, wantErr: true},
+		{name: "sessions.max_sessions negative", cfg: &Config{MCPServers: servers, Sessions: &SessionsConfig{MaxSessions: -1}}, wantErr: true},
+
+		// sse
+		{name: "sse", cfg: &Config{MCPServers: servers, SSE: &SSEConfig{QueueSize: 8, Overflow: SSEOverflowDisconnect, HeartbeatInterval: "5s"}}},
+		{name: "sse negative queue_size", cfg: &Config{MCPServers: servers, SSE: &SSEConfig{QueueSize: -1}}, wantErr: true},
+		{name: "sse unknown overflow", cfg: &Config{MCPServers: servers, SSE: &SSEConfig{Overflow: "block"}}, wantErr: true},
+		{name: "sse zero heartbeat_interval", cfg: &Config{MCPServers: servers, SSE: &SSEConfig{HeartbeatInterval: "0s"}}, wantErr: true},
+		{name: "sse heartbeat_interval not a duration", cfg: &Config{MCPServers: servers, SSE: &SSEConfig{HeartbeatInterval: "often"}}, wantErr: true},
+
+		// resource_conflicts
+		{name: "resource_conflicts default", cfg: &Config{MCPServers: twoServers(), ResourceConflicts: &ResourceConflictsConfig{}}},
+		{name: "resource_conflicts error", cfg: &Config{MCPServers: twoServers(), ResourceConflicts: &ResourceConflictsConfig{Policy: ResourceConflictError}}},
+		{name: "resource_conflicts prefer_servers", cfg: &Config{MCPServers: twoServers(), ResourceConflicts: &ResourceConflictsConfig{Policy: ResourceConflictPreferServers, PreferServers: []string{"b"}}}},
+		{name: "resource_conflicts unknown policy", cfg: &Config{MCPServers: twoServers(), ResourceConflicts: &ResourceConflictsConfig{Policy: "last_wins"}}, wantErr: true},
+		{name: "resource_conflicts prefer_servers without servers", cfg: &Config{MCPServers: twoServers(), ResourceConflicts: &ResourceConflictsConfig{Policy: ResourceConflictPreferServers}}, wantErr: true},
+		{name: "resource_conflicts prefer_servers unknown server", cfg: &Config{MCPServers: twoServers(), ResourceConflicts: &ResourceConflictsConfig{Policy: ResourceConflictPreferServers, PreferServers: []string{"c"}}}, wantErr: true},
+
+		// json_numbers
+		{name: "json_numbers exact", cfg: &Config{MCPServers: servers, JSONNumbers: JSONNumbersExact}},
+		{name: "json_numbers float", cfg: &Config{MCPServers: servers, JSONNumbers: JSONNumbersFloat}},
+		{name: "json_numbers unknown", cfg: &Config{MCPServers: servers, JSONNumbers: "int"}, wantErr: true},
+
+		// admin
+		{name: "admin disabled", cfg: &Config{MCPServers: servers, Admin: &AdminConfig{}}},
+		{name: "admin api_keys", cfg: &Config{MCPServers: servers, Admin: &AdminConfig{Enabled: true, APIKeys: map[string]string{"alice": "key"}}}},
+		{name: "admin mTLS listener", cfg: &Config{MCPServers: servers, Admin: &AdminConfig{Enabled: true, Listen: "127.0.0.1:9090", TLSCertFile: "cert.pem", TLSKeyFile: "key.pem", ClientCAFile: "ca.pem"}}},
+		{name: "admin without credentials", cfg: &Config{MCPServers: servers, Admin: &AdminConfig{Enabled: true}}, wantErr: true},
+		{name: "admin empty api key", cfg: &Config{MCPServers: servers, Admin: &AdminConfig{Enabled: true, APIKeys: map[string]string{"alice": ""}}}, wantErr: true},
+		{name: "admin tls_cert_file without key", cfg: &Config{MCPServers: servers, Admin: &AdminConfig{Enabled: true, APIKeys: map[string]string{"alice": "key"}, TLSCertFile: "cert.pem"}}, wantErr: true},
+		{name: "admin TLS without listen", cfg: &Config{MCPServers: servers, Admin: &AdminConfig{Enabled: true, APIKeys: map[string]string{"alice": "key"}, TLSCertFile: "cert.pem", TLSKeyFile: "key.pem"}}, wantErr: true},
+		{name: "admin client_ca_file without TLS", cfg: &Config{MCPServers: servers, Admin: &AdminConfig{Enabled: true, Listen: "127.0.0.1:9090", ClientCAFile: "ca.pem"}}, wantErr: true},
+		{name: "admin_token combined with admin", cfg: &Config{MCPServers: servers, AdminToken: "secret", Admin: &AdminConfig{Enabled: true, APIKeys: map[string]string{"a": "b"}}}, wantErr: true},
+		{name: "pprof with a disabled admin group", cfg: &Config{MCPServers: servers, Pprof: true, Admin: &AdminConfig{APIKeys: map[string]string{"a": "b"}}}, wantErr: true},
+
+		// health_path
+		{name: "health_path", cfg: one(MCPServerConfig{Name: "s", Address: "http://backend.example", HealthPath: "/healthz", HealthIntervalSeconds: 5})},
+		{name: "health_path on a stdio server", cfg: one(MCPServerConfig{Name: "s", Command: "server", HealthPath: "/healthz"}), wantErr: true},
+		{name: "health_path without leading slash", cfg: one(MCPServerConfig{Name: "s", Address: "http://backend.example", HealthPath: "healthz"}), wantErr: true},
+		{name: "negative health_interval_seconds", cfg: one(MCPServerConfig{Name: "s", Address: "http://backend.example", HealthIntervalSeconds: -1}), wantErr: true},
+
+		// signing
+		{name: "signing_secret", cfg: one(MCPServerConfig{Name: "s", Address: "http://backend.example", SigningSecret: "secret"})},
+		{name: "signing with custom headers", cfg: one(MCPServerConfig{Name: "s", Address: "http://backend.example", SigningSecret: "secret", SigningAlgorithm: SigningSHA512, SignatureHeader: "X-Hub-Signature", TimestampHeader: "X-Hub-Time"})},
+		{name: "signing_secret on a stdio server", cfg: one(MCPServerConfig{Name: "s", Command: "server", SigningSecret: "secret"}), wantErr: true},
+		{name: "signing_algorithm without secret", cfg: one(MCPServerConfig{Name: "s", Address: "http://backend.example", SigningAlgorithm: SigningSHA256}), wantErr: true},
+		{name: "unknown signing_algorithm", cfg: one(MCPServerConfig{Name: "s", Address: "http://backend.example", SigningSecret: "secret", SigningAlgorithm: "md5"}), wantErr: true},
+		{name: "invalid signature_header", cfg: one(MCPServerConfig{Name: "s", Address: "http://backend.example", SigningSecret: "secret", SignatureHeader: "bad header"}), wantErr: true},
+		{name: "timestamp_header same as signature_header", cfg: one(MCPServerConfig{Name: "s", Address: "http://backend.example", SigningSecret: "secret", TimestampHeader: "x-signature"}), wantErr: true},
+
+		// type
+		{name: "smart-mcp-proxy type", cfg: one(MCPServerConfig{Name: "s", Address: "http://team-proxy.example", Type: BackendSmartMCPProxy})},
+		{name: "smart-mcp-proxy type with rest discovery", cfg: one(MCPServerConfig{Name: "s", Address: "http://team-proxy.example", Type: BackendSmartMCPProxy, Discovery: DiscoveryREST})},
+		{name: "unknown type", cfg: one(MCPServerConfig{Name: "s", Address: "http://backend.example", Type: "proxy"}), wantErr: true},
+		{name: "smart-mcp-proxy type with a command", cfg: one(MCPServerConfig{Name: "s", Command: "server", Type: BackendSmartMCPProxy}), wantErr: true},
+		{name: "smart-mcp-proxy type with jsonrpc discovery", cfg: one(MCPServerConfig{Name: "s", Address: "http://team-proxy.example", Type: BackendSmartMCPProxy, Discovery: DiscoveryJSONRPC}), wantErr: true},
+		{name: "smart-mcp-proxy type with tools_path", cfg: one(MCPServerConfig{Name: "s", Address: "http://team-proxy.example", Type: BackendSmartMCPProxy, ToolsPath: "/v1/tools"}), wantErr: true},
+
+		// argument limits
+		{name: "disabled max_argument_bytes", cfg: &Config{MCPServers: servers, MaxArgumentBytes: -1}, wantErr: false},
+		{name: "disabled max_argument_depth", cfg: &Config{MCPServers: servers, MaxArgumentDepth: -1}, wantErr: false},
+		{name: "negative max_argument_bytes", cfg: &Config{MCPServers: servers, MaxArgumentBytes: -2}, wantErr: true},
+		{name: "negative max_argument_depth", cfg: &Config{MCPServers: servers, MaxArgumentDepth: -2}, wantErr: true},
+		{name: "negative max_message_bytes", cfg: &Config{MCPServers: servers, MaxMessageBytes: -1}, wantErr: true},
+
+		// rate_limit_store
+		{name: "rate_limit_store default", cfg: &Config{MCPServers: servers, RateLimitStore: &RateLimitStoreConfig{}}},
+		{name: "rate_limit_store redis", cfg: &Config{MCPServers: servers, RateLimitStore: &RateLimitStoreConfig{Type: RateLimitStoreRedis, Address: "redis:6379", Username: "proxy", Password: "secret", OnFailure: RateLimitStoreFailClosed}}},
+		{name: "rate_limit_store unknown type", cfg: &Config{MCPServers: servers, RateLimitStore: &RateLimitStoreConfig{Type: "memcached"}}, wantErr: true},
+		{name: "rate_limit_store redis without address", cfg: &Config{MCPServers: servers, RateLimitStore: &RateLimitStoreConfig{Type: RateLimitStoreRedis}}, wantErr: true},
+		{name: "rate_limit_store negative db", cfg: &Config{MCPServers: servers, RateLimitStore: &RateLimitStoreConfig{Type: RateLimitStoreRedis, Address: "redis:6379", DB: -1}}, wantErr: true},
+		{name: "rate_limit_store username without password", cfg: &Config{MCPServers: servers, RateLimitStore: &RateLimitStoreConfig{Type: RateLimitStoreRedis, Address: "redis:6379", Username: "proxy"}}, wantErr: true},
+		{name: "rate_limit_store negative max_connections", cfg: &Config{MCPServers: servers, RateLimitStore: &RateLimitStoreConfig{Type: RateLimitStoreRedis, Address: "redis:6379", MaxConnections: -1}}, wantErr: true},
+		{name: "rate_limit_store timeout not a duration", cfg: &Config{MCPServers: servers, RateLimitStore: &RateLimitStoreConfig{Type: RateLimitStoreRedis, Address: "redis:6379", Timeout: "soon"}}, wantErr: true},
+		{name: "rate_limit_store unknown on_failure", cfg: &Config{MCPServers: servers, RateLimitStore: &RateLimitStoreConfig{Type: RateLimitStoreRedis, Address: "redis:6379", OnFailure: "retry"}}, wantErr: true},
+
+		// restricted_status, log_schema, max_redirects, list_limit
+		{name: "unknown restricted_status", cfg: &Config{MCPServers: servers, RestrictedStatus: "hidden"}, wantErr: true},
+		{name: "log_schema ecs", cfg: &Config{MCPServers: servers, LogSchema: LogSchemaECS}},
+		{name: "unknown log_schema", cfg: &Config{MCPServers: servers, LogSchema: "gelf"}, wantErr: true},
+		{name: "negative max_redirects", cfg: one(MCPServerConfig{Name: "s", Address: "http://backend.example", MaxRedirects: -1}), wantErr: true},
+		{name: "negative server list_limit", cfg: one(MCPServerConfig{Name: "s", Address: "http://backend.example", ListLimit: -1}), wantErr: true},
+		{name: "negative list_limit", cfg: &Config{MCPServers: servers, ListLimit: -1}, wantErr: true},
+
+		// static_resources
+		{name: "only static resources", cfg: one(MCPServerConfig{Name: "docs", StaticResources: []StaticResourceConfig{{Name: "readme", Content: "# Hello"}}})},
+		{name: "static resource without name", cfg: one(MCPServerConfig{Name: "docs", StaticResources: []StaticResourceConfig{{Content: "x"}}}), wantErr: true},
+		{name: "static resource without content or file", cfg: one(MCPServerConfig{Name: "docs", StaticResources: []StaticResourceConfig{{Name: "a"}}}), wantErr: true},
+		{name: "static resource with content and file", cfg: one(MCPServerConfig{Name: "docs", StaticResources: []StaticResourceConfig{{Name: "a", Content: "x", File: "a.txt"}}}), wantErr: true},
+		{name: "duplicate static resource", cfg: one(MCPServerConfig{Name: "docs", StaticResources: []StaticResourceConfig{{Name: "a", Content: "x"}, {Name: "a", File: "a.txt"}}}), wantErr: true},
+
+		// allowed_tools_from
+		{name: "allowed_tools_from file", cfg: one(MCPServerConfig{Name: "s", Address: "http://backend.example", AllowedToolsFrom: "file:///etc/mcp/allowed.txt"})},
+		{name: "allowed_tools_from https", cfg: one(MCPServerConfig{Name: "s", Address: "http://backend.example", AllowedToolsFrom: "https://policy.example/allowed.txt"})},
+		{name: "allowed_tools_from plain http", cfg: one(MCPServerConfig{Name: "s", Address: "http://backend.example", AllowedToolsFrom: "http://policy.example/allowed.txt"}), wantErr: true},
+		{name: "allowed_tools_from relative file URL", cfg: one(MCPServerConfig{Name: "s", Address: "http://backend.example", AllowedToolsFrom: "file://allowed.txt"}), wantErr: true},
+		{name: "allowed_tools_from path", cfg: one(MCPServerConfig{Name: "s", Address: "http://backend.example", AllowedToolsFrom: "/etc/mcp/allowed.txt"}), wantErr: true},
+		{name: "allowed_tools_from with allowed_tools", cfg: one(MCPServerConfig{Name: "s", Address: "http://backend.example", AllowedToolsFrom: "file:///etc/mcp/allowed.txt", AllowedTools: []string{"a"}}), wantErr: true},
+		{name: "negative allowed_tools_refresh_seconds", cfg: one(MCPServerConfig{Name: "s", Address: "http://backend.example", AllowedToolsRefreshSeconds: -1}), wantErr: true},
+
+		// static_tools
+		{name: "only static tools", cfg: one(MCPServerConfig{Name: "utils", StaticTools: []StaticToolConfig{{Name: "echo", Command: "cat"}}})},
+		{name: "static tool without name", cfg: one(MCPServerConfig{Name: "utils", StaticTools: []StaticToolConfig{{Command: "cat"}}}), wantErr: true},
+		{name: "static tool without command", cfg: one(MCPServerConfig{Name: "utils", StaticTools: []StaticToolConfig{{Name: "echo"}}}), wantErr: true},
+		{name: "static tool negative timeout", cfg: one(MCPServerConfig{Name: "utils", StaticTools: []StaticToolConfig{{Name: "echo", Command: "cat", TimeoutSeconds: -1}}}), wantErr: true},
+		{name: "duplicate static tool", cfg: one(MCPServerConfig{Name: "utils", StaticTools: []StaticToolConfig{{Name: "echo", Command: "cat"}, {Name: "echo", Command: "tee"}}}), wantErr: true},
 	}
-	if err := cfg.Validate(); err != nil {
-		t.Errorf("expected valid config, got error: %v", err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("unexpected validation error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected validation error, got nil")
+			}
+			if tt.errContains != "" && !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("expected error containing %q, got %v", tt.errContains, err)
+			}
+		})
 	}
+}
+
+// twoServers returns two HTTP servers, a and b.
+func twoServers() []MCPServerConfig {
+	return []MCPServerConfig{{Name: "a", Address: "http://a.example"}, {Name: "b", Address: "http://b.example"}}
+}
 
-	cfgEmpty := &Config{}
-	if err := cfgEmpty.Validate(); err == nil {
-		t.Error("expected error for empty MCPServers, got nil")
+// TestConfigDefaults tests the values used for settings left unset.
+func TestConfigDefaults(t *testing.T) {
+	cfg := &Config{MCPServers: []MCPServerConfig{{Name: "s", Address: "http://backend.example"}}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	server := cfg.MCPServers[0]
+	if got := server.StdioToolMethodOrDefault(); got != "tools/call" {
+		t.Errorf("default stdio tool method = %q", got)
+	}
+	if d, _ := (SessionsConfig{}).TTLDuration(); d != DefaultSessionTTL {
+		t.Errorf("default session TTL = %v", d)
+	}
+	sse := SSEConfig{}
+	if sse.QueueSizeOrDefault() != DefaultSSEQueueSize || sse.OverflowOrDefault() != SSEOverflowDropOldest {
+		t.Errorf("unexpected SSE defaults %d %s", sse.QueueSizeOrDefault(), sse.OverflowOrDefault())
+	}
+	if cfg.MaxArgumentBytesOrDefault() != DefaultMaxArgumentBytes || cfg.MaxArgumentDepthOrDefault() != DefaultMaxArgumentDepth {
+		t.Errorf("expected default argument limits, got %d bytes and depth %d", cfg.MaxArgumentBytesOrDefault(), cfg.MaxArgumentDepthOrDefault())
+	}
+	if disabled := (&Config{MaxArgumentBytes: -1, MaxArgumentDepth: -1}); disabled.MaxArgumentBytesOrDefault() != 0 || disabled.MaxArgumentDepthOrDefault() != 0 {
+		t.Errorf("expected -1 to disable the argument limits, got %d bytes and depth %d", disabled.MaxArgumentBytesOrDefault(), disabled.MaxArgumentDepthOrDefault())
+	}
+	if got := cfg.RestrictedStatusOrDefault(); got != RestrictedStatusForbidden {
+		t.Errorf("expected default restricted_status '%s', got '%s'", RestrictedStatusForbidden, got)
+	}
+	if got := cfg.LogSchemaOrDefault(); got != LogSchemaText {
+		t.Errorf("expected default log_schema '%s', got '%s'", LogSchemaText, got)
+	}
+	if !server.FollowsRedirects() || server.MaxRedirectsOrDefault() != DefaultMaxRedirects {
+		t.Errorf("expected redirects to be followed up to %d times by default", DefaultMaxRedirects)
 	}
 
-	cfgDuplicate := &Config{
-		MCPServers: []MCPServerConfig{
-			{Name: "server1", Address: "http://localhost:9000"},
-			{Name: "server1", Address: "http://localhost:9001"},
-		},
+	cfg = &Config{MCPServers: []MCPServerConfig{{Name: "s", Address: "http://backend.example"}, {Name: "t", Address: "http://backend.example", ListLimit: 2}}, ListLimit: 5}
+	if got := cfg.ListLimitFor(cfg.MCPServers[0]); got != 5 {
+		t.Errorf("expected the global list_limit 5, got %d", got)
 	}
-	if err := cfgDuplicate.Validate(); err == nil {
-		t.Error("expected error for duplicate server names, got nil")
+	if got := cfg.ListLimitFor(cfg.MCPServers[1]); got != 2 {
+		t.Errorf("expected the server's list_limit 2, got %d", got)
 	}
+}
 
-	cfgNoName := &Config{
-		MCPServers: []MCPServerConfig{
-			{Name: "", Address: "http://localhost:9000"},
-		},
+// TestStaticResourceFile tests that a static resource file is read relative to the
+// config directory.
+func TestStaticResourceFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "guide.txt"), []byte("guide"), 0o644); err != nil {
+		t.Fatal(err)
 	}
-	if err := cfgNoName.Validate(); err == nil {
-		t.Error("expected error for empty server name, got nil")
+	cfg := &Config{MCPServers: []MCPServerConfig{{Name: "docs", StaticResources: []StaticResourceConfig{{Name: "guide", File: "guide.txt"}}}}}
+	cfg.ResolvePaths(dir)
+	body, err := cfg.MCPServers[0].StaticResource("guide").Read()
+	if err != nil || string(body) != "guide" {
+		t.Errorf("expected the file relative to the config directory to be read, got %q, %v", body, err)
 	}
+}
 
-	cfgNoAddressOrCommand := &Config{
-		MCPServers: []MCPServerConfig{
-			{Name: "server1", Address: "", Command: ""},
-		},
+// TestFormatEnvValue tests normalization of non-string env values.
+func TestFormatEnvValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    string
+		wantErr bool
+	}{
+		{"string", "bar", "bar", false},
+		{"large integer", float64(3000000000), "3000000000", false},
+		{"fraction", 1.5, "1.5", false},
+		{"negative", float64(-42), "-42", false},
+		{"go int", 8080, "8080", false},
+		{"json number", json.Number("12345678901234567890"), "12345678901234567890", false},
+		{"true", true, "true", false},
+		{"false", false, "false", false},
+		{"null", nil, "", true},
+		{"object", map[string]interface{}{"a": 1}, "", true},
+		{"array", []interface{}{"a"}, "", true},
 	}
-	if err := cfgNoAddressOrCommand.Validate(); err == nil {
-		t.Error("expected error for empty server address and command, got nil")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := formatEnvValue(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("formatEnvValue(%v) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("formatEnvValue(%v) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
 	}
 }
 
@@ -120,6 +438,110 @@ func TestNewMCPServers(t *testing.T) {
 	}
 }
 
+// TestDependencyOrder tests that dependencies sort before dependents and independent servers keep config order.
+func TestDependencyOrder(t *testing.T) {
+	order, err := DependencyOrder([]MCPServerConfig{
+		{Name: "web", DependsOn: []string{"db", "cache"}},
+		{Name: "db"},
+		{Name: "cache", DependsOn: []string{"db"}},
+		{Name: "other"},
+	})
+	if err != nil {
+		t.Fatalf("DependencyOrder failed: %v", err)
+	}
+	want := []string{"db", "cache", "web", "other"}
+	if strings.Join(order, ",") != strings.Join(want, ",") {
+		t.Errorf("expected order %v, got %v", want, order)
+	}
+}
+
+// TestNewMCPServers_DependsOn tests that a dependent starts only after its dependency is ready,
+// and starts anyway once depends_on_timeout elapses.
+func TestNewMCPServers_DependsOn(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	record := func(event string) {
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}
+	newBackend := func(name string, delay time.Duration) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/tools" {
+				record(name + " discovery started")
+				time.Sleep(delay)
+			}
+			w.Write([]byte(`{"tools":[],"resources":[]}`))
+		}))
+	}
+	base := newBackend("base", 200*time.Millisecond)
+	defer base.Close()
+	dependent := newBackend("dependent", 0)
+	defer dependent.Close()
+
+	cfg := &Config{MCPServers: []MCPServerConfig{
+		{Name: "dependent", Address: dependent.URL, DependsOn: []string{"base"}},
+		{Name: "base", Address: base.URL},
+	}}
+	servers, err := NewMCPServers(cfg)
+	if err != nil {
+		t.Fatalf("NewMCPServers failed: %v", err)
+	}
+	if servers[0].Config.Name != "dependent" || servers[1].Config.Name != "base" {
+		t.Errorf("expected configuration order to be kept, got %s, %s", servers[0].Config.Name, servers[1].Config.Name)
+	}
+	if len(events) != 2 || events[0] != "base discovery started" {
+		t.Errorf("expected base to be discovered before dependent, got %v", events)
+	}
+
+	// A dependency slower than depends_on_timeout does not block the dependent
+	slow := newBackend("slow", time.Second)
+	defer slow.Close()
+	events = nil
+	cfg = &Config{MCPServers: []MCPServerConfig{
+		{Name: "dependent", Address: dependent.URL, DependsOn: []string{"slow"}, DependsOnTimeout: "50ms"},
+		{Name: "slow", Address: slow.URL},
+	}}
+	if _, err := NewMCPServers(cfg); err != nil {
+		t.Fatalf("NewMCPServers failed: %v", err)
+	}
+	if len(events) != 2 || events[0] != "slow discovery started" || events[1] != "dependent discovery started" {
+		t.Errorf("expected dependent to start during slow discovery, got %v", events)
+	}
+}
+
+// TestNewMCPServers_NoUsableServers tests the warning, and the strict_startup error, when
+// every configured server is disabled.
+func TestNewMCPServers_NoUsableServers(t *testing.T) {
+	disabled := false
+	cfg := &Config{
+		MCPServers: []MCPServerConfig{
+			{Name: "server1", Address: "http://localhost:9000", Enabled: &disabled},
+			{Name: "server2", Command: "cat", Enabled: &disabled},
+		},
+	}
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	servers, err := NewMCPServers(cfg)
+	if err != nil {
+		t.Fatalf("NewMCPServers failed: %v", err)
+	}
+	if len(servers) != 0 {
+		t.Errorf("expected disabled servers to be skipped, got %d", len(servers))
+	}
+	if !strings.Contains(logs.String(), "WARNING: "+ErrNoUsableServers.Error()) {
+		t.Errorf("expected a warning about no usable servers, got logs: %s", logs.String())
+	}
+
+	cfg.StrictStartup = true
+	if _, err := NewMCPServers(cfg); !errors.Is(err, ErrNoUsableServers) {
+		t.Errorf("expected ErrNoUsableServers under strict_startup, got %v", err)
+	}
+}
+
 // TestNewMCPServers_Stdio tests instantiation of stdio-based MCP server.
 func TestNewMCPServers_Stdio(t *testing.T) {
 	cfg := &Config{
@@ -269,6 +691,45 @@ func TestRefreshToolsAndResources_HTTP_FullAndLegacy(t *testing.T) {
 	}
 }
 
+// TestRefreshToolsAndResources_HTTP_LegacyNames tests that tools and resources listed as
+// arrays of names are converted, while an array of anything else still fails.
+func TestRefreshToolsAndResources_HTTP_LegacyNames(t *testing.T) {
+	bodies := map[string]string{
+		"/tools":     `{"tools":["tool1","tool2"]}`,
+		"/resources": `{"resources":["res1"]}`,
+	}
+	server := &MCPServer{Config: MCPServerConfig{Name: "legacy-server", Address: "http://mockserver"}}
+	server.httpClient = &http.Client{
+		Transport: &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				body, ok := bodies[req.URL.Path]
+				if !ok {
+					return nil, fmt.Errorf("unexpected URL: %s", req.URL)
+				}
+				return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body))}, nil
+			},
+		},
+	}
+
+	if err := server.refreshToolsAndResources(); err != nil {
+		t.Fatalf("refreshToolsAndResources failed: %v", err)
+	}
+	if len(server.tools) != 2 || server.tools[0].Name != "tool1" || server.tools[1].Name != "tool2" {
+		t.Errorf("unexpected tools parsed: %+v", server.tools)
+	}
+	if !reflect.DeepEqual(server.tools[0].InputSchema, map[string]interface{}{"type": "object"}) {
+		t.Errorf("expected an empty object schema for a legacy tool, got %v", server.tools[0].InputSchema)
+	}
+	if len(server.resources) != 1 || server.resources[0].Name != "res1" {
+		t.Errorf("unexpected resources parsed: %+v", server.resources)
+	}
+
+	bodies["/tools"] = `{"tools":[1,2]}`
+	if err := server.refreshToolsAndResources(); err == nil {
+		t.Error("expected an error for a tools array of numbers")
+	}
+}
+
 // mockRoundTripper mocks http.RoundTripper for testing
 type mockRoundTripper struct {
 	roundTripFunc func(req *http.Request) (*http.Response, error)
@@ -337,6 +798,39 @@ func TestRefreshToolsAndResources_Stdio_FullAndLegacy(t *testing.T) {
 	}
 }
 
+// TestRefreshToolsAndResources_Stdio_Unwrapped tests that responses without the result envelope are still parsed.
+func TestRefreshToolsAndResources_Stdio_Unwrapped(t *testing.T) {
+	server := &mockMCPServer{
+		MCPServer: MCPServer{
+			Config: MCPServerConfig{
+				Name:    "stdio-server",
+				Command: "mockcmd",
+			},
+		},
+		responses: map[string][]string{
+			"tools/list": {
+				`{"tools":[{"name":"tool1","description":"desc1"}],"nextCursor":"cursor1"}`,
+				`{"tools":[{"name":"tool2","description":"desc2"}]}`,
+			},
+			"resources/list": {
+				`{"resources":[{"name":"res1","description":"desc1"}]}`,
+			},
+		},
+		callCount: make(map[string]int),
+	}
+	server.HandleStdioRequestFunc = server.HandleStdioRequest
+
+	if err := server.refreshToolsAndResources(); err != nil {
+		t.Fatalf("refreshToolsAndResources failed: %v", err)
+	}
+	if len(server.tools) != 2 || server.tools[0].Name != "tool1" || server.tools[1].Name != "tool2" {
+		t.Errorf("unexpected tools parsed: %+v", server.tools)
+	}
+	if len(server.resources) != 1 || server.resources[0].Name != "res1" {
+		t.Errorf("unexpected resources parsed: %+v", server.resources)
+	}
+}
+
 // TestRefreshToolsAndResources_HTTP_ErrorCases tests error handling in HTTP fetcher.
 func TestRefreshToolsAndResources_HTTP_ErrorCases(t *testing.T) {
 	server := &MCPServer{
@@ -381,6 +875,140 @@ func (m *errorMockMCPServer) HandleStdioRequest(req []byte) ([]byte, error) {
 	return nil, fmt.Errorf("stdio error")
 }
 
+// TestDiscovery_RetriesTransientFailure tests that discovery is retried up to discovery_retries times.
+func TestDiscovery_RetriesTransientFailure(t *testing.T) {
+	defer func(d time.Duration) { discoveryRetryBackoff = d }(discoveryRetryBackoff)
+	discoveryRetryBackoff = time.Millisecond
+
+	toolCalls := 0
+	server := &MCPServer{
+		Config: MCPServerConfig{Name: "http-server", Address: "http://mockserver", DiscoveryRetries: 2},
+		httpClient: &http.Client{
+			Transport: &mockRoundTripper{
+				roundTripFunc: func(req *http.Request) (*http.Response, error) {
+					body := `{"resources":[]}`
+					if strings.HasSuffix(req.URL.String(), "/tools") {
+						toolCalls++
+						if toolCalls < 3 {
+							return nil, fmt.Errorf("connection refused")
+						}
+						body = `{"tools":[{"name":"tool1"}]}`
+					}
+					return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body))}, nil
+				},
+			},
+		},
+	}
+
+	if err := server.refreshToolsAndResources(); err != nil {
+		t.Fatalf("expected discovery to succeed after retries, got %v", err)
+	}
+	if toolCalls != 3 {
+		t.Errorf("expected 3 discovery attempts, got %d", toolCalls)
+	}
+	if len(server.tools) != 1 || server.tools[0].Name != "tool1" {
+		t.Errorf("unexpected tools parsed: %+v", server.tools)
+	}
+
+	// Without retries the first failure is returned
+	toolCalls = 0
+	server.Config.DiscoveryRetries = 0
+	if err := server.refreshToolsAndResources(); err == nil {
+		t.Error("expected error without retries, got nil")
+	}
+}
+
+// TestDiscovery_Timeout tests that discovery gives up after discovery_timeout_seconds,
+// even when the tool call client allows longer, for both HTTP and stdio servers.
+func TestDiscovery_Timeout(t *testing.T) {
+	httpServer := &MCPServer{
+		Config: MCPServerConfig{Name: "http-server", Address: "http://mockserver", DiscoveryTimeoutSeconds: 1},
+		httpClient: &http.Client{
+			Timeout: time.Minute,
+			Transport: &mockRoundTripper{
+				roundTripFunc: func(req *http.Request) (*http.Response, error) {
+					<-req.Context().Done()
+					return nil, req.Context().Err()
+				},
+			},
+		},
+	}
+	start := time.Now()
+	if err := httpServer.refreshToolsAndResources(); err == nil {
+		t.Error("expected HTTP discovery timeout error, got nil")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("HTTP discovery took %s, expected about 1s", elapsed)
+	}
+
+	stdioServer := &MCPServer{
+		Config: MCPServerConfig{Name: "stdio-server", Command: "mockcmd", DiscoveryTimeoutSeconds: 1},
+		HandleStdioRequestFunc: func(reqBytes []byte) ([]byte, error) {
+			time.Sleep(3 * time.Second)
+			return []byte(`{"result":{}}`), nil
+		},
+	}
+	start = time.Now()
+	err := stdioServer.refreshToolsAndResources()
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected stdio discovery timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("stdio discovery took %s, expected about 1s", elapsed)
+	}
+}
+
+// TestDiscovery_CancelledByShutdown tests that shutting down a server aborts a slow
+// discovery in flight instead of waiting for the discovery timeout.
+func TestDiscovery_CancelledByShutdown(t *testing.T) {
+	var stalled sync.Once
+	blocked := make(chan struct{})
+	slowMode := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-slowMode:
+			// Stall like a hung backend until the proxy gives up
+			stalled.Do(func() { close(blocked) })
+			<-r.Context().Done()
+			return
+		default:
+		}
+		if strings.HasSuffix(r.URL.Path, "/tools") {
+			w.Write([]byte(`{"tools":[{"name":"tool1"}]}`))
+			return
+		}
+		w.Write([]byte(`{"resources":[]}`))
+	}))
+	defer backend.Close()
+
+	servers, err := NewMCPServers(&Config{MCPServers: []MCPServerConfig{
+		{Name: "slow", Address: backend.URL, DiscoveryTimeoutSeconds: 30, DiscoveryRetries: 2},
+	}})
+	if err != nil {
+		t.Fatalf("NewMCPServers: %v", err)
+	}
+	server := servers[0]
+
+	close(slowMode)
+	refreshed := make(chan error, 1)
+	go func() { refreshed <- server.Refresh() }()
+	<-blocked
+
+	start := time.Now()
+	server.Shutdown()
+	select {
+	case err := <-refreshed:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected discovery to be cancelled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("discovery still running 5s after shutdown")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("shutdown during discovery took %s", elapsed)
+	}
+}
+
 // TestRefreshToolsAndResources_Stdio_ErrorCases tests error handling in stdio fetcher.
 func TestRefreshToolsAndResources_Stdio_ErrorCases(t *testing.T) {
 	server := &mockMCPServer{
@@ -402,3 +1030,53 @@ func TestRefreshToolsAndResources_Stdio_ErrorCases(t *testing.T) {
 		t.Errorf("expected error for stdio fetch failure, got %v", err)
 	}
 }
+
+// TestMCPServerConfig_ProxyFunc tests per-server http_proxy and no_proxy selection and validation.
+func TestMCPServerConfig_ProxyFunc(t *testing.T) {
+	for _, env := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy"} {
+		t.Setenv(env, "")
+	}
+
+	if (MCPServerConfig{Address: "http://backend.example"}).ProxyFunc() != nil {
+		t.Error("expected nil proxy func without http_proxy or no_proxy")
+	}
+
+	proxy := MCPServerConfig{HTTPProxy: "http://egress:3128", NoProxy: "internal.example"}.ProxyFunc()
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"http://backend.example/tools", "http://egress:3128"},
+		{"https://backend.example/tools", "http://egress:3128"},
+		{"http://api.internal.example/tools", ""},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, tt.target, nil)
+		got, err := proxy(req)
+		if err != nil {
+			t.Fatalf("proxy(%s) failed: %v", tt.target, err)
+		}
+		gotStr := ""
+		if got != nil {
+			gotStr = got.String()
+		}
+		if gotStr != tt.want {
+			t.Errorf("proxy(%s) = %q, want %q", tt.target, gotStr, tt.want)
+		}
+	}
+
+	invalid := []MCPServerConfig{
+		{Name: "s", Address: "http://backend.example", HTTPProxy: "ftp://egress"},
+		{Name: "s", Address: "http://backend.example", HTTPProxy: "egress:3128"},
+		{Name: "s", Command: "cat", HTTPProxy: "http://egress:3128"},
+	}
+	for _, sc := range invalid {
+		if err := (&Config{MCPServers: []MCPServerConfig{sc}}).Validate(); err == nil {
+			t.Errorf("expected validation error for http_proxy '%s' on %+v", sc.HTTPProxy, sc)
+		}
+	}
+	valid := &Config{MCPServers: []MCPServerConfig{{Name: "s", Address: "http://backend.example", HTTPProxy: "http://egress:3128", NoProxy: "*.internal"}}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+}
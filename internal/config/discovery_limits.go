@@ -0,0 +1,75 @@
+package config
+
+import "log"
+
+// DefaultDiscoveryMaxPages is used when discovery_max_pages is not set.
+const DefaultDiscoveryMaxPages = 100
+
+// DefaultDiscoveryMaxItems is used when discovery_max_items is not set.
+const DefaultDiscoveryMaxItems = 10000
+
+// MaxDiscoveryPages returns the cap on nextCursor pages followed per list call.
+func (sc MCPServerConfig) MaxDiscoveryPages() int {
+	if sc.DiscoveryMaxPages <= 0 {
+		return DefaultDiscoveryMaxPages
+	}
+	return sc.DiscoveryMaxPages
+}
+
+// MaxDiscoveredItems returns the cap on tools, and separately resources, kept from
+// one discovery.
+func (sc MCPServerConfig) MaxDiscoveredItems() int {
+	if sc.DiscoveryMaxItems <= 0 {
+		return DefaultDiscoveryMaxItems
+	}
+	return sc.DiscoveryMaxItems
+}
+
+// pageGuard stops a paginated list call that exceeds the page limit or returns a
+// nextCursor it already followed, which would otherwise loop forever.
+type pageGuard struct {
+	server   string
+	method   string
+	maxPages int
+	pages    int
+	seen     map[string]bool
+}
+
+// newPageGuard returns the guard for one paginated list call.
+func (s *MCPServer) newPageGuard(method string) *pageGuard {
+	return &pageGuard{server: s.Config.Name, method: method, maxPages: s.Config.MaxDiscoveryPages(), pages: 1, seen: make(map[string]bool)}
+}
+
+// follow reports whether the page at nextCursor should be fetched. Stopping early
+// keeps the pages fetched so far and logs a warning.
+func (g *pageGuard) follow(nextCursor string) bool {
+	if nextCursor == "" {
+		return false
+	}
+	if g.seen[nextCursor] {
+		log.Printf("Warning: MCP server %s repeated nextCursor %q in %s; stopping after %d pages", g.server, nextCursor, g.method, g.pages)
+		return false
+	}
+	if g.pages >= g.maxPages {
+		log.Printf("Warning: MCP server %s returned more than %d pages in %s; ignoring the rest", g.server, g.maxPages, g.method)
+		return false
+	}
+	g.seen[nextCursor] = true
+	g.pages++
+	return true
+}
+
+// capDiscovered truncates discovered tools and resources to discovery_max_items each,
+// logging a warning when anything is dropped.
+func (s *MCPServer) capDiscovered(tools []ToolInfo, resources []ResourceInfo) ([]ToolInfo, []ResourceInfo) {
+	max := s.Config.MaxDiscoveredItems()
+	if len(tools) > max {
+		log.Printf("Warning: MCP server %s listed %d tools; keeping the first %d (discovery_max_items)", s.Config.Name, len(tools), max)
+		tools = tools[:max]
+	}
+	if len(resources) > max {
+		log.Printf("Warning: MCP server %s listed %d resources; keeping the first %d (discovery_max_items)", s.Config.Name, len(resources), max)
+		resources = resources[:max]
+	}
+	return tools, resources
+}
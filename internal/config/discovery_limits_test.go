@@ -0,0 +1,74 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestDiscovery_RepeatingCursor is a regression test for a server that returns the same
+// nextCursor forever, which used to loop until the discovery timeout.
+func TestDiscovery_RepeatingCursor(t *testing.T) {
+	calls := 0
+	server := &MCPServer{
+		Config: MCPServerConfig{Name: "stdio-server", Command: "mockcmd", DiscoveryTimeoutSeconds: 5},
+		HandleStdioRequestFunc: func(reqBytes []byte) ([]byte, error) {
+			calls++
+			return []byte(`{"result":{"tools":[{"name":"tool1"}],"nextCursor":"same"}}`), nil
+		},
+	}
+	start := time.Now()
+	if err := server.refreshToolsAndResources(); err != nil {
+		t.Fatalf("refreshToolsAndResources failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("discovery took %s, expected the repeated cursor to stop it at once", elapsed)
+	}
+	// initialize and notifications/initialized, then two pages per list call (the
+	// first and the one at "same")
+	if calls != 6 {
+		t.Errorf("expected 6 requests, got %d", calls)
+	}
+}
+
+// TestDiscovery_PageAndItemLimits tests that pagination stops at discovery_max_pages
+// and that discovered tools are truncated to discovery_max_items.
+func TestDiscovery_PageAndItemLimits(t *testing.T) {
+	toolPages := 0
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     int64  `json:"id"`
+			Method string `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		if req.Method == "tools/list" {
+			toolPages++
+			resp["result"] = map[string]interface{}{
+				"tools":      []map[string]interface{}{{"name": fmt.Sprintf("tool%d-a", toolPages)}, {"name": fmt.Sprintf("tool%d-b", toolPages)}},
+				"nextCursor": fmt.Sprint(toolPages),
+			}
+		} else {
+			resp["result"] = map[string]interface{}{"resources": []interface{}{}}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer backend.Close()
+
+	server := &MCPServer{
+		Config:     MCPServerConfig{Name: "rpc", Address: backend.URL, Discovery: DiscoveryJSONRPC, DiscoveryMaxPages: 3, DiscoveryMaxItems: 5},
+		httpClient: &http.Client{},
+	}
+	if err := server.refreshToolsAndResources(); err != nil {
+		t.Fatalf("refreshToolsAndResources failed: %v", err)
+	}
+	if toolPages != 3 {
+		t.Errorf("expected 3 pages of tools, got %d", toolPages)
+	}
+	if names := toolNames(server); len(names) != 5 || names[4] != "tool3-a" {
+		t.Errorf("expected the first 5 tools, got %v", names)
+	}
+}
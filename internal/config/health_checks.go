@@ -0,0 +1,91 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// DefaultHealthInterval is used when health_interval_seconds is not set.
+const DefaultHealthInterval = 30 * time.Second
+
+// HealthInterval returns how often health_path is polled.
+func (sc MCPServerConfig) HealthInterval() time.Duration {
+	if sc.HealthIntervalSeconds <= 0 {
+		return DefaultHealthInterval
+	}
+	return time.Duration(sc.HealthIntervalSeconds) * time.Second
+}
+
+// Healthy reports whether the server passed its last health check, or, without a
+// health_path, its last discovery. A server is healthy until a check fails.
+func (s *MCPServer) Healthy() bool {
+	return s.HealthError() == nil
+}
+
+// HealthError returns why the server is unhealthy, or nil while it is healthy.
+func (s *MCPServer) HealthError() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.unhealthy
+}
+
+// setHealth records the outcome of a health check, where nil is a pass, and emits an
+// event when the server changes between healthy and unhealthy.
+func (s *MCPServer) setHealth(err error) {
+	s.mu.Lock()
+	wasHealthy := s.unhealthy == nil
+	s.unhealthy = err
+	s.mu.Unlock()
+	switch {
+	case wasHealthy && err != nil:
+		log.Printf("MCP server %s is unhealthy: %v", s.Config.Name, err)
+		s.emit(EventUnhealthy, err)
+	case !wasHealthy && err == nil:
+		log.Printf("MCP server %s is healthy again", s.Config.Name)
+		s.emit(EventHealthy, nil)
+	}
+}
+
+// checkHealth GETs the server's health_path; any 2xx status is a pass.
+func (s *MCPServer) checkHealth(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.Config.HealthInterval())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.Config.Address+s.Config.HealthPath, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("health check failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("health check returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// runHealthChecks polls the server's health_path at once and then every health
+// interval, until ctx ends at shutdown.
+func (s *MCPServer) runHealthChecks(ctx context.Context) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.Config.HealthInterval())
+	defer ticker.Stop()
+	for {
+		err := s.checkHealth(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		s.setHealth(err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
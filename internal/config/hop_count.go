@@ -0,0 +1,39 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// HopCountHeader counts the proxies a request has passed through. Each proxy forwards
+// it to its backends one higher than it received it.
+const HopCountHeader = "X-MCP-Hop-Count"
+
+// ProxyHopsHeader is an alias of HopCountHeader, accepted from clients and sent to
+// backends alongside it.
+const ProxyHopsHeader = "X-MCP-Proxy-Hops"
+
+// hopCountKey is the context key holding the hop count of the client's request.
+type hopCountKey struct{}
+
+// WithHopCount returns a context carrying the hop count of the client's request.
+func WithHopCount(ctx context.Context, hops int) context.Context {
+	if hops == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, hopCountKey{}, hops)
+}
+
+// HopCount returns the hop count stored by WithHopCount, or zero.
+func HopCount(ctx context.Context) int {
+	hops, _ := ctx.Value(hopCountKey{}).(int)
+	return hops
+}
+
+// SetHopCount sets both hop count headers of a request to a backend, one higher than
+// the count of the client's request.
+func SetHopCount(header http.Header, hops int) {
+	header.Set(HopCountHeader, strconv.Itoa(hops+1))
+	header.Set(ProxyHopsHeader, strconv.Itoa(hops+1))
+}
@@ -0,0 +1,248 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Values of discovery for HTTP servers.
+const (
+	DiscoveryREST    = "rest"    // GET /tools and /resources, POST /tool/{name}
+	DiscoveryJSONRPC = "jsonrpc" // JSON-RPC requests POSTed to the server address
+	DiscoveryAuto    = "auto"    // Try JSON-RPC, fall back to REST; the outcome is cached
+)
+
+// JSONRPCError is an error object returned by a JSON-RPC server.
+type JSONRPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *JSONRPCError) Error() string {
+	return fmt.Sprintf("JSON-RPC error %d: %s", e.Code, e.Message)
+}
+
+// jsonRPCMethodNotFound is the JSON-RPC code for an unknown method.
+const jsonRPCMethodNotFound = -32601
+
+// UsesJSONRPC reports whether tool calls to this HTTP server are sent as JSON-RPC
+// tools/call requests. Under "auto" this is known once discovery has succeeded.
+func (s *MCPServer) UsesJSONRPC() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.discoveryMode == DiscoveryJSONRPC
+}
+
+// resolvedDiscovery returns the discovery mode in use: the configured one, or the
+// cached outcome of "auto" ("" while undecided).
+func (s *MCPServer) resolvedDiscovery() string {
+	switch s.Config.Discovery {
+	case "", DiscoveryREST:
+		return DiscoveryREST
+	case DiscoveryJSONRPC:
+		return DiscoveryJSONRPC
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.discoveryMode
+}
+
+// fetchToolsAndResourcesHTTPMode discovers an HTTP server using its discovery mode.
+// Under "auto" it tries JSON-RPC first, falls back to REST, and caches the mode that worked.
+func (s *MCPServer) fetchToolsAndResourcesHTTPMode(ctx context.Context) ([]ToolInfo, []ResourceInfo, error) {
+	if s.Config.IsChainedProxy() {
+		return s.fetchToolsAndResourcesChained(ctx)
+	}
+	mode := s.resolvedDiscovery()
+	var tools []ToolInfo
+	var resources []ResourceInfo
+	var err error
+	switch mode {
+	case DiscoveryREST:
+		tools, resources, err = s.fetchToolsAndResourcesHTTP(ctx)
+	case DiscoveryJSONRPC:
+		tools, resources, err = s.fetchToolsAndResourcesJSONRPC(ctx)
+	default:
+		var rpcErr error
+		mode = DiscoveryJSONRPC
+		tools, resources, rpcErr = s.fetchToolsAndResourcesJSONRPC(ctx)
+		if rpcErr != nil {
+			mode = DiscoveryREST
+			tools, resources, err = s.fetchToolsAndResourcesHTTP(ctx)
+			if err != nil {
+				return nil, nil, fmt.Errorf("auto discovery failed: jsonrpc: %v; rest: %w", rpcErr, err)
+			}
+		}
+		log.Printf("MCP server %s: auto discovery selected %s", s.Config.Name, mode)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	s.mu.Lock()
+	s.discoveryMode = mode
+	s.mu.Unlock()
+	return tools, resources, nil
+}
+
+// fetchToolsAndResourcesJSONRPC lists tools and resources with tools/list and
+// resources/list requests POSTed to the server address, following nextCursor.
+// A method answered with "method not found" (-32601) is recorded as an absent capability.
+func (s *MCPServer) fetchToolsAndResourcesJSONRPC(ctx context.Context) ([]ToolInfo, []ResourceInfo, error) {
+	// The handshake comes first; list requests before it are invalid under MCP
+	if err := s.initializeJSONRPC(ctx); err != nil {
+		return nil, nil, fmt.Errorf("initialize failed: %w", err)
+	}
+
+	list := func(method string, page func(stdioToolsAndResourceInfo)) error {
+		pages := s.newPageGuard(method)
+		cursor := ""
+		for {
+			params := map[string]interface{}{}
+			if cursor != "" {
+				params["cursor"] = cursor
+			}
+			var resp stdioToolsAndResourceInfo
+			if err := s.CallJSONRPC(ctx, method, params, &resp.Result); err != nil {
+				return err
+			}
+			page(resp)
+			if !pages.follow(resp.Result.NextCursor) {
+				return nil
+			}
+			cursor = resp.Result.NextCursor
+		}
+	}
+
+	var tools []ToolInfo
+	toolsAbsent := s.capabilityAbsent(CapabilityTools)
+	if !toolsAbsent {
+		err := list("tools/list", func(r stdioToolsAndResourceInfo) { tools = append(tools, toToolInfos(r.Result.Tools)...) })
+		switch {
+		case isCapabilityAbsent(err):
+			toolsAbsent = true
+		case err != nil:
+			return nil, nil, fmt.Errorf("failed to list tools: %w", err)
+		}
+	}
+	var resources []ResourceInfo
+	resourcesAbsent := s.capabilityAbsent(CapabilityResources)
+	if !resourcesAbsent {
+		err := list("resources/list", func(r stdioToolsAndResourceInfo) { resources = append(resources, r.Result.Resources...) })
+		switch {
+		case isCapabilityAbsent(err):
+			resourcesAbsent = true
+		case err != nil:
+			return nil, nil, fmt.Errorf("failed to list resources: %w", err)
+		}
+	}
+	if err := s.recordAbsentCapabilities(toolsAbsent, resourcesAbsent); err != nil {
+		return nil, nil, err
+	}
+	return tools, resources, nil
+}
+
+// NextRPCID returns a new JSON-RPC request ID for this server. IDs are never reused for
+// the life of the MCPServer, across process restarts, so a late or stale response can
+// never be taken for the answer to another request.
+func (s *MCPServer) NextRPCID() int64 {
+	return s.rpcID.Add(1)
+}
+
+// newJSONRPCRequest returns a POST of the JSON-RPC message body to the server address,
+// with the hop count of ctx and the headers set by RequestHeaders, signed when
+// signing_secret is set.
+func (s *MCPServer) newJSONRPCRequest(ctx context.Context, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Config.Address, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	SetHopCount(req.Header, HopCount(ctx))
+	if s.RequestHeaders != nil {
+		s.RequestHeaders(ctx, req.Header)
+	}
+	s.Config.SignRequest(req.Header, body, time.Now())
+	return req, nil
+}
+
+// CallJSONRPC POSTs a JSON-RPC request to the server address and decodes its result
+// into result. A JSON-RPC error response is returned as a *JSONRPCError.
+func (s *MCPServer) CallJSONRPC(ctx context.Context, method string, params, result interface{}) error {
+	id := s.NextRPCID()
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := s.newJSONRPCRequest(ctx, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s (id %d) returned status %d", method, id, resp.StatusCode)
+	}
+
+	var rpcResp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *JSONRPCError   `json:"error"`
+	}
+	if err := json.Unmarshal(respBody, &rpcResp); err != nil {
+		return fmt.Errorf("invalid JSON-RPC response to %s (id %d): %w", method, id, err)
+	}
+	if rpcResp.Error != nil {
+		return rpcResp.Error
+	}
+	if len(rpcResp.Result) == 0 {
+		return fmt.Errorf("JSON-RPC response to %s (id %d) has no result", method, id)
+	}
+	return json.Unmarshal(rpcResp.Result, result)
+}
+
+// NotifyJSONRPC POSTs a JSON-RPC notification to the server address. Any 2xx status,
+// typically 202 Accepted without a body, is success.
+func (s *MCPServer) NotifyJSONRPC(ctx context.Context, method string, params interface{}) error {
+	message := map[string]interface{}{"jsonrpc": "2.0", "method": method}
+	if params != nil {
+		message["params"] = params
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	req, err := s.newJSONRPCRequest(ctx, body)
+	if err != nil {
+		return err
+	}
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned status %d", method, resp.StatusCode)
+	}
+	return nil
+}
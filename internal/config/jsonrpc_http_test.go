@@ -0,0 +1,121 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// testJSONRPCServer starts a JSON-RPC MCP server at "/" listing two pages of tools and
+// answering resources/list with "Method not found". It counts JSON-RPC requests.
+func testJSONRPCServer(requests *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		requests.Add(1)
+		var req struct {
+			ID     int64                  `json:"id"`
+			Method string                 `json:"method"`
+			Params map[string]interface{} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch {
+		case req.Method == "tools/list" && req.Params["cursor"] == nil:
+			resp["result"] = map[string]interface{}{"tools": []map[string]interface{}{{"name": "page1", "inputSchema": map[string]interface{}{"type": "object"}}}, "nextCursor": "2"}
+		case req.Method == "tools/list" && req.Params["cursor"] == "2":
+			resp["result"] = map[string]interface{}{"tools": []map[string]interface{}{{"name": "page2", "inputSchema": map[string]interface{}{"type": "object"}}}}
+		default:
+			resp["error"] = map[string]interface{}{"code": -32601, "message": "Method not found"}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+// testRESTServer starts a REST MCP server that rejects JSON-RPC POSTs.
+func testRESTServer(posts *atomic.Int32) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tools", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tools":[{"name":"rest-tool","inputSchema":{"type":"object"}}]}`))
+	})
+	mux.HandleFunc("/resources", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"resources":[{"name":"rest-res"}]}`))
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		posts.Add(1)
+		http.NotFound(w, r)
+	})
+	return httptest.NewServer(mux)
+}
+
+// toolNames returns the names of the server's advertised tools.
+func toolNames(s *MCPServer) []string {
+	var names []string
+	for _, tool := range s.GetTools() {
+		names = append(names, tool.Name)
+	}
+	return names
+}
+
+// TestDiscovery_JSONRPC tests paginated JSON-RPC discovery, with resources/list unsupported.
+func TestDiscovery_JSONRPC(t *testing.T) {
+	var requests atomic.Int32
+	backend := testJSONRPCServer(&requests)
+	defer backend.Close()
+
+	server := &MCPServer{Config: MCPServerConfig{Name: "rpc", Address: backend.URL, Discovery: DiscoveryJSONRPC}, httpClient: &http.Client{}}
+	if err := server.refreshToolsAndResources(); err != nil {
+		t.Fatalf("refreshToolsAndResources failed: %v", err)
+	}
+	if names := toolNames(server); len(names) != 2 || names[0] != "page1" || names[1] != "page2" {
+		t.Errorf("expected tools from both pages, got %v", names)
+	}
+	if len(server.GetResources()) != 0 {
+		t.Errorf("expected no resources, got %+v", server.GetResources())
+	}
+	if !server.UsesJSONRPC() {
+		t.Error("expected tool calls to use JSON-RPC")
+	}
+	// Two tools/list pages, resources/list and initialize
+	if got := requests.Load(); got != 4 {
+		t.Errorf("expected 4 JSON-RPC requests, got %d", got)
+	}
+}
+
+// TestDiscovery_Auto tests that auto discovery picks the mode the backend supports and
+// does not probe again once decided.
+func TestDiscovery_Auto(t *testing.T) {
+	var rpcRequests, restPosts atomic.Int32
+	rpcBackend := testJSONRPCServer(&rpcRequests)
+	defer rpcBackend.Close()
+	restBackend := testRESTServer(&restPosts)
+	defer restBackend.Close()
+
+	rpcServer := &MCPServer{Config: MCPServerConfig{Name: "rpc", Address: rpcBackend.URL, Discovery: DiscoveryAuto}, httpClient: &http.Client{}}
+	if err := rpcServer.refreshToolsAndResources(); err != nil {
+		t.Fatalf("refreshToolsAndResources failed: %v", err)
+	}
+	if !rpcServer.UsesJSONRPC() || len(toolNames(rpcServer)) != 2 {
+		t.Errorf("expected JSON-RPC discovery, got tools %v", toolNames(rpcServer))
+	}
+
+	restServer := &MCPServer{Config: MCPServerConfig{Name: "rest", Address: restBackend.URL, Discovery: DiscoveryAuto}, httpClient: &http.Client{}}
+	for i := 0; i < 2; i++ {
+		if err := restServer.refreshToolsAndResources(); err != nil {
+			t.Fatalf("refreshToolsAndResources failed: %v", err)
+		}
+	}
+	if restServer.UsesJSONRPC() {
+		t.Error("expected REST tool calls after falling back")
+	}
+	if names := toolNames(restServer); len(names) != 1 || names[0] != "rest-tool" {
+		t.Errorf("expected REST tools, got %v", names)
+	}
+	if got := restPosts.Load(); got != 1 {
+		t.Errorf("expected a single JSON-RPC probe, got %d", got)
+	}
+}
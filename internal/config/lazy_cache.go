@@ -0,0 +1,85 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// toolCache is the lazy_cache_dir file of a lazy server: its tools and resources as
+// discovered, before allow-lists are applied.
+type toolCache struct {
+	Tools     []ToolInfo     `json:"tools"`
+	Resources []ResourceInfo `json:"resources"`
+}
+
+// toolCachePath returns the cache file of the named server in dir.
+func toolCachePath(dir, name string) string {
+	return filepath.Join(dir, url.PathEscape(name)+".json")
+}
+
+// readToolCache reads the cache file of the named server at path. It reports false
+// when there is no usable cache file.
+func readToolCache(path, name string) (toolCache, bool) {
+	var cache toolCache
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Warning: failed to read tool cache of MCP server %s: %v", name, err)
+		}
+		return cache, false
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		log.Printf("Warning: ignoring invalid tool cache %s of MCP server %s: %v", path, name, err)
+		return cache, false
+	}
+	return cache, true
+}
+
+// CachedTools returns the tools of a lazy server from its lazy_cache_dir file, as
+// discovered before allow-lists are applied, without starting it. It reports false
+// for a server that is not lazy or has no usable cache file.
+func (c *Config) CachedTools(sc MCPServerConfig) ([]ToolInfo, bool) {
+	if !sc.Lazy || c.LazyCacheDir == "" {
+		return nil, false
+	}
+	cache, ok := readToolCache(toolCachePath(c.LazyCacheDir, sc.Name), sc.Name)
+	return cache.Tools, ok
+}
+
+// loadToolCache applies the cached tools and resources of a lazy server. It reports
+// false when there is no usable cache file and the server must be discovered.
+func (s *MCPServer) loadToolCache() bool {
+	if s.toolCachePath == "" {
+		return false
+	}
+	cache, ok := readToolCache(s.toolCachePath, s.Config.Name)
+	if !ok {
+		return false
+	}
+	s.applyDiscovered(cache.Tools, cache.Resources)
+	log.Printf("Loaded %d tools and %d resources of lazy MCP server %s from %s", len(cache.Tools), len(cache.Resources), s.Config.Name, s.toolCachePath)
+	return true
+}
+
+// saveToolCache persists discovered tools and resources for the next startup. Errors
+// are logged; the server keeps working without a cache.
+func (s *MCPServer) saveToolCache(tools []ToolInfo, resources []ResourceInfo) {
+	data, err := json.MarshalIndent(toolCache{Tools: tools, Resources: resources}, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(s.toolCachePath), 0o755)
+	}
+	if err == nil {
+		// Write then rename so a crash never leaves a truncated cache behind
+		tmp := s.toolCachePath + ".tmp"
+		if err = os.WriteFile(tmp, data, 0o600); err == nil {
+			err = os.Rename(tmp, s.toolCachePath)
+		}
+	}
+	if err != nil {
+		log.Printf("Warning: failed to write tool cache of MCP server %s: %v", s.Config.Name, err)
+	}
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLazyServerColdStart tests that a lazy server is stopped after startup discovery,
+// starts on its first tool call, and stops again once idle.
+func TestLazyServerColdStart(t *testing.T) {
+	sc := testStdioServerConfig("lazy")
+	sc.Lazy = true
+	servers, err := NewMCPServers(&Config{MCPServers: []MCPServerConfig{sc}, StdioIdleTimeout: "100ms"})
+	if err != nil {
+		t.Fatalf("NewMCPServers failed: %v", err)
+	}
+	server := servers[0]
+	defer server.Shutdown()
+
+	if names := toolNames(server); len(names) != 1 || names[0] != "lazy-tool" {
+		t.Fatalf("expected the lazy server's tool to be discovered, got %v", names)
+	}
+	if server.ProcessRunning() {
+		t.Fatal("expected the lazy server to be stopped until first use")
+	}
+
+	resp, err := server.HandleStdioRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"lazy-tool"}}`))
+	if err != nil {
+		t.Fatalf("tools/call failed: %v", err)
+	}
+	if !strings.Contains(string(resp), `"text":"lazy"`) {
+		t.Errorf("unexpected response %s", resp)
+	}
+	if !server.ProcessRunning() {
+		t.Error("expected the first call to start the process")
+	}
+	waitFor(t, 2*time.Second, func() bool { return !server.ProcessRunning() })
+}
+
+// TestLazyServerToolCache tests that a lazy server's tools are persisted and, on the
+// next startup, loaded without starting the process.
+func TestLazyServerToolCache(t *testing.T) {
+	dir := t.TempDir()
+	sc := testStdioServerConfig("cached")
+	sc.Lazy = true
+	servers, err := NewMCPServers(&Config{MCPServers: []MCPServerConfig{sc}, LazyCacheDir: dir})
+	if err != nil {
+		t.Fatalf("NewMCPServers failed: %v", err)
+	}
+	servers[0].Shutdown()
+	if _, err := os.Stat(toolCachePath(dir, "cached")); err != nil {
+		t.Fatalf("expected a tool cache file: %v", err)
+	}
+
+	// A command that cannot be discovered proves the tools come from the cache
+	sc.Args = []string{"-c", "exit 1"}
+	servers, err = NewMCPServers(&Config{MCPServers: []MCPServerConfig{sc}, LazyCacheDir: dir, StrictStartup: true})
+	if err != nil {
+		t.Fatalf("NewMCPServers failed: %v", err)
+	}
+	server := servers[0]
+	defer server.Shutdown()
+	if names := toolNames(server); len(names) != 1 || names[0] != "cached-tool" {
+		t.Errorf("expected the cached tool, got %v", names)
+	}
+	if server.ProcessRunning() {
+		t.Error("expected the process not to be started")
+	}
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// LazySchemaCacheSize is how many schemas a server with lazy_schemas keeps after
+// fetching them on demand; the least recently used one is evicted first.
+const LazySchemaCacheSize = 32
+
+// ErrToolNotProvided is returned by ToolSchema for a tool the server does not provide.
+var ErrToolNotProvided = errors.New("tool not provided by server")
+
+// dropSchemas removes the input and output schemas of a tool discovered with
+// lazy_schemas, keeping its name, description and annotations.
+func dropSchemas(tool *ToolInfo) {
+	tool.InputSchema = nil
+	tool.OutputSchema = nil
+	tool.LazySchema = true
+}
+
+// WithPlaceholderSchema returns tool with {"type":"object"} in place of an input schema
+// dropped by lazy_schemas, for listings whose clients require an inputSchema.
+func WithPlaceholderSchema(tool ToolInfo) ToolInfo {
+	if tool.LazySchema {
+		tool.InputSchema = map[string]interface{}{"type": "object"}
+	}
+	return tool
+}
+
+// ToolSchema returns an allowed tool with its input and output schemas. On a server
+// with lazy_schemas the schemas are fetched from the backend by a single discovery
+// attempt, of which only the requested tool is kept, in a small LRU cache. Concurrent
+// misses on the same server share one attempt.
+func (s *MCPServer) ToolSchema(ctx context.Context, name string) (ToolInfo, error) {
+	var cached *ToolInfo
+	for _, tool := range s.GetTools() {
+		if tool.Name == name {
+			cached = &tool
+			break
+		}
+	}
+	if cached == nil {
+		return ToolInfo{}, fmt.Errorf("%w: '%s' on %s", ErrToolNotProvided, name, s.Config.Name)
+	}
+	if !cached.LazySchema {
+		return *cached, nil
+	}
+	if tool, ok := s.lazySchemas.get(name); ok {
+		return tool, nil
+	}
+
+	tools, err := s.fetchSchemas(ctx)
+	if err != nil {
+		return ToolInfo{}, fmt.Errorf("failed to fetch schema of tool '%s' from server %s: %w", name, s.Config.Name, err)
+	}
+	for _, tool := range tools {
+		if tool.Name == name {
+			normalizeToolSchema(&tool)
+			tool.SchemaIssue = ""
+			s.lazySchemas.put(tool)
+			return tool, nil
+		}
+	}
+	return ToolInfo{}, fmt.Errorf("%w: '%s' is gone from %s", ErrToolNotProvided, name, s.Config.Name)
+}
+
+// schemaFetch is a discovery attempt of ToolSchema, shared by the misses made while
+// it runs.
+type schemaFetch struct {
+	done  chan struct{} // Closed once tools and err are set
+	tools []ToolInfo
+	err   error
+}
+
+// fetchSchemas returns the tools of a single discovery attempt, joining the one in
+// flight if any. The attempt is not tied to ctx, as other misses may be waiting on
+// it; ctx only bounds the wait.
+func (s *MCPServer) fetchSchemas(ctx context.Context) ([]ToolInfo, error) {
+	c := &s.lazySchemas
+	c.mu.Lock()
+	fetch := c.fetch
+	if fetch == nil {
+		fetch = &schemaFetch{done: make(chan struct{})}
+		c.fetch = fetch
+		go func() {
+			fetch.tools, _, fetch.err = s.discoverOnce(context.WithoutCancel(ctx), s.Config.DiscoveryTimeout())
+			c.mu.Lock()
+			if c.fetch == fetch {
+				c.fetch = nil
+			}
+			c.mu.Unlock()
+			close(fetch.done)
+		}()
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-fetch.done:
+		return fetch.tools, fetch.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// schemaLRU holds the tools whose schemas were fetched on demand, most recently used
+// first. The zero value is an empty cache of LazySchemaCacheSize entries.
+type schemaLRU struct {
+	mu      sync.Mutex
+	order   *list.List               // *ToolInfo values, most recently used at the front
+	entries map[string]*list.Element // By tool name
+	fetch   *schemaFetch             // Discovery attempt in flight, if any
+}
+
+// get returns the cached tool and marks it as most recently used.
+func (c *schemaLRU) get(name string) (ToolInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[name]
+	if !ok {
+		return ToolInfo{}, false
+	}
+	c.order.MoveToFront(elem)
+	return *elem.Value.(*ToolInfo), true
+}
+
+// put caches a tool, evicting the least recently used one when full.
+func (c *schemaLRU) put(tool ToolInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.order = list.New()
+		c.entries = make(map[string]*list.Element)
+	}
+	if elem, ok := c.entries[tool.Name]; ok {
+		elem.Value = &tool
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[tool.Name] = c.order.PushFront(&tool)
+	if c.order.Len() > LazySchemaCacheSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*ToolInfo).Name)
+	}
+}
+
+// len returns the number of cached schemas.
+func (c *schemaLRU) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// clear empties the cache, after a refresh may have changed the tools' schemas. Later
+// misses do not join an attempt already in flight.
+func (c *schemaLRU) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order = nil
+	c.entries = nil
+	c.fetch = nil
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestSchemaLRU tests that the schema cache evicts the least recently used tool once
+// it holds LazySchemaCacheSize of them, and that clear empties it.
+func TestSchemaLRU(t *testing.T) {
+	var cache schemaLRU
+	for i := 0; i < LazySchemaCacheSize; i++ {
+		cache.put(ToolInfo{Name: fmt.Sprintf("tool%d", i)})
+	}
+	if _, ok := cache.get("tool0"); !ok {
+		t.Fatal("expected tool0 to be cached")
+	}
+	cache.put(ToolInfo{Name: "extra"})
+
+	if cache.len() != LazySchemaCacheSize {
+		t.Errorf("expected %d cached schemas, got %d", LazySchemaCacheSize, cache.len())
+	}
+	if _, ok := cache.get("tool1"); ok {
+		t.Error("expected tool1, the least recently used, to be evicted")
+	}
+	if _, ok := cache.get("tool0"); !ok {
+		t.Error("expected tool0 to stay cached after being used")
+	}
+
+	cache.clear()
+	if _, ok := cache.get("extra"); ok || cache.len() != 0 {
+		t.Error("expected clear to empty the cache")
+	}
+}
+
+// TestApplyDiscoveredLazySchemas tests that lazy_schemas drops the schemas of
+// discovered tools but keeps their description and annotations.
+func TestApplyDiscoveredLazySchemas(t *testing.T) {
+	server := &MCPServer{Config: MCPServerConfig{Name: "lazy", LazySchemas: true}}
+	server.applyDiscovered([]ToolInfo{{
+		Name:         "search",
+		Description:  "Searches",
+		InputSchema:  map[string]interface{}{"type": "object"},
+		OutputSchema: map[string]interface{}{"type": "object"},
+		Annotations:  map[string]interface{}{"readOnlyHint": true},
+	}}, nil)
+
+	tools := server.GetTools()
+	if len(tools) != 1 {
+		t.Fatalf("expected 1 tool, got %d", len(tools))
+	}
+	tool := tools[0]
+	if !tool.LazySchema || tool.InputSchema != nil || tool.OutputSchema != nil {
+		t.Errorf("expected schemas to be dropped, got %+v", tool)
+	}
+	if tool.Description != "Searches" || tool.Annotations["readOnlyHint"] != true {
+		t.Errorf("expected description and annotations to be kept, got %+v", tool)
+	}
+	if server.OutputSchema("search") != nil {
+		t.Error("expected no compiled output schema")
+	}
+	if issues := server.SchemaIssues(); issues != nil {
+		t.Errorf("expected no schema issues, got %v", issues)
+	}
+}
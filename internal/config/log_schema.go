@@ -0,0 +1,45 @@
+package config
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+// Values of log_schema.
+const (
+	LogSchemaText = "text" // Lines of the standard logger, and Gin's access log on stdout
+	LogSchemaECS  = "ecs"  // Elastic Common Schema JSON documents on stderr, one per line
+)
+
+// LogSchemaOrDefault returns LogSchema, or LogSchemaText when unset.
+func (c *Config) LogSchemaOrDefault() string {
+	if c.LogSchema == "" {
+		return LogSchemaText
+	}
+	return c.LogSchema
+}
+
+// StderrHandler receives each line a stdio server writes to stderr.
+type StderrHandler func(server, line string)
+
+// stderrHandler is the handler set by SetStderrHandler, if any.
+var stderrHandler atomic.Pointer[StderrHandler]
+
+// SetStderrHandler registers the handler of stdio server stderr lines for all servers,
+// in place of logging them. Nil restores logging.
+func SetStderrHandler(h StderrHandler) {
+	if h == nil {
+		stderrHandler.Store(nil)
+		return
+	}
+	stderrHandler.Store(&h)
+}
+
+// logStderr passes a stderr line of the server to the handler, or logs it.
+func (s *MCPServer) logStderr(line string) {
+	if h := stderrHandler.Load(); h != nil {
+		(*h)(s.Config.Name, line)
+		return
+	}
+	log.Printf("MCP server %s stderr: %s", s.Config.Name, line)
+}
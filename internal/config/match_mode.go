@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Values of match_mode.
+const (
+	MatchExact           = "exact"
+	MatchCaseInsensitive = "case_insensitive"
+	MatchNormalized      = "normalized"
+)
+
+// MatchModeOrDefault returns how allowed_tools and allowed_resources entries are matched.
+func (sc MCPServerConfig) MatchModeOrDefault() string {
+	if sc.MatchMode == "" {
+		return MatchExact
+	}
+	return sc.MatchMode
+}
+
+// matchKey returns the form of a tool or resource name compared under the match mode.
+func (sc MCPServerConfig) matchKey(name string) string {
+	switch sc.MatchModeOrDefault() {
+	case MatchCaseInsensitive:
+		return strings.ToLower(name)
+	case MatchNormalized:
+		return normalizeName(name)
+	}
+	return name
+}
+
+// normalizeName lowercases a name, trims it and collapses each run of whitespace,
+// '-', '_' and '.' into a single '_', so "Search-Repos " matches "search_repos".
+func normalizeName(name string) string {
+	fields := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return unicode.IsSpace(r) || r == '-' || r == '_' || r == '.'
+	})
+	return strings.Join(fields, "_")
+}
+
+// AllowsTool reports whether allowed_tools admits toolName under the match mode. An
+// empty allowed_tools admits every tool.
+func (sc MCPServerConfig) AllowsTool(toolName string) bool {
+	return sc.allowListed(sc.AllowedTools, toolName)
+}
+
+// allowListed reports whether name is allowed by an allow-list under the match mode;
+// an empty list allows every name.
+func (sc MCPServerConfig) allowListed(list []string, name string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	key := sc.matchKey(name)
+	return slices.ContainsFunc(list, func(entry string) bool { return sc.matchKey(entry) == key })
+}
+
+// validateMatchMode checks match_mode.
+func (sc MCPServerConfig) validateMatchMode() error {
+	switch sc.MatchModeOrDefault() {
+	case MatchExact, MatchCaseInsensitive, MatchNormalized:
+		return nil
+	}
+	return fmt.Errorf("invalid match_mode '%s': must be '%s', '%s' or '%s'", sc.MatchMode, MatchExact, MatchCaseInsensitive, MatchNormalized)
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestAllowListMatchModes tests IsToolAllowed and IsResourceAllowed under each
+// match_mode, and that exact matching stays the default.
+func TestAllowListMatchModes(t *testing.T) {
+	tests := []struct {
+		mode  string
+		entry string
+		name  string
+		want  bool
+	}{
+		{"", "search_repos", "search_repos", true},
+		{"", "Search_Repos", "search_repos", false},
+		{"", " search_repos", "search_repos", false},
+		{MatchExact, "Search_Repos", "search_repos", false},
+		{MatchCaseInsensitive, "Search_Repos", "search_repos", true},
+		{MatchCaseInsensitive, "search-repos", "search_repos", false},
+		{MatchNormalized, " Search-Repos ", "search_repos", true},
+		{MatchNormalized, "search  repos", "Search__Repos", true},
+		{MatchNormalized, "search_repos", "searchrepos", false},
+	}
+	for _, tt := range tests {
+		server := &MCPServer{Config: MCPServerConfig{MatchMode: tt.mode, AllowedTools: []string{tt.entry}, AllowedResources: []string{tt.entry}}}
+		if got := server.IsToolAllowed(tt.name); got != tt.want {
+			t.Errorf("match_mode '%s': IsToolAllowed(%q) with allowed_tools [%q] = %v, want %v", tt.mode, tt.name, tt.entry, got, tt.want)
+		}
+		if got := server.IsResourceAllowed(tt.name); got != tt.want {
+			t.Errorf("match_mode '%s': IsResourceAllowed(%q) with allowed_resources [%q] = %v, want %v", tt.mode, tt.name, tt.entry, got, tt.want)
+		}
+	}
+}
+
+// TestRefreshToolsAndResources_MatchMode tests that discovery partitions tools and
+// resources with the same matching as IsToolAllowed.
+func TestRefreshToolsAndResources_MatchMode(t *testing.T) {
+	for _, tt := range []struct {
+		mode        string
+		wantAllowed int
+	}{{MatchExact, 0}, {MatchCaseInsensitive, 1}} {
+		server := &MCPServer{Config: MCPServerConfig{
+			Name: "renamed", Address: "http://mockserver", MatchMode: tt.mode,
+			AllowedTools: []string{"Search_Repos"}, AllowedResources: []string{"README"},
+		}}
+		server.httpClient = &http.Client{Transport: &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				body := `{"tools":[{"name":"search_repos","inputSchema":{"type":"object"}}]}`
+				if strings.HasSuffix(req.URL.Path, "/resources") {
+					body = `{"resources":[{"name":"readme"}]}`
+				}
+				return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body))}, nil
+			},
+		}}
+		if err := server.refreshToolsAndResources(); err != nil {
+			t.Fatalf("refreshToolsAndResources failed: %v", err)
+		}
+		if got := len(server.GetTools()); got != tt.wantAllowed {
+			t.Errorf("match_mode '%s': expected %d allowed tools, got %d", tt.mode, tt.wantAllowed, got)
+		}
+		if got := len(server.GetResources()); got != tt.wantAllowed {
+			t.Errorf("match_mode '%s': expected %d allowed resources, got %d", tt.mode, tt.wantAllowed, got)
+		}
+	}
+}
+
+// TestValidate_MatchMode tests validation of match_mode.
+func TestValidate_MatchMode(t *testing.T) {
+	for mode, wantErr := range map[string]bool{"": false, MatchExact: false, MatchCaseInsensitive: false, MatchNormalized: false, "fuzzy": true} {
+		cfg := &Config{MCPServers: []MCPServerConfig{{Name: "s", Address: "http://backend.example", MatchMode: mode}}}
+		if err := cfg.Validate(); (err != nil) != wantErr {
+			t.Errorf("match_mode '%s': unexpected validation result %v", mode, err)
+		}
+	}
+}
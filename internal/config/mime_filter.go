@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// Values of mime_type_fallback.
+const (
+	MimeTypeFallbackAllow    = "allow"
+	MimeTypeFallbackRestrict = "restrict"
+)
+
+// Filters reported in ResourceInfo.RestrictedBy.
+const (
+	FilterAllowedResources = "allowed_resources"
+	FilterDeniedMimeTypes  = "denied_mime_types"
+	FilterMimeTypeFallback = "mime_type_fallback"
+)
+
+// mimeFallback returns the effective mime_type_fallback: restrict when an allow-list is
+// set, allow otherwise.
+func (sc MCPServerConfig) mimeFallback() string {
+	if sc.MimeTypeFallback != "" {
+		return sc.MimeTypeFallback
+	}
+	if len(sc.AllowedMimeTypes) > 0 {
+		return MimeTypeFallbackRestrict
+	}
+	return MimeTypeFallbackAllow
+}
+
+// restrictedByMimeType returns the MIME type filter that hides a resource, or "" when it
+// is advertised. Denied types win over allowed ones; an empty type or one matched by
+// neither list is decided by mime_type_fallback.
+func (sc MCPServerConfig) restrictedByMimeType(mimeType string) string {
+	if len(sc.AllowedMimeTypes) == 0 && len(sc.DeniedMimeTypes) == 0 && sc.MimeTypeFallback == "" {
+		return ""
+	}
+	if mimeType != "" {
+		if matchMimeType(sc.DeniedMimeTypes, mimeType) {
+			return FilterDeniedMimeTypes
+		}
+		if matchMimeType(sc.AllowedMimeTypes, mimeType) {
+			return ""
+		}
+	}
+	if sc.mimeFallback() == MimeTypeFallbackRestrict {
+		return FilterMimeTypeFallback
+	}
+	return ""
+}
+
+// matchMimeType reports whether mimeType matches one of patterns. Patterns are exact
+// types or wildcards ("text/*", "*/*"); case and parameters such as charset are ignored.
+func matchMimeType(patterns []string, mimeType string) bool {
+	mediaType := normalizeMimeType(mimeType)
+	major, _, _ := strings.Cut(mediaType, "/")
+	for _, pattern := range patterns {
+		pattern = normalizeMimeType(pattern)
+		switch {
+		case pattern == "*/*", pattern == mediaType:
+			return true
+		case strings.HasSuffix(pattern, "/*") && strings.TrimSuffix(pattern, "/*") == major:
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeMimeType lowercases a MIME type and strips its parameters.
+func normalizeMimeType(mimeType string) string {
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		return mediaType
+	}
+	mediaType, _, _ := strings.Cut(mimeType, ";")
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+// validateMimeTypePatterns checks that every pattern has the form type/subtype.
+func validateMimeTypePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		major, minor, ok := strings.Cut(normalizeMimeType(pattern), "/")
+		if !ok || major == "" || minor == "" || (major == "*" && minor != "*") {
+			return fmt.Errorf("invalid MIME type '%s'", pattern)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mimeTestResources is the /resources body served to the MIME type filter tests.
+const mimeTestResources = `{"resources":[
+	{"name":"notes","mimeType":"text/plain"},
+	{"name":"readme","mimeType":"Text/Markdown; charset=utf-8"},
+	{"name":"logo","mimeType":"image/png"},
+	{"name":"blob","mimeType":"application/octet-stream"},
+	{"name":"untyped"}
+]}`
+
+// TestRefreshToolsAndResources_MimeTypeFilters tests advertising resources by MIME type,
+// including resources without one.
+func TestRefreshToolsAndResources_MimeTypeFilters(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         MCPServerConfig
+		wantAllowed    []string
+		wantRestricted map[string]string // Resource name to filter
+	}{
+		{
+			name:           "no filters",
+			wantAllowed:    []string{"notes", "readme", "logo", "blob", "untyped"},
+			wantRestricted: map[string]string{},
+		},
+		{
+			name:           "denied wildcard",
+			config:         MCPServerConfig{DeniedMimeTypes: []string{"image/*"}},
+			wantAllowed:    []string{"notes", "readme", "blob", "untyped"},
+			wantRestricted: map[string]string{"logo": FilterDeniedMimeTypes},
+		},
+		{
+			name:        "allowed text only",
+			config:      MCPServerConfig{AllowedMimeTypes: []string{"text/*"}},
+			wantAllowed: []string{"notes", "readme"},
+			wantRestricted: map[string]string{
+				"logo": FilterMimeTypeFallback, "blob": FilterMimeTypeFallback, "untyped": FilterMimeTypeFallback,
+			},
+		},
+		{
+			name:           "allowed with fallback allow",
+			config:         MCPServerConfig{AllowedMimeTypes: []string{"text/plain"}, DeniedMimeTypes: []string{"image/png"}, MimeTypeFallback: MimeTypeFallbackAllow},
+			wantAllowed:    []string{"notes", "readme", "blob", "untyped"},
+			wantRestricted: map[string]string{"logo": FilterDeniedMimeTypes},
+		},
+		{
+			name:           "allowed_resources applies first",
+			config:         MCPServerConfig{AllowedResources: []string{"notes", "logo"}, DeniedMimeTypes: []string{"image/png"}},
+			wantAllowed:    []string{"notes"},
+			wantRestricted: map[string]string{"readme": FilterAllowedResources, "logo": FilterDeniedMimeTypes, "blob": FilterAllowedResources, "untyped": FilterAllowedResources},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := tt.config
+			sc.Name, sc.Address = "mime-server", "http://mockserver"
+			server := &MCPServer{Config: sc}
+			server.httpClient = &http.Client{Transport: &mockRoundTripper{
+				roundTripFunc: func(req *http.Request) (*http.Response, error) {
+					body := `{"tools":[]}`
+					if strings.HasSuffix(req.URL.Path, "/resources") {
+						body = mimeTestResources
+					}
+					return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body))}, nil
+				},
+			}}
+			if err := server.refreshToolsAndResources(); err != nil {
+				t.Fatalf("refreshToolsAndResources failed: %v", err)
+			}
+
+			var allowed []string
+			for _, r := range server.GetResources() {
+				allowed = append(allowed, r.Name)
+			}
+			if !reflect.DeepEqual(allowed, tt.wantAllowed) {
+				t.Errorf("expected allowed %v, got %v", tt.wantAllowed, allowed)
+			}
+			restricted := map[string]string{}
+			for _, r := range server.GetRestrictedResources() {
+				restricted[r.Name] = r.RestrictedBy
+			}
+			if !reflect.DeepEqual(restricted, tt.wantRestricted) {
+				t.Errorf("expected restricted %v, got %v", tt.wantRestricted, restricted)
+			}
+		})
+	}
+}
+
+// TestValidate_MimeTypeFilters tests validation of the MIME type filter settings.
+func TestValidate_MimeTypeFilters(t *testing.T) {
+	for _, sc := range []MCPServerConfig{
+		{AllowedMimeTypes: []string{"text"}},
+		{DeniedMimeTypes: []string{"*/png"}},
+		{MimeTypeFallback: "pass"},
+	} {
+		sc.Name, sc.Address = "server1", "http://localhost:9000"
+		cfg := &Config{MCPServers: []MCPServerConfig{sc}}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("expected error for %+v, got nil", sc)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package config
+
+import "errors"
+
+// ProcessState is where a stdio server's process is in its lifecycle. Every start and
+// stop goes through these states under the server's stateMu, so that a restart after a
+// crash, a start on demand and a stop cannot overlap and launch a second process.
+//
+//	stopped -> starting -> ready -> restarting -> ready
+//	starting, ready, restarting -> stopping -> stopped
+//
+// A failed start or restart goes back to stopped.
+type ProcessState string
+
+// Values of ProcessState.
+const (
+	ProcessStopped    ProcessState = "stopped"
+	ProcessStarting   ProcessState = "starting"
+	ProcessReady      ProcessState = "ready"
+	ProcessRestarting ProcessState = "restarting"
+	ProcessStopping   ProcessState = "stopping"
+)
+
+// errProcessStopping is returned for a start requested while the process is stopping.
+var errProcessStopping = errors.New("MCP server process is stopping")
+
+// ProcessState returns the state of the stdio process, or "" for an HTTP server.
+func (s *MCPServer) ProcessState() ProcessState {
+	if s.Config.Command == "" {
+		return ""
+	}
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	return s.processStateLocked()
+}
+
+// transition moves the process from state from to state to, unless it has left from
+// meanwhile, e.g. to be stopped.
+func (s *MCPServer) transition(from, to ProcessState) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	if s.state == from {
+		s.state = to
+	}
+}
+
+// processStateLocked is ProcessState for callers holding stateMu. The zero state is
+// stopped.
+func (s *MCPServer) processStateLocked() ProcessState {
+	if s.state == "" {
+		return ProcessStopped
+	}
+	return s.state
+}
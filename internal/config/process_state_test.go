@@ -0,0 +1,123 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// markedProcesses counts the running processes whose arguments include marker.
+func markedProcesses(t *testing.T, marker string) int {
+	t.Helper()
+	cmdlines, err := filepath.Glob("/proc/[0-9]*/cmdline")
+	if err != nil || len(cmdlines) == 0 {
+		t.Skip("process listing needs /proc")
+	}
+	n := 0
+	for _, path := range cmdlines {
+		cmdline, err := os.ReadFile(path)
+		if err == nil && bytes.Contains(cmdline, []byte("\x00"+marker+"\x00")) {
+			n++
+		}
+	}
+	return n
+}
+
+// TestProcessState_ConcurrentStartStop hammers a stdio server with starts, stops and
+// crashes from several goroutines and checks that it never runs two processes: each
+// would outlive Shutdown, as only the last one started is stopped.
+func TestProcessState_ConcurrentStartStop(t *testing.T) {
+	origBackoff := restartBackoff
+	restartBackoff = time.Millisecond
+	defer func() { restartBackoff = origBackoff }()
+
+	marker := fmt.Sprintf("0.%d", os.Getpid())
+	server := &MCPServer{Config: MCPServerConfig{Name: "hammered", Command: "sleep", Args: []string{"30", marker}}}
+	if got := server.ProcessState(); got != ProcessStopped {
+		t.Fatalf("initial state = %q, want %q", got, ProcessStopped)
+	}
+
+	var wg sync.WaitGroup
+	run := func(n int, f func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				f()
+			}
+		}()
+	}
+	for g := 0; g < 4; g++ {
+		run(50, func() {
+			if err := server.startStdioProcess(); err != nil && !errors.Is(err, errProcessStopping) {
+				t.Errorf("startStdioProcess failed: %v", err)
+			}
+		})
+	}
+	for g := 0; g < 2; g++ {
+		run(25, func() {
+			server.stopProcess()
+			time.Sleep(time.Millisecond)
+		})
+	}
+	run(50, func() {
+		if server.ProcessState() == ProcessReady {
+			server.mu.Lock()
+			if server.cmd != nil && server.cmd.Process != nil {
+				server.cmd.Process.Kill()
+			}
+			server.mu.Unlock()
+		}
+		time.Sleep(time.Millisecond)
+	})
+	wg.Wait()
+
+	if err := server.Shutdown(); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if got := server.ProcessState(); got != ProcessStopped {
+		t.Errorf("state after Shutdown = %q, want %q", got, ProcessStopped)
+	}
+	waitFor(t, 5*time.Second, func() bool { return markedProcesses(t, marker) == 0 })
+}
+
+// TestProcessState_StopDuringRequest tests that stopping a stdio server does not wait
+// for a request the process never answers.
+func TestProcessState_StopDuringRequest(t *testing.T) {
+	server := &MCPServer{Config: MCPServerConfig{Name: "silent", Command: "sh", Args: []string{"-c", "while read line; do :; done"}}}
+	if err := server.startStdioProcess(); err != nil {
+		t.Fatalf("startStdioProcess failed: %v", err)
+	}
+	requested := make(chan error, 1)
+	go func() {
+		_, err := server.HandleStdioRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"hang"}}`))
+		requested <- err
+	}()
+	// The request holds mu until it is answered
+	waitFor(t, 2*time.Second, func() bool {
+		if server.mu.TryLock() {
+			server.mu.Unlock()
+			return false
+		}
+		return true
+	})
+
+	stopped := make(chan struct{})
+	go func() {
+		server.Shutdown()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Shutdown waited for the in-flight request")
+	}
+	if err := <-requested; err == nil {
+		t.Error("expected the in-flight request to fail")
+	}
+}
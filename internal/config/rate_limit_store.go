@@ -0,0 +1,114 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Stores holding the token buckets of tool_rate_limits.
+const (
+	RateLimitStoreMemory = "memory" // Each replica keeps its own buckets
+	RateLimitStoreRedis  = "redis"  // Replicas share buckets in Redis
+)
+
+// Policies for calls to rate-limited tools while the rate limit store is unreachable.
+const (
+	RateLimitStoreFailOpen   = "open"   // Limit calls with this replica's in-memory buckets
+	RateLimitStoreFailClosed = "closed" // Reject the calls as overloaded
+)
+
+// Rate limit store defaults applied when the corresponding field is zero.
+const (
+	DefaultRateLimitStoreTimeout   = 100 * time.Millisecond
+	DefaultRateLimitStoreKeyPrefix = "smart-mcp-proxy:"
+	DefaultRateLimitStoreMaxConns  = 32
+)
+
+// RateLimitStoreConfig selects where the token buckets of tool_rate_limits are kept.
+// With several replicas behind a load balancer, in-memory buckets let each replica
+// allow the full rate; a Redis store makes the limits apply across all of them.
+type RateLimitStoreConfig struct {
+	Type      string `json:"type,omitempty"`       // RateLimitStoreMemory (default) or RateLimitStoreRedis
+	Address   string `json:"address,omitempty"`    // Redis host:port
+	Username  string `json:"username,omitempty"`   // Redis ACL user; empty authenticates with Password only
+	Password  string `json:"password,omitempty"`   // Sent with AUTH when set
+	DB        int    `json:"db,omitempty"`         // Database selected with SELECT when not 0
+	TLS       bool   `json:"tls,omitempty"`        // Connect with TLS, verified against the system roots
+	KeyPrefix string `json:"key_prefix,omitempty"` // Prefix of the bucket keys
+	Timeout   string `json:"timeout,omitempty"`    // Deadline of each Redis round trip, e.g. "100ms"
+	OnFailure string `json:"on_failure,omitempty"` // RateLimitStoreFailOpen (default) or RateLimitStoreFailClosed
+
+	MaxConnections int `json:"max_connections,omitempty"` // Open Redis connections at most; 0 uses DefaultRateLimitStoreMaxConns
+}
+
+// TypeOrDefault returns Type, or RateLimitStoreMemory when unset.
+func (r RateLimitStoreConfig) TypeOrDefault() string {
+	if r.Type == "" {
+		return RateLimitStoreMemory
+	}
+	return r.Type
+}
+
+// KeyPrefixOrDefault returns KeyPrefix, or DefaultRateLimitStoreKeyPrefix when unset.
+func (r RateLimitStoreConfig) KeyPrefixOrDefault() string {
+	if r.KeyPrefix == "" {
+		return DefaultRateLimitStoreKeyPrefix
+	}
+	return r.KeyPrefix
+}
+
+// MaxConnectionsOrDefault returns MaxConnections, or DefaultRateLimitStoreMaxConns when
+// unset.
+func (r RateLimitStoreConfig) MaxConnectionsOrDefault() int {
+	if r.MaxConnections == 0 {
+		return DefaultRateLimitStoreMaxConns
+	}
+	return r.MaxConnections
+}
+
+// TimeoutDuration parses Timeout, defaulting to DefaultRateLimitStoreTimeout.
+func (r RateLimitStoreConfig) TimeoutDuration() (time.Duration, error) {
+	if r.Timeout == "" {
+		return DefaultRateLimitStoreTimeout, nil
+	}
+	return time.ParseDuration(r.Timeout)
+}
+
+// OnFailureOrDefault returns OnFailure, or RateLimitStoreFailOpen when unset.
+func (r RateLimitStoreConfig) OnFailureOrDefault() string {
+	if r.OnFailure == "" {
+		return RateLimitStoreFailOpen
+	}
+	return r.OnFailure
+}
+
+// validate checks the store type, the Redis settings and the failure policy.
+func (r RateLimitStoreConfig) validate() error {
+	switch r.TypeOrDefault() {
+	case RateLimitStoreMemory:
+		return nil
+	case RateLimitStoreRedis:
+	default:
+		return fmt.Errorf("invalid type '%s': must be '%s' or '%s'", r.Type, RateLimitStoreMemory, RateLimitStoreRedis)
+	}
+	if r.Address == "" {
+		return errors.New("address is required for a redis store")
+	}
+	if r.DB < 0 {
+		return errors.New("db must not be negative")
+	}
+	if r.Username != "" && r.Password == "" {
+		return errors.New("password is required with username")
+	}
+	if r.MaxConnections < 0 {
+		return errors.New("max_connections must not be negative")
+	}
+	if d, err := r.TimeoutDuration(); err != nil || d <= 0 {
+		return fmt.Errorf("invalid timeout '%s'", r.Timeout)
+	}
+	if f := r.OnFailureOrDefault(); f != RateLimitStoreFailOpen && f != RateLimitStoreFailClosed {
+		return fmt.Errorf("invalid on_failure '%s': must be '%s' or '%s'", r.OnFailure, RateLimitStoreFailOpen, RateLimitStoreFailClosed)
+	}
+	return nil
+}
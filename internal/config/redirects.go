@@ -0,0 +1,51 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// DefaultMaxRedirects is the number of redirects followed when max_redirects is not set.
+const DefaultMaxRedirects = 10
+
+// ErrRedirectNotFollowed is returned by requests to an HTTP server that redirected to
+// another host without allow_cross_host_redirects, or more than max_redirects times.
+var ErrRedirectNotFollowed = errors.New("redirect not followed")
+
+// FollowsRedirects reports whether redirects from the server are followed. They are
+// unless follow_redirects is explicitly false.
+func (sc MCPServerConfig) FollowsRedirects() bool {
+	return sc.FollowRedirects == nil || *sc.FollowRedirects
+}
+
+// MaxRedirectsOrDefault returns MaxRedirects, or DefaultMaxRedirects when unset.
+func (sc MCPServerConfig) MaxRedirectsOrDefault() int {
+	if sc.MaxRedirects <= 0 {
+		return DefaultMaxRedirects
+	}
+	return sc.MaxRedirects
+}
+
+// CheckRedirect is the http.Client redirect policy of the server. Without
+// follow_redirects the redirect response itself is returned; otherwise redirects to
+// another host (or port) than the original request's fail unless
+// allow_cross_host_redirects is set, as do redirects beyond max_redirects.
+func (sc MCPServerConfig) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if !sc.FollowsRedirects() {
+		return http.ErrUseLastResponse
+	}
+	if max := sc.MaxRedirectsOrDefault(); len(via) > max {
+		return fmt.Errorf("%w: stopped after %d redirects", ErrRedirectNotFollowed, max)
+	}
+	if origin := via[0].URL.Host; req.URL.Host != origin && !sc.AllowCrossHostRedirects {
+		return fmt.Errorf("%w: redirect from %s to another host %s", ErrRedirectNotFollowed, origin, req.URL.Host)
+	}
+	return nil
+}
+
+// Client returns an HTTP client for requests to the server, with its transport and
+// redirect policy and no timeout of its own.
+func (s *MCPServer) Client() *http.Client {
+	return &http.Client{Transport: s.Transport(), CheckRedirect: s.Config.CheckRedirect}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMaxConcurrentRestarts is used when max_concurrent_restarts is not set.
+const DefaultMaxConcurrentRestarts = 3
+
+// restartBackoff is the minimum pause before a crashed stdio server is restarted.
+// A random jitter of up to the same amount is added so simultaneous crashes spread out.
+var restartBackoff = 3 * time.Second
+
+// queuedRestarts counts restarts waiting for a slot across all limiters.
+var queuedRestarts atomic.Int64
+
+// QueuedRestarts returns the number of stdio server restarts waiting for a restart slot.
+func QueuedRestarts() int64 {
+	return queuedRestarts.Load()
+}
+
+// restartLimiter caps how many stdio servers are restarted at the same time, so a
+// host-wide failure (e.g. the Docker daemon restarting) does not cause a restart stampede.
+type restartLimiter struct {
+	slots chan struct{}
+}
+
+// newRestartLimiter returns a limiter allowing max concurrent restarts, or
+// DefaultMaxConcurrentRestarts when max is zero.
+func newRestartLimiter(max int) *restartLimiter {
+	if max <= 0 {
+		max = DefaultMaxConcurrentRestarts
+	}
+	return &restartLimiter{slots: make(chan struct{}, max)}
+}
+
+// acquire waits for a restart slot. It returns ctx's error if ctx is done first,
+// which lets shutdown interrupt queued restarts immediately.
+func (l *restartLimiter) acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	queuedRestarts.Add(1)
+	defer queuedRestarts.Add(-1)
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees a slot taken by acquire.
+func (l *restartLimiter) release() {
+	<-l.slots
+}
+
+// jitteredRestartBackoff returns restartBackoff plus a random jitter of up to restartBackoff.
+func jitteredRestartBackoff() time.Duration {
+	if restartBackoff <= 0 {
+		return 0
+	}
+	return restartBackoff + time.Duration(rand.Int63n(int64(restartBackoff)))
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds or the timeout elapses.
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before timeout")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestRestartLimiter tests that restarts beyond the limit are queued and that a
+// cancelled context releases a queued restart.
+func TestRestartLimiter(t *testing.T) {
+	limiter := newRestartLimiter(2)
+	for i := 0; i < 2; i++ {
+		if err := limiter.acquire(context.Background()); err != nil {
+			t.Fatalf("acquire %d failed: %v", i, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- limiter.acquire(ctx) }()
+	waitFor(t, time.Second, func() bool { return QueuedRestarts() == 1 })
+
+	cancel()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected queued acquire to fail after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queued acquire was not interrupted")
+	}
+	if got := QueuedRestarts(); got != 0 {
+		t.Errorf("QueuedRestarts() = %d after cancel, want 0", got)
+	}
+
+	limiter.release()
+	if err := limiter.acquire(context.Background()); err != nil {
+		t.Errorf("acquire after release failed: %v", err)
+	}
+}
+
+// TestMonitorProcess_ShutdownInterruptsQueuedRestart tests that Shutdown does not wait
+// for a restart slot held by another server.
+func TestMonitorProcess_ShutdownInterruptsQueuedRestart(t *testing.T) {
+	origBackoff := restartBackoff
+	restartBackoff = 10 * time.Millisecond
+	defer func() { restartBackoff = origBackoff }()
+
+	limiter := newRestartLimiter(1)
+	if err := limiter.acquire(context.Background()); err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+	defer limiter.release()
+
+	server := &MCPServer{
+		Config:         MCPServerConfig{Name: "crashing-server", Command: "true"},
+		restartLimiter: limiter,
+	}
+	if err := server.startStdioProcess(); err != nil {
+		t.Fatalf("startStdioProcess failed: %v", err)
+	}
+	waitFor(t, 2*time.Second, func() bool { return QueuedRestarts() == 1 })
+
+	start := time.Now()
+	if err := server.Shutdown(); err != nil {
+		t.Errorf("Shutdown failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Shutdown took %v with a queued restart", elapsed)
+	}
+	if got := server.Restarts(); got != 0 {
+		t.Errorf("Restarts() = %d, want 0", got)
+	}
+	if got := QueuedRestarts(); got != 0 {
+		t.Errorf("QueuedRestarts() = %d after shutdown, want 0", got)
+	}
+}
@@ -0,0 +1,49 @@
+package config
+
+// Filters reported in ToolInfo.RestrictedBy.
+const (
+	FilterAllowedTools  = "allowed_tools"
+	FilterStrictSchemas = "strict_schemas"
+	FilterChainedProxy  = "chained_proxy" // Also reported in ResourceInfo.RestrictedBy
+)
+
+// Machine-readable reasons a tool or resource is restricted, as reported by the
+// restricted listings.
+const (
+	RestrictedByServerAllowlist = "server_allowlist" // Not matched by the server's allowed_tools, allowed_resources or allowed_mime_types
+	RestrictedByServerDenylist  = "server_denylist"  // Matched by the server's denied_mime_types
+	RestrictedBySchemaRule      = "schema_rule"      // Its inputSchema is invalid and strict_schemas is set
+	RestrictedByChainedProxy    = "chained_proxy"    // Listed as restricted by a chained smart-mcp-proxy
+)
+
+// RestrictionReason returns the reason for a restriction by the named filter, or ""
+// for an unknown filter.
+func RestrictionReason(filter string) string {
+	switch filter {
+	case FilterAllowedTools, FilterAllowedResources, FilterMimeTypeFallback:
+		return RestrictedByServerAllowlist
+	case FilterDeniedMimeTypes:
+		return RestrictedByServerDenylist
+	case FilterStrictSchemas:
+		return RestrictedBySchemaRule
+	case FilterChainedProxy:
+		return RestrictedByChainedProxy
+	}
+	return ""
+}
+
+// Values of restricted_status: how calls to restricted tools and requests for
+// restricted resources are answered.
+const (
+	RestrictedStatusForbidden = "forbidden" // 403, revealing that the name exists
+	RestrictedStatusNotFound  = "not_found" // 404, as for a name no server provides
+)
+
+// RestrictedStatusOrDefault returns RestrictedStatus, or RestrictedStatusForbidden when
+// unset.
+func (c *Config) RestrictedStatusOrDefault() string {
+	if c.RestrictedStatus == "" {
+		return RestrictedStatusForbidden
+	}
+	return c.RestrictedStatus
+}
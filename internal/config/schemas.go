@@ -0,0 +1,126 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+
+	"smart-mcp-proxy/internal/jsonschema"
+)
+
+// Problems recorded in ToolInfo.SchemaIssue for input schemas that had to be replaced.
+const (
+	SchemaMissing   = "missing"
+	SchemaNull      = "null"
+	SchemaNotObject = "not an object"
+)
+
+// discoveredTool is a ToolInfo as listed by a backend. It is a separate type so the
+// lenient decoding below does not apply to types embedding ToolInfo.
+type discoveredTool ToolInfo
+
+// toToolInfos converts discovered tools to ToolInfo.
+func toToolInfos(tools []discoveredTool) []ToolInfo {
+	if tools == nil {
+		return nil
+	}
+	infos := make([]ToolInfo, len(tools))
+	for i, tool := range tools {
+		infos[i] = ToolInfo(tool)
+	}
+	return infos
+}
+
+// UnmarshalJSON decodes a tool and tolerates an inputSchema that is missing, null or
+// not a JSON object. Such schemas leave InputSchema nil and set SchemaIssue instead of
+// failing discovery for the whole server.
+func (t *discoveredTool) UnmarshalJSON(data []byte) error {
+	type toolInfoAlias ToolInfo
+	var raw struct {
+		toolInfoAlias
+		InputSchema json.RawMessage `json:"inputSchema"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*t = discoveredTool(raw.toolInfoAlias)
+	t.InputSchema = nil
+	t.SchemaIssue = ""
+
+	schema := bytes.TrimSpace(raw.InputSchema)
+	switch {
+	case len(schema) == 0:
+		t.SchemaIssue = SchemaMissing
+	case bytes.Equal(schema, []byte("null")):
+		t.SchemaIssue = SchemaNull
+	case schema[0] != '{':
+		t.SchemaIssue = SchemaNotObject
+	default:
+		return json.Unmarshal(schema, &t.InputSchema)
+	}
+	return nil
+}
+
+// normalizeToolSchema replaces an unusable input schema with an empty object schema,
+// so clients always receive {"type":"object"} at minimum.
+func normalizeToolSchema(tool *ToolInfo) {
+	if tool.InputSchema == nil {
+		tool.InputSchema = map[string]interface{}{"type": "object"}
+		if tool.SchemaIssue == "" {
+			tool.SchemaIssue = SchemaMissing
+		}
+	}
+}
+
+// logSchemaIssue reports a tool whose input schema was replaced.
+func (s *MCPServer) logSchemaIssue(tool ToolInfo) {
+	action := "advertising it with {\"type\":\"object\"}"
+	if s.Config.StrictSchemas {
+		action = "restricting it (strict_schemas)"
+	}
+	log.Printf("MCP server %s: tool '%s' has a %s inputSchema; %s", s.Config.Name, tool.Name, tool.SchemaIssue, action)
+}
+
+// SchemaIssues returns the tools whose input schema was missing, null or not an
+// object at the last discovery, mapped to the problem found.
+func (s *MCPServer) SchemaIssues() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.schemaIssues) == 0 {
+		return nil
+	}
+	issues := make(map[string]string, len(s.schemaIssues))
+	for name, issue := range s.schemaIssues {
+		issues[name] = issue
+	}
+	return issues
+}
+
+// compileOutputSchemas compiles the outputSchema of each tool that declares one. An
+// invalid schema is logged and skipped, so results of that tool are not validated.
+func (s *MCPServer) compileOutputSchemas(tools []ToolInfo) map[string]*jsonschema.Schema {
+	var schemas map[string]*jsonschema.Schema
+	for _, tool := range tools {
+		if tool.OutputSchema == nil {
+			continue
+		}
+		schema, err := jsonschema.Compile(tool.OutputSchema)
+		if err != nil {
+			log.Printf("MCP server %s: tool '%s' has an invalid outputSchema, results are not validated: %v", s.Config.Name, tool.Name, err)
+			continue
+		}
+		if schemas == nil {
+			schemas = make(map[string]*jsonschema.Schema)
+		}
+		schemas[tool.Name] = schema
+	}
+	return schemas
+}
+
+// OutputSchema returns the compiled outputSchema of a tool from the last discovery, or
+// nil if the tool declares none.
+func (s *MCPServer) OutputSchema(toolName string) *jsonschema.Schema {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.outputSchemas[toolName]
+}
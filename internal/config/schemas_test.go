@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// schemaTestServer returns an HTTP MCPServer whose /tools lists a valid tool and tools
+// with null, missing and non-object input schemas.
+func schemaTestServer(strict bool) *MCPServer {
+	server := &MCPServer{Config: MCPServerConfig{Name: "schema-server", Address: "http://mockserver", StrictSchemas: strict}}
+	server.httpClient = &http.Client{
+		Transport: &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				var body string
+				switch {
+				case strings.HasSuffix(req.URL.Path, "/tools"):
+					body = `{"tools":[
+						{"name":"valid","inputSchema":{"type":"object","properties":{"q":{"type":"string"}}}},
+						{"name":"null-schema","inputSchema":null},
+						{"name":"missing-schema"},
+						{"name":"string-schema","inputSchema":"object"},
+						{"name":"array-schema","inputSchema":[]}
+					]}`
+				case strings.HasSuffix(req.URL.Path, "/resources"):
+					body = `{"resources":[]}`
+				default:
+					return nil, fmt.Errorf("unexpected URL: %s", req.URL)
+				}
+				return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body))}, nil
+			},
+		},
+	}
+	return server
+}
+
+// wantSchemaIssues are the problems expected for the tools served by schemaTestServer.
+var wantSchemaIssues = map[string]string{
+	"null-schema":    SchemaNull,
+	"missing-schema": SchemaMissing,
+	"string-schema":  SchemaNotObject,
+	"array-schema":   SchemaNotObject,
+}
+
+// TestRefreshToolsAndResources_NormalizesSchemas tests that unusable input schemas are
+// replaced with an empty object schema and reported.
+func TestRefreshToolsAndResources_NormalizesSchemas(t *testing.T) {
+	server := schemaTestServer(false)
+	if err := server.refreshToolsAndResources(); err != nil {
+		t.Fatalf("refreshToolsAndResources failed: %v", err)
+	}
+
+	tools := server.GetTools()
+	if len(tools) != 5 {
+		t.Fatalf("expected 5 tools, got %+v", tools)
+	}
+	for _, tool := range tools {
+		if tool.InputSchema["type"] != "object" {
+			t.Errorf("tool %s: expected an object schema, got %v", tool.Name, tool.InputSchema)
+		}
+		if tool.SchemaIssue != wantSchemaIssues[tool.Name] {
+			t.Errorf("tool %s: expected issue %q, got %q", tool.Name, wantSchemaIssues[tool.Name], tool.SchemaIssue)
+		}
+	}
+	if tools[0].InputSchema["properties"] == nil {
+		t.Errorf("valid schema should be kept as is, got %v", tools[0].InputSchema)
+	}
+	if got := server.SchemaIssues(); !reflect.DeepEqual(got, wantSchemaIssues) {
+		t.Errorf("expected schema issues %v, got %v", wantSchemaIssues, got)
+	}
+}
+
+// TestRefreshToolsAndResources_StrictSchemas tests that strict_schemas restricts tools
+// with unusable input schemas.
+func TestRefreshToolsAndResources_StrictSchemas(t *testing.T) {
+	server := schemaTestServer(true)
+	if err := server.refreshToolsAndResources(); err != nil {
+		t.Fatalf("refreshToolsAndResources failed: %v", err)
+	}
+
+	if tools := server.GetTools(); len(tools) != 1 || tools[0].Name != "valid" {
+		t.Errorf("expected only the valid tool to be advertised, got %+v", tools)
+	}
+	restricted := server.GetRestrictedTools()
+	if len(restricted) != len(wantSchemaIssues) {
+		t.Fatalf("expected %d restricted tools, got %+v", len(wantSchemaIssues), restricted)
+	}
+	for _, tool := range restricted {
+		if _, ok := wantSchemaIssues[tool.Name]; !ok {
+			t.Errorf("unexpected restricted tool %s", tool.Name)
+		}
+	}
+	if got := server.SchemaIssues(); !reflect.DeepEqual(got, wantSchemaIssues) {
+		t.Errorf("expected schema issues %v, got %v", wantSchemaIssues, got)
+	}
+}
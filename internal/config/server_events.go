@@ -0,0 +1,58 @@
+package config
+
+import (
+	"reflect"
+	"slices"
+)
+
+// EventKind names a change in the state of an MCP server.
+type EventKind string
+
+// Server event kinds passed to a ServerEventHandler.
+const (
+	EventBackendDown      EventKind = "backend_down"      // The stdio process exited unexpectedly, or could not be restarted
+	EventBackendUp        EventKind = "backend_up"        // The stdio process was restarted after exiting
+	EventToolsetChanged   EventKind = "toolset_changed"   // Discovery changed the server's allowed tools
+	EventResourcesChanged EventKind = "resources_changed" // Discovery changed the server's allowed resources
+	EventRefreshFailed    EventKind = "refresh_failed"    // Discovery of tools and resources failed
+	EventUnhealthy        EventKind = "unhealthy"         // A health check or discovery failed after succeeding
+	EventHealthy          EventKind = "healthy"           // A health check or discovery succeeded after failing
+)
+
+// ServerEvent describes a change in the state of an MCP server.
+type ServerEvent struct {
+	Kind   EventKind
+	Server string
+	Err    error // Cause of backend_down, refresh_failed and unhealthy events
+}
+
+// ServerEventHandler receives the events of an MCP server. It is called synchronously
+// from the goroutine that observed the change and must not block.
+type ServerEventHandler func(ServerEvent)
+
+// SetEventHandler installs the handler receiving the server's events from now on.
+func (s *MCPServer) SetEventHandler(h ServerEventHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.eventHandler = h
+}
+
+// emit passes an event to the handler, if any. Callers must not hold s.mu.
+func (s *MCPServer) emit(kind EventKind, err error) {
+	s.mu.Lock()
+	h := s.eventHandler
+	s.mu.Unlock()
+	if h != nil {
+		h(ServerEvent{Kind: kind, Server: s.Config.Name, Err: err})
+	}
+}
+
+// toolsetChanged reports whether discovery produced different allowed tools.
+func toolsetChanged(old, tools []ToolInfo) bool {
+	return !slices.EqualFunc(old, tools, func(a, b ToolInfo) bool { return reflect.DeepEqual(a, b) })
+}
+
+// resourcesChanged reports whether discovery produced different allowed resources.
+func resourcesChanged(old, resources []ResourceInfo) bool {
+	return !slices.EqualFunc(old, resources, func(a, b ResourceInfo) bool { return reflect.DeepEqual(a, b) })
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// eventRecorder collects the kinds of the events passed to its handler.
+type eventRecorder struct {
+	mu    sync.Mutex
+	kinds []EventKind
+}
+
+func (r *eventRecorder) handle(e ServerEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.kinds = append(r.kinds, e.Kind)
+}
+
+func (r *eventRecorder) recorded() []EventKind {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.kinds)
+}
+
+// TestServerEvents_Restart tests that a crashed stdio server reports going down and
+// coming back up, and that rediscovering the same tools is not a toolset change.
+func TestServerEvents_Restart(t *testing.T) {
+	origBackoff := restartBackoff
+	restartBackoff = 10 * time.Millisecond
+	defer func() { restartBackoff = origBackoff }()
+
+	servers, err := NewMCPServers(&Config{MCPServers: []MCPServerConfig{testStdioServerConfig("crashy")}})
+	if err != nil {
+		t.Fatalf("NewMCPServers failed: %v", err)
+	}
+	server := servers[0]
+	defer server.Shutdown()
+	var rec eventRecorder
+	server.SetEventHandler(rec.handle)
+
+	server.mu.Lock()
+	process := server.cmd.Process
+	server.mu.Unlock()
+	if err := process.Kill(); err != nil {
+		t.Fatalf("failed to kill process: %v", err)
+	}
+	waitFor(t, 5*time.Second, func() bool { return server.Restarts() == 1 && !server.IsRestarting() })
+
+	if got, want := rec.recorded(), []EventKind{EventBackendDown, EventBackendUp}; !slices.Equal(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+// TestServerEvents_ToolsetChanged tests that only discovery results that change the
+// allowed tools are reported.
+func TestServerEvents_ToolsetChanged(t *testing.T) {
+	server := &MCPServer{Config: MCPServerConfig{Name: "s", AllowedTools: []string{"a", "b"}}}
+	var rec eventRecorder
+	server.SetEventHandler(rec.handle)
+
+	server.applyDiscovered([]ToolInfo{{Name: "a"}}, nil)
+	server.applyDiscovered([]ToolInfo{{Name: "a"}, {Name: "hidden"}}, nil) // Only a restricted tool added
+	server.applyDiscovered([]ToolInfo{{Name: "a"}, {Name: "b"}}, nil)
+
+	if got, want := rec.recorded(), []EventKind{EventToolsetChanged, EventToolsetChanged}; !slices.Equal(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+// TestServerEvents_ResourcesChanged tests that discovery results that change the
+// allowed resources are reported apart from tool changes.
+func TestServerEvents_ResourcesChanged(t *testing.T) {
+	server := &MCPServer{Config: MCPServerConfig{Name: "s", AllowedResources: []string{"a", "b"}}}
+	var rec eventRecorder
+	server.SetEventHandler(rec.handle)
+
+	server.applyDiscovered(nil, []ResourceInfo{{Name: "a"}})
+	server.applyDiscovered(nil, []ResourceInfo{{Name: "a"}, {Name: "hidden"}}) // Only a restricted resource added
+	server.applyDiscovered([]ToolInfo{{Name: "t"}}, []ResourceInfo{{Name: "a"}, {Name: "b"}})
+
+	if got, want := rec.recorded(), []EventKind{EventResourcesChanged, EventToolsetChanged, EventResourcesChanged}; !slices.Equal(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
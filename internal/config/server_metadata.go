@@ -0,0 +1,224 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"reflect"
+)
+
+// initializeProtocolVersion is the MCP protocol version sent in initialize requests.
+const initializeProtocolVersion = "2025-06-18"
+
+// DisplayConfig is how a server is presented by UIs listing its tools. Each field
+// overrides what the server reports about itself in its initialize result.
+type DisplayConfig struct {
+	Title   string `json:"title,omitempty"`
+	IconURL string `json:"icon_url,omitempty"` // http, https or data URL
+	Color   string `json:"color,omitempty"`    // Any CSS color, e.g. "#1f6feb"
+}
+
+// validate checks the icon URL.
+func (d *DisplayConfig) validate() error {
+	if d.IconURL == "" {
+		return nil
+	}
+	u, err := url.Parse(d.IconURL)
+	if err != nil {
+		return fmt.Errorf("invalid display.icon_url: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "data":
+		return nil
+	default:
+		return fmt.Errorf("display.icon_url has unsupported scheme '%s'", u.Scheme)
+	}
+}
+
+// ServerIcon is an icon listed in a server's initialize result.
+type ServerIcon struct {
+	Src      string   `json:"src"`
+	MimeType string   `json:"mimeType,omitempty"`
+	Sizes    []string `json:"sizes,omitempty"`
+}
+
+// ServerInfo is the serverInfo of a backend's initialize result.
+type ServerInfo struct {
+	Name       string       `json:"name,omitempty"`
+	Title      string       `json:"title,omitempty"`
+	Version    string       `json:"version,omitempty"`
+	WebsiteURL string       `json:"websiteUrl,omitempty"`
+	Icons      []ServerIcon `json:"icons,omitempty"`
+}
+
+// ServerMetadata describes a server for display next to its tools and resources.
+// Every field except Name is optional and omitted when unknown.
+type ServerMetadata struct {
+	Name       string       `json:"name"` // Configured server name
+	Title      string       `json:"title,omitempty"`
+	Version    string       `json:"version,omitempty"`
+	WebsiteURL string       `json:"websiteUrl,omitempty"`
+	IconURL    string       `json:"iconUrl,omitempty"`
+	Icons      []ServerIcon `json:"icons,omitempty"`
+	Color      string       `json:"color,omitempty"`
+}
+
+// Metadata returns the server's display metadata, combining its display config with
+// the serverInfo of its last initialize result. It is nil when neither provides
+// anything.
+func (s *MCPServer) Metadata() *ServerMetadata {
+	s.mu.Lock()
+	info := s.serverInfo
+	s.mu.Unlock()
+	display := s.Config.Display
+	if info == nil && display == nil {
+		return nil
+	}
+
+	meta := &ServerMetadata{Name: s.Config.Name}
+	if info != nil {
+		meta.Title = info.Title
+		meta.Version = info.Version
+		meta.WebsiteURL = info.WebsiteURL
+		meta.Icons = info.Icons
+		if len(info.Icons) > 0 {
+			meta.IconURL = info.Icons[0].Src
+		}
+	}
+	if display != nil {
+		if display.Title != "" {
+			meta.Title = display.Title
+		}
+		if display.IconURL != "" {
+			meta.IconURL = display.IconURL
+		}
+		meta.Color = display.Color
+	}
+	if meta.Title == "" && meta.Version == "" && meta.WebsiteURL == "" && meta.IconURL == "" && meta.Color == "" {
+		return nil
+	}
+	return meta
+}
+
+// initializeResult is the part of a backend's initialize result the proxy keeps.
+type initializeResult struct {
+	ServerInfo   *ServerInfo            `json:"serverInfo"`
+	Capabilities map[string]interface{} `json:"capabilities"`
+}
+
+// initializeParams are the params of the initialize request sent to backends.
+func initializeParams() map[string]interface{} {
+	return map[string]interface{}{
+		"protocolVersion": initializeProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]interface{}{"name": "smart-mcp-proxy", "version": "1.0"},
+	}
+}
+
+// needsServerInfo reports whether initialize has not been sent to the current process
+// or HTTP server yet.
+func (s *MCPServer) needsServerInfo() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.initializeSent
+}
+
+// setServerInfo records the outcome of an initialize request. A failed request keeps
+// the previous serverInfo and capabilities. Clients keep the capabilities advertised
+// when they initialized, so a later change is announced as changed tool and resource
+// lists for them to list again.
+func (s *MCPServer) setServerInfo(info *ServerInfo, capabilities map[string]interface{}, err error) {
+	s.mu.Lock()
+	s.initializeSent = true
+	if err != nil {
+		s.mu.Unlock()
+		log.Printf("MCP server %s: initialize failed, server metadata unavailable: %v", s.Config.Name, err)
+		return
+	}
+	if info != nil {
+		s.serverInfo = info
+	}
+	if capabilities == nil {
+		capabilities = map[string]interface{}{}
+	}
+	changed := s.capabilities != nil && !reflect.DeepEqual(s.capabilities, capabilities)
+	s.capabilities = capabilities
+	s.mu.Unlock()
+	if changed {
+		log.Printf("MCP server %s changed its capabilities", s.Config.Name)
+		s.emit(EventToolsetChanged, nil)
+		s.emit(EventResourcesChanged, nil)
+	}
+}
+
+// initializedNotification is the notification that completes the initialize handshake.
+var initializedNotification = []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+
+// initializeStdio performs the initialize handshake with a stdio server once per
+// process, before any other request, and keeps its serverInfo and capabilities. A
+// failed initialize is logged and otherwise ignored, as some servers answer list
+// requests without it; notifications/initialized is only sent after a successful one.
+func (s *MCPServer) initializeStdio(ctx context.Context) {
+	if !s.needsServerInfo() || ctx.Err() != nil {
+		return
+	}
+	reqBytes, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      s.NextRPCID(),
+		"method":  "initialize",
+		"params":  initializeParams(),
+	})
+	if err != nil {
+		s.setServerInfo(nil, nil, err)
+		return
+	}
+	respBytes, err := s.HandleStdioRequest(reqBytes)
+	if err != nil {
+		s.setServerInfo(nil, nil, err)
+		return
+	}
+	var resp struct {
+		Result initializeResult `json:"result"`
+		Error  interface{}      `json:"error"`
+	}
+	if err := json.Unmarshal(respBytes, &resp); err != nil {
+		s.setServerInfo(nil, nil, err)
+		return
+	}
+	if resp.Error != nil {
+		s.setServerInfo(nil, nil, stdioResponseError(resp.Error, respBytes))
+		return
+	}
+	s.setServerInfo(resp.Result.ServerInfo, resp.Result.Capabilities, nil)
+	if err := s.NotifyStdio(initializedNotification); err != nil {
+		log.Printf("MCP server %s: failed to send notifications/initialized: %v", s.Config.Name, err)
+	}
+}
+
+// initializeJSONRPC performs the initialize handshake with a JSON-RPC HTTP server once,
+// before any other request, and keeps its serverInfo and capabilities. A JSON-RPC error
+// is logged and otherwise ignored, like for stdio servers. Any other failure means the
+// server does not answer JSON-RPC at all and is returned, without marking the
+// handshake as done.
+func (s *MCPServer) initializeJSONRPC(ctx context.Context) error {
+	if !s.needsServerInfo() {
+		return nil
+	}
+	var result initializeResult
+	err := s.CallJSONRPC(ctx, "initialize", initializeParams(), &result)
+	var rpcErr *JSONRPCError
+	if err != nil && !errors.As(err, &rpcErr) {
+		return err
+	}
+	s.setServerInfo(result.ServerInfo, result.Capabilities, err)
+	if err != nil {
+		return nil
+	}
+	if err := s.NotifyJSONRPC(ctx, "notifications/initialized", nil); err != nil {
+		log.Printf("MCP server %s: failed to send notifications/initialized: %v", s.Config.Name, err)
+	}
+	return nil
+}
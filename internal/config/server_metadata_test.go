@@ -0,0 +1,199 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestServerMetadata_Initialize tests that serverInfo from a stdio server's initialize
+// result is captured and combined with the display config.
+func TestServerMetadata_Initialize(t *testing.T) {
+	server := &mockMCPServer{
+		MCPServer: MCPServer{Config: MCPServerConfig{Name: "stdio-server", Command: "mockcmd"}},
+		responses: map[string][]string{
+			"tools/list":     {`{"result":{"tools":[{"name":"tool1"}]}}`, `{"result":{"tools":[{"name":"tool1"}]}}`},
+			"resources/list": {`{"result":{"resources":[]}}`, `{"result":{"resources":[]}}`},
+			"initialize": {`{"result":{"protocolVersion":"2025-06-18","serverInfo":{"name":"files","title":"File Server","version":"1.2.0",` +
+				`"websiteUrl":"https://files.example","icons":[{"src":"https://files.example/icon.png","mimeType":"image/png"}]}}}`},
+		},
+		callCount: make(map[string]int),
+	}
+	server.HandleStdioRequestFunc = server.HandleStdioRequest
+
+	if server.Metadata() != nil {
+		t.Errorf("expected no metadata before discovery, got %+v", server.Metadata())
+	}
+	if err := server.refreshToolsAndResources(); err != nil {
+		t.Fatalf("refreshToolsAndResources failed: %v", err)
+	}
+	want := &ServerMetadata{
+		Name:       "stdio-server",
+		Title:      "File Server",
+		Version:    "1.2.0",
+		WebsiteURL: "https://files.example",
+		IconURL:    "https://files.example/icon.png",
+		Icons:      []ServerIcon{{Src: "https://files.example/icon.png", MimeType: "image/png"}},
+	}
+	if got := server.Metadata(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Metadata() = %+v, want %+v", got, want)
+	}
+
+	server.Config.Display = &DisplayConfig{Title: "Files", Color: "#1f6feb"}
+	got := server.Metadata()
+	if got.Title != "Files" || got.Color != "#1f6feb" || got.Version != "1.2.0" {
+		t.Errorf("expected display config to override the title and add a color, got %+v", got)
+	}
+
+	// initialize is sent once per process
+	if err := server.refreshToolsAndResources(); err != nil {
+		t.Fatalf("second refreshToolsAndResources failed: %v", err)
+	}
+	if server.callCount["initialize"] != 1 {
+		t.Errorf("expected a single initialize request, got %d", server.callCount["initialize"])
+	}
+}
+
+// TestServerMetadata_Optional tests that a server without serverInfo or display
+// config has no metadata, and that absent fields are omitted from JSON.
+func TestServerMetadata_Optional(t *testing.T) {
+	server := &MCPServer{Config: MCPServerConfig{Name: "plain"}}
+	if server.Metadata() != nil {
+		t.Errorf("expected nil metadata, got %+v", server.Metadata())
+	}
+
+	server.Config.Display = &DisplayConfig{Color: "red"}
+	data, err := json.Marshal(server.Metadata())
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if string(data) != `{"name":"plain","color":"red"}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+}
+
+// TestDisplayConfig_Validate tests validation of display.icon_url.
+func TestDisplayConfig_Validate(t *testing.T) {
+	for _, iconURL := range []string{"https://example.com/a.png", "data:image/png;base64,AAAA"} {
+		cfg := &Config{MCPServers: []MCPServerConfig{{Name: "s", Command: "cat", Display: &DisplayConfig{IconURL: iconURL}}}}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("unexpected validation error for %s: %v", iconURL, err)
+		}
+	}
+	cfg := &Config{MCPServers: []MCPServerConfig{{Name: "s", Command: "cat", Display: &DisplayConfig{IconURL: "javascript:alert(1)"}}}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "display.icon_url") {
+		t.Errorf("expected display.icon_url error, got %v", err)
+	}
+}
+
+// TestServerMetadata_Capabilities tests that the capabilities of initialize results are
+// kept, and that a change after the first one is announced as changed lists.
+func TestServerMetadata_Capabilities(t *testing.T) {
+	server := &MCPServer{Config: MCPServerConfig{Name: "s", Command: "mockcmd"}}
+	var rec eventRecorder
+	server.SetEventHandler(rec.handle)
+
+	if server.BackendCapabilities() != nil {
+		t.Errorf("expected no capabilities before initialize, got %v", server.BackendCapabilities())
+	}
+	server.setServerInfo(nil, map[string]interface{}{"tools": map[string]interface{}{}, "resources": map[string]interface{}{"subscribe": true}}, nil)
+	want := map[string]interface{}{"tools": map[string]interface{}{}, "resources": map[string]interface{}{"subscribe": true}}
+	if got := server.BackendCapabilities(); !reflect.DeepEqual(got, want) {
+		t.Errorf("BackendCapabilities() = %v, want %v", got, want)
+	}
+
+	server.setServerInfo(nil, nil, errors.New("initialize failed"))
+	server.setServerInfo(nil, map[string]interface{}{"tools": map[string]interface{}{}, "resources": map[string]interface{}{"subscribe": true}}, nil)
+	if got := rec.recorded(); len(got) != 0 {
+		t.Errorf("expected no events while the capabilities are unchanged, got %v", got)
+	}
+	server.setServerInfo(nil, map[string]interface{}{"tools": map[string]interface{}{}, "prompts": map[string]interface{}{}}, nil)
+	if got, want := rec.recorded(), []EventKind{EventToolsetChanged, EventResourcesChanged}; !slices.Equal(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+	if server.BackendCapabilities()["prompts"] == nil {
+		t.Error("expected the new capabilities to be kept")
+	}
+}
+
+// TestServerMetadata_HandshakeFirst tests that discovery starts with initialize and
+// notifications/initialized, and that the capabilities are known once it is done.
+func TestServerMetadata_HandshakeFirst(t *testing.T) {
+	want := []string{"initialize", "notifications/initialized", "tools/list", "resources/list"}
+	capabilities := map[string]interface{}{"tools": map[string]interface{}{}}
+
+	var stdioMethods []string
+	stdio := &MCPServer{Config: MCPServerConfig{Name: "stdio", Command: "mockcmd"}}
+	stdio.HandleStdioRequestFunc = func(reqBytes []byte) ([]byte, error) {
+		var req struct {
+			Method string `json:"method"`
+		}
+		json.Unmarshal(reqBytes, &req)
+		stdioMethods = append(stdioMethods, req.Method)
+		switch req.Method {
+		case "initialize":
+			return json.Marshal(map[string]interface{}{"result": map[string]interface{}{"capabilities": capabilities}})
+		case "tools/list":
+			return []byte(`{"result":{"tools":[]}}`), nil
+		case "resources/list":
+			return []byte(`{"result":{"resources":[]}}`), nil
+		}
+		return nil, nil
+	}
+	if err := stdio.refreshToolsAndResources(); err != nil {
+		t.Fatalf("stdio refreshToolsAndResources failed: %v", err)
+	}
+	if !slices.Equal(stdioMethods, want) {
+		t.Errorf("stdio requests = %v, want %v", stdioMethods, want)
+	}
+	if stdio.BackendCapabilities()["tools"] == nil {
+		t.Errorf("expected the stdio capabilities after the first discovery, got %v", stdio.BackendCapabilities())
+	}
+
+	var mu sync.Mutex
+	var rpcMethods []string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     *int64 `json:"id"`
+			Method string `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		rpcMethods = append(rpcMethods, req.Method)
+		mu.Unlock()
+		if req.ID == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": *req.ID}
+		switch req.Method {
+		case "initialize":
+			resp["result"] = map[string]interface{}{"capabilities": capabilities}
+		case "tools/list":
+			resp["result"] = map[string]interface{}{"tools": []interface{}{}}
+		default:
+			resp["result"] = map[string]interface{}{"resources": []interface{}{}}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer backend.Close()
+
+	rpc := &MCPServer{Config: MCPServerConfig{Name: "rpc", Address: backend.URL, Discovery: DiscoveryJSONRPC}, httpClient: &http.Client{}}
+	if err := rpc.refreshToolsAndResources(); err != nil {
+		t.Fatalf("JSON-RPC refreshToolsAndResources failed: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(rpcMethods, want) {
+		t.Errorf("JSON-RPC requests = %v, want %v", rpcMethods, want)
+	}
+	if rpc.BackendCapabilities()["tools"] == nil {
+		t.Errorf("expected the JSON-RPC capabilities after the first discovery, got %v", rpc.BackendCapabilities())
+	}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// jsonRPCInternalError is the JSON-RPC code for an internal error.
+const jsonRPCInternalError = -32603
+
+// ServerRequestHandler answers a request sent by a stdio server to its client, e.g.
+// sampling/createMessage. It returns the result to send back, or an error; a
+// *JSONRPCError is sent back as is.
+type ServerRequestHandler func(method string, params json.RawMessage) (json.RawMessage, error)
+
+// serverRequest is a JSON-RPC request read from a stdio server's stdout.
+type serverRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// serverRequestResponse is the answer written back to a stdio server's stdin.
+type serverRequestResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *JSONRPCError   `json:"error,omitempty"`
+}
+
+// answerServerRequest answers line on the server's stdin if it is a request rather
+// than the response being waited for, and reports whether it was. Callers must hold
+// the stdio pipe.
+func (s *MCPServer) answerServerRequest(line []byte) (bool, error) {
+	var req serverRequest
+	if err := json.Unmarshal(line, &req); err != nil || req.Method == "" || len(req.ID) == 0 || string(req.ID) == "null" {
+		return false, nil
+	}
+
+	resp := serverRequestResponse{JSONRPC: "2.0", ID: req.ID}
+	if s.ServerRequestHandler == nil {
+		resp.Error = &JSONRPCError{Code: jsonRPCMethodNotFound, Message: "Method not found"}
+	} else if result, err := s.ServerRequestHandler(req.Method, req.Params); err != nil {
+		var rpcErr *JSONRPCError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &JSONRPCError{Code: jsonRPCInternalError, Message: err.Error()}
+		}
+		resp.Error = rpcErr
+	} else {
+		if len(result) == 0 {
+			result = json.RawMessage("{}")
+		}
+		resp.Result = result
+	}
+	if resp.Error != nil {
+		log.Printf("MCP server %s request %s failed: %s", s.Config.Name, req.Method, resp.Error.Message)
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return true, fmt.Errorf("failed to encode answer to %s: %w", req.Method, err)
+	}
+	if _, err := s.stdin.Write(append(data, '\n')); err != nil {
+		return true, err
+	}
+	return true, nil
+}
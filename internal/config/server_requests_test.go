@@ -0,0 +1,28 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestServerRequestWithoutHandler tests that a request from a stdio server is answered
+// with "Method not found" when no handler is set, and the response is still read.
+func TestServerRequestWithoutHandler(t *testing.T) {
+	server := &MCPServer{Config: MCPServerConfig{Name: "asker", Command: "sh", Args: []string{"-c", `read line
+echo '{"jsonrpc":"2.0","id":"srv-1","method":"sampling/createMessage","params":{}}'
+read reply
+echo "$reply"
+read line`}}}
+	if err := server.startStdioProcess(); err != nil {
+		t.Fatalf("startStdioProcess failed: %v", err)
+	}
+	defer server.Shutdown()
+
+	resp, err := server.HandleStdioRequest([]byte(`{"method":"ask"}`))
+	if err != nil {
+		t.Fatalf("HandleStdioRequest failed: %v", err)
+	}
+	if !strings.Contains(string(resp), `"id":"srv-1"`) || !strings.Contains(string(resp), `"code":-32601`) {
+		t.Errorf("expected a method not found answer to srv-1, got %s", resp)
+	}
+}
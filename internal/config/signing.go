@@ -0,0 +1,111 @@
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// Values of signing_algorithm.
+const (
+	SigningSHA256 = "sha256"
+	SigningSHA512 = "sha512"
+)
+
+// Default headers of signed requests.
+const (
+	DefaultSignatureHeader = "X-Signature"
+	DefaultTimestampHeader = "X-Timestamp"
+)
+
+// SigningAlgorithmOrDefault returns the HMAC hash of signed requests.
+func (sc MCPServerConfig) SigningAlgorithmOrDefault() string {
+	if sc.SigningAlgorithm == "" {
+		return SigningSHA256
+	}
+	return sc.SigningAlgorithm
+}
+
+// SignatureHeaderOrDefault returns the header carrying the signature.
+func (sc MCPServerConfig) SignatureHeaderOrDefault() string {
+	if sc.SignatureHeader == "" {
+		return DefaultSignatureHeader
+	}
+	return sc.SignatureHeader
+}
+
+// TimestampHeaderOrDefault returns the header carrying the signed timestamp.
+func (sc MCPServerConfig) TimestampHeaderOrDefault() string {
+	if sc.TimestampHeader == "" {
+		return DefaultTimestampHeader
+	}
+	return sc.TimestampHeader
+}
+
+// signingHash returns the hash constructor of the signing algorithm.
+func (sc MCPServerConfig) signingHash() func() hash.Hash {
+	if sc.SigningAlgorithmOrDefault() == SigningSHA512 {
+		return sha512.New
+	}
+	return sha256.New
+}
+
+// Signature returns the signature header value for a body sent at timestamp (Unix
+// seconds): "<algorithm>=" followed by the hex HMAC of "<timestamp>.<body>" keyed with
+// signing_secret.
+func (sc MCPServerConfig) Signature(timestamp string, body []byte) string {
+	mac := hmac.New(sc.signingHash(), []byte(sc.SigningSecret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return sc.SigningAlgorithmOrDefault() + "=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignRequest sets the timestamp and signature headers of a request to the server
+// with the given body. It does nothing when signing_secret is not set.
+func (sc MCPServerConfig) SignRequest(header http.Header, body []byte, now time.Time) {
+	if sc.SigningSecret == "" {
+		return
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	header.Set(sc.TimestampHeaderOrDefault(), timestamp)
+	header.Set(sc.SignatureHeaderOrDefault(), sc.Signature(timestamp, body))
+}
+
+// validateSigning checks the server's request signing settings, which only apply to
+// HTTP servers.
+func (sc MCPServerConfig) validateSigning() error {
+	if sc.SigningSecret == "" {
+		if sc.SigningAlgorithm != "" || sc.SignatureHeader != "" || sc.TimestampHeader != "" {
+			return errors.New("signing_algorithm, signature_header and timestamp_header require signing_secret")
+		}
+		return nil
+	}
+	if strings.TrimSpace(sc.Address) == "" {
+		return errors.New("signing_secret requires address")
+	}
+	switch sc.SigningAlgorithmOrDefault() {
+	case SigningSHA256, SigningSHA512:
+	default:
+		return fmt.Errorf("invalid signing_algorithm '%s': must be '%s' or '%s'", sc.SigningAlgorithm, SigningSHA256, SigningSHA512)
+	}
+	for _, name := range []string{sc.SignatureHeader, sc.TimestampHeader} {
+		if name != "" && !httpguts.ValidHeaderFieldName(name) {
+			return fmt.Errorf("invalid signing header name '%s'", name)
+		}
+	}
+	if strings.EqualFold(sc.SignatureHeaderOrDefault(), sc.TimestampHeaderOrDefault()) {
+		return errors.New("signature_header and timestamp_header must differ")
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// StaticResourceConfig is a resource the proxy serves itself, from inline content or a
+// file, instead of forwarding requests for it to the server's backend.
+type StaticResourceConfig struct {
+	Name        string `json:"name"`
+	URI         string `json:"uri,omitempty"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mime_type,omitempty"`
+
+	// Content is the resource body. Exactly one of Content and File is set.
+	Content string `json:"content,omitempty"`
+	// File is read on every request, so edits are served without a restart. A relative
+	// path is resolved against the config file's directory.
+	File string `json:"file,omitempty"`
+
+	resolvedFile string // File resolved against the config file's directory; "" when not needed
+}
+
+// Info returns the resource as listed.
+func (r StaticResourceConfig) Info() ResourceInfo {
+	return ResourceInfo{Name: r.Name, URI: r.URI, Description: r.Description, MimeType: r.MimeType}
+}
+
+// Read returns the resource body.
+func (r StaticResourceConfig) Read() ([]byte, error) {
+	if r.File == "" {
+		return []byte(r.Content), nil
+	}
+	path := r.File
+	if r.resolvedFile != "" {
+		path = r.resolvedFile
+	}
+	return os.ReadFile(path)
+}
+
+// IsStatic reports whether the server only serves static_resources and static_tools,
+// having neither an address nor a command.
+func (sc MCPServerConfig) IsStatic() bool {
+	return sc.Address == "" && sc.Command == "" && (len(sc.StaticResources) > 0 || len(sc.StaticTools) > 0)
+}
+
+// StaticResource returns the static resource named name, or nil.
+func (sc MCPServerConfig) StaticResource(name string) *StaticResourceConfig {
+	for i := range sc.StaticResources {
+		if sc.StaticResources[i].Name == name {
+			return &sc.StaticResources[i]
+		}
+	}
+	return nil
+}
+
+// staticResourceInfos lists the static resources, which discovery adds to the
+// backend's before filtering.
+func (sc MCPServerConfig) staticResourceInfos() []ResourceInfo {
+	infos := make([]ResourceInfo, len(sc.StaticResources))
+	for i, r := range sc.StaticResources {
+		infos[i] = r.Info()
+	}
+	return infos
+}
+
+// resolveStaticFiles resolves relative static resource files against baseDir.
+func (sc *MCPServerConfig) resolveStaticFiles(baseDir string) {
+	for i := range sc.StaticResources {
+		r := &sc.StaticResources[i]
+		if r.File != "" && !filepath.IsAbs(r.File) {
+			r.resolvedFile = filepath.Join(baseDir, r.File)
+		}
+	}
+}
+
+// validateStaticResources checks that static resources have unique names and exactly
+// one of content and file.
+func (sc MCPServerConfig) validateStaticResources() error {
+	names := make(map[string]bool, len(sc.StaticResources))
+	for i, r := range sc.StaticResources {
+		if r.Name == "" {
+			return fmt.Errorf("static_resources[%d]: name is required", i)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("static_resources[%d]: duplicate resource name '%s'", i, r.Name)
+		}
+		names[r.Name] = true
+		if (r.Content == "") == (r.File == "") {
+			return fmt.Errorf("static_resources[%d]: exactly one of content and file is required", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultStaticToolTimeout is used when a static tool sets no timeout_seconds.
+const DefaultStaticToolTimeout = 30 * time.Second
+
+// ErrStaticToolTimeout is returned when a static tool's command does not exit within
+// its timeout.
+var ErrStaticToolTimeout = errors.New("static tool timed out")
+
+// StaticToolConfig is a tool the proxy serves itself by running a local command, with
+// the call's arguments as a JSON object on stdin and stdout as the result text.
+type StaticToolConfig struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema,omitempty"` // Defaults to {"type":"object"}
+
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	// TimeoutSeconds bounds each run; the command is killed after it. Zero uses
+	// DefaultStaticToolTimeout.
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+}
+
+// Timeout returns how long a run of the tool may take.
+func (t StaticToolConfig) Timeout() time.Duration {
+	if t.TimeoutSeconds <= 0 {
+		return DefaultStaticToolTimeout
+	}
+	return time.Duration(t.TimeoutSeconds) * time.Second
+}
+
+// Info returns the tool as listed.
+func (t StaticToolConfig) Info() ToolInfo {
+	schema := t.InputSchema
+	if schema == nil {
+		schema = map[string]interface{}{"type": "object"}
+	}
+	return ToolInfo{Name: t.Name, Description: t.Description, InputSchema: schema}
+}
+
+// StaticTool returns the static tool named name, or nil.
+func (sc MCPServerConfig) StaticTool(name string) *StaticToolConfig {
+	for i := range sc.StaticTools {
+		if sc.StaticTools[i].Name == name {
+			return &sc.StaticTools[i]
+		}
+	}
+	return nil
+}
+
+// staticToolInfos lists the static tools, which discovery adds to the backend's
+// before filtering.
+func (sc MCPServerConfig) staticToolInfos() []ToolInfo {
+	infos := make([]ToolInfo, len(sc.StaticTools))
+	for i, t := range sc.StaticTools {
+		infos[i] = t.Info()
+	}
+	return infos
+}
+
+// validateStaticTools checks that static tools have unique names, a command and a
+// non-negative timeout.
+func (sc MCPServerConfig) validateStaticTools() error {
+	names := make(map[string]bool, len(sc.StaticTools))
+	for i, t := range sc.StaticTools {
+		if t.Name == "" {
+			return fmt.Errorf("static_tools[%d]: name is required", i)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("static_tools[%d]: duplicate tool name '%s'", i, t.Name)
+		}
+		names[t.Name] = true
+		if strings.TrimSpace(t.Command) == "" {
+			return fmt.Errorf("static_tools[%d]: command is required", i)
+		}
+		if t.TimeoutSeconds < 0 {
+			return fmt.Errorf("static_tools[%d]: timeout_seconds must not be negative", i)
+		}
+	}
+	return nil
+}
+
+// CallStaticTool runs the static tool named toolName in the server's working_dir and
+// env, writing arguments to its stdin. Its stdout becomes a text content block; a
+// non-zero exit is a result with isError set and stderr as the text. An error is
+// returned when the command cannot be run or times out.
+func (s *MCPServer) CallStaticTool(ctx context.Context, toolName string, arguments map[string]interface{}) (*CallToolResult, error) {
+	tool := s.Config.StaticTool(toolName)
+	if tool == nil {
+		return nil, fmt.Errorf("no static tool '%s' on MCP server %s", toolName, s.Config.Name)
+	}
+	if arguments == nil {
+		arguments = map[string]interface{}{}
+	}
+	input, err := json.Marshal(arguments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode arguments of static tool '%s': %w", toolName, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, tool.Timeout())
+	defer cancel()
+	cmd := exec.CommandContext(ctx, tool.Command, tool.Args...)
+	cmd.Dir = s.Config.WorkingDirPath()
+	envVars := make([]string, 0, len(s.Config.Env))
+	for k, v := range s.Config.Env {
+		value, err := formatEnvValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid env value for '%s': %w", k, err)
+		}
+		envVars = append(envVars, k+"="+value)
+	}
+	cmd.Env = append(os.Environ(), envVars...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("%w after %s: %s", ErrStaticToolTimeout, tool.Timeout(), toolName)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		text := strings.TrimSpace(stderr.String())
+		if text == "" {
+			text = stdout.String()
+		}
+		if text == "" {
+			text = exitErr.Error()
+		}
+		return &CallToolResult{Content: []ContentBlock{{Type: "text", Text: &text}}, IsError: true}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to run static tool '%s': %w", toolName, err)
+	}
+	text := stdout.String()
+	return &CallToolResult{Content: []ContentBlock{{Type: "text", Text: &text}}}, nil
+}
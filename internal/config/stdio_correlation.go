@@ -0,0 +1,60 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+)
+
+// rpcIDKey returns the JSON-RPC id as the exact JSON it was sent as, compacted, so that
+// the string "1" and the number 1 are different keys. It is empty when there is no id
+// or the id is null.
+func rpcIDKey(id json.RawMessage) string {
+	if len(id) == 0 {
+		return ""
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, id); err != nil {
+		return string(id)
+	}
+	if compact.String() == "null" {
+		return ""
+	}
+	return compact.String()
+}
+
+// stdioMessage is the part of a JSON-RPC message on a stdio pipe used to correlate it.
+type stdioMessage struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+}
+
+// stdioRequestID returns the id key of a request written to a stdio server, or "" for
+// requests without one, such as the HTTP-shaped requests of the REST routes.
+func stdioRequestID(reqBytes []byte) string {
+	var msg stdioMessage
+	if err := json.Unmarshal(reqBytes, &msg); err != nil {
+		return ""
+	}
+	return rpcIDKey(msg.ID)
+}
+
+// answers reports whether line, read from the server's stdout, is the response to the
+// request with id key id. Notifications and responses carrying another id are logged
+// and skipped. A response without an id is accepted, as some backends answer with a
+// bare result.
+func (s *MCPServer) answers(line []byte, id string) bool {
+	var msg stdioMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return true // Left to the caller to report
+	}
+	if msg.Method != "" {
+		log.Printf("MCP server %s sent %s while request id %s was pending, skipping it", s.Config.Name, msg.Method, id)
+		return false
+	}
+	if got := rpcIDKey(msg.ID); got != "" && got != id {
+		log.Printf("MCP server %s answered id %s while request id %s was pending, discarding it", s.Config.Name, got, id)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestStdioCorrelation tests that a stdio request returns the response carrying its id,
+// of the same JSON type, skipping notifications and responses to other ids first.
+func TestStdioCorrelation(t *testing.T) {
+	script := `read line
+echo '{"jsonrpc":"2.0","method":"notifications/message","params":{"level":"info"}}'
+echo '{"jsonrpc":"2.0","id":6,"result":{"stale":true}}'
+echo '{"jsonrpc":"2.0","id":"7","result":{"string":true}}'
+echo '{"jsonrpc":"2.0","id":7,"result":{"number":true}}'
+read line
+echo '{"jsonrpc":"2.0","id":7,"result":{"number":true}}'
+echo '{"jsonrpc":"2.0","id":"7","result":{"string":true}}'
+read line
+echo '{"result":{"bare":true}}'
+read line`
+	server := &MCPServer{Config: MCPServerConfig{Name: "chatty", Command: "sh", Args: []string{"-c", script}}}
+	if err := server.startStdioProcess(); err != nil {
+		t.Fatalf("startStdioProcess failed: %v", err)
+	}
+	defer server.Shutdown()
+
+	for _, tt := range []struct {
+		request string
+		want    string
+	}{
+		{`{"jsonrpc":"2.0","id":7,"method":"tools/call"}`, `{"number":true}`},
+		{`{"jsonrpc":"2.0","id":"7","method":"tools/call"}`, `{"string":true}`},
+		{`{"jsonrpc":"2.0","id":8,"method":"tools/call"}`, `{"bare":true}`},
+	} {
+		resp, err := server.HandleStdioRequest([]byte(tt.request))
+		if err != nil {
+			t.Fatalf("HandleStdioRequest(%s) failed: %v", tt.request, err)
+		}
+		var got struct {
+			Result json.RawMessage `json:"result"`
+		}
+		if err := json.Unmarshal(resp, &got); err != nil {
+			t.Fatalf("invalid response %s: %v", resp, err)
+		}
+		if string(got.Result) != tt.want {
+			t.Errorf("HandleStdioRequest(%s) result = %s, want %s", tt.request, got.Result, tt.want)
+		}
+	}
+}
+
+// TestStdioRequestIDs tests that discovery and initialize never send the same id twice.
+func TestStdioRequestIDs(t *testing.T) {
+	seen := map[string]bool{}
+	server := &MCPServer{
+		Config: MCPServerConfig{Name: "ids", Command: "mockcmd"},
+		HandleStdioRequestFunc: func(reqBytes []byte) ([]byte, error) {
+			if strings.Contains(string(reqBytes), `"method":"notifications/`) {
+				return nil, nil // Not answered
+			}
+			id := stdioRequestID(reqBytes)
+			if id == "" || seen[id] {
+				t.Errorf("request %s reuses or lacks an id", reqBytes)
+			}
+			seen[id] = true
+			return []byte(`{"jsonrpc":"2.0","id":` + id + `,"result":{}}`), nil
+		},
+	}
+	for i := 0; i < 2; i++ {
+		if _, _, err := server.fetchToolsAndResourcesStdio(context.Background()); err != nil {
+			t.Fatalf("fetchToolsAndResourcesStdio failed: %v", err)
+		}
+	}
+	if len(seen) < 4 {
+		t.Errorf("expected at least 4 requests, saw %d", len(seen))
+	}
+}
+
+// TestRPCIDKey tests that ids are keyed by their exact JSON.
+func TestRPCIDKey(t *testing.T) {
+	for raw, want := range map[string]string{`1`: `1`, `"1"`: `"1"`, ` "a b" `: `"a b"`, `null`: ``, ``: ``} {
+		if got := rpcIDKey(json.RawMessage(raw)); got != want {
+			t.Errorf("rpcIDKey(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultStdioIdleTimeout is used when stdio_idle_timeout is not set.
+const DefaultStdioIdleTimeout = 5 * time.Minute
+
+// errServerShutDown is returned for requests to a pooled stdio server after Shutdown.
+var errServerShutDown = errors.New("MCP server is shut down")
+
+// StdioIdleTimeoutDuration parses StdioIdleTimeout, defaulting to DefaultStdioIdleTimeout.
+func (c *Config) StdioIdleTimeoutDuration() (time.Duration, error) {
+	if c.StdioIdleTimeout == "" {
+		return DefaultStdioIdleTimeout, nil
+	}
+	return time.ParseDuration(c.StdioIdleTimeout)
+}
+
+// stdioPool bounds the number of stdio server processes running at once. A server
+// takes a slot when its process is started on demand and keeps it until the process
+// is evicted to make room for another server, stops after being idle, or shuts down.
+type stdioPool struct {
+	max         int
+	idleTimeout time.Duration
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	running []*MCPServer // Servers holding a slot, least recently used first
+}
+
+// newStdioPool returns a pool of max slots, or nil when max is zero (no limit).
+func newStdioPool(max int, idleTimeout time.Duration) *stdioPool {
+	if max <= 0 {
+		return nil
+	}
+	p := &stdioPool{max: max, idleTimeout: idleTimeout}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// acquire marks s in use, starting its process first if it is not running. When every
+// slot is taken, the least recently used idle server is stopped; if none is idle,
+// acquire waits. Each successful acquire must be paired with release.
+func (p *stdioPool) acquire(s *MCPServer) error {
+	p.mu.Lock()
+	for {
+		switch {
+		case s.poolClosed:
+			p.mu.Unlock()
+			return errServerShutDown
+		case s.poolStarting || s.poolStopping:
+			p.cond.Wait()
+		case s.pooled:
+			s.inUse++
+			s.stopIdleTimer()
+			p.touch(s)
+			p.mu.Unlock()
+			return nil
+		case len(p.running) < p.max:
+			s.pooled = true
+			s.poolStarting = true
+			s.inUse++
+			s.lastUsed = time.Now()
+			p.running = append(p.running, s)
+			n := len(p.running)
+			p.mu.Unlock()
+
+			log.Printf("Starting MCP server %s (%d of %d stdio processes)", s.Config.Name, n, p.max)
+			err := s.startStdioProcess()
+
+			p.mu.Lock()
+			s.poolStarting = false
+			if err != nil {
+				s.inUse--
+				s.pooled = false
+				p.remove(s)
+			}
+			p.cond.Broadcast()
+			p.mu.Unlock()
+			return err
+		default:
+			victim := p.idleVictim()
+			if victim == nil {
+				p.cond.Wait()
+				continue
+			}
+			p.evictLocked(victim)
+			p.mu.Unlock()
+			log.Printf("Stopping idle MCP server %s to start %s (max_stdio_processes %d)", victim.Config.Name, s.Config.Name, p.max)
+			p.stopEvicted(victim)
+			p.mu.Lock()
+		}
+	}
+}
+
+// release ends a use of s started by acquire. The idle timer starts once s is unused.
+func (p *stdioPool) release(s *MCPServer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s.inUse--
+	s.lastUsed = time.Now()
+	if s.inUse == 0 && s.pooled && p.idleTimeout > 0 {
+		s.idleTimer = time.AfterFunc(p.idleTimeout, func() { p.stopIfIdle(s) })
+	}
+	p.cond.Broadcast()
+}
+
+// stopIfIdle stops s when its idle timer fires and it has not been used since.
+func (p *stdioPool) stopIfIdle(s *MCPServer) {
+	p.mu.Lock()
+	if !s.pooled || s.inUse > 0 || time.Since(s.lastUsed) < p.idleTimeout {
+		p.mu.Unlock()
+		return
+	}
+	p.evictLocked(s)
+	p.mu.Unlock()
+	log.Printf("Stopping MCP server %s after being idle for %s", s.Config.Name, p.idleTimeout)
+	p.stopEvicted(s)
+}
+
+// close frees the slot of s for good: it is no longer started on demand. The process
+// is left to the caller to stop.
+func (p *stdioPool) close(s *MCPServer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s.poolClosed = true
+	if s.pooled {
+		s.pooled = false
+		s.stopIdleTimer()
+		p.remove(s)
+		p.cond.Broadcast()
+	}
+}
+
+// stopBeyondLimit stops the processes of stdio servers beyond the first max in config
+// order once startup discovery is done; they start again on first use. Lazy servers
+// are already stopped and do not count.
+func (p *stdioPool) stopBeyondLimit(servers []*MCPServer) {
+	n := 0
+	for _, s := range servers {
+		if s.pool != p || s.Config.Lazy {
+			continue
+		}
+		if n++; n <= p.max {
+			continue
+		}
+		p.stopUnused(s, fmt.Sprintf("until first use (max_stdio_processes %d)", p.max))
+	}
+}
+
+// stopUnused stops the process of s if it is running with no request in flight; it
+// starts again on next use. why completes the log line.
+func (p *stdioPool) stopUnused(s *MCPServer, why string) {
+	p.mu.Lock()
+	running := s.pooled && s.inUse == 0
+	if running {
+		p.evictLocked(s)
+	}
+	p.mu.Unlock()
+	if running {
+		log.Printf("Stopping MCP server %s %s", s.Config.Name, why)
+		p.stopEvicted(s)
+	}
+}
+
+// idleVictim returns the least recently used server holding a slot with no request in
+// flight, or nil. Callers must hold p.mu.
+func (p *stdioPool) idleVictim() *MCPServer {
+	for _, s := range p.running {
+		if s.inUse == 0 && !s.poolStarting {
+			return s
+		}
+	}
+	return nil
+}
+
+// evictLocked frees the slot of s and wakes waiters. Until stopEvicted returns, s is
+// not started again. Callers must hold p.mu.
+func (p *stdioPool) evictLocked(s *MCPServer) {
+	s.pooled = false
+	s.poolStopping = true
+	s.stopIdleTimer()
+	p.remove(s)
+	p.cond.Broadcast()
+}
+
+// stopEvicted stops the process of a server freed by evictLocked.
+func (p *stdioPool) stopEvicted(s *MCPServer) {
+	s.stopProcess()
+	p.mu.Lock()
+	s.poolStopping = false
+	p.cond.Broadcast()
+	p.mu.Unlock()
+}
+
+// touch moves s to the most recently used end. Callers must hold p.mu.
+func (p *stdioPool) touch(s *MCPServer) {
+	s.lastUsed = time.Now()
+	p.remove(s)
+	p.running = append(p.running, s)
+}
+
+// remove drops s from the running list. Callers must hold p.mu.
+func (p *stdioPool) remove(s *MCPServer) {
+	for i, r := range p.running {
+		if r == s {
+			p.running = append(p.running[:i], p.running[i+1:]...)
+			return
+		}
+	}
+}
+
+// stopIdleTimer cancels a pending idle stop. Callers must hold the pool's mu.
+func (s *MCPServer) stopIdleTimer() {
+	if s.idleTimer != nil {
+		s.idleTimer.Stop()
+		s.idleTimer = nil
+	}
+}
+
+// ProcessRunning reports whether the stdio process is running. Without
+// max_stdio_processes or lazy, stdio processes run from startup to shutdown.
+func (s *MCPServer) ProcessRunning() bool {
+	if s.pool == nil {
+		return s.Config.Command != ""
+	}
+	s.pool.mu.Lock()
+	defer s.pool.mu.Unlock()
+	return s.pooled
+}
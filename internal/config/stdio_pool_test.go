@@ -0,0 +1,87 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"smart-mcp-proxy/proxytest"
+)
+
+// testStdioServerConfig returns a stdio server that lists one tool, "<name>-tool", and
+// answers calls of it with its name. Other methods are answered with "Method not found".
+func testStdioServerConfig(name string) MCPServerConfig {
+	backend := proxytest.StdioBackend{Tools: []proxytest.Tool{{Name: name + "-tool", Result: &proxytest.Result{Text: name}}}}
+	return MCPServerConfig{Name: name, Command: backend.Command(), Args: backend.Args()}
+}
+
+// TestMaxStdioProcesses tests that with a limit of 1, the second stdio server is stopped
+// after discovery and starts, evicting the first, only when its tool is called.
+func TestMaxStdioProcesses(t *testing.T) {
+	servers, err := NewMCPServers(&Config{
+		MCPServers:        []MCPServerConfig{testStdioServerConfig("first"), testStdioServerConfig("second")},
+		MaxStdioProcesses: 1,
+	})
+	if err != nil {
+		t.Fatalf("NewMCPServers failed: %v", err)
+	}
+	first, second := servers[0], servers[1]
+	defer first.Shutdown()
+	defer second.Shutdown()
+
+	// Both servers were discovered, but only one process may be running
+	if names := toolNames(second); len(names) != 1 || names[0] != "second-tool" {
+		t.Fatalf("expected the second server's tool to be discovered, got %v", names)
+	}
+	if second.ProcessRunning() {
+		t.Fatal("expected the second server to be stopped until first use")
+	}
+
+	resp, err := second.HandleStdioRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"second-tool"}}`))
+	if err != nil {
+		t.Fatalf("tools/call failed: %v", err)
+	}
+	if !strings.Contains(string(resp), `"text":"second"`) {
+		t.Errorf("unexpected response %s", resp)
+	}
+	if !second.ProcessRunning() || first.ProcessRunning() {
+		t.Errorf("expected only the second server to run, got first=%v second=%v", first.ProcessRunning(), second.ProcessRunning())
+	}
+
+	// Calling the first server swaps them again
+	if _, err := first.HandleStdioRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"first-tool"}}`)); err != nil {
+		t.Fatalf("tools/call failed: %v", err)
+	}
+	if !first.ProcessRunning() || second.ProcessRunning() {
+		t.Errorf("expected only the first server to run, got first=%v second=%v", first.ProcessRunning(), second.ProcessRunning())
+	}
+
+	// After shutdown the server is not started on demand
+	first.Shutdown()
+	if _, err := first.HandleStdioRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call"}`)); err == nil {
+		t.Error("expected a request after shutdown to fail")
+	}
+}
+
+// TestStdioIdleTimeout tests that a pooled stdio process is stopped once idle.
+func TestStdioIdleTimeout(t *testing.T) {
+	servers, err := NewMCPServers(&Config{
+		MCPServers:        []MCPServerConfig{testStdioServerConfig("idle")},
+		MaxStdioProcesses: 2,
+		StdioIdleTimeout:  "100ms",
+	})
+	if err != nil {
+		t.Fatalf("NewMCPServers failed: %v", err)
+	}
+	server := servers[0]
+	defer server.Shutdown()
+
+	waitFor(t, 2*time.Second, func() bool { return !server.ProcessRunning() })
+	if _, err := server.HandleStdioRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call"}`)); err != nil {
+		t.Fatalf("tools/call failed: %v", err)
+	}
+	if !server.ProcessRunning() {
+		t.Error("expected the process to start again on use")
+	}
+	waitFor(t, 2*time.Second, func() bool { return !server.ProcessRunning() })
+}
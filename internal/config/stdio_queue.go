@@ -0,0 +1,51 @@
+package config
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// StdioQueueObserver receives queueing events of stdio requests, e.g. to export them
+// as metrics. Either function may be nil.
+type StdioQueueObserver struct {
+	// Depth is called with the number of requests waiting for a server's stdin/stdout
+	// pipe whenever it changes.
+	Depth func(server string, depth int64)
+	// Wait is called with the time a request waited before it was sent.
+	Wait func(server string, wait time.Duration)
+}
+
+// stdioQueueObserver is the observer set by SetStdioQueueObserver, if any.
+var stdioQueueObserver atomic.Pointer[StdioQueueObserver]
+
+// SetStdioQueueObserver registers the observer of stdio request queueing for all
+// servers. Nil removes it.
+func SetStdioQueueObserver(o *StdioQueueObserver) {
+	stdioQueueObserver.Store(o)
+}
+
+// StdioQueueDepth returns the number of requests waiting to be sent to the server's
+// stdin/stdout pipe, which serves one request at a time.
+func (s *MCPServer) StdioQueueDepth() int64 {
+	return s.stdioQueue.Load()
+}
+
+// lockStdio takes the lock guarding the stdio pipe, counting the request as queued
+// until it gets the lock.
+func (s *MCPServer) lockStdio() {
+	o := stdioQueueObserver.Load()
+	depth := s.stdioQueue.Add(1)
+	if o != nil && o.Depth != nil {
+		o.Depth(s.Config.Name, depth)
+	}
+	start := time.Now()
+	s.mu.Lock()
+	wait := time.Since(start)
+	depth = s.stdioQueue.Add(-1)
+	if o != nil && o.Depth != nil {
+		o.Depth(s.Config.Name, depth)
+	}
+	if o != nil && o.Wait != nil {
+		o.Wait(s.Config.Name, wait)
+	}
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestStdioQueueDepth tests that concurrent requests to a slow stdio server are counted
+// as queued while they wait for the pipe, and that their wait is reported.
+func TestStdioQueueDepth(t *testing.T) {
+	var maxDepth atomic.Int64
+	var waits atomic.Int32
+	SetStdioQueueObserver(&StdioQueueObserver{
+		Depth: func(server string, depth int64) {
+			for {
+				cur := maxDepth.Load()
+				if depth <= cur || maxDepth.CompareAndSwap(cur, depth) {
+					return
+				}
+			}
+		},
+		Wait: func(server string, wait time.Duration) { waits.Add(1) },
+	})
+	defer SetStdioQueueObserver(nil)
+
+	servers, err := NewMCPServers(&Config{MCPServers: []MCPServerConfig{{
+		Name:    "slow",
+		Command: "sh",
+		Args:    []string{"-c", `while read line; do sleep 0.1; echo '{"result":{}}'; done`},
+	}}})
+	if err != nil {
+		t.Fatalf("NewMCPServers failed: %v", err)
+	}
+	server := servers[0]
+	defer server.Shutdown()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := server.HandleStdioRequest([]byte(`{"method":"ping"}`)); err != nil {
+				t.Errorf("HandleStdioRequest failed: %v", err)
+			}
+		}()
+	}
+	waitFor(t, 2*time.Second, func() bool { return server.StdioQueueDepth() >= 2 })
+	wg.Wait()
+
+	if got := server.StdioQueueDepth(); got != 0 {
+		t.Errorf("StdioQueueDepth() = %d after all requests finished, want 0", got)
+	}
+	if got := maxDepth.Load(); got < 2 {
+		t.Errorf("observed max queue depth %d, want at least 2", got)
+	}
+	if got := waits.Load(); got < 4 {
+		t.Errorf("observed %d waits, want at least 4", got)
+	}
+}
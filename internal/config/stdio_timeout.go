@@ -0,0 +1,51 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// DefaultStdioTimeout is used when stdio_timeout_seconds is not set.
+const DefaultStdioTimeout = 60 * time.Second
+
+// ErrStdioTimeout is returned when a stdio server does not accept a request on stdin or
+// answer it on stdout in time. The process is killed, so that it is restarted with
+// clean pipes, and callers may retry.
+var ErrStdioTimeout = errors.New("stdio server did not respond in time")
+
+// StdioTimeout returns how long a write to the stdio server's stdin, or a read of one
+// line of its stdout, may block.
+func (sc MCPServerConfig) StdioTimeout() time.Duration {
+	if sc.StdioTimeoutSeconds <= 0 {
+		return DefaultStdioTimeout
+	}
+	return time.Duration(sc.StdioTimeoutSeconds) * time.Second
+}
+
+// pipeOp runs op, a write to stdin or a read from stdout of the stdio process, and
+// gives up after stdio_timeout_seconds. Pipes have no deadlines, so op runs on its own
+// goroutine; on timeout the process is killed, which ends op, as a late answer would
+// otherwise be read as the answer to the next request. The caller holds mu.
+func (s *MCPServer) pipeOp(what string, op func() error) error {
+	timeout := s.Config.StdioTimeout()
+	done := make(chan error, 1)
+	go func() {
+		done <- op()
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+	}
+
+	log.Printf("MCP server %s blocked the %s for %s, killing it to be restarted", s.Config.Name, what, timeout)
+	if s.cmd != nil && s.cmd.Process != nil {
+		s.cmd.Process.Kill()
+	}
+	<-done // The pipe is closed once the process has been reaped
+	return fmt.Errorf("%w: %s of MCP server %s timed out after %s", ErrStdioTimeout, what, s.Config.Name, timeout)
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestStdioTimeout_NoResponse tests that a request to a stdio server that reads it but
+// never answers times out, and that the hung process is restarted.
+func TestStdioTimeout_NoResponse(t *testing.T) {
+	origBackoff := restartBackoff
+	restartBackoff = time.Millisecond
+	defer func() { restartBackoff = origBackoff }()
+
+	server := &MCPServer{Config: MCPServerConfig{Name: "hung", Command: "sh", Args: []string{"-c", "while read line; do :; done"}, StdioTimeoutSeconds: 1}}
+	if err := server.startStdioProcess(); err != nil {
+		t.Fatalf("startStdioProcess failed: %v", err)
+	}
+	defer server.Shutdown()
+
+	start := time.Now()
+	_, err := server.HandleStdioRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"hang"}}`))
+	if !errors.Is(err, ErrStdioTimeout) {
+		t.Fatalf("expected ErrStdioTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("request took %s, want about the 1s stdio_timeout_seconds", elapsed)
+	}
+	waitFor(t, 5*time.Second, func() bool { return server.Restarts() >= 1 })
+}
+
+// TestStdioTimeout_StdinBlocked tests that a write to a stdio server that stops reading
+// its stdin times out once the pipe is full.
+func TestStdioTimeout_StdinBlocked(t *testing.T) {
+	server := &MCPServer{Config: MCPServerConfig{Name: "deaf", Command: "sleep", Args: []string{"30"}, StdioTimeoutSeconds: 1}}
+	if err := server.startStdioProcess(); err != nil {
+		t.Fatalf("startStdioProcess failed: %v", err)
+	}
+	defer server.Shutdown()
+
+	request := append([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"big","arguments":{"data":"`), bytes.Repeat([]byte("x"), 1<<20)...)
+	request = append(request, `"}}}`...)
+	_, err := server.HandleStdioRequest(request)
+	if !errors.Is(err, ErrStdioTimeout) {
+		t.Fatalf("expected ErrStdioTimeout, got %v", err)
+	}
+}
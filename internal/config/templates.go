@@ -0,0 +1,104 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"text/template"
+)
+
+// ServerInstance instantiates a server template with a set of variables.
+type ServerInstance struct {
+	Template string            `json:"template"`
+	Vars     map[string]string `json:"vars,omitempty"`
+}
+
+// ExpandTemplates appends one MCPServerConfig per instance to MCPServers, substituting
+// `{{ .var }}` placeholders in the template's name, args, env values, and allowed lists.
+// Referencing an undefined variable is an error. On success, ServerTemplates and
+// Instances are cleared so the Config only describes concrete servers.
+func (c *Config) ExpandTemplates() error {
+	for i, instance := range c.Instances {
+		tmpl, ok := c.ServerTemplates[instance.Template]
+		if !ok {
+			return fmt.Errorf("instances[%d]: unknown server template '%s'", i, instance.Template)
+		}
+		server, err := expandServerTemplate(tmpl, instance.Vars)
+		if err != nil {
+			return fmt.Errorf("server template '%s', instances[%d]: %w", instance.Template, i, err)
+		}
+		c.MCPServers = append(c.MCPServers, server)
+	}
+	c.ServerTemplates = nil
+	c.Instances = nil
+	return nil
+}
+
+// expandServerTemplate returns a copy of tmpl with placeholders replaced by vars.
+func expandServerTemplate(tmpl MCPServerConfig, vars map[string]string) (MCPServerConfig, error) {
+	server := tmpl
+	var err error
+	if server.Name, err = expandTemplateString("name", tmpl.Name, vars); err != nil {
+		return server, err
+	}
+	if server.Args, err = expandTemplateList("args", tmpl.Args, vars); err != nil {
+		return server, err
+	}
+	if server.AllowedTools, err = expandTemplateList("allowed_tools", tmpl.AllowedTools, vars); err != nil {
+		return server, err
+	}
+	if server.AllowedResources, err = expandTemplateList("allowed_resources", tmpl.AllowedResources, vars); err != nil {
+		return server, err
+	}
+	if tmpl.Env != nil {
+		server.Env = make(map[string]interface{}, len(tmpl.Env))
+		keys := make([]string, 0, len(tmpl.Env))
+		for key := range tmpl.Env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value, ok := tmpl.Env[key].(string)
+			if !ok {
+				server.Env[key] = tmpl.Env[key]
+				continue
+			}
+			if server.Env[key], err = expandTemplateString("env."+key, value, vars); err != nil {
+				return server, err
+			}
+		}
+	}
+	return server, nil
+}
+
+// expandTemplateList expands every element of values, returning a new slice.
+func expandTemplateList(field string, values []string, vars map[string]string) ([]string, error) {
+	if values == nil {
+		return nil, nil
+	}
+	expanded := make([]string, len(values))
+	for i, value := range values {
+		s, err := expandTemplateString(fmt.Sprintf("%s[%d]", field, i), value, vars)
+		if err != nil {
+			return nil, err
+		}
+		expanded[i] = s
+	}
+	return expanded, nil
+}
+
+// expandTemplateString executes value as a text/template against vars.
+func expandTemplateString(field, value string, vars map[string]string) (string, error) {
+	t, err := template.New(field).Option("missingkey=error").Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", field, err)
+	}
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("%s: %w", field, err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestExpandTemplates tests that instances expand into concrete server configs.
+func TestExpandTemplates(t *testing.T) {
+	cfg := &Config{
+		ServerTemplates: map[string]MCPServerConfig{
+			"repo": {
+				Name:         "repo-{{ .name }}",
+				Command:      "/usr/bin/repo-server",
+				Args:         []string{"--path", "{{ .path }}"},
+				Env:          map[string]interface{}{"REPO": "{{ .name }}", "DEBUG": true},
+				AllowedTools: []string{"{{ .name }}_search"},
+			},
+		},
+		Instances: []ServerInstance{
+			{Template: "repo", Vars: map[string]string{"name": "a", "path": "/src/a"}},
+			{Template: "repo", Vars: map[string]string{"name": "b", "path": "/src/b"}},
+		},
+	}
+	if err := cfg.ExpandTemplates(); err != nil {
+		t.Fatalf("ExpandTemplates failed: %v", err)
+	}
+	if len(cfg.MCPServers) != 2 {
+		t.Fatalf("expected 2 servers, got %d", len(cfg.MCPServers))
+	}
+	b := cfg.MCPServers[1]
+	if b.Name != "repo-b" || b.Command != "/usr/bin/repo-server" {
+		t.Errorf("unexpected name/command: %s %s", b.Name, b.Command)
+	}
+	if !reflect.DeepEqual(b.Args, []string{"--path", "/src/b"}) {
+		t.Errorf("unexpected args: %v", b.Args)
+	}
+	if b.Env["REPO"] != "b" || b.Env["DEBUG"] != true {
+		t.Errorf("unexpected env: %v", b.Env)
+	}
+	if !reflect.DeepEqual(b.AllowedTools, []string{"b_search"}) {
+		t.Errorf("unexpected allowed_tools: %v", b.AllowedTools)
+	}
+	if cfg.MCPServers[0].Args[1] != "/src/a" {
+		t.Errorf("instances share expanded args: %v", cfg.MCPServers[0].Args)
+	}
+	if cfg.ServerTemplates != nil || cfg.Instances != nil {
+		t.Error("expected templates and instances to be cleared after expansion")
+	}
+}
+
+// TestExpandTemplates_Errors tests that expansion errors name the template and instance.
+func TestExpandTemplates_Errors(t *testing.T) {
+	cfg := &Config{
+		ServerTemplates: map[string]MCPServerConfig{"repo": {Name: "repo-{{ .name }}", Address: "http://localhost:9000", Args: []string{"{{ .missing }}"}}},
+		Instances:       []ServerInstance{{Template: "repo", Vars: map[string]string{"name": "a"}}},
+	}
+	err := cfg.ExpandTemplates()
+	if err == nil || !strings.Contains(err.Error(), "'repo'") || !strings.Contains(err.Error(), "instances[0]") || !strings.Contains(err.Error(), "args[0]") {
+		t.Errorf("expected error naming template, instance and field, got %v", err)
+	}
+
+	cfg = &Config{Instances: []ServerInstance{{Template: "nope"}}}
+	if err := cfg.ExpandTemplates(); err == nil || !strings.Contains(err.Error(), "unknown server template 'nope'") {
+		t.Errorf("expected unknown template error, got %v", err)
+	}
+}
+
+// TestLoadConfig_Templates tests that LoadConfig expands templates before validation.
+func TestLoadConfig_Templates(t *testing.T) {
+	content := `{
+		"server_templates": {
+			"remote": {"name": "remote-{{ .id }}", "address": "http://localhost:9000"}
+		},
+		"instances": [
+			{"template": "remote", "vars": {"id": "1"}},
+			{"template": "remote", "vars": {"id": "1"}}
+		]
+	}`
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	// Both instances expand to the same name, which validation rejects
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "duplicate server name 'remote-1'") {
+		t.Errorf("expected duplicate name error after expansion, got %v", err)
+	}
+}
@@ -0,0 +1,281 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultAllowedToolsRefresh is used when allowed_tools_refresh_seconds is not set.
+const DefaultAllowedToolsRefresh = time.Minute
+
+// policyHTTPClient fetches allowed_tools_from URLs; tests replace it to trust their TLS
+// servers.
+var policyHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// AllowedToolsRefresh returns how often allowed_tools_from is reloaded.
+func (sc MCPServerConfig) AllowedToolsRefresh() time.Duration {
+	if sc.AllowedToolsRefreshSeconds <= 0 {
+		return DefaultAllowedToolsRefresh
+	}
+	return time.Duration(sc.AllowedToolsRefreshSeconds) * time.Second
+}
+
+// validateToolPolicy checks allowed_tools_from and allowed_tools_refresh_seconds.
+func (sc MCPServerConfig) validateToolPolicy() error {
+	if sc.AllowedToolsRefreshSeconds < 0 {
+		return errors.New("allowed_tools_refresh_seconds must not be negative")
+	}
+	if sc.AllowedToolsFrom == "" {
+		return nil
+	}
+	if len(sc.AllowedTools) > 0 {
+		return errors.New("allowed_tools and allowed_tools_from are mutually exclusive")
+	}
+	u, err := url.Parse(sc.AllowedToolsFrom)
+	if err != nil {
+		return fmt.Errorf("invalid allowed_tools_from '%s': %w", sc.AllowedToolsFrom, err)
+	}
+	switch {
+	case u.Scheme == "file" && path.IsAbs(u.Path):
+		return nil
+	case u.Scheme == "https" && u.Host != "":
+		return nil
+	}
+	return fmt.Errorf("invalid allowed_tools_from '%s': must be a file:///absolute/path or https:// URL", sc.AllowedToolsFrom)
+}
+
+// toolPolicy is the allowed_tools list of a server loaded from allowed_tools_from.
+// Unlike allowed_tools, an empty or not yet loaded list allows no tool, and entries may
+// be globs such as "search_*".
+type toolPolicy struct {
+	source string
+
+	mu      sync.Mutex
+	entries []string
+	body    []byte // Last loaded list, to detect changes of a file
+	etag    string // ETag of the last loaded list, for conditional requests
+	err     error  // Why the last load failed; the list is stale while set
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// allows reports whether the list admits toolName under the match mode of sc.
+func (p *toolPolicy) allows(sc MCPServerConfig, toolName string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := sc.matchKey(toolName)
+	return slices.ContainsFunc(p.entries, func(entry string) bool {
+		pattern := sc.matchKey(entry)
+		if matched, err := path.Match(pattern, key); err == nil && matched {
+			return true
+		}
+		return pattern == key
+	})
+}
+
+// startToolPolicy loads allowed_tools_from, so that discovery partitions the tools by it,
+// and reloads it in the background until Shutdown.
+func (s *MCPServer) startToolPolicy() {
+	if s.Config.AllowedToolsFrom == "" || s.policy != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.policy = &toolPolicy{source: s.Config.AllowedToolsFrom, cancel: cancel, done: make(chan struct{})}
+	s.reloadToolPolicy(ctx)
+	go s.runToolPolicyRefresh(ctx)
+}
+
+// stopToolPolicy stops reloading allowed_tools_from.
+func (s *MCPServer) stopToolPolicy() {
+	if s.policy != nil {
+		s.policy.cancel()
+		<-s.policy.done
+	}
+}
+
+// ToolPolicyError returns why the last load of allowed_tools_from failed, leaving the
+// list stale, or nil while it is current or the server has no allowed_tools_from.
+func (s *MCPServer) ToolPolicyError() error {
+	if s.policy == nil {
+		return nil
+	}
+	s.policy.mu.Lock()
+	defer s.policy.mu.Unlock()
+	return s.policy.err
+}
+
+// AllowedTools returns the allowed_tools in effect: the loaded allowed_tools_from list,
+// or the configured allowed_tools.
+func (s *MCPServer) AllowedTools() []string {
+	if s.policy == nil {
+		return s.Config.AllowedTools
+	}
+	s.policy.mu.Lock()
+	defer s.policy.mu.Unlock()
+	return slices.Clone(s.policy.entries)
+}
+
+// runToolPolicyRefresh reloads allowed_tools_from every allowed_tools_refresh_seconds and
+// re-partitions the tools when it changed.
+func (s *MCPServer) runToolPolicyRefresh(ctx context.Context) {
+	defer close(s.policy.done)
+	ticker := time.NewTicker(s.Config.AllowedToolsRefresh())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.reloadToolPolicy(ctx) {
+				s.repartitionTools()
+			}
+		}
+	}
+}
+
+// reloadToolPolicy loads allowed_tools_from and reports whether the list changed. A
+// failed load keeps the previous list and marks it stale.
+func (s *MCPServer) reloadToolPolicy(ctx context.Context) bool {
+	p := s.policy
+	p.mu.Lock()
+	etag := p.etag
+	p.mu.Unlock()
+	body, newETag, err := fetchToolPolicy(ctx, p.source, etag)
+	if ctx.Err() != nil {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		if p.err == nil {
+			kept := "keeping the previous list"
+			if p.body == nil {
+				kept = "allowing no tools until it loads"
+			}
+			log.Printf("Failed to load allowed_tools_from of MCP server %s, %s: %v", s.Config.Name, kept, err)
+		}
+		p.err = err
+		return false
+	}
+	if p.err != nil {
+		log.Printf("Loaded allowed_tools_from of MCP server %s again", s.Config.Name)
+		p.err = nil
+	}
+	if body == nil {
+		// Not modified
+		return false
+	}
+	if p.body != nil && bytes.Equal(body, p.body) {
+		p.etag = newETag
+		return false
+	}
+	first := p.body == nil
+	entries := parseToolPolicy(body)
+	added, removed := diffEntries(p.entries, entries)
+	p.entries, p.body, p.etag = entries, body, newETag
+	if first {
+		log.Printf("Loaded %d allowed_tools_from entries for MCP server %s", len(entries), s.Config.Name)
+		return true
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return false
+	}
+	log.Printf("allowed_tools_from of MCP server %s changed: added %v, removed %v", s.Config.Name, added, removed)
+	return true
+}
+
+// repartitionTools sorts the tools of the last discovery into allowed and restricted
+// again after allowed_tools_from changed, without asking the backend.
+func (s *MCPServer) repartitionTools() {
+	s.mu.Lock()
+	allowed, restricted := s.partitionTools(s.discoveredTools)
+	changed := toolsetChanged(s.tools, allowed)
+	s.tools = allowed
+	s.restrictedTools = restricted
+	s.mu.Unlock()
+	if changed {
+		s.emit(EventToolsetChanged, nil)
+	}
+}
+
+// fetchToolPolicy reads the list at source. For an https URL it sends etag as
+// If-None-Match, and returns a nil body when the list is not modified.
+func fetchToolPolicy(ctx context.Context, source, etag string) (body []byte, newETag string, err error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, "", err
+	}
+	if u.Scheme == "file" {
+		body, err := os.ReadFile(u.Path)
+		return body, "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := policyHTTPClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, etag, nil
+	case http.StatusOK:
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxToolPolicySize))
+		return body, resp.Header.Get("ETag"), err
+	}
+	return nil, "", fmt.Errorf("GET %s: unexpected status %s", source, resp.Status)
+}
+
+// maxToolPolicySize bounds an allowed_tools_from list fetched over HTTPS.
+const maxToolPolicySize = 1 << 20
+
+// parseToolPolicy returns the entries of a list, one per line. Blank lines and lines
+// starting with '#' are skipped.
+func parseToolPolicy(body []byte) []string {
+	entries := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			entries = append(entries, line)
+		}
+	}
+	return entries
+}
+
+// diffEntries returns the entries of next missing from prev, and those of prev missing
+// from next.
+func diffEntries(prev, next []string) (added, removed []string) {
+	for _, entry := range next {
+		if !slices.Contains(prev, entry) {
+			added = append(added, entry)
+		}
+	}
+	for _, entry := range prev {
+		if !slices.Contains(next, entry) {
+			removed = append(removed, entry)
+		}
+	}
+	return added, removed
+}
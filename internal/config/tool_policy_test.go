@@ -0,0 +1,103 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+var policyTestTools = []ToolInfo{{Name: "search_repos"}, {Name: "search_code"}, {Name: "read_file"}, {Name: "delete_repo"}}
+
+// TestToolPolicy_File tests that an allowed_tools_from file, with globs, partitions the
+// tools, that edits re-partition them without discovery and that a failed reload keeps
+// the previous list and marks it stale.
+func TestToolPolicy_File(t *testing.T) {
+	list := filepath.Join(t.TempDir(), "allowed.txt")
+	if err := os.WriteFile(list, []byte("# Reviewed by security\nsearch_*\n\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	server := &MCPServer{Config: MCPServerConfig{Name: "gh", AllowedToolsFrom: "file://" + list, AllowedToolsRefreshSeconds: 1}}
+	server.startToolPolicy()
+	defer server.stopToolPolicy()
+	server.applyDiscovered(policyTestTools, nil)
+
+	if got := toolNames(server); !slices.Equal(got, []string{"search_repos", "search_code"}) {
+		t.Fatalf("allowed tools = %v, want the search_* tools", got)
+	}
+	if server.IsToolAllowed("delete_repo") {
+		t.Error("expected delete_repo to be restricted")
+	}
+
+	if err := os.WriteFile(list, []byte("search_repos\nread_file\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 5*time.Second, func() bool { return slices.Equal(toolNames(server), []string{"search_repos", "read_file"}) })
+	if got := server.AllowedTools(); !slices.Equal(got, []string{"search_repos", "read_file"}) {
+		t.Errorf("AllowedTools() = %v", got)
+	}
+
+	if err := os.Remove(list); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 5*time.Second, func() bool { return server.ToolPolicyError() != nil })
+	if got := toolNames(server); !slices.Equal(got, []string{"search_repos", "read_file"}) {
+		t.Errorf("allowed tools after a failed reload = %v, want the previous list kept", got)
+	}
+}
+
+// TestToolPolicy_HTTPS tests that an allowed_tools_from URL is refreshed with
+// If-None-Match, and that a list that never loaded allows no tools.
+func TestToolPolicy_HTTPS(t *testing.T) {
+	var mu sync.Mutex
+	body, etag := "read_file\n", `"v1"`
+	notModified := 0
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(body))
+	}))
+	defer backend.Close()
+	origClient := policyHTTPClient
+	policyHTTPClient = backend.Client()
+	defer func() { policyHTTPClient = origClient }()
+
+	server := &MCPServer{Config: MCPServerConfig{Name: "gh", AllowedToolsFrom: backend.URL + "/allowed.txt", AllowedToolsRefreshSeconds: 1}}
+	server.startToolPolicy()
+	defer server.stopToolPolicy()
+	server.applyDiscovered(policyTestTools, nil)
+	if got := toolNames(server); !slices.Equal(got, []string{"read_file"}) {
+		t.Fatalf("allowed tools = %v, want [read_file]", got)
+	}
+	waitFor(t, 5*time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return notModified > 0
+	})
+
+	mu.Lock()
+	body, etag = "read_file\ndelete_repo\n", `"v2"`
+	mu.Unlock()
+	waitFor(t, 5*time.Second, func() bool { return slices.Equal(toolNames(server), []string{"read_file", "delete_repo"}) })
+
+	unreachable := &MCPServer{Config: MCPServerConfig{Name: "down", AllowedToolsFrom: "https://127.0.0.1:1/allowed.txt"}}
+	unreachable.startToolPolicy()
+	defer unreachable.stopToolPolicy()
+	unreachable.applyDiscovered(policyTestTools, nil)
+	if got := toolNames(unreachable); len(got) != 0 {
+		t.Errorf("allowed tools without a loaded list = %v, want none", got)
+	}
+	if unreachable.ToolPolicyError() == nil {
+		t.Error("expected the policy to be marked stale")
+	}
+}
@@ -0,0 +1,413 @@
+// Package jsonschema validates decoded JSON values against the subset of JSON Schema
+// used by MCP tool schemas.
+//
+// Supported keywords: type, enum, const, properties, required, additionalProperties,
+// items, minItems, maxItems, minLength, maxLength, pattern, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum, allOf, anyOf and oneOf. Other keywords, including
+// $ref and format, are ignored.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a compiled schema. It is safe for concurrent use.
+type Schema struct {
+	types []string
+	enum  []interface{}
+	cnst  *interface{}
+
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema // Nil allows any additional property
+	noAdditional         bool    // additionalProperties: false
+
+	items              *Schema
+	minItems, maxItems *int
+
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+
+	minimum, maximum                   *float64
+	exclusiveMinimum, exclusiveMaximum *float64
+
+	allOf, anyOf, oneOf []*Schema
+}
+
+// Compile compiles a schema decoded from JSON. A nil schema accepts every value.
+func Compile(schema map[string]interface{}) (*Schema, error) {
+	return compile(schema, "")
+}
+
+// compile compiles schema found at path, used in error messages.
+func compile(schema map[string]interface{}, path string) (*Schema, error) {
+	s := &Schema{}
+	at := func(keyword string) string { return strings.TrimPrefix(path+"/"+keyword, "/") }
+
+	switch t := schema["type"].(type) {
+	case nil:
+	case string:
+		s.types = []string{t}
+	case []interface{}:
+		for _, v := range t {
+			name, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: type entries must be strings", at("type"))
+			}
+			s.types = append(s.types, name)
+		}
+	default:
+		return nil, fmt.Errorf("%s: must be a string or an array", at("type"))
+	}
+	if enum, ok := schema["enum"]; ok {
+		values, ok := enum.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: must be an array", at("enum"))
+		}
+		s.enum = values
+	}
+	if c, ok := schema["const"]; ok {
+		s.cnst = &c
+	}
+
+	if props, ok := schema["properties"]; ok {
+		propMap, ok := props.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: must be an object", at("properties"))
+		}
+		s.properties = make(map[string]*Schema, len(propMap))
+		for name, prop := range propMap {
+			sub, err := compileSub(prop, at("properties/"+name))
+			if err != nil {
+				return nil, err
+			}
+			s.properties[name] = sub
+		}
+	}
+	if req, ok := schema["required"]; ok {
+		names, ok := req.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: must be an array", at("required"))
+		}
+		for _, v := range names {
+			name, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: entries must be strings", at("required"))
+			}
+			s.required = append(s.required, name)
+		}
+	}
+	switch ap := schema["additionalProperties"].(type) {
+	case nil:
+	case bool:
+		s.noAdditional = !ap
+	default:
+		sub, err := compileSub(ap, at("additionalProperties"))
+		if err != nil {
+			return nil, err
+		}
+		s.additionalProperties = sub
+	}
+
+	if items, ok := schema["items"]; ok {
+		sub, err := compileSub(items, at("items"))
+		if err != nil {
+			return nil, err
+		}
+		s.items = sub
+	}
+
+	var err error
+	ints := map[string]**int{"minItems": &s.minItems, "maxItems": &s.maxItems, "minLength": &s.minLength, "maxLength": &s.maxLength}
+	for keyword, dst := range ints {
+		if *dst, err = intKeyword(schema, keyword, at(keyword)); err != nil {
+			return nil, err
+		}
+	}
+	nums := map[string]**float64{"minimum": &s.minimum, "maximum": &s.maximum, "exclusiveMinimum": &s.exclusiveMinimum, "exclusiveMaximum": &s.exclusiveMaximum}
+	for keyword, dst := range nums {
+		if *dst, err = numberKeyword(schema, keyword, at(keyword)); err != nil {
+			return nil, err
+		}
+	}
+	if p, ok := schema["pattern"]; ok {
+		pattern, ok := p.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: must be a string", at("pattern"))
+		}
+		if s.pattern, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("%s: %w", at("pattern"), err)
+		}
+	}
+
+	combinators := map[string]*[]*Schema{"allOf": &s.allOf, "anyOf": &s.anyOf, "oneOf": &s.oneOf}
+	for keyword, dst := range combinators {
+		raw, ok := schema[keyword]
+		if !ok {
+			continue
+		}
+		list, ok := raw.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: must be an array", at(keyword))
+		}
+		for i, item := range list {
+			sub, err := compileSub(item, at(fmt.Sprintf("%s/%d", keyword, i)))
+			if err != nil {
+				return nil, err
+			}
+			*dst = append(*dst, sub)
+		}
+	}
+	return s, nil
+}
+
+// compileSub compiles a nested schema, which may also be the boolean true.
+func compileSub(v interface{}, path string) (*Schema, error) {
+	switch sub := v.(type) {
+	case map[string]interface{}:
+		return compile(sub, path)
+	case bool:
+		if sub {
+			return &Schema{}, nil
+		}
+		return &Schema{enum: []interface{}{}}, nil // false: nothing matches
+	default:
+		return nil, fmt.Errorf("%s: must be a schema object", path)
+	}
+}
+
+// intKeyword reads a non-negative integer keyword.
+func intKeyword(schema map[string]interface{}, keyword, path string) (*int, error) {
+	raw, ok := schema[keyword]
+	if !ok {
+		return nil, nil
+	}
+	f, ok := raw.(float64)
+	if !ok || f < 0 || f != math.Trunc(f) {
+		return nil, fmt.Errorf("%s: must be a non-negative integer", path)
+	}
+	n := int(f)
+	return &n, nil
+}
+
+// numberKeyword reads a number keyword. A boolean exclusiveMinimum/Maximum (draft 4
+// style) is ignored.
+func numberKeyword(schema map[string]interface{}, keyword, path string) (*float64, error) {
+	switch v := schema[keyword].(type) {
+	case nil, bool:
+		return nil, nil
+	case float64:
+		return &v, nil
+	default:
+		return nil, fmt.Errorf("%s: must be a number", path)
+	}
+}
+
+// Validate checks a value decoded with encoding/json (maps, slices, float64, string,
+// bool or nil) against the schema. The error names the first failing location.
+func (s *Schema) Validate(v interface{}) error {
+	if s == nil {
+		return nil
+	}
+	return s.validate(normalize(v), "")
+}
+
+// normalize converts v to the generic types produced by decoding JSON into interface{}.
+func normalize(v interface{}) interface{} {
+	switch v.(type) {
+	case nil, bool, float64, string, []interface{}, map[string]interface{}:
+		return v
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if json.Unmarshal(data, &out) != nil {
+		return v
+	}
+	return out
+}
+
+// validate checks v at path.
+func (s *Schema) validate(v interface{}, path string) error {
+	where := path
+	if where == "" {
+		where = "value"
+	}
+	fail := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%s: %s", where, fmt.Sprintf(format, args...))
+	}
+
+	if len(s.types) > 0 && !matchesAnyType(v, s.types) {
+		return fail("expected %s, got %s", strings.Join(s.types, " or "), typeName(v))
+	}
+	if s.enum != nil && !containsValue(s.enum, v) {
+		return fail("not one of the allowed values")
+	}
+	if s.cnst != nil && !reflect.DeepEqual(*s.cnst, v) {
+		return fail("does not equal the expected constant")
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.required {
+			if _, ok := val[name]; !ok {
+				return fail("missing required property '%s'", name)
+			}
+		}
+		names := make([]string, 0, len(val))
+		for name := range val {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			child := joinPath(path, name)
+			if prop, ok := s.properties[name]; ok {
+				if err := prop.validate(val[name], child); err != nil {
+					return err
+				}
+			} else if s.noAdditional {
+				return fail("unexpected property '%s'", name)
+			} else if s.additionalProperties != nil {
+				if err := s.additionalProperties.validate(val[name], child); err != nil {
+					return err
+				}
+			}
+		}
+	case []interface{}:
+		if s.minItems != nil && len(val) < *s.minItems {
+			return fail("expected at least %d items, got %d", *s.minItems, len(val))
+		}
+		if s.maxItems != nil && len(val) > *s.maxItems {
+			return fail("expected at most %d items, got %d", *s.maxItems, len(val))
+		}
+		if s.items != nil {
+			for i, item := range val {
+				if err := s.items.validate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(val)
+		if s.minLength != nil && length < *s.minLength {
+			return fail("expected at least %d characters, got %d", *s.minLength, length)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			return fail("expected at most %d characters, got %d", *s.maxLength, length)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(val) {
+			return fail("does not match pattern '%s'", s.pattern)
+		}
+	case float64:
+		if s.minimum != nil && val < *s.minimum {
+			return fail("must be at least %v", *s.minimum)
+		}
+		if s.maximum != nil && val > *s.maximum {
+			return fail("must be at most %v", *s.maximum)
+		}
+		if s.exclusiveMinimum != nil && val <= *s.exclusiveMinimum {
+			return fail("must be greater than %v", *s.exclusiveMinimum)
+		}
+		if s.exclusiveMaximum != nil && val >= *s.exclusiveMaximum {
+			return fail("must be less than %v", *s.exclusiveMaximum)
+		}
+	}
+
+	for _, sub := range s.allOf {
+		if err := sub.validate(v, path); err != nil {
+			return err
+		}
+	}
+	if len(s.anyOf) > 0 {
+		matched := false
+		for _, sub := range s.anyOf {
+			if sub.validate(v, path) == nil {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fail("does not match any schema in anyOf")
+		}
+	}
+	if len(s.oneOf) > 0 {
+		matches := 0
+		for _, sub := range s.oneOf {
+			if sub.validate(v, path) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fail("matches %d schemas in oneOf, expected exactly 1", matches)
+		}
+	}
+	return nil
+}
+
+// joinPath appends a property name to a dotted path.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// matchesAnyType reports whether v has one of the JSON Schema types.
+func matchesAnyType(v interface{}, types []string) bool {
+	for _, t := range types {
+		switch t {
+		case "integer":
+			if f, ok := v.(float64); ok && f == math.Trunc(f) {
+				return true
+			}
+		case "number":
+			if _, ok := v.(float64); ok {
+				return true
+			}
+		default:
+			if typeName(v) == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// typeName returns the JSON Schema type of a decoded value.
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// containsValue reports whether values contains v.
+func containsValue(values []interface{}, v interface{}) bool {
+	for _, candidate := range values {
+		if reflect.DeepEqual(candidate, v) {
+			return true
+		}
+	}
+	return false
+}
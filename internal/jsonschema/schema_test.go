@@ -0,0 +1,104 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// mustCompile compiles a schema given as JSON.
+func mustCompile(t *testing.T, schemaJSON string) *Schema {
+	t.Helper()
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(schemaJSON), &raw); err != nil {
+		t.Fatalf("invalid schema JSON: %v", err)
+	}
+	s, err := Compile(raw)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	return s
+}
+
+func TestValidate(t *testing.T) {
+	schema := mustCompile(t, `{
+		"type": "object",
+		"properties": {
+			"id": {"type": "integer", "minimum": 1},
+			"name": {"type": "string", "minLength": 1, "pattern": "^[a-z]+$"},
+			"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2},
+			"kind": {"enum": ["a", "b"]},
+			"score": {"anyOf": [{"type": "number"}, {"type": "null"}]}
+		},
+		"required": ["id", "name"],
+		"additionalProperties": false
+	}`)
+
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{"valid", `{"id": 3, "name": "abc", "tags": ["x"], "kind": "a", "score": null}`, ""},
+		{"wrong root type", `[]`, "value: expected object, got array"},
+		{"missing required", `{"id": 3}`, "missing required property 'name'"},
+		{"not an integer", `{"id": 1.5, "name": "abc"}`, "id: expected integer, got number"},
+		{"below minimum", `{"id": 0, "name": "abc"}`, "id: must be at least 1"},
+		{"pattern mismatch", `{"id": 1, "name": "ABC"}`, "name: does not match pattern"},
+		{"item type", `{"id": 1, "name": "abc", "tags": [1]}`, "tags[0]: expected string, got number"},
+		{"too many items", `{"id": 1, "name": "abc", "tags": ["a", "b", "c"]}`, "tags: expected at most 2 items"},
+		{"enum", `{"id": 1, "name": "abc", "kind": "c"}`, "kind: not one of the allowed values"},
+		{"anyOf", `{"id": 1, "name": "abc", "score": "high"}`, "score: does not match any schema in anyOf"},
+		{"additional property", `{"id": 1, "name": "abc", "extra": true}`, "unexpected property 'extra'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.value), &value); err != nil {
+				t.Fatalf("invalid value JSON: %v", err)
+			}
+			err := schema.Validate(value)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateGoValues(t *testing.T) {
+	schema := mustCompile(t, `{"type": "object", "properties": {"n": {"type": "integer"}}}`)
+	if err := schema.Validate(struct {
+		N int `json:"n"`
+	}{N: 2}); err != nil {
+		t.Errorf("Validate() error = %v, want nil", err)
+	}
+	var nilSchema *Schema
+	if err := nilSchema.Validate("anything"); err != nil {
+		t.Errorf("nil schema Validate() error = %v, want nil", err)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		schema  map[string]interface{}
+		wantErr string
+	}{
+		{map[string]interface{}{"type": 5.0}, "type: must be a string or an array"},
+		{map[string]interface{}{"pattern": "("}, "pattern:"},
+		{map[string]interface{}{"minLength": -1.0}, "minLength: must be a non-negative integer"},
+		{map[string]interface{}{"properties": map[string]interface{}{"a": "x"}}, "properties/a: must be a schema object"},
+		{map[string]interface{}{"oneOf": "x"}, "oneOf: must be an array"},
+	}
+	for _, tt := range tests {
+		_, err := Compile(tt.schema)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Compile(%v) error = %v, want it to contain %q", tt.schema, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package proxytest
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// AuditEntry is an admin request logged by the proxy as
+// `Admin audit: actor=<actor> client=<ip> action="<method> <path>" status=<code>`.
+type AuditEntry struct {
+	Actor  string // "-" for rejected requests
+	Client string
+	Action string // Method and request URI, e.g. "POST /admin/refresh"
+	Status int
+}
+
+// auditLinePattern matches the fields of an admin audit log line.
+var auditLinePattern = regexp.MustCompile(`Admin audit: actor=(\S+) client=(\S+) action=("(?:[^"\\]|\\.)*") status=(\d+)`)
+
+// AuditEntries returns the admin audit entries in the proxy's log output, oldest first.
+func AuditEntries(logs string) []AuditEntry {
+	var entries []AuditEntry
+	for _, match := range auditLinePattern.FindAllStringSubmatch(logs, -1) {
+		action, err := strconv.Unquote(match[3])
+		if err != nil {
+			continue
+		}
+		status, _ := strconv.Atoi(match[4])
+		entries = append(entries, AuditEntry{Actor: match[1], Client: match[2], Action: action, Status: status})
+	}
+	return entries
+}
@@ -0,0 +1,21 @@
+package proxytest
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestAuditEntries tests parsing admin audit lines out of log output.
+func TestAuditEntries(t *testing.T) {
+	logs := `2026/10/16 12:00:00 Refreshing server1
+2026/10/16 12:00:00 Admin audit: actor=deploy-bot client=192.0.2.1 action="POST /admin/refresh?server=server1" status=200
+2026/10/16 12:00:01 Admin audit: actor=- client=192.0.2.1 action="GET /debug/pprof/profile?name=\"x\"" status=401
+`
+	want := []AuditEntry{
+		{Actor: "deploy-bot", Client: "192.0.2.1", Action: "POST /admin/refresh?server=server1", Status: 200},
+		{Actor: "-", Client: "192.0.2.1", Action: `GET /debug/pprof/profile?name="x"`, Status: 401},
+	}
+	if got := AuditEntries(logs); !reflect.DeepEqual(got, want) {
+		t.Errorf("AuditEntries = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,240 @@
+// Package proxytest provides fake MCP backends for testing configurations of the
+// proxy and code that talks to it.
+//
+// A Backend is an HTTP server speaking the proxy's REST backend protocol: GET /tools and
+// GET /resources for discovery, POST /tool/{name} for calls and /resource/{name}/... for
+// resources. Its tools return canned results, can be made to fail with an HTTP status
+// or to respond slowly, and every call is recorded for assertions.
+//
+// A StdioBackend is the same for stdio servers: a sh script answering JSON-RPC requests
+// with canned results.
+//
+// A Conversation drives command mode through pipes, either to a CommandProxy wired to
+// io.Pipes or, with StartCommand, to the proxy binary. MetricValue and AuditEntries
+// assert on the side effects of requests in /metrics output and the log.
+//
+// Not provided: starting a proxy in memory. The proxy lives in package main and
+// cannot be imported, so an in-memory proxy needs it moved to a package of its own
+// first. Until then, run the binary with StartCommand or as an HTTP server, and point
+// its mcp_servers entries at Backend.URL or a StdioBackend.
+package proxytest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tool is a tool served by a Backend.
+type Tool struct {
+	Name        string
+	Description string
+	// InputSchema defaults to {"type": "object"}.
+	InputSchema map[string]interface{}
+	// OutputSchema, when set, is listed as the tool's outputSchema.
+	OutputSchema map[string]interface{}
+
+	// Result is returned by calls to the tool; nil returns a result without content.
+	Result *Result
+	// Status, when set, is the HTTP status of call responses. A non-2xx status without
+	// a Result fails calls with a plain text body; with one, Result is sent with the
+	// status, as some backends do for tool errors.
+	Status int
+	// Delay is waited before answering a call, or until the request is canceled.
+	Delay time.Duration
+}
+
+// Result is a canned CallToolResult.
+type Result struct {
+	Text              string      // Returned as a single text content block when not empty
+	IsError           bool        // Marks the result as a tool error
+	StructuredContent interface{} // Returned as structuredContent when not nil
+}
+
+// Resource is a resource served by a Backend.
+type Resource struct {
+	Name     string
+	URI      string
+	MimeType string
+
+	// Body is returned for requests to the resource and its sub-paths, as
+	// application/json, unless Handler is set.
+	Body string
+	// Handler, when set, answers the resource's requests instead of Body.
+	Handler http.HandlerFunc
+}
+
+// Call is a tool call received by a Backend.
+type Call struct {
+	Tool      string
+	Arguments map[string]interface{}
+	Header    http.Header
+}
+
+// Backend is a fake REST MCP backend. Close it when done.
+type Backend struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	tools     []Tool
+	resources []Resource
+	calls     []Call
+}
+
+// NewBackend starts a Backend serving tools and resources.
+func NewBackend(tools []Tool, resources []Resource) *Backend {
+	b := &Backend{tools: tools, resources: resources}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /tools", b.handleTools)
+	mux.HandleFunc("GET /resources", b.handleResources)
+	mux.HandleFunc("POST /tool/{name}", b.handleToolCall)
+	mux.HandleFunc("/resource/", b.handleResource)
+	b.Server = httptest.NewServer(mux)
+	return b
+}
+
+// Calls returns the tool calls received so far, oldest first.
+func (b *Backend) Calls() []Call {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Call(nil), b.calls...)
+}
+
+// SetToolStatus sets the Status of the named tool, e.g. to make its calls fail and,
+// with 0, succeed again. It does not change the advertised tools.
+func (b *Backend) SetToolStatus(name string, status int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := range b.tools {
+		if b.tools[i].Name == name {
+			b.tools[i].Status = status
+		}
+	}
+}
+
+func (b *Backend) tool(name string) (Tool, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, tool := range b.tools {
+		if tool.Name == name {
+			return tool, true
+		}
+	}
+	return Tool{}, false
+}
+
+func (b *Backend) handleTools(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	tools := make([]map[string]interface{}, 0, len(b.tools))
+	for _, tool := range b.tools {
+		tools = append(tools, tool.listing())
+	}
+	b.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tools": tools})
+}
+
+func (b *Backend) handleResources(w http.ResponseWriter, r *http.Request) {
+	resources := make([]map[string]interface{}, 0, len(b.resources))
+	for _, resource := range b.resources {
+		entry := map[string]interface{}{"name": resource.Name}
+		if resource.URI != "" {
+			entry["uri"] = resource.URI
+		}
+		if resource.MimeType != "" {
+			entry["mimeType"] = resource.MimeType
+		}
+		resources = append(resources, entry)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"resources": resources})
+}
+
+func (b *Backend) handleToolCall(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	tool, ok := b.tool(name)
+	if !ok {
+		http.Error(w, "unknown tool "+name, http.StatusNotFound)
+		return
+	}
+	var arguments map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&arguments)
+	b.mu.Lock()
+	b.calls = append(b.calls, Call{Tool: name, Arguments: arguments, Header: r.Header.Clone()})
+	b.mu.Unlock()
+
+	if tool.Delay > 0 {
+		select {
+		case <-time.After(tool.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	status := tool.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if tool.Result == nil && (status < 200 || status >= 300) {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	writeJSON(w, status, tool.Result.callToolResult())
+}
+
+func (b *Backend) handleResource(w http.ResponseWriter, r *http.Request) {
+	name, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/resource/"), "/")
+	for _, resource := range b.resources {
+		if resource.Name != name {
+			continue
+		}
+		if resource.Handler != nil {
+			resource.Handler(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(resource.Body))
+		return
+	}
+	http.Error(w, "unknown resource "+name, http.StatusNotFound)
+}
+
+// listing returns the tool's entry in a tools listing.
+func (tool Tool) listing() map[string]interface{} {
+	schema := tool.InputSchema
+	if schema == nil {
+		schema = map[string]interface{}{"type": "object"}
+	}
+	entry := map[string]interface{}{"name": tool.Name, "inputSchema": schema}
+	if tool.Description != "" {
+		entry["description"] = tool.Description
+	}
+	if tool.OutputSchema != nil {
+		entry["outputSchema"] = tool.OutputSchema
+	}
+	return entry
+}
+
+// callToolResult returns the MCP CallToolResult for r.
+func (r *Result) callToolResult() map[string]interface{} {
+	result := map[string]interface{}{"content": []interface{}{}}
+	if r == nil {
+		return result
+	}
+	if r.Text != "" {
+		result["content"] = []interface{}{map[string]interface{}{"type": "text", "text": r.Text}}
+	}
+	if r.IsError {
+		result["isError"] = true
+	}
+	if r.StructuredContent != nil {
+		result["structuredContent"] = r.StructuredContent
+	}
+	return result
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,73 @@
+package proxytest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestBackend tests discovery, canned results, induced failures and call recording.
+func TestBackend(t *testing.T) {
+	backend := NewBackend([]Tool{
+		{Name: "echo", Result: &Result{Text: "hi", StructuredContent: map[string]interface{}{"n": 1}}},
+		{Name: "broken", Status: http.StatusBadGateway},
+	}, []Resource{{Name: "docs", MimeType: "text/plain", Body: `{"page":1}`}})
+	defer backend.Close()
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(backend.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	call := func(tool, args string) (int, string) {
+		resp, err := http.Post(backend.URL+"/tool/"+tool, "application/json", strings.NewReader(args))
+		if err != nil {
+			t.Fatalf("calling %s failed: %v", tool, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	var tools struct {
+		Tools []struct {
+			Name        string                 `json:"name"`
+			InputSchema map[string]interface{} `json:"inputSchema"`
+		} `json:"tools"`
+	}
+	_, body := get("/tools")
+	if err := json.Unmarshal([]byte(body), &tools); err != nil || len(tools.Tools) != 2 || tools.Tools[0].InputSchema["type"] != "object" {
+		t.Errorf("GET /tools = %s", body)
+	}
+	if _, body := get("/resources"); !strings.Contains(body, `"mimeType":"text/plain"`) {
+		t.Errorf("GET /resources = %s", body)
+	}
+	if status, body := get("/resource/docs/page1"); status != http.StatusOK || body != `{"page":1}` {
+		t.Errorf("GET /resource/docs/page1 = %d %s", status, body)
+	}
+
+	if status, body := call("echo", `{"a":1}`); status != http.StatusOK || !strings.Contains(body, `"text":"hi"`) || !strings.Contains(body, `"structuredContent":{"n":1}`) {
+		t.Errorf("echo = %d %s", status, body)
+	}
+	if status, _ := call("broken", `{}`); status != http.StatusBadGateway {
+		t.Errorf("broken status = %d", status)
+	}
+	backend.SetToolStatus("broken", 0)
+	if status, _ := call("broken", `{}`); status != http.StatusOK {
+		t.Errorf("recovered broken status = %d", status)
+	}
+	if status, _ := call("missing", `{}`); status != http.StatusNotFound {
+		t.Errorf("missing tool status = %d", status)
+	}
+
+	calls := backend.Calls()
+	if len(calls) != 3 || calls[0].Tool != "echo" || calls[0].Arguments["a"] != 1.0 {
+		t.Errorf("calls = %+v", calls)
+	}
+}
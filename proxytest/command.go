@@ -0,0 +1,174 @@
+package proxytest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Message is a JSON-RPC message written by a command-mode proxy: a response, a
+// notification, or a request relayed from a backend.
+type Message struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *RPCError       `json:"error,omitempty"`
+}
+
+// RPCError is the error of a JSON-RPC response.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("JSON-RPC error %d: %s", e.Code, e.Message)
+}
+
+// Conversation drives a command-mode proxy through its input and output: requests are
+// written one per line and the proxy's messages read one per line.
+type Conversation struct {
+	// Timeout bounds each wait for a message from the proxy. Defaults to 10 seconds.
+	Timeout time.Duration
+
+	in       io.WriteCloser
+	messages chan []byte
+	readErr  error // Set before messages is closed
+	cmd      *exec.Cmd
+
+	mu      sync.Mutex
+	nextID  int
+	skipped []Message
+}
+
+// NewConversation starts a conversation with a proxy reading its requests from in and
+// writing its messages to out, e.g. the ends of two io.Pipes.
+func NewConversation(in io.WriteCloser, out io.Reader) *Conversation {
+	c := &Conversation{Timeout: 10 * time.Second, in: in, messages: make(chan []byte, 16)}
+	go func() {
+		lines := bufio.NewScanner(out)
+		lines.Buffer(nil, 16<<20)
+		for lines.Scan() {
+			c.messages <- append([]byte(nil), lines.Bytes()...)
+		}
+		c.readErr = lines.Err()
+		if c.readErr == nil {
+			c.readErr = io.EOF
+		}
+		close(c.messages)
+	}()
+	return c
+}
+
+// StartCommand starts cmd, e.g. the proxy binary run with -mode command and -config,
+// and returns a conversation over its stdin and stdout. Close waits for it to exit.
+func StartCommand(cmd *exec.Cmd) (*Conversation, error) {
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	c := NewConversation(in, out)
+	c.cmd = cmd
+	return c, nil
+}
+
+// Send writes one raw message, such as a notification or a response to a relayed request.
+func (c *Conversation) Send(message string) error {
+	_, err := io.WriteString(c.in, message+"\n")
+	return err
+}
+
+// Read returns the next message written by the proxy.
+func (c *Conversation) Read() (Message, error) {
+	select {
+	case line, ok := <-c.messages:
+		if !ok {
+			return Message{}, c.readErr
+		}
+		var msg Message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return Message{}, fmt.Errorf("invalid message %s: %w", line, err)
+		}
+		return msg, nil
+	case <-time.After(c.Timeout):
+		return Message{}, fmt.Errorf("no message from the proxy within %s", c.Timeout)
+	}
+}
+
+// Call sends a request for method with params under a new id and returns the response
+// to it. A JSON-RPC error response is returned as a *RPCError. Messages read before the
+// response, like notifications, are kept for Skipped.
+func (c *Conversation) Call(method string, params interface{}) (json.RawMessage, error) {
+	c.mu.Lock()
+	c.nextID++
+	id := fmt.Sprint(c.nextID)
+	c.mu.Unlock()
+	request, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": json.RawMessage(id), "method": method, "params": params})
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Send(string(request)); err != nil {
+		return nil, err
+	}
+	for {
+		msg, err := c.Read()
+		if err != nil {
+			return nil, err
+		}
+		if string(msg.ID) != id || msg.Method != "" {
+			c.mu.Lock()
+			c.skipped = append(c.skipped, msg)
+			c.mu.Unlock()
+			continue
+		}
+		if msg.Error != nil {
+			return nil, msg.Error
+		}
+		return msg.Result, nil
+	}
+}
+
+// Skipped returns the messages Call read while waiting for its responses, oldest first.
+func (c *Conversation) Skipped() []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Message(nil), c.skipped...)
+}
+
+// Close closes the proxy's input, which ends command mode, and for a conversation
+// from StartCommand waits for the command to exit, discarding messages not read yet.
+// A command still writing after Timeout is killed.
+func (c *Conversation) Close() error {
+	err := c.in.Close()
+	if c.cmd == nil {
+		return err
+	}
+	// The output must be read to its end before waiting for the command
+	timeout := time.After(c.Timeout)
+	for drained := false; !drained; {
+		select {
+		case _, ok := <-c.messages:
+			drained = !ok
+		case <-timeout:
+			c.cmd.Process.Kill()
+			timeout = nil
+		}
+	}
+	if waitErr := c.cmd.Wait(); waitErr != nil {
+		return waitErr
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+package proxytest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os/exec"
+	"testing"
+)
+
+// TestStartCommand tests a conversation with a stdio process: results, JSON-RPC errors,
+// and messages skipped while waiting for a response.
+func TestStartCommand(t *testing.T) {
+	backend := StdioBackend{
+		Tools: []Tool{{Name: "echo", Result: &Result{Text: "hi"}}},
+		Calls: map[string]string{"echo": Echo(`{"jsonrpc":"2.0","method":"notifications/message","params":{}}`) + "\n      " +
+			Reply(`{"content":[{"type":"text","text":"hi"}]}`)},
+	}
+	conversation, err := StartCommand(exec.Command(backend.Command(), backend.Args()...))
+	if err != nil {
+		t.Fatalf("StartCommand failed: %v", err)
+	}
+
+	result, err := conversation.Call("tools/call", map[string]interface{}{"name": "echo"})
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	var call struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(result, &call); err != nil || len(call.Content) != 1 || call.Content[0].Text != "hi" {
+		t.Errorf("unexpected result %s", result)
+	}
+	if skipped := conversation.Skipped(); len(skipped) != 1 || skipped[0].Method != "notifications/message" {
+		t.Errorf("expected the notification to be skipped, got %+v", skipped)
+	}
+
+	_, err = conversation.Call("prompts/list", nil)
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32601 {
+		t.Errorf("expected a -32601 error, got %v", err)
+	}
+
+	if err := conversation.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if _, err := conversation.Read(); err != io.EOF {
+		t.Errorf("expected EOF after Close, got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package proxytest
+
+import (
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// MetricValue returns the value of metric name in the Prometheus text exposition body,
+// as served by the proxy's /metrics, for the series whose labels include labels; nil
+// matches any series. Counters, gauges and untyped metrics give their value, histograms
+// and summaries their sample count. It reports false when no series matches.
+func MetricValue(body, name string, labels map[string]string) (float64, bool) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(body))
+	if err != nil {
+		return 0, false
+	}
+	family, ok := families[name]
+	if !ok {
+		return 0, false
+	}
+	for _, metric := range family.GetMetric() {
+		if !hasLabels(metric, labels) {
+			continue
+		}
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			return metric.GetCounter().GetValue(), true
+		case dto.MetricType_GAUGE:
+			return metric.GetGauge().GetValue(), true
+		case dto.MetricType_HISTOGRAM:
+			return float64(metric.GetHistogram().GetSampleCount()), true
+		case dto.MetricType_SUMMARY:
+			return float64(metric.GetSummary().GetSampleCount()), true
+		default:
+			return metric.GetUntyped().GetValue(), true
+		}
+	}
+	return 0, false
+}
+
+// hasLabels reports whether metric carries every one of labels.
+func hasLabels(metric *dto.Metric, labels map[string]string) bool {
+	for name, value := range labels {
+		found := false
+		for _, pair := range metric.GetLabel() {
+			if pair.GetName() == name && pair.GetValue() == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,35 @@
+package proxytest
+
+import "testing"
+
+// TestMetricValue tests finding series by name and labels in Prometheus text.
+func TestMetricValue(t *testing.T) {
+	body := `# TYPE mcp_proxy_tool_calls_total counter
+mcp_proxy_tool_calls_total{server="a",status="ok"} 3
+mcp_proxy_tool_calls_total{server="b",status="ok"} 5
+# TYPE mcp_proxy_queue_depth gauge
+mcp_proxy_queue_depth 2
+# TYPE mcp_proxy_call_seconds histogram
+mcp_proxy_call_seconds_bucket{le="+Inf"} 4
+mcp_proxy_call_seconds_sum 1.5
+mcp_proxy_call_seconds_count 4
+`
+	for _, tt := range []struct {
+		name   string
+		labels map[string]string
+		want   float64
+		found  bool
+	}{
+		{"mcp_proxy_tool_calls_total", map[string]string{"server": "b"}, 5, true},
+		{"mcp_proxy_tool_calls_total", map[string]string{"server": "a", "status": "ok"}, 3, true},
+		{"mcp_proxy_tool_calls_total", map[string]string{"server": "c"}, 0, false},
+		{"mcp_proxy_queue_depth", nil, 2, true},
+		{"mcp_proxy_call_seconds", nil, 4, true},
+		{"mcp_proxy_missing", nil, 0, false},
+	} {
+		got, found := MetricValue(body, tt.name, tt.labels)
+		if got != tt.want || found != tt.found {
+			t.Errorf("MetricValue(%s, %v) = %v, %v, want %v, %v", tt.name, tt.labels, got, found, tt.want, tt.found)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package proxytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// StdioBackend is a fake stdio MCP backend: a sh script reading JSON-RPC requests line
+// by line and answering each on one line with the request's id. Run it as the Command
+// and Args of an mcp_servers entry.
+//
+// It answers tools/list with Tools and tools/call with the called tool's Result; Status
+// and Delay of a Tool only apply to a Backend. Notifications are read and ignored. Any
+// other method fails with -32601 "Method not found", unless Capabilities or Methods
+// answer it.
+type StdioBackend struct {
+	Tools []Tool
+	// Capabilities, when set, is the JSON of the capabilities returned by initialize.
+	Capabilities string
+	// Calls replaces the answer to calls of the tools named by its keys with a shell
+	// command, which finds the request id in $id. See Reply, ReplyError and Echo.
+	Calls map[string]string
+	// Methods answers the methods named by its keys with a shell command, like Calls.
+	Methods map[string]string
+	// Setup is a shell command run once before the first request is read.
+	Setup string
+}
+
+// Command returns the command running the backend.
+func (b StdioBackend) Command() string {
+	return "sh"
+}
+
+// Args returns the arguments of Command.
+func (b StdioBackend) Args() []string {
+	return []string{"-c", b.Script()}
+}
+
+// Script returns the backend's sh script.
+func (b StdioBackend) Script() string {
+	var script strings.Builder
+	if b.Setup != "" {
+		script.WriteString(b.Setup + "\n")
+	}
+	script.WriteString("while read line; do\n")
+	script.WriteString(`  id=${line#*'"id":'}; id=${id%%,*}` + "\n")
+	script.WriteString("  case \"$line\" in\n")
+	writeCase := func(pattern, command string) {
+		fmt.Fprintf(&script, "    *%s*)\n      %s ;;\n", shellQuote(pattern), command)
+	}
+
+	script.WriteString("    *'\"method\":\"notifications/'*) ;;\n") // Notifications are not answered
+	tools := make([]map[string]interface{}, 0, len(b.Tools))
+	for _, tool := range b.Tools {
+		tools = append(tools, tool.listing())
+	}
+	writeCase(`"tools/list"`, Reply(mustJSON(map[string]interface{}{"tools": tools})))
+	if b.Capabilities != "" {
+		writeCase(`"initialize"`, Reply(`{"protocolVersion":"2025-06-18","capabilities":`+b.Capabilities+`}`))
+	}
+	for _, tool := range b.Tools {
+		command, ok := b.Calls[tool.Name]
+		if !ok {
+			command = Reply(mustJSON(tool.Result.callToolResult()))
+		}
+		writeCase(`"name":`+mustJSON(tool.Name), command)
+	}
+	methods := make([]string, 0, len(b.Methods))
+	for method := range b.Methods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		writeCase(mustJSON(method), b.Methods[method])
+	}
+	script.WriteString("    *) " + ReplyError(-32601, "Method not found") + " ;;\n")
+	script.WriteString("  esac\ndone")
+	return script.String()
+}
+
+// Reply returns a StdioBackend command answering the request with the JSON-RPC result.
+func Reply(result string) string {
+	return echoWithID(`{"jsonrpc":"2.0","id":`, `,"result":`+result+`}`)
+}
+
+// ReplyError returns a StdioBackend command answering the request with a JSON-RPC error.
+func ReplyError(code int, message string) string {
+	return echoWithID(`{"jsonrpc":"2.0","id":`, `,"error":`+mustJSON(map[string]interface{}{"code": code, "message": message})+`}`)
+}
+
+// Echo returns a StdioBackend command writing line as is, e.g. a bare result without
+// a JSON-RPC envelope or a line that is not JSON.
+func Echo(line string) string {
+	return "echo " + shellQuote(line)
+}
+
+// echoWithID returns a command writing prefix, the request id and suffix.
+func echoWithID(prefix, suffix string) string {
+	return "echo " + shellQuote(prefix) + `"$id"` + shellQuote(suffix)
+}
+
+// shellQuote quotes s as a single sh word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func mustJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return string(data)
+}
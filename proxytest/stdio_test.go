@@ -0,0 +1,73 @@
+package proxytest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestStdioBackend tests listing, canned results, replaced calls and method answers of
+// a StdioBackend, and that every response carries its request's id.
+func TestStdioBackend(t *testing.T) {
+	backend := StdioBackend{
+		Tools: []Tool{
+			{Name: "echo", Result: &Result{Text: "it's here"}, OutputSchema: map[string]interface{}{"type": "object"}},
+			{Name: "broken"},
+			{Name: "raw"},
+		},
+		Capabilities: `{"tools":{}}`,
+		Calls: map[string]string{
+			"broken": ReplyError(-32603, "backend crashed"),
+			"raw":    Echo(`{"content":[]}`),
+		},
+		Methods: map[string]string{"ping": Reply(`{}`)},
+	}
+	cmd := exec.Command(backend.Command(), backend.Args()...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Wait()
+	defer stdin.Close()
+	lines := bufio.NewScanner(stdout)
+
+	for i, tt := range []struct {
+		request string
+		want    string
+	}{
+		{`"method":"initialize"`, `{"jsonrpc":"2.0","id":ID,"result":{"protocolVersion":"2025-06-18","capabilities":{"tools":{}}}}`},
+		{`"method":"tools/list"`, `{"jsonrpc":"2.0","id":ID,"result":{"tools":[{"name":"echo","inputSchema":{"type":"object"},"outputSchema":{"type":"object"}},{"name":"broken","inputSchema":{"type":"object"}},{"name":"raw","inputSchema":{"type":"object"}}]}}`},
+		{`"method":"tools/call","params":{"name":"echo"}`, `{"jsonrpc":"2.0","id":ID,"result":{"content":[{"type":"text","text":"it's here"}]}}`},
+		{`"method":"tools/call","params":{"name":"broken"}`, `{"jsonrpc":"2.0","id":ID,"error":{"code":-32603,"message":"backend crashed"}}`},
+		{`"method":"tools/call","params":{"name":"raw"}`, `{"content":[]}`},
+		{`"method":"ping"`, `{"jsonrpc":"2.0","id":ID,"result":{}}`},
+		{`"method":"prompts/list"`, `{"jsonrpc":"2.0","id":ID,"error":{"code":-32601,"message":"Method not found"}}`},
+	} {
+		id := fmt.Sprint(i + 10)
+		io.WriteString(stdin, `{"jsonrpc":"2.0","id":`+id+`,`+tt.request+"}\n")
+		if !lines.Scan() {
+			t.Fatalf("no response to %s: %v", tt.request, lines.Err())
+		}
+		want := map[string]interface{}{}
+		got := map[string]interface{}{}
+		json.Unmarshal([]byte(strings.Replace(tt.want, "ID", id, 1)), &want)
+		if err := json.Unmarshal(lines.Bytes(), &got); err != nil {
+			t.Fatalf("invalid response to %s: %s", tt.request, lines.Bytes())
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("response to %s = %s, want %s", tt.request, lines.Bytes(), strings.Replace(tt.want, "ID", id, 1))
+		}
+	}
+}